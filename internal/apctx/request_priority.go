@@ -0,0 +1,27 @@
+package apctx
+
+import (
+	"context"
+
+	"github.com/rmorlok/authproxy/internal/schema/common"
+)
+
+const requestPriorityKey = "requestPriority"
+
+// WithRequestPriority records the priority class a proxied request was
+// tagged with. Rate limits and request events read it back from the context.
+func WithRequestPriority(ctx context.Context, priority common.RequestPriority) context.Context {
+	return context.WithValue(ctx, requestPriorityKey, priority)
+}
+
+// RequestPriority returns the priority recorded on ctx, or "" when the
+// request was never tagged (e.g. probes and other internal traffic).
+func RequestPriority(ctx context.Context) common.RequestPriority {
+	if ctx == nil {
+		return ""
+	}
+	if p, ok := ctx.Value(requestPriorityKey).(common.RequestPriority); ok {
+		return p
+	}
+	return ""
+}
@@ -0,0 +1,17 @@
+package apctx
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rmorlok/authproxy/internal/schema/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestPriority(t *testing.T) {
+	ctx := context.Background()
+	require.Empty(t, RequestPriority(ctx))
+
+	ctx = WithRequestPriority(ctx, common.RequestPriorityBatch)
+	require.Equal(t, common.RequestPriorityBatch, RequestPriority(ctx))
+}
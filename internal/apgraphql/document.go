@@ -0,0 +1,228 @@
+package apgraphql
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ParseOperation finds the operation definitions in a GraphQL document and
+// returns the one a request with operationName would execute. When
+// operationName is empty the document must contain exactly one operation.
+//
+// Only the top level of the document is read: the keyword, name and the
+// extent of each definition. Selection sets, arguments and fragments are
+// skipped without being validated, so a document the upstream would reject
+// may still yield an operation here.
+func ParseOperation(document, operationName string) (*Operation, error) {
+	ops, err := operations(document)
+	if err != nil {
+		return nil, err
+	}
+	if len(ops) == 0 {
+		return nil, errors.New("graphql document contains no operations")
+	}
+
+	if operationName == "" {
+		if len(ops) > 1 {
+			return nil, errors.New("graphql document contains several operations but no operationName")
+		}
+		return &ops[0], nil
+	}
+
+	for i := range ops {
+		if ops[i].Name == operationName {
+			return &ops[i], nil
+		}
+	}
+	return nil, fmt.Errorf("graphql document has no operation named %q", operationName)
+}
+
+// operations lists the operation definitions at the top level of document,
+// in order.
+func operations(document string) ([]Operation, error) {
+	l := &lexer{src: document}
+
+	var (
+		ops []Operation
+		// depth counts open braces, parentheses and brackets; definitions
+		// only start at depth zero.
+		depth int
+		// started is set once a definition's keyword has been read, so its
+		// selection set isn't mistaken for the query shorthand.
+		started bool
+		// wantName is set after an operation keyword, when the next name
+		// (if any) is the operation's name.
+		wantName bool
+	)
+
+	for {
+		tok, err := l.next()
+		if err != nil {
+			return nil, err
+		}
+		if tok.kind == tokenEOF {
+			break
+		}
+
+		if depth > 0 {
+			switch tok.value {
+			case "{", "(", "[":
+				depth++
+			case "}", ")", "]":
+				depth--
+			}
+			continue
+		}
+
+		switch {
+		case tok.kind == tokenName && wantName:
+			ops[len(ops)-1].Name = tok.value
+			wantName = false
+		case tok.kind == tokenName && !started:
+			switch tok.value {
+			case "query", "mutation", "subscription":
+				ops = append(ops, Operation{Type: OperationType(tok.value)})
+				wantName = true
+			}
+			started = true
+		case tok.kind == tokenPunct && tok.value == "@":
+			// Directives on the definition: skip the directive's name so a
+			// directive called e.g. "query" isn't read as anything else.
+			wantName = false
+			if _, err := l.next(); err != nil {
+				return nil, err
+			}
+		case tok.kind == tokenPunct && (tok.value == "(" || tok.value == "["):
+			wantName = false
+			depth++
+		case tok.kind == tokenPunct && tok.value == "{":
+			if !started {
+				ops = append(ops, Operation{Type: OperationTypeQuery})
+			}
+			wantName = false
+			depth++
+			// Every definition ends with its selection set (or, for type
+			// system definitions, a braced body), so the next top-level
+			// token starts a new definition.
+			started = false
+		case tok.kind == tokenPunct && (tok.value == "}" || tok.value == ")" || tok.value == "]"):
+			return nil, fmt.Errorf("graphql document has an unmatched %q", tok.value)
+		default:
+			wantName = false
+		}
+	}
+
+	if depth != 0 {
+		return nil, errors.New("graphql document ends inside a definition")
+	}
+	return ops, nil
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenName
+	tokenPunct
+	tokenValue
+)
+
+type token struct {
+	kind  tokenKind
+	value string
+}
+
+// lexer splits a GraphQL document into the tokens operations needs.
+// Strings and numbers are returned as opaque values; comments, whitespace
+// and commas are skipped.
+type lexer struct {
+	src string
+	pos int
+}
+
+func (l *lexer) next() (token, error) {
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',':
+			l.pos++
+		case c == '#':
+			for l.pos < len(l.src) && l.src[l.pos] != '\n' && l.src[l.pos] != '\r' {
+				l.pos++
+			}
+		case strings.HasPrefix(l.src[l.pos:], "\uFEFF"):
+			l.pos += len("\uFEFF")
+		case strings.HasPrefix(l.src[l.pos:], `"""`):
+			return l.blockString()
+		case c == '"':
+			return l.string()
+		case isNameStart(c):
+			start := l.pos
+			for l.pos < len(l.src) && isNameContinue(l.src[l.pos]) {
+				l.pos++
+			}
+			return token{kind: tokenName, value: l.src[start:l.pos]}, nil
+		case c == '-' || (c >= '0' && c <= '9'):
+			start := l.pos
+			l.pos++
+			for l.pos < len(l.src) && (isNameContinue(l.src[l.pos]) || l.src[l.pos] == '.' || l.src[l.pos] == '+' || l.src[l.pos] == '-') {
+				l.pos++
+			}
+			return token{kind: tokenValue, value: l.src[start:l.pos]}, nil
+		case strings.HasPrefix(l.src[l.pos:], "..."):
+			l.pos += 3
+			return token{kind: tokenPunct, value: "..."}, nil
+		case strings.ContainsRune("!$&()[]{}:=@|", rune(c)):
+			l.pos++
+			return token{kind: tokenPunct, value: string(c)}, nil
+		default:
+			return token{}, fmt.Errorf("graphql document has an unexpected character %q at offset %d", c, l.pos)
+		}
+	}
+	return token{kind: tokenEOF}, nil
+}
+
+func (l *lexer) string() (token, error) {
+	start := l.pos
+	l.pos++
+	for l.pos < len(l.src) {
+		switch l.src[l.pos] {
+		case '\\':
+			l.pos += 2
+		case '"':
+			l.pos++
+			return token{kind: tokenValue, value: l.src[start:l.pos]}, nil
+		case '\n', '\r':
+			return token{}, fmt.Errorf("graphql document has an unterminated string at offset %d", start)
+		default:
+			l.pos++
+		}
+	}
+	return token{}, fmt.Errorf("graphql document has an unterminated string at offset %d", start)
+}
+
+func (l *lexer) blockString() (token, error) {
+	start := l.pos
+	l.pos += 3
+	for l.pos < len(l.src) {
+		switch {
+		case strings.HasPrefix(l.src[l.pos:], `\"""`):
+			l.pos += 4
+		case strings.HasPrefix(l.src[l.pos:], `"""`):
+			l.pos += 3
+			return token{kind: tokenValue, value: l.src[start:l.pos]}, nil
+		default:
+			l.pos++
+		}
+	}
+	return token{}, fmt.Errorf("graphql document has an unterminated block string at offset %d", start)
+}
+
+func isNameStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isNameContinue(c byte) bool {
+	return isNameStart(c) || (c >= '0' && c <= '9')
+}
@@ -0,0 +1,90 @@
+// Package apgraphql recognises GraphQL operations in proxied request bodies
+// so the rest of the proxy can treat them as more than an opaque
+// POST /graphql.
+package apgraphql
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// OperationType is the kind of a GraphQL operation.
+type OperationType string
+
+const (
+	OperationTypeQuery        OperationType = "query"
+	OperationTypeMutation     OperationType = "mutation"
+	OperationTypeSubscription OperationType = "subscription"
+)
+
+// IsValidOperationType reports whether t is a recognised OperationType.
+func IsValidOperationType(t OperationType) bool {
+	switch t {
+	case OperationTypeQuery, OperationTypeMutation, OperationTypeSubscription:
+		return true
+	default:
+		return false
+	}
+}
+
+// Operation identifies the operation a GraphQL request executes. Name is
+// empty for anonymous operations.
+type Operation struct {
+	Type OperationType
+	Name string
+}
+
+// FromJSON returns the operation executed by a decoded GraphQL request body,
+// i.e. an object with a "query" document and an optional "operationName".
+// It returns nil when v is not a GraphQL request or the operation it names
+// can't be found. Batched requests (a JSON array) are not recognised.
+func FromJSON(v any) *Operation {
+	body, ok := v.(map[string]any)
+	if !ok {
+		return nil
+	}
+	query, ok := body["query"].(string)
+	if !ok || query == "" {
+		return nil
+	}
+	name, _ := body["operationName"].(string)
+
+	op, err := ParseOperation(query, name)
+	if err != nil {
+		return nil
+	}
+	return op
+}
+
+// FromBody is FromJSON for a raw request body.
+func FromBody(body []byte) *Operation {
+	if len(body) == 0 {
+		return nil
+	}
+	var v any
+	if err := json.Unmarshal(body, &v); err != nil {
+		return nil
+	}
+	return FromJSON(v)
+}
+
+type operationKey struct{}
+
+// ContextWithOperation returns a context carrying op. A nil op returns ctx
+// unchanged.
+func ContextWithOperation(ctx context.Context, op *Operation) context.Context {
+	if op == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, operationKey{}, op)
+}
+
+// OperationFromContext returns the operation stamped on ctx by the proxy, or
+// nil when the request was not recognised as GraphQL.
+func OperationFromContext(ctx context.Context) *Operation {
+	if ctx == nil {
+		return nil
+	}
+	op, _ := ctx.Value(operationKey{}).(*Operation)
+	return op
+}
@@ -0,0 +1,102 @@
+package apgraphql
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseOperation(t *testing.T) {
+	tests := []struct {
+		name          string
+		document      string
+		operationName string
+		want          Operation
+	}{
+		{name: "named query", document: `query GetShop { shop { name } }`, want: Operation{Type: OperationTypeQuery, Name: "GetShop"}},
+		{name: "anonymous query", document: `query { viewer { login } }`, want: Operation{Type: OperationTypeQuery}},
+		{name: "shorthand", document: `{ viewer { login } }`, want: Operation{Type: OperationTypeQuery}},
+		{name: "mutation", document: `mutation CreateIssue($input: CreateIssueInput!) { createIssue(input: $input) { issue { id } } }`, want: Operation{Type: OperationTypeMutation, Name: "CreateIssue"}},
+		{name: "subscription", document: `subscription OnEvent { event { id } }`, want: Operation{Type: OperationTypeSubscription, Name: "OnEvent"}},
+		{
+			name:     "variables with object defaults and directives",
+			document: `query Search($filter: Filter = {status: "open", tags: ["a", "b"]}, $first: Int = 10) @cached(ttl: 60) { search(filter: $filter, first: $first) { id } }`,
+			want:     Operation{Type: OperationTypeQuery, Name: "Search"},
+		},
+		{
+			name: "fragments, comments and strings are skipped",
+			document: `
+				# query Commented { x }
+				fragment Fields on Issue { id title }
+				query Issues {
+					issues(query: "mutation Fake { x }", body: """
+						subscription AlsoFake { "quoted" \""" }
+					""") { ...Fields }
+				}`,
+			want: Operation{Type: OperationTypeQuery, Name: "Issues"},
+		},
+		{
+			name:          "selects by operation name",
+			document:      `query A { a } mutation B { b } query C { c }`,
+			operationName: "B",
+			want:          Operation{Type: OperationTypeMutation, Name: "B"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			op, err := ParseOperation(tt.document, tt.operationName)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, *op)
+		})
+	}
+}
+
+func TestParseOperation_Errors(t *testing.T) {
+	tests := []struct {
+		name          string
+		document      string
+		operationName string
+		wantErr       string
+	}{
+		{name: "empty", document: ``, wantErr: "no operations"},
+		{name: "only fragments", document: `fragment F on User { id }`, wantErr: "no operations"},
+		{name: "ambiguous", document: `query A { a } query B { b }`, wantErr: "no operationName"},
+		{name: "unknown name", document: `query A { a }`, operationName: "B", wantErr: `no operation named "B"`},
+		{name: "unbalanced", document: `query A { a`, wantErr: "ends inside a definition"},
+		{name: "unmatched close", document: `query A { a } }`, wantErr: "unmatched"},
+		{name: "unterminated string", document: `query A { a(x: "oops) }`, wantErr: "unterminated string"},
+		{name: "unterminated block string", document: `query A { a(x: """oops) }`, wantErr: "unterminated block string"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseOperation(tt.document, tt.operationName)
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestFromBody(t *testing.T) {
+	op := FromBody([]byte(`{"query":"query A { a } mutation B { b }","operationName":"B","variables":{}}`))
+	require.NotNil(t, op)
+	assert.Equal(t, Operation{Type: OperationTypeMutation, Name: "B"}, *op)
+
+	assert.Nil(t, FromBody(nil))
+	assert.Nil(t, FromBody([]byte(`not json`)))
+	assert.Nil(t, FromBody([]byte(`{"name":"Ada"}`)))
+	assert.Nil(t, FromBody([]byte(`[{"query":"{ a }"}]`)))
+	assert.Nil(t, FromBody([]byte(`{"query":"query A { a } query B { b }"}`)))
+}
+
+func TestContextWithOperation(t *testing.T) {
+	ctx := context.Background()
+	assert.Nil(t, OperationFromContext(ctx))
+	assert.Nil(t, OperationFromContext(nil))
+	assert.Equal(t, ctx, ContextWithOperation(ctx, nil))
+
+	op := &Operation{Type: OperationTypeQuery, Name: "GetShop"}
+	assert.Same(t, op, OperationFromContext(ContextWithOperation(ctx, op)))
+}
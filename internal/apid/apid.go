@@ -26,7 +26,9 @@ const (
 	PrefixConnectorDefinitionVersion Prefix = "cvd_"
 	PrefixOAuth2Token                Prefix = "tok_"
 	PrefixOauth2State                Prefix = "oas_"
+	PrefixOauth1State                Prefix = "o1s_"
 	PrefixApiKeyCredential           Prefix = "akc_"
+	PrefixClientCertificate          Prefix = "ccr_"
 	PrefixProbeOutcome               Prefix = "pou_"
 	PrefixNonce                      Prefix = "non_"
 	PrefixRequestEvents              Prefix = "req_"
@@ -52,12 +54,14 @@ var validPrefixes = map[Prefix]bool{
 	PrefixConnectorDefinitionVersion: true,
 	PrefixOAuth2Token:                true,
 	PrefixApiKeyCredential:           true,
+	PrefixClientCertificate:          true,
 	PrefixProbeOutcome:               true,
 	PrefixNonce:                      true,
 	PrefixRequestEvents:              true,
 	PrefixCorrelation:                true,
 	PrefixJwtId:                      true,
 	PrefixOauth2State:                true,
+	PrefixOauth1State:                true,
 	PrefixSession:                    true,
 	PrefixKey:                        true,
 	PrefixDataEncryptionKey:          true,
@@ -0,0 +1,88 @@
+package apjs
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Budget bounds the resources a single expression evaluation may use. The
+// library's top-level code runs inside the same budget as the expression.
+// Zero fields fall back to DefaultBudget.
+type Budget struct {
+	// Timeout is the wall-clock time an evaluation may run before it is
+	// interrupted.
+	Timeout time.Duration
+
+	// MaxCallStackSize is the deepest the JavaScript call stack may grow.
+	MaxCallStackSize int
+
+	// MaxOutputSize caps the size, in bytes of JSON, of the value an
+	// expression returns. goja cannot bound allocations directly, so this
+	// keeps a runaway script from handing an unbounded result to its caller.
+	MaxOutputSize uint64
+}
+
+// DefaultBudget applies to evaluations whose budget doesn't set a field.
+var DefaultBudget = Budget{
+	Timeout:          time.Second,
+	MaxCallStackSize: 1000,
+	MaxOutputSize:    1 << 20,
+}
+
+// WithDefaults returns the budget with its zero fields taken from
+// DefaultBudget.
+func (b Budget) WithDefaults() Budget {
+	if b.Timeout <= 0 {
+		b.Timeout = DefaultBudget.Timeout
+	}
+	if b.MaxCallStackSize <= 0 {
+		b.MaxCallStackSize = DefaultBudget.MaxCallStackSize
+	}
+	if b.MaxOutputSize == 0 {
+		b.MaxOutputSize = DefaultBudget.MaxOutputSize
+	}
+	return b
+}
+
+// BudgetLimit identifies which part of a Budget an evaluation exceeded.
+type BudgetLimit string
+
+const (
+	BudgetLimitTimeout       BudgetLimit = "timeout"
+	BudgetLimitCallStackSize BudgetLimit = "call_stack_size"
+	BudgetLimitOutputSize    BudgetLimit = "output_size"
+)
+
+// ErrBudgetExceeded matches any BudgetExceededError with errors.Is.
+var ErrBudgetExceeded = errors.New("script exceeded budget")
+
+// BudgetExceededError is returned when an evaluation is stopped for exceeding
+// its budget rather than failing on its own.
+type BudgetExceededError struct {
+	Limit  BudgetLimit
+	Budget Budget
+}
+
+func (e *BudgetExceededError) Error() string {
+	switch e.Limit {
+	case BudgetLimitTimeout:
+		return fmt.Sprintf("script exceeded budget: ran past its %s time limit", e.Budget.Timeout)
+	case BudgetLimitCallStackSize:
+		return fmt.Sprintf("script exceeded budget: call stack grew past %d frames", e.Budget.MaxCallStackSize)
+	case BudgetLimitOutputSize:
+		return fmt.Sprintf("script exceeded budget: result is larger than %d bytes", e.Budget.MaxOutputSize)
+	default:
+		return ErrBudgetExceeded.Error()
+	}
+}
+
+func (e *BudgetExceededError) Is(target error) bool {
+	return target == ErrBudgetExceeded
+}
+
+// IsBudgetExceeded reports whether err, or any error it wraps, is a
+// BudgetExceededError.
+func IsBudgetExceeded(err error) bool {
+	return errors.Is(err, ErrBudgetExceeded)
+}
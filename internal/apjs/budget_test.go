@@ -0,0 +1,101 @@
+package apjs
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContextBudget(t *testing.T) {
+	t.Run("defaults fill unset fields", func(t *testing.T) {
+		budget := NewContext(nil, nil).WithBudget(Budget{Timeout: 5 * time.Second}).Budget()
+		assert.Equal(t, 5*time.Second, budget.Timeout)
+		assert.Equal(t, DefaultBudget.MaxCallStackSize, budget.MaxCallStackSize)
+		assert.Equal(t, DefaultBudget.MaxOutputSize, budget.MaxOutputSize)
+	})
+
+	t.Run("runaway loop is interrupted without an explicit timeout", func(t *testing.T) {
+		ctx := NewContext(nil, nil).WithBudget(Budget{Timeout: 20 * time.Millisecond})
+
+		_, err := ctx.EvaluateBoolean(`while (true) {}`)
+		var exceeded *BudgetExceededError
+		require.True(t, errors.As(err, &exceeded))
+		assert.Equal(t, BudgetLimitTimeout, exceeded.Limit)
+	})
+
+	t.Run("library top-level code counts against the timeout", func(t *testing.T) {
+		library, err := CompileLibrary(`while (true) {}`)
+		require.NoError(t, err)
+
+		_, err = library.NewContext(nil).WithTimeout(20 * time.Millisecond).EvaluateBoolean(`true`)
+		assert.True(t, IsBudgetExceeded(err))
+	})
+
+	t.Run("deep recursion exceeds the call stack", func(t *testing.T) {
+		ctx := NewContext(nil, nil).WithBudget(Budget{MaxCallStackSize: 50})
+
+		_, err := ctx.Evaluate(`(function f(n) { return n === 0 ? 0 : 1 + f(n - 1); })(100)`)
+		var exceeded *BudgetExceededError
+		require.True(t, errors.As(err, &exceeded))
+		assert.Equal(t, BudgetLimitCallStackSize, exceeded.Limit)
+		assert.Contains(t, err.Error(), "50 frames")
+
+		result, err := ctx.Evaluate(`(function f(n) { return n === 0 ? 0 : 1 + f(n - 1); })(10)`)
+		require.NoError(t, err)
+		assert.EqualValues(t, 10, result)
+	})
+
+	t.Run("oversized results are rejected", func(t *testing.T) {
+		ctx := NewContext(nil, nil).WithBudget(Budget{MaxOutputSize: 100})
+
+		_, err := ctx.EvaluateObject(`({data: "x".repeat(200)})`)
+		var exceeded *BudgetExceededError
+		require.True(t, errors.As(err, &exceeded))
+		assert.Equal(t, BudgetLimitOutputSize, exceeded.Limit)
+
+		result, err := ctx.EvaluateObject(`({data: "x".repeat(20)})`)
+		require.NoError(t, err)
+		assert.Equal(t, strings.Repeat("x", 20), result["data"])
+	})
+
+	t.Run("script errors are not budget errors", func(t *testing.T) {
+		_, err := NewContext(nil, nil).Evaluate(`missing.field`)
+		require.Error(t, err)
+		assert.False(t, IsBudgetExceeded(err))
+	})
+}
+
+func TestLibraryValidateBudget(t *testing.T) {
+	library, err := CompileLibrary(`function f(n) { return f(n + 1); } f(0);`)
+	require.NoError(t, err)
+	assert.True(t, IsBudgetExceeded(library.Validate()))
+}
+
+func TestProgramCache(t *testing.T) {
+	cache := newProgramCache(2)
+
+	first, err := cache.get(`1 + 1`)
+	require.NoError(t, err)
+	again, err := cache.get(`1 + 1`)
+	require.NoError(t, err)
+	assert.Same(t, first, again)
+
+	_, err = cache.get(`2 + 2`)
+	require.NoError(t, err)
+	_, err = cache.get(`3 + 3`)
+	require.NoError(t, err)
+	assert.Equal(t, 2, cache.len())
+
+	// The least recently used program was evicted and is compiled afresh.
+	evicted, err := cache.get(`1 + 1`)
+	require.NoError(t, err)
+	assert.NotSame(t, first, evicted)
+
+	_, err = cache.get(`(`)
+	require.Error(t, err)
+	assert.Equal(t, 2, cache.len())
+}
@@ -1,7 +1,10 @@
 package apjs
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/dop251/goja"
 )
@@ -11,6 +14,7 @@ import (
 type Context struct {
 	library *Library
 	vars    map[string]any
+	budget  Budget
 }
 
 // NewContext builds a JavaScript evaluation context with an optional library.
@@ -32,6 +36,26 @@ func (c Context) WithVar(name string, value any) Context {
 	return c
 }
 
+// WithBudget returns a copy of the context whose evaluations are bounded by
+// b. Zero fields fall back to DefaultBudget.
+func (c Context) WithBudget(b Budget) Context {
+	c.budget = b
+	return c
+}
+
+// Budget returns the budget the context's evaluations run under.
+func (c Context) Budget() Budget {
+	return c.budget.WithDefaults()
+}
+
+// WithTimeout returns a copy of the context whose evaluations are interrupted
+// once they have run for d, including the library's top-level code. The rest
+// of the budget is unchanged; zero restores the default.
+func (c Context) WithTimeout(d time.Duration) Context {
+	c.budget.Timeout = d
+	return c
+}
+
 // EvaluateBoolean runs a JavaScript expression and requires a boolean result.
 // Undefined, null, and other result types are rejected so callers can fail
 // closed instead of guessing intent.
@@ -88,12 +112,36 @@ func (c Context) EvaluateObject(expression string) (map[string]any, error) {
 	return obj, nil
 }
 
+// Evaluate runs a JavaScript expression and exports its result as a JSON-like
+// value. Undefined is returned as nil, like null.
+func (c Context) Evaluate(expression string) (any, error) {
+	result, err := c.runExpression(expression)
+	if err != nil {
+		return nil, err
+	}
+	if goja.IsUndefined(result) || goja.IsNull(result) {
+		return nil, nil
+	}
+	return result.Export(), nil
+}
+
 func (c Context) runExpression(expression string) (goja.Value, error) {
-	vm := goja.New()
+	budget := c.Budget()
+
+	program, err := expressionPrograms.get(expression)
+	if err != nil {
+		return nil, err
+	}
+
+	vm := newBudgetedRuntime(budget)
+	timer := time.AfterFunc(budget.Timeout, func() {
+		vm.Interrupt(BudgetLimitTimeout)
+	})
+	defer timer.Stop()
 
 	if c.library != nil {
 		if err := c.library.run(vm); err != nil {
-			return nil, err
+			return nil, budgetError(budget, err)
 		}
 		if err := validateReservedRuntimeVars(vm); err != nil {
 			return nil, err
@@ -106,9 +154,47 @@ func (c Context) runExpression(expression string) (goja.Value, error) {
 		}
 	}
 
-	result, err := vm.RunString(expression)
+	result, err := vm.RunProgram(program)
 	if err != nil {
-		return nil, fmt.Errorf("JS expression error: %w", err)
+		return nil, budgetError(budget, fmt.Errorf("JS expression error: %w", err))
+	}
+	if err := checkOutputSize(budget, result); err != nil {
+		return nil, err
 	}
 	return result, nil
 }
+
+func newBudgetedRuntime(budget Budget) *goja.Runtime {
+	vm := goja.New()
+	vm.SetMaxCallStackSize(budget.MaxCallStackSize)
+	return vm
+}
+
+// budgetError reports an evaluation stopped by its budget as a
+// BudgetExceededError rather than as a script error.
+func budgetError(budget Budget, err error) error {
+	var interrupted *goja.InterruptedError
+	if errors.As(err, &interrupted) {
+		return &BudgetExceededError{Limit: BudgetLimitTimeout, Budget: budget}
+	}
+	var overflow *goja.StackOverflowError
+	if errors.As(err, &overflow) {
+		return &BudgetExceededError{Limit: BudgetLimitCallStackSize, Budget: budget}
+	}
+	return err
+}
+
+// checkOutputSize rejects results whose JSON encoding is larger than the
+// budget allows. Results that can't be encoded are left for the caller's own
+// type checks.
+func checkOutputSize(budget Budget, result goja.Value) error {
+	if result == nil || goja.IsUndefined(result) || goja.IsNull(result) {
+		return nil
+	}
+
+	data, err := json.Marshal(result.Export())
+	if err == nil && uint64(len(data)) > budget.MaxOutputSize {
+		return &BudgetExceededError{Limit: BudgetLimitOutputSize, Budget: budget}
+	}
+	return nil
+}
@@ -3,6 +3,7 @@ package apjs
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/dop251/goja"
 )
@@ -12,6 +13,10 @@ var reservedRuntimeVars = map[string]struct{}{
 	"labels":      {},
 	"annotations": {},
 	"data":        {},
+	"body":        {},
+	"headers":     {},
+	"request":     {},
+	"response":    {},
 }
 
 // Library is connector-level JavaScript that can define shared constants and
@@ -77,14 +82,21 @@ func (l *Library) Source() string {
 // Validate runs the connector library without any injected runtime variables.
 // This catches top-level initialization errors and prevents connector code from
 // claiming names that AuthProxy injects during predicate and transform calls.
+// The library runs under DefaultBudget.
 func (l *Library) Validate() error {
 	if l == nil {
 		return nil
 	}
 
-	vm := goja.New()
+	budget := DefaultBudget
+	vm := newBudgetedRuntime(budget)
+	timer := time.AfterFunc(budget.Timeout, func() {
+		vm.Interrupt(BudgetLimitTimeout)
+	})
+	defer timer.Stop()
+
 	if err := l.run(vm); err != nil {
-		return err
+		return budgetError(budget, err)
 	}
 	return validateReservedRuntimeVars(vm)
 }
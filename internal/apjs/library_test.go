@@ -2,6 +2,7 @@ package apjs
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -135,3 +136,49 @@ func TestLibraryEvaluationUsesFreshRuntime(t *testing.T) {
 	require.NoError(t, err)
 	assert.True(t, second)
 }
+
+func TestLibraryContextEvaluate(t *testing.T) {
+	library, err := CompileAndValidateLibrary(`
+		function nextCursor(b) {
+			return b.meta.has_more ? b.meta.cursor : null;
+		}
+	`)
+	require.NoError(t, err)
+
+	ctx := library.NewContext(nil).WithVar("body", map[string]any{
+		"items": []any{"a", "b"},
+		"meta":  map[string]any{"has_more": true, "cursor": "c2"},
+	})
+
+	items, err := ctx.Evaluate(`body.items`)
+	require.NoError(t, err)
+	assert.Equal(t, []any{"a", "b"}, items)
+
+	next, err := ctx.Evaluate(`nextCursor(body)`)
+	require.NoError(t, err)
+	assert.Equal(t, "c2", next)
+
+	missing, err := ctx.Evaluate(`body.nope`)
+	require.NoError(t, err)
+	assert.Nil(t, missing)
+}
+
+func TestLibraryContextWithTimeout(t *testing.T) {
+	library, err := CompileAndValidateLibrary(`
+		function spin() {
+			while (true) {}
+		}
+	`)
+	require.NoError(t, err)
+
+	ctx := library.NewContext(nil).WithTimeout(20 * time.Millisecond)
+
+	_, err = ctx.Evaluate(`spin()`)
+	require.Error(t, err)
+	assert.True(t, IsBudgetExceeded(err))
+	assert.Contains(t, err.Error(), "20ms time limit")
+
+	result, err := ctx.EvaluateBoolean(`true`)
+	require.NoError(t, err)
+	assert.True(t, result)
+}
@@ -0,0 +1,79 @@
+package apjs
+
+import (
+	"container/list"
+	"fmt"
+	"sync"
+
+	"github.com/dop251/goja"
+)
+
+// programCacheSize bounds how many compiled expressions are kept. Connector
+// expressions are few and evaluated repeatedly, so this is generous.
+const programCacheSize = 1024
+
+// programCache holds compiled expressions keyed by their source, so hot
+// predicates and transforms are parsed once rather than on every evaluation.
+// Compiled programs are immutable and safe to share between runtimes.
+type programCache struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type programCacheEntry struct {
+	source  string
+	program *goja.Program
+}
+
+var expressionPrograms = newProgramCache(programCacheSize)
+
+func newProgramCache(size int) *programCache {
+	return &programCache{
+		size:    size,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// get returns the compiled program for source, compiling and caching it if
+// needed. Sources that fail to compile are not cached.
+func (c *programCache) get(source string) (*goja.Program, error) {
+	c.mu.Lock()
+	if el, ok := c.entries[source]; ok {
+		c.order.MoveToFront(el)
+		program := el.Value.(*programCacheEntry).program
+		c.mu.Unlock()
+		return program, nil
+	}
+	c.mu.Unlock()
+
+	program, err := goja.Compile("expression.js", source, false)
+	if err != nil {
+		return nil, fmt.Errorf("JS expression error: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[source]; ok {
+		c.order.MoveToFront(el)
+		return el.Value.(*programCacheEntry).program, nil
+	}
+
+	c.entries[source] = c.order.PushFront(&programCacheEntry{source: source, program: program})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*programCacheEntry).source)
+	}
+
+	return program, nil
+}
+
+func (c *programCache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
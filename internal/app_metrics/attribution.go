@@ -2,6 +2,8 @@ package app_metrics
 
 import (
 	"context"
+	"net/http"
+	"time"
 
 	"github.com/rmorlok/authproxy/internal/apid"
 )
@@ -30,6 +32,23 @@ const (
 	// resource enforcement (#223). Defining it here keeps the schema
 	// stable across the two PRs; population happens in #223.
 	ResponseSourceRateLimit ResponseSource = "rate_limit"
+
+	// ResponseSourceUpstreamNotAllowed means the proxy refused to send the
+	// request because its URL did not match the connector's
+	// allowedUpstreams. No credential was resolved and no upstream call
+	// was made.
+	ResponseSourceUpstreamNotAllowed ResponseSource = "upstream_not_allowed"
+
+	// ResponseSourceEgressDenied means the egress policy refused to connect
+	// to the address the request's host (or a redirect hop) resolved to.
+	// No bytes were sent upstream.
+	ResponseSourceEgressDenied ResponseSource = "egress_denied"
+
+	// ResponseSourceCache means the response was served from the
+	// connection's response cache while still fresh. No upstream call was
+	// made. Responses revalidated with the upstream are recorded as
+	// ResponseSourceUpstream.
+	ResponseSourceCache ResponseSource = "cache"
 )
 
 // IsValidResponseSource reports whether s is a recognised ResponseSource.
@@ -40,7 +59,10 @@ func IsValidResponseSource(s ResponseSource) bool {
 	switch s {
 	case ResponseSourceUpstream,
 		ResponseSourceConnectorRateLimiter,
-		ResponseSourceRateLimit:
+		ResponseSourceRateLimit,
+		ResponseSourceUpstreamNotAllowed,
+		ResponseSourceEgressDenied,
+		ResponseSourceCache:
 		return true
 	}
 	return false
@@ -55,6 +77,11 @@ type RateLimitMatch struct {
 	Id     apid.ID           `json:"id"`
 	Mode   string            `json:"mode"`
 	Bucket map[string]string `json:"bucket,omitempty"`
+
+	// QuotaUsed is how many units the request took from a quota rule's
+	// allowance, including any cost the upstream reported. Zero for other
+	// algorithms.
+	QuotaUsed int `json:"quotaUsed,omitempty"`
 }
 
 // Attribution carries per-request events attribution that the proxy stack
@@ -73,6 +100,26 @@ type Attribution struct {
 	RateLimitMode    string
 	RateLimitBucket  map[string]string
 	RateLimitMatched []RateLimitMatch
+
+	// RateLimitQueueWait is written by the rate-limit enforcer when a
+	// shape-mode rule held the request; see LogRecord.RateLimitQueueWait.
+	RateLimitQueueWait time.Duration
+
+	// Attempt and RetryOf are stamped by the proxy orchestrator when it
+	// sends a request more than once; see LogRecord.Attempt.
+	Attempt int
+	RetryOf apid.ID
+
+	// RequestId is written by the request-events round-tripper once it has
+	// assigned the request its id, so the proxy can link later attempts to
+	// the first one.
+	RequestId apid.ID
+
+	// TransformResponseBody, when set by the proxy orchestrator, rewrites
+	// the upstream response body before it is stored in the full request
+	// log, so the connector's response transforms also apply to what is
+	// logged. A nil result stores no body.
+	TransformResponseBody func(statusCode int, header http.Header, body []byte) []byte
 }
 
 type attributionKey struct{}
@@ -4,7 +4,10 @@ import (
 	"context"
 	"testing"
 
+	"github.com/rmorlok/authproxy/internal/apctx"
+	"github.com/rmorlok/authproxy/internal/apgraphql"
 	"github.com/rmorlok/authproxy/internal/apid"
+	"github.com/rmorlok/authproxy/internal/schema/common"
 	"github.com/stretchr/testify/require"
 )
 
@@ -12,6 +15,7 @@ func TestIsValidResponseSource(t *testing.T) {
 	require.True(t, IsValidResponseSource(ResponseSourceUpstream))
 	require.True(t, IsValidResponseSource(ResponseSourceConnectorRateLimiter))
 	require.True(t, IsValidResponseSource(ResponseSourceRateLimit))
+	require.True(t, IsValidResponseSource(ResponseSourceEgressDenied))
 	require.False(t, IsValidResponseSource(""))
 	require.False(t, IsValidResponseSource("bogus"))
 }
@@ -105,6 +109,31 @@ func TestApplyAttributionToLogRecord_KeepsExistingValuesWhenAttrIsBlank(t *testi
 	require.Equal(t, apid.ID("rl_existing"), er.RateLimitId)
 }
 
+func TestApplyAttributionToLogRecord_GraphqlOperation(t *testing.T) {
+	// The GraphQL operation rides on its own context key, so it is stamped
+	// even when no attribution was installed.
+	er := &LogRecord{}
+	ctx := apgraphql.ContextWithOperation(context.Background(), &apgraphql.Operation{
+		Type: apgraphql.OperationTypeMutation,
+		Name: "CreateIssue",
+	})
+	ApplyAttributionToLogRecord(er, ctx)
+
+	require.Equal(t, "mutation", er.GraphqlOperationType)
+	require.Equal(t, "CreateIssue", er.GraphqlOperationName)
+	require.Equal(t, ResponseSourceUpstream, er.ResponseSource)
+}
+
+func TestApplyAttributionToLogRecord_Priority(t *testing.T) {
+	er := &LogRecord{}
+	ApplyAttributionToLogRecord(er, context.Background())
+	require.Empty(t, er.Priority)
+
+	ctx := apctx.WithRequestPriority(context.Background(), common.RequestPriorityBatch)
+	ApplyAttributionToLogRecord(er, ctx)
+	require.Equal(t, "batch", er.Priority)
+}
+
 func TestRateLimitMatchJSON_RoundTrip(t *testing.T) {
 	// The codec is exercised end-to-end in DB tests; this just pins the
 	// public JSON shape used in API responses.
@@ -93,6 +93,28 @@ func (e *FullLogResponse) setRecordFields(er *LogRecord) {
 	er.ResponseBodySkipped = e.BodySkipped
 }
 
+// FullLogSession describes the connection after a successful protocol
+// upgrade (a 101 response, e.g. a WebSocket), from the 101 until either
+// side closed it.
+type FullLogSession struct {
+	Protocol            string              `json:"p"`
+	MillisecondDuration MillisecondDuration `json:"dur"`
+	// BytesSent is caller to upstream; BytesReceived is upstream to caller.
+	BytesSent     int64 `json:"sent,omitempty"`
+	BytesReceived int64 `json:"recv,omitempty"`
+}
+
+func (e *FullLogSession) setRecordFields(er *LogRecord) {
+	if e == nil {
+		return
+	}
+
+	er.UpgradeProtocol = e.Protocol
+	er.SessionMillisecondDuration = e.MillisecondDuration
+	er.SessionBytesSent = e.BytesSent
+	er.SessionBytesReceived = e.BytesReceived
+}
+
 type FullLog struct {
 	Id                  apid.ID             `json:"id"`
 	Namespace           string              `json:"ns"`
@@ -104,6 +126,7 @@ type FullLog struct {
 	RequestCancelled    bool                `json:"rc,omitempty"`
 	Request             FullLogRequest      `json:"req"`
 	Response            FullLogResponse     `json:"res"`
+	Session             *FullLogSession     `json:"ses,omitempty"`
 }
 
 func (e *FullLog) GetId() apid.ID {
@@ -128,6 +151,7 @@ func (e *FullLog) setRecordFields(er *LogRecord) {
 
 	e.Request.setRecordFields(er)
 	e.Response.setRecordFields(er)
+	e.Session.setRecordFields(er)
 }
 
 func (e *FullLog) ToRecord() *LogRecord {
@@ -185,5 +209,14 @@ func NewFullLogFromRecord(er *LogRecord) *FullLog {
 		entry.Response.Headers["Content-Type"] = []string{er.ResponseMimeType}
 	}
 
+	if er.UpgradeProtocol != "" {
+		entry.Session = &FullLogSession{
+			Protocol:            er.UpgradeProtocol,
+			MillisecondDuration: er.SessionMillisecondDuration,
+			BytesSent:           er.SessionBytesSent,
+			BytesReceived:       er.SessionBytesReceived,
+		}
+	}
+
 	return entry
 }
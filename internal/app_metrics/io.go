@@ -1,6 +1,13 @@
 package app_metrics
 
-import "io"
+import (
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"k8s.io/utils/clock"
+)
 
 /*
  * Utilities that allow us to manipulate readers for requests/responses so we can track the
@@ -118,3 +125,78 @@ func (b *trackingReadCloser) Done() <-chan interface{} {
 
 var _ trackingReader = &trackingReadCloser{}
 var _ io.ReadCloser = &trackingReadCloser{}
+
+// sessionTracker wraps the io.ReadWriteCloser net/http returns as the body of
+// a 101 Switching Protocols response. Reads are bytes from the upstream and
+// writes are bytes to it. Counters are atomic because the two directions are
+// pumped from separate goroutines.
+type sessionTracker struct {
+	io.ReadWriteCloser
+	clock         clock.Clock
+	start         time.Time
+	bytesSent     atomic.Int64
+	bytesReceived atomic.Int64
+
+	closeOnce sync.Once
+	mu        sync.Mutex
+	closedAt  time.Time
+	done      chan interface{}
+}
+
+func newSessionTracker(rwc io.ReadWriteCloser, c clock.Clock) *sessionTracker {
+	return &sessionTracker{
+		ReadWriteCloser: rwc,
+		clock:           c,
+		start:           c.Now(),
+		done:            make(chan interface{}),
+	}
+}
+
+func (s *sessionTracker) Read(p []byte) (n int, err error) {
+	n, err = s.ReadWriteCloser.Read(p)
+	s.bytesReceived.Add(int64(n))
+	return n, err
+}
+
+func (s *sessionTracker) Write(p []byte) (n int, err error) {
+	n, err = s.ReadWriteCloser.Write(p)
+	s.bytesSent.Add(int64(n))
+	return n, err
+}
+
+// Close is safe to call more than once; the proxy closes the session from
+// whichever direction finishes first.
+func (s *sessionTracker) Close() error {
+	err := s.ReadWriteCloser.Close()
+	s.closeOnce.Do(func() {
+		s.mu.Lock()
+		s.closedAt = s.clock.Now()
+		s.mu.Unlock()
+		close(s.done)
+	})
+	return err
+}
+
+func (s *sessionTracker) Done() <-chan interface{} {
+	return s.done
+}
+
+// fullLogSession snapshots the session. A session that is still open is
+// measured up to now.
+func (s *sessionTracker) fullLogSession(protocol string) *FullLogSession {
+	s.mu.Lock()
+	end := s.closedAt
+	s.mu.Unlock()
+	if end.IsZero() {
+		end = s.clock.Now()
+	}
+
+	return &FullLogSession{
+		Protocol:            protocol,
+		MillisecondDuration: MillisecondDuration(end.Sub(s.start)),
+		BytesSent:           s.bytesSent.Load(),
+		BytesReceived:       s.bytesReceived.Load(),
+	}
+}
+
+var _ io.ReadWriteCloser = &sessionTracker{}
@@ -83,6 +83,10 @@ type ListRequestBuilder interface {
 	ForLabelSelector(selector string) (ListRequestBuilder, error)
 	ForResponseSource(s ResponseSource) ListRequestBuilder
 	ForRateLimitId(id apid.ID) ListRequestBuilder
+	ForRetryOf(id apid.ID) ListRequestBuilder
+	ForGraphqlOperationType(t string) ListRequestBuilder
+	ForGraphqlOperationName(name string) ListRequestBuilder
+	ForPriority(p string) ListRequestBuilder
 }
 
 // ListFilters holds the filter, pagination, and ordering data for list requests.
@@ -108,6 +112,10 @@ type ListFilters struct {
 	LabelSelector            *string           `json:"labelSelector,omitempty"`
 	ResponseSource           *string           `json:"responseSource,omitempty"`
 	RateLimitId              *apid.ID          `json:"rateLimitId,omitempty"`
+	RetryOf                  *apid.ID          `json:"retryOf,omitempty"`
+	GraphqlOperationType     *string           `json:"graphqlOperationType,omitempty"`
+	GraphqlOperationName     *string           `json:"graphqlOperationName,omitempty"`
+	Priority                 *string           `json:"priority,omitempty"`
 	Errors                   *multierror.Error `json:"-"`
 }
 
@@ -218,3 +226,27 @@ func (l *ListFilters) SetResponseSource(s ResponseSource) {
 func (l *ListFilters) SetRateLimitId(id apid.ID) {
 	l.RateLimitId = util.ToPtr(id)
 }
+
+// SetRetryOf filters to the attempts of a retried proxy call: the first
+// attempt, whose id is given, and every attempt that links back to it.
+func (l *ListFilters) SetRetryOf(id apid.ID) {
+	l.RetryOf = util.ToPtr(id)
+}
+
+// SetGraphqlOperationType filters to GraphQL requests executing a query,
+// mutation or subscription.
+func (l *ListFilters) SetGraphqlOperationType(t string) {
+	l.GraphqlOperationType = util.ToPtr(t)
+}
+
+// SetGraphqlOperationName filters to GraphQL requests executing the named
+// operation.
+func (l *ListFilters) SetGraphqlOperationName(name string) {
+	l.GraphqlOperationName = util.ToPtr(name)
+}
+
+// SetPriority filters to requests tagged with a rate-limit priority class.
+// Filtering on normal also matches untagged requests.
+func (l *ListFilters) SetPriority(p string) {
+	l.Priority = util.ToPtr(p)
+}
@@ -49,6 +49,41 @@ type LogRecord struct {
 	FullRequestRecorded bool              `json:"fullRequestRecorded,omitempty"`
 	Labels              database.Labels   `json:"labels,omitempty"`
 
+	// OutboundProxy identifies the forward proxy the request was routed
+	// through, e.g. "socks5://proxy.internal:1080". Empty for direct
+	// connections.
+	OutboundProxy string `json:"outboundProxy,omitempty"`
+
+	// UpgradeProtocol is the protocol the connection switched to after a
+	// 101 response, e.g. "websocket". The Session fields describe the
+	// upgraded connection from the 101 until either side closed it; the
+	// request/response fields above describe only the handshake.
+	UpgradeProtocol            string              `json:"upgradeProtocol,omitempty"`
+	SessionMillisecondDuration MillisecondDuration `json:"sessionDuration,omitempty"`
+	// SessionBytesSent counts bytes from the caller to the upstream and
+	// SessionBytesReceived bytes from the upstream to the caller.
+	SessionBytesSent     int64 `json:"sessionBytesSent,omitempty"`
+	SessionBytesReceived int64 `json:"sessionBytesReceived,omitempty"`
+
+	// Attempt is the 1-based attempt number when the proxy orchestrator
+	// sent the request, counting retries and the replay after a 401. It is
+	// zero for requests made outside the orchestrator. RetryOf is the
+	// request id of the first attempt, set on every attempt after it, so
+	// all attempts of one proxied call can be found together.
+	Attempt int     `json:"attempt,omitempty"`
+	RetryOf apid.ID `json:"retryOf,omitempty"`
+
+	// GraphqlOperationType and GraphqlOperationName identify the GraphQL
+	// operation a proxied request executed, when its body was recognised as
+	// one. The name is empty for anonymous operations.
+	GraphqlOperationType string `json:"graphqlOperationType,omitempty"`
+	GraphqlOperationName string `json:"graphqlOperationName,omitempty"`
+
+	// Priority is the rate-limit priority class the caller tagged the
+	// request with. Empty for untagged requests, which rate limits treat
+	// as normal.
+	Priority string `json:"priority,omitempty"`
+
 	// ResponseSource identifies who produced the response. Defaults to
 	// ResponseSourceUpstream so historical entries — and any non-429
 	// response — keep the obvious meaning. See attribution.go.
@@ -68,6 +103,11 @@ type LogRecord struct {
 	// operators see *every* rule that contributed to the decision, not
 	// just the one that ultimately rejected the request.
 	RateLimitMatched []RateLimitMatch `json:"rateLimitMatched,omitempty"`
+
+	// RateLimitQueueWait is how long shape-mode rate-limit rules held the
+	// request before it was admitted or rejected. Included in the
+	// request's duration.
+	RateLimitQueueWait MillisecondDuration `json:"rateLimitQueueWait,omitempty"`
 }
 
 func (e *LogRecord) GetId() apid.ID {
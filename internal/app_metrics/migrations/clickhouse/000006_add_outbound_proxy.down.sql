@@ -0,0 +1,2 @@
+ALTER TABLE app_metrics_request_events
+    DROP COLUMN IF EXISTS outbound_proxy;
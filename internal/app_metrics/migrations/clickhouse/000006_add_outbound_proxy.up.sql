@@ -0,0 +1,2 @@
+ALTER TABLE app_metrics_request_events
+    ADD COLUMN IF NOT EXISTS outbound_proxy String DEFAULT '';
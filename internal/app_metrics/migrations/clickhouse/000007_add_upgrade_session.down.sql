@@ -0,0 +1,5 @@
+ALTER TABLE app_metrics_request_events
+    DROP COLUMN IF EXISTS upgrade_protocol,
+    DROP COLUMN IF EXISTS session_duration_ms,
+    DROP COLUMN IF EXISTS session_bytes_sent,
+    DROP COLUMN IF EXISTS session_bytes_received;
@@ -0,0 +1,5 @@
+ALTER TABLE app_metrics_request_events
+    ADD COLUMN IF NOT EXISTS upgrade_protocol String DEFAULT '',
+    ADD COLUMN IF NOT EXISTS session_duration_ms Int64 DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS session_bytes_sent Int64 DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS session_bytes_received Int64 DEFAULT 0;
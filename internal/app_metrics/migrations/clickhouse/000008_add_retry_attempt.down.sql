@@ -0,0 +1,3 @@
+ALTER TABLE app_metrics_request_events
+    DROP COLUMN IF EXISTS attempt,
+    DROP COLUMN IF EXISTS retry_of;
@@ -0,0 +1,3 @@
+ALTER TABLE app_metrics_request_events
+    ADD COLUMN IF NOT EXISTS attempt Int32 DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS retry_of String DEFAULT '';
@@ -0,0 +1,3 @@
+ALTER TABLE app_metrics_request_events
+    DROP COLUMN IF EXISTS graphql_operation_type,
+    DROP COLUMN IF EXISTS graphql_operation_name;
@@ -0,0 +1,3 @@
+ALTER TABLE app_metrics_request_events
+    ADD COLUMN IF NOT EXISTS graphql_operation_type String DEFAULT '',
+    ADD COLUMN IF NOT EXISTS graphql_operation_name String DEFAULT '';
@@ -0,0 +1,2 @@
+ALTER TABLE app_metrics_request_events
+    ADD COLUMN IF NOT EXISTS rate_limit_queue_wait_ms Int64 DEFAULT 0;
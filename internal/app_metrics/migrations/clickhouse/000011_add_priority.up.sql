@@ -0,0 +1,2 @@
+ALTER TABLE app_metrics_request_events
+    ADD COLUMN IF NOT EXISTS priority String DEFAULT '';
@@ -0,0 +1,5 @@
+ALTER TABLE app_metrics_request_events
+    DROP COLUMN upgrade_protocol,
+    DROP COLUMN session_duration_ms,
+    DROP COLUMN session_bytes_sent,
+    DROP COLUMN session_bytes_received;
@@ -0,0 +1,5 @@
+ALTER TABLE app_metrics_request_events
+    ADD COLUMN upgrade_protocol TEXT NOT NULL DEFAULT '',
+    ADD COLUMN session_duration_ms BIGINT NOT NULL DEFAULT 0,
+    ADD COLUMN session_bytes_sent BIGINT NOT NULL DEFAULT 0,
+    ADD COLUMN session_bytes_received BIGINT NOT NULL DEFAULT 0;
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_app_metrics_request_events_retry_of;
+
+ALTER TABLE app_metrics_request_events
+    DROP COLUMN attempt,
+    DROP COLUMN retry_of;
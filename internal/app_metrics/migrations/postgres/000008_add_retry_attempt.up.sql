@@ -0,0 +1,5 @@
+ALTER TABLE app_metrics_request_events
+    ADD COLUMN attempt INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN retry_of TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS idx_app_metrics_request_events_retry_of ON app_metrics_request_events (retry_of) WHERE retry_of <> '';
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_app_metrics_request_events_graphql_operation_name;
+
+ALTER TABLE app_metrics_request_events
+    DROP COLUMN graphql_operation_type,
+    DROP COLUMN graphql_operation_name;
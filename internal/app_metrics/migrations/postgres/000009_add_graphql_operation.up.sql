@@ -0,0 +1,5 @@
+ALTER TABLE app_metrics_request_events
+    ADD COLUMN graphql_operation_type TEXT NOT NULL DEFAULT '',
+    ADD COLUMN graphql_operation_name TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS idx_app_metrics_request_events_graphql_operation_name ON app_metrics_request_events (graphql_operation_name) WHERE graphql_operation_name <> '';
@@ -0,0 +1,4 @@
+ALTER TABLE app_metrics_request_events DROP COLUMN upgrade_protocol;
+ALTER TABLE app_metrics_request_events DROP COLUMN session_duration_ms;
+ALTER TABLE app_metrics_request_events DROP COLUMN session_bytes_sent;
+ALTER TABLE app_metrics_request_events DROP COLUMN session_bytes_received;
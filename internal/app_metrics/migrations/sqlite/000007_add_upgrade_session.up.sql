@@ -0,0 +1,11 @@
+ALTER TABLE app_metrics_request_events
+    ADD COLUMN upgrade_protocol TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE app_metrics_request_events
+    ADD COLUMN session_duration_ms INTEGER NOT NULL DEFAULT 0;
+
+ALTER TABLE app_metrics_request_events
+    ADD COLUMN session_bytes_sent INTEGER NOT NULL DEFAULT 0;
+
+ALTER TABLE app_metrics_request_events
+    ADD COLUMN session_bytes_received INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,5 @@
+ALTER TABLE app_metrics_request_events
+    ADD COLUMN attempt INTEGER NOT NULL DEFAULT 0;
+
+ALTER TABLE app_metrics_request_events
+    ADD COLUMN retry_of TEXT NOT NULL DEFAULT '';
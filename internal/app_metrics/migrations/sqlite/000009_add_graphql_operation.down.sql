@@ -0,0 +1,2 @@
+ALTER TABLE app_metrics_request_events DROP COLUMN graphql_operation_type;
+ALTER TABLE app_metrics_request_events DROP COLUMN graphql_operation_name;
@@ -0,0 +1,5 @@
+ALTER TABLE app_metrics_request_events
+    ADD COLUMN graphql_operation_type TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE app_metrics_request_events
+    ADD COLUMN graphql_operation_name TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,2 @@
+ALTER TABLE app_metrics_request_events
+    ADD COLUMN rate_limit_queue_wait_ms INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1 @@
+ALTER TABLE app_metrics_request_events DROP COLUMN priority;
@@ -36,6 +36,10 @@ type MockListRequestBuilderExecutor struct {
 	LabelSelector            *string     `json:"labelSelector,omitempty"`
 	ResponseSource           *string     `json:"responseSource,omitempty"`
 	RateLimitId              *apid.ID    `json:"rateLimitId,omitempty"`
+	RetryOf                  *apid.ID    `json:"retryOf,omitempty"`
+	GraphqlOperationType     *string     `json:"graphqlOperationType,omitempty"`
+	GraphqlOperationName     *string     `json:"graphqlOperationName,omitempty"`
+	Priority                 *string     `json:"priority,omitempty"`
 }
 
 func (l *MockListRequestBuilderExecutor) ForNamespaceMatcher(matcher string) app_metrics.ListRequestBuilder {
@@ -139,6 +143,26 @@ func (l *MockListRequestBuilderExecutor) ForRateLimitId(id apid.ID) app_metrics.
 	return l
 }
 
+func (l *MockListRequestBuilderExecutor) ForRetryOf(id apid.ID) app_metrics.ListRequestBuilder {
+	l.RetryOf = util.ToPtr(id)
+	return l
+}
+
+func (l *MockListRequestBuilderExecutor) ForGraphqlOperationType(t string) app_metrics.ListRequestBuilder {
+	l.GraphqlOperationType = util.ToPtr(t)
+	return l
+}
+
+func (l *MockListRequestBuilderExecutor) ForGraphqlOperationName(name string) app_metrics.ListRequestBuilder {
+	l.GraphqlOperationName = util.ToPtr(name)
+	return l
+}
+
+func (l *MockListRequestBuilderExecutor) ForPriority(p string) app_metrics.ListRequestBuilder {
+	l.Priority = util.ToPtr(p)
+	return l
+}
+
 func (l *MockListRequestBuilderExecutor) Limit(limit int32) app_metrics.ListRequestBuilder {
 	l.LimitVal = limit
 	return l
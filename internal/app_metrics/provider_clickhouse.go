@@ -62,8 +62,11 @@ func (s *clickhouseRecordStore) StoreRecords(ctx context.Context, records []*Log
 			"internal_timeout, request_cancelled, full_request_recorded, "+
 			"labels, response_source, rate_limit_id, rate_limit_mode, "+
 			"rate_limit_bucket, rate_limit_matched, "+
-			"request_body_skipped, response_body_skipped) "+
-			"VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+			"request_body_skipped, response_body_skipped, outbound_proxy, "+
+			"upgrade_protocol, session_duration_ms, session_bytes_sent, session_bytes_received, "+
+			"attempt, retry_of, graphql_operation_type, graphql_operation_name, "+
+			"rate_limit_queue_wait_ms, priority) "+
+			"VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
 		entryRecordsTable,
 	))
 	if err != nil {
@@ -105,6 +108,13 @@ func (s *clickhouseRecordStore) StoreRecords(ctx context.Context, records []*Log
 			string(source), r.RateLimitId.String(), r.RateLimitMode,
 			bucketJSON, matchedJSON,
 			string(r.RequestBodySkipped), string(r.ResponseBodySkipped),
+			r.OutboundProxy,
+			r.UpgradeProtocol, r.SessionMillisecondDuration.Duration().Milliseconds(),
+			r.SessionBytesSent, r.SessionBytesReceived,
+			r.Attempt, r.RetryOf.String(),
+			r.GraphqlOperationType, r.GraphqlOperationName,
+			r.RateLimitQueueWait.Duration().Milliseconds(),
+			r.Priority,
 		)
 		if err != nil {
 			s.logger.Error("failed to insert record into clickhouse", "error", err, "entry_id", r.RequestId.String())
@@ -373,6 +383,26 @@ func (l *clickhouseListRequestsBuilder) ForRateLimitId(id apid.ID) ListRequestBu
 	return l
 }
 
+func (l *clickhouseListRequestsBuilder) ForRetryOf(id apid.ID) ListRequestBuilder {
+	l.sqlListRequestsBuilder.ForRetryOf(id)
+	return l
+}
+
+func (l *clickhouseListRequestsBuilder) ForGraphqlOperationType(t string) ListRequestBuilder {
+	l.sqlListRequestsBuilder.ForGraphqlOperationType(t)
+	return l
+}
+
+func (l *clickhouseListRequestsBuilder) ForGraphqlOperationName(name string) ListRequestBuilder {
+	l.sqlListRequestsBuilder.ForGraphqlOperationName(name)
+	return l
+}
+
+func (l *clickhouseListRequestsBuilder) ForPriority(p string) ListRequestBuilder {
+	l.sqlListRequestsBuilder.ForPriority(p)
+	return l
+}
+
 var _ ListRequestExecutor = (*clickhouseListRequestsBuilder)(nil)
 var _ ListRequestBuilder = (*clickhouseListRequestsBuilder)(nil)
 
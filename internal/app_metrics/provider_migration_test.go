@@ -43,8 +43,8 @@ func TestSQLMigrateUsesAppMetricsSchemaMigrationsTable(t *testing.T) {
 
 	status := MigrationStatus(context.Background(), cfg)
 	require.Equal(t, migration.StateCurrent, status.State)
-	require.Equal(t, uint(5), status.AvailableVersion)
-	require.Equal(t, uint(5), *status.CurrentVersion)
+	require.Equal(t, uint(11), status.AvailableVersion)
+	require.Equal(t, uint(11), *status.CurrentVersion)
 }
 
 func TestMigrationStatusCurrentForConfiguredProvider(t *testing.T) {
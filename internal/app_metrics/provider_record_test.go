@@ -34,6 +34,13 @@ type recordOpts struct {
 	rateLimitMatched []RateLimitMatch
 	reqBodySkipped   BodySkippedReason
 	respBodySkipped  BodySkippedReason
+	outboundProxy    string
+	attempt          int
+	retryOf          apid.ID
+	graphqlType      string
+	graphqlName      string
+	queueWait        time.Duration
+	priority         string
 }
 
 func makeRecord(namespace string, o recordOpts) *LogRecord {
@@ -59,28 +66,35 @@ func makeRecord(namespace string, o recordOpts) *LogRecord {
 		o.duration = 123 * time.Millisecond
 	}
 	return &LogRecord{
-		RequestId:           apid.New(apid.PrefixRequestEvents),
-		Namespace:           namespace,
-		Type:                o.requestType,
-		CorrelationId:       o.correlationId,
-		Timestamp:           o.timestamp,
-		MillisecondDuration: MillisecondDuration(o.duration),
-		ConnectionId:        o.connectionId,
-		ConnectorId:         o.connectorId,
-		ConnectorVersion:    o.connectorVersion,
-		Method:              o.method,
-		Host:                o.host,
-		Scheme:              "https",
-		Path:                o.path,
-		ResponseStatusCode:  o.statusCode,
-		Labels:              o.labels,
-		ResponseSource:      o.responseSource,
-		RateLimitId:         o.rateLimitId,
-		RateLimitMode:       o.rateLimitMode,
-		RateLimitBucket:     o.rateLimitBucket,
-		RateLimitMatched:    o.rateLimitMatched,
-		RequestBodySkipped:  o.reqBodySkipped,
-		ResponseBodySkipped: o.respBodySkipped,
+		RequestId:            apid.New(apid.PrefixRequestEvents),
+		Namespace:            namespace,
+		Type:                 o.requestType,
+		CorrelationId:        o.correlationId,
+		Timestamp:            o.timestamp,
+		MillisecondDuration:  MillisecondDuration(o.duration),
+		ConnectionId:         o.connectionId,
+		ConnectorId:          o.connectorId,
+		ConnectorVersion:     o.connectorVersion,
+		Method:               o.method,
+		Host:                 o.host,
+		Scheme:               "https",
+		Path:                 o.path,
+		ResponseStatusCode:   o.statusCode,
+		Labels:               o.labels,
+		ResponseSource:       o.responseSource,
+		RateLimitId:          o.rateLimitId,
+		RateLimitMode:        o.rateLimitMode,
+		RateLimitBucket:      o.rateLimitBucket,
+		RateLimitMatched:     o.rateLimitMatched,
+		RequestBodySkipped:   o.reqBodySkipped,
+		ResponseBodySkipped:  o.respBodySkipped,
+		OutboundProxy:        o.outboundProxy,
+		Attempt:              o.attempt,
+		RetryOf:              o.retryOf,
+		GraphqlOperationType: o.graphqlType,
+		GraphqlOperationName: o.graphqlName,
+		RateLimitQueueWait:   MillisecondDuration(o.queueWait),
+		Priority:             o.priority,
 	}
 }
 
@@ -111,6 +125,13 @@ func TestRequestEvents_StoreAndGetRecord_RoundTrip(t *testing.T) {
 		},
 		reqBodySkipped:  BodySkippedStreaming,
 		respBodySkipped: BodySkippedTooLarge,
+		outboundProxy:   "socks5://proxy.internal:1080",
+		attempt:         2,
+		retryOf:         apid.New(apid.PrefixRequestEvents),
+		graphqlType:     "mutation",
+		graphqlName:     "CreateIssue",
+		queueWait:       750 * time.Millisecond,
+		priority:        "batch",
 	})
 
 	require.NoError(t, store.StoreRecord(ctx, rec))
@@ -140,6 +161,13 @@ func TestRequestEvents_StoreAndGetRecord_RoundTrip(t *testing.T) {
 	require.Equal(t, rec.RateLimitMatched, got.RateLimitMatched)
 	require.Equal(t, rec.RequestBodySkipped, got.RequestBodySkipped)
 	require.Equal(t, rec.ResponseBodySkipped, got.ResponseBodySkipped)
+	require.Equal(t, rec.OutboundProxy, got.OutboundProxy)
+	require.Equal(t, rec.Attempt, got.Attempt)
+	require.Equal(t, rec.RetryOf, got.RetryOf)
+	require.Equal(t, rec.GraphqlOperationType, got.GraphqlOperationType)
+	require.Equal(t, rec.GraphqlOperationName, got.GraphqlOperationName)
+	require.Equal(t, rec.RateLimitQueueWait, got.RateLimitQueueWait)
+	require.Equal(t, rec.Priority, got.Priority)
 }
 
 func TestRequestEvents_StoreRecords_Batch(t *testing.T) {
@@ -266,6 +294,60 @@ func TestRequestEvents_List_FilterByScalarFields(t *testing.T) {
 	}
 }
 
+func TestRequestEvents_List_FilterByRetryOf(t *testing.T) {
+	store, retriever, _ := MustNewBlankRequestEventsStore(t)
+	ctx := context.Background()
+
+	first := makeRecord("root", recordOpts{attempt: 1, statusCode: 503})
+	second := makeRecord("root", recordOpts{attempt: 2, retryOf: first.RequestId, statusCode: 503})
+	third := makeRecord("root", recordOpts{attempt: 3, retryOf: first.RequestId})
+	unrelated := makeRecord("root", recordOpts{attempt: 1})
+	require.NoError(t, store.StoreRecords(ctx, []*LogRecord{first, second, third, unrelated}))
+
+	result := retriever.NewListRequestsBuilder().ForRetryOf(first.RequestId).FetchPage(ctx)
+	require.NoError(t, result.Error)
+	require.Equal(t, map[apid.ID]bool{first.RequestId: true, second.RequestId: true, third.RequestId: true}, collectIDs(result.Results))
+}
+
+func TestRequestEvents_List_FilterByGraphqlOperation(t *testing.T) {
+	store, retriever, _ := MustNewBlankRequestEventsStore(t)
+	ctx := context.Background()
+
+	getProducts := makeRecord("root", recordOpts{method: "POST", path: "/graphql", graphqlType: "query", graphqlName: "GetProducts"})
+	getOrders := makeRecord("root", recordOpts{method: "POST", path: "/graphql", graphqlType: "query", graphqlName: "GetOrders"})
+	createOrder := makeRecord("root", recordOpts{method: "POST", path: "/graphql", graphqlType: "mutation", graphqlName: "CreateOrder"})
+	rest := makeRecord("root", recordOpts{})
+	require.NoError(t, store.StoreRecords(ctx, []*LogRecord{getProducts, getOrders, createOrder, rest}))
+
+	result := retriever.NewListRequestsBuilder().ForGraphqlOperationType("query").FetchPage(ctx)
+	require.NoError(t, result.Error)
+	require.Equal(t, map[apid.ID]bool{getProducts.RequestId: true, getOrders.RequestId: true}, collectIDs(result.Results))
+
+	result = retriever.NewListRequestsBuilder().ForGraphqlOperationName("CreateOrder").FetchPage(ctx)
+	require.NoError(t, result.Error)
+	require.Equal(t, map[apid.ID]bool{createOrder.RequestId: true}, collectIDs(result.Results))
+}
+
+func TestRequestEvents_List_FilterByPriority(t *testing.T) {
+	store, retriever, _ := MustNewBlankRequestEventsStore(t)
+	ctx := context.Background()
+
+	interactive := makeRecord("root", recordOpts{priority: "interactive"})
+	normal := makeRecord("root", recordOpts{priority: "normal"})
+	untagged := makeRecord("root", recordOpts{})
+	batch := makeRecord("root", recordOpts{priority: "batch"})
+	require.NoError(t, store.StoreRecords(ctx, []*LogRecord{interactive, normal, untagged, batch}))
+
+	result := retriever.NewListRequestsBuilder().ForPriority("batch").FetchPage(ctx)
+	require.NoError(t, result.Error)
+	require.Equal(t, map[apid.ID]bool{batch.RequestId: true}, collectIDs(result.Results))
+
+	// Untagged requests are limited as normal, so they're listed with it.
+	result = retriever.NewListRequestsBuilder().ForPriority("normal").FetchPage(ctx)
+	require.NoError(t, result.Error)
+	require.Equal(t, map[apid.ID]bool{normal.RequestId: true, untagged.RequestId: true}, collectIDs(result.Results))
+}
+
 func TestRequestEvents_List_FilterByTimestampRange(t *testing.T) {
 	store, retriever, _ := MustNewBlankRequestEventsStore(t)
 	ctx := context.Background()
@@ -567,6 +649,88 @@ func TestRequestEvents_Metrics_ErrorsAndDurations(t *testing.T) {
 	require.Equal(t, []float64{900, 300}, byRef["p95"])
 }
 
+func TestRequestEvents_Metrics_RateLimitQueueWait(t *testing.T) {
+	store, retriever, _ := MustNewBlankRequestEventsStore(t)
+	ctx := context.Background()
+
+	base := time.Date(2026, 5, 25, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, store.StoreRecords(ctx, []*LogRecord{
+		makeRecord("root", recordOpts{timestamp: base.Add(time.Minute), queueWait: 100 * time.Millisecond}),
+		makeRecord("root", recordOpts{timestamp: base.Add(2 * time.Minute), queueWait: 500 * time.Millisecond}),
+		// Never held; left out rather than averaged in as zero.
+		makeRecord("root", recordOpts{timestamp: base.Add(3 * time.Minute)}),
+	}))
+
+	series, err := retriever.QueryRequestEventMetrics(ctx, []RequestEventMetricsQuery{
+		{
+			RefID:  "avg",
+			Metric: RequestEventMetricRateLimitQueueWaitAvgMS,
+			Start:  base,
+			End:    base.Add(15 * time.Minute),
+			Step:   15 * time.Minute,
+		},
+		{
+			RefID:  "p95",
+			Metric: RequestEventMetricRateLimitQueueWaitP95MS,
+			Start:  base,
+			End:    base.Add(15 * time.Minute),
+			Step:   15 * time.Minute,
+		},
+	})
+	require.NoError(t, err)
+	require.Len(t, series, 2)
+	require.Equal(t, 300.0, series[0].Points[0].Value)
+	require.Equal(t, 500.0, series[1].Points[0].Value)
+}
+
+func TestRequestEvents_Metrics_RateLimitQuotaUsed(t *testing.T) {
+	store, retriever, _ := MustNewBlankRequestEventsStore(t)
+	ctx := context.Background()
+
+	base := time.Date(2026, 5, 25, 12, 0, 0, 0, time.UTC)
+	quotaA := apid.New(apid.PrefixRateLimit)
+	quotaB := apid.New(apid.PrefixRateLimit)
+	require.NoError(t, store.StoreRecords(ctx, []*LogRecord{
+		makeRecord("root", recordOpts{timestamp: base.Add(time.Minute), rateLimitMatched: []RateLimitMatch{
+			{Id: quotaA, Mode: "enforce", QuotaUsed: 1},
+			{Id: quotaB, Mode: "enforce", QuotaUsed: 50},
+		}}),
+		makeRecord("root", recordOpts{timestamp: base.Add(2 * time.Minute), rateLimitMatched: []RateLimitMatch{
+			{Id: quotaA, Mode: "enforce", QuotaUsed: 1},
+		}}),
+		// Matched but consumed nothing; contributes no series.
+		makeRecord("root", recordOpts{timestamp: base.Add(3 * time.Minute), rateLimitMatched: []RateLimitMatch{
+			{Id: apid.New(apid.PrefixRateLimit), Mode: "enforce"},
+		}}),
+	}))
+
+	series, err := retriever.QueryRequestEventMetrics(ctx, []RequestEventMetricsQuery{{
+		RefID:   "quota",
+		Metric:  RequestEventMetricRateLimitQuotaUsedSum,
+		Start:   base,
+		End:     base.Add(15 * time.Minute),
+		Step:    15 * time.Minute,
+		GroupBy: []RequestEventGroupBy{RequestEventGroupByRateLimitID},
+	}})
+	require.NoError(t, err)
+	require.Len(t, series, 2)
+
+	values := metricSeriesValuesByLabels(series)
+	require.Equal(t, []float64{2}, values["rate_limit_id="+quotaA.String()+"\x00"])
+	require.Equal(t, []float64{50}, values["rate_limit_id="+quotaB.String()+"\x00"])
+
+	series, err = retriever.QueryRequestEventMetrics(ctx, []RequestEventMetricsQuery{{
+		RefID:  "total",
+		Metric: RequestEventMetricRateLimitQuotaUsedSum,
+		Start:  base,
+		End:    base.Add(15 * time.Minute),
+		Step:   15 * time.Minute,
+	}})
+	require.NoError(t, err)
+	require.Len(t, series, 1)
+	require.Equal(t, 52.0, series[0].Points[0].Value)
+}
+
 func collectIDs(records []*LogRecord) map[apid.ID]bool {
 	out := make(map[apid.ID]bool, len(records))
 	for _, r := range records {
@@ -15,6 +15,7 @@ import (
 	"github.com/rmorlok/authproxy/internal/apid"
 	"github.com/rmorlok/authproxy/internal/database"
 	"github.com/rmorlok/authproxy/internal/httpf"
+	"github.com/rmorlok/authproxy/internal/schema/common"
 	"github.com/rmorlok/authproxy/internal/schema/config"
 	"github.com/rmorlok/authproxy/internal/sqlh"
 	"github.com/rmorlok/authproxy/internal/util"
@@ -98,6 +99,17 @@ func (s *sqlRecordStore) StoreRecords(ctx context.Context, records []*LogRecord)
 			"rate_limit_matched",
 			"request_body_skipped",
 			"response_body_skipped",
+			"outbound_proxy",
+			"upgrade_protocol",
+			"session_duration_ms",
+			"session_bytes_sent",
+			"session_bytes_received",
+			"attempt",
+			"retry_of",
+			"graphql_operation_type",
+			"graphql_operation_name",
+			"rate_limit_queue_wait_ms",
+			"priority",
 		)
 
 	for _, record := range records {
@@ -150,6 +162,17 @@ func (s *sqlRecordStore) StoreRecords(ctx context.Context, records []*LogRecord)
 			matchedJSON,
 			string(record.RequestBodySkipped),
 			string(record.ResponseBodySkipped),
+			record.OutboundProxy,
+			record.UpgradeProtocol,
+			record.SessionMillisecondDuration.Duration().Milliseconds(),
+			record.SessionBytesSent,
+			record.SessionBytesReceived,
+			record.Attempt,
+			record.RetryOf.String(),
+			record.GraphqlOperationType,
+			record.GraphqlOperationName,
+			record.RateLimitQueueWait.Duration().Milliseconds(),
+			record.Priority,
 		)
 	}
 
@@ -209,12 +232,18 @@ var entryRecordColumns = []string{
 	"response_source", "rate_limit_id", "rate_limit_mode",
 	"rate_limit_bucket", "rate_limit_matched",
 	"request_body_skipped", "response_body_skipped",
+	"outbound_proxy",
+	"upgrade_protocol", "session_duration_ms", "session_bytes_sent", "session_bytes_received",
+	"attempt", "retry_of",
+	"graphql_operation_type", "graphql_operation_name",
+	"rate_limit_queue_wait_ms",
+	"priority",
 }
 
 func scanLogRecord(row interface{ Scan(dest ...any) error }) (*LogRecord, error) {
 	er := &LogRecord{}
-	var requestId, connectionId, connectorId string
-	var timestampMs, durationMs int64
+	var requestId, connectionId, connectorId, retryOf string
+	var timestampMs, durationMs, sessionDurationMs, queueWaitMs int64
 	var responseSource, rateLimitId, rateLimitMode string
 	var rateLimitBucket, rateLimitMatched []byte
 	var requestBodySkipped, responseBodySkipped string
@@ -231,6 +260,12 @@ func scanLogRecord(row interface{ Scan(dest ...any) error }) (*LogRecord, error)
 		&responseSource, &rateLimitId, &rateLimitMode,
 		&rateLimitBucket, &rateLimitMatched,
 		&requestBodySkipped, &responseBodySkipped,
+		&er.OutboundProxy,
+		&er.UpgradeProtocol, &sessionDurationMs, &er.SessionBytesSent, &er.SessionBytesReceived,
+		&er.Attempt, &retryOf,
+		&er.GraphqlOperationType, &er.GraphqlOperationName,
+		&queueWaitMs,
+		&er.Priority,
 	)
 	if err != nil {
 		return nil, err
@@ -241,8 +276,11 @@ func scanLogRecord(row interface{ Scan(dest ...any) error }) (*LogRecord, error)
 	er.RequestId = apid.ID(requestId)
 	er.ConnectionId = apid.ID(connectionId)
 	er.ConnectorId = apid.ID(connectorId)
+	er.RetryOf = apid.ID(retryOf)
 	er.Timestamp = time.Unix(0, timestampMs*int64(time.Millisecond)).In(time.UTC)
 	er.MillisecondDuration = MillisecondDuration(time.Duration(durationMs) * time.Millisecond)
+	er.SessionMillisecondDuration = MillisecondDuration(time.Duration(sessionDurationMs) * time.Millisecond)
+	er.RateLimitQueueWait = MillisecondDuration(time.Duration(queueWaitMs) * time.Millisecond)
 
 	if responseSource == "" {
 		responseSource = string(ResponseSourceUpstream)
@@ -464,6 +502,26 @@ func (l *sqlListRequestsBuilder) ForRateLimitId(id apid.ID) ListRequestBuilder {
 	return l
 }
 
+func (l *sqlListRequestsBuilder) ForRetryOf(id apid.ID) ListRequestBuilder {
+	l.ListFilters.SetRetryOf(id)
+	return l
+}
+
+func (l *sqlListRequestsBuilder) ForGraphqlOperationType(t string) ListRequestBuilder {
+	l.ListFilters.SetGraphqlOperationType(t)
+	return l
+}
+
+func (l *sqlListRequestsBuilder) ForGraphqlOperationName(name string) ListRequestBuilder {
+	l.ListFilters.SetGraphqlOperationName(name)
+	return l
+}
+
+func (l *sqlListRequestsBuilder) ForPriority(p string) ListRequestBuilder {
+	l.ListFilters.SetPriority(p)
+	return l
+}
+
 func (l *sqlListRequestsBuilder) buildQuery() sq.SelectBuilder {
 	builder := sq.Select(entryRecordColumns...).
 		From(entryRecordsTable).
@@ -585,6 +643,30 @@ func (l *sqlListRequestsBuilder) applyFilters(builder sq.SelectBuilder) sq.Selec
 		builder = builder.Where(sq.Eq{"rate_limit_id": l.RateLimitId.String()})
 	}
 
+	if l.RetryOf != nil {
+		builder = builder.Where(sq.Or{
+			sq.Eq{"request_id": l.RetryOf.String()},
+			sq.Eq{"retry_of": l.RetryOf.String()},
+		})
+	}
+
+	if l.GraphqlOperationType != nil {
+		builder = builder.Where(sq.Eq{"graphql_operation_type": *l.GraphqlOperationType})
+	}
+
+	if l.GraphqlOperationName != nil {
+		builder = builder.Where(sq.Eq{"graphql_operation_name": *l.GraphqlOperationName})
+	}
+
+	if l.Priority != nil {
+		// Untagged requests are limited as normal, so they match it too.
+		priorities := []string{*l.Priority}
+		if *l.Priority == string(common.DefaultRequestPriority) {
+			priorities = append(priorities, "")
+		}
+		builder = builder.Where(sq.Eq{"priority": priorities})
+	}
+
 	return builder
 }
 
@@ -19,6 +19,17 @@ const (
 	RequestEventMetricErrorsCount   RequestEventMetric = "request_events.errors.count"
 	RequestEventMetricDurationAvgMS RequestEventMetric = "request_events.duration_ms.avg"
 	RequestEventMetricDurationP95MS RequestEventMetric = "request_events.duration_ms.p95"
+
+	// The queue-wait metrics cover only requests a shape-mode rate limit
+	// actually held; requests that never waited would otherwise swamp them.
+	RequestEventMetricRateLimitQueueWaitAvgMS RequestEventMetric = "request_events.rate_limit_queue_wait_ms.avg"
+	RequestEventMetricRateLimitQueueWaitP95MS RequestEventMetric = "request_events.rate_limit_queue_wait_ms.p95"
+
+	// RequestEventMetricRateLimitQuotaUsedSum totals the units requests
+	// took from quota rate limits. Each quota rule a request matched
+	// contributes separately; grouped by rate_limit_id, the series are per
+	// quota rule.
+	RequestEventMetricRateLimitQuotaUsedSum RequestEventMetric = "request_events.rate_limit_quota_used.sum"
 )
 
 type RequestEventGroupBy string
@@ -29,6 +40,10 @@ const (
 	RequestEventGroupByResponseStatusCode RequestEventGroupBy = "response_status_code"
 	RequestEventGroupByResponseSource     RequestEventGroupBy = "response_source"
 	RequestEventGroupByConnectorID        RequestEventGroupBy = "connector_id"
+
+	// RequestEventGroupByRateLimitID groups by the rate limit attributed
+	// to the request — or, for quota usage, the quota rule charged.
+	RequestEventGroupByRateLimitID RequestEventGroupBy = "rate_limit_id"
 )
 
 type RequestEventMetricsQuery struct {
@@ -93,7 +108,10 @@ func isValidRequestEventMetric(metric RequestEventMetric) bool {
 	case RequestEventMetricCount,
 		RequestEventMetricErrorsCount,
 		RequestEventMetricDurationAvgMS,
-		RequestEventMetricDurationP95MS:
+		RequestEventMetricDurationP95MS,
+		RequestEventMetricRateLimitQueueWaitAvgMS,
+		RequestEventMetricRateLimitQueueWaitP95MS,
+		RequestEventMetricRateLimitQuotaUsedSum:
 		return true
 	default:
 		return false
@@ -106,7 +124,8 @@ func isValidRequestEventGroupBy(groupBy RequestEventGroupBy) bool {
 		RequestEventGroupByMethod,
 		RequestEventGroupByResponseStatusCode,
 		RequestEventGroupByResponseSource,
-		RequestEventGroupByConnectorID:
+		RequestEventGroupByConnectorID,
+		RequestEventGroupByRateLimitID:
 		return true
 	default:
 		return false
@@ -132,20 +151,36 @@ func (a *requestEventMetricAccumulator) add(metric RequestEventMetric, record *L
 		a.sum += float64(record.MillisecondDuration.Duration().Milliseconds())
 	case RequestEventMetricDurationP95MS:
 		a.durations = append(a.durations, float64(record.MillisecondDuration.Duration().Milliseconds()))
+	case RequestEventMetricRateLimitQueueWaitAvgMS:
+		if record.RateLimitQueueWait > 0 {
+			a.count++
+			a.sum += float64(record.RateLimitQueueWait.Duration().Milliseconds())
+		}
+	case RequestEventMetricRateLimitQueueWaitP95MS:
+		if record.RateLimitQueueWait > 0 {
+			a.durations = append(a.durations, float64(record.RateLimitQueueWait.Duration().Milliseconds()))
+		}
 	}
 }
 
+// addQuotaUsed adds what match charged its quota rule.
+func (a *requestEventMetricAccumulator) addQuotaUsed(match RateLimitMatch) {
+	a.sum += float64(match.QuotaUsed)
+}
+
 func (a *requestEventMetricAccumulator) value(metric RequestEventMetric) float64 {
 	switch metric {
 	case RequestEventMetricCount, RequestEventMetricErrorsCount:
 		return float64(a.count)
-	case RequestEventMetricDurationAvgMS:
+	case RequestEventMetricDurationAvgMS, RequestEventMetricRateLimitQueueWaitAvgMS:
 		if a.count == 0 {
 			return 0
 		}
 		return a.sum / float64(a.count)
-	case RequestEventMetricDurationP95MS:
+	case RequestEventMetricDurationP95MS, RequestEventMetricRateLimitQueueWaitP95MS:
 		return percentileNearestRank(a.durations, 0.95)
+	case RequestEventMetricRateLimitQuotaUsedSum:
+		return a.sum
 	default:
 		return 0
 	}
@@ -200,13 +235,31 @@ func buildRequestEventMetricSeries(query RequestEventMetricsQuery, records []*Lo
 		if bucketIdx < 0 || bucketIdx >= bucketCount {
 			continue
 		}
-		labels := requestEventMetricLabels(record, query.GroupBy)
-		key := requestEventMetricGroupKey(labels)
-		if _, ok := accumulators[key]; !ok {
-			accumulators[key] = make([]requestEventMetricAccumulator, bucketCount)
-			labelsByKey[key] = labels
+		accumulator := func(labels map[string]string) *requestEventMetricAccumulator {
+			key := requestEventMetricGroupKey(labels)
+			if _, ok := accumulators[key]; !ok {
+				accumulators[key] = make([]requestEventMetricAccumulator, bucketCount)
+				labelsByKey[key] = labels
+			}
+			return &accumulators[key][bucketIdx]
+		}
+
+		if query.Metric == RequestEventMetricRateLimitQuotaUsedSum {
+			// A request can draw on several quotas; each is charged to
+			// its own rule.
+			for _, match := range record.RateLimitMatched {
+				if match.QuotaUsed <= 0 {
+					continue
+				}
+				labels := requestEventMetricLabels(record, query.GroupBy)
+				if _, ok := labels[string(RequestEventGroupByRateLimitID)]; ok {
+					labels[string(RequestEventGroupByRateLimitID)] = match.Id.String()
+				}
+				accumulator(labels).addQuotaUsed(match)
+			}
+			continue
 		}
-		accumulators[key][bucketIdx].add(query.Metric, record)
+		accumulator(requestEventMetricLabels(record, query.GroupBy)).add(query.Metric, record)
 	}
 
 	keys := make([]string, 0, len(accumulators))
@@ -251,6 +304,8 @@ func requestEventMetricLabels(record *LogRecord, groupBy []RequestEventGroupBy)
 			labels[string(group)] = string(source)
 		case RequestEventGroupByConnectorID:
 			labels[string(group)] = record.ConnectorId.String()
+		case RequestEventGroupByRateLimitID:
+			labels[string(group)] = record.RateLimitId.String()
 		}
 	}
 	return labels
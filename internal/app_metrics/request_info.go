@@ -3,6 +3,8 @@ package app_metrics
 import (
 	"context"
 
+	"github.com/rmorlok/authproxy/internal/apctx"
+	"github.com/rmorlok/authproxy/internal/apgraphql"
 	"github.com/rmorlok/authproxy/internal/httpf"
 )
 
@@ -18,12 +20,14 @@ func SetLogRecordFieldsFromRequestInfo(er *LogRecord, ri httpf.RequestInfo) {
 	er.ConnectorVersion = ri.ConnectorVersion
 	er.ConnectionId = ri.ConnectionId
 	er.Labels = ri.Labels
+	er.OutboundProxy = ri.OutboundProxy
 }
 
 // ApplyAttributionToLogRecord stamps the LogRecord with whatever the
 // proxy stack recorded on the request context — the response source
 // plus, when a rate-limit resource matched, the rule id / mode / bucket
-// / full match set. When no attribution was installed (older code paths
+// / full match set / queue wait — the GraphQL operation the proxy identified in
+// the request body and the priority the request was tagged with. When no attribution was installed (older code paths
 // or non-proxy traffic), defaults the source to ResponseSourceUpstream
 // so the column is always populated.
 //
@@ -31,6 +35,14 @@ func SetLogRecordFieldsFromRequestInfo(er *LogRecord, ri httpf.RequestInfo) {
 // the existing signature isn't disturbed; the request-events round-tripper
 // invokes both in sequence.
 func ApplyAttributionToLogRecord(er *LogRecord, ctx context.Context) {
+	if op := apgraphql.OperationFromContext(ctx); op != nil {
+		er.GraphqlOperationType = string(op.Type)
+		er.GraphqlOperationName = op.Name
+	}
+	if p := apctx.RequestPriority(ctx); p != "" {
+		er.Priority = string(p)
+	}
+
 	attr := AttributionFromContext(ctx)
 	if attr == nil {
 		if er.ResponseSource == "" {
@@ -57,4 +69,11 @@ func ApplyAttributionToLogRecord(er *LogRecord, ctx context.Context) {
 	if len(attr.RateLimitMatched) > 0 {
 		er.RateLimitMatched = attr.RateLimitMatched
 	}
+	if attr.RateLimitQueueWait > 0 {
+		er.RateLimitQueueWait = MillisecondDuration(attr.RateLimitQueueWait)
+	}
+	if attr.Attempt > 0 {
+		er.Attempt = attr.Attempt
+		er.RetryOf = attr.RetryOf
+	}
 }
@@ -44,9 +44,13 @@ func (t *RoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
 	var responseBodyTrackingReader trackingReader
 	var requestBodySkipped BodySkippedReason
 	var responseBodySkipped BodySkippedReason
+	var session *sessionTracker
 
 	// Generate a unique ID for this request
 	id := apctx.GetIdGenerator(ctx).New(apid.PrefixRequestEvents)
+	if attr := AttributionFromContext(ctx); attr != nil {
+		attr.RequestId = id
+	}
 
 	// Record start time
 	clock := apctx.GetClock(ctx)
@@ -127,6 +131,15 @@ func (t *RoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
 		full_log.Response.StatusCode = http.StatusInternalServerError
 		full_log.Response.Err = requestErr.Error()
 
+		// The egress policy fails the dial rather than producing a
+		// response; record it as a refusal rather than a server error.
+		if httpf.IsEgressDenied(requestErr) {
+			full_log.Response.StatusCode = http.StatusForbidden
+			if attr := AttributionFromContext(ctx); attr != nil {
+				attr.Source = ResponseSourceEgressDenied
+			}
+		}
+
 		// Store the full_log in Redis asynchronously
 		go func() {
 			if cc.recordFullRequest {
@@ -168,7 +181,13 @@ func (t *RoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
 	full_log.Response.Headers = resp.Header
 	full_log.Response.ContentLength = resp.ContentLength // This will be overwritten if we are recording the full response
 
-	if cc.recordFullRequest && cc.maxFullResponseSize > 0 && resp.Body != nil {
+	if rwc, ok := resp.Body.(io.ReadWriteCloser); ok && resp.StatusCode == http.StatusSwitchingProtocols {
+		// The connection was upgraded (e.g. a WebSocket) and the body is
+		// now the bidirectional stream. Count both directions until it is
+		// closed rather than treating it as a response body.
+		session = newSessionTracker(rwc, clock)
+		resp.Body = session
+	} else if cc.recordFullRequest && cc.maxFullResponseSize > 0 && resp.Body != nil {
 		// Same size-bounded decision as the request side, against the
 		// upstream's Content-Length and max_full_response_size. SSE /
 		// chunked streams skip the tee — the whole point of the raw
@@ -233,24 +252,37 @@ func (t *RoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
 					t.logger.Error("error reading full request body", "error", err, "entry_id", full_log.Id.String())
 					full_log.Response.Body = []byte(err.Error())
 				} else {
+					if attr := AttributionFromContext(ctx); attr != nil && attr.TransformResponseBody != nil {
+						responseData = attr.TransformResponseBody(resp.StatusCode, resp.Header, responseData)
+					}
 					full_log.Response.Body = responseData
 				}
 			}
 		}
 
-		// This select should immediately return the body reader done if the full request is being recorded. This
-		// is to cover cases where we aren't recording the full response but need to wait for the client to fully
-		// consume the data.
-		select {
-		case <-ctx.Done():
-			full_log.RequestCancelled = true
-		case <-responseBodyTrackingReader.Done():
-			if full_log.Response.ContentLength <= 0 {
-				full_log.Response.ContentLength = responseBodyTrackingReader.BytesRead()
+		if session != nil {
+			// Sessions routinely outlive maxResponseWait, so wait for the
+			// session to close rather than timing out.
+			select {
+			case <-ctx.Done():
+			case <-session.Done():
+			}
+			full_log.Session = session.fullLogSession(resp.Header.Get("Upgrade"))
+		} else {
+			// This select should immediately return the body reader done if the full request is being recorded. This
+			// is to cover cases where we aren't recording the full response but need to wait for the client to fully
+			// consume the data.
+			select {
+			case <-ctx.Done():
+				full_log.RequestCancelled = true
+			case <-responseBodyTrackingReader.Done():
+				if full_log.Response.ContentLength <= 0 {
+					full_log.Response.ContentLength = responseBodyTrackingReader.BytesRead()
+				}
+			case <-time.After(cc.maxResponseWait):
+				full_log.InternalTimeout = true
+				t.logger.Error("timed out waiting for response body to be read; full_log will not have accurate size", "entry_id", full_log.Id.String(), "correlation_id", full_log.CorrelationID, "max_wait", cc.maxResponseWait.String())
 			}
-		case <-time.After(cc.maxResponseWait):
-			full_log.InternalTimeout = true
-			t.logger.Error("timed out waiting for response body to be read; full_log will not have accurate size", "entry_id", full_log.Id.String(), "correlation_id", full_log.CorrelationID, "max_wait", cc.maxResponseWait.String())
 		}
 
 		record := full_log.ToRecord()
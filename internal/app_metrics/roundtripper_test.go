@@ -5,7 +5,9 @@ import (
 	"context"
 	"errors"
 	"io"
+	"net"
 	"net/http"
+	"net/netip"
 	"net/url"
 	"sync"
 	"testing"
@@ -18,6 +20,7 @@ import (
 	"github.com/rmorlok/authproxy/internal/httpf"
 	"github.com/rmorlok/authproxy/internal/util"
 	"github.com/stretchr/testify/require"
+	clock "k8s.io/utils/clock/testing"
 )
 
 // mockRecordStore captures StoreRecord calls for test assertions.
@@ -731,6 +734,119 @@ func TestRoundTripper_RoundTrip_TimesOutAtContextCancel(t *testing.T) {
 	require.Equal(t, expectedFullLog, result)
 }
 
+func TestRoundTripper_RoundTrip_EgressDenied(t *testing.T) {
+	denied := &url.Error{
+		Op:  "Get",
+		URL: "http://169.254.169.254/latest/meta-data",
+		Err: &net.OpError{Op: "dial", Net: "tcp", Err: &httpf.EgressDeniedError{Addr: netip.MustParseAddr("169.254.169.254")}},
+	}
+	store := &mockRecordStore{}
+	fullStore := newMockFullStore()
+
+	rt := &RoundTripper{
+		store:     store,
+		fullStore: fullStore,
+		logger:    slog.New(slog.NewTextHandler(io.Discard, nil)),
+		captureConfig: captureConfig{
+			expiration:            time.Minute,
+			fullRequestExpiration: time.Minute,
+		},
+		requestInfo: httpf.RequestInfo{},
+		transport:   &mockRoundTripper{err: denied},
+	}
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, "http://169.254.169.254/latest/meta-data", http.NoBody)
+	require.NoError(t, err)
+
+	_, err = rt.RoundTrip(req)
+	require.ErrorIs(t, err, denied)
+
+	fullStore.waitForStore(t, 5*time.Second)
+	records := store.getRecords()
+	require.Len(t, records, 1)
+	require.Equal(t, ResponseSourceEgressDenied, records[0].ResponseSource)
+	require.Equal(t, http.StatusForbidden, records[0].ResponseStatusCode)
+}
+
+func TestRoundTripper_RoundTrip_LinksRetryAttempts(t *testing.T) {
+	store := &mockRecordStore{}
+	fullStore := newMockFullStore()
+
+	rt := &RoundTripper{
+		store:     store,
+		fullStore: fullStore,
+		logger:    slog.New(slog.NewTextHandler(io.Discard, nil)),
+		captureConfig: captureConfig{
+			expiration:            time.Minute,
+			fullRequestExpiration: time.Minute,
+		},
+		requestInfo: httpf.RequestInfo{},
+		transport:   &mockRoundTripper{response: &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewReader(nil))}},
+	}
+
+	firstId := apid.New(apid.PrefixRequestEvents)
+	attr := &Attribution{Attempt: 2, RetryOf: firstId}
+	req, err := http.NewRequestWithContext(ContextWithAttribution(context.Background(), attr), http.MethodGet, "http://example.com/", http.NoBody)
+	require.NoError(t, err)
+
+	resp, err := rt.RoundTrip(req)
+	require.NoError(t, err)
+	_, _ = io.ReadAll(resp.Body)
+	require.NoError(t, resp.Body.Close())
+
+	fullStore.waitForStore(t, 5*time.Second)
+	records := store.getRecords()
+	require.Len(t, records, 1)
+	require.Equal(t, records[0].RequestId, attr.RequestId, "the assigned id is reported back to the caller")
+	require.Equal(t, 2, records[0].Attempt)
+	require.Equal(t, firstId, records[0].RetryOf)
+}
+
+func TestRoundTripper_RoundTrip_TransformsLoggedResponseBody(t *testing.T) {
+	store := &mockRecordStore{}
+	fullStore := newMockFullStore()
+
+	body := []byte(`{"id":"c_1","email":"ada@example.com"}`)
+	rt := &RoundTripper{
+		store:     store,
+		fullStore: fullStore,
+		logger:    slog.New(slog.NewTextHandler(io.Discard, nil)),
+		captureConfig: captureConfig{
+			expiration:            time.Minute,
+			fullRequestExpiration: time.Minute,
+			recordFullRequest:     true,
+			maxFullRequestSize:    1024,
+			maxFullResponseSize:   1024,
+			maxResponseWait:       time.Second,
+		},
+		requestInfo: httpf.RequestInfo{},
+		transport: &mockRoundTripper{response: &http.Response{
+			StatusCode:    http.StatusOK,
+			Header:        http.Header{"Content-Type": {"application/json"}},
+			ContentLength: int64(len(body)),
+			Body:          io.NopCloser(bytes.NewReader(body)),
+		}},
+	}
+
+	attr := &Attribution{TransformResponseBody: func(statusCode int, header http.Header, body []byte) []byte {
+		require.Equal(t, http.StatusOK, statusCode)
+		return []byte(`{"id":"c_1"}`)
+	}}
+	req, err := http.NewRequestWithContext(ContextWithAttribution(context.Background(), attr), http.MethodGet, "http://example.com/", http.NoBody)
+	require.NoError(t, err)
+
+	resp, err := rt.RoundTrip(req)
+	require.NoError(t, err)
+	got, _ := io.ReadAll(resp.Body)
+	require.NoError(t, resp.Body.Close())
+	require.Equal(t, body, got, "the caller still receives the upstream body")
+
+	fullStore.waitForStore(t, 5*time.Second)
+	logs := fullStore.getLogs()
+	require.Len(t, logs, 1)
+	require.Equal(t, `{"id":"c_1"}`, string(logs[0].Response.Body))
+}
+
 type mockRoundTripper struct {
 	response *http.Response
 	err      error
@@ -740,3 +856,67 @@ func (m *mockRoundTripper) RoundTrip(req *http.Request) (*http.Response, error)
 	io.ReadAll(req.Body) // Simulate request being consumed
 	return m.response, m.err
 }
+
+func TestRoundTripper_RoundTrip_UpgradedSession(t *testing.T) {
+	local, remote := net.Pipe()
+	defer remote.Close()
+
+	store := &mockRecordStore{}
+	fullStore := newMockFullStore()
+	rt := &RoundTripper{
+		store:     store,
+		fullStore: fullStore,
+		logger:    slog.New(slog.NewTextHandler(io.Discard, nil)),
+		captureConfig: captureConfig{
+			expiration:            time.Minute,
+			fullRequestExpiration: time.Minute,
+			// A session must not be cut off by the response-body wait.
+			maxResponseWait: time.Millisecond,
+		},
+		transport: &mockRoundTripper{response: &http.Response{
+			StatusCode: http.StatusSwitchingProtocols,
+			Proto:      "HTTP/1.1",
+			Header:     http.Header{"Connection": {"Upgrade"}, "Upgrade": {"websocket"}},
+			Body:       local,
+		}},
+	}
+
+	fakeClock := clock.NewFakeClock(time.Date(2026, time.October, 1, 12, 0, 0, 0, time.UTC))
+	ctx := apctx.NewBuilderBackground().WithClock(fakeClock).Build()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://realtime.example.com/socket", http.NoBody)
+	require.NoError(t, err)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+
+	resp, err := rt.RoundTrip(req)
+	require.NoError(t, err)
+	session, ok := resp.Body.(io.ReadWriteCloser)
+	require.True(t, ok, "upgraded body must stay writable")
+
+	go func() {
+		buf := make([]byte, 4)
+		_, _ = io.ReadFull(remote, buf)
+		_, _ = remote.Write([]byte("pong!"))
+	}()
+	_, err = session.Write([]byte("ping"))
+	require.NoError(t, err)
+	buf := make([]byte, 5)
+	_, err = io.ReadFull(session, buf)
+	require.NoError(t, err)
+
+	time.Sleep(10 * time.Millisecond)
+	require.Empty(t, store.getRecords(), "record must wait for the session to close")
+
+	fakeClock.Step(90 * time.Second)
+	require.NoError(t, session.Close())
+
+	fullStore.waitForStore(t, 5*time.Second)
+	records := store.getRecords()
+	require.Len(t, records, 1)
+	require.Equal(t, http.StatusSwitchingProtocols, records[0].ResponseStatusCode)
+	require.Equal(t, "websocket", records[0].UpgradeProtocol)
+	require.Equal(t, 90*time.Second, records[0].SessionMillisecondDuration.Duration())
+	require.Equal(t, int64(4), records[0].SessionBytesSent)
+	require.Equal(t, int64(5), records[0].SessionBytesReceived)
+	require.False(t, records[0].InternalTimeout)
+}
@@ -10,6 +10,12 @@ func RenderMustache(template string, data map[string]any) (string, error) {
 	return mustache.Render(template, data)
 }
 
+// RenderMustacheRaw renders a mustache template without HTML-escaping variable values. Used where
+// the output is not markup, e.g. the canonical string of a request signature.
+func RenderMustacheRaw(template string, data map[string]any) (string, error) {
+	return mustache.RenderRaw(template, true, data)
+}
+
 // ContainsMustache returns true if the string contains mustache syntax ({{ }}).
 func ContainsMustache(s string) bool {
 	for i := 0; i < len(s)-1; i++ {
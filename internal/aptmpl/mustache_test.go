@@ -62,6 +62,16 @@ func TestRenderMustache(t *testing.T) {
 	})
 }
 
+func TestRenderMustacheRaw(t *testing.T) {
+	result, err := RenderMustacheRaw("{{method}} {{path}}?{{query}}", map[string]any{
+		"method": "GET",
+		"path":   "/a&b",
+		"query":  "x=1&y=<2>",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "GET /a&b?x=1&y=<2>", result)
+}
+
 func TestContainsMustache(t *testing.T) {
 	assert.True(t, ContainsMustache("https://{{tenant}}.example.com"))
 	assert.True(t, ContainsMustache("{{foo}}"))
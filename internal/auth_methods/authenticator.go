@@ -26,14 +26,17 @@ type AuthApplication struct {
 }
 
 // Authenticator is the auth-method-shaped contract used by the proxy
-// orchestrator. Each auth method (oauth2, api_key, no_auth) exposes one
-// implementation per connection.
+// orchestrator. Each auth method (oauth2, api_key, request_signing,
+// no_auth) exposes one implementation per connection.
 //
 // Resolve and RecoverFrom401 mirror the retry-once-after-refresh semantics
 // already exercised by OAuth2: build the request with the freshly resolved
 // credential, send it, and if the upstream rejects it with 401, recover
 // (refresh) and retry exactly once. Refresh exposes the maintenance refresh
 // operation directly for callers that are not responding to a request failure.
+//
+// Auth methods whose credential depends on the request itself (request
+// signing) additionally implement RequestSigner.
 type Authenticator interface {
 	// Resolve loads the active credential (refreshing automatically if
 	// it is locally known to be expired) and returns the application to
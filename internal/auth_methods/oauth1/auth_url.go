@@ -0,0 +1,175 @@
+package oauth1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+
+	apauthcore "github.com/rmorlok/authproxy/internal/apauth/core"
+	"github.com/rmorlok/authproxy/internal/apauth/jwt"
+	auth "github.com/rmorlok/authproxy/internal/apauth/service"
+	"github.com/rmorlok/authproxy/internal/apctx"
+	"github.com/rmorlok/authproxy/internal/apid"
+	"github.com/rmorlok/authproxy/internal/schema/config"
+)
+
+func (o *oAuth1Connection) getPublicRedirectUrl(ctx context.Context, stateId apid.ID, actor apauthcore.IActorData) (string, error) {
+	if o.cfg == nil {
+		return "", errors.New("config is nil")
+	}
+
+	if o.cfg.GetRoot() == nil {
+		return "", errors.New("config root is nil")
+	}
+
+	tb, err := jwt.NewJwtTokenBuilder().
+		WithActor(actor).
+		WithExpiresInCtx(ctx, o.cfg.GetRoot().Oauth.GetInitiateToRedirectTtlOrDefault()).
+		WithServiceId(config.ServiceIdPublic).
+		WithSystemSigned().
+		WithSecretConfigKeyData(ctx, o.cfg.GetRoot().SystemAuth.GlobalAESKey)
+
+	if err != nil {
+		return "", fmt.Errorf("failed to create token builder to sign redirect jwt: %w", err)
+	}
+
+	tokenString, err := tb.TokenCtx(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to create generate temporary auth token: %w", err)
+	}
+
+	u, err := url.Parse(o.cfg.GetRoot().Public.GetBaseUrl())
+	if err != nil {
+		return "", fmt.Errorf("failed to parse base url for oauth1 return: %w", err)
+	}
+
+	query := u.Query()
+	query.Set("stateId", stateId.String())
+	auth.SetJwtQueryParm(query, tokenString)
+
+	u.Path += "/oauth1/redirect"
+	u.RawQuery = query.Encode()
+
+	return u.String(), nil
+}
+
+// getPublicCallbackUrl returns the oauth_callback sent with the request-token
+// request. OAuth 1.0a has no state parameter, so the state id rides on the
+// callback URL itself; the provider appends oauth_token and oauth_verifier.
+func (o *oAuth1Connection) getPublicCallbackUrl() (string, error) {
+	if o.cfg == nil {
+		return "", errors.New("config is nil")
+	}
+
+	if o.cfg.GetRoot() == nil {
+		return "", errors.New("config root is nil")
+	}
+
+	if o.state == nil {
+		return "", errors.New("must have existing state stored to redis")
+	}
+
+	u, err := url.Parse(o.cfg.GetRoot().Public.GetBaseUrl())
+	if err != nil {
+		return "", fmt.Errorf("failed to parse base url for oauth1 return: %w", err)
+	}
+
+	u.Path += "/oauth1/callback"
+	u.RawQuery = url.Values{"state": {o.state.Id.String()}}.Encode()
+	return u.String(), nil
+}
+
+// GenerateAuthUrl performs the first leg of the flow: it obtains temporary
+// credentials from the request-token endpoint, records them on the state and
+// returns the provider's authorization URL for the request token.
+func (o *oAuth1Connection) GenerateAuthUrl(ctx context.Context, actor apauthcore.IActorData) (string, error) {
+	connectorId := o.connection.GetConnector().GetId()
+
+	if o.state == nil {
+		return "", fmt.Errorf("must have existing state stored to redis")
+	}
+
+	callbackUrl, err := o.getPublicCallbackUrl()
+	if err != nil {
+		return "", fmt.Errorf("failed to get public callback url: %w", err)
+	}
+
+	requestTokenEndpoint, err := o.renderMustache(ctx, o.auth.RequestToken.Endpoint)
+	if err != nil {
+		return "", fmt.Errorf("failed to render request token endpoint template for connector %s: %w", connectorId, err)
+	}
+
+	values, err := o.postSignedForm(ctx, requestTokenEndpoint, "", "", map[string]string{
+		"oauth_callback": callbackUrl,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to obtain request token for connector %s: %w", connectorId, err)
+	}
+
+	// RFC 5849 §2.1 — the server MUST confirm the callback. A provider that
+	// doesn't is speaking OAuth 1.0 (pre-1.0a) and is vulnerable to session
+	// fixation, so the flow is refused.
+	if values.Get("oauth_callback_confirmed") != "true" {
+		return "", fmt.Errorf("request token endpoint for connector %s did not confirm the callback", connectorId)
+	}
+
+	requestToken := values.Get("oauth_token")
+	if requestToken == "" {
+		return "", fmt.Errorf("request token endpoint for connector %s did not return oauth_token", connectorId)
+	}
+
+	o.state.RequestToken = requestToken
+	o.state.RequestTokenSecret = values.Get("oauth_token_secret")
+	ttl := o.state.ExpiresAt.Sub(apctx.GetClock(ctx).Now())
+	if err := writeStateToRedis(ctx, o.r, o.encrypt, o.state, ttl); err != nil {
+		return "", fmt.Errorf("failed to record request token for connection %s: %w", o.connection.GetId(), err)
+	}
+
+	authEndpoint, err := o.renderMustache(ctx, o.auth.Authorization.Endpoint)
+	if err != nil {
+		return "", fmt.Errorf("failed to render authorization endpoint template for connector %s: %w", connectorId, err)
+	}
+
+	authUrl3p, err := url.Parse(authEndpoint)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse authorization endpoint for connector %s: %w", connectorId, err)
+	}
+
+	query := authUrl3p.Query()
+	query.Set("oauth_token", requestToken)
+
+	for k, v := range o.auth.Authorization.QueryOverrides {
+		rendered, err := o.renderMustache(ctx, v)
+		if err != nil {
+			return "", fmt.Errorf("failed to render query override %q template for connector %s: %w", k, connectorId, err)
+		}
+		query.Set(k, rendered)
+	}
+
+	authUrl3p.RawQuery = query.Encode()
+
+	return authUrl3p.String(), nil
+}
+
+// SetStateAndGeneratePublicUrl starts the OAuth1 process. It creates a state record for the connection authorization
+// flow and returns a redirect URL to our public /oauth1/redirect route. The request token is not obtained until the
+// user follows that redirect, so temporary credentials are never requested for a flow the user abandons early.
+func (o *oAuth1Connection) SetStateAndGeneratePublicUrl(
+	ctx context.Context,
+	actor apauthcore.IActorData,
+	returnToUrl string,
+) (string, error) {
+	stateId := apid.New(apid.PrefixOauth1State)
+
+	if err := o.saveStateToRedis(ctx, actor, stateId, o.safeReturnToUrl(returnToUrl)); err != nil {
+		return "", err
+	}
+
+	redirectUrl, err := o.getPublicRedirectUrl(ctx, stateId, actor)
+	if err != nil {
+		return "", fmt.Errorf("failed to get public redirect url: %w", err)
+	}
+
+	return redirectUrl, nil
+}
@@ -0,0 +1,28 @@
+package oauth1
+
+import (
+	"context"
+
+	"github.com/rmorlok/authproxy/internal/auth_methods"
+)
+
+// Resolve returns an empty AuthApplication. OAuth 1.0a credentials are a
+// per-request signature over the final method, URL and form body, so the
+// work happens in SignRequest once the orchestrator has built the request.
+func (o *oAuth1Connection) Resolve(ctx context.Context) (auth_methods.AuthApplication, error) {
+	return auth_methods.AuthApplication{}, nil
+}
+
+// RecoverFrom401 returns ErrCannotRecover. OAuth 1.0a tokens have no refresh
+// grant; a rejected token requires the user to re-authorize.
+func (o *oAuth1Connection) RecoverFrom401(ctx context.Context) error {
+	return auth_methods.ErrCannotRecover
+}
+
+// Refresh is a no-op for OAuth1 connections; there is nothing to refresh.
+func (o *oAuth1Connection) Refresh(ctx context.Context) error {
+	return nil
+}
+
+var _ auth_methods.Authenticator = (*oAuth1Connection)(nil)
+var _ auth_methods.RequestSigner = (*oAuth1Connection)(nil)
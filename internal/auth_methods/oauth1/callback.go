@@ -0,0 +1,106 @@
+package oauth1
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/rmorlok/authproxy/internal/database"
+	"github.com/rmorlok/authproxy/internal/schema/config"
+)
+
+// CallbackFrom3rdParty completes the flow after the provider redirects back
+// with oauth_token and oauth_verifier. On failure the connection is moved to
+// the auth-failed state and the user is returned to the original return URL
+// so the marketplace UI can offer a retry, matching the OAuth2 callback.
+func (o *oAuth1Connection) CallbackFrom3rdParty(ctx context.Context, query url.Values) (string, error) {
+	errorRedirectPage := o.cfg.GetErrorPageUrl(config.ErrorPageInternalError)
+
+	if o.state == nil {
+		return errorRedirectPage, errors.New("state is nil")
+	}
+
+	redirectUrl, err := o.exchangeVerifierAndAdvance(ctx, query)
+	if err == nil {
+		return redirectUrl, nil
+	}
+
+	o.logger.WarnContext(ctx, "oauth1 token exchange failed",
+		"connection_id", o.connection.GetId(),
+		"state_id", o.state.Id,
+		"error", err,
+	)
+
+	if recordErr := o.connection.HandleAuthFailed(ctx, err); recordErr != nil {
+		return errorRedirectPage, fmt.Errorf("failed to record auth failure (%v) after: %w", recordErr, err)
+	}
+	return o.appendSetupPendingToReturnUrl(o.safeReturnToUrl(o.state.ReturnToUrl)), nil
+}
+
+func (o *oAuth1Connection) exchangeVerifierAndAdvance(ctx context.Context, query url.Values) (string, error) {
+	// The callback consumes the state whatever the outcome; a verifier can
+	// only be exchanged once.
+	if err := deleteStateFromRedis(ctx, o.r, o.state.Id); err != nil {
+		return "", fmt.Errorf("failed to clean up oauth1 state: %w", err)
+	}
+
+	// OAuth 1.0a has no standard denial response. Providers commonly send
+	// `denied=<token>` or the oauth_problem extension instead of a verifier.
+	if problem := query.Get("oauth_problem"); problem != "" {
+		return "", fmt.Errorf("authorization denied by provider: %s", problem)
+	}
+	if query.Has("denied") {
+		return "", errors.New("authorization denied by provider")
+	}
+
+	if o.state.RequestToken == "" {
+		return "", errors.New("no request token recorded for state")
+	}
+
+	token := query.Get("oauth_token")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(o.state.RequestToken)) != 1 {
+		return "", errors.New("oauth_token does not match the request token for this flow")
+	}
+
+	verifier := query.Get("oauth_verifier")
+	if verifier == "" {
+		return "", errors.New("no oauth_verifier in query")
+	}
+
+	accessTokenEndpoint, err := o.renderMustache(ctx, o.auth.AccessToken.Endpoint)
+	if err != nil {
+		return "", fmt.Errorf("failed to render access token endpoint template: %w", err)
+	}
+
+	values, err := o.postSignedForm(ctx, accessTokenEndpoint, o.state.RequestToken, o.state.RequestTokenSecret, map[string]string{
+		"oauth_verifier": verifier,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to exchange verifier for access token: %w", err)
+	}
+
+	accessToken := values.Get("oauth_token")
+	if accessToken == "" {
+		return "", errors.New("access token endpoint did not return oauth_token")
+	}
+
+	if err := o.persistToken(ctx, database.OAuth1TokenPlaintext{
+		Token:       accessToken,
+		TokenSecret: values.Get("oauth_token_secret"),
+	}); err != nil {
+		return "", err
+	}
+
+	outcome, err := o.connection.HandleCredentialsEstablished(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to handle post-auth state transition: %w", err)
+	}
+
+	returnToUrl := o.safeReturnToUrl(o.state.ReturnToUrl)
+	if outcome.SetupPending {
+		return o.appendSetupPendingToReturnUrl(returnToUrl), nil
+	}
+	return returnToUrl, nil
+}
@@ -0,0 +1,69 @@
+package oauth1
+
+import (
+	"context"
+	"log/slog"
+
+	apauthcore "github.com/rmorlok/authproxy/internal/apauth/core"
+	"github.com/rmorlok/authproxy/internal/apid"
+	"github.com/rmorlok/authproxy/internal/apredis"
+	"github.com/rmorlok/authproxy/internal/auth_methods"
+	"github.com/rmorlok/authproxy/internal/config"
+	coreIface "github.com/rmorlok/authproxy/internal/core/iface"
+	"github.com/rmorlok/authproxy/internal/database"
+	"github.com/rmorlok/authproxy/internal/encrypt"
+	"github.com/rmorlok/authproxy/internal/httpf"
+)
+
+type factory struct {
+	cfg     config.C
+	db      database.DB
+	redis   apredis.Client
+	core    coreIface.C
+	httpf   httpf.F
+	encrypt encrypt.E
+	logger  *slog.Logger
+}
+
+// NewFactory constructs an OAuth 1.0a factory. The factory is owned by the
+// core service (and the public routes) and shared across all OAuth1
+// connections.
+func NewFactory(
+	cfg config.C,
+	db database.DB,
+	r apredis.Client,
+	core coreIface.C,
+	httpf httpf.F,
+	encrypt encrypt.E,
+	logger *slog.Logger,
+) Factory {
+	return &factory{
+		cfg:     cfg,
+		db:      db,
+		redis:   r,
+		core:    core,
+		httpf:   httpf,
+		encrypt: encrypt,
+		logger:  logger,
+	}
+}
+
+var _ auth_methods.Factory = (*factory)(nil)
+
+func (f *factory) NewOAuth1(connection coreIface.Connection) OAuth1Connection {
+	return f.newConnection(connection)
+}
+
+// NewAuthenticator returns the same oAuth1Connection instance typed as an
+// auth_methods.Authenticator.
+func (f *factory) NewAuthenticator(connection coreIface.Connection) auth_methods.Authenticator {
+	return f.newConnection(connection)
+}
+
+func (f *factory) newConnection(connection coreIface.Connection) *oAuth1Connection {
+	return newOAuth1(f.cfg, f.db, f.redis, f.encrypt, f.logger, f.httpf, connection)
+}
+
+func (f *factory) GetOAuth1State(ctx context.Context, actor apauthcore.IActorData, stateId apid.ID) (OAuth1Connection, error) {
+	return getOAuth1State(ctx, f.cfg, f.db, f.redis, f.core, f.httpf, f.encrypt, f.logger, actor, stateId)
+}
@@ -0,0 +1,360 @@
+package oauth1
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/rmorlok/authproxy/internal/apctx"
+	"github.com/rmorlok/authproxy/internal/apid"
+	"github.com/rmorlok/authproxy/internal/aplog"
+	"github.com/rmorlok/authproxy/internal/apredis"
+	"github.com/rmorlok/authproxy/internal/auth_methods"
+	"github.com/rmorlok/authproxy/internal/config"
+	coreIface "github.com/rmorlok/authproxy/internal/core/iface"
+	mockCore "github.com/rmorlok/authproxy/internal/core/mock"
+	"github.com/rmorlok/authproxy/internal/database"
+	mockDb "github.com/rmorlok/authproxy/internal/database/mock"
+	"github.com/rmorlok/authproxy/internal/encfield"
+	"github.com/rmorlok/authproxy/internal/encrypt"
+	mockH "github.com/rmorlok/authproxy/internal/httpf/mock"
+	"github.com/rmorlok/authproxy/internal/schema/common"
+	sconfig "github.com/rmorlok/authproxy/internal/schema/config"
+	cschema "github.com/rmorlok/authproxy/internal/schema/resources/connectors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	genmock "gopkg.in/h2non/gentleman-mock.v2"
+	gock "gopkg.in/h2non/gock.v1"
+)
+
+// connectionWithConnector overrides the mock connection's nil GetConnector
+// so the flow can read the connector definition.
+type connectionWithConnector struct {
+	*mockCore.Connection
+	connector *mockCore.Connector
+}
+
+func (c *connectionWithConnector) GetConnector() coreIface.Connector {
+	return c.connector
+}
+
+// captureAuthorization is a gock matcher that records the decoded OAuth
+// parameters from the request's Authorization header.
+func captureAuthorization(t *testing.T, dst *map[string]string) func(*http.Request, *gock.Request) (bool, error) {
+	return func(req *http.Request, _ *gock.Request) (bool, error) {
+		*dst = parseAuthorizationHeader(t, req.Header.Get("Authorization"))
+		return true, nil
+	}
+}
+
+type flowTest struct {
+	o1     *oAuth1Connection
+	db     *mockDb.MockDB
+	conn   *mockCore.Connection
+	ctx    context.Context
+	stored *database.OAuth1TokenPlaintext
+}
+
+func setupFlowTest(t *testing.T) *flowTest {
+	t.Helper()
+	ctrl := gomock.NewController(t)
+	t.Cleanup(ctrl.Finish)
+
+	_, r := apredis.MustApplyTestConfig(nil)
+	cfg := config.FromRoot(&sconfig.Root{
+		Public: sconfig.ServicePublic{
+			ServiceHttp: sconfig.ServiceHttp{
+				PortVal: common.NewIntegerValueDirect(8080),
+			},
+		},
+		SystemAuth: sconfig.SystemAuth{
+			GlobalAESKey: sconfig.NewKeyDataRandomBytes(),
+		},
+	})
+
+	auth := &cschema.AuthOAuth1{
+		Type:           cschema.AuthTypeOAuth1,
+		ConsumerKey:    common.NewStringValueDirect("consumer-key"),
+		ConsumerSecret: common.NewStringValueDirect("consumer-secret"),
+		RequestToken:   cschema.AuthOAuth1Endpoint{Endpoint: "http://example.com/oauth/request_token"},
+		Authorization: cschema.AuthOAuth1Authorization{
+			Endpoint:       "http://example.com/oauth/authorize",
+			QueryOverrides: map[string]string{"perms": "read"},
+		},
+		AccessToken: cschema.AuthOAuth1Endpoint{Endpoint: "http://example.com/oauth/access_token"},
+		Revocation:  &cschema.AuthOAuth1Endpoint{Endpoint: "http://example.com/oauth/revoke"},
+	}
+
+	conn := &mockCore.Connection{Id: apid.New(apid.PrefixConnection), Namespace: "root"}
+	db := mockDb.NewMockDB(ctrl)
+	ft := &flowTest{
+		db:   db,
+		conn: conn,
+		ctx:  apctx.WithFixedClock(context.Background(), time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)),
+	}
+
+	ft.o1 = &oAuth1Connection{
+		cfg:     cfg,
+		db:      db,
+		r:       r,
+		encrypt: encrypt.NewFakeEncryptService(false),
+		logger:  aplog.NewNoopLogger(),
+		auth:    auth,
+		httpf:   mockH.NewFactoryWithMockingClient(ctrl),
+		connection: &connectionWithConnector{
+			Connection: conn,
+			connector: &mockCore.Connector{
+				Id:         apid.New(apid.PrefixConnectorVersion),
+				Definition: &cschema.Connector{Auth: &cschema.Auth{InnerVal: auth}},
+			},
+		},
+	}
+
+	ft.o1.state = &state{
+		Id:                  apid.New(apid.PrefixOauth1State),
+		ActorNamespace:      "root",
+		ConnectionNamespace: "root",
+		ActorId:             apid.New(apid.PrefixActor),
+		ConnectorId:         ft.o1.connection.GetConnector().GetId(),
+		ConnectionId:        conn.Id,
+		ReturnToUrl:         "http://localhost:8080/connections/abc",
+		ExpiresAt:           apctx.GetClock(ft.ctx).Now().Add(time.Hour),
+	}
+	require.NoError(t, writeStateToRedis(ft.ctx, r, ft.o1.encrypt, ft.o1.state, time.Hour))
+
+	return ft
+}
+
+// expectTokenStored records the token passed to InsertApiKeyCredential.
+func (ft *flowTest) expectTokenStored(t *testing.T) {
+	ft.db.
+		EXPECT().
+		InsertApiKeyCredential(gomock.Any(), ft.conn.Id, gomock.Any(), nil, gomock.Any()).
+		DoAndReturn(func(_ context.Context, _ apid.ID, ef encfield.EncryptedField, _ *cschema.ApiKeyPlacement, actorId *apid.ID) (*database.ApiKeyCredential, error) {
+			assert.Equal(t, ft.o1.state.ActorId, *actorId)
+			var tok database.OAuth1TokenPlaintext
+			require.NoError(t, json.Unmarshal([]byte(ef.Data), &tok))
+			ft.stored = &tok
+			return &database.ApiKeyCredential{}, nil
+		})
+}
+
+func TestGenerateAuthUrl(t *testing.T) {
+	t.Run("obtains a request token and records it on the state", func(t *testing.T) {
+		ft := setupFlowTest(t)
+
+		var params map[string]string
+		genmock.
+			New("http://example.com").
+			Post("/oauth/request_token").
+			AddMatcher(captureAuthorization(t, &params)).
+			Reply(200).
+			BodyString("oauth_token=rt&oauth_token_secret=rts&oauth_callback_confirmed=true")
+
+		authUrl, err := ft.o1.GenerateAuthUrl(ft.ctx, nil)
+		require.NoError(t, err)
+
+		u, err := url.Parse(authUrl)
+		require.NoError(t, err)
+		assert.Equal(t, "/oauth/authorize", u.Path)
+		assert.Equal(t, "rt", u.Query().Get("oauth_token"))
+		assert.Equal(t, "read", u.Query().Get("perms"))
+
+		assert.Equal(t, "consumer-key", params["oauth_consumer_key"])
+		assert.Equal(t, "http://localhost:8080/oauth1/callback?state="+ft.o1.state.Id.String(), params["oauth_callback"])
+		_, hasToken := params["oauth_token"]
+		assert.False(t, hasToken, "request token request is signed with consumer credentials only")
+
+		stored, err := readStateFromRedis(ft.ctx, ft.o1.r, ft.o1.encrypt, ft.o1.state.Id)
+		require.NoError(t, err)
+		assert.Equal(t, "rt", stored.RequestToken)
+		assert.Equal(t, "rts", stored.RequestTokenSecret)
+	})
+
+	t.Run("refuses providers that do not confirm the callback", func(t *testing.T) {
+		ft := setupFlowTest(t)
+
+		genmock.
+			New("http://example.com").
+			Post("/oauth/request_token").
+			Reply(200).
+			BodyString("oauth_token=rt&oauth_token_secret=rts")
+
+		_, err := ft.o1.GenerateAuthUrl(ft.ctx, nil)
+		assert.ErrorContains(t, err, "did not confirm the callback")
+	})
+
+	t.Run("surfaces request token endpoint errors", func(t *testing.T) {
+		ft := setupFlowTest(t)
+
+		genmock.
+			New("http://example.com").
+			Post("/oauth/request_token").
+			Reply(401).
+			BodyString("oauth_problem=signature_invalid")
+
+		_, err := ft.o1.GenerateAuthUrl(ft.ctx, nil)
+		assert.ErrorContains(t, err, "received status code 401")
+	})
+}
+
+func TestCallbackFrom3rdParty(t *testing.T) {
+	withRequestToken := func(ft *flowTest) {
+		ft.o1.state.RequestToken = "rt"
+		ft.o1.state.RequestTokenSecret = "rts"
+	}
+
+	t.Run("exchanges the verifier and stores the access token", func(t *testing.T) {
+		ft := setupFlowTest(t)
+		withRequestToken(ft)
+		ft.expectTokenStored(t)
+
+		var params map[string]string
+		genmock.
+			New("http://example.com").
+			Post("/oauth/access_token").
+			AddMatcher(captureAuthorization(t, &params)).
+			Reply(200).
+			BodyString("oauth_token=at&oauth_token_secret=ats")
+
+		redirectUrl, err := ft.o1.CallbackFrom3rdParty(ft.ctx, url.Values{
+			"oauth_token":    {"rt"},
+			"oauth_verifier": {"verifier"},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "http://localhost:8080/connections/abc", redirectUrl)
+
+		assert.Equal(t, "rt", params["oauth_token"])
+		assert.Equal(t, "verifier", params["oauth_verifier"])
+
+		require.NotNil(t, ft.stored)
+		assert.Equal(t, database.OAuth1TokenPlaintext{Token: "at", TokenSecret: "ats"}, *ft.stored)
+		assert.Nil(t, ft.conn.SetupError)
+
+		_, err = readStateFromRedis(ft.ctx, ft.o1.r, ft.o1.encrypt, ft.o1.state.Id)
+		assert.ErrorIs(t, err, errStateNotFound, "state is consumed by the callback")
+	})
+
+	t.Run("rejects a mismatched request token", func(t *testing.T) {
+		ft := setupFlowTest(t)
+		withRequestToken(ft)
+
+		redirectUrl, err := ft.o1.CallbackFrom3rdParty(ft.ctx, url.Values{
+			"oauth_token":    {"other"},
+			"oauth_verifier": {"verifier"},
+		})
+		require.NoError(t, err)
+		assert.Contains(t, redirectUrl, "http://localhost:8080/connections/abc")
+		require.NotNil(t, ft.conn.SetupError)
+		assert.Contains(t, *ft.conn.SetupError, "does not match")
+	})
+
+	t.Run("records provider denial as an auth failure", func(t *testing.T) {
+		ft := setupFlowTest(t)
+		withRequestToken(ft)
+
+		_, err := ft.o1.CallbackFrom3rdParty(ft.ctx, url.Values{
+			"denied": {"rt"},
+		})
+		require.NoError(t, err)
+		require.NotNil(t, ft.conn.SetupError)
+		assert.Contains(t, *ft.conn.SetupError, "denied")
+		require.NotNil(t, ft.conn.SetupStep)
+		assert.Equal(t, cschema.SetupStepAuthFailed, *ft.conn.SetupStep)
+	})
+
+	t.Run("rejects a callback before a request token was issued", func(t *testing.T) {
+		ft := setupFlowTest(t)
+
+		_, err := ft.o1.CallbackFrom3rdParty(ft.ctx, url.Values{
+			"oauth_token":    {""},
+			"oauth_verifier": {"verifier"},
+		})
+		require.NoError(t, err)
+		require.NotNil(t, ft.conn.SetupError)
+		assert.Contains(t, *ft.conn.SetupError, "no request token")
+	})
+}
+
+func TestSignRequestAndRevoke(t *testing.T) {
+	expectToken := func(ft *flowTest) {
+		ft.db.
+			EXPECT().
+			GetActiveApiKeyCredential(gomock.Any(), ft.conn.Id).
+			Return(&database.ApiKeyCredential{
+				ConnectionId:         ft.conn.Id,
+				EncryptedCredentials: encfield.EncryptedField{ID: "dek_fake", Data: `{"token":"at","tokenSecret":"ats"}`},
+			}, nil).
+			AnyTimes()
+	}
+
+	t.Run("signs form bodies into the signature", func(t *testing.T) {
+		ft := setupFlowTest(t)
+		expectToken(ft)
+
+		body := []byte("status=hello%20world")
+		req, err := http.NewRequest(http.MethodPost, "https://api.example.com/statuses/update?include_entities=true", nil)
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		require.NoError(t, ft.o1.SignRequest(ft.ctx, req, auth_methods.SigningPayload{Body: body}))
+
+		params := parseAuthorizationHeader(t, req.Header.Get("Authorization"))
+		assert.Equal(t, "at", params["oauth_token"])
+
+		form, err := url.ParseQuery(string(body))
+		require.NoError(t, err)
+		s, err := newSigner(ft.ctx, ft.o1.auth)
+		require.NoError(t, err)
+		want, err := s.sign(signatureBaseString(http.MethodPost, req.URL, oauthParamsFromHeader(params), form), "ats")
+		require.NoError(t, err)
+		assert.Equal(t, want, params["oauth_signature"])
+	})
+
+	t.Run("fails when the connection has no token", func(t *testing.T) {
+		ft := setupFlowTest(t)
+		ft.db.
+			EXPECT().
+			GetActiveApiKeyCredential(gomock.Any(), ft.conn.Id).
+			Return(nil, database.ErrNotFound)
+
+		req, err := http.NewRequest(http.MethodGet, "https://api.example.com/items", nil)
+		require.NoError(t, err)
+		assert.ErrorIs(t, ft.o1.SignRequest(ft.ctx, req, auth_methods.SigningPayload{}), database.ErrNotFound)
+	})
+
+	t.Run("revokes at the provider then deletes the local token", func(t *testing.T) {
+		ft := setupFlowTest(t)
+		expectToken(ft)
+
+		var params map[string]string
+		genmock.
+			New("http://example.com").
+			Post("/oauth/revoke").
+			AddMatcher(captureAuthorization(t, &params)).
+			Reply(200)
+
+		ft.db.
+			EXPECT().
+			DeleteAllApiKeyCredentialsForConnection(gomock.Any(), ft.conn.Id).
+			Return(nil)
+
+		require.True(t, ft.o1.SupportsRevoke())
+		require.NoError(t, ft.o1.Revoke(ft.ctx))
+		assert.Equal(t, "at", params["oauth_token"])
+	})
+
+	t.Run("revoke is a no-op without a token", func(t *testing.T) {
+		ft := setupFlowTest(t)
+		ft.db.
+			EXPECT().
+			GetActiveApiKeyCredential(gomock.Any(), ft.conn.Id).
+			Return(nil, database.ErrNotFound)
+
+		require.NoError(t, ft.o1.Revoke(ft.ctx))
+	})
+}
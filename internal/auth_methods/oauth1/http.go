@@ -0,0 +1,79 @@
+package oauth1
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/rmorlok/authproxy/internal/httpf"
+)
+
+// postSignedForm sends an empty-bodied POST to one of the provider's OAuth
+// endpoints, signed with the consumer credentials and the given token
+// credentials, and parses the form-encoded response body (RFC 5849 §2).
+func (o *oAuth1Connection) postSignedForm(
+	ctx context.Context,
+	endpoint string,
+	token string,
+	tokenSecret string,
+	extra map[string]string,
+) (url.Values, error) {
+	body, err := o.postSigned(ctx, endpoint, token, tokenSecret, extra)
+	if err != nil {
+		return nil, err
+	}
+
+	values, err := url.ParseQuery(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse form-encoded response: %w", err)
+	}
+
+	return values, nil
+}
+
+// postSigned sends an empty-bodied signed POST and returns the response body.
+// Any non-2xx status is an error.
+func (o *oAuth1Connection) postSigned(
+	ctx context.Context,
+	endpoint string,
+	token string,
+	tokenSecret string,
+	extra map[string]string,
+) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse endpoint: %w", err)
+	}
+
+	s, err := newSigner(ctx, o.auth)
+	if err != nil {
+		return "", err
+	}
+
+	authHeader, err := s.authorizationHeader(ctx, http.MethodPost, u, nil, token, tokenSecret, extra)
+	if err != nil {
+		return "", err
+	}
+
+	c := o.httpf.
+		ForRequestType(httpf.RequestTypeOAuth).
+		ForConnection(o.connection).
+		New().
+		UseContext(ctx)
+
+	resp, err := c.Request().
+		Method(http.MethodPost).
+		URL(u.String()).
+		AddHeader("Authorization", authHeader).
+		Send()
+	if err != nil {
+		return "", fmt.Errorf("failed to post to %s: %w", u.Redacted(), err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return "", fmt.Errorf("received status code %d from %s", resp.StatusCode, u.Redacted())
+	}
+
+	return resp.String(), nil
+}
@@ -0,0 +1,37 @@
+package oauth1
+
+import (
+	"context"
+	"net/url"
+
+	apauthcore "github.com/rmorlok/authproxy/internal/apauth/core"
+	"github.com/rmorlok/authproxy/internal/apid"
+	"github.com/rmorlok/authproxy/internal/auth_methods"
+	coreIface "github.com/rmorlok/authproxy/internal/core/iface"
+	cschema "github.com/rmorlok/authproxy/internal/schema/resources/connectors"
+)
+
+// Factory builds OAuth 1.0a connections. In addition to the generic
+// auth_methods.Factory surface it exposes the flow-specific entry points used
+// by the public /oauth1 routes.
+type Factory interface {
+	NewOAuth1(connection coreIface.Connection) OAuth1Connection
+	NewAuthenticator(connection coreIface.Connection) auth_methods.Authenticator
+	ManifestSetupSteps(connection coreIface.Connection, connector *cschema.Connector) []coreIface.ManifestSetupStep
+	GetOAuth1State(ctx context.Context, actor apauthcore.IActorData, stateId apid.ID) (OAuth1Connection, error)
+}
+
+// OAuth1Connection drives the three-legged flow for a single connection. The
+// shape mirrors oauth2.OAuth2Connection so the public routes for both
+// protocols read the same.
+type OAuth1Connection interface {
+	RecordCancelSessionAfterAuth(ctx context.Context, shouldCancel bool) error
+	CancelSessionAfterAuth() bool
+	GenerateAuthUrl(ctx context.Context, actor apauthcore.IActorData) (string, error)
+	SetStateAndGeneratePublicUrl(
+		ctx context.Context,
+		actor apauthcore.IActorData,
+		returnToUrl string,
+	) (string, error)
+	CallbackFrom3rdParty(ctx context.Context, query url.Values) (string, error)
+}
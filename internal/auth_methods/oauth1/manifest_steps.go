@@ -0,0 +1,44 @@
+package oauth1
+
+import (
+	"context"
+	"fmt"
+
+	apauthcore "github.com/rmorlok/authproxy/internal/apauth/core"
+	coreIface "github.com/rmorlok/authproxy/internal/core/iface"
+	cschema "github.com/rmorlok/authproxy/internal/schema/resources/connectors"
+)
+
+// OAuth1AuthorizeStepId is the manifest id for OAuth1's authorize-redirect
+// step.
+const OAuth1AuthorizeStepId = "apxy:auth:oauth1_authorize"
+
+// ManifestSetupSteps returns the single redirect step that sends the user
+// through the three-legged flow.
+func (f *factory) ManifestSetupSteps(connection coreIface.Connection, connector *cschema.Connector) []coreIface.ManifestSetupStep {
+	if connector == nil || connector.Auth == nil {
+		return nil
+	}
+	if _, ok := connector.Auth.Inner().(*cschema.AuthOAuth1); !ok {
+		return nil
+	}
+	return []coreIface.ManifestSetupStep{
+		coreIface.NewRedirectStep(coreIface.RedirectStepConfig{
+			Id:          OAuth1AuthorizeStepId,
+			Title:       "Authorize",
+			Description: "Sign in to authorize this connection.",
+			Render: func(ctx context.Context, opts coreIface.RenderRedirectOptions) (coreIface.RedirectInfo, error) {
+				if opts.ReturnToUrl == "" {
+					return coreIface.RedirectInfo{}, fmt.Errorf("returnToUrl is required for OAuth1 authorize step")
+				}
+				ra := apauthcore.GetAuthFromContext(ctx)
+				o1 := f.NewOAuth1(connection)
+				url, err := o1.SetStateAndGeneratePublicUrl(ctx, ra.MustGetActor(), opts.ReturnToUrl)
+				if err != nil {
+					return coreIface.RedirectInfo{}, err
+				}
+				return coreIface.RedirectInfo{URL: url}, nil
+			},
+		}),
+	}
+}
@@ -0,0 +1,94 @@
+package oauth1
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/rmorlok/authproxy/internal/apctx"
+	"github.com/rmorlok/authproxy/internal/apredis"
+	"github.com/rmorlok/authproxy/internal/aptmpl"
+	"github.com/rmorlok/authproxy/internal/config"
+	coreIface "github.com/rmorlok/authproxy/internal/core/iface"
+	"github.com/rmorlok/authproxy/internal/database"
+	"github.com/rmorlok/authproxy/internal/encrypt"
+	"github.com/rmorlok/authproxy/internal/httpf"
+	cschema "github.com/rmorlok/authproxy/internal/schema/resources/connectors"
+)
+
+type oAuth1Connection struct {
+	cfg     config.C
+	db      database.DB
+	r       apredis.Client
+	encrypt encrypt.E
+	logger  *slog.Logger
+	auth    *cschema.AuthOAuth1
+	httpf   httpf.F
+
+	connection coreIface.Connection
+	state      *state
+}
+
+var _ OAuth1Connection = (*oAuth1Connection)(nil)
+
+func newOAuth1(
+	cfg config.C,
+	db database.DB,
+	r apredis.Client,
+	encrypt encrypt.E,
+	logger *slog.Logger,
+	httpf httpf.F,
+	connection coreIface.Connection,
+) *oAuth1Connection {
+	connDef := connection.GetConnector().GetDefinition()
+	auth, ok := connDef.Auth.Inner().(*cschema.AuthOAuth1)
+	if !ok {
+		panic(fmt.Sprintf("connector id %s is not an oauth1 connector", connDef.Id))
+	}
+
+	return &oAuth1Connection{
+		cfg:     cfg,
+		db:      db,
+		r:       r,
+		encrypt: encrypt,
+		logger:  logger,
+		auth:    auth,
+		httpf:   httpf,
+
+		connection: connection,
+	}
+}
+
+func (o *oAuth1Connection) RecordCancelSessionAfterAuth(ctx context.Context, shouldCancel bool) error {
+	if shouldCancel == o.state.CancelSessionAfterAuth {
+		return nil
+	}
+
+	o.state.CancelSessionAfterAuth = shouldCancel
+	ttl := o.state.ExpiresAt.Sub(apctx.GetClock(ctx).Now())
+
+	if err := writeStateToRedis(ctx, o.r, o.encrypt, o.state, ttl); err != nil {
+		return fmt.Errorf("failed to set state in redis for session status for connection %s: %w", o.connection.GetId(), err)
+	}
+
+	return nil
+}
+
+func (o *oAuth1Connection) CancelSessionAfterAuth() bool {
+	return o.state.CancelSessionAfterAuth
+}
+
+// renderMustache renders a mustache template string using the connection's mustache context.
+// If the string contains no mustache syntax, it is returned unchanged without fetching the context.
+func (o *oAuth1Connection) renderMustache(ctx context.Context, template string) (string, error) {
+	if !aptmpl.ContainsMustache(template) {
+		return template, nil
+	}
+
+	data, err := o.connection.GetMustacheContext(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return aptmpl.RenderMustache(template, data)
+}
@@ -0,0 +1,71 @@
+package oauth1
+
+import (
+	"net/url"
+	"strings"
+)
+
+const defaultOAuthReturnPath = "/connections"
+
+// safeReturnToUrl only honors return URLs on the public service's origin so
+// the callback can't be used as an open redirect. Anything else falls back to
+// the connections page.
+func (o *oAuth1Connection) safeReturnToUrl(raw string) string {
+	fallback := o.defaultReturnToUrl()
+
+	returnURL, err := url.Parse(raw)
+	if err != nil || returnURL.Scheme == "" || returnURL.Host == "" {
+		return fallback
+	}
+
+	if returnURL.User != nil || !isAllowedReturnScheme(returnURL.Scheme) {
+		return fallback
+	}
+
+	publicURL, err := url.Parse(o.cfg.GetRoot().Public.GetBaseUrl())
+	if err != nil {
+		return fallback
+	}
+
+	if !strings.EqualFold(publicURL.Scheme, returnURL.Scheme) || !strings.EqualFold(publicURL.Host, returnURL.Host) {
+		return fallback
+	}
+
+	return returnURL.String()
+}
+
+func (o *oAuth1Connection) defaultReturnToUrl() string {
+	raw := o.cfg.GetRoot().Public.GetBaseUrl()
+	publicURL, err := url.Parse(raw)
+	if err != nil {
+		return raw
+	}
+
+	publicURL.Path = defaultOAuthReturnPath
+	publicURL.RawQuery = ""
+	publicURL.Fragment = ""
+	return publicURL.String()
+}
+
+func isAllowedReturnScheme(scheme string) bool {
+	switch strings.ToLower(scheme) {
+	case "http", "https":
+		return true
+	default:
+		return false
+	}
+}
+
+// appendSetupPendingToReturnUrl augments the return URL with query params that signal the UI
+// to poll for setup-step advancement. Falls back to the raw URL if parsing fails.
+func (o *oAuth1Connection) appendSetupPendingToReturnUrl(raw string) string {
+	returnUrl, err := url.Parse(raw)
+	if err != nil {
+		return raw
+	}
+	q := returnUrl.Query()
+	q.Set("setup", "pending")
+	q.Set("connectionId", string(o.connection.GetId()))
+	returnUrl.RawQuery = q.Encode()
+	return returnUrl.String()
+}
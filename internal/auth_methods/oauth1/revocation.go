@@ -0,0 +1,44 @@
+package oauth1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/rmorlok/authproxy/internal/database"
+)
+
+// SupportsRevoke reports whether the connector declares a revocation
+// endpoint — true means Revoke will invalidate the token at the provider;
+// false means Revoke is a no-op for this connection.
+func (o *oAuth1Connection) SupportsRevoke() bool {
+	return o.auth != nil && o.auth.Revocation != nil && o.auth.Revocation.Endpoint != ""
+}
+
+// Revoke invalidates the connection's access token with a signed POST to the
+// provider's revocation endpoint, then deletes the local token. No-op when
+// SupportsRevoke is false or the connection has no token.
+func (o *oAuth1Connection) Revoke(ctx context.Context) error {
+	if !o.SupportsRevoke() {
+		return nil
+	}
+
+	token, err := o.loadToken(ctx)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	revocationEndpoint, err := o.renderMustache(ctx, o.auth.Revocation.Endpoint)
+	if err != nil {
+		return fmt.Errorf("failed to render revocation endpoint template: %w", err)
+	}
+
+	if _, err := o.postSigned(ctx, revocationEndpoint, token.Token, token.TokenSecret, nil); err != nil {
+		return fmt.Errorf("failed to revoke oauth1 token: %w", err)
+	}
+
+	return o.db.DeleteAllApiKeyCredentialsForConnection(ctx, o.connection.GetId())
+}
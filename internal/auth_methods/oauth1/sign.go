@@ -0,0 +1,49 @@
+package oauth1
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+
+	"github.com/rmorlok/authproxy/internal/auth_methods"
+)
+
+// SignRequest sets the OAuth 1.0a Authorization header on req, signed with
+// the consumer credentials and the connection's access token. Form-encoded
+// bodies are part of the signature base string (RFC 5849 §3.4.1.3.1); a
+// streamed form body can't be read before sending, so it is signed without
+// its parameters and strict providers will reject it.
+func (o *oAuth1Connection) SignRequest(ctx context.Context, req *http.Request, payload auth_methods.SigningPayload) error {
+	token, err := o.loadToken(ctx)
+	if err != nil {
+		return err
+	}
+
+	s, err := newSigner(ctx, o.auth)
+	if err != nil {
+		return err
+	}
+
+	var form url.Values
+	if !payload.Unsigned && len(payload.Body) > 0 && isFormEncoded(req.Header.Get("Content-Type")) {
+		form, err = url.ParseQuery(string(payload.Body))
+		if err != nil {
+			return fmt.Errorf("failed to parse form body for oauth1 signature: %w", err)
+		}
+	}
+
+	authHeader, err := s.authorizationHeader(ctx, req.Method, req.URL, form, token.Token, token.TokenSecret, nil)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Authorization", authHeader)
+	return nil
+}
+
+func isFormEncoded(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && mediaType == "application/x-www-form-urlencoded"
+}
@@ -0,0 +1,254 @@
+package oauth1
+
+import (
+	"context"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rmorlok/authproxy/internal/apctx"
+	cschema "github.com/rmorlok/authproxy/internal/schema/resources/connectors"
+)
+
+// signer holds the resolved consumer credentials for a connector and produces
+// RFC 5849 Authorization headers. Token credentials are passed per call since
+// they differ between the request-token, access-token and resource requests.
+type signer struct {
+	method         cschema.OAuth1SignatureMethod
+	consumerKey    string
+	consumerSecret string
+	privateKey     *rsa.PrivateKey
+	realm          string
+}
+
+func newSigner(ctx context.Context, auth *cschema.AuthOAuth1) (*signer, error) {
+	s := &signer{
+		method: auth.GetSignatureMethodOrDefault(),
+		realm:  auth.Realm,
+	}
+
+	if auth.ConsumerKey == nil || !auth.ConsumerKey.HasValue(ctx) {
+		return nil, errors.New("oauth1 consumer key does not have a value")
+	}
+	consumerKey, err := auth.ConsumerKey.GetValue(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get oauth1 consumer key: %w", err)
+	}
+	s.consumerKey = consumerKey
+
+	if auth.ConsumerSecret != nil && auth.ConsumerSecret.HasValue(ctx) {
+		secret, err := auth.ConsumerSecret.GetValue(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get oauth1 consumer secret: %w", err)
+		}
+		s.consumerSecret = secret
+	}
+
+	if s.method == cschema.OAuth1SignatureRsaSha1 {
+		if auth.PrivateKey == nil || !auth.PrivateKey.HasValue(ctx) {
+			return nil, errors.New("oauth1 private key does not have a value")
+		}
+		pemData, err := auth.PrivateKey.GetValue(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get oauth1 private key: %w", err)
+		}
+		key, err := parseRsaPrivateKey([]byte(pemData))
+		if err != nil {
+			return nil, err
+		}
+		s.privateKey = key
+	}
+
+	return s, nil
+}
+
+func parseRsaPrivateKey(pemData []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(pemData)
+	if block == nil {
+		return nil, errors.New("oauth1 private key is not PEM encoded")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse oauth1 private key: %w", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("oauth1 private key is not an RSA key")
+	}
+	return key, nil
+}
+
+// authorizationHeader builds the `Authorization: OAuth ...` header value for
+// a request (RFC 5849 §3.5.1). form holds application/x-www-form-urlencoded
+// body parameters, which are part of the signature base string; it may be
+// nil. extra carries protocol parameters specific to a flow step
+// (oauth_callback, oauth_verifier).
+func (s *signer) authorizationHeader(
+	ctx context.Context,
+	method string,
+	u *url.URL,
+	form url.Values,
+	token string,
+	tokenSecret string,
+	extra map[string]string,
+) (string, error) {
+	nonce, err := generateNonce()
+	if err != nil {
+		return "", err
+	}
+
+	oauthParams := map[string]string{
+		"oauth_consumer_key":     s.consumerKey,
+		"oauth_signature_method": string(s.method),
+		"oauth_timestamp":        strconv.FormatInt(apctx.GetClock(ctx).Now().Unix(), 10),
+		"oauth_nonce":            nonce,
+		"oauth_version":          "1.0",
+	}
+	if token != "" {
+		oauthParams["oauth_token"] = token
+	}
+	for k, v := range extra {
+		oauthParams[k] = v
+	}
+
+	signature, err := s.sign(signatureBaseString(method, u, oauthParams, form), tokenSecret)
+	if err != nil {
+		return "", err
+	}
+	oauthParams["oauth_signature"] = signature
+
+	keys := make([]string, 0, len(oauthParams))
+	for k := range oauthParams {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys)+1)
+	if s.realm != "" {
+		parts = append(parts, fmt.Sprintf(`realm="%s"`, percentEncode(s.realm)))
+	}
+	for _, k := range keys {
+		parts = append(parts, fmt.Sprintf(`%s="%s"`, percentEncode(k), percentEncode(oauthParams[k])))
+	}
+	return "OAuth " + strings.Join(parts, ", "), nil
+}
+
+// sign computes oauth_signature over the base string (RFC 5849 §3.4).
+func (s *signer) sign(baseString, tokenSecret string) (string, error) {
+	key := percentEncode(s.consumerSecret) + "&" + percentEncode(tokenSecret)
+
+	switch s.method {
+	case cschema.OAuth1SignatureHmacSha1:
+		mac := hmac.New(sha1.New, []byte(key))
+		mac.Write([]byte(baseString))
+		return base64.StdEncoding.EncodeToString(mac.Sum(nil)), nil
+	case cschema.OAuth1SignatureRsaSha1:
+		if s.privateKey == nil {
+			return "", errors.New("oauth1 RSA-SHA1 signing requires a private key")
+		}
+		digest := sha1.Sum([]byte(baseString))
+		sig, err := rsa.SignPKCS1v15(rand.Reader, s.privateKey, crypto.SHA1, digest[:])
+		if err != nil {
+			return "", fmt.Errorf("failed to sign oauth1 request: %w", err)
+		}
+		return base64.StdEncoding.EncodeToString(sig), nil
+	case cschema.OAuth1SignaturePlaintext:
+		return key, nil
+	default:
+		return "", fmt.Errorf("unsupported oauth1 signature method %q", s.method)
+	}
+}
+
+// signatureBaseString builds the RFC 5849 §3.4.1 base string from the
+// method, the base string URI and the normalized request parameters (query,
+// oauth protocol parameters and form body parameters).
+func signatureBaseString(method string, u *url.URL, oauthParams map[string]string, form url.Values) string {
+	type pair struct{ k, v string }
+	var pairs []pair
+	for k, vs := range u.Query() {
+		for _, v := range vs {
+			pairs = append(pairs, pair{percentEncode(k), percentEncode(v)})
+		}
+	}
+	for k, vs := range form {
+		for _, v := range vs {
+			pairs = append(pairs, pair{percentEncode(k), percentEncode(v)})
+		}
+	}
+	for k, v := range oauthParams {
+		if k == "oauth_signature" || k == "realm" {
+			continue
+		}
+		pairs = append(pairs, pair{percentEncode(k), percentEncode(v)})
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].k != pairs[j].k {
+			return pairs[i].k < pairs[j].k
+		}
+		return pairs[i].v < pairs[j].v
+	})
+
+	normalized := make([]string, len(pairs))
+	for i, p := range pairs {
+		normalized[i] = p.k + "=" + p.v
+	}
+
+	return strings.ToUpper(method) + "&" +
+		percentEncode(baseStringUri(u)) + "&" +
+		percentEncode(strings.Join(normalized, "&"))
+}
+
+// baseStringUri is the RFC 5849 §3.4.1.2 base string URI: lower-case scheme
+// and host, the port only when non-default, and the path without query or
+// fragment.
+func baseStringUri(u *url.URL) string {
+	scheme := strings.ToLower(u.Scheme)
+	host := strings.ToLower(u.Hostname())
+	if port := u.Port(); port != "" && !(scheme == "http" && port == "80") && !(scheme == "https" && port == "443") {
+		host = host + ":" + port
+	}
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	return scheme + "://" + host + path
+}
+
+// percentEncode implements the RFC 5849 §3.6 encoding: every byte other than
+// the RFC 3986 unreserved characters is percent-encoded with upper-case hex.
+func percentEncode(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') ||
+			c == '-' || c == '.' || c == '_' || c == '~' {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
+
+func generateNonce() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate oauth1 nonce: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,170 @@
+package oauth1
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rmorlok/authproxy/internal/apctx"
+	"github.com/rmorlok/authproxy/internal/schema/common"
+	cschema "github.com/rmorlok/authproxy/internal/schema/resources/connectors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// parseAuthorizationHeader splits an `OAuth k="v", ...` header into its
+// decoded parameters.
+func parseAuthorizationHeader(t *testing.T, header string) map[string]string {
+	t.Helper()
+	require.True(t, strings.HasPrefix(header, "OAuth "), header)
+	params := map[string]string{}
+	for _, part := range strings.Split(strings.TrimPrefix(header, "OAuth "), ", ") {
+		k, v, ok := strings.Cut(part, "=")
+		require.True(t, ok, part)
+		v, err := url.PathUnescape(strings.Trim(v, `"`))
+		require.NoError(t, err)
+		params[k] = v
+	}
+	return params
+}
+
+// oauthParamsFromHeader returns the header parameters that take part in the
+// signature base string.
+func oauthParamsFromHeader(params map[string]string) map[string]string {
+	out := map[string]string{}
+	for k, v := range params {
+		if k != "oauth_signature" && k != "realm" {
+			out[k] = v
+		}
+	}
+	return out
+}
+
+// TestSignature_RFC5849Example checks the HMAC-SHA1 example from RFC 5849
+// §1.2 (with the token credentials from the same section).
+func TestSignature_RFC5849Example(t *testing.T) {
+	u, err := url.Parse("http://photos.example.net/photos?file=vacation.jpg&size=original")
+	require.NoError(t, err)
+
+	base := signatureBaseString("GET", u, map[string]string{
+		"oauth_consumer_key":     "dpf43f3p2l4k3l03",
+		"oauth_token":            "nnch734d00sl2jdk",
+		"oauth_signature_method": "HMAC-SHA1",
+		"oauth_timestamp":        "1191242096",
+		"oauth_nonce":            "kllo9940pd9333jh",
+		"oauth_version":          "1.0",
+	}, nil)
+	assert.Equal(t,
+		"GET&http%3A%2F%2Fphotos.example.net%2Fphotos&file%3Dvacation.jpg%26oauth_consumer_key%3Ddpf43f3p2l4k3l03%26oauth_nonce%3Dkllo9940pd9333jh%26oauth_signature_method%3DHMAC-SHA1%26oauth_timestamp%3D1191242096%26oauth_token%3Dnnch734d00sl2jdk%26oauth_version%3D1.0%26size%3Doriginal",
+		base,
+	)
+
+	s := &signer{method: cschema.OAuth1SignatureHmacSha1, consumerKey: "dpf43f3p2l4k3l03", consumerSecret: "kd94hf93k423kf44"}
+	sig, err := s.sign(base, "pfkkdhi9sl3r4s00")
+	require.NoError(t, err)
+	assert.Equal(t, "tR3+Ty81lMeYAr/Fid0kMTYa/WM=", sig)
+}
+
+func TestSignatureBaseString_FormAndDuplicateParams(t *testing.T) {
+	// RFC 5849 §3.4.1.3.2: parameters are sorted by encoded name, then value,
+	// and form body parameters are included alongside query parameters.
+	u, err := url.Parse("HTTPS://Example.COM:443/request?b5=%3D%253D&a3=a&c%40=&a2=r%20b")
+	require.NoError(t, err)
+
+	base := signatureBaseString("post", u, map[string]string{
+		"oauth_consumer_key":     "9djdj82h48djs9d2",
+		"oauth_token":            "kkk9d7dh3k39sjv7",
+		"oauth_signature_method": "HMAC-SHA1",
+		"oauth_timestamp":        "137131201",
+		"oauth_nonce":            "7d8f3e4a",
+		"oauth_signature":        "ignored",
+	}, url.Values{"c2": {""}, "a3": {"2 q"}})
+
+	assert.Equal(t,
+		"POST&https%3A%2F%2Fexample.com%2Frequest&a2%3Dr%2520b%26a3%3D2%2520q%26a3%3Da%26b5%3D%253D%25253D%26c%2540%3D%26c2%3D%26oauth_consumer_key%3D9djdj82h48djs9d2%26oauth_nonce%3D7d8f3e4a%26oauth_signature_method%3DHMAC-SHA1%26oauth_timestamp%3D137131201%26oauth_token%3Dkkk9d7dh3k39sjv7",
+		base,
+	)
+}
+
+func TestBaseStringUri(t *testing.T) {
+	for raw, want := range map[string]string{
+		"HTTP://EXAMPLE.com:80/r%20v/X?id=123": "http://example.com/r%20v/X",
+		"https://www.example.net:8080/?q=1":    "https://www.example.net:8080/",
+		"https://example.com":                  "https://example.com/",
+	} {
+		u, err := url.Parse(raw)
+		require.NoError(t, err)
+		assert.Equal(t, want, baseStringUri(u), raw)
+	}
+}
+
+func TestPercentEncode(t *testing.T) {
+	assert.Equal(t, "Ladies%20%2B%20Gentlemen", percentEncode("Ladies + Gentlemen"))
+	assert.Equal(t, "An%20encoded%20string%21", percentEncode("An encoded string!"))
+	assert.Equal(t, "Dogs%2C%20Cats%20%26%20Mice", percentEncode("Dogs, Cats & Mice"))
+	assert.Equal(t, "%E2%98%83", percentEncode("☃"))
+	assert.Equal(t, "-._~", percentEncode("-._~"))
+}
+
+func TestSign_Plaintext(t *testing.T) {
+	s := &signer{method: cschema.OAuth1SignaturePlaintext, consumerSecret: "djr9rjt0jd78jf88"}
+	sig, err := s.sign("ignored", "jjd99$tj88uiths3")
+	require.NoError(t, err)
+	assert.Equal(t, "djr9rjt0jd78jf88&jjd99%24tj88uiths3", sig)
+}
+
+func TestAuthorizationHeader_RsaSha1(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	pemKey := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+
+	ctx := apctx.WithFixedClock(context.Background(), time.Unix(1700000000, 0))
+	s, err := newSigner(ctx, &cschema.AuthOAuth1{
+		Type:            cschema.AuthTypeOAuth1,
+		SignatureMethod: cschema.OAuth1SignatureRsaSha1,
+		ConsumerKey:     common.NewStringValueDirect("consumer"),
+		PrivateKey:      common.NewStringValueDirect(string(pemKey)),
+		Realm:           "Example",
+	})
+	require.NoError(t, err)
+
+	u, err := url.Parse("https://api.example.com/items?limit=10")
+	require.NoError(t, err)
+	header, err := s.authorizationHeader(ctx, "GET", u, nil, "token", "", nil)
+	require.NoError(t, err)
+
+	params := parseAuthorizationHeader(t, header)
+	assert.Equal(t, "Example", params["realm"])
+	assert.Equal(t, "RSA-SHA1", params["oauth_signature_method"])
+	assert.Equal(t, "1700000000", params["oauth_timestamp"])
+	assert.Equal(t, "token", params["oauth_token"])
+
+	sig, err := base64.StdEncoding.DecodeString(params["oauth_signature"])
+	require.NoError(t, err)
+	digest := sha1.Sum([]byte(signatureBaseString("GET", u, oauthParamsFromHeader(params), nil)))
+	assert.NoError(t, rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA1, digest[:], sig))
+}
+
+func TestNewSigner_Errors(t *testing.T) {
+	ctx := context.Background()
+
+	_, err := newSigner(ctx, &cschema.AuthOAuth1{Type: cschema.AuthTypeOAuth1})
+	assert.ErrorContains(t, err, "consumer key")
+
+	_, err = newSigner(ctx, &cschema.AuthOAuth1{
+		Type:            cschema.AuthTypeOAuth1,
+		SignatureMethod: cschema.OAuth1SignatureRsaSha1,
+		ConsumerKey:     common.NewStringValueDirect("consumer"),
+		PrivateKey:      common.NewStringValueDirect("not a pem"),
+	})
+	assert.ErrorContains(t, err, "not PEM encoded")
+}
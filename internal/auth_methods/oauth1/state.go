@@ -0,0 +1,197 @@
+package oauth1
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	apauthcore "github.com/rmorlok/authproxy/internal/apauth/core"
+	"github.com/rmorlok/authproxy/internal/apctx"
+	"github.com/rmorlok/authproxy/internal/apid"
+	"github.com/rmorlok/authproxy/internal/apredis"
+	"github.com/rmorlok/authproxy/internal/config"
+	coreIface "github.com/rmorlok/authproxy/internal/core/iface"
+	"github.com/rmorlok/authproxy/internal/database"
+	"github.com/rmorlok/authproxy/internal/encfield"
+	"github.com/rmorlok/authproxy/internal/encrypt"
+	"github.com/rmorlok/authproxy/internal/httpf"
+	cschema "github.com/rmorlok/authproxy/internal/schema/resources/connectors"
+)
+
+var errStateNotFound = errors.New("oauth1 state not found")
+
+// state is the record that spans the three legs of the flow. It is written
+// when the setup step renders, picks up the temporary request token when the
+// user is redirected to the provider, and is consumed by the callback.
+type state struct {
+	Id                     apid.ID   `json:"id"`
+	ActorNamespace         string    `json:"actorNamespace,omitempty"`
+	ConnectionNamespace    string    `json:"connectionNamespace,omitempty"`
+	ActorId                apid.ID   `json:"actorId"`
+	ConnectorId            apid.ID   `json:"connectorId"`
+	ConnectorVersion       uint64    `json:"connectorVersion"`
+	ConnectionId           apid.ID   `json:"connectionId"`
+	ReturnToUrl            string    `json:"returnToUrl"`
+	CancelSessionAfterAuth bool      `json:"cancelSessionAfterAuth"`
+	ExpiresAt              time.Time `json:"expiresAt"`
+
+	// RequestToken and RequestTokenSecret are the temporary credentials from
+	// the request-token endpoint (RFC 5849 §2.1). Empty until the user is
+	// redirected to the provider. The secret is needed to sign the
+	// access-token request and never leaves the proxy.
+	RequestToken       string `json:"requestToken,omitempty"`
+	RequestTokenSecret string `json:"requestTokenSecret,omitempty"`
+}
+
+func (s *state) IsValid() bool {
+	return s.ActorNamespace != "" && s.ConnectionNamespace != "" && s.ActorId != apid.Nil && s.ConnectorId != apid.Nil && s.ConnectionId != apid.Nil && !s.ExpiresAt.IsZero()
+}
+
+// writeStateToRedis encrypts the state with the global key (AES-GCM AEAD) and
+// stores it under the state's Redis key, the same envelope the OAuth2 flow
+// uses. The request token secret is part of the payload, so the state must
+// never be stored in the clear.
+func writeStateToRedis(ctx context.Context, r apredis.Client, e encrypt.E, s *state, ttl time.Duration) error {
+	plaintext, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("failed to marshal oauth1 state: %w", err)
+	}
+	ef, err := e.EncryptGlobal(ctx, plaintext)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt oauth1 state: %w", err)
+	}
+	result := r.Set(ctx, getStateRedisKey(s.Id), ef.ToInlineString(), ttl)
+	if result.Err() != nil {
+		return fmt.Errorf("failed to set state in redis for state %s: %w", s.Id, result.Err())
+	}
+	return nil
+}
+
+func readStateFromRedis(ctx context.Context, r apredis.Client, e encrypt.E, stateId apid.ID) (*state, error) {
+	raw, err := r.Get(ctx, getStateRedisKey(stateId)).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, fmt.Errorf("%w: id %s", errStateNotFound, stateId.String())
+		}
+		return nil, fmt.Errorf("failed to get oauth1 state from redis for id %s: %w", stateId.String(), err)
+	}
+	ef, err := encfield.ParseInlineString(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse oauth1 state envelope for id %s: %w", stateId.String(), err)
+	}
+	plaintext, err := e.Decrypt(ctx, ef)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt oauth1 state for id %s: %w", stateId.String(), err)
+	}
+	var s state
+	if err := json.Unmarshal(plaintext, &s); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal oauth1 state for id %s: %w", stateId.String(), err)
+	}
+	return &s, nil
+}
+
+func getStateRedisKey(u apid.ID) string {
+	return fmt.Sprintf("oauth1:state:%s", u.String())
+}
+
+func deleteStateFromRedis(ctx context.Context, r apredis.Client, stateId apid.ID) error {
+	result := r.Del(ctx, getStateRedisKey(stateId))
+	if result.Err() != nil {
+		return fmt.Errorf("failed to delete oauth1 state from redis for id %s: %w", stateId.String(), result.Err())
+	}
+	return nil
+}
+
+func (o *oAuth1Connection) saveStateToRedis(ctx context.Context, actor apauthcore.IActorData, stateId apid.ID, returnToUrl string) error {
+	ttl := o.cfg.GetRoot().Oauth.GetRoundTripTtlOrDefault()
+	s := &state{
+		Id:                  stateId,
+		ActorNamespace:      actor.GetNamespace(),
+		ConnectionNamespace: o.connection.GetNamespace(),
+		ActorId:             actor.GetId(),
+		ConnectorId:         o.connection.GetConnector().GetId(),
+		ConnectorVersion:    o.connection.GetConnector().GetVersion(),
+		ConnectionId:        o.connection.GetId(),
+		ExpiresAt:           apctx.GetClock(ctx).Now().Add(ttl),
+		ReturnToUrl:         returnToUrl,
+	}
+
+	if err := writeStateToRedis(ctx, o.r, o.encrypt, s, ttl); err != nil {
+		return fmt.Errorf("failed to set state in redis for connection %s: %w", o.connection.GetId(), err)
+	}
+
+	o.state = s
+	return nil
+}
+
+// getOAuth1State loads the state and checks that it belongs to the inbound
+// actor and to an OAuth1 connection in the namespace it was issued for. Every
+// rejection is logged as a warning with the same message so operators can
+// correlate probes against the flow.
+func getOAuth1State(
+	ctx context.Context,
+	cfg config.C,
+	db database.DB,
+	r apredis.Client,
+	core coreIface.C,
+	httpf httpf.F,
+	encrypt encrypt.E,
+	logger *slog.Logger,
+	actor apauthcore.IActorData,
+	stateId apid.ID,
+) (OAuth1Connection, error) {
+	reject := func(err error) (OAuth1Connection, error) {
+		logger.WarnContext(ctx, "oauth1 state rejected",
+			"state_id", stateId,
+			"actor_id", actor.GetId(),
+			"error", err,
+		)
+		return nil, err
+	}
+
+	s, err := readStateFromRedis(ctx, r, encrypt, stateId)
+	if err != nil {
+		return reject(err)
+	}
+
+	if !s.IsValid() {
+		return reject(fmt.Errorf("state %s is invalid", stateId.String()))
+	}
+
+	if s.ExpiresAt.Before(apctx.GetClock(ctx).Now()) {
+		return reject(fmt.Errorf("state %s has expired", stateId.String()))
+	}
+
+	if s.ActorId != actor.GetId() {
+		return reject(fmt.Errorf("actor id %s does not match state actor id %s", actor.GetId(), s.ActorId))
+	}
+
+	if s.ActorNamespace != actor.GetNamespace() {
+		return reject(fmt.Errorf("actor namespace %q does not match state actor namespace %q", actor.GetNamespace(), s.ActorNamespace))
+	}
+
+	connection, err := core.GetConnection(ctx, s.ConnectionId)
+	if err != nil {
+		if errors.Is(err, coreIface.ErrNotFound) {
+			return reject(fmt.Errorf("connection %s not found for state %s", s.ConnectionId.String(), stateId.String()))
+		}
+		return nil, fmt.Errorf("failed to get connection %s for state %s: %w", s.ConnectionId.String(), stateId.String(), err)
+	}
+
+	if s.ConnectionNamespace != connection.GetNamespace() {
+		return reject(fmt.Errorf("connection namespace %q does not match state connection namespace %q", connection.GetNamespace(), s.ConnectionNamespace))
+	}
+
+	if connection.GetConnector().GetDefinition().Auth.GetType() != cschema.AuthTypeOAuth1 {
+		return reject(fmt.Errorf("connector %s is not an oauth1 connector", s.ConnectorId))
+	}
+
+	o := newOAuth1(cfg, db, r, encrypt, logger, httpf, connection)
+	o.state = s
+
+	return o, nil
+}
@@ -0,0 +1,54 @@
+package oauth1
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/rmorlok/authproxy/internal/database"
+)
+
+// persistToken encrypts the access token and token secret as a single JSON
+// blob and stores it in connection_credentials, soft-deleting any prior
+// token for the connection. Only called from the callback, so o.state is set.
+func (o *oAuth1Connection) persistToken(ctx context.Context, token database.OAuth1TokenPlaintext) error {
+	plaintext, err := json.Marshal(token)
+	if err != nil {
+		return fmt.Errorf("failed to marshal oauth1 token: %w", err)
+	}
+
+	encrypted, err := o.encrypt.EncryptStringForNamespace(ctx, o.connection.GetNamespace(), string(plaintext))
+	if err != nil {
+		return fmt.Errorf("failed to encrypt oauth1 token: %w", err)
+	}
+
+	// The callback has already checked that the inbound actor is the one
+	// recorded on the state.
+	actorId := o.state.ActorId
+	if _, err := o.db.InsertApiKeyCredential(ctx, o.connection.GetId(), encrypted, nil, &actorId); err != nil {
+		return fmt.Errorf("failed to store oauth1 token: %w", err)
+	}
+
+	return nil
+}
+
+// loadToken returns the connection's active access token and token secret.
+// The underlying database.ErrNotFound is wrapped so callers can detect a
+// connection that has not completed the flow.
+func (o *oAuth1Connection) loadToken(ctx context.Context) (database.OAuth1TokenPlaintext, error) {
+	cred, err := o.db.GetActiveApiKeyCredential(ctx, o.connection.GetId())
+	if err != nil {
+		return database.OAuth1TokenPlaintext{}, fmt.Errorf("failed to load oauth1 token: %w", err)
+	}
+
+	decrypted, err := o.encrypt.DecryptString(ctx, cred.EncryptedCredentials)
+	if err != nil {
+		return database.OAuth1TokenPlaintext{}, fmt.Errorf("failed to decrypt oauth1 token: %w", err)
+	}
+
+	var token database.OAuth1TokenPlaintext
+	if err := json.Unmarshal([]byte(decrypted), &token); err != nil {
+		return database.OAuth1TokenPlaintext{}, fmt.Errorf("failed to unmarshal oauth1 token: %w", err)
+	}
+	return token, nil
+}
@@ -111,6 +111,10 @@ func (o *oAuth2Connection) GenerateAuthUrl(ctx context.Context, actor IActorData
 		query.Set("code_challenge_method", string(method))
 	}
 
+	if o.state.OidcNonce != "" {
+		query.Set("nonce", o.state.OidcNonce)
+	}
+
 	for k, v := range o.auth.Authorization.QueryOverrides {
 		rendered, err := o.renderMustache(ctx, v)
 		if err != nil {
@@ -8,6 +8,7 @@ import (
 	"net/url"
 	"time"
 
+	"github.com/rmorlok/authproxy/internal/apid"
 	"github.com/rmorlok/authproxy/internal/httpf"
 	"github.com/rmorlok/authproxy/internal/schema/config"
 	"github.com/rmorlok/authproxy/internal/util/retry"
@@ -213,6 +214,26 @@ func (o *oAuth2Connection) exchangeCodeAndAdvanceInner(ctx context.Context, quer
 		return "", err
 	}
 
+	var superseded []apid.ID
+	if o.auth.Oidc != nil {
+		identity, err := o.verifyOidcIdentity(ctx, resp)
+		if err != nil {
+			err = fmt.Errorf("failed to verify oidc identity: %w", err)
+			o.emitAndRecordExchangeFailure(ctx, tokenExchangeInvalidIdToken, o.tokenExchangeAttrsFromConn(err))
+			return "", err
+		}
+
+		superseded, err = o.recordOidcIdentity(ctx, identity)
+		if err != nil {
+			category := tokenExchangeInternalError
+			if errors.Is(err, errOidcDuplicateSubject) {
+				category = tokenExchangeDuplicateSubject
+			}
+			o.emitAndRecordExchangeFailure(ctx, category, o.tokenExchangeAttrsFromConn(err))
+			return "", err
+		}
+	}
+
 	_, err = o.createDbTokenFromResponse(ctx, resp, nil)
 	if err != nil {
 		err = fmt.Errorf("failed to create db token from response: %w", err)
@@ -227,6 +248,7 @@ func (o *oAuth2Connection) exchangeCodeAndAdvanceInner(ctx context.Context, quer
 		return "", err
 	}
 
+	o.supersedeOidcConnections(ctx, superseded)
 	o.tel.recordTokenExchangeSuccess(ctx, o.connectionLabelsForTelemetry())
 
 	returnToUrl := o.safeReturnToUrl(o.state.ReturnToUrl)
@@ -245,23 +267,32 @@ func (o *oAuth2Connection) ExchangeClientCredentials(ctx context.Context) error
 		"token_exchange",
 		o.connectorIDForTelemetry(),
 		func(ctx context.Context) error {
-			return o.exchangeClientCredentialsInner(ctx)
+			return o.exchangeServiceGrantInner(ctx, "client credentials")
 		})
 }
 
-func (o *oAuth2Connection) exchangeClientCredentialsInner(ctx context.Context) error {
+// ExchangeJwtBearer performs RFC 7523 §2.1's synchronous token endpoint
+// exchange: a signed assertion minted from the connection's uploaded key is
+// the grant. Like client_credentials there is no redirect or callback.
+func (o *oAuth2Connection) ExchangeJwtBearer(ctx context.Context) error {
+	return o.tel.withSpan(
+		ctx,
+		"token_exchange",
+		o.connectorIDForTelemetry(),
+		func(ctx context.Context) error {
+			return o.exchangeServiceGrantInner(ctx, "jwt-bearer")
+		})
+}
+
+// exchangeServiceGrantInner runs the token exchange for the grants that need
+// no user interaction. grantLabel only appears in error messages.
+func (o *oAuth2Connection) exchangeServiceGrantInner(ctx context.Context, grantLabel string) error {
 	c := o.httpf.
 		ForRequestType(httpf.RequestTypeOAuth).
 		ForConnection(o.connection).
 		New().
 		UseContext(ctx)
 
-	clientId, clientSecret, err := o.resolveClientCredentials(ctx)
-	if err != nil {
-		o.emitAndRecordExchangeFailure(ctx, tokenExchangeInternalError, o.tokenExchangeAttrsFromConn(err))
-		return err
-	}
-
 	tokenEndpoint, err := o.renderMustache(ctx, o.auth.Token.Endpoint)
 	if err != nil {
 		err = fmt.Errorf("failed to render token endpoint template: %w", err)
@@ -269,22 +300,13 @@ func (o *oAuth2Connection) exchangeClientCredentialsInner(ctx context.Context) e
 		return err
 	}
 
-	values := url.Values{
-		"grant_type": {"client_credentials"},
-	}
-	effectiveScopes, err := o.effectiveScopes(ctx)
+	values, err := o.serviceGrantValues(ctx, tokenEndpoint)
 	if err != nil {
-		err = fmt.Errorf("failed to resolve oauth2 scopes: %w", err)
 		o.emitAndRecordExchangeFailure(ctx, tokenExchangeInternalError, o.tokenExchangeAttrsFromConn(err))
 		return err
 	}
-	if scopeString := JoinScopes(effectiveScopes); scopeString != "" {
-		values.Set("scope", scopeString)
-	}
 
-	values, authHeader, err := applyTokenEndpointClientAuth(
-		o.auth.GetTokenEndpointAuthMethodOrDefault(), clientId, clientSecret, values,
-	)
+	values, authHeader, err := o.applyServiceGrantClientAuth(ctx, values)
 	if err != nil {
 		o.emitAndRecordExchangeFailure(ctx, tokenExchangeInternalError, o.tokenExchangeAttrsFromConn(err))
 		return err
@@ -296,7 +318,7 @@ func (o *oAuth2Connection) exchangeClientCredentialsInner(ctx context.Context) e
 
 	resp, attempts, err := o.postTokenExchangeWithRetry(ctx, c, tokenEndpoint, values, authHeader)
 	if err != nil {
-		err = fmt.Errorf("failed to post client credentials token exchange: %w", err)
+		err = fmt.Errorf("failed to post %s token exchange: %w", grantLabel, err)
 		attrs := o.tokenExchangeAttrsFromConn(err)
 		attrs.Attempts = attempts
 		o.emitAndRecordExchangeFailure(ctx, tokenExchangeNetworkError, attrs)
@@ -305,7 +327,7 @@ func (o *oAuth2Connection) exchangeClientCredentialsInner(ctx context.Context) e
 
 	if resp.StatusCode != 200 {
 		category, providerErr := classifyTokenEndpointStatus(resp.StatusCode, resp.Bytes())
-		err := fmt.Errorf("received status code %d from client credentials token exchange", resp.StatusCode)
+		err := fmt.Errorf("received status code %d from %s token exchange", resp.StatusCode, grantLabel)
 		attrs := o.tokenExchangeAttrsFromConn(err)
 		attrs.ProviderStatusCode = resp.StatusCode
 		attrs.ProviderError = providerErr
@@ -325,6 +347,33 @@ func (o *oAuth2Connection) exchangeClientCredentialsInner(ctx context.Context) e
 	return nil
 }
 
+// serviceGrantValues builds the token request form for grants without a
+// refresh token. Used both for the initial exchange and to obtain a new
+// access token when the current one nears expiry.
+func (o *oAuth2Connection) serviceGrantValues(ctx context.Context, tokenEndpoint string) (url.Values, error) {
+	values := url.Values{}
+	if o.auth.GetGrantTypeOrDefault() == config.OAuth2GrantJwtBearer {
+		assertion, err := o.buildJwtBearerAssertion(ctx, tokenEndpoint)
+		if err != nil {
+			return nil, fmt.Errorf("failed to build jwt-bearer assertion: %w", err)
+		}
+		values.Set("grant_type", string(config.OAuth2GrantJwtBearer))
+		values.Set("assertion", assertion)
+	} else {
+		values.Set("grant_type", "client_credentials")
+	}
+
+	effectiveScopes, err := o.effectiveScopes(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve oauth2 scopes: %w", err)
+	}
+	if scopeString := JoinScopes(effectiveScopes); scopeString != "" {
+		values.Set("scope", scopeString)
+	}
+
+	return values, nil
+}
+
 // postTokenExchangeWithRetry POSTs the token-exchange form to the provider's
 // token endpoint, retrying transient failures (transport errors and 5xx
 // responses) up to tokenExchangeMaxAttempts times. Returns the final
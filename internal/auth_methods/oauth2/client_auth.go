@@ -62,6 +62,25 @@ func (o *oAuth2Connection) resolveStoredClientCredentials(ctx context.Context) (
 	return plaintext.ClientId, plaintext.ClientSecret, nil
 }
 
+// applyServiceGrantClientAuth resolves the client credentials and attaches
+// them to a client_credentials or jwt-bearer token request. A jwt-bearer
+// connector without a client_id doesn't authenticate the client at all:
+// the signed assertion is the only credential (RFC 7523 §3.1).
+func (o *oAuth2Connection) applyServiceGrantClientAuth(ctx context.Context, values url.Values) (url.Values, string, error) {
+	if o.auth.GetGrantTypeOrDefault() == sconfig.OAuth2GrantJwtBearer && o.auth.ClientId == nil {
+		return values, "", nil
+	}
+
+	clientId, clientSecret, err := o.resolveClientCredentials(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return applyTokenEndpointClientAuth(
+		o.auth.GetTokenEndpointAuthMethodOrDefault(), clientId, clientSecret, values,
+	)
+}
+
 // applyTokenEndpointClientAuth attaches client credentials to the
 // token-endpoint request per the connector's configured
 // token_endpoint_auth_method (RFC 6749 §2.3.1, RFC 7591 §2).
@@ -0,0 +1,417 @@
+package oauth2
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/redis/go-redis/v9"
+	"github.com/rmorlok/authproxy/internal/apctx"
+	"github.com/rmorlok/authproxy/internal/apid"
+	coreIface "github.com/rmorlok/authproxy/internal/core/iface"
+	"github.com/rmorlok/authproxy/internal/encfield"
+	"github.com/rmorlok/authproxy/internal/httperr"
+	"github.com/rmorlok/authproxy/internal/httpf"
+	"github.com/rmorlok/authproxy/internal/schema/config"
+	"gopkg.in/h2non/gentleman.v2"
+)
+
+// deviceCodeSlowDownIncrement is how much the polling interval grows each
+// time the token endpoint answers slow_down (RFC 8628 §3.5).
+const deviceCodeSlowDownIncrement = 5 * time.Second
+
+// deviceAuthorization is the in-flight RFC 8628 device authorization for a
+// connection. Stored encrypted in Redis — the device_code is all the token
+// endpoint needs to issue tokens — until it expires or polling finishes.
+type deviceAuthorization struct {
+	// Nonce ties poll tasks to this authorization, so a task left over from
+	// an earlier attempt stops rather than polling alongside the new one.
+	Nonce                   string        `json:"nonce"`
+	DeviceCode              string        `json:"deviceCode"`
+	UserCode                string        `json:"userCode"`
+	VerificationUri         string        `json:"verificationUri"`
+	VerificationUriComplete string        `json:"verificationUriComplete,omitempty"`
+	ExpiresAt               time.Time     `json:"expiresAt"`
+	Interval                time.Duration `json:"interval"`
+}
+
+func (d *deviceAuthorization) info() coreIface.DeviceCodeInfo {
+	return coreIface.DeviceCodeInfo{
+		UserCode:                d.UserCode,
+		VerificationUri:         d.VerificationUri,
+		VerificationUriComplete: d.VerificationUriComplete,
+		ExpiresAt:               d.ExpiresAt,
+		Interval:                d.Interval,
+	}
+}
+
+// deviceAuthorizationResponse is the device authorization endpoint response
+// (RFC 8628 §3.2).
+type deviceAuthorizationResponse struct {
+	DeviceCode      string `json:"device_code"`
+	UserCode        string `json:"user_code"`
+	VerificationUri string `json:"verification_uri"`
+	// VerificationUrl is the pre-RFC spelling some providers (Google) still
+	// return instead of verification_uri.
+	VerificationUrl         string `json:"verification_url"`
+	VerificationUriComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int    `json:"expires_in"`
+	Interval                int    `json:"interval"`
+}
+
+func getDeviceAuthorizationRedisKey(connectionId apid.ID) string {
+	return fmt.Sprintf("oauth2:device:%s", connectionId.String())
+}
+
+// loadDeviceAuthorization returns the connection's pending device
+// authorization, or nil when there is none (never started, consumed, or
+// expired out of Redis).
+func (o *oAuth2Connection) loadDeviceAuthorization(ctx context.Context) (*deviceAuthorization, error) {
+	raw, err := o.r.Get(ctx, getDeviceAuthorizationRedisKey(o.connection.GetId())).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get device authorization from redis: %w", err)
+	}
+	ef, err := encfield.ParseInlineString(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse device authorization envelope: %w", err)
+	}
+	plaintext, err := o.encrypt.Decrypt(ctx, ef)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt device authorization: %w", err)
+	}
+	var da deviceAuthorization
+	if err := json.Unmarshal(plaintext, &da); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal device authorization: %w", err)
+	}
+	return &da, nil
+}
+
+// saveDeviceAuthorization stores the device authorization until it expires.
+func (o *oAuth2Connection) saveDeviceAuthorization(ctx context.Context, da *deviceAuthorization) error {
+	ttl := da.ExpiresAt.Sub(apctx.GetClock(ctx).Now())
+	if ttl <= 0 {
+		return errors.New("device authorization has already expired")
+	}
+	plaintext, err := json.Marshal(da)
+	if err != nil {
+		return fmt.Errorf("failed to marshal device authorization: %w", err)
+	}
+	ef, err := o.encrypt.EncryptGlobal(ctx, plaintext)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt device authorization: %w", err)
+	}
+	if err := o.r.Set(ctx, getDeviceAuthorizationRedisKey(o.connection.GetId()), ef.ToInlineString(), ttl).Err(); err != nil {
+		return fmt.Errorf("failed to set device authorization in redis: %w", err)
+	}
+	return nil
+}
+
+func (o *oAuth2Connection) deleteDeviceAuthorization(ctx context.Context) error {
+	if err := o.r.Del(ctx, getDeviceAuthorizationRedisKey(o.connection.GetId())).Err(); err != nil {
+		return fmt.Errorf("failed to delete device authorization from redis: %w", err)
+	}
+	return nil
+}
+
+// EnsureDeviceAuthorization returns the connection's pending device
+// authorization, starting a new one (and its background poll) when none is
+// in flight. Resuming setup calls this again, so the user code stays stable
+// for as long as it is valid.
+func (o *oAuth2Connection) EnsureDeviceAuthorization(ctx context.Context) (coreIface.DeviceCodeInfo, error) {
+	existing, err := o.loadDeviceAuthorization(ctx)
+	if err != nil {
+		return coreIface.DeviceCodeInfo{}, err
+	}
+	if existing != nil && apctx.GetClock(ctx).Now().Before(existing.ExpiresAt) {
+		return existing.info(), nil
+	}
+
+	if o.ac == nil {
+		return coreIface.DeviceCodeInfo{}, errors.New("device authorization requires a task client to poll the token endpoint")
+	}
+
+	da, err := o.requestDeviceAuthorization(ctx)
+	if err != nil {
+		return coreIface.DeviceCodeInfo{}, err
+	}
+
+	if err := o.saveDeviceAuthorization(ctx, da); err != nil {
+		return coreIface.DeviceCodeInfo{}, err
+	}
+
+	if err := o.scheduleDevicePoll(ctx, da); err != nil {
+		return coreIface.DeviceCodeInfo{}, err
+	}
+
+	return da.info(), nil
+}
+
+// requestDeviceAuthorization calls the device authorization endpoint (RFC
+// 8628 §3.1). Client authentication follows the token endpoint's method,
+// which for the usual public client is just client_id in the body.
+func (o *oAuth2Connection) requestDeviceAuthorization(ctx context.Context) (*deviceAuthorization, error) {
+	endpoint, err := o.renderMustache(ctx, o.auth.DeviceAuthorization.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render device authorization endpoint template: %w", err)
+	}
+
+	clientId, clientSecret, err := o.resolveClientCredentials(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	values := url.Values{}
+	effectiveScopes, err := o.effectiveScopes(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve oauth2 scopes: %w", err)
+	}
+	if scopeString := JoinScopes(effectiveScopes); scopeString != "" {
+		values.Set("scope", scopeString)
+	}
+
+	values, authHeader, err := applyTokenEndpointClientAuth(
+		o.auth.GetTokenEndpointAuthMethodOrDefault(), clientId, clientSecret, values,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	for k, v := range o.auth.DeviceAuthorization.FormOverrides {
+		rendered, err := o.renderMustache(ctx, v)
+		if err != nil {
+			return nil, fmt.Errorf("failed to render device authorization form override %q: %w", k, err)
+		}
+		values.Set(k, rendered)
+	}
+
+	req := o.httpf.
+		ForRequestType(httpf.RequestTypeOAuth).
+		ForConnection(o.connection).
+		New().
+		UseContext(ctx).
+		Request().
+		Method("POST").
+		URL(endpoint).
+		Type("application/x-www-form-urlencoded").
+		AddHeader("accept", "application/json")
+
+	if authHeader != "" {
+		req = req.AddHeader("Authorization", authHeader)
+	}
+
+	for k, v := range o.auth.DeviceAuthorization.QueryOverrides {
+		rendered, err := o.renderMustache(ctx, v)
+		if err != nil {
+			return nil, fmt.Errorf("failed to render device authorization query override %q: %w", k, err)
+		}
+		req = req.SetQuery(k, rendered)
+	}
+
+	resp, err := req.BodyString(values.Encode()).Send()
+	if err != nil {
+		return nil, httperr.New(http.StatusBadGateway, "device authorization request failed",
+			httperr.WithInternalErrorf("failed to post device authorization request: %w", err))
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		_, providerErr := classifyTokenEndpointStatus(resp.StatusCode, resp.Bytes())
+		return nil, httperr.New(http.StatusBadGateway, "device authorization request was rejected by the provider",
+			httperr.WithInternalErrorf("received status code %d (%q) from device authorization endpoint", resp.StatusCode, providerErr))
+	}
+
+	var parsed deviceAuthorizationResponse
+	if err := resp.JSON(&parsed); err != nil {
+		return nil, fmt.Errorf("failed to parse device authorization response: %w", err)
+	}
+	if parsed.VerificationUri == "" {
+		parsed.VerificationUri = parsed.VerificationUrl
+	}
+	if parsed.DeviceCode == "" || parsed.UserCode == "" || parsed.VerificationUri == "" || parsed.ExpiresIn <= 0 {
+		return nil, errors.New("device authorization response is missing device_code, user_code, verification_uri or expires_in")
+	}
+
+	interval := o.auth.DeviceAuthorization.GetPollIntervalOrDefault()
+	if provider := time.Duration(parsed.Interval) * time.Second; provider > interval {
+		interval = provider
+	}
+
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate device authorization nonce: %w", err)
+	}
+
+	return &deviceAuthorization{
+		Nonce:                   hex.EncodeToString(nonce),
+		DeviceCode:              parsed.DeviceCode,
+		UserCode:                parsed.UserCode,
+		VerificationUri:         parsed.VerificationUri,
+		VerificationUriComplete: parsed.VerificationUriComplete,
+		ExpiresAt:               apctx.GetClock(ctx).Now().Add(time.Duration(parsed.ExpiresIn) * time.Second),
+		Interval:                interval,
+	}, nil
+}
+
+// scheduleDevicePoll enqueues the next token endpoint poll one interval out.
+func (o *oAuth2Connection) scheduleDevicePoll(ctx context.Context, da *deviceAuthorization) error {
+	t, err := newPollDeviceTokenTask(o.connection.GetId(), da.Nonce)
+	if err != nil {
+		return fmt.Errorf("failed to create device token poll task: %w", err)
+	}
+	if _, err := o.ac.EnqueueContext(ctx, t, asynq.ProcessIn(da.Interval)); err != nil {
+		return fmt.Errorf("failed to enqueue device token poll task: %w", err)
+	}
+	return nil
+}
+
+// pollDeviceToken makes one device access token request (RFC 8628 §3.4) and
+// acts on the answer: reschedule while the user hasn't approved, store the
+// token and advance setup once they have, or fail the connection into
+// apxy:auth_failed when the code expires or is denied.
+func (o *oAuth2Connection) pollDeviceToken(ctx context.Context, nonce string) error {
+	// Setup moved on without us (aborted, retried, or reauthed through a
+	// different step); there is nothing left to poll for.
+	if step := o.connection.GetSetupStep(); step == nil || step.Id() != OAuth2DeviceCodeStepId {
+		return nil
+	}
+
+	da, err := o.loadDeviceAuthorization(ctx)
+	if err != nil {
+		return err
+	}
+	if da != nil && da.Nonce != nonce {
+		return nil
+	}
+	if da == nil || !apctx.GetClock(ctx).Now().Before(da.ExpiresAt) {
+		return o.failDeviceAuthorization(ctx, tokenExchangeExpiredToken, 0, "",
+			errors.New("device code expired before the connection was approved"))
+	}
+
+	tokenEndpoint, err := o.renderMustache(ctx, o.auth.Token.Endpoint)
+	if err != nil {
+		return o.failDeviceAuthorization(ctx, tokenExchangeInternalError, 0, "",
+			fmt.Errorf("failed to render token endpoint template: %w", err))
+	}
+
+	clientId, clientSecret, err := o.resolveClientCredentials(ctx)
+	if err != nil {
+		return o.failDeviceAuthorization(ctx, tokenExchangeInternalError, 0, "", err)
+	}
+
+	values, authHeader, err := applyTokenEndpointClientAuth(
+		o.auth.GetTokenEndpointAuthMethodOrDefault(), clientId, clientSecret, url.Values{
+			"grant_type":  {string(config.OAuth2GrantDeviceCode)},
+			"device_code": {da.DeviceCode},
+		},
+	)
+	if err != nil {
+		return o.failDeviceAuthorization(ctx, tokenExchangeInternalError, 0, "", err)
+	}
+
+	for k, v := range o.auth.Token.FormOverrides {
+		values.Set(k, v)
+	}
+
+	c := o.httpf.
+		ForRequestType(httpf.RequestTypeOAuth).
+		ForConnection(o.connection).
+		New().
+		UseContext(ctx)
+
+	resp, attempts, err := o.postTokenExchangeWithRetry(ctx, c, tokenEndpoint, values, authHeader)
+	if err != nil || resp.StatusCode >= 500 {
+		// The device code is still good; try again next interval rather than
+		// failing the connection over a provider blip.
+		o.logger.WarnContext(ctx, "oauth device token poll failed transiently; rescheduling",
+			"connection_id", o.connection.GetId(),
+			"attempts", attempts,
+		)
+		return o.scheduleDevicePoll(ctx, da)
+	}
+
+	// Some providers (GitHub) report pending/slow_down with a 200, so the
+	// error field is checked regardless of status.
+	var pollErr struct {
+		Error string `json:"error"`
+	}
+	_ = json.Unmarshal(resp.Bytes(), &pollErr)
+
+	switch {
+	case pollErr.Error == "authorization_pending":
+		return o.scheduleDevicePoll(ctx, da)
+	case pollErr.Error == "slow_down":
+		da.Interval += deviceCodeSlowDownIncrement
+		if err := o.saveDeviceAuthorization(ctx, da); err != nil {
+			return err
+		}
+		return o.scheduleDevicePoll(ctx, da)
+	case pollErr.Error == "" && resp.StatusCode == http.StatusOK:
+		return o.completeDeviceAuthorization(ctx, resp)
+	}
+
+	status := resp.StatusCode
+	if status == http.StatusOK {
+		status = http.StatusBadRequest
+	}
+	category, providerErr := classifyTokenEndpointStatus(status, resp.Bytes())
+	err = fmt.Errorf("received status code %d (%q) from device token request", resp.StatusCode, pollErr.Error)
+	if category == tokenExchangeExpiredToken {
+		err = errors.New("device code expired before the connection was approved")
+	} else if category == tokenExchangeProviderDenied {
+		err = fmt.Errorf("authorization denied by provider: %s", providerErr)
+	}
+	return o.failDeviceAuthorization(ctx, category, resp.StatusCode, providerErr, err)
+}
+
+// completeDeviceAuthorization stores the issued token and advances the
+// connection past the device-code step, to verify or configure.
+func (o *oAuth2Connection) completeDeviceAuthorization(ctx context.Context, resp *gentleman.Response) error {
+	if _, err := o.createDbTokenFromResponse(ctx, resp, nil); err != nil {
+		return o.failDeviceAuthorization(ctx, tokenExchangeMalformedResponse, resp.StatusCode, "",
+			fmt.Errorf("failed to create db token from response: %w", err))
+	}
+
+	if err := o.deleteDeviceAuthorization(ctx); err != nil {
+		return err
+	}
+
+	if _, err := o.connection.HandleCredentialsEstablished(ctx); err != nil {
+		return fmt.Errorf("failed to handle post-auth state transition: %w", err)
+	}
+
+	o.tel.recordTokenExchangeSuccess(ctx, o.connectionLabelsForTelemetry())
+	return nil
+}
+
+// failDeviceAuthorization records a terminal device flow failure: the pending
+// authorization is dropped and the connection lands in apxy:auth_failed,
+// where the user can retry for a fresh user code.
+func (o *oAuth2Connection) failDeviceAuthorization(
+	ctx context.Context,
+	category tokenExchangeCategory,
+	statusCode int,
+	providerErr string,
+	err error,
+) error {
+	attrs := o.tokenExchangeAttrsFromConn(err)
+	attrs.ProviderStatusCode = statusCode
+	attrs.ProviderError = providerErr
+	o.emitAndRecordExchangeFailure(ctx, category, attrs)
+
+	if delErr := o.deleteDeviceAuthorization(ctx); delErr != nil {
+		return delErr
+	}
+	if recordErr := o.connection.HandleAuthFailed(ctx, err); recordErr != nil {
+		return fmt.Errorf("failed to record auth failure (%v) after: %w", recordErr, err)
+	}
+	return nil
+}
@@ -0,0 +1,349 @@
+package oauth2
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/hibiken/asynq"
+	mockAsynq "github.com/rmorlok/authproxy/internal/apasynq/mock"
+	"github.com/rmorlok/authproxy/internal/apctx"
+	"github.com/rmorlok/authproxy/internal/apid"
+	"github.com/rmorlok/authproxy/internal/aplog"
+	"github.com/rmorlok/authproxy/internal/apredis"
+	mockCore "github.com/rmorlok/authproxy/internal/core/mock"
+	"github.com/rmorlok/authproxy/internal/database"
+	mockDb "github.com/rmorlok/authproxy/internal/database/mock"
+	"github.com/rmorlok/authproxy/internal/encrypt"
+	"github.com/rmorlok/authproxy/internal/httperr"
+	mockH "github.com/rmorlok/authproxy/internal/httpf/mock"
+	"github.com/rmorlok/authproxy/internal/schema/common"
+	cschema "github.com/rmorlok/authproxy/internal/schema/resources/connectors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	genmock "gopkg.in/h2non/gentleman-mock.v2"
+)
+
+func deviceCodeAuth() *cschema.AuthOAuth2 {
+	return &cschema.AuthOAuth2{
+		Type:                    cschema.AuthTypeOAuth2,
+		GrantType:               cschema.NewOAuth2GrantType(cschema.OAuth2GrantDeviceCode),
+		TokenEndpointAuthMethod: cschema.NewTokenEndpointAuthMethod(cschema.TokenEndpointAuthNone),
+		ClientId:                common.NewStringValueDirect("client-id"),
+		DeviceAuthorization: &cschema.AuthOauth2DeviceAuthorization{
+			Endpoint: "https://example.com/oauth/device",
+		},
+		Token: cschema.AuthOauth2Token{
+			Endpoint: "https://example.com/oauth/token",
+		},
+		Scopes: []cschema.Scope{{Id: "read"}},
+	}
+}
+
+// deviceCodeConnFor builds an oAuth2Connection sitting on the device-code
+// setup step, backed by miniredis and the fake encrypt service.
+func deviceCodeConnFor(t *testing.T, ctrl *gomock.Controller) (*oAuth2Connection, *mockDb.MockDB, *mockAsynq.MockClient, *mockCore.Connection) {
+	_, r := apredis.MustApplyTestConfig(nil)
+	db := mockDb.NewMockDB(ctrl)
+	ac := mockAsynq.NewMockClient(ctrl)
+	step := cschema.MustNewSetupStep(OAuth2DeviceCodeStepId)
+	connection := &mockCore.Connection{
+		Id:        apid.New(apid.PrefixConnection),
+		SetupStep: &step,
+	}
+
+	return &oAuth2Connection{
+		db:         db,
+		r:          r,
+		encrypt:    encrypt.NewFakeEncryptService(false),
+		logger:     aplog.NewNoopLogger(),
+		httpf:      mockH.NewFactoryWithMockingClient(ctrl),
+		connection: connection,
+		auth:       deviceCodeAuth(),
+		ac:         ac,
+	}, db, ac, connection
+}
+
+// expectDevicePoll expects one poll task to be scheduled and records its
+// payload and the options it was enqueued with.
+func expectDevicePoll(ac *mockAsynq.MockClient, payload *pollDeviceTokenTaskPayload, opts *[]asynq.Option) {
+	ac.EXPECT().
+		EnqueueContext(gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, task *asynq.Task, o ...asynq.Option) (*asynq.TaskInfo, error) {
+			_ = json.Unmarshal(task.Payload(), payload)
+			*opts = o
+			return &asynq.TaskInfo{}, nil
+		})
+}
+
+func TestEnsureDeviceAuthorization(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	o, _, ac, _ := deviceCodeConnFor(t, ctrl)
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	ctx := apctx.WithFixedClock(context.Background(), now)
+
+	var capturedBody string
+	genmock.
+		New("https://example.com").
+		Post("/oauth/device").
+		MatchType("application/x-www-form-urlencoded").
+		AddMatcher(captureBody(&capturedBody)).
+		Reply(200).
+		AddHeader("Content-Type", "application/json").
+		BodyString(`{"device_code":"dev-code","user_code":"WDJB-MJHT","verification_uri":"https://example.com/device","verification_uri_complete":"https://example.com/device?user_code=WDJB-MJHT","expires_in":900,"interval":10}`)
+
+	var payload pollDeviceTokenTaskPayload
+	var opts []asynq.Option
+	expectDevicePoll(ac, &payload, &opts)
+
+	info, err := o.EnsureDeviceAuthorization(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "WDJB-MJHT", info.UserCode)
+	assert.Equal(t, "https://example.com/device", info.VerificationUri)
+	assert.Equal(t, "https://example.com/device?user_code=WDJB-MJHT", info.VerificationUriComplete)
+	assert.Equal(t, now.Add(15*time.Minute), info.ExpiresAt)
+	assert.Equal(t, 10*time.Second, info.Interval, "provider interval wins over the 5s default")
+
+	form, err := url.ParseQuery(capturedBody)
+	require.NoError(t, err)
+	assert.Equal(t, "client-id", form.Get("client_id"))
+	assert.Equal(t, "read", form.Get("scope"))
+
+	assert.Equal(t, o.connection.GetId(), payload.ConnectionId)
+	assert.NotEmpty(t, payload.Nonce)
+	assert.Contains(t, opts, asynq.ProcessIn(10*time.Second))
+
+	// Resuming returns the same pending authorization without calling the
+	// provider or scheduling a second poller.
+	again, err := o.EnsureDeviceAuthorization(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, info, again)
+}
+
+func TestEnsureDeviceAuthorization_ProviderRejects(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	o, _, _, _ := deviceCodeConnFor(t, ctrl)
+
+	genmock.
+		New("https://example.com").
+		Post("/oauth/device").
+		Reply(400).
+		AddHeader("Content-Type", "application/json").
+		BodyString(`{"error":"invalid_client"}`)
+
+	_, err := o.EnsureDeviceAuthorization(context.Background())
+	require.Error(t, err)
+	assert.True(t, httperr.IsStatus(err, http.StatusBadGateway))
+	assert.Contains(t, err.Error(), "invalid_client")
+}
+
+// seedDeviceAuthorization stores a pending authorization as
+// EnsureDeviceAuthorization would have.
+func seedDeviceAuthorization(t *testing.T, ctx context.Context, o *oAuth2Connection, expiresAt time.Time) *deviceAuthorization {
+	t.Helper()
+	da := &deviceAuthorization{
+		Nonce:           "nonce-1",
+		DeviceCode:      "dev-code",
+		UserCode:        "WDJB-MJHT",
+		VerificationUri: "https://example.com/device",
+		ExpiresAt:       expiresAt,
+		Interval:        5 * time.Second,
+	}
+	require.NoError(t, o.saveDeviceAuthorization(ctx, da))
+	return da
+}
+
+func mockDeviceTokenResponse(status int, body string, capturedBody *string) {
+	genmock.
+		New("https://example.com").
+		Post("/oauth/token").
+		AddMatcher(captureBody(capturedBody)).
+		Reply(status).
+		AddHeader("Content-Type", "application/json").
+		BodyString(body)
+}
+
+func TestPollDeviceToken(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	ctx := apctx.WithFixedClock(context.Background(), now)
+
+	t.Run("authorization_pending reschedules", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		o, _, ac, conn := deviceCodeConnFor(t, ctrl)
+		seedDeviceAuthorization(t, ctx, o, now.Add(10*time.Minute))
+
+		var capturedBody string
+		mockDeviceTokenResponse(400, `{"error":"authorization_pending"}`, &capturedBody)
+
+		var payload pollDeviceTokenTaskPayload
+		var opts []asynq.Option
+		expectDevicePoll(ac, &payload, &opts)
+
+		require.NoError(t, o.pollDeviceToken(ctx, "nonce-1"))
+
+		form, err := url.ParseQuery(capturedBody)
+		require.NoError(t, err)
+		assert.Equal(t, "urn:ietf:params:oauth:grant-type:device_code", form.Get("grant_type"))
+		assert.Equal(t, "dev-code", form.Get("device_code"))
+		assert.Equal(t, "client-id", form.Get("client_id"))
+
+		assert.Equal(t, "nonce-1", payload.Nonce)
+		assert.Contains(t, opts, asynq.ProcessIn(5*time.Second))
+		assert.Equal(t, OAuth2DeviceCodeStepId, conn.GetSetupStep().Id())
+	})
+
+	t.Run("slow_down grows the interval", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		o, _, ac, _ := deviceCodeConnFor(t, ctrl)
+		seedDeviceAuthorization(t, ctx, o, now.Add(10*time.Minute))
+
+		var capturedBody string
+		mockDeviceTokenResponse(200, `{"error":"slow_down"}`, &capturedBody)
+
+		var payload pollDeviceTokenTaskPayload
+		var opts []asynq.Option
+		expectDevicePoll(ac, &payload, &opts)
+
+		require.NoError(t, o.pollDeviceToken(ctx, "nonce-1"))
+		assert.Contains(t, opts, asynq.ProcessIn(10*time.Second))
+
+		da, err := o.loadDeviceAuthorization(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 10*time.Second, da.Interval)
+	})
+
+	t.Run("success stores the token and advances setup", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		o, db, _, _ := deviceCodeConnFor(t, ctrl)
+		seedDeviceAuthorization(t, ctx, o, now.Add(10*time.Minute))
+
+		db.EXPECT().
+			InsertOAuth2Token(gomock.Any(), o.connection.GetId(), nil, gomock.Any(), gomock.Any(), gomock.Any(), "read", "read", gomock.Any()).
+			Return(&database.OAuth2Token{}, nil)
+
+		var capturedBody string
+		mockDeviceTokenResponse(200, `{"access_token":"access-token","refresh_token":"refresh-token","expires_in":3600}`, &capturedBody)
+
+		require.NoError(t, o.pollDeviceToken(ctx, "nonce-1"))
+
+		da, err := o.loadDeviceAuthorization(ctx)
+		require.NoError(t, err)
+		assert.Nil(t, da, "consumed authorization is deleted")
+	})
+
+	t.Run("expired_token fails the connection", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		o, _, _, conn := deviceCodeConnFor(t, ctrl)
+		seedDeviceAuthorization(t, ctx, o, now.Add(10*time.Minute))
+
+		var capturedBody string
+		mockDeviceTokenResponse(400, `{"error":"expired_token"}`, &capturedBody)
+
+		require.NoError(t, o.pollDeviceToken(ctx, "nonce-1"))
+		assert.Equal(t, cschema.SetupStepAuthFailed, *conn.GetSetupStep())
+		require.NotNil(t, conn.GetSetupError())
+		assert.Contains(t, *conn.GetSetupError(), "expired")
+
+		da, err := o.loadDeviceAuthorization(ctx)
+		require.NoError(t, err)
+		assert.Nil(t, da)
+	})
+
+	t.Run("access_denied fails the connection", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		o, _, _, conn := deviceCodeConnFor(t, ctrl)
+		seedDeviceAuthorization(t, ctx, o, now.Add(10*time.Minute))
+
+		var capturedBody string
+		mockDeviceTokenResponse(400, `{"error":"access_denied"}`, &capturedBody)
+
+		require.NoError(t, o.pollDeviceToken(ctx, "nonce-1"))
+		assert.Equal(t, cschema.SetupStepAuthFailed, *conn.GetSetupStep())
+		assert.Contains(t, *conn.GetSetupError(), "denied")
+	})
+
+	t.Run("past expiry fails without polling", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		o, _, _, conn := deviceCodeConnFor(t, ctrl)
+		seedDeviceAuthorization(t, ctx, o, now.Add(time.Minute))
+
+		later := apctx.WithFixedClock(context.Background(), now.Add(2*time.Minute))
+		require.NoError(t, o.pollDeviceToken(later, "nonce-1"))
+		assert.Equal(t, cschema.SetupStepAuthFailed, *conn.GetSetupStep())
+	})
+
+	t.Run("stale nonce is a no-op", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		o, _, _, conn := deviceCodeConnFor(t, ctrl)
+		seedDeviceAuthorization(t, ctx, o, now.Add(10*time.Minute))
+
+		require.NoError(t, o.pollDeviceToken(ctx, "nonce-from-earlier-attempt"))
+		assert.Equal(t, OAuth2DeviceCodeStepId, conn.GetSetupStep().Id())
+	})
+
+	t.Run("setup moved on is a no-op", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		o, _, _, conn := deviceCodeConnFor(t, ctrl)
+		seedDeviceAuthorization(t, ctx, o, now.Add(10*time.Minute))
+		conn.SetupStep = nil
+
+		require.NoError(t, o.pollDeviceToken(ctx, "nonce-1"))
+		assert.Nil(t, conn.GetSetupStep())
+	})
+}
+
+func TestAuthOAuth2Validate_DeviceCode(t *testing.T) {
+	vc := &common.ValidationContext{}
+
+	t.Run("valid", func(t *testing.T) {
+		assert.NoError(t, deviceCodeAuth().Validate(vc))
+	})
+
+	t.Run("requires device authorization endpoint", func(t *testing.T) {
+		auth := deviceCodeAuth()
+		auth.DeviceAuthorization = nil
+		assert.ErrorContains(t, auth.Validate(vc), "device_authorization")
+
+		auth = deviceCodeAuth()
+		auth.DeviceAuthorization.Endpoint = ""
+		assert.ErrorContains(t, auth.Validate(vc), "endpoint")
+	})
+
+	t.Run("requires client id", func(t *testing.T) {
+		auth := deviceCodeAuth()
+		auth.ClientId = nil
+		assert.ErrorContains(t, auth.Validate(vc), "client_id")
+	})
+
+	t.Run("rejects device authorization for other grants", func(t *testing.T) {
+		auth := deviceCodeAuth()
+		auth.GrantType = nil
+		auth.Authorization.Endpoint = "https://example.com/oauth/authorize"
+		auth.Authorization.PKCE = &cschema.AuthOauth2PKCE{}
+		assert.ErrorContains(t, auth.Validate(vc), "must be omitted unless grant_type")
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"log/slog"
 
+	"github.com/rmorlok/authproxy/internal/apasynq"
 	"github.com/rmorlok/authproxy/internal/apid"
 	"github.com/rmorlok/authproxy/internal/apredis"
 	"github.com/rmorlok/authproxy/internal/aptelemetry"
@@ -25,6 +26,7 @@ type factory struct {
 	encrypt encrypt.E
 	logger  *slog.Logger
 	tel     *telemetry
+	ac      apasynq.Client
 }
 
 // FactoryOption configures a Factory at construction time. The functional-
@@ -35,6 +37,7 @@ type FactoryOption func(*factoryOptions)
 type factoryOptions struct {
 	providers *aptelemetry.Providers
 	telCfg    *sconfig.Telemetry
+	ac        apasynq.Client
 }
 
 // WithTelemetry registers the OTel providers + telemetry config so the
@@ -49,6 +52,15 @@ func WithTelemetry(providers *aptelemetry.Providers, telCfg *sconfig.Telemetry)
 	}
 }
 
+// WithTaskClient registers the asynq client used to schedule the background
+// token-endpoint polling for the device_code grant. Factories without it
+// can't start a device authorization.
+func WithTaskClient(ac apasynq.Client) FactoryOption {
+	return func(o *factoryOptions) {
+		o.ac = ac
+	}
+}
+
 func NewFactory(
 	cfg config.C,
 	db database.DB,
@@ -83,6 +95,7 @@ func NewFactory(
 		encrypt: encrypt,
 		logger:  logger,
 		tel:     tel,
+		ac:      resolved.ac,
 	}
 }
 
@@ -107,7 +120,9 @@ func (f *factory) newConnection(connection coreIface.Connection) *oAuth2Connecti
 		f.httpf,
 		connection,
 	)
+	conn.core = f.core
 	conn.tel = f.tel
+	conn.ac = f.ac
 	return conn
 }
 
@@ -135,6 +150,7 @@ func (f *factory) GetOAuth2State(
 	}
 	if oc, ok := conn.(*oAuth2Connection); ok {
 		oc.tel = f.tel
+		oc.ac = f.ac
 	}
 	return conn, nil
 }
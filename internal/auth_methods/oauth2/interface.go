@@ -41,4 +41,6 @@ type OAuth2Connection interface {
 	) (string, error)
 	CallbackFrom3rdParty(ctx context.Context, query url.Values) (string, error)
 	ExchangeClientCredentials(ctx context.Context) error
+	ExchangeJwtBearer(ctx context.Context) error
+	EnsureDeviceAuthorization(ctx context.Context) (coreIface.DeviceCodeInfo, error)
 }
@@ -0,0 +1,245 @@
+package oauth2
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+	apauthcore "github.com/rmorlok/authproxy/internal/apauth/core"
+	"github.com/rmorlok/authproxy/internal/apctx"
+	coreIface "github.com/rmorlok/authproxy/internal/core/iface"
+	"github.com/rmorlok/authproxy/internal/database"
+	"github.com/rmorlok/authproxy/internal/httperr"
+	"github.com/rmorlok/authproxy/internal/schema/common"
+	"github.com/rmorlok/authproxy/internal/schema/common/json_schema"
+	"github.com/rmorlok/authproxy/internal/schema/common/ui_schema"
+	cschema "github.com/rmorlok/authproxy/internal/schema/resources/connectors"
+)
+
+const attrPrivateKey = "private_key"
+const attrKeyId = "key_id"
+
+func synthesizeJwtBearerKeyStep() *cschema.SetupFlowStep {
+	js := json_schema.Schema{
+		Type:     "object",
+		Required: []string{attrPrivateKey},
+		Properties: map[string]json_schema.Property{
+			attrPrivateKey: {
+				Type:      "string",
+				Title:     "Private Key (PEM)",
+				MinLength: 1,
+			},
+			attrKeyId: {
+				Type:  "string",
+				Title: "Key ID",
+			},
+		},
+		AdditionalProperties: false,
+	}
+	ui := ui_schema.Schema{
+		Type: "VerticalLayout",
+		Elements: []ui_schema.Control{
+			{
+				Type:    "Control",
+				Scope:   fmt.Sprintf("#/properties/%s", attrPrivateKey),
+				Options: map[string]string{"multi": "true"},
+			},
+			{
+				Type:  "Control",
+				Scope: fmt.Sprintf("#/properties/%s", attrKeyId),
+			},
+		},
+	}
+
+	jsBytes, err := json.Marshal(js)
+	if err != nil {
+		panic("oauth2: failed to marshal synthesized jwt-bearer key json_schema: " + err.Error())
+	}
+	uiBytes, err := json.Marshal(ui)
+	if err != nil {
+		panic("oauth2: failed to marshal synthesized jwt-bearer key ui_schema: " + err.Error())
+	}
+
+	return &cschema.SetupFlowStep{
+		Id:          OAuth2JwtBearerKeyStepId,
+		Title:       "Upload signing key",
+		Description: "Provide the private key used to authenticate with this service.",
+		JsonSchema:  common.RawJSON(jsBytes),
+		UiSchema:    common.RawJSON(uiBytes),
+	}
+}
+
+// PersistJwtBearerKey validates the submitted private key against the
+// connector's assertion algorithm and stores it, encrypted, in
+// connection_credentials.
+func (f *factory) PersistJwtBearerKey(
+	ctx context.Context,
+	connection coreIface.Connection,
+	auth *cschema.AuthOAuth2,
+	credData map[string]any,
+) error {
+	plaintext := database.OAuth2JwtBearerKeyPlaintext{}
+	if v, ok := credData[attrPrivateKey].(string); ok {
+		plaintext.PrivateKey = strings.TrimSpace(v)
+	}
+	if plaintext.PrivateKey == "" {
+		return httperr.BadRequest(fmt.Sprintf("%s is required", attrPrivateKey))
+	}
+	if v, ok := credData[attrKeyId].(string); ok {
+		plaintext.KeyId = strings.TrimSpace(v)
+	}
+
+	// Reject a key that can't sign with the configured algorithm now, while
+	// the user is still on the form, rather than at the token exchange.
+	if _, _, err := parseAssertionSigningKey(auth.Assertion.GetAlgorithmOrDefault(), plaintext.PrivateKey); err != nil {
+		return httperr.BadRequest(fmt.Sprintf("invalid %s: %s", attrPrivateKey, err.Error()))
+	}
+
+	blobJSON, err := json.Marshal(plaintext)
+	if err != nil {
+		return httperr.InternalServerError(httperr.WithInternalErrorf("failed to marshal OAuth2 jwt-bearer key: %w", err))
+	}
+	encrypted, err := f.encrypt.EncryptStringForNamespace(ctx, connection.GetNamespace(), string(blobJSON))
+	if err != nil {
+		return httperr.InternalServerError(httperr.WithInternalErrorf("failed to encrypt OAuth2 jwt-bearer key: %w", err))
+	}
+
+	actorId := apauthcore.GetAuthFromContext(ctx).MustGetActor().GetId()
+	if _, err := f.db.InsertApiKeyCredential(ctx, connection.GetId(), encrypted, nil, &actorId); err != nil {
+		return httperr.InternalServerError(httperr.WithInternalErrorf("failed to persist OAuth2 jwt-bearer key: %w", err))
+	}
+	return nil
+}
+
+func (o *oAuth2Connection) loadJwtBearerKey(ctx context.Context) (database.OAuth2JwtBearerKeyPlaintext, error) {
+	cred, err := o.db.GetActiveApiKeyCredential(ctx, o.connection.GetId())
+	if err != nil {
+		return database.OAuth2JwtBearerKeyPlaintext{}, fmt.Errorf("failed to load OAuth2 jwt-bearer key: %w", err)
+	}
+
+	plaintextJSON, err := o.encrypt.DecryptString(ctx, cred.EncryptedCredentials)
+	if err != nil {
+		return database.OAuth2JwtBearerKeyPlaintext{}, fmt.Errorf("failed to decrypt OAuth2 jwt-bearer key: %w", err)
+	}
+
+	var plaintext database.OAuth2JwtBearerKeyPlaintext
+	if err := json.Unmarshal([]byte(plaintextJSON), &plaintext); err != nil {
+		return database.OAuth2JwtBearerKeyPlaintext{}, fmt.Errorf("failed to parse OAuth2 jwt-bearer key: %w", err)
+	}
+	if plaintext.PrivateKey == "" {
+		return database.OAuth2JwtBearerKeyPlaintext{}, errors.New("OAuth2 jwt-bearer key missing private key")
+	}
+	return plaintext, nil
+}
+
+// parseAssertionSigningKey parses a PEM private key of the family the
+// algorithm needs: RSA for RS*/PS*, ECDSA for ES*.
+func parseAssertionSigningKey(alg cschema.JwtAssertionAlgorithm, pemKey string) (jwt.SigningMethod, any, error) {
+	method := jwt.GetSigningMethod(string(alg))
+	if method == nil {
+		return nil, nil, fmt.Errorf("unsupported assertion algorithm %q", alg)
+	}
+
+	switch method.(type) {
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS:
+		key, err := jwt.ParseRSAPrivateKeyFromPEM([]byte(pemKey))
+		if err != nil {
+			return nil, nil, fmt.Errorf("expected a PEM-encoded RSA private key for %s: %w", alg, err)
+		}
+		return method, key, nil
+	case *jwt.SigningMethodECDSA:
+		key, err := jwt.ParseECPrivateKeyFromPEM([]byte(pemKey))
+		if err != nil {
+			return nil, nil, fmt.Errorf("expected a PEM-encoded EC private key for %s: %w", alg, err)
+		}
+		return method, key, nil
+	default:
+		return nil, nil, fmt.Errorf("unsupported assertion algorithm %q", alg)
+	}
+}
+
+// buildJwtBearerAssertion mints the signed JWT presented as the grant
+// (RFC 7523 §3). A fresh assertion with a unique jti is minted for every
+// token request, including background refreshes.
+func (o *oAuth2Connection) buildJwtBearerAssertion(ctx context.Context, tokenEndpoint string) (string, error) {
+	a := o.auth.Assertion
+	if a == nil {
+		return "", errors.New("connector has no assertion configuration")
+	}
+
+	key, err := o.loadJwtBearerKey(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	method, signingKey, err := parseAssertionSigningKey(a.GetAlgorithmOrDefault(), key.PrivateKey)
+	if err != nil {
+		return "", err
+	}
+
+	issuer, err := o.renderMustache(ctx, a.Issuer)
+	if err != nil {
+		return "", fmt.Errorf("failed to render assertion issuer template: %w", err)
+	}
+
+	audience := tokenEndpoint
+	if a.Audience != "" {
+		audience, err = o.renderMustache(ctx, a.Audience)
+		if err != nil {
+			return "", fmt.Errorf("failed to render assertion audience template: %w", err)
+		}
+	}
+
+	jti := make([]byte, 16)
+	if _, err := rand.Read(jti); err != nil {
+		return "", fmt.Errorf("failed to generate assertion jti: %w", err)
+	}
+
+	now := apctx.GetClock(ctx).Now()
+	claims := jwt.MapClaims{}
+	for k, v := range a.AdditionalClaims {
+		rendered, err := o.renderMustache(ctx, v)
+		if err != nil {
+			return "", fmt.Errorf("failed to render assertion claim %q template: %w", k, err)
+		}
+		claims[k] = rendered
+	}
+
+	claims["iss"] = issuer
+	claims["aud"] = audience
+	claims["iat"] = now.Unix()
+	claims["exp"] = now.Add(a.GetLifetimeOrDefault()).Unix()
+	claims["jti"] = hex.EncodeToString(jti)
+
+	if a.Subject != "" {
+		subject, err := o.renderMustache(ctx, a.Subject)
+		if err != nil {
+			return "", fmt.Errorf("failed to render assertion subject template: %w", err)
+		}
+		claims["sub"] = subject
+	}
+
+	if a.Scope != "" {
+		scope, err := o.renderMustache(ctx, a.Scope)
+		if err != nil {
+			return "", fmt.Errorf("failed to render assertion scope template: %w", err)
+		}
+		claims["scope"] = scope
+	}
+
+	token := jwt.NewWithClaims(method, claims)
+	if key.KeyId != "" {
+		token.Header["kid"] = key.KeyId
+	}
+
+	signed, err := token.SignedString(signingKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign assertion: %w", err)
+	}
+	return signed, nil
+}
@@ -0,0 +1,374 @@
+package oauth2
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/golang/mock/gomock"
+	"github.com/rmorlok/authproxy/internal/apctx"
+	"github.com/rmorlok/authproxy/internal/apid"
+	"github.com/rmorlok/authproxy/internal/apredis"
+	mockCore "github.com/rmorlok/authproxy/internal/core/mock"
+	"github.com/rmorlok/authproxy/internal/database"
+	mockDb "github.com/rmorlok/authproxy/internal/database/mock"
+	"github.com/rmorlok/authproxy/internal/encfield"
+	mockEncrypt "github.com/rmorlok/authproxy/internal/encrypt/mock"
+	mockH "github.com/rmorlok/authproxy/internal/httpf/mock"
+	"github.com/rmorlok/authproxy/internal/schema/common"
+	sconfig "github.com/rmorlok/authproxy/internal/schema/config"
+	cschema "github.com/rmorlok/authproxy/internal/schema/resources/connectors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	genmock "gopkg.in/h2non/gentleman-mock.v2"
+)
+
+func rsaKeyPEM(t *testing.T) (*rsa.PrivateKey, string) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	return key, string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}))
+}
+
+func jwtBearerAuth() *cschema.AuthOAuth2 {
+	return &cschema.AuthOAuth2{
+		Type:      cschema.AuthTypeOAuth2,
+		GrantType: cschema.NewOAuth2GrantType(cschema.OAuth2GrantJwtBearer),
+		Token: cschema.AuthOauth2Token{
+			Endpoint: "https://example.com/oauth/token",
+		},
+		Scopes: []cschema.Scope{{Id: "read"}},
+		Assertion: &cschema.AuthOAuth2Assertion{
+			Issuer:           "{{cfg.service_account}}",
+			Subject:          "admin@example.com",
+			Scope:            "https://example.com/auth/read",
+			AdditionalClaims: map[string]string{"tenant": "{{cfg.tenant}}"},
+		},
+	}
+}
+
+// jwtBearerConnFor builds an oAuth2Connection whose stored credential is the
+// given jwt-bearer key blob.
+func jwtBearerConnFor(t *testing.T, ctrl *gomock.Controller, auth *cschema.AuthOAuth2, keyJSON string) (*oAuth2Connection, *mockDb.MockDB, *mockEncrypt.MockE) {
+	db := mockDb.NewMockDB(ctrl)
+	encrypt := mockEncrypt.NewMockE(ctrl)
+	connectionId := apid.New(apid.PrefixConnection)
+
+	encryptedKey := encfield.EncryptedField{ID: "ekv_test", Data: "encrypted_key"}
+	db.EXPECT().
+		GetActiveApiKeyCredential(gomock.Any(), connectionId).
+		Return(&database.ApiKeyCredential{
+			Id:                   apid.New(apid.PrefixApiKeyCredential),
+			ConnectionId:         connectionId,
+			EncryptedCredentials: encryptedKey,
+		}, nil).
+		AnyTimes()
+	encrypt.EXPECT().
+		DecryptString(gomock.Any(), encryptedKey).
+		Return(keyJSON, nil).
+		AnyTimes()
+
+	return &oAuth2Connection{
+		db:      db,
+		encrypt: encrypt,
+		httpf:   mockH.NewFactoryWithMockingClient(ctrl),
+		connection: &mockCore.Connection{
+			Id:            connectionId,
+			Configuration: map[string]any{"service_account": "svc@example.iam", "tenant": "acme"},
+		},
+		auth: auth,
+	}, db, encrypt
+}
+
+func keyBlob(t *testing.T, pemKey, keyId string) string {
+	t.Helper()
+	b, err := json.Marshal(database.OAuth2JwtBearerKeyPlaintext{PrivateKey: pemKey, KeyId: keyId})
+	require.NoError(t, err)
+	return string(b)
+}
+
+func TestBuildJwtBearerAssertion(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	key, pemKey := rsaKeyPEM(t)
+	o, _, _ := jwtBearerConnFor(t, ctrl, jwtBearerAuth(), keyBlob(t, pemKey, "key-1"))
+
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	ctx := apctx.WithFixedClock(context.Background(), now)
+
+	assertion, err := o.buildJwtBearerAssertion(ctx, "https://example.com/oauth/token")
+	require.NoError(t, err)
+
+	claims := jwt.MapClaims{}
+	parsed, err := jwt.ParseWithClaims(assertion, claims, func(token *jwt.Token) (interface{}, error) {
+		return &key.PublicKey, nil
+	}, jwt.WithTimeFunc(func() time.Time { return now }))
+	require.NoError(t, err)
+
+	assert.Equal(t, "RS256", parsed.Method.Alg())
+	assert.Equal(t, "key-1", parsed.Header["kid"])
+	assert.Equal(t, "svc@example.iam", claims["iss"])
+	assert.Equal(t, "admin@example.com", claims["sub"])
+	assert.Equal(t, "https://example.com/oauth/token", claims["aud"], "aud defaults to the token endpoint")
+	assert.Equal(t, "https://example.com/auth/read", claims["scope"])
+	assert.Equal(t, "acme", claims["tenant"])
+	assert.Equal(t, float64(now.Unix()), claims["iat"])
+	assert.Equal(t, float64(now.Add(time.Hour).Unix()), claims["exp"])
+	assert.NotEmpty(t, claims["jti"])
+
+	again, err := o.buildJwtBearerAssertion(ctx, "https://example.com/oauth/token")
+	require.NoError(t, err)
+	assert.NotEqual(t, assertion, again, "each assertion has a unique jti")
+}
+
+func TestBuildJwtBearerAssertion_ES256(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	pemKey := string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}))
+
+	auth := jwtBearerAuth()
+	auth.Assertion = &cschema.AuthOAuth2Assertion{
+		Algorithm: cschema.JwtAssertionAlgorithmES256,
+		Issuer:    "client-id",
+		Audience:  "https://example.com",
+	}
+	o, _, _ := jwtBearerConnFor(t, ctrl, auth, keyBlob(t, pemKey, ""))
+
+	assertion, err := o.buildJwtBearerAssertion(context.Background(), "https://example.com/oauth/token")
+	require.NoError(t, err)
+
+	claims := jwt.MapClaims{}
+	parsed, err := jwt.ParseWithClaims(assertion, claims, func(token *jwt.Token) (interface{}, error) {
+		return &key.PublicKey, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "ES256", parsed.Method.Alg())
+	_, hasKid := parsed.Header["kid"]
+	assert.False(t, hasKid)
+	assert.Equal(t, "https://example.com", claims["aud"])
+	_, hasSub := claims["sub"]
+	assert.False(t, hasSub, "sub omitted when not configured")
+}
+
+func TestParseAssertionSigningKey_RejectsWrongKeyFamily(t *testing.T) {
+	_, pemKey := rsaKeyPEM(t)
+	_, _, err := parseAssertionSigningKey(cschema.JwtAssertionAlgorithmES256, pemKey)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "EC private key")
+
+	_, _, err = parseAssertionSigningKey(cschema.JwtAssertionAlgorithmRS256, "not a key")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "RSA private key")
+}
+
+func TestExchangeJwtBearer(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	_, pemKey := rsaKeyPEM(t)
+	o, db, encrypt := jwtBearerConnFor(t, ctrl, jwtBearerAuth(), keyBlob(t, pemKey, ""))
+	connectionId := o.connection.GetId()
+
+	encrypt.EXPECT().
+		EncryptStringForEntity(gomock.Any(), gomock.Any(), "access-token").
+		Return(encfield.EncryptedField{ID: "ekv_test", Data: "encrypted_access_token"}, nil)
+	db.EXPECT().
+		InsertOAuth2Token(
+			gomock.Any(),
+			connectionId,
+			nil,
+			encfield.EncryptedField{},
+			encfield.EncryptedField{ID: "ekv_test", Data: "encrypted_access_token"},
+			gomock.Any(),
+			"read",
+			"read",
+			gomock.Any(),
+		).
+		Return(&database.OAuth2Token{}, nil)
+
+	var capturedBody, capturedAuth string
+	genmock.
+		New("https://example.com").
+		Post("/oauth/token").
+		MatchType("application/x-www-form-urlencoded").
+		AddMatcher(captureBody(&capturedBody)).
+		AddMatcher(captureHeader("Authorization", &capturedAuth)).
+		Reply(200).
+		AddHeader("Content-Type", "application/json").
+		BodyString(`{"access_token":"access-token","expires_in":3600}`)
+
+	require.NoError(t, o.ExchangeJwtBearer(context.Background()))
+
+	form, err := url.ParseQuery(capturedBody)
+	require.NoError(t, err)
+	assert.Equal(t, "urn:ietf:params:oauth:grant-type:jwt-bearer", form.Get("grant_type"))
+	assert.NotEmpty(t, form.Get("assertion"))
+	assert.Equal(t, "read", form.Get("scope"))
+	assert.Empty(t, form.Get("client_id"), "no client authentication without a client_id")
+	assert.Empty(t, capturedAuth)
+}
+
+func TestExchangeJwtBearer_AuthenticatesClientWhenConfigured(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	auth := jwtBearerAuth()
+	auth.ClientId = common.NewStringValueDirect("client-id")
+	auth.TokenEndpointAuthMethod = cschema.NewTokenEndpointAuthMethod(cschema.TokenEndpointAuthNone)
+
+	_, pemKey := rsaKeyPEM(t)
+	o, db, encrypt := jwtBearerConnFor(t, ctrl, auth, keyBlob(t, pemKey, ""))
+
+	encrypt.EXPECT().
+		EncryptStringForEntity(gomock.Any(), gomock.Any(), "access-token").
+		Return(encfield.EncryptedField{ID: "ekv_test", Data: "encrypted_access_token"}, nil)
+	db.EXPECT().
+		InsertOAuth2Token(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(&database.OAuth2Token{}, nil)
+
+	var capturedBody string
+	genmock.
+		New("https://example.com").
+		Post("/oauth/token").
+		AddMatcher(captureBody(&capturedBody)).
+		Reply(200).
+		AddHeader("Content-Type", "application/json").
+		BodyString(`{"access_token":"access-token","expires_in":3600}`)
+
+	require.NoError(t, o.ExchangeJwtBearer(context.Background()))
+
+	form, err := url.ParseQuery(capturedBody)
+	require.NoError(t, err)
+	assert.Equal(t, "client-id", form.Get("client_id"))
+}
+
+func TestRefreshAccessToken_JwtBearerMintsNewAssertion(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	_, r := apredis.MustApplyTestConfig(nil)
+	_, pemKey := rsaKeyPEM(t)
+	o, db, encrypt := jwtBearerConnFor(t, ctrl, jwtBearerAuth(), keyBlob(t, pemKey, ""))
+	o.r = r
+	connectionId := o.connection.GetId()
+	tokenId := apid.New(apid.PrefixOAuth2Token)
+
+	existing := &database.OAuth2Token{
+		Id:           tokenId,
+		ConnectionId: connectionId,
+	}
+	db.EXPECT().
+		GetOAuth2Token(gomock.Any(), connectionId).
+		Return(existing, nil)
+	encrypt.EXPECT().
+		EncryptStringForEntity(gomock.Any(), gomock.Any(), "new-access-token").
+		Return(encfield.EncryptedField{ID: "ekv_test", Data: "encrypted_access_token"}, nil)
+	db.EXPECT().
+		InsertOAuth2Token(
+			gomock.Any(),
+			connectionId,
+			&tokenId,
+			encfield.EncryptedField{},
+			encfield.EncryptedField{ID: "ekv_test", Data: "encrypted_access_token"},
+			gomock.Any(),
+			"read",
+			"read",
+			gomock.Any(),
+		).
+		Return(&database.OAuth2Token{}, nil)
+
+	var capturedBody string
+	genmock.
+		New("https://example.com").
+		Post("/oauth/token").
+		AddMatcher(captureBody(&capturedBody)).
+		Reply(200).
+		AddHeader("Content-Type", "application/json").
+		BodyString(`{"access_token":"new-access-token","expires_in":3600}`)
+
+	_, err := o.refreshAccessToken(context.Background(), existing, refreshModeAlways)
+	require.NoError(t, err)
+
+	form, err := url.ParseQuery(capturedBody)
+	require.NoError(t, err)
+	assert.Equal(t, string(sconfig.OAuth2GrantJwtBearer), form.Get("grant_type"))
+	assert.NotEmpty(t, form.Get("assertion"))
+	assert.Empty(t, form.Get("refresh_token"))
+}
+
+func TestAuthOAuth2_Validate_JwtBearer(t *testing.T) {
+	t.Run("valid without client credentials", func(t *testing.T) {
+		require.NoError(t, jwtBearerAuth().Validate(&common.ValidationContext{}))
+	})
+
+	t.Run("requires assertion", func(t *testing.T) {
+		a := jwtBearerAuth()
+		a.Assertion = nil
+		err := a.Validate(&common.ValidationContext{})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "assertion")
+	})
+
+	t.Run("requires issuer", func(t *testing.T) {
+		a := jwtBearerAuth()
+		a.Assertion.Issuer = ""
+		err := a.Validate(&common.ValidationContext{})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "issuer")
+	})
+
+	t.Run("rejects unknown algorithm", func(t *testing.T) {
+		a := jwtBearerAuth()
+		a.Assertion.Algorithm = "HS256"
+		err := a.Validate(&common.ValidationContext{})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "HS256")
+	})
+
+	t.Run("rejects reserved additional claims", func(t *testing.T) {
+		a := jwtBearerAuth()
+		a.Assertion.AdditionalClaims = map[string]string{"exp": "0"}
+		err := a.Validate(&common.ValidationContext{})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "exp")
+	})
+
+	t.Run("rejects authorization block", func(t *testing.T) {
+		a := jwtBearerAuth()
+		a.Authorization.Endpoint = "https://example.com/oauth/authorize"
+		err := a.Validate(&common.ValidationContext{})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "authorization")
+	})
+
+	t.Run("client_id with default auth method requires secret", func(t *testing.T) {
+		a := jwtBearerAuth()
+		a.ClientId = common.NewStringValueDirect("client-id")
+		err := a.Validate(&common.ValidationContext{})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "client_secret")
+	})
+
+	t.Run("assertion rejected for other grants", func(t *testing.T) {
+		a := jwtBearerAuth()
+		a.GrantType = cschema.NewOAuth2GrantType(cschema.OAuth2GrantClientCredentials)
+		err := a.Validate(&common.ValidationContext{})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "assertion")
+	})
+}
@@ -20,10 +20,21 @@ const OAuth2AuthorizeStepId = "apxy:auth:oauth2_authorize"
 // client-credentials collection form.
 const OAuth2ClientCredentialsStepId = "apxy:auth:oauth2_client_credentials"
 
+// OAuth2JwtBearerKeyStepId is the manifest id for OAuth2's synthesized
+// jwt-bearer signing-key upload form.
+const OAuth2JwtBearerKeyStepId = "apxy:auth:oauth2_jwt_bearer_key"
+
+// OAuth2DeviceCodeStepId is the manifest id for OAuth2's device-code step.
+// The device_code poll task compares against it to tell whether setup is
+// still waiting on the user.
+const OAuth2DeviceCodeStepId = "apxy:auth:oauth2_device_code"
+
 // ManifestSetupSteps returns the OAuth2-emitted setup steps for this
 // connection. authorization_code emits a redirect step; client_credentials
 // emits a credential form that stores the submitted client id / secret and
-// performs the token endpoint exchange on submit.
+// performs the token endpoint exchange on submit; jwt-bearer does the same
+// with an uploaded signing key; device_code emits a device-code step that
+// shows the user code while a background task polls for the token.
 func (f *factory) ManifestSetupSteps(connection coreIface.Connection, connector *cschema.Connector) []coreIface.ManifestSetupStep {
 	if connector == nil || connector.Auth == nil {
 		return nil
@@ -55,6 +66,41 @@ func (f *factory) ManifestSetupSteps(connection coreIface.Connection, connector
 			}),
 		}
 	}
+	if auth.GetGrantTypeOrDefault() == cschema.OAuth2GrantJwtBearer {
+		spec := synthesizeJwtBearerKeyStep()
+		return []coreIface.ManifestSetupStep{
+			coreIface.NewFormStep(coreIface.FormStepConfig{
+				Id:          spec.Id,
+				Title:       spec.Title,
+				Description: spec.Description,
+				JsonSchema:  json.RawMessage(spec.JsonSchema),
+				UiSchema:    json.RawMessage(spec.UiSchema),
+				OnSubmit: func(ctx context.Context, data json.RawMessage) error {
+					keyData, err := spec.ValidateAndMergeData(spec.Id, data, nil)
+					if err != nil {
+						return httperr.BadRequest(err.Error())
+					}
+					if err := f.PersistJwtBearerKey(ctx, connection, auth, keyData); err != nil {
+						return err
+					}
+					o2 := f.NewOAuth2(connection)
+					return o2.ExchangeJwtBearer(ctx)
+				},
+			}),
+		}
+	}
+	if auth.GetGrantTypeOrDefault() == cschema.OAuth2GrantDeviceCode {
+		return []coreIface.ManifestSetupStep{
+			coreIface.NewDeviceCodeStep(coreIface.DeviceCodeStepConfig{
+				Id:          OAuth2DeviceCodeStepId,
+				Title:       "Authorize on another device",
+				Description: "Visit the verification page and enter the code to authorize this connection.",
+				Render: func(ctx context.Context) (coreIface.DeviceCodeInfo, error) {
+					return f.NewOAuth2(connection).EnsureDeviceAuthorization(ctx)
+				},
+			}),
+		}
+	}
 	return []coreIface.ManifestSetupStep{
 		coreIface.NewRedirectStep(coreIface.RedirectStepConfig{
 			Id:          OAuth2AuthorizeStepId,
@@ -272,6 +272,21 @@ func (mr *MockOAuth2ConnectionMockRecorder) CancelSessionAfterAuth() *gomock.Cal
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CancelSessionAfterAuth", reflect.TypeOf((*MockOAuth2Connection)(nil).CancelSessionAfterAuth))
 }
 
+// EnsureDeviceAuthorization mocks base method.
+func (m *MockOAuth2Connection) EnsureDeviceAuthorization(ctx context.Context) (iface.DeviceCodeInfo, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EnsureDeviceAuthorization", ctx)
+	ret0, _ := ret[0].(iface.DeviceCodeInfo)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// EnsureDeviceAuthorization indicates an expected call of EnsureDeviceAuthorization.
+func (mr *MockOAuth2ConnectionMockRecorder) EnsureDeviceAuthorization(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EnsureDeviceAuthorization", reflect.TypeOf((*MockOAuth2Connection)(nil).EnsureDeviceAuthorization), ctx)
+}
+
 // ExchangeClientCredentials mocks base method.
 func (m *MockOAuth2Connection) ExchangeClientCredentials(ctx context.Context) error {
 	m.ctrl.T.Helper()
@@ -286,6 +301,20 @@ func (mr *MockOAuth2ConnectionMockRecorder) ExchangeClientCredentials(ctx interf
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExchangeClientCredentials", reflect.TypeOf((*MockOAuth2Connection)(nil).ExchangeClientCredentials), ctx)
 }
 
+// ExchangeJwtBearer mocks base method.
+func (m *MockOAuth2Connection) ExchangeJwtBearer(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExchangeJwtBearer", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ExchangeJwtBearer indicates an expected call of ExchangeJwtBearer.
+func (mr *MockOAuth2ConnectionMockRecorder) ExchangeJwtBearer(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExchangeJwtBearer", reflect.TypeOf((*MockOAuth2Connection)(nil).ExchangeJwtBearer), ctx)
+}
+
 // GenerateAuthUrl mocks base method.
 func (m *MockOAuth2Connection) GenerateAuthUrl(ctx context.Context, actor oauth2.IActorData) (string, error) {
 	m.ctrl.T.Helper()
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log/slog"
 
+	"github.com/rmorlok/authproxy/internal/apasynq"
 	"github.com/rmorlok/authproxy/internal/apctx"
 	"github.com/rmorlok/authproxy/internal/apredis"
 	"github.com/rmorlok/authproxy/internal/config"
@@ -24,13 +25,18 @@ type oAuth2Connection struct {
 	auth    *sconfig.AuthOAuth2
 	httpf   httpf.F
 
-	connector  coreIface.C
+	// core disconnects connections superseded under the OIDC merge policy.
+	core       coreIface.C
 	connection coreIface.Connection
 	state      *state
 
 	// tel is the OAuth2 lifecycle telemetry surface. May be nil — every
 	// telemetry method is nil-safe so call sites don't need to guard.
 	tel *telemetry
+
+	// ac enqueues the device_code poll task. Nil when the factory was built
+	// without WithTaskClient; only the device_code grant needs it.
+	ac apasynq.Client
 }
 
 var _ OAuth2Connection = (*oAuth2Connection)(nil)
@@ -0,0 +1,337 @@
+package oauth2
+
+import (
+	"context"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/redis/go-redis/v9"
+	"github.com/rmorlok/authproxy/internal/apctx"
+	"github.com/rmorlok/authproxy/internal/httpf"
+)
+
+// oidcClockSkew is the leeway applied to the id_token's exp, iat and nbf
+// claims to absorb clock drift between the proxy and the provider.
+const oidcClockSkew = time.Minute
+
+// oidcSigningAlgorithms are the id_token algorithms the proxy accepts. "none"
+// and the HMAC family are deliberately excluded: the former is unsigned and
+// the latter would be keyed with the client secret, which the JWKS can't
+// verify.
+var oidcSigningAlgorithms = []string{
+	"RS256", "RS384", "RS512",
+	"PS256", "PS384", "PS512",
+	"ES256", "ES384", "ES512",
+}
+
+// oidcProviderMetadata is the subset of the OpenID Provider discovery
+// document (OpenID Connect Discovery 1.0 §3) the proxy uses.
+type oidcProviderMetadata struct {
+	Issuer           string `json:"issuer"`
+	JwksUri          string `json:"jwks_uri"`
+	UserinfoEndpoint string `json:"userinfo_endpoint"`
+}
+
+// jsonWebKey is a single RFC 7517 key. Only the members needed to build RSA
+// and EC verification keys are decoded.
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+type jsonWebKeySet struct {
+	Keys []jsonWebKey `json:"keys"`
+}
+
+// resolveOidcProviderMetadata resolves the JWKS and userinfo endpoints for the
+// connector, applying the connector's overrides on top of the discovery
+// document. Discovery is skipped when the overrides supply everything needed.
+func (o *oAuth2Connection) resolveOidcProviderMetadata(ctx context.Context) (oidcProviderMetadata, error) {
+	oidc := o.auth.Oidc
+	meta := oidcProviderMetadata{
+		Issuer:           oidc.Issuer,
+		JwksUri:          oidc.JwksUri,
+		UserinfoEndpoint: oidc.UserinfoEndpoint,
+	}
+
+	if meta.JwksUri != "" && (!oidc.FetchUserinfo || meta.UserinfoEndpoint != "") {
+		return meta, nil
+	}
+
+	body, err := o.fetchOidcDocument(ctx, oidc.GetDiscoveryUrlOrDefault(), false)
+	if err != nil {
+		return oidcProviderMetadata{}, fmt.Errorf("failed to fetch oidc discovery document: %w", err)
+	}
+
+	var discovered oidcProviderMetadata
+	if err := json.Unmarshal(body, &discovered); err != nil {
+		return oidcProviderMetadata{}, fmt.Errorf("failed to parse oidc discovery document: %w", err)
+	}
+
+	// OpenID Connect Discovery 1.0 §4.3 — the issuer in the document must be
+	// identical to the one it was retrieved for.
+	if discovered.Issuer != oidc.Issuer {
+		return oidcProviderMetadata{}, fmt.Errorf("oidc discovery document issuer %q does not match configured issuer %q", discovered.Issuer, oidc.Issuer)
+	}
+
+	if meta.JwksUri == "" {
+		meta.JwksUri = discovered.JwksUri
+	}
+	if meta.UserinfoEndpoint == "" {
+		meta.UserinfoEndpoint = discovered.UserinfoEndpoint
+	}
+
+	if meta.JwksUri == "" {
+		return oidcProviderMetadata{}, errors.New("oidc discovery document has no jwks_uri")
+	}
+
+	return meta, nil
+}
+
+func getOidcDocumentRedisKey(documentUrl string) string {
+	// Hash the URL so arbitrary connector-supplied URLs produce bounded,
+	// well-formed keys.
+	sum := sha256.Sum256([]byte(documentUrl))
+	return fmt.Sprintf("oauth2:oidc:doc:%s", hex.EncodeToString(sum[:]))
+}
+
+// fetchOidcDocument GETs a discovery document or JWKS, serving it from the
+// Redis cache when present. Both are public, so they are cached unencrypted.
+// bypassCache forces a re-fetch, which refreshes the cached copy.
+func (o *oAuth2Connection) fetchOidcDocument(ctx context.Context, documentUrl string, bypassCache bool) ([]byte, error) {
+	key := getOidcDocumentRedisKey(documentUrl)
+
+	if !bypassCache {
+		cached, err := o.r.Get(ctx, key).Bytes()
+		if err == nil {
+			return cached, nil
+		}
+		if !errors.Is(err, redis.Nil) {
+			return nil, fmt.Errorf("failed to read cached oidc document: %w", err)
+		}
+	}
+
+	resp, err := o.httpf.
+		ForRequestType(httpf.RequestTypeOAuth).
+		ForConnection(o.connection).
+		New().
+		UseContext(ctx).
+		Request().
+		Method("GET").
+		URL(documentUrl).
+		SetHeader("Accept", "application/json").
+		Send()
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("received status code %d from %s", resp.StatusCode, documentUrl)
+	}
+
+	body := resp.Bytes()
+	if err := o.r.Set(ctx, key, body, o.auth.Oidc.GetJwksCacheTtlOrDefault()).Err(); err != nil {
+		return nil, fmt.Errorf("failed to cache oidc document: %w", err)
+	}
+
+	return body, nil
+}
+
+// oidcVerificationKey returns the JWKS key matching kid. A kid missing from
+// the cached key set re-fetches the set once, so rotated provider keys are
+// picked up without waiting for the cache to expire.
+func (o *oAuth2Connection) oidcVerificationKey(ctx context.Context, jwksUri, kid string) (any, error) {
+	for _, bypassCache := range []bool{false, true} {
+		body, err := o.fetchOidcDocument(ctx, jwksUri, bypassCache)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch jwks: %w", err)
+		}
+
+		var set jsonWebKeySet
+		if err := json.Unmarshal(body, &set); err != nil {
+			return nil, fmt.Errorf("failed to parse jwks: %w", err)
+		}
+
+		if jwk, ok := set.find(kid); ok {
+			return jwk.publicKey()
+		}
+	}
+
+	return nil, fmt.Errorf("no signing key with kid %q in jwks", kid)
+}
+
+// find returns the signing key with the given kid. An id_token without a kid
+// only matches when the set holds exactly one signing key.
+func (s jsonWebKeySet) find(kid string) (jsonWebKey, bool) {
+	var candidates []jsonWebKey
+	for _, k := range s.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		if kid != "" && k.Kid == kid {
+			return k, true
+		}
+		candidates = append(candidates, k)
+	}
+
+	if kid == "" && len(candidates) == 1 {
+		return candidates[0], true
+	}
+	return jsonWebKey{}, false
+}
+
+func decodeJwkInt(field, value string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil || len(b) == 0 {
+		return nil, fmt.Errorf("jwk has invalid %q", field)
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+// publicKey builds the verification key for an RSA or EC JWK (RFC 7518 §6).
+func (k jsonWebKey) publicKey() (any, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeJwkInt("n", k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeJwkInt("e", k.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, errors.New("jwk has invalid \"e\"")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		var ecdhCurve ecdh.Curve
+		switch k.Crv {
+		case "P-256":
+			curve, ecdhCurve = elliptic.P256(), ecdh.P256()
+		case "P-384":
+			curve, ecdhCurve = elliptic.P384(), ecdh.P384()
+		case "P-521":
+			curve, ecdhCurve = elliptic.P521(), ecdh.P521()
+		default:
+			return nil, fmt.Errorf("unsupported jwk curve %q", k.Crv)
+		}
+		x, err := decodeJwkInt("x", k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeJwkInt("y", k.Y)
+		if err != nil {
+			return nil, err
+		}
+
+		// Reject points that aren't on the curve before handing the key to
+		// the verifier; ecdh performs the check on the uncompressed encoding.
+		size := (curve.Params().BitSize + 7) / 8
+		if len(x.Bytes()) > size || len(y.Bytes()) > size {
+			return nil, errors.New("jwk point is not on the curve")
+		}
+		point := make([]byte, 1+2*size)
+		point[0] = 4
+		x.FillBytes(point[1 : 1+size])
+		y.FillBytes(point[1+size:])
+		if _, err := ecdhCurve.NewPublicKey(point); err != nil {
+			return nil, errors.New("jwk point is not on the curve")
+		}
+
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	default:
+		return nil, fmt.Errorf("unsupported jwk key type %q", k.Kty)
+	}
+}
+
+// validateIdToken verifies the id_token per OpenID Connect Core 1.0 §3.1.3.7:
+// signature against the provider's JWKS, iss, aud, azp, exp, iat and the
+// nonce sent on the authorization request.
+func (o *oAuth2Connection) validateIdToken(ctx context.Context, meta oidcProviderMetadata, rawIdToken, clientId, nonce string) (jwt.MapClaims, error) {
+	parser := jwt.NewParser(
+		jwt.WithValidMethods(oidcSigningAlgorithms),
+		jwt.WithIssuer(o.auth.Oidc.Issuer),
+		jwt.WithAudience(clientId),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(oidcClockSkew),
+		jwt.WithTimeFunc(apctx.GetClock(ctx).Now),
+	)
+
+	claims := jwt.MapClaims{}
+	_, err := parser.ParseWithClaims(rawIdToken, claims, func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+		return o.oidcVerificationKey(ctx, meta.JwksUri, kid)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid id_token: %w", err)
+	}
+
+	if sub, _ := claims["sub"].(string); sub == "" {
+		return nil, errors.New("invalid id_token: missing sub claim")
+	}
+
+	if nonce != "" {
+		if got, _ := claims["nonce"].(string); got != nonce {
+			return nil, errors.New("invalid id_token: nonce does not match authorization request")
+		}
+	}
+
+	aud, err := claims.GetAudience()
+	if err != nil {
+		return nil, fmt.Errorf("invalid id_token: %w", err)
+	}
+	azp, hasAzp := claims["azp"].(string)
+	if (len(aud) > 1 || hasAzp) && azp != clientId {
+		return nil, errors.New("invalid id_token: azp does not match client id")
+	}
+
+	return claims, nil
+}
+
+// fetchUserinfo calls the OIDC userinfo endpoint (OpenID Connect Core 1.0
+// §5.3) with the access token just issued.
+func (o *oAuth2Connection) fetchUserinfo(ctx context.Context, endpoint, accessToken string) (map[string]any, error) {
+	resp, err := o.httpf.
+		ForRequestType(httpf.RequestTypeOAuth).
+		ForConnection(o.connection).
+		New().
+		UseContext(ctx).
+		Request().
+		Method("GET").
+		URL(endpoint).
+		SetHeader("Accept", "application/json").
+		SetHeader("Authorization", "Bearer "+accessToken).
+		Send()
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch oidc userinfo: %w", err)
+	}
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("received status code %d from oidc userinfo endpoint", resp.StatusCode)
+	}
+
+	claims := map[string]any{}
+	if err := resp.JSON(&claims); err != nil {
+		return nil, fmt.Errorf("failed to parse oidc userinfo response: %w", err)
+	}
+	return claims, nil
+}
@@ -0,0 +1,243 @@
+package oauth2
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/rmorlok/authproxy/internal/apid"
+	coreIface "github.com/rmorlok/authproxy/internal/core/iface"
+	"github.com/rmorlok/authproxy/internal/database"
+	cschema "github.com/rmorlok/authproxy/internal/schema/resources/connectors"
+	"github.com/rmorlok/authproxy/internal/util/pagination"
+	"gopkg.in/h2non/gentleman.v2"
+)
+
+// System annotations recording the upstream account an OIDC connection is
+// connected to. They live in the reserved apxy/ namespace so users can't
+// set or spoof them through the annotations API.
+const (
+	OidcIssuerAnnotation  = "apxy/oidc/iss"
+	OidcSubjectAnnotation = "apxy/oidc/sub"
+	OidcEmailAnnotation   = "apxy/oidc/email"
+	OidcTenantAnnotation  = "apxy/oidc/tenant"
+	// OidcSupersededByAnnotation is set on connections disconnected by the
+	// merge duplicate-subject policy and holds the id of the connection that
+	// replaced them.
+	OidcSupersededByAnnotation = "apxy/oidc/superseded-by"
+)
+
+// errOidcDuplicateSubject is returned when the connector rejects duplicate
+// subjects and another connection is already connected to the account.
+var errOidcDuplicateSubject = errors.New("upstream account is already connected")
+
+// oidcIdentity is the connected upstream account, as asserted by the
+// validated id_token and, optionally, the userinfo endpoint.
+type oidcIdentity struct {
+	Issuer  string
+	Subject string
+	Email   string
+	Tenant  string
+}
+
+func (i oidcIdentity) annotations() map[string]string {
+	return map[string]string{
+		OidcIssuerAnnotation:  i.Issuer,
+		OidcSubjectAnnotation: i.Subject,
+		OidcEmailAnnotation:   i.Email,
+		OidcTenantAnnotation:  i.Tenant,
+	}
+}
+
+// stringClaim reads a claim that providers may encode as a string or a
+// number (e.g. numeric subject ids).
+func stringClaim(claims map[string]any, name string) string {
+	if name == "" {
+		return ""
+	}
+	switch v := claims[name].(type) {
+	case string:
+		return v
+	case float64:
+		return fmt.Sprintf("%.0f", v)
+	default:
+		return ""
+	}
+}
+
+// verifyOidcIdentity validates the id_token in the token response and
+// resolves the identity of the connected account, consulting the userinfo
+// endpoint when the connector asks for it.
+func (o *oAuth2Connection) verifyOidcIdentity(ctx context.Context, resp *gentleman.Response) (oidcIdentity, error) {
+	var tr tokenResponse
+	if err := resp.JSON(&tr); err != nil {
+		return oidcIdentity{}, fmt.Errorf("failed to parse token response: %w", err)
+	}
+	if tr.IdToken == "" {
+		return oidcIdentity{}, errors.New("token response has no id_token")
+	}
+
+	clientId, _, err := o.resolveClientCredentials(ctx)
+	if err != nil {
+		return oidcIdentity{}, err
+	}
+
+	meta, err := o.resolveOidcProviderMetadata(ctx)
+	if err != nil {
+		return oidcIdentity{}, err
+	}
+
+	nonce := ""
+	if o.state != nil {
+		nonce = o.state.OidcNonce
+	}
+
+	claims, err := o.validateIdToken(ctx, meta, tr.IdToken, clientId, nonce)
+	if err != nil {
+		return oidcIdentity{}, err
+	}
+
+	identity := oidcIdentity{
+		Issuer:  o.auth.Oidc.Issuer,
+		Subject: stringClaim(claims, "sub"),
+		Email:   stringClaim(claims, "email"),
+		Tenant:  stringClaim(claims, o.auth.Oidc.TenantClaim),
+	}
+
+	if o.auth.Oidc.FetchUserinfo {
+		if meta.UserinfoEndpoint == "" {
+			return oidcIdentity{}, errors.New("provider has no userinfo endpoint")
+		}
+
+		userinfo, err := o.fetchUserinfo(ctx, meta.UserinfoEndpoint, tr.AccessToken)
+		if err != nil {
+			return oidcIdentity{}, err
+		}
+
+		// OpenID Connect Core 1.0 §5.3.2 — the userinfo sub must match the
+		// id_token's, otherwise the response must not be used.
+		if sub := stringClaim(userinfo, "sub"); sub != identity.Subject {
+			return oidcIdentity{}, fmt.Errorf("userinfo sub %q does not match id_token sub %q", sub, identity.Subject)
+		}
+
+		if identity.Email == "" {
+			identity.Email = stringClaim(userinfo, "email")
+		}
+		if identity.Tenant == "" {
+			identity.Tenant = stringClaim(userinfo, o.auth.Oidc.TenantClaim)
+		}
+	}
+
+	return identity, nil
+}
+
+// findConnectionsForOidcSubject returns the other live connections for this
+// connector in the connection's namespace that are connected to the same
+// upstream account.
+func (o *oAuth2Connection) findConnectionsForOidcSubject(ctx context.Context, identity oidcIdentity) ([]apid.ID, error) {
+	var matches []apid.ID
+	err := o.db.ListConnectionsBuilder().
+		ForConnectorId(o.connection.GetConnectorId()).
+		ForNamespaceMatcher(o.connection.GetNamespace()).
+		ForStates([]database.ConnectionState{database.ConnectionStateSetup, database.ConnectionStateConfigured}).
+		WithDeletedHandling(database.DeletedHandlingExclude).
+		Enumerate(ctx, func(pr pagination.PageResult[database.Connection]) (pagination.KeepGoing, error) {
+			for _, conn := range pr.Results {
+				if conn.Id == o.connection.GetId() {
+					continue
+				}
+				if conn.Annotations[OidcIssuerAnnotation] == identity.Issuer &&
+					conn.Annotations[OidcSubjectAnnotation] == identity.Subject {
+					matches = append(matches, conn.Id)
+				}
+			}
+			return pagination.Continue, nil
+		})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list connections for oidc subject: %w", err)
+	}
+	return matches, nil
+}
+
+// recordOidcIdentity applies the connector's duplicate-subject policy and
+// stores the identity on the connection as system annotations. It runs
+// before the token is persisted so a rejected duplicate leaves no
+// credentials behind. Under the merge policy it returns the connections the
+// new one replaces; they are left untouched until the new connection's
+// credentials are established (see supersedeOidcConnections).
+func (o *oAuth2Connection) recordOidcIdentity(ctx context.Context, identity oidcIdentity) ([]apid.ID, error) {
+	var superseded []apid.ID
+	policy := o.auth.Oidc.GetDuplicateSubjectsOrDefault()
+	if policy != cschema.OidcDuplicateSubjectAllow {
+		duplicates, err := o.findConnectionsForOidcSubject(ctx, identity)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(duplicates) > 0 && policy == cschema.OidcDuplicateSubjectReject {
+			return nil, fmt.Errorf("%w: connection %s", errOidcDuplicateSubject, duplicates[0])
+		}
+
+		if policy == cschema.OidcDuplicateSubjectMerge {
+			superseded = duplicates
+		}
+	}
+
+	// Clear claims the provider no longer returns so a reconnect to a
+	// different account doesn't leave the previous account's email behind.
+	set := map[string]string{}
+	var stale []string
+	for k, v := range identity.annotations() {
+		if v != "" {
+			set[k] = v
+		} else if _, ok := o.connection.GetAnnotations()[k]; ok {
+			stale = append(stale, k)
+		}
+	}
+
+	if _, err := o.db.PutConnectionAnnotations(ctx, o.connection.GetId(), set); err != nil {
+		return nil, fmt.Errorf("failed to store oidc identity annotations: %w", err)
+	}
+	if len(stale) > 0 {
+		if _, err := o.db.DeleteConnectionAnnotations(ctx, o.connection.GetId(), stale); err != nil {
+			return nil, fmt.Errorf("failed to clear stale oidc identity annotations: %w", err)
+		}
+	}
+
+	return superseded, nil
+}
+
+// supersedeOidcConnections disconnects the connections replaced under the
+// merge policy, annotating each with the id of the connection that replaced
+// it. It runs once the new connection's credentials are stored, and goes
+// through the core disconnect so the old credentials are revoked. The new
+// connection is already usable at this point, so failures are logged rather
+// than failing the callback.
+func (o *oAuth2Connection) supersedeOidcConnections(ctx context.Context, ids []apid.ID) {
+	for _, id := range ids {
+		if _, err := o.db.PutConnectionAnnotations(ctx, id, map[string]string{
+			OidcSupersededByAnnotation: o.connection.GetId().String(),
+		}); err != nil {
+			o.logger.ErrorContext(ctx, "failed to annotate connection superseded by new connection for the same oidc subject",
+				"connection_id", id,
+				"superseded_by", o.connection.GetId(),
+				"error", err,
+			)
+			continue
+		}
+
+		if _, err := o.core.DisconnectConnection(ctx, id, coreIface.ConnectionDisconnectOptions{}); err != nil {
+			o.logger.ErrorContext(ctx, "failed to disconnect connection superseded by new connection for the same oidc subject",
+				"connection_id", id,
+				"superseded_by", o.connection.GetId(),
+				"error", err,
+			)
+			continue
+		}
+
+		o.logger.InfoContext(ctx, "disconnecting connection superseded by new connection for the same oidc subject",
+			"connection_id", id,
+			"superseded_by", o.connection.GetId(),
+		)
+	}
+}
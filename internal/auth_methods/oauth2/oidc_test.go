@@ -0,0 +1,486 @@
+package oauth2
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/golang/mock/gomock"
+	"github.com/rmorlok/authproxy/internal/apid"
+	"github.com/rmorlok/authproxy/internal/aplog"
+	"github.com/rmorlok/authproxy/internal/apredis"
+	"github.com/rmorlok/authproxy/internal/config"
+	coreIface "github.com/rmorlok/authproxy/internal/core/iface"
+	mockCore "github.com/rmorlok/authproxy/internal/core/mock"
+	"github.com/rmorlok/authproxy/internal/database"
+	"github.com/rmorlok/authproxy/internal/encrypt"
+	mockH "github.com/rmorlok/authproxy/internal/httpf/mock"
+	"github.com/rmorlok/authproxy/internal/schema/common"
+	sconfig "github.com/rmorlok/authproxy/internal/schema/config"
+	cschema "github.com/rmorlok/authproxy/internal/schema/resources/connectors"
+	"github.com/rmorlok/authproxy/internal/tasks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	genmock "gopkg.in/h2non/gentleman-mock.v2"
+)
+
+func rsaJwks(t *testing.T, kid string, key *rsa.PrivateKey) string {
+	t.Helper()
+	b, err := json.Marshal(jsonWebKeySet{Keys: []jsonWebKey{{
+		Kty: "RSA",
+		Kid: kid,
+		Use: "sig",
+		N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}}})
+	require.NoError(t, err)
+	return string(b)
+}
+
+func signIdToken(t *testing.T, method jwt.SigningMethod, kid string, key any, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	require.NoError(t, err)
+	return signed
+}
+
+// oidcConnFor builds an OIDC-enabled oAuth2Connection whose issuer and
+// endpoints live on host, backed by a real test database so annotations and
+// duplicate lookups exercise the actual queries.
+func oidcConnFor(t *testing.T, ctrl *gomock.Controller, host string) (*oAuth2Connection, database.DB) {
+	t.Helper()
+	_, r := apredis.MustApplyTestConfig(nil)
+	_, db := database.MustApplyBlankTestDbConfig(t, nil)
+
+	conn := &mockCore.Connection{
+		Id:          apid.New(apid.PrefixConnection),
+		Namespace:   "root",
+		ConnectorId: apid.New(apid.PrefixConnectorVersion),
+	}
+	require.NoError(t, db.CreateConnection(context.Background(), &database.Connection{
+		Id:               conn.Id,
+		Namespace:        conn.Namespace,
+		ConnectorId:      conn.ConnectorId,
+		ConnectorVersion: 1,
+		State:            database.ConnectionStateSetup,
+	}))
+
+	return &oAuth2Connection{
+		cfg: config.FromRoot(&sconfig.Root{
+			Public: sconfig.ServicePublic{
+				ServiceHttp: sconfig.ServiceHttp{
+					PortVal: common.NewIntegerValueDirect(8080),
+				},
+			},
+		}),
+		db:      db,
+		r:       r,
+		encrypt: encrypt.NewFakeEncryptService(false),
+		logger:  aplog.NewNoopLogger(),
+		httpf:   mockH.NewFactoryWithMockingClient(ctrl),
+		auth: &cschema.AuthOAuth2{
+			Type:         cschema.AuthTypeOAuth2,
+			ClientId:     common.NewStringValueDirect("client-id"),
+			ClientSecret: common.NewStringValueDirect("client-secret"),
+			Authorization: cschema.AuthOauth2Authorization{
+				Endpoint: host + "/authorize",
+			},
+			Token: cschema.AuthOauth2Token{
+				Endpoint: host + "/token",
+			},
+			Oidc: &cschema.AuthOauth2Oidc{
+				Issuer:  host,
+				JwksUri: host + "/jwks",
+			},
+			Scopes: []cschema.Scope{{Id: "openid"}, {Id: "email"}},
+		},
+		connection: conn,
+		state: &state{
+			Id:          apid.New(apid.PrefixOauth2State),
+			ReturnToUrl: "https://app.example.com/callback",
+			OidcNonce:   "nonce-1",
+		},
+	}, db
+}
+
+func validIdTokenClaims(issuer string) jwt.MapClaims {
+	now := time.Now()
+	return jwt.MapClaims{
+		"iss":   issuer,
+		"sub":   "user-123",
+		"aud":   "client-id",
+		"exp":   now.Add(time.Hour).Unix(),
+		"iat":   now.Unix(),
+		"nonce": "nonce-1",
+		"email": "user@example.com",
+	}
+}
+
+func TestValidateIdToken(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		mutate  func(c jwt.MapClaims)
+		wantErr string
+	}{
+		{name: "valid"},
+		{name: "wrong nonce", mutate: func(c jwt.MapClaims) { c["nonce"] = "other" }, wantErr: "nonce"},
+		{name: "missing nonce", mutate: func(c jwt.MapClaims) { delete(c, "nonce") }, wantErr: "nonce"},
+		{name: "wrong audience", mutate: func(c jwt.MapClaims) { c["aud"] = "other-client" }, wantErr: "aud"},
+		{name: "wrong issuer", mutate: func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" }, wantErr: "iss"},
+		{name: "expired", mutate: func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Hour).Unix() }, wantErr: "expired"},
+		{name: "missing sub", mutate: func(c jwt.MapClaims) { delete(c, "sub") }, wantErr: "sub"},
+		{
+			name:    "multiple audiences without azp",
+			mutate:  func(c jwt.MapClaims) { c["aud"] = []string{"client-id", "other-client"} },
+			wantErr: "azp",
+		},
+		{
+			name: "multiple audiences with azp",
+			mutate: func(c jwt.MapClaims) {
+				c["aud"] = []string{"client-id", "other-client"}
+				c["azp"] = "client-id"
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			host := "https://validate-idp.example.com"
+			o, _ := oidcConnFor(t, ctrl, host)
+
+			genmock.New(host).Get("/jwks").Reply(200).BodyString(rsaJwks(t, "k1", key))
+
+			claims := validIdTokenClaims(host)
+			if tt.mutate != nil {
+				tt.mutate(claims)
+			}
+			raw := signIdToken(t, jwt.SigningMethodRS256, "k1", key, claims)
+
+			meta, err := o.resolveOidcProviderMetadata(context.Background())
+			require.NoError(t, err)
+
+			got, err := o.validateIdToken(context.Background(), meta, raw, "client-id", "nonce-1")
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "user-123", got["sub"])
+		})
+	}
+
+	t.Run("rejects hmac signed tokens", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		host := "https://hmac-idp.example.com"
+		o, _ := oidcConnFor(t, ctrl, host)
+		raw := signIdToken(t, jwt.SigningMethodHS256, "k1", []byte("client-secret"), validIdTokenClaims(host))
+
+		_, err := o.validateIdToken(context.Background(), oidcProviderMetadata{JwksUri: host + "/jwks"}, raw, "client-id", "nonce-1")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "signing method")
+	})
+
+	t.Run("refetches jwks for an unknown kid", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		host := "https://rotate-idp.example.com"
+		o, _ := oidcConnFor(t, ctrl, host)
+		ctx := context.Background()
+
+		oldKey, err := rsa.GenerateKey(rand.Reader, 2048)
+		require.NoError(t, err)
+
+		// Prime the cache with the pre-rotation key set.
+		genmock.New(host).Get("/jwks").Reply(200).BodyString(rsaJwks(t, "old", oldKey))
+		_, err = o.oidcVerificationKey(ctx, host+"/jwks", "old")
+		require.NoError(t, err)
+
+		genmock.New(host).Get("/jwks").Reply(200).BodyString(rsaJwks(t, "new", key))
+		raw := signIdToken(t, jwt.SigningMethodRS256, "new", key, validIdTokenClaims(host))
+
+		_, err = o.validateIdToken(ctx, oidcProviderMetadata{JwksUri: host + "/jwks"}, raw, "client-id", "nonce-1")
+		require.NoError(t, err)
+	})
+}
+
+func TestJsonWebKey_EC(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	jwk := jsonWebKey{
+		Kty: "EC",
+		Crv: "P-256",
+		X:   base64.RawURLEncoding.EncodeToString(key.X.FillBytes(make([]byte, 32))),
+		Y:   base64.RawURLEncoding.EncodeToString(key.Y.FillBytes(make([]byte, 32))),
+	}
+
+	pub, err := jwk.publicKey()
+	require.NoError(t, err)
+	assert.True(t, key.PublicKey.Equal(pub))
+
+	// Flip the y coordinate so the point falls off the curve.
+	jwk.Y = base64.RawURLEncoding.EncodeToString(new(big.Int).Add(key.Y, big.NewInt(1)).FillBytes(make([]byte, 32)))
+	_, err = jwk.publicKey()
+	assert.ErrorContains(t, err, "not on the curve")
+
+	_, err = jsonWebKey{Kty: "oct"}.publicKey()
+	assert.ErrorContains(t, err, "unsupported jwk key type")
+}
+
+func TestCallback_Oidc(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	host := "https://callback-idp.example.com"
+	o, db := oidcConnFor(t, ctrl, host)
+	o.auth.Oidc.JwksUri = ""
+	o.auth.Oidc.FetchUserinfo = true
+	o.auth.Oidc.TenantClaim = "hd"
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	claims := validIdTokenClaims(host)
+	delete(claims, "email")
+	idToken := signIdToken(t, jwt.SigningMethodRS256, "k1", key, claims)
+
+	genmock.New(host).
+		Get("/.well-known/openid-configuration").
+		Reply(200).
+		BodyString(`{"issuer":"` + host + `","jwks_uri":"` + host + `/jwks","userinfo_endpoint":"` + host + `/userinfo"}`)
+	genmock.New(host).Get("/jwks").Reply(200).BodyString(rsaJwks(t, "k1", key))
+	genmock.New(host).
+		Post("/token").
+		Reply(200).
+		AddHeader("Content-Type", "application/json").
+		BodyString(`{"access_token":"a","expires_in":3600,"id_token":"` + idToken + `"}`)
+
+	var capturedAuth string
+	genmock.New(host).
+		Get("/userinfo").
+		AddMatcher(captureHeader("Authorization", &capturedAuth)).
+		Reply(200).
+		AddHeader("Content-Type", "application/json").
+		BodyString(`{"sub":"user-123","email":"user@example.com","hd":"example.com"}`)
+
+	_, err = o.CallbackFrom3rdParty(context.Background(), url.Values{"code": {"auth-code"}})
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer a", capturedAuth)
+
+	conn, err := db.GetConnection(context.Background(), o.connection.GetId())
+	require.NoError(t, err)
+	assert.Equal(t, host, conn.Annotations[OidcIssuerAnnotation])
+	assert.Equal(t, "user-123", conn.Annotations[OidcSubjectAnnotation])
+	assert.Equal(t, "user@example.com", conn.Annotations[OidcEmailAnnotation])
+	assert.Equal(t, "example.com", conn.Annotations[OidcTenantAnnotation])
+}
+
+func TestCallback_OidcMissingIdToken(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	host := "https://missing-idp.example.com"
+	o, _ := oidcConnFor(t, ctrl, host)
+
+	genmock.New(host).
+		Post("/token").
+		Reply(200).
+		AddHeader("Content-Type", "application/json").
+		BodyString(`{"access_token":"a","expires_in":3600}`)
+
+	_, err := o.CallbackFrom3rdParty(context.Background(), url.Values{"code": {"auth-code"}})
+	require.NoError(t, err, "failures are recorded on the connection, not returned")
+
+	mc := o.connection.(*mockCore.Connection)
+	require.NotNil(t, mc.SetupError)
+	assert.Contains(t, *mc.SetupError, "no id_token")
+}
+
+// disconnectRecordingCore is a coreIface.C that records the connections
+// disconnected through it.
+type disconnectRecordingCore struct {
+	coreIface.C
+	disconnected []apid.ID
+}
+
+func (c *disconnectRecordingCore) DisconnectConnection(_ context.Context, id apid.ID, _ coreIface.ConnectionDisconnectOptions) (*tasks.TaskInfo, error) {
+	c.disconnected = append(c.disconnected, id)
+	return &tasks.TaskInfo{}, nil
+}
+
+func TestRecordOidcIdentity_DuplicateSubjects(t *testing.T) {
+	identity := oidcIdentity{
+		Issuer:  "https://dup-idp.example.com",
+		Subject: "user-123",
+		Email:   "user@example.com",
+	}
+
+	// seedExisting creates another connection for the same connector and
+	// namespace that is already connected to identity.
+	seedExisting := func(t *testing.T, o *oAuth2Connection, db database.DB) apid.ID {
+		id := apid.New(apid.PrefixConnection)
+		require.NoError(t, db.CreateConnection(context.Background(), &database.Connection{
+			Id:               id,
+			Namespace:        o.connection.GetNamespace(),
+			ConnectorId:      o.connection.GetConnectorId(),
+			ConnectorVersion: 1,
+			State:            database.ConnectionStateConfigured,
+			Annotations:      identity.annotations(),
+		}))
+		return id
+	}
+
+	t.Run("allow", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		o, db := oidcConnFor(t, ctrl, identity.Issuer)
+		existing := seedExisting(t, o, db)
+
+		superseded, err := o.recordOidcIdentity(context.Background(), identity)
+		require.NoError(t, err)
+		assert.Empty(t, superseded)
+
+		conn, err := db.GetConnection(context.Background(), existing)
+		require.NoError(t, err)
+		assert.Equal(t, database.ConnectionStateConfigured, conn.State)
+	})
+
+	t.Run("reject", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		o, db := oidcConnFor(t, ctrl, identity.Issuer)
+		o.auth.Oidc.DuplicateSubjects = &[]cschema.OidcDuplicateSubjectPolicy{cschema.OidcDuplicateSubjectReject}[0]
+		seedExisting(t, o, db)
+
+		_, err := o.recordOidcIdentity(context.Background(), identity)
+		require.ErrorIs(t, err, errOidcDuplicateSubject)
+
+		conn, err := db.GetConnection(context.Background(), o.connection.GetId())
+		require.NoError(t, err)
+		assert.NotContains(t, conn.Annotations, OidcSubjectAnnotation, "a rejected duplicate must not record the identity")
+	})
+
+	t.Run("reject ignores other subjects and namespaces", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		o, db := oidcConnFor(t, ctrl, identity.Issuer)
+		o.auth.Oidc.DuplicateSubjects = &[]cschema.OidcDuplicateSubjectPolicy{cschema.OidcDuplicateSubjectReject}[0]
+		require.NoError(t, db.CreateConnection(context.Background(), &database.Connection{
+			Id:               apid.New(apid.PrefixConnection),
+			Namespace:        "root.other",
+			ConnectorId:      o.connection.GetConnectorId(),
+			ConnectorVersion: 1,
+			State:            database.ConnectionStateConfigured,
+			Annotations:      identity.annotations(),
+		}))
+
+		_, err := o.recordOidcIdentity(context.Background(), identity)
+		require.NoError(t, err)
+		_, err = o.recordOidcIdentity(context.Background(), identity)
+		require.NoError(t, err, "reconnecting the same connection is not a duplicate")
+	})
+
+	t.Run("merge", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		o, db := oidcConnFor(t, ctrl, identity.Issuer)
+		o.auth.Oidc.DuplicateSubjects = &[]cschema.OidcDuplicateSubjectPolicy{cschema.OidcDuplicateSubjectMerge}[0]
+		existing := seedExisting(t, o, db)
+		core := &disconnectRecordingCore{}
+		o.core = core
+
+		superseded, err := o.recordOidcIdentity(context.Background(), identity)
+		require.NoError(t, err)
+		assert.Equal(t, []apid.ID{existing}, superseded)
+
+		old, err := db.GetConnection(context.Background(), existing)
+		require.NoError(t, err)
+		assert.Equal(t, database.ConnectionStateConfigured, old.State, "the old connection is left alone until the new one has credentials")
+		assert.NotContains(t, old.Annotations, OidcSupersededByAnnotation)
+
+		conn, err := db.GetConnection(context.Background(), o.connection.GetId())
+		require.NoError(t, err)
+		assert.Equal(t, "user-123", conn.Annotations[OidcSubjectAnnotation])
+
+		o.supersedeOidcConnections(context.Background(), superseded)
+		assert.Equal(t, []apid.ID{existing}, core.disconnected)
+
+		old, err = db.GetConnection(context.Background(), existing)
+		require.NoError(t, err)
+		assert.Equal(t, o.connection.GetId().String(), old.Annotations[OidcSupersededByAnnotation])
+	})
+}
+
+func TestAuthOAuth2Validate_Oidc(t *testing.T) {
+	vc := &common.ValidationContext{}
+	valid := func() *cschema.AuthOAuth2 {
+		return &cschema.AuthOAuth2{
+			Type:         cschema.AuthTypeOAuth2,
+			ClientId:     common.NewStringValueDirect("client-id"),
+			ClientSecret: common.NewStringValueDirect("client-secret"),
+			Authorization: cschema.AuthOauth2Authorization{
+				Endpoint: "https://example.com/authorize",
+			},
+			Token: cschema.AuthOauth2Token{
+				Endpoint: "https://example.com/token",
+			},
+			Oidc:   &cschema.AuthOauth2Oidc{Issuer: "https://example.com"},
+			Scopes: []cschema.Scope{{Id: "openid"}},
+		}
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		assert.NoError(t, valid().Validate(vc))
+	})
+
+	t.Run("requires issuer", func(t *testing.T) {
+		auth := valid()
+		auth.Oidc.Issuer = ""
+		assert.ErrorContains(t, auth.Validate(vc), "issuer")
+	})
+
+	t.Run("requires openid scope", func(t *testing.T) {
+		auth := valid()
+		auth.Scopes = []cschema.Scope{{Id: "email"}}
+		assert.ErrorContains(t, auth.Validate(vc), "openid")
+	})
+
+	t.Run("rejects unknown duplicate policy", func(t *testing.T) {
+		auth := valid()
+		auth.Oidc.DuplicateSubjects = &[]cschema.OidcDuplicateSubjectPolicy{"replace"}[0]
+		assert.ErrorContains(t, auth.Validate(vc), "duplicate_subjects")
+	})
+
+	t.Run("rejects oidc for other grants", func(t *testing.T) {
+		auth := deviceCodeAuth()
+		auth.Oidc = &cschema.AuthOauth2Oidc{Issuer: "https://example.com"}
+		assert.ErrorContains(t, auth.Validate(vc), "must be omitted unless grant_type")
+	})
+}
@@ -105,11 +105,6 @@ func (o *oAuth2Connection) refreshAccessTokenInner(ctx context.Context, token *d
 		return nil, o.classifyAndRecordRefreshFailure(ctx, tokenRefreshNoRefreshToken, 0, "", 0, errNoRefreshToken)
 	}
 
-	clientId, clientSecret, err := o.resolveClientCredentials(ctx)
-	if err != nil {
-		return nil, o.classifyAndRecordRefreshFailure(ctx, tokenRefreshInternalError, 0, "", 0, err)
-	}
-
 	client := o.httpf.
 		ForRequestType(httpf.RequestTypeOAuth).
 		ForConnection(o.connection).
@@ -120,35 +115,45 @@ func (o *oAuth2Connection) refreshAccessTokenInner(ctx context.Context, token *d
 			fmt.Errorf("failed to render token endpoint template: %w", err))
 	}
 
-	values := url.Values{}
+	var values url.Values
+	var authHeader string
 	persistOptions := tokenPersistOptions{PersistRefreshToken: true}
 	if o.auth.SupportsRefreshToken() {
+		clientId, clientSecret, err := o.resolveClientCredentials(ctx)
+		if err != nil {
+			return nil, o.classifyAndRecordRefreshFailure(ctx, tokenRefreshInternalError, 0, "", 0, err)
+		}
+
 		refreshToken, err := o.encrypt.DecryptString(ctx, token.EncryptedRefreshToken)
 		if err != nil {
 			return nil, o.classifyAndRecordRefreshFailure(ctx, tokenRefreshInternalError, 0, "", 0, err)
 		}
-		values.Set("grant_type", "refresh_token")
-		values.Set("refresh_token", refreshToken)
+
+		values, authHeader, err = applyTokenEndpointClientAuth(
+			o.auth.GetTokenEndpointAuthMethodOrDefault(), clientId, clientSecret, url.Values{
+				"grant_type":    {"refresh_token"},
+				"refresh_token": {refreshToken},
+			},
+		)
+		if err != nil {
+			return nil, o.classifyAndRecordRefreshFailure(ctx, tokenRefreshInternalError, 0, "", 0, err)
+		}
 	} else {
-		values.Set("grant_type", "client_credentials")
-		effectiveScopes, err := o.effectiveScopes(ctx)
+		// client_credentials and jwt-bearer have no refresh token; the
+		// original grant is repeated (with a freshly minted assertion for
+		// jwt-bearer) to obtain a new access token.
+		values, err = o.serviceGrantValues(ctx, tokenEndpoint)
 		if err != nil {
-			return nil, o.classifyAndRecordRefreshFailure(ctx, tokenRefreshInternalError, 0, "", 0,
-				fmt.Errorf("failed to resolve oauth2 scopes: %w", err))
+			return nil, o.classifyAndRecordRefreshFailure(ctx, tokenRefreshInternalError, 0, "", 0, err)
 		}
-		if scopeString := JoinScopes(effectiveScopes); scopeString != "" {
-			values.Set("scope", scopeString)
+
+		values, authHeader, err = o.applyServiceGrantClientAuth(ctx, values)
+		if err != nil {
+			return nil, o.classifyAndRecordRefreshFailure(ctx, tokenRefreshInternalError, 0, "", 0, err)
 		}
 		persistOptions.PersistRefreshToken = false
 	}
 
-	values, authHeader, err := applyTokenEndpointClientAuth(
-		o.auth.GetTokenEndpointAuthMethodOrDefault(), clientId, clientSecret, values,
-	)
-	if err != nil {
-		return nil, o.classifyAndRecordRefreshFailure(ctx, tokenRefreshInternalError, 0, "", 0, err)
-	}
-
 	refreshResp, attempts, err := o.postRefreshWithRetry(ctx, client, tokenEndpoint, values, authHeader)
 	if err != nil {
 		// Transport-layer failure — provider never produced a status code.
@@ -2,6 +2,8 @@ package oauth2
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -44,6 +46,11 @@ type state struct {
 	// connector at callback time) means a connector reconfig mid-flow
 	// can't silently break the exchange.
 	PKCEMethod sconfig.PKCEMethod `json:"pkceMethod,omitempty"`
+
+	// OidcNonce is sent as the OIDC `nonce` authorization parameter and must
+	// come back in the id_token's nonce claim, binding the token to this
+	// flow. Empty when the connector doesn't enable OIDC.
+	OidcNonce string `json:"oidcNonce,omitempty"`
 }
 
 func (s *state) IsValid() bool {
@@ -137,6 +144,14 @@ func (o *oAuth2Connection) saveStateToRedis(ctx context.Context, actor IActorDat
 		s.PKCEMethod = o.auth.Authorization.PKCE.GetMethodOrDefault()
 	}
 
+	if o.auth != nil && o.auth.Oidc != nil {
+		nonce := make([]byte, 16)
+		if _, err := rand.Read(nonce); err != nil {
+			return fmt.Errorf("failed to generate oidc nonce for connection %s: %w", o.connection.GetId(), err)
+		}
+		s.OidcNonce = hex.EncodeToString(nonce)
+	}
+
 	if err := writeStateToRedis(ctx, o.r, o.encrypt, s, ttl); err != nil {
 		return fmt.Errorf("failed to set state in redis for connection %s: %w", o.connection.GetId(), err)
 	}
@@ -280,6 +295,7 @@ func getOAuth2State(
 	}
 
 	o := newOAuth2(cfg, db, r, encrypt, logger, httpf, connection)
+	o.core = core
 	o.state = s
 
 	return o, nil
@@ -0,0 +1,48 @@
+package oauth2
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hibiken/asynq"
+	"github.com/rmorlok/authproxy/internal/apid"
+)
+
+const taskTypePollDeviceToken = "oauth2:poll_device_token"
+
+func newPollDeviceTokenTask(connectionId apid.ID, nonce string) (*asynq.Task, error) {
+	payload, err := json.Marshal(pollDeviceTokenTaskPayload{connectionId, nonce})
+	if err != nil {
+		return nil, err
+	}
+	return asynq.NewTask(taskTypePollDeviceToken, payload), nil
+}
+
+type pollDeviceTokenTaskPayload struct {
+	ConnectionId apid.ID `json:"connectionId"`
+	Nonce        string  `json:"nonce"`
+}
+
+func (th *taskHandler) pollDeviceToken(ctx context.Context, t *asynq.Task) error {
+	var p pollDeviceTokenTaskPayload
+	if err := json.Unmarshal(t.Payload(), &p); err != nil {
+		return fmt.Errorf("%s json.Unmarshal failed: %v: %w", taskTypePollDeviceToken, err, asynq.SkipRetry)
+	}
+
+	if p.ConnectionId == apid.Nil {
+		return fmt.Errorf("%s connection id not specified: %w", taskTypePollDeviceToken, asynq.SkipRetry)
+	}
+
+	connection, err := th.core.GetConnection(ctx, p.ConnectionId)
+	if err != nil {
+		return fmt.Errorf("failed to load connection: %v", err)
+	}
+
+	if connection == nil {
+		return fmt.Errorf("connection not found: %w", asynq.SkipRetry)
+	}
+
+	o2 := th.factory.NewOAuth2(connection).(*oAuth2Connection)
+	return o2.pollDeviceToken(ctx, p.Nonce)
+}
@@ -50,13 +50,14 @@ func NewTaskHandler(
 		httpf:   httpf,
 		encrypt: encrypt,
 		logger:  logger,
-		factory: NewFactory(cfg, db, redis, c, httpf, encrypt, logger, factoryOpts...),
+		factory: NewFactory(cfg, db, redis, c, httpf, encrypt, logger, append(factoryOpts, WithTaskClient(ac))...),
 	}
 }
 
 func (th *taskHandler) RegisterTasks(mux *asynq.ServeMux) {
 	mux.HandleFunc(taskTypeRefreshExpiringOAuthTokens, th.refreshExpiringOauth2Tokens)
 	mux.HandleFunc(taskTypeRefreshOAuthToken, th.refreshOauth2Token)
+	mux.HandleFunc(taskTypePollDeviceToken, th.pollDeviceToken)
 }
 
 func (th *taskHandler) GetCronTasks() []*asynq.PeriodicTaskConfig {
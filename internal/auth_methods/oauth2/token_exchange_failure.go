@@ -57,6 +57,18 @@ const (
 	// body could not be parsed as a token response, or the parsed response
 	// was missing access_token.
 	tokenExchangeMalformedResponse tokenExchangeCategory = "malformed_response"
+	// tokenExchangeExpiredToken — the device_code expired before the user
+	// approved it (RFC 8628 §3.5). The user has to restart setup for a new
+	// user code.
+	tokenExchangeExpiredToken tokenExchangeCategory = "expired_token"
+	// tokenExchangeInvalidIdToken — OIDC is enabled and the id_token was
+	// missing or failed validation (signature, issuer, audience, nonce,
+	// expiry), or the userinfo subject didn't match it.
+	tokenExchangeInvalidIdToken tokenExchangeCategory = "invalid_id_token"
+	// tokenExchangeDuplicateSubject — the connector rejects duplicate
+	// subjects and another connection in the namespace is already connected
+	// to the same upstream account.
+	tokenExchangeDuplicateSubject tokenExchangeCategory = "duplicate_subject"
 	// tokenExchangeStateCleanupError — failed to delete the consumed state
 	// from Redis prior to exchange. Defensive: we abort the exchange to
 	// avoid minting a token against a state we couldn't invalidate.
@@ -163,6 +175,10 @@ func classifyTokenEndpointStatus(statusCode int, body []byte) (tokenExchangeCate
 			return tokenExchangeUnsupportedGrantType, parsed.Error
 		case "invalid_scope":
 			return tokenExchangeInvalidScope, parsed.Error
+		case "expired_token":
+			return tokenExchangeExpiredToken, parsed.Error
+		case "access_denied":
+			return tokenExchangeProviderDenied, parsed.Error
 		}
 		return tokenExchangeProvider4xxOther, parsed.Error
 	}
@@ -21,6 +21,8 @@ type tokenResponse struct {
 	ExpiresIn    *int   `json:"expires_in"`
 	RefreshToken string `json:"refresh_token"`
 	Scope        string `json:"scope"`
+	// IdToken is only present for OpenID Connect flows.
+	IdToken string `json:"id_token"`
 }
 
 type tokenPersistOptions struct {
@@ -75,7 +77,9 @@ func (o *oAuth2Connection) createDbTokenFromResponseWithOptions(
 	} else if opts.PersistRefreshToken && refreshFrom != nil {
 		encryptedRefreshToken = refreshFrom.EncryptedRefreshToken
 	} else if jsonResp.RefreshToken != "" && o.logger != nil {
-		o.logger.WarnContext(ctx, "oauth token response included refresh_token for client_credentials grant; discarding")
+		o.logger.WarnContext(ctx, "oauth token response included refresh_token for a grant without refresh tokens; discarding",
+			"grant_type", string(o.auth.GetGrantTypeOrDefault()),
+		)
 	}
 
 	scopes := requestedScopes
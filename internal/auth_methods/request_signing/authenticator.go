@@ -0,0 +1,41 @@
+package request_signing
+
+import (
+	"context"
+
+	"github.com/rmorlok/authproxy/internal/auth_methods"
+)
+
+// Resolve returns an empty AuthApplication. A request signature depends on
+// the final method, URL, headers and body, so all of the work happens in
+// SignRequest, which the orchestrator calls once the request is fully built.
+func (c *requestSigningConnection) Resolve(ctx context.Context) (auth_methods.AuthApplication, error) {
+	return auth_methods.AuthApplication{}, nil
+}
+
+// RecoverFrom401 returns ErrCannotRecover — signing keys are static secrets
+// with no automated replacement path. The orchestrator surfaces the upstream
+// 401 unchanged.
+func (c *requestSigningConnection) RecoverFrom401(ctx context.Context) error {
+	return auth_methods.ErrCannotRecover
+}
+
+// Refresh is a no-op for request-signing connections; there is nothing to
+// refresh.
+func (c *requestSigningConnection) Refresh(ctx context.Context) error {
+	return nil
+}
+
+// SupportsRevoke returns false — signing keys are issued and revoked in the
+// provider's console, not via an API call from AuthProxy.
+func (c *requestSigningConnection) SupportsRevoke() bool {
+	return false
+}
+
+// Revoke is a no-op for request-signing connections. See SupportsRevoke.
+func (c *requestSigningConnection) Revoke(ctx context.Context) error {
+	return nil
+}
+
+var _ auth_methods.Authenticator = (*requestSigningConnection)(nil)
+var _ auth_methods.RequestSigner = (*requestSigningConnection)(nil)
@@ -0,0 +1,38 @@
+package request_signing
+
+import (
+	"log/slog"
+
+	"github.com/rmorlok/authproxy/internal/auth_methods"
+	coreIface "github.com/rmorlok/authproxy/internal/core/iface"
+	"github.com/rmorlok/authproxy/internal/database"
+	"github.com/rmorlok/authproxy/internal/encrypt"
+)
+
+type factory struct {
+	db      database.DB
+	encrypt encrypt.E
+	logger  *slog.Logger
+}
+
+// NewFactory constructs a request-signing authenticator factory. The factory
+// is owned by the core service and shared across all request-signing
+// connections.
+func NewFactory(db database.DB, encrypt encrypt.E, logger *slog.Logger) Factory {
+	return &factory{
+		db:      db,
+		encrypt: encrypt,
+		logger:  logger,
+	}
+}
+
+var _ auth_methods.Factory = (*factory)(nil)
+
+func (f *factory) NewAuthenticator(connection coreIface.Connection) auth_methods.Authenticator {
+	return &requestSigningConnection{
+		db:         f.db,
+		encrypt:    f.encrypt,
+		logger:     f.logger,
+		connection: connection,
+	}
+}
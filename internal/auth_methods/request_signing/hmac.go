@@ -0,0 +1,126 @@
+package request_signing
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rmorlok/authproxy/internal/aptmpl"
+	"github.com/rmorlok/authproxy/internal/auth_methods"
+	"github.com/rmorlok/authproxy/internal/database"
+	cschema "github.com/rmorlok/authproxy/internal/schema/resources/connectors"
+)
+
+// signHmac renders the connector's canonical string for req, computes the
+// HMAC over it with the connection's secret, and sets the signature header.
+// The timestamp and key id headers, when configured, are set before the
+// canonical string is rendered so templates may reference them through
+// headers.<name> as well as the dedicated variables.
+func signHmac(
+	req *http.Request,
+	payload auth_methods.SigningPayload,
+	cfg *cschema.RequestSigningHmac,
+	creds database.RequestSigningCredentialPlaintext,
+	now time.Time,
+) error {
+	if creds.Secret == "" {
+		return errors.New("hmac credential is missing its secret")
+	}
+
+	newHash, err := hmacHashFunc(cfg.Algorithm)
+	if err != nil {
+		return err
+	}
+
+	timestamp := formatHmacTimestamp(cfg.TimestampFormat, now)
+	if cfg.TimestampHeader != "" {
+		req.Header.Set(cfg.TimestampHeader, timestamp)
+	}
+	if cfg.KeyIdHeader != "" {
+		req.Header.Set(cfg.KeyIdHeader, creds.KeyId)
+	}
+
+	canonical, err := aptmpl.RenderMustacheRaw(cfg.CanonicalString, canonicalStringData(req, payload, timestamp, creds.KeyId))
+	if err != nil {
+		return fmt.Errorf("failed to render hmac canonical string: %w", err)
+	}
+
+	mac := hmac.New(newHash, []byte(creds.Secret))
+	mac.Write([]byte(canonical))
+	sum := mac.Sum(nil)
+
+	var signature string
+	switch cfg.Encoding {
+	case cschema.HmacSignatureEncodingBase64:
+		signature = base64.StdEncoding.EncodeToString(sum)
+	default:
+		signature = hex.EncodeToString(sum)
+	}
+
+	req.Header.Set(cfg.SignatureHeader, cfg.SignaturePrefix+signature)
+	return nil
+}
+
+// canonicalStringData builds the mustache context for the canonical string.
+// See cschema.HmacCanonicalStringVariables for the contract.
+func canonicalStringData(req *http.Request, payload auth_methods.SigningPayload, timestamp, keyId string) map[string]any {
+	headers := make(map[string]any, len(req.Header))
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.Join(values, ",")
+	}
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+
+	return map[string]any{
+		"method":      strings.ToUpper(req.Method),
+		"host":        host,
+		"path":        path,
+		"query":       req.URL.RawQuery,
+		"timestamp":   timestamp,
+		"body_sha256": payloadHash(payload),
+		"key_id":      keyId,
+		"headers":     headers,
+	}
+}
+
+func hmacHashFunc(algorithm cschema.HmacAlgorithm) (func() hash.Hash, error) {
+	switch algorithm {
+	case "", cschema.HmacAlgorithmSHA256:
+		return sha256.New, nil
+	case cschema.HmacAlgorithmSHA1:
+		return sha1.New, nil
+	case cschema.HmacAlgorithmSHA512:
+		return sha512.New, nil
+	default:
+		return nil, fmt.Errorf("unsupported hmac algorithm %q", algorithm)
+	}
+}
+
+func formatHmacTimestamp(format cschema.HmacTimestampFormat, now time.Time) string {
+	switch format {
+	case cschema.HmacTimestampFormatUnixMillis:
+		return strconv.FormatInt(now.UnixMilli(), 10)
+	case cschema.HmacTimestampFormatRFC3339:
+		return now.UTC().Format(time.RFC3339)
+	default:
+		return strconv.FormatInt(now.Unix(), 10)
+	}
+}
@@ -0,0 +1,31 @@
+package request_signing
+
+import (
+	"context"
+
+	"github.com/rmorlok/authproxy/internal/auth_methods"
+	coreIface "github.com/rmorlok/authproxy/internal/core/iface"
+	cschema "github.com/rmorlok/authproxy/internal/schema/resources/connectors"
+)
+
+// Factory builds the Authenticator for a request-signing connection and owns
+// the persistence of the signing keys submitted during setup. One factory per
+// core service, shared across all request-signing connections.
+type Factory interface {
+	NewAuthenticator(connection coreIface.Connection) auth_methods.Authenticator
+	ManifestSetupSteps(connection coreIface.Connection, connector *cschema.Connector) []coreIface.ManifestSetupStep
+
+	// PersistCredentials extracts the signing key fields from credData (the
+	// validated form payload from a credentials-phase submit), encrypts them
+	// as a single JSON blob, and inserts the row into connection_credentials.
+	// Which fields are required depends on the connector's signing mode.
+	//
+	// Returns an *httperr.Err on user-visible errors (e.g. missing required
+	// field), which is the contract the HTTP route relies on.
+	PersistCredentials(
+		ctx context.Context,
+		connection coreIface.Connection,
+		auth *cschema.AuthRequestSigning,
+		credData map[string]any,
+	) error
+}
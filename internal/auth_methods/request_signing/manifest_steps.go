@@ -0,0 +1,48 @@
+package request_signing
+
+import (
+	"context"
+	"encoding/json"
+
+	coreIface "github.com/rmorlok/authproxy/internal/core/iface"
+	"github.com/rmorlok/authproxy/internal/httperr"
+	cschema "github.com/rmorlok/authproxy/internal/schema/resources/connectors"
+)
+
+// ManifestSetupSteps returns the single form step request-signing contributes
+// to a connection's setup flow: a credential-collection form synthesized from
+// the connector's signing mode. The OnSubmit closure validates the submitted
+// form data against the synthesized JSON Schema and persists the resulting
+// credential via the factory.
+//
+// Returns nil if the connector is not a request-signing connector or has no
+// signing mode configured.
+func (f *factory) ManifestSetupSteps(connection coreIface.Connection, connector *cschema.Connector) []coreIface.ManifestSetupStep {
+	if connector == nil || connector.Auth == nil {
+		return nil
+	}
+	auth, ok := connector.Auth.Inner().(*cschema.AuthRequestSigning)
+	if !ok {
+		return nil
+	}
+	spec := synthesizeCredentialsStep(auth)
+	if spec == nil {
+		return nil
+	}
+	return []coreIface.ManifestSetupStep{
+		coreIface.NewFormStep(coreIface.FormStepConfig{
+			Id:          spec.Id,
+			Title:       spec.Title,
+			Description: spec.Description,
+			JsonSchema:  json.RawMessage(spec.JsonSchema),
+			UiSchema:    json.RawMessage(spec.UiSchema),
+			OnSubmit: func(ctx context.Context, data json.RawMessage) error {
+				credData, err := spec.ValidateAndMergeData(spec.Id, data, nil)
+				if err != nil {
+					return httperr.BadRequest(err.Error())
+				}
+				return f.PersistCredentials(ctx, connection, auth, credData)
+			},
+		}),
+	}
+}
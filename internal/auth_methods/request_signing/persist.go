@@ -0,0 +1,82 @@
+package request_signing
+
+import (
+	"context"
+	"encoding/json"
+
+	apauthcore "github.com/rmorlok/authproxy/internal/apauth/core"
+	coreIface "github.com/rmorlok/authproxy/internal/core/iface"
+	"github.com/rmorlok/authproxy/internal/database"
+	"github.com/rmorlok/authproxy/internal/httperr"
+	cschema "github.com/rmorlok/authproxy/internal/schema/resources/connectors"
+)
+
+// PersistCredentials extracts the signing key fields from credData, encrypts
+// them as a single JSON blob, and inserts a fresh row into
+// connection_credentials. InsertApiKeyCredential soft-deletes any active row
+// in the same transaction, so this is the rotation path as well as the
+// initial-set path. Request-signing rows carry no placement snapshot; the
+// signing configuration is read from the connector at signing time.
+func (f *factory) PersistCredentials(
+	ctx context.Context,
+	connection coreIface.Connection,
+	auth *cschema.AuthRequestSigning,
+	credData map[string]any,
+) error {
+	if auth == nil {
+		return httperr.InternalServerErrorMsg("request-signing connector missing auth at credential submission time")
+	}
+
+	str := func(name string) string {
+		v, _ := credData[name].(string)
+		return v
+	}
+
+	plaintext := database.RequestSigningCredentialPlaintext{}
+	switch {
+	case auth.SigV4 != nil:
+		plaintext.AwsAccessKeyId = str(fieldAwsAccessKeyId)
+		plaintext.AwsSecretAccessKey = str(fieldAwsSecretAccessKey)
+		plaintext.AwsSessionToken = str(fieldAwsSessionToken)
+		if plaintext.AwsAccessKeyId == "" {
+			return httperr.BadRequestf("%s is required", fieldAwsAccessKeyId)
+		}
+		if plaintext.AwsSecretAccessKey == "" {
+			return httperr.BadRequestf("%s is required", fieldAwsSecretAccessKey)
+		}
+		if auth.SigV4.Region == "" {
+			plaintext.Region = str(fieldRegion)
+			if plaintext.Region == "" {
+				return httperr.BadRequestf("%s is required", fieldRegion)
+			}
+		}
+	case auth.Hmac != nil:
+		plaintext.Secret = str(fieldSecret)
+		if plaintext.Secret == "" {
+			return httperr.BadRequestf("%s is required", fieldSecret)
+		}
+		if auth.Hmac.UsesKeyId() {
+			plaintext.KeyId = str(fieldKeyId)
+			if plaintext.KeyId == "" {
+				return httperr.BadRequestf("%s is required", fieldKeyId)
+			}
+		}
+	default:
+		return httperr.InternalServerErrorMsg("request-signing connector has no signing mode at credential submission time")
+	}
+
+	blobJSON, err := json.Marshal(plaintext)
+	if err != nil {
+		return httperr.InternalServerError(httperr.WithInternalErrorf("failed to marshal request-signing plaintext: %w", err))
+	}
+	encrypted, err := f.encrypt.EncryptStringForNamespace(ctx, connection.GetNamespace(), string(blobJSON))
+	if err != nil {
+		return httperr.InternalServerError(httperr.WithInternalErrorf("failed to encrypt request-signing credentials: %w", err))
+	}
+
+	actorId := apauthcore.GetAuthFromContext(ctx).MustGetActor().GetId()
+	if _, err := f.db.InsertApiKeyCredential(ctx, connection.GetId(), encrypted, nil, &actorId); err != nil {
+		return httperr.InternalServerError(httperr.WithInternalErrorf("failed to persist request-signing credentials: %w", err))
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+package request_signing
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	apauthcore "github.com/rmorlok/authproxy/internal/apauth/core"
+	"github.com/rmorlok/authproxy/internal/apid"
+	"github.com/rmorlok/authproxy/internal/aplog"
+	mockCore "github.com/rmorlok/authproxy/internal/core/mock"
+	"github.com/rmorlok/authproxy/internal/database"
+	mockDb "github.com/rmorlok/authproxy/internal/database/mock"
+	"github.com/rmorlok/authproxy/internal/encfield"
+	"github.com/rmorlok/authproxy/internal/encrypt"
+	"github.com/rmorlok/authproxy/internal/httperr"
+	cschema "github.com/rmorlok/authproxy/internal/schema/resources/connectors"
+)
+
+func newTestFactory(t *testing.T, ctrl *gomock.Controller) (*factory, *mockDb.MockDB) {
+	t.Helper()
+	db := mockDb.NewMockDB(ctrl)
+	return &factory{
+		db:      db,
+		encrypt: encrypt.NewFakeEncryptService(false),
+		logger:  aplog.NewNoopLogger(),
+	}, db
+}
+
+func ctxWithActor(t *testing.T) (context.Context, apid.ID) {
+	t.Helper()
+	actorId := apid.MustParse("act_test1111111111aa")
+	ra := apauthcore.NewAuthenticatedRequestAuth(&apauthcore.Actor{
+		Id:        actorId,
+		Namespace: "root",
+	})
+	return ra.ContextWith(context.Background()), actorId
+}
+
+type captureEncField struct{ field encfield.EncryptedField }
+
+func (c *captureEncField) Matches(x any) bool {
+	v, ok := x.(encfield.EncryptedField)
+	if !ok {
+		return false
+	}
+	c.field = v
+	return true
+}
+func (c *captureEncField) String() string { return "captured encfield.EncryptedField" }
+
+func TestPersistCredentials_SigV4(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	f, db := newTestFactory(t, ctrl)
+	conn := &mockCore.Connection{Id: apid.New(apid.PrefixConnection), Namespace: "root"}
+	auth := &cschema.AuthRequestSigning{
+		Type:  cschema.AuthTypeRequestSigning,
+		SigV4: &cschema.RequestSigningSigV4{Service: "execute-api"},
+	}
+
+	cap := &captureEncField{}
+	ctx, actorId := ctxWithActor(t)
+	db.EXPECT().
+		InsertApiKeyCredential(gomock.Any(), conn.Id, cap, nil, &actorId).
+		Return(&database.ApiKeyCredential{Id: apid.New(apid.PrefixApiKeyCredential)}, nil)
+
+	require.NoError(t, f.PersistCredentials(ctx, conn, auth, map[string]any{
+		fieldAwsAccessKeyId:     "AKID",
+		fieldAwsSecretAccessKey: "secret",
+		fieldRegion:             "us-west-2",
+	}))
+
+	var plaintext database.RequestSigningCredentialPlaintext
+	require.NoError(t, json.Unmarshal([]byte(cap.field.Data), &plaintext))
+	assert.Equal(t, database.RequestSigningCredentialPlaintext{
+		AwsAccessKeyId:     "AKID",
+		AwsSecretAccessKey: "secret",
+		Region:             "us-west-2",
+	}, plaintext)
+}
+
+func TestPersistCredentials_Validation(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	f, _ := newTestFactory(t, ctrl)
+	conn := &mockCore.Connection{Id: apid.New(apid.PrefixConnection), Namespace: "root"}
+	ctx, _ := ctxWithActor(t)
+
+	tests := []struct {
+		name     string
+		auth     *cschema.AuthRequestSigning
+		credData map[string]any
+	}{
+		{
+			name:     "sigv4 missing region when connector does not pin one",
+			auth:     &cschema.AuthRequestSigning{SigV4: &cschema.RequestSigningSigV4{Service: "s3"}},
+			credData: map[string]any{fieldAwsAccessKeyId: "AKID", fieldAwsSecretAccessKey: "secret"},
+		},
+		{
+			name:     "sigv4 missing secret",
+			auth:     &cschema.AuthRequestSigning{SigV4: &cschema.RequestSigningSigV4{Service: "s3", Region: "us-east-1"}},
+			credData: map[string]any{fieldAwsAccessKeyId: "AKID"},
+		},
+		{
+			name:     "hmac missing key id when connector sends one",
+			auth:     &cschema.AuthRequestSigning{Hmac: &cschema.RequestSigningHmac{CanonicalString: "{{method}}", SignatureHeader: "X-Sig", KeyIdHeader: "X-Key"}},
+			credData: map[string]any{fieldSecret: "shh"},
+		},
+		{
+			name:     "hmac missing secret",
+			auth:     &cschema.AuthRequestSigning{Hmac: &cschema.RequestSigningHmac{CanonicalString: "{{method}}", SignatureHeader: "X-Sig"}},
+			credData: map[string]any{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := f.PersistCredentials(ctx, conn, tt.auth, tt.credData)
+			require.Error(t, err)
+			var herr *httperr.Error
+			require.ErrorAs(t, err, &herr)
+			assert.Equal(t, 400, herr.Status)
+		})
+	}
+}
+
+func TestSynthesizeCredentialsStep(t *testing.T) {
+	fields := func(t *testing.T, auth *cschema.AuthRequestSigning) ([]string, map[string]any) {
+		t.Helper()
+		spec := synthesizeCredentialsStep(auth)
+		require.NotNil(t, spec)
+		var js struct {
+			Required   []string       `json:"required"`
+			Properties map[string]any `json:"properties"`
+		}
+		require.NoError(t, json.Unmarshal(spec.JsonSchema, &js))
+		return js.Required, js.Properties
+	}
+
+	t.Run("sigv4 without region", func(t *testing.T) {
+		required, props := fields(t, &cschema.AuthRequestSigning{SigV4: &cschema.RequestSigningSigV4{Service: "s3"}})
+		assert.Equal(t, []string{fieldAwsAccessKeyId, fieldAwsSecretAccessKey, fieldRegion}, required)
+		assert.Contains(t, props, fieldAwsSessionToken)
+	})
+
+	t.Run("sigv4 with region", func(t *testing.T) {
+		required, props := fields(t, &cschema.AuthRequestSigning{SigV4: &cschema.RequestSigningSigV4{Service: "s3", Region: "us-east-1"}})
+		assert.Equal(t, []string{fieldAwsAccessKeyId, fieldAwsSecretAccessKey}, required)
+		assert.NotContains(t, props, fieldRegion)
+	})
+
+	t.Run("hmac with key id", func(t *testing.T) {
+		required, _ := fields(t, &cschema.AuthRequestSigning{Hmac: &cschema.RequestSigningHmac{CanonicalString: "{{key_id}}"}})
+		assert.Equal(t, []string{fieldKeyId, fieldSecret}, required)
+	})
+
+	t.Run("no mode", func(t *testing.T) {
+		assert.Nil(t, synthesizeCredentialsStep(&cschema.AuthRequestSigning{}))
+	})
+}
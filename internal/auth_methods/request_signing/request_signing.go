@@ -0,0 +1,16 @@
+package request_signing
+
+import (
+	"log/slog"
+
+	coreIface "github.com/rmorlok/authproxy/internal/core/iface"
+	"github.com/rmorlok/authproxy/internal/database"
+	"github.com/rmorlok/authproxy/internal/encrypt"
+)
+
+type requestSigningConnection struct {
+	db         database.DB
+	encrypt    encrypt.E
+	logger     *slog.Logger
+	connection coreIface.Connection
+}
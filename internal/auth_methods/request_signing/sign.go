@@ -0,0 +1,86 @@
+package request_signing
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/rmorlok/authproxy/internal/apctx"
+	"github.com/rmorlok/authproxy/internal/auth_methods"
+	"github.com/rmorlok/authproxy/internal/database"
+	cschema "github.com/rmorlok/authproxy/internal/schema/resources/connectors"
+)
+
+// SignRequest signs req according to the connector's signing mode using the
+// connection's active credential. The credential is loaded fresh on every
+// call so a rotation takes effect on the next request; the signing
+// configuration is read from the connector definition the connection is
+// pinned to.
+func (c *requestSigningConnection) SignRequest(ctx context.Context, req *http.Request, payload auth_methods.SigningPayload) error {
+	auth, err := c.signingConfig()
+	if err != nil {
+		return err
+	}
+
+	creds, err := c.loadCredentials(ctx)
+	if err != nil {
+		return err
+	}
+
+	now := apctx.GetClock(ctx).Now().UTC()
+	switch {
+	case auth.SigV4 != nil:
+		return signSigV4(ctx, req, payload, auth.SigV4, creds, now)
+	case auth.Hmac != nil:
+		return signHmac(req, payload, auth.Hmac, creds, now)
+	default:
+		return errors.New("request-signing connector has no signing mode configured")
+	}
+}
+
+func (c *requestSigningConnection) signingConfig() (*cschema.AuthRequestSigning, error) {
+	connector := c.connection.GetConnector()
+	if connector == nil {
+		return nil, errors.New("request-signing connection has no connector")
+	}
+	def := connector.GetDefinition()
+	if def == nil || def.Auth == nil {
+		return nil, errors.New("request-signing connector has no auth definition")
+	}
+	auth, ok := def.Auth.Inner().(*cschema.AuthRequestSigning)
+	if !ok {
+		return nil, fmt.Errorf("connector auth type %q is not %q", def.Auth.GetType(), cschema.AuthTypeRequestSigning)
+	}
+	return auth, nil
+}
+
+func (c *requestSigningConnection) loadCredentials(ctx context.Context) (database.RequestSigningCredentialPlaintext, error) {
+	cred, err := c.db.GetActiveApiKeyCredential(ctx, c.connection.GetId())
+	if err != nil {
+		return database.RequestSigningCredentialPlaintext{}, fmt.Errorf("failed to load request-signing credential: %w", err)
+	}
+
+	decrypted, err := c.encrypt.DecryptString(ctx, cred.EncryptedCredentials)
+	if err != nil {
+		return database.RequestSigningCredentialPlaintext{}, fmt.Errorf("failed to decrypt request-signing credential: %w", err)
+	}
+	var plaintext database.RequestSigningCredentialPlaintext
+	if err := json.Unmarshal([]byte(decrypted), &plaintext); err != nil {
+		return database.RequestSigningCredentialPlaintext{}, fmt.Errorf("failed to unmarshal request-signing plaintext: %w", err)
+	}
+	return plaintext, nil
+}
+
+// payloadHash returns the hex SHA-256 of the payload body, or
+// UNSIGNED-PAYLOAD when the body is streamed.
+func payloadHash(payload auth_methods.SigningPayload) string {
+	if payload.Unsigned {
+		return auth_methods.UnsignedPayload
+	}
+	sum := sha256.Sum256(payload.Body)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,194 @@
+package request_signing
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/rmorlok/authproxy/internal/apctx"
+	"github.com/rmorlok/authproxy/internal/apid"
+	"github.com/rmorlok/authproxy/internal/aplog"
+	"github.com/rmorlok/authproxy/internal/auth_methods"
+	coreIface "github.com/rmorlok/authproxy/internal/core/iface"
+	mockCore "github.com/rmorlok/authproxy/internal/core/mock"
+	"github.com/rmorlok/authproxy/internal/database"
+	mockDb "github.com/rmorlok/authproxy/internal/database/mock"
+	"github.com/rmorlok/authproxy/internal/encfield"
+	"github.com/rmorlok/authproxy/internal/encrypt"
+	cschema "github.com/rmorlok/authproxy/internal/schema/resources/connectors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var signingTime = time.Date(2026, 3, 14, 15, 9, 26, 0, time.UTC)
+
+// connectionWithConnector overrides the mock connection's nil GetConnector
+// so SignRequest can read the signing configuration.
+type connectionWithConnector struct {
+	*mockCore.Connection
+	connector *mockCore.Connector
+}
+
+func (c *connectionWithConnector) GetConnector() coreIface.Connector {
+	return c.connector
+}
+
+func newTestSigningConn(t *testing.T, ctrl *gomock.Controller, auth *cschema.AuthRequestSigning, credJSON string) *requestSigningConnection {
+	t.Helper()
+	connectionId := apid.New(apid.PrefixConnection)
+	db := mockDb.NewMockDB(ctrl)
+	db.EXPECT().GetActiveApiKeyCredential(gomock.Any(), connectionId).Return(&database.ApiKeyCredential{
+		Id:                   apid.New(apid.PrefixApiKeyCredential),
+		ConnectionId:         connectionId,
+		EncryptedCredentials: encfield.EncryptedField{ID: "dek_fake", Data: credJSON},
+	}, nil).AnyTimes()
+
+	return &requestSigningConnection{
+		db:      db,
+		encrypt: encrypt.NewFakeEncryptService(false),
+		logger:  aplog.NewNoopLogger(),
+		connection: &connectionWithConnector{
+			Connection: &mockCore.Connection{Id: connectionId, Namespace: "root"},
+			connector: &mockCore.Connector{
+				Definition: &cschema.Connector{Auth: &cschema.Auth{InnerVal: auth}},
+			},
+		},
+	}
+}
+
+func TestSignRequest_SigV4(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	conn := newTestSigningConn(t, ctrl,
+		&cschema.AuthRequestSigning{
+			Type:  cschema.AuthTypeRequestSigning,
+			SigV4: &cschema.RequestSigningSigV4{Service: "execute-api"},
+		},
+		`{"awsAccessKeyId":"AKIDEXAMPLE","awsSecretAccessKey":"secret","awsSessionToken":"session","region":"eu-west-1"}`,
+	)
+	ctx := apctx.WithFixedClock(context.Background(), signingTime)
+
+	t.Run("buffered body", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, "https://abc123.execute-api.eu-west-1.amazonaws.com/prod/items", strings.NewReader(`{"a":1}`))
+		require.NoError(t, err)
+
+		require.NoError(t, conn.SignRequest(ctx, req, auth_methods.SigningPayload{Body: []byte(`{"a":1}`)}))
+
+		sum := sha256.Sum256([]byte(`{"a":1}`))
+		assert.Equal(t, hex.EncodeToString(sum[:]), req.Header.Get("X-Amz-Content-Sha256"))
+		assert.Equal(t, "20260314T150926Z", req.Header.Get("X-Amz-Date"))
+		assert.Equal(t, "session", req.Header.Get("X-Amz-Security-Token"))
+		assert.True(t, strings.HasPrefix(
+			req.Header.Get("Authorization"),
+			"AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20260314/eu-west-1/execute-api/aws4_request, SignedHeaders=",
+		), req.Header.Get("Authorization"))
+	})
+
+	t.Run("streamed body", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPut, "https://abc123.execute-api.eu-west-1.amazonaws.com/prod/upload", strings.NewReader("stream"))
+		require.NoError(t, err)
+
+		require.NoError(t, conn.SignRequest(ctx, req, auth_methods.SigningPayload{Unsigned: true}))
+		assert.Equal(t, auth_methods.UnsignedPayload, req.Header.Get("X-Amz-Content-Sha256"))
+		assert.Contains(t, req.Header.Get("Authorization"), "x-amz-content-sha256")
+	})
+}
+
+func TestSignRequest_SigV4ConnectorRegionWins(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	conn := newTestSigningConn(t, ctrl,
+		&cschema.AuthRequestSigning{
+			Type:  cschema.AuthTypeRequestSigning,
+			SigV4: &cschema.RequestSigningSigV4{Service: "s3", Region: "us-east-2"},
+		},
+		`{"awsAccessKeyId":"AKIDEXAMPLE","awsSecretAccessKey":"secret"}`,
+	)
+	ctx := apctx.WithFixedClock(context.Background(), signingTime)
+
+	req, err := http.NewRequest(http.MethodGet, "https://bucket.s3.us-east-2.amazonaws.com/key", nil)
+	require.NoError(t, err)
+	require.NoError(t, conn.SignRequest(ctx, req, auth_methods.SigningPayload{}))
+	assert.Contains(t, req.Header.Get("Authorization"), "/20260314/us-east-2/s3/aws4_request")
+	assert.Empty(t, req.Header.Get("X-Amz-Security-Token"))
+}
+
+func TestSignRequest_Hmac(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	conn := newTestSigningConn(t, ctrl,
+		&cschema.AuthRequestSigning{
+			Type: cschema.AuthTypeRequestSigning,
+			Hmac: &cschema.RequestSigningHmac{
+				CanonicalString: "{{method}}\n{{path}}\n{{query}}\n{{timestamp}}\n{{headers.content-type}}\n{{body_sha256}}",
+				SignatureHeader: "X-Signature",
+				TimestampHeader: "X-Timestamp",
+				KeyIdHeader:     "X-Key-Id",
+			},
+		},
+		`{"keyId":"key-1","secret":"shh"}`,
+	)
+	ctx := apctx.WithFixedClock(context.Background(), signingTime)
+
+	req, err := http.NewRequest(http.MethodPost, "https://api.example.com/v1/orders?a=1&b=%3Cx%3E", strings.NewReader("body"))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+
+	require.NoError(t, conn.SignRequest(ctx, req, auth_methods.SigningPayload{Body: []byte("body")}))
+
+	bodySum := sha256.Sum256([]byte("body"))
+	canonical := "POST\n/v1/orders\na=1&b=%3Cx%3E\n1773500966\napplication/json\n" + hex.EncodeToString(bodySum[:])
+	mac := hmac.New(sha256.New, []byte("shh"))
+	mac.Write([]byte(canonical))
+
+	assert.Equal(t, hex.EncodeToString(mac.Sum(nil)), req.Header.Get("X-Signature"))
+	assert.Equal(t, "1773500966", req.Header.Get("X-Timestamp"))
+	assert.Equal(t, "key-1", req.Header.Get("X-Key-Id"))
+}
+
+func TestSignHmac_Options(t *testing.T) {
+	req, err := http.NewRequest(http.MethodGet, "https://api.example.com", nil)
+	require.NoError(t, err)
+
+	require.NoError(t, signHmac(req, auth_methods.SigningPayload{Unsigned: true}, &cschema.RequestSigningHmac{
+		Algorithm:       cschema.HmacAlgorithmSHA1,
+		CanonicalString: "{{key_id}}:{{method}}:{{path}}:{{timestamp}}:{{body_sha256}}",
+		SignatureHeader: "Authorization",
+		SignaturePrefix: "HMAC ",
+		Encoding:        cschema.HmacSignatureEncodingBase64,
+		TimestampFormat: cschema.HmacTimestampFormatRFC3339,
+	}, database.RequestSigningCredentialPlaintext{KeyId: "k", Secret: "shh"}, signingTime))
+
+	mac := hmac.New(sha1.New, []byte("shh"))
+	mac.Write([]byte("k:GET:/:2026-03-14T15:09:26Z:UNSIGNED-PAYLOAD"))
+	assert.Equal(t, "HMAC "+base64.StdEncoding.EncodeToString(mac.Sum(nil)), req.Header.Get("Authorization"))
+}
+
+func TestSignRequest_MissingCredential(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	conn := newTestSigningConn(t, ctrl,
+		&cschema.AuthRequestSigning{
+			Type: cschema.AuthTypeRequestSigning,
+			Hmac: &cschema.RequestSigningHmac{CanonicalString: "{{method}}", SignatureHeader: "X-Signature"},
+		},
+		`{}`,
+	)
+
+	req, err := http.NewRequest(http.MethodGet, "https://api.example.com", nil)
+	require.NoError(t, err)
+	require.Error(t, conn.SignRequest(context.Background(), req, auth_methods.SigningPayload{}))
+	assert.Empty(t, req.Header.Get("X-Signature"))
+}
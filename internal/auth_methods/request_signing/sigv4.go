@@ -0,0 +1,65 @@
+package request_signing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/rmorlok/authproxy/internal/auth_methods"
+	"github.com/rmorlok/authproxy/internal/database"
+	cschema "github.com/rmorlok/authproxy/internal/schema/resources/connectors"
+)
+
+// sigV4ContentSha256Header carries the payload hash. S3 requires it on every
+// request and it is how UNSIGNED-PAYLOAD is communicated; other services
+// accept it as an ordinary signed header.
+const sigV4ContentSha256Header = "X-Amz-Content-Sha256"
+
+// signSigV4 applies an AWS Signature Version 4 Authorization header (plus
+// X-Amz-Date and, for temporary credentials, X-Amz-Security-Token) to req.
+// The region comes from the connector when it pins one, otherwise from the
+// value captured during setup.
+func signSigV4(
+	ctx context.Context,
+	req *http.Request,
+	payload auth_methods.SigningPayload,
+	cfg *cschema.RequestSigningSigV4,
+	creds database.RequestSigningCredentialPlaintext,
+	now time.Time,
+) error {
+	region := cfg.Region
+	if region == "" {
+		region = creds.Region
+	}
+	if region == "" {
+		return errors.New("sigv4 signing requires a region")
+	}
+	if creds.AwsAccessKeyId == "" || creds.AwsSecretAccessKey == "" {
+		return errors.New("sigv4 credential is missing its access key")
+	}
+
+	hash := payloadHash(payload)
+	req.Header.Set(sigV4ContentSha256Header, hash)
+
+	return v4.NewSigner().SignHTTP(
+		ctx,
+		aws.Credentials{
+			AccessKeyID:     creds.AwsAccessKeyId,
+			SecretAccessKey: creds.AwsSecretAccessKey,
+			SessionToken:    creds.AwsSessionToken,
+		},
+		req,
+		hash,
+		cfg.Service,
+		region,
+		now,
+		func(o *v4.SignerOptions) {
+			// S3 signs the path exactly as sent; every other service expects
+			// it escaped a second time.
+			o.DisableURIPathEscaping = cfg.Service == "s3"
+		},
+	)
+}
@@ -0,0 +1,104 @@
+package request_signing
+
+import (
+	"encoding/json"
+
+	"github.com/rmorlok/authproxy/internal/schema/common"
+	"github.com/rmorlok/authproxy/internal/schema/common/json_schema"
+	"github.com/rmorlok/authproxy/internal/schema/common/ui_schema"
+	cschema "github.com/rmorlok/authproxy/internal/schema/resources/connectors"
+)
+
+// SynthesizedRequestSigningCredentialsStepId is the canonical id assigned to
+// the auto-generated credentials step for request-signing connectors.
+const SynthesizedRequestSigningCredentialsStepId = "_authproxy_request_signing_credentials"
+
+// Field names in the synthesized credentials form.
+const (
+	fieldAwsAccessKeyId     = "aws_access_key_id"
+	fieldAwsSecretAccessKey = "aws_secret_access_key"
+	fieldAwsSessionToken    = "aws_session_token"
+	fieldRegion             = "region"
+	fieldKeyId              = "key_id"
+	fieldSecret             = "secret"
+)
+
+// synthesizeCredentialsStep builds the credential-collection form spec for a
+// request-signing connector. Like the api-key step, it is materialized at
+// runtime by ManifestSetupSteps and never stored on the connector.
+//
+// SigV4 connectors ask for an access key id, secret access key, an optional
+// session token, and the region when the connector doesn't pin one. HMAC
+// connectors ask for the shared secret, plus a key id when the connector
+// sends or signs one. Secret values use password inputs.
+//
+// Returns nil if auth is nil or configures neither mode.
+func synthesizeCredentialsStep(auth *cschema.AuthRequestSigning) *cschema.SetupFlowStep {
+	if auth == nil {
+		return nil
+	}
+
+	js := json_schema.Schema{
+		Type:                 "object",
+		Required:             []string{},
+		Properties:           map[string]json_schema.Property{},
+		AdditionalProperties: false,
+	}
+	ui := ui_schema.Schema{Type: "VerticalLayout", Elements: []ui_schema.Control{}}
+
+	addField := func(name, title string, required, secret bool) {
+		prop := json_schema.Property{Type: "string", Title: title}
+		if required {
+			js.Required = append(js.Required, name)
+			prop.MinLength = 1
+		}
+		js.Properties[name] = prop
+
+		control := ui_schema.Control{Type: "Control", Scope: "#/properties/" + name}
+		if secret {
+			control.Options = map[string]string{"format": "password"}
+		}
+		ui.Elements = append(ui.Elements, control)
+	}
+
+	var title, description string
+	switch {
+	case auth.SigV4 != nil:
+		title = "Enter your AWS credentials"
+		description = "Provide the AWS access key used to sign requests to this service."
+		addField(fieldAwsAccessKeyId, "Access Key ID", true, false)
+		addField(fieldAwsSecretAccessKey, "Secret Access Key", true, true)
+		addField(fieldAwsSessionToken, "Session Token", false, true)
+		if auth.SigV4.Region == "" {
+			addField(fieldRegion, "Region", true, false)
+		}
+	case auth.Hmac != nil:
+		title = "Enter your signing key"
+		description = "Provide the shared secret used to sign requests to this service."
+		if auth.Hmac.UsesKeyId() {
+			addField(fieldKeyId, "Key ID", true, false)
+		}
+		addField(fieldSecret, "Secret", true, true)
+	default:
+		return nil
+	}
+
+	jsBytes, err := json.Marshal(js)
+	if err != nil {
+		// json.Marshal on these inline types cannot fail; if it ever does, panic
+		// is appropriate since this is config-load-time code with no recovery.
+		panic("request_signing: failed to marshal synthesized json_schema: " + err.Error())
+	}
+	uiBytes, err := json.Marshal(ui)
+	if err != nil {
+		panic("request_signing: failed to marshal synthesized ui_schema: " + err.Error())
+	}
+
+	return &cschema.SetupFlowStep{
+		Id:          SynthesizedRequestSigningCredentialsStepId,
+		Title:       title,
+		Description: description,
+		JsonSchema:  common.RawJSON(jsBytes),
+		UiSchema:    common.RawJSON(uiBytes),
+	}
+}
@@ -0,0 +1,39 @@
+package auth_methods
+
+import (
+	"context"
+	"net/http"
+)
+
+// UnsignedPayload is the payload hash used in place of a body digest when the
+// body is streamed to the upstream and can't be hashed before the request is
+// sent. The value is the AWS SigV4 convention; HMAC signers use the same
+// literal so connector authors only have one marker to reason about.
+const UnsignedPayload = "UNSIGNED-PAYLOAD"
+
+// SigningPayload describes the body of the request handed to
+// RequestSigner.SignRequest.
+type SigningPayload struct {
+	// Body is the fully-buffered request body. Nil for requests without a
+	// body and for unsigned payloads.
+	Body []byte
+
+	// Unsigned is set when the body is streamed and its bytes are not
+	// available at signing time. Signers must sign UnsignedPayload in
+	// place of a body digest.
+	Unsigned bool
+}
+
+// RequestSigner is implemented by Authenticators whose credential is a
+// signature over the outbound request (method, URL, headers, body) rather
+// than a value that can be computed up front. The orchestrator type-asserts
+// for it and, when present, calls SignRequest after the AuthApplication from
+// Resolve has been applied — immediately before the request is handed to
+// the httpf client. It runs once per attempt, so the retry-after-recover
+// path gets a fresh signature and timestamp.
+//
+// SignRequest mutates req in place (typically by setting headers). It must
+// not consume req.Body; the body bytes, when available, are in payload.
+type RequestSigner interface {
+	SignRequest(ctx context.Context, req *http.Request, payload SigningPayload) error
+}
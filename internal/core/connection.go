@@ -141,7 +141,7 @@ func (c *connection) GetJavascriptContext(ctx context.Context) (apjs.Context, er
 			"labels":      labels,
 			"annotations": annotations,
 		},
-	), nil
+	).WithBudget(c.connector.javascriptBudget()), nil
 }
 
 func (c *connection) Logger() *slog.Logger {
@@ -284,6 +284,16 @@ func (c *connection) GetRateLimitConfig() *connectors.RateLimiting {
 	return def.RateLimiting
 }
 
+// GetResponseCacheConfig returns the connector's response cache configuration, or nil when its responses are not
+// cached.
+func (c *connection) GetResponseCacheConfig() *connectors.ResponseCache {
+	def := c.connector.GetDefinition()
+	if def == nil {
+		return nil
+	}
+	return def.ResponseCache
+}
+
 // PropagateTraceContext returns the per-connector override for outbound W3C
 // trace context injection. nil means "use the global default" from the
 // telemetry config block.
@@ -299,3 +309,4 @@ var _ iface.Connection = (*connection)(nil)
 var _ aplog.HasLogger = (*connection)(nil)
 var _ httpf.RateLimitConfigProvider = (*connection)(nil)
 var _ httpf.TracePropagationProvider = (*connection)(nil)
+var _ httpf.ClientCertificateProvider = (*connection)(nil)
@@ -0,0 +1,342 @@
+package core
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	apauthcore "github.com/rmorlok/authproxy/internal/apauth/core"
+	"github.com/rmorlok/authproxy/internal/apctx"
+	"github.com/rmorlok/authproxy/internal/core/iface"
+	"github.com/rmorlok/authproxy/internal/database"
+	"github.com/rmorlok/authproxy/internal/httperr"
+	aschema "github.com/rmorlok/authproxy/internal/schema/auth"
+	"github.com/rmorlok/authproxy/internal/schema/common"
+	"github.com/rmorlok/authproxy/internal/schema/common/json_schema"
+	"github.com/rmorlok/authproxy/internal/schema/common/ui_schema"
+	cschema "github.com/rmorlok/authproxy/internal/schema/resources/connectors"
+)
+
+// ClientCertificateStepId is the manifest id for the synthesized form that
+// collects a connection's TLS client certificate when the connector's
+// transport.clientCertificate leaves the pair to be uploaded.
+const ClientCertificateStepId = "apxy:transport:client_certificate"
+
+const attrClientCertificate = "certificate"
+const attrClientCertificatePrivateKey = "private_key"
+
+// getClientCertificateConfig returns the connector's client certificate
+// configuration, or nil if the connector doesn't use mutual TLS.
+func getClientCertificateConfig(c iface.Connection) *cschema.TransportClientCertificate {
+	connector := c.GetConnector()
+	if connector == nil {
+		return nil
+	}
+	def := connector.GetDefinition()
+	if def == nil || def.Transport == nil {
+		return nil
+	}
+	return def.Transport.ClientCertificate
+}
+
+// RequiresClientCertificate reports whether outbound calls for this
+// connection must present a TLS client certificate.
+func (c *connection) RequiresClientCertificate() bool {
+	return getClientCertificateConfig(c) != nil
+}
+
+// GetClientCertificate resolves the connection's TLS client certificate from
+// the connector's key data or, when the pair is uploaded per connection, from
+// connection_client_certificates. It is called during the TLS handshake, so
+// rotated certificates are picked up by new upstream connections.
+func (c *connection) GetClientCertificate(ctx context.Context) (*tls.Certificate, error) {
+	cc := getClientCertificateConfig(c)
+	if cc == nil {
+		return nil, errors.New("connector does not configure a client certificate")
+	}
+
+	var pair database.ClientCertificatePlaintext
+	if cc.IsUploaded() {
+		row, err := c.s.db.GetActiveConnectionClientCertificate(ctx, c.Id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		plaintextJSON, err := c.s.encrypt.DecryptString(ctx, row.EncryptedCertificate)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt client certificate: %w", err)
+		}
+		if err := json.Unmarshal([]byte(plaintextJSON), &pair); err != nil {
+			return nil, fmt.Errorf("failed to parse client certificate: %w", err)
+		}
+	} else {
+		certVersion, err := cc.Certificate.GetCurrentVersion(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		keyVersion, err := cc.PrivateKey.GetCurrentVersion(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate private key: %w", err)
+		}
+		pair.Certificate = string(certVersion.Data)
+		pair.PrivateKey = string(keyVersion.Data)
+	}
+
+	return parseClientCertificate(pair)
+}
+
+// parseClientCertificate parses a PEM certificate chain and private key into
+// a tls.Certificate with its leaf populated.
+func parseClientCertificate(pair database.ClientCertificatePlaintext) (*tls.Certificate, error) {
+	cert, err := tls.X509KeyPair([]byte(pair.Certificate), []byte(pair.PrivateKey))
+	if err != nil {
+		return nil, err
+	}
+	if cert.Leaf == nil {
+		leaf, err := x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			return nil, err
+		}
+		cert.Leaf = leaf
+	}
+	return &cert, nil
+}
+
+// clientCertificateNotAfter returns the expiry of the connection's client
+// certificate. For uploaded pairs it reads the plaintext not_after column so
+// the expiry sweep doesn't need to decrypt every certificate.
+func (c *connection) clientCertificateNotAfter(ctx context.Context) (time.Time, error) {
+	cc := getClientCertificateConfig(c)
+	if cc.IsUploaded() {
+		row, err := c.s.db.GetActiveConnectionClientCertificate(ctx, c.Id)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return row.NotAfter, nil
+	}
+
+	cert, err := c.GetClientCertificate(ctx)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return cert.Leaf.NotAfter, nil
+}
+
+// revokeClientCertificate discards an uploaded client certificate. Connectors
+// that source the pair from key data have nothing to revoke per connection.
+func (c *connection) revokeClientCertificate(ctx context.Context) error {
+	if err := c.s.db.DeleteAllConnectionClientCertificatesForConnection(ctx, c.Id); err != nil {
+		return err
+	}
+	c.s.releaseConnectionTransport(c.Id)
+	return c.resolveRequiredActionNotifications(ctx, database.NotificationKeyClientCertificateExpiring)
+}
+
+func synthesizeClientCertificateStep() *cschema.SetupFlowStep {
+	js := json_schema.Schema{
+		Type:     "object",
+		Required: []string{attrClientCertificate, attrClientCertificatePrivateKey},
+		Properties: map[string]json_schema.Property{
+			attrClientCertificate: {
+				Type:      "string",
+				Title:     "Certificate (PEM)",
+				MinLength: 1,
+			},
+			attrClientCertificatePrivateKey: {
+				Type:      "string",
+				Title:     "Private Key (PEM)",
+				MinLength: 1,
+			},
+		},
+		AdditionalProperties: false,
+	}
+	ui := ui_schema.Schema{
+		Type: "VerticalLayout",
+		Elements: []ui_schema.Control{
+			{
+				Type:    "Control",
+				Scope:   fmt.Sprintf("#/properties/%s", attrClientCertificate),
+				Options: map[string]string{"multi": "true"},
+			},
+			{
+				Type:    "Control",
+				Scope:   fmt.Sprintf("#/properties/%s", attrClientCertificatePrivateKey),
+				Options: map[string]string{"multi": "true"},
+			},
+		},
+	}
+
+	jsBytes, err := json.Marshal(js)
+	if err != nil {
+		panic("core: failed to marshal synthesized client certificate json_schema: " + err.Error())
+	}
+	uiBytes, err := json.Marshal(ui)
+	if err != nil {
+		panic("core: failed to marshal synthesized client certificate ui_schema: " + err.Error())
+	}
+
+	return &cschema.SetupFlowStep{
+		Id:          ClientCertificateStepId,
+		Title:       "Upload client certificate",
+		Description: "Provide the TLS client certificate and private key this service requires for mutual TLS.",
+		JsonSchema:  common.RawJSON(jsBytes),
+		UiSchema:    common.RawJSON(uiBytes),
+	}
+}
+
+// newClientCertificateStep returns the form step that collects an uploaded
+// client certificate. It runs before the auth method's steps so that auth
+// calls which go over mutual TLS (e.g. a FAPI token endpoint) already have a
+// certificate to present.
+func (s *service) newClientCertificateStep(c iface.Connection, cc *cschema.TransportClientCertificate) iface.ManifestSetupStep {
+	spec := synthesizeClientCertificateStep()
+	return iface.NewFormStep(iface.FormStepConfig{
+		Id:          spec.Id,
+		Title:       spec.Title,
+		Description: spec.Description,
+		JsonSchema:  json.RawMessage(spec.JsonSchema),
+		UiSchema:    json.RawMessage(spec.UiSchema),
+		OnSubmit: func(ctx context.Context, data json.RawMessage) error {
+			certData, err := spec.ValidateAndMergeData(spec.Id, data, nil)
+			if err != nil {
+				return httperr.BadRequest(err.Error())
+			}
+			return s.persistClientCertificate(ctx, c, cc, certData)
+		},
+	})
+}
+
+// persistClientCertificate validates the submitted pair and stores it,
+// encrypted, in connection_client_certificates.
+func (s *service) persistClientCertificate(
+	ctx context.Context,
+	c iface.Connection,
+	cc *cschema.TransportClientCertificate,
+	certData map[string]any,
+) error {
+	pair := database.ClientCertificatePlaintext{}
+	if v, ok := certData[attrClientCertificate].(string); ok {
+		pair.Certificate = strings.TrimSpace(v)
+	}
+	if v, ok := certData[attrClientCertificatePrivateKey].(string); ok {
+		pair.PrivateKey = strings.TrimSpace(v)
+	}
+	if pair.Certificate == "" || pair.PrivateKey == "" {
+		return httperr.BadRequest(fmt.Sprintf("%s and %s are required", attrClientCertificate, attrClientCertificatePrivateKey))
+	}
+
+	cert, err := parseClientCertificate(pair)
+	if err != nil {
+		return httperr.BadRequest(fmt.Sprintf("invalid client certificate: %s", err.Error()))
+	}
+	if !apctx.GetClock(ctx).Now().Before(cert.Leaf.NotAfter) {
+		return httperr.BadRequest("client certificate has expired")
+	}
+
+	blobJSON, err := json.Marshal(pair)
+	if err != nil {
+		return httperr.InternalServerError(httperr.WithInternalErrorf("failed to marshal client certificate: %w", err))
+	}
+	encrypted, err := s.encrypt.EncryptStringForNamespace(ctx, c.GetNamespace(), string(blobJSON))
+	if err != nil {
+		return httperr.InternalServerError(httperr.WithInternalErrorf("failed to encrypt client certificate: %w", err))
+	}
+
+	actorId := apauthcore.GetAuthFromContext(ctx).MustGetActor().GetId()
+	if _, err := s.db.InsertConnectionClientCertificate(ctx, c.GetId(), encrypted, cert.Leaf.NotAfter, &actorId); err != nil {
+		return httperr.InternalServerError(httperr.WithInternalErrorf("failed to persist client certificate: %w", err))
+	}
+	// Pooled TLS connections still present the previous certificate.
+	s.releaseConnectionTransport(c.GetId())
+
+	if err := s.syncClientCertificateExpiryNotification(ctx, c, cc, cert.Leaf.NotAfter); err != nil {
+		return httperr.InternalServerError(httperr.WithInternalErrorf("failed to update client certificate expiry notification: %w", err))
+	}
+	return nil
+}
+
+// syncClientCertificateExpiryNotification raises a connection notification
+// when the client certificate expires within the connector's warning window
+// (as an error once it has expired), and resolves it otherwise.
+func (s *service) syncClientCertificateExpiryNotification(
+	ctx context.Context,
+	c iface.Connection,
+	cc *cschema.TransportClientCertificate,
+	notAfter time.Time,
+) error {
+	key := connectionRequiredActionNotificationKey(c.GetId(), database.NotificationKeyClientCertificateExpiring)
+
+	now := apctx.GetClock(ctx).Now()
+	if now.Add(cc.GetExpiryWarningOrDefault()).Before(notAfter) {
+		return s.resolveNotificationsForResourceKeys(ctx, "connection", c.GetId(), []string{key})
+	}
+
+	level := database.NotificationLevelWarning
+	title := "Client certificate expiring"
+	message := fmt.Sprintf("The TLS client certificate for this connection expires on %s.", notAfter.UTC().Format(time.RFC1123))
+	if !now.Before(notAfter) {
+		level = database.NotificationLevelError
+		title = "Client certificate expired"
+		message = fmt.Sprintf("The TLS client certificate for this connection expired on %s.", notAfter.UTC().Format(time.RFC1123))
+	}
+
+	// Uploaded certificates are rotated by re-running setup; certificates
+	// from the connector definition can only be rotated by an operator.
+	var actionURL *string
+	actionPermissions := aschema.NoPermissions()
+	if cc.IsUploaded() {
+		url := fmt.Sprintf("/connections/%s?action=reauth", c.GetId())
+		actionURL = &url
+		actionPermissions = aschema.PermissionsSingleWithResourceIds(c.GetNamespace(), "connections", "update", c.GetId().String())
+	} else {
+		message += " The certificate is configured on the connector and must be rotated by an administrator."
+	}
+
+	_, err := s.upsertNotification(ctx, database.NotificationUpsert{
+		Key:          key,
+		Level:        level,
+		ResourceType: "connection",
+		ResourceId:   c.GetId(),
+		Namespace:    c.GetNamespace(),
+		Labels:       c.GetLabels(),
+		Title:        title,
+		Message:      message,
+		ActionUrl:    actionURL,
+		ViewPermissions: aschema.PermissionsSingleWithResourceIds(
+			c.GetNamespace(),
+			"connections",
+			"get",
+			c.GetId().String(),
+		),
+		ActionPermissions: actionPermissions,
+		Metadata: map[string]any{
+			"connector_id": c.GetConnectorId().String(),
+			"not_after":    notAfter.UTC().Format(time.RFC3339),
+		},
+	})
+	return err
+}
+
+// checkClientCertificateExpiry refreshes the expiry notification for one
+// connection. Connections without a certificate yet (still in setup) are
+// skipped.
+func (s *service) checkClientCertificateExpiry(ctx context.Context, c *connection) error {
+	cc := getClientCertificateConfig(c)
+	if cc == nil {
+		return nil
+	}
+
+	notAfter, err := c.clientCertificateNotAfter(ctx)
+	if errors.Is(err, database.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return s.syncClientCertificateExpiryNotification(ctx, c, cc, notAfter)
+}
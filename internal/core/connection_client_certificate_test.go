@@ -0,0 +1,245 @@
+package core
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/rmorlok/authproxy/internal/apid"
+	"github.com/rmorlok/authproxy/internal/auth_methods/api_key"
+	"github.com/rmorlok/authproxy/internal/database"
+	mockDb "github.com/rmorlok/authproxy/internal/database/mock"
+	"github.com/rmorlok/authproxy/internal/encfield"
+	"github.com/rmorlok/authproxy/internal/httperr"
+	"github.com/rmorlok/authproxy/internal/schema/common"
+	cschema "github.com/rmorlok/authproxy/internal/schema/resources/connectors"
+	"github.com/rmorlok/authproxy/internal/schema/resources/key"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mustClientCertificatePEM returns a self-signed PEM certificate and PKCS#8
+// private key that expire at notAfter.
+func mustClientCertificatePEM(t *testing.T, notAfter time.Time) (string, string) {
+	t.Helper()
+	k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "tenant-a"},
+		NotBefore:    notAfter.Add(-365 * 24 * time.Hour),
+		NotAfter:     notAfter,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &k.PublicKey, k)
+	require.NoError(t, err)
+	keyDer, err := x509.MarshalPKCS8PrivateKey(k)
+	require.NoError(t, err)
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDer}))
+}
+
+func newTestClientCertificateConnection(t *testing.T, ctrl *gomock.Controller, cc *cschema.TransportClientCertificate) (*connection, *mockDb.MockDB) {
+	t.Helper()
+	conn, db, _ := newTestApiKeyConnection(t, ctrl, &cschema.ApiKeyPlacement{Type: cschema.ApiKeyPlacementBearer}, nil)
+	conn.connector.GetDefinition().Transport = &cschema.Transport{ClientCertificate: cc}
+	return conn, db
+}
+
+func TestManifestSetupFlow_ClientCertificateStep(t *testing.T) {
+	t.Run("uploaded pair runs before auth", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		conn, _ := newTestClientCertificateConnection(t, ctrl, &cschema.TransportClientCertificate{})
+
+		steps, err := conn.s.buildManifestSetupFlow(conn).Steps(context.Background())
+		require.NoError(t, err)
+		require.Len(t, steps, 2)
+		assert.Equal(t, ClientCertificateStepId, steps[0].Id())
+		assert.Equal(t, api_key.SynthesizedApiKeyCredentialsStepId, steps[1].Id())
+	})
+
+	t.Run("key data pair has no step", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		pemData := &key.KeyData{InnerVal: &key.KeyDataValue{Value: "unused"}}
+		conn, _ := newTestClientCertificateConnection(t, ctrl, &cschema.TransportClientCertificate{
+			Certificate: pemData,
+			PrivateKey:  pemData,
+		})
+
+		steps, err := conn.s.buildManifestSetupFlow(conn).Steps(context.Background())
+		require.NoError(t, err)
+		require.Len(t, steps, 1)
+		assert.Equal(t, api_key.SynthesizedApiKeyCredentialsStepId, steps[0].Id())
+	})
+}
+
+func TestClientCertificateStep_Submit(t *testing.T) {
+	submit := func(t *testing.T, conn *connection, certPEM, keyPEM string) error {
+		t.Helper()
+		ctx, _ := contextWithActor(t)
+		step, ok, err := conn.s.buildManifestSetupFlow(conn).StepById(ctx, ClientCertificateStepId)
+		require.NoError(t, err)
+		require.True(t, ok)
+		data, err := json.Marshal(map[string]string{"certificate": certPEM, "private_key": keyPEM})
+		require.NoError(t, err)
+		return step.OnSubmit(ctx, data)
+	}
+
+	t.Run("stores the pair and serves it to the transport", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		conn, db := newTestClientCertificateConnection(t, ctrl, &cschema.TransportClientCertificate{})
+		notAfter := time.Now().Add(365 * 24 * time.Hour).Truncate(time.Second)
+		certPEM, keyPEM := mustClientCertificatePEM(t, notAfter)
+
+		blob := &captureEncCredential{}
+		_, actorId := contextWithActor(t)
+		db.EXPECT().
+			InsertConnectionClientCertificate(gomock.Any(), conn.Id, blob, gomock.Any(), &actorId).
+			DoAndReturn(func(_ context.Context, _ apid.ID, _ encfield.EncryptedField, got time.Time, _ *apid.ID) (*database.ConnectionClientCertificate, error) {
+				assert.True(t, notAfter.Equal(got))
+				return &database.ConnectionClientCertificate{Id: apid.New(apid.PrefixClientCertificate)}, nil
+			})
+		expectResolveRequiredActionNotification(db, conn.Id, database.NotificationKeyClientCertificateExpiring)
+
+		require.NoError(t, submit(t, conn, certPEM, keyPEM))
+
+		db.EXPECT().
+			GetActiveConnectionClientCertificate(gomock.Any(), conn.Id).
+			Return(&database.ConnectionClientCertificate{EncryptedCertificate: blob.field, NotAfter: notAfter}, nil)
+
+		cert, err := conn.GetClientCertificate(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, "tenant-a", cert.Leaf.Subject.CommonName)
+	})
+
+	t.Run("rejects an expired certificate", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		conn, _ := newTestClientCertificateConnection(t, ctrl, &cschema.TransportClientCertificate{})
+		certPEM, keyPEM := mustClientCertificatePEM(t, time.Now().Add(-time.Hour))
+
+		err := submit(t, conn, certPEM, keyPEM)
+		require.True(t, httperr.IsStatus(err, 400))
+		assert.Contains(t, err.Error(), "expired")
+	})
+
+	t.Run("rejects a mismatched key", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		conn, _ := newTestClientCertificateConnection(t, ctrl, &cschema.TransportClientCertificate{})
+		certPEM, _ := mustClientCertificatePEM(t, time.Now().Add(time.Hour))
+		_, otherKeyPEM := mustClientCertificatePEM(t, time.Now().Add(time.Hour))
+
+		err := submit(t, conn, certPEM, otherKeyPEM)
+		require.True(t, httperr.IsStatus(err, 400))
+	})
+}
+
+func TestConnection_GetClientCertificateFromKeyData(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	certPEM, keyPEM := mustClientCertificatePEM(t, time.Now().Add(time.Hour))
+	conn, _ := newTestClientCertificateConnection(t, ctrl, &cschema.TransportClientCertificate{
+		Certificate: &key.KeyData{InnerVal: &key.KeyDataValue{Value: certPEM}},
+		PrivateKey:  &key.KeyData{InnerVal: &key.KeyDataValue{Value: keyPEM}},
+	})
+
+	require.True(t, conn.RequiresClientCertificate())
+	cert, err := conn.GetClientCertificate(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "tenant-a", cert.Leaf.Subject.CommonName)
+
+	// Nothing is stored per connection, so there is nothing to revoke.
+	assert.Empty(t, conn.getRevokeCredentialsOperations())
+}
+
+func TestCheckClientCertificateExpiry(t *testing.T) {
+	tests := []struct {
+		name       string
+		notAfter   time.Duration
+		wantLevel  database.NotificationLevel
+		wantNotify bool
+	}{
+		{name: "outside warning window", notAfter: 60 * 24 * time.Hour},
+		{name: "inside warning window", notAfter: 3 * 24 * time.Hour, wantNotify: true, wantLevel: database.NotificationLevelWarning},
+		{name: "expired", notAfter: -time.Hour, wantNotify: true, wantLevel: database.NotificationLevelError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			conn, db := newTestClientCertificateConnection(t, ctrl, &cschema.TransportClientCertificate{
+				ExpiryWarning: &common.HumanDuration{Duration: 7 * 24 * time.Hour},
+			})
+			db.EXPECT().
+				GetActiveConnectionClientCertificate(gomock.Any(), conn.Id).
+				Return(&database.ConnectionClientCertificate{NotAfter: time.Now().Add(tt.notAfter)}, nil)
+
+			if tt.wantNotify {
+				db.EXPECT().
+					UpsertNotification(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, u database.NotificationUpsert) (*database.Notification, error) {
+						assert.Equal(t, connectionRequiredActionNotificationKey(conn.Id, database.NotificationKeyClientCertificateExpiring), u.Key)
+						assert.Equal(t, tt.wantLevel, u.Level)
+						require.NotNil(t, u.ActionUrl)
+						assert.Contains(t, *u.ActionUrl, "action=reauth")
+						return &database.Notification{}, nil
+					})
+			} else {
+				expectResolveRequiredActionNotification(db, conn.Id, database.NotificationKeyClientCertificateExpiring)
+			}
+
+			require.NoError(t, conn.s.checkClientCertificateExpiry(context.Background(), conn))
+		})
+	}
+
+	t.Run("no certificate uploaded yet", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		conn, db := newTestClientCertificateConnection(t, ctrl, &cschema.TransportClientCertificate{})
+		db.EXPECT().
+			GetActiveConnectionClientCertificate(gomock.Any(), conn.Id).
+			Return(nil, database.ErrNotFound)
+
+		require.NoError(t, conn.s.checkClientCertificateExpiry(context.Background(), conn))
+	})
+}
+
+func TestRevokeCredentialsOperations_ClientCertificate(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	conn, db := newTestClientCertificateConnection(t, ctrl, &cschema.TransportClientCertificate{})
+
+	ops := conn.getRevokeCredentialsOperations()
+	require.NotEmpty(t, ops)
+
+	db.EXPECT().DeleteAllApiKeyCredentialsForConnection(gomock.Any(), conn.Id).Return(nil).AnyTimes()
+	db.EXPECT().DeleteAllConnectionClientCertificatesForConnection(gomock.Any(), conn.Id).Return(nil)
+	expectResolveRequiredActionNotification(db, conn.Id, database.NotificationKeyClientCertificateExpiring)
+
+	for _, op := range ops {
+		require.NoError(t, op(context.Background()))
+	}
+}
@@ -31,7 +31,7 @@ func (c *connection) GetDataSource(ctx context.Context, sourceId string) ([]apjs
 
 	flow := c.s.buildManifestSetupFlow(c)
 	if _, ok, err := flow.StepById(ctx, setupStep.Id()); err != nil {
-		return nil, httperr.InternalServerError(httperr.WithInternalErrorf("failed to evaluate setup flow: %w", err))
+		return nil, setupFlowEvaluationError(err)
 	} else if !ok {
 		return nil, httperr.BadRequest("current setup step is not eligible for data sources")
 	}
@@ -103,6 +103,9 @@ func (c *connection) GetDataSource(ctx context.Context, sourceId string) ([]apjs
 	}
 
 	options, err := jsctx.TransformJSON(ds.Transform, responseData)
+	if apjs.IsBudgetExceeded(err) {
+		return nil, httperr.InternalServerErrorMsg("data source transform script exceeded budget", httperr.WithInternalErrorf("data source transform failed: %w", err))
+	}
 	if err != nil {
 		return nil, httperr.InternalServerErrorMsg("failed to transform data source response", httperr.WithInternalErrorf("data source transform failed: %w", err))
 	}
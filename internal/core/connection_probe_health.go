@@ -137,15 +137,18 @@ func (c *connection) consecutiveOutcomeStreak(ctx context.Context, probeId, matc
 	return n, nil
 }
 
-// maybeUpdateApiKeyLastValidated stamps the active api-key credential's
-// last_validated_at on probe success. No-op for non-api-key auth types and
-// when no active credential exists.
+// maybeUpdateApiKeyLastValidated stamps the active connection credential's
+// last_validated_at on probe success. No-op for auth types that don't store
+// their credentials in connection_credentials (api-key, request-signing and
+// oauth1 do) and when no active credential exists.
 func (c *connection) maybeUpdateApiKeyLastValidated(ctx context.Context) error {
 	def := c.connector.GetDefinition()
 	if def == nil || def.Auth == nil {
 		return nil
 	}
-	if _, ok := def.Auth.Inner().(*config.AuthApiKey); !ok {
+	switch def.Auth.Inner().(type) {
+	case *config.AuthApiKey, *config.AuthRequestSigning, *config.AuthOAuth1:
+	default:
 		return nil
 	}
 
@@ -4,6 +4,8 @@ import (
 	"context"
 	"errors"
 	"net/http"
+	"net/url"
+	"time"
 
 	"github.com/rmorlok/authproxy/internal/auth_methods"
 	"github.com/rmorlok/authproxy/internal/core/iface"
@@ -47,6 +49,93 @@ func (c *connection) resolveAuthenticator(connector *cschema.Connector) (auth_me
 	return factory.NewAuthenticator(c), nil
 }
 
+// GetAllowedUpstreams returns the connector's allowedUpstreams rendered
+// against this connection's mustache context. The connection's
+// configuration is only loaded when at least one entry is templated.
+func (c *connection) GetAllowedUpstreams(ctx context.Context) (cschema.AllowedUpstreams, error) {
+	def := c.connector.GetDefinition()
+	if def == nil || len(def.AllowedUpstreams) == 0 {
+		return nil, nil
+	}
+
+	if !def.AllowedUpstreams.IsTemplated() {
+		return def.AllowedUpstreams, nil
+	}
+
+	data, err := c.GetMustacheContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return def.AllowedUpstreams.Render(data)
+}
+
+// GetBaseUrl returns the connector's baseUrl rendered against this
+// connection's mustache context, or nil when the connector doesn't declare
+// one. The configuration is only loaded when the base URL is templated.
+func (c *connection) GetBaseUrl(ctx context.Context) (*url.URL, error) {
+	def := c.connector.GetDefinition()
+	if def == nil || def.BaseUrl == "" {
+		return nil, nil
+	}
+
+	if !def.BaseUrl.IsTemplated() {
+		return def.BaseUrl.Render(nil)
+	}
+
+	data, err := c.GetMustacheContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return def.BaseUrl.Render(data)
+}
+
+// GetUpgradeIdleTimeout returns how long an upgraded connection (e.g. a
+// WebSocket) may sit idle before the proxy closes it.
+func (c *connection) GetUpgradeIdleTimeout() time.Duration {
+	var transport *cschema.Transport
+	if def := c.connector.GetDefinition(); def != nil {
+		transport = def.Transport
+	}
+	return transport.GetUpgradeIdleTimeoutOrDefault()
+}
+
+// GetRetryPolicy returns the connector's retry policy for transient
+// upstream failures, or nil when it doesn't declare one.
+func (c *connection) GetRetryPolicy() *cschema.RetryPolicy {
+	if def := c.connector.GetDefinition(); def != nil {
+		return def.RetryPolicy
+	}
+	return nil
+}
+
+// GetRequestTransforms returns the connector's wrapped proxy request
+// transforms.
+func (c *connection) GetRequestTransforms() cschema.ProxyTransforms {
+	if def := c.connector.GetDefinition(); def != nil {
+		return def.RequestTransform
+	}
+	return nil
+}
+
+// GetResponseTransforms returns the connector's wrapped proxy response
+// transforms.
+func (c *connection) GetResponseTransforms() cschema.ProxyTransforms {
+	if def := c.connector.GetDefinition(); def != nil {
+		return def.ResponseTransform
+	}
+	return nil
+}
+
+// GetPaginationRules returns the connector's pagination declarations.
+func (c *connection) GetPaginationRules() cschema.PaginationRules {
+	if def := c.connector.GetDefinition(); def != nil {
+		return def.Pagination
+	}
+	return nil
+}
+
 func (c *connection) ProxyRequest(
 	ctx context.Context,
 	reqType httpf.RequestType,
@@ -60,6 +149,20 @@ func (c *connection) ProxyRequest(
 	return p.ProxyRequest(ctx, reqType, req)
 }
 
+func (c *connection) ProxyRequestPages(
+	ctx context.Context,
+	reqType httpf.RequestType,
+	req *iface.ProxyRequest,
+	onPage iface.PageCallback,
+) (*iface.PaginationResult, error) {
+	p, err := c.getProxyImpl()
+	if err != nil {
+		return nil, err
+	}
+
+	return p.ProxyRequestPages(ctx, reqType, req, onPage)
+}
+
 func (c *connection) ProxyRequestRaw(
 	ctx context.Context,
 	reqType httpf.RequestType,
@@ -73,3 +176,8 @@ func (c *connection) ProxyRequestRaw(
 
 	return p.ProxyRequestRaw(ctx, reqType, req, w)
 }
+
+var _ proxy.AllowedUpstreamsProvider = (*connection)(nil)
+var _ proxy.PaginationRulesProvider = (*connection)(nil)
+var _ proxy.ProxyTransformsProvider = (*connection)(nil)
+var _ proxy.UpgradeIdleTimeoutProvider = (*connection)(nil)
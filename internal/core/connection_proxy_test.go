@@ -0,0 +1,95 @@
+package core
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/rmorlok/authproxy/internal/encfield"
+	"github.com/rmorlok/authproxy/internal/schema/common"
+	cschema "github.com/rmorlok/authproxy/internal/schema/resources/connectors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConnectionGetAllowedUpstreams(t *testing.T) {
+	t.Run("nil when connector declares none", func(t *testing.T) {
+		conn := newTestConnection(cschema.Connector{})
+
+		allowed, err := conn.GetAllowedUpstreams(context.Background())
+		require.NoError(t, err)
+		assert.Nil(t, allowed)
+	})
+
+	t.Run("static entries are returned without loading configuration", func(t *testing.T) {
+		conn := newTestConnection(cschema.Connector{
+			AllowedUpstreams: cschema.AllowedUpstreams{{Hosts: []string{"api.example.com"}}},
+		})
+		// A configuration that can't be decrypted proves it was never read.
+		conn.EncryptedConfiguration = &encfield.EncryptedField{ID: "dek_wrong", Data: "some-data"}
+
+		allowed, err := conn.GetAllowedUpstreams(context.Background())
+		require.NoError(t, err)
+		require.Len(t, allowed, 1)
+		assert.True(t, allowed.Allows(&url.URL{Scheme: "https", Host: "api.example.com"}))
+	})
+
+	t.Run("templated entries render against cfg", func(t *testing.T) {
+		conn := newTestConnection(cschema.Connector{
+			AllowedUpstreams: cschema.AllowedUpstreams{{Hosts: []string{"{{cfg.subdomain}}.example.com"}}},
+		})
+		ef, err := conn.s.encrypt.EncryptStringForNamespace(context.Background(), conn.GetNamespace(), `{"subdomain":"acme"}`)
+		require.NoError(t, err)
+		conn.EncryptedConfiguration = &ef
+
+		allowed, err := conn.GetAllowedUpstreams(context.Background())
+		require.NoError(t, err)
+		assert.True(t, allowed.Allows(&url.URL{Scheme: "https", Host: "acme.example.com"}))
+		assert.False(t, allowed.Allows(&url.URL{Scheme: "https", Host: "other.example.com"}))
+	})
+}
+
+func TestConnectionGetUpgradeIdleTimeout(t *testing.T) {
+	assert.Equal(t, 5*time.Minute, newTestConnection(cschema.Connector{}).GetUpgradeIdleTimeout())
+
+	conn := newTestConnection(cschema.Connector{Transport: &cschema.Transport{
+		UpgradeIdleTimeout: &common.HumanDuration{Duration: time.Hour},
+	}})
+	assert.Equal(t, time.Hour, conn.GetUpgradeIdleTimeout())
+}
+
+func TestConnectionGetRetryPolicy(t *testing.T) {
+	assert.Nil(t, newTestConnection(cschema.Connector{}).GetRetryPolicy())
+
+	policy := &cschema.RetryPolicy{StatusCodes: []int{503}}
+	assert.Equal(t, policy, newTestConnection(cschema.Connector{RetryPolicy: policy}).GetRetryPolicy())
+}
+
+func TestConnectionGetBaseUrl(t *testing.T) {
+	t.Run("nil when connector declares none", func(t *testing.T) {
+		u, err := newTestConnection(cschema.Connector{}).GetBaseUrl(context.Background())
+		require.NoError(t, err)
+		assert.Nil(t, u)
+	})
+
+	t.Run("static base url is returned without loading configuration", func(t *testing.T) {
+		conn := newTestConnection(cschema.Connector{BaseUrl: "https://api.example.com/v1"})
+		conn.EncryptedConfiguration = &encfield.EncryptedField{ID: "dek_wrong", Data: "some-data"}
+
+		u, err := conn.GetBaseUrl(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, "https://api.example.com/v1", u.String())
+	})
+
+	t.Run("templated base url renders against cfg", func(t *testing.T) {
+		conn := newTestConnection(cschema.Connector{BaseUrl: "https://{{cfg.subdomain}}.example.com/api"})
+		ef, err := conn.s.encrypt.EncryptStringForNamespace(context.Background(), conn.GetNamespace(), `{"subdomain":"acme"}`)
+		require.NoError(t, err)
+		conn.EncryptedConfiguration = &ef
+
+		u, err := conn.GetBaseUrl(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, "https://acme.example.com/api", u.String())
+	})
+}
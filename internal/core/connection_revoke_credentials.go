@@ -2,21 +2,26 @@ package core
 
 // getRevokeCredentialsOperations returns the operations that can be performed
 // to revoke credentials for this connection. May return a nil slice if the
-// connection's auth method does not support revocation. Dispatch is generic
-// across auth types — the Authenticator interface carries SupportsRevoke /
-// Revoke so this function makes no assumptions about which method is in use.
+// connection's auth method does not support revocation and it has no uploaded
+// client certificate. Dispatch is generic across auth types — the
+// Authenticator interface carries SupportsRevoke / Revoke so this function
+// makes no assumptions about which method is in use.
 func (c *connection) getRevokeCredentialsOperations() []operation {
 	def := c.connector.GetDefinition()
 	if def == nil {
 		return nil
 	}
-	factory := c.s.getAuthMethodFactory(def)
-	if factory == nil {
-		return nil
+
+	var ops []operation
+	if factory := c.s.getAuthMethodFactory(def); factory != nil {
+		if auth := factory.NewAuthenticator(c); auth.SupportsRevoke() {
+			ops = append(ops, auth.Revoke)
+		}
 	}
-	auth := factory.NewAuthenticator(c)
-	if !auth.SupportsRevoke() {
-		return nil
+
+	if def.Transport != nil && def.Transport.ClientCertificate.IsUploaded() {
+		ops = append(ops, c.revokeClientCertificate)
 	}
-	return []operation{auth.Revoke}
+
+	return ops
 }
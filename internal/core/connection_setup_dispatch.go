@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"time"
 
 	"github.com/rmorlok/authproxy/internal/core/iface"
 	"github.com/rmorlok/authproxy/internal/database"
@@ -12,12 +13,14 @@ import (
 )
 
 // advanceToStep transitions setup_step to next and returns the response the
-// API surface renders to the caller. Three special cases beyond plain form
+// API surface renders to the caller. Four special cases beyond plain form
 // rendering:
 //
 //   - next is apxy:verify: enqueue the verify task and return Verifying.
 //   - next is a redirect step: call RenderRedirect with the supplied return-
 //     to-url and return Redirect.
+//   - next is a device-code step: call RenderDeviceCode, which starts the
+//     device authorization and its background poll, and return DeviceCode.
 //   - next is nil: flow is complete — clear setup_step, transition state to
 //     Configured, return Complete.
 //
@@ -46,6 +49,17 @@ func (c *connection) advanceToStep(
 		}
 	}
 
+	// Device-code steps likewise start the device authorization before the
+	// step is recorded so a provider rejection leaves setup_step untouched.
+	var deviceCodeInfo iface.DeviceCodeInfo
+	if next.Type() == iface.ManifestStepTypeDeviceCode {
+		var err error
+		deviceCodeInfo, err = next.RenderDeviceCode(ctx)
+		if err != nil {
+			return nil, httperr.FromErrorf("failed to start device authorization: %w", err)
+		}
+	}
+
 	nextStep := cschema.MustNewSetupStep(next.Id())
 	if err := c.SetSetupStep(ctx, &nextStep); err != nil {
 		return nil, httperr.InternalServerError(httperr.WithInternalErrorf("failed to update setup step: %w", err))
@@ -65,6 +79,10 @@ func (c *connection) advanceToStep(
 		}, nil
 	}
 
+	if next.Type() == iface.ManifestStepTypeDeviceCode {
+		return c.deviceCodeResponse(next, deviceCodeInfo), nil
+	}
+
 	return c.renderStepResponse(ctx, flow, next, iface.RenderRedirectOptions{ReturnToUrl: returnToUrl})
 }
 
@@ -144,10 +162,34 @@ func (c *connection) renderStepResponse(
 			Type: iface.ConnectionSetupResponseTypeVerifying,
 		}, nil
 
+	case iface.ManifestStepTypeDeviceCode:
+		// Resume returns the pending device authorization rather than
+		// starting a new one, so the user code the user is typing stays valid.
+		info, err := step.RenderDeviceCode(ctx)
+		if err != nil {
+			return nil, httperr.FromErrorf("failed to render device authorization: %w", err)
+		}
+		return c.deviceCodeResponse(step, info), nil
+
 	}
 	return nil, httperr.InternalServerError(httperr.WithInternalErrorf("unsupported manifest step type %q", step.Type()))
 }
 
+func (c *connection) deviceCodeResponse(step iface.ManifestSetupStep, info iface.DeviceCodeInfo) *iface.ConnectionSetupDeviceCode {
+	return &iface.ConnectionSetupDeviceCode{
+		Id:                      c.GetId(),
+		Type:                    iface.ConnectionSetupResponseTypeDeviceCode,
+		StepId:                  step.Id(),
+		StepTitle:               step.Title(),
+		StepDescription:         step.Description(),
+		UserCode:                info.UserCode,
+		VerificationUri:         info.VerificationUri,
+		VerificationUriComplete: info.VerificationUriComplete,
+		ExpiresAt:               info.ExpiresAt,
+		Interval:                int(info.Interval / time.Second),
+	}
+}
+
 // getReconfigureFormData returns only the stored configuration fields owned by
 // the current form step. A reconfigure keeps the connection in Configured state,
 // which distinguishes it from initial setup and lets resumed/subsequent steps
@@ -230,13 +272,13 @@ func (c *connection) renderResumeResponse(ctx context.Context, flow iface.Manife
 	}
 	step, ok, err := flow.StepById(ctx, setupStep.Id())
 	if err != nil {
-		return nil, httperr.InternalServerError(httperr.WithInternalErrorf("failed to evaluate setup flow: %w", err))
+		return nil, setupFlowEvaluationError(err)
 	}
 	if !ok {
 		if flow.ContainsStep(setupStep.Id()) {
 			next, hasNext, err := flow.NextStep(ctx, setupStep.Id())
 			if err != nil {
-				return nil, httperr.InternalServerError(httperr.WithInternalErrorf("failed to evaluate setup flow: %w", err))
+				return nil, setupFlowEvaluationError(err)
 			}
 			if !hasNext {
 				return c.completeFlow(ctx)
@@ -9,6 +9,7 @@ import (
 
 	apauthcore "github.com/rmorlok/authproxy/internal/apauth/core"
 	"github.com/rmorlok/authproxy/internal/apid"
+	"github.com/rmorlok/authproxy/internal/apjs"
 	"github.com/rmorlok/authproxy/internal/aptmpl"
 	"github.com/rmorlok/authproxy/internal/core/iface"
 	"github.com/rmorlok/authproxy/internal/core/setup_token"
@@ -24,8 +25,9 @@ import (
 const setupTokenTTL = 15 * time.Minute
 
 // buildManifestSetupFlow assembles the ManifestSetupFlow for a connection.
-// The linear order is preconnect (schema) + auth-method-emitted steps
-// (factory) + configure (schema). When the connector has probes, the
+// The linear order is preconnect (schema) + client certificate upload (when
+// the connector requires mutual TLS with an uploaded pair) + auth-method-
+// emitted steps (factory) + configure (schema). When the connector has probes, the
 // apxy:verify pseudo-step is inserted between the last credential-
 // establishing step and the first configure step (or returned as the next
 // step from the last credential-establishing step when there are no
@@ -54,6 +56,10 @@ func (s *service) buildManifestSetupFlow(c iface.Connection) iface.ManifestSetup
 		}
 	}
 
+	if connector.Transport != nil && connector.Transport.ClientCertificate.IsUploaded() {
+		steps = append(steps, s.newClientCertificateStep(c, connector.Transport.ClientCertificate))
+	}
+
 	if factory := s.getAuthMethodFactory(connector); factory != nil {
 		authSteps := factory.ManifestSetupSteps(c, connector)
 		steps = append(steps, authSteps...)
@@ -198,6 +204,15 @@ func newSchemaStepEligibility(c iface.Connection, spec *cschema.SetupFlowStep) f
 	}
 }
 
+// setupFlowEvaluationError reports a failure to work out which setup steps are eligible. A step condition stopped by
+// its script budget is a problem with the connector rather than the request, so the caller is told as much.
+func setupFlowEvaluationError(err error) error {
+	if apjs.IsBudgetExceeded(err) {
+		return httperr.InternalServerErrorMsg("setup flow script exceeded budget", httperr.WithInternalErrorf("failed to evaluate setup flow: %w", err))
+	}
+	return httperr.InternalServerError(httperr.WithInternalErrorf("failed to evaluate setup flow: %w", err))
+}
+
 // mintReturnURL mints a setup_token and returns the public-endpoint URL
 // (/setup/connections/{id}/{advance|abort}?token=<jti>) that substitutes
 // for the corresponding placeholder in a redirect step's URL template.
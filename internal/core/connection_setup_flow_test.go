@@ -3,9 +3,13 @@ package core
 import (
 	"context"
 	"encoding/json"
+	"net/http"
 	"testing"
+	"time"
 
 	"github.com/golang/mock/gomock"
+	"github.com/rmorlok/authproxy/internal/apjs"
+	"github.com/rmorlok/authproxy/internal/httperr"
 	"github.com/rmorlok/authproxy/internal/schema/common"
 	cschema "github.com/rmorlok/authproxy/internal/schema/resources/connectors"
 	"github.com/stretchr/testify/assert"
@@ -150,6 +154,36 @@ func TestManifestSetupFlowIfJavascriptError(t *testing.T) {
 	assert.Contains(t, err.Error(), "if.javascript")
 }
 
+func TestManifestSetupFlowIfJavascriptExceedsBudget(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	sf := &cschema.SetupFlow{
+		Configure: &cschema.SetupFlowPhase{
+			Steps: []cschema.SetupFlowStep{
+				{
+					Id:         "spins",
+					JsonSchema: workspaceSchema,
+					If:         &common.Predicate{Javascript: `(function() { while (true) {} })()`},
+				},
+			},
+		},
+	}
+	conn, _ := newTestConnectionWithSetupFlow(t, ctrl, sf)
+	conn.connector = NewTestConnector(cschema.Connector{
+		JavascriptBudget: &common.JavascriptBudget{Timeout: &common.HumanDuration{Duration: 20 * time.Millisecond}},
+		SetupFlow:        sf,
+	})
+
+	flow := conn.s.buildManifestSetupFlow(conn)
+	_, err := flow.FirstStep(context.Background())
+	require.True(t, apjs.IsBudgetExceeded(err))
+
+	herr := httperr.FromError(setupFlowEvaluationError(err))
+	assert.Equal(t, http.StatusInternalServerError, herr.Status)
+	assert.Equal(t, "setup flow script exceeded budget", herr.ResponseMsg)
+}
+
 func setConnectionConfigFixture(t *testing.T, conn *connection, cfg map[string]any) {
 	t.Helper()
 
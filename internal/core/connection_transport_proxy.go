@@ -0,0 +1,22 @@
+package core
+
+import (
+	"github.com/rmorlok/authproxy/internal/httpf"
+	cschema "github.com/rmorlok/authproxy/internal/schema/resources/connectors"
+)
+
+// GetTransportProxy returns the forward proxy the connector routes outbound
+// calls through, or nil to use the global outbound proxy configuration.
+func (c *connection) GetTransportProxy() *cschema.TransportProxy {
+	connector := c.GetConnector()
+	if connector == nil {
+		return nil
+	}
+	def := connector.GetDefinition()
+	if def == nil || def.Transport == nil {
+		return nil
+	}
+	return def.Transport.Proxy
+}
+
+var _ httpf.TransportProxyProvider = (*connection)(nil)
@@ -0,0 +1,16 @@
+package core
+
+import (
+	"testing"
+
+	cschema "github.com/rmorlok/authproxy/internal/schema/resources/connectors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConnectionGetTransportProxy(t *testing.T) {
+	assert.Nil(t, newTestConnection(cschema.Connector{}).GetTransportProxy())
+
+	proxy := &cschema.TransportProxy{Url: "socks5://proxy.internal:1080"}
+	conn := newTestConnection(cschema.Connector{Transport: &cschema.Transport{Proxy: proxy}})
+	assert.Equal(t, proxy, conn.GetTransportProxy())
+}
@@ -0,0 +1,42 @@
+package core
+
+import (
+	"context"
+
+	"github.com/rmorlok/authproxy/internal/httpf"
+	cschema "github.com/rmorlok/authproxy/internal/schema/resources/connectors"
+)
+
+func (c *connection) getTransportTLSConfig() *cschema.TransportTLS {
+	def := c.connector.GetDefinition()
+	if def == nil || def.Transport == nil {
+		return nil
+	}
+	return def.Transport.TLS
+}
+
+// HasTransportTLS reports whether the connector customizes TLS verification
+// for calls to the upstream.
+func (c *connection) HasTransportTLS() bool {
+	return c.getTransportTLSConfig() != nil
+}
+
+// GetTransportTLS returns the connector's TLS settings rendered against this
+// connection's mustache context, so tenants of a self-hosted product can
+// supply their own CA bundle during preconnect. The connection's
+// configuration is only loaded when a setting is templated.
+func (c *connection) GetTransportTLS(ctx context.Context) (*cschema.TransportTLS, error) {
+	settings := c.getTransportTLSConfig()
+	if !settings.IsTemplated() {
+		return settings, nil
+	}
+
+	data, err := c.GetMustacheContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return settings.Render(data)
+}
+
+var _ httpf.TransportTLSProvider = (*connection)(nil)
@@ -0,0 +1,48 @@
+package core
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rmorlok/authproxy/internal/encfield"
+	cschema "github.com/rmorlok/authproxy/internal/schema/resources/connectors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConnectionGetTransportTLS(t *testing.T) {
+	t.Run("absent when connector declares none", func(t *testing.T) {
+		conn := newTestConnection(cschema.Connector{Transport: &cschema.Transport{}})
+
+		assert.False(t, conn.HasTransportTLS())
+		settings, err := conn.GetTransportTLS(context.Background())
+		require.NoError(t, err)
+		assert.Nil(t, settings)
+	})
+
+	t.Run("static settings are returned without loading configuration", func(t *testing.T) {
+		conn := newTestConnection(cschema.Connector{
+			Transport: &cschema.Transport{TLS: &cschema.TransportTLS{MinVersion: "1.3"}},
+		})
+		// A configuration that can't be decrypted proves it was never read.
+		conn.EncryptedConfiguration = &encfield.EncryptedField{ID: "dek_wrong", Data: "some-data"}
+
+		require.True(t, conn.HasTransportTLS())
+		settings, err := conn.GetTransportTLS(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, "1.3", settings.MinVersion)
+	})
+
+	t.Run("templated settings render against cfg", func(t *testing.T) {
+		conn := newTestConnection(cschema.Connector{
+			Transport: &cschema.Transport{TLS: &cschema.TransportTLS{ServerName: "{{cfg.hostname}}"}},
+		})
+		ef, err := conn.s.encrypt.EncryptStringForNamespace(context.Background(), conn.GetNamespace(), `{"hostname":"gitlab.acme.internal"}`)
+		require.NoError(t, err)
+		conn.EncryptedConfiguration = &ef
+
+		settings, err := conn.GetTransportTLS(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, "gitlab.acme.internal", settings.ServerName)
+	})
+}
@@ -187,6 +187,21 @@ func (c *Connector) getJavascriptLibrary() (*apjs.Library, error) {
 	return c.jsLib, c.jsLibErr
 }
 
+// javascriptBudget returns the budget this connector's JavaScript runs under: the default budget, overridden by the
+// global config and then by the connector definition.
+func (c *Connector) javascriptBudget() apjs.Budget {
+	budget := apjs.DefaultBudget
+	if c.s != nil && c.s.cfg != nil {
+		if root := c.s.cfg.GetRoot(); root != nil {
+			budget = root.JavascriptBudget.Apply(budget)
+		}
+	}
+	if def, err := c.getDefinition(); err == nil && def != nil {
+		budget = def.JavascriptBudget.Apply(budget)
+	}
+	return budget
+}
+
 func (c *Connector) resetJavascriptLibrary() {
 	c.jsMu.Lock()
 	defer c.jsMu.Unlock()
@@ -4,14 +4,19 @@ import (
 	"context"
 	"encoding/json"
 	"testing"
+	"time"
 
 	"github.com/golang/mock/gomock"
 	"github.com/rmorlok/authproxy/internal/apid"
+	"github.com/rmorlok/authproxy/internal/apjs"
 	"github.com/rmorlok/authproxy/internal/aplog"
+	"github.com/rmorlok/authproxy/internal/config"
 	"github.com/rmorlok/authproxy/internal/database"
 	"github.com/rmorlok/authproxy/internal/encfield"
 	"github.com/rmorlok/authproxy/internal/encrypt"
 	encryptmock "github.com/rmorlok/authproxy/internal/encrypt/mock"
+	scommon "github.com/rmorlok/authproxy/internal/schema/common"
+	sconfig "github.com/rmorlok/authproxy/internal/schema/config"
 	cschema "github.com/rmorlok/authproxy/internal/schema/resources/connectors"
 	"github.com/rmorlok/authproxy/internal/util"
 	"github.com/stretchr/testify/assert"
@@ -200,6 +205,26 @@ func TestConnector_SetDefinitionResetsJavascriptLibrary(t *testing.T) {
 	assert.True(t, ok)
 }
 
+func TestConnector_JavascriptBudget(t *testing.T) {
+	c := NewTestConnector(cschema.Connector{
+		JavascriptBudget: &scommon.JavascriptBudget{MaxCallStackSize: util.ToPtr(200)},
+	})
+
+	assert.Equal(t, apjs.DefaultBudget.Timeout, c.javascriptBudget().Timeout)
+	assert.Equal(t, 200, c.javascriptBudget().MaxCallStackSize)
+
+	c.s.cfg = config.FromRoot(&sconfig.Root{
+		JavascriptBudget: &scommon.JavascriptBudget{
+			Timeout:          &scommon.HumanDuration{Duration: 5 * time.Second},
+			MaxCallStackSize: util.ToPtr(500),
+		},
+	})
+	budget := c.javascriptBudget()
+	assert.Equal(t, 5*time.Second, budget.Timeout)
+	assert.Equal(t, 200, budget.MaxCallStackSize)
+	assert.Equal(t, apjs.DefaultBudget.MaxOutputSize, budget.MaxOutputSize)
+}
+
 // NewTestConnector creates a hydrated test connector using the provided definition.
 func NewTestConnector(c cschema.Connector) *Connector {
 	e := encrypt.NewFakeEncryptService(false)
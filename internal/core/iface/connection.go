@@ -3,6 +3,7 @@ package iface
 import (
 	"context"
 	"net/http"
+	"net/url"
 	"time"
 
 	"github.com/rmorlok/authproxy/internal/apid"
@@ -57,6 +58,9 @@ type Connection interface {
 	GetConfiguration(ctx context.Context) (map[string]any, error)
 	SetConfiguration(ctx context.Context, data map[string]any) error
 	GetMustacheContext(ctx context.Context) (map[string]any, error)
+	// GetBaseUrl returns the connector's baseUrl rendered for this connection,
+	// or nil when the connector doesn't declare one.
+	GetBaseUrl(ctx context.Context) (*url.URL, error)
 	GetProbe(probeId string) (Probe, error)
 	GetProbes() []Probe
 	GetEnabledProbe(ctx context.Context, probeId string) (Probe, error)
@@ -66,6 +70,15 @@ type Connection interface {
 		reqType httpf.RequestType,
 		req *ProxyRequest,
 	) (*ProxyResponse, error)
+	// ProxyRequestPages follows the pages of a GET request using the
+	// connector's pagination rule for its path, passing each page to onPage
+	// as it is fetched.
+	ProxyRequestPages(
+		ctx context.Context,
+		reqType httpf.RequestType,
+		req *ProxyRequest,
+		onPage PageCallback,
+	) (*PaginationResult, error)
 	ProxyRequestRaw(
 		ctx context.Context,
 		reqType httpf.RequestType,
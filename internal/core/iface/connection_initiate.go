@@ -7,11 +7,12 @@ type InitiateConnectionRequest = schemaapi.InitiateConnectionRequest
 type ConnectionSetupResponseType = schemaapi.ConnectionSetupResponseType
 
 const (
-	ConnectionSetupResponseTypeRedirect  = schemaapi.ConnectionSetupResponseTypeRedirect
-	ConnectionSetupResponseTypeForm      = schemaapi.ConnectionSetupResponseTypeForm
-	ConnectionSetupResponseTypeComplete  = schemaapi.ConnectionSetupResponseTypeComplete
-	ConnectionSetupResponseTypeVerifying = schemaapi.ConnectionSetupResponseTypeVerifying
-	ConnectionSetupResponseTypeError     = schemaapi.ConnectionSetupResponseTypeError
+	ConnectionSetupResponseTypeRedirect   = schemaapi.ConnectionSetupResponseTypeRedirect
+	ConnectionSetupResponseTypeForm       = schemaapi.ConnectionSetupResponseTypeForm
+	ConnectionSetupResponseTypeComplete   = schemaapi.ConnectionSetupResponseTypeComplete
+	ConnectionSetupResponseTypeVerifying  = schemaapi.ConnectionSetupResponseTypeVerifying
+	ConnectionSetupResponseTypeDeviceCode = schemaapi.ConnectionSetupResponseTypeDeviceCode
+	ConnectionSetupResponseTypeError      = schemaapi.ConnectionSetupResponseTypeError
 )
 
 type ConnectionSetupResponse = schemaapi.ConnectionSetupResponse
@@ -19,5 +20,6 @@ type ConnectionSetupRedirect = schemaapi.ConnectionSetupRedirect
 type ConnectionSetupForm = schemaapi.ConnectionSetupForm
 type ConnectionSetupComplete = schemaapi.ConnectionSetupComplete
 type ConnectionSetupVerifying = schemaapi.ConnectionSetupVerifying
+type ConnectionSetupDeviceCode = schemaapi.ConnectionSetupDeviceCode
 type ConnectionSetupError = schemaapi.ConnectionSetupError
 type SubmitConnectionRequest = schemaapi.SubmitConnectionRequest
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"time"
 )
 
 // ManifestStepType identifies the runtime kind of a setup step. The schema
@@ -11,7 +12,8 @@ import (
 // to the manifest unchanged, plus the auth-method-emitted steps that get one
 // or the other depending on what the method needs at that point in the flow
 // (api-key and OAuth2 client_credentials emit form steps for credential
-// collection; OAuth2 authorization_code emits a redirect step).
+// collection; OAuth2 authorization_code emits a redirect step; OAuth2
+// device_code emits a device-code step).
 type ManifestStepType string
 
 const (
@@ -34,6 +36,13 @@ const (
 	// The user sees a "verifying" response and polls; OnSubmit /
 	// RenderRedirect both return the matching sentinel errors.
 	ManifestStepTypeVerify ManifestStepType = "verify"
+
+	// ManifestStepTypeDeviceCode is an auth-method-emitted wait on a user
+	// approving on a second device (RFC 8628). The setup API returns the user
+	// code and verification URI for the client to display; completion happens
+	// in a background task that polls the token endpoint, so the user polls
+	// the setup step rather than submitting anything.
+	ManifestStepTypeDeviceCode ManifestStepType = "device_code"
 )
 
 // Sentinel errors returned when a method is invoked on a step that doesn't
@@ -41,8 +50,9 @@ const (
 // dispatch by Type() before invoking the method; the sentinels exist so that
 // programmer errors fail loudly instead of silently misbehaving.
 var (
-	ErrSubmitNotSupported   = errors.New("step does not accept form submissions")
-	ErrRedirectNotSupported = errors.New("step is not a redirect step")
+	ErrSubmitNotSupported     = errors.New("step does not accept form submissions")
+	ErrRedirectNotSupported   = errors.New("step is not a redirect step")
+	ErrDeviceCodeNotSupported = errors.New("step is not a device code step")
 )
 
 // RedirectInfo is the resolved redirect for a redirect-type step. The setup
@@ -70,6 +80,26 @@ type RenderRedirectOptions struct {
 	ReturnToUrl string
 }
 
+// DeviceCodeInfo is the pending device authorization for a device-code step.
+// The HTTP layer turns it into the ConnectionSetupDeviceCode response.
+type DeviceCodeInfo struct {
+	// UserCode is the code the user enters at VerificationUri.
+	UserCode string
+
+	// VerificationUri is where the user approves the connection.
+	VerificationUri string
+
+	// VerificationUriComplete optionally embeds the user code in the URI so
+	// it can be opened or rendered as a QR code without typing. May be empty.
+	VerificationUriComplete string
+
+	// ExpiresAt is when the device code stops being accepted.
+	ExpiresAt time.Time
+
+	// Interval is how often the background task polls the token endpoint.
+	Interval time.Duration
+}
+
 // ManifestSetupStep is a single, fully-resolved step in a connection's setup
 // flow. Owners (schema-defined steps from the connector YAML, auth-method-
 // emitted steps from the auth method's factory) construct ManifestSetupStep
@@ -77,8 +107,8 @@ type RenderRedirectOptions struct {
 // they came from.
 //
 // All steps expose presentation metadata (Id, Title, Description, Type). Type
-// determines which of OnSubmit / RenderRedirect is meaningful — calling the
-// other returns the matching sentinel error.
+// determines which of OnSubmit / RenderRedirect / RenderDeviceCode is
+// meaningful — calling the others returns the matching sentinel error.
 type ManifestSetupStep interface {
 	// Id is the step identifier. User-authored steps carry the id from the
 	// connector YAML; system-emitted steps use the apxy: prefix (e.g.
@@ -114,6 +144,11 @@ type ManifestSetupStep interface {
 	// the URL.
 	RenderRedirect(ctx context.Context, opts RenderRedirectOptions) (RedirectInfo, error)
 
+	// RenderDeviceCode returns the pending device authorization for
+	// device-code steps, starting one if none is in flight. Other step types
+	// return ErrDeviceCodeNotSupported.
+	RenderDeviceCode(ctx context.Context) (DeviceCodeInfo, error)
+
 	// IsEligible reports whether this step should currently participate in
 	// the setup flow. Auth-method-emitted steps and apxy:* pseudo-steps are
 	// always eligible; connector-authored steps may use this to evaluate an
@@ -194,6 +229,24 @@ func NewRedirectStep(cfg RedirectStepConfig) ManifestSetupStep {
 	return &redirectStep{cfg: cfg}
 }
 
+// DeviceCodeStepConfig configures NewDeviceCodeStep.
+type DeviceCodeStepConfig struct {
+	Id          string
+	Title       string
+	Description string
+	IsEligible  func(ctx context.Context) (bool, error)
+	// Render returns the in-flight device authorization, or starts a new one
+	// when none is pending. Called on both the initial transition and resume,
+	// so it must be idempotent. Required.
+	Render func(ctx context.Context) (DeviceCodeInfo, error)
+}
+
+// NewDeviceCodeStep returns a device-code-type ManifestSetupStep backed by the
+// supplied configuration.
+func NewDeviceCodeStep(cfg DeviceCodeStepConfig) ManifestSetupStep {
+	return &deviceCodeStep{cfg: cfg}
+}
+
 // NewVerifyStep returns the synthetic apxy:verify pseudo-step that
 // ManifestSetupFlow uses to mark "credentials established, probes running."
 // The id and title are fixed; OnSubmit / RenderRedirect both return the
@@ -224,6 +277,10 @@ func (s *formStep) RenderRedirect(_ context.Context, _ RenderRedirectOptions) (R
 	return RedirectInfo{}, ErrRedirectNotSupported
 }
 
+func (s *formStep) RenderDeviceCode(_ context.Context) (DeviceCodeInfo, error) {
+	return DeviceCodeInfo{}, ErrDeviceCodeNotSupported
+}
+
 func (s *formStep) IsEligible(ctx context.Context) (bool, error) {
 	if s.cfg.IsEligible == nil {
 		return true, nil
@@ -253,6 +310,10 @@ func (s *redirectStep) RenderRedirect(ctx context.Context, opts RenderRedirectOp
 	return s.cfg.Render(ctx, opts)
 }
 
+func (s *redirectStep) RenderDeviceCode(_ context.Context) (DeviceCodeInfo, error) {
+	return DeviceCodeInfo{}, ErrDeviceCodeNotSupported
+}
+
 func (s *redirectStep) IsEligible(ctx context.Context) (bool, error) {
 	if s.cfg.IsEligible == nil {
 		return true, nil
@@ -260,6 +321,39 @@ func (s *redirectStep) IsEligible(ctx context.Context) (bool, error) {
 	return s.cfg.IsEligible(ctx)
 }
 
+type deviceCodeStep struct {
+	cfg DeviceCodeStepConfig
+}
+
+func (s *deviceCodeStep) Id() string                  { return s.cfg.Id }
+func (s *deviceCodeStep) Title() string               { return s.cfg.Title }
+func (s *deviceCodeStep) Description() string         { return s.cfg.Description }
+func (s *deviceCodeStep) Type() ManifestStepType      { return ManifestStepTypeDeviceCode }
+func (s *deviceCodeStep) JsonSchema() json.RawMessage { return nil }
+func (s *deviceCodeStep) UiSchema() json.RawMessage   { return nil }
+
+func (s *deviceCodeStep) OnSubmit(_ context.Context, _ json.RawMessage) error {
+	return ErrSubmitNotSupported
+}
+
+func (s *deviceCodeStep) RenderRedirect(_ context.Context, _ RenderRedirectOptions) (RedirectInfo, error) {
+	return RedirectInfo{}, ErrRedirectNotSupported
+}
+
+func (s *deviceCodeStep) RenderDeviceCode(ctx context.Context) (DeviceCodeInfo, error) {
+	if s.cfg.Render == nil {
+		return DeviceCodeInfo{}, ErrDeviceCodeNotSupported
+	}
+	return s.cfg.Render(ctx)
+}
+
+func (s *deviceCodeStep) IsEligible(ctx context.Context) (bool, error) {
+	if s.cfg.IsEligible == nil {
+		return true, nil
+	}
+	return s.cfg.IsEligible(ctx)
+}
+
 // verifyStep is the synthetic apxy:verify pseudo-step. Stateless — no
 // configuration is needed since its sole purpose is to mark "probes are
 // running; the UI should display Verifying."
@@ -280,6 +374,10 @@ func (s *verifyStep) RenderRedirect(_ context.Context, _ RenderRedirectOptions)
 	return RedirectInfo{}, ErrRedirectNotSupported
 }
 
+func (s *verifyStep) RenderDeviceCode(_ context.Context) (DeviceCodeInfo, error) {
+	return DeviceCodeInfo{}, ErrDeviceCodeNotSupported
+}
+
 func (s *verifyStep) IsEligible(_ context.Context) (bool, error) {
 	return true, nil
 }
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -108,3 +109,40 @@ func TestRedirectStep_NilRenderReturnsSentinel(t *testing.T) {
 	_, err := step.RenderRedirect(context.Background(), iface.RenderRedirectOptions{})
 	assert.ErrorIs(t, err, iface.ErrRedirectNotSupported)
 }
+
+// TestDeviceCodeStep_DispatchSurface verifies that NewDeviceCodeStep produces
+// a step whose Type is device_code, whose RenderDeviceCode invokes the
+// supplied closure, and whose OnSubmit / RenderRedirect return the sentinels.
+func TestDeviceCodeStep_DispatchSurface(t *testing.T) {
+	expiresAt := time.Date(2025, 1, 1, 0, 15, 0, 0, time.UTC)
+	step := iface.NewDeviceCodeStep(iface.DeviceCodeStepConfig{
+		Id:    "apxy:auth:oauth2_device_code",
+		Title: "Authorize on another device",
+		Render: func(_ context.Context) (iface.DeviceCodeInfo, error) {
+			return iface.DeviceCodeInfo{
+				UserCode:        "WDJB-MJHT",
+				VerificationUri: "https://provider.example.com/device",
+				ExpiresAt:       expiresAt,
+				Interval:        5 * time.Second,
+			}, nil
+		},
+	})
+
+	assert.Equal(t, "apxy:auth:oauth2_device_code", step.Id())
+	assert.Equal(t, iface.ManifestStepTypeDeviceCode, step.Type())
+	assert.Nil(t, step.JsonSchema())
+	assert.Nil(t, step.UiSchema())
+
+	info, err := step.RenderDeviceCode(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "WDJB-MJHT", info.UserCode)
+	assert.Equal(t, "https://provider.example.com/device", info.VerificationUri)
+	assert.Equal(t, expiresAt, info.ExpiresAt)
+
+	assert.ErrorIs(t, step.OnSubmit(context.Background(), nil), iface.ErrSubmitNotSupported)
+	_, err = step.RenderRedirect(context.Background(), iface.RenderRedirectOptions{})
+	assert.ErrorIs(t, err, iface.ErrRedirectNotSupported)
+
+	_, err = iface.NewFormStep(iface.FormStepConfig{Id: "x"}).RenderDeviceCode(context.Background())
+	assert.ErrorIs(t, err, iface.ErrDeviceCodeNotSupported)
+}
@@ -4,8 +4,10 @@ import (
 	"bytes"
 	"context"
 	"net/http"
+	"net/url"
 	"strings"
 
+	"github.com/rmorlok/authproxy/internal/apgraphql"
 	"github.com/rmorlok/authproxy/internal/database"
 	"github.com/rmorlok/authproxy/internal/httperr"
 	"github.com/rmorlok/authproxy/internal/httpf"
@@ -22,6 +24,15 @@ type ProxyRequest struct {
 	Labels   map[string]string     `json:"labels,omitempty"`
 	BodyRaw  []byte                `json:"bodyRaw,omitempty"`
 	BodyJson interface{}           `json:"bodyJson,omitempty"`
+
+	// Paginate, when set, follows the pages of a GET request using the
+	// connector's pagination rule for the URL's path.
+	Paginate *ProxyPaginate `json:"paginate,omitempty"`
+
+	// Priority tags the request with a priority class for rate limiting.
+	// Empty means normal. The API routes check the caller may use the
+	// priority before it gets here.
+	Priority common.RequestPriority `json:"priority,omitempty"`
 }
 
 func (r *ProxyRequest) Apply(req *gentleman.Request) {
@@ -41,6 +52,18 @@ func (r *ProxyRequest) Apply(req *gentleman.Request) {
 	}
 }
 
+// GraphqlOperation returns the GraphQL operation the request body executes,
+// or nil when the body isn't a GraphQL request. Only POST bodies are read.
+func (r *ProxyRequest) GraphqlOperation() *apgraphql.Operation {
+	if !strings.EqualFold(r.Method, http.MethodPost) {
+		return nil
+	}
+	if r.BodyJson != nil {
+		return apgraphql.FromJSON(r.BodyJson)
+	}
+	return apgraphql.FromBody(r.BodyRaw)
+}
+
 func (r *ProxyRequest) Validate() error {
 	errors := make([]string, 0)
 
@@ -79,6 +102,19 @@ func (r *ProxyRequest) Validate() error {
 		}
 	}
 
+	if r.Priority != "" && !common.IsValidRequestPriority(r.Priority) {
+		errors = append(errors, "invalid priority")
+	}
+
+	if r.Paginate != nil {
+		if r.Method != http.MethodGet {
+			errors = append(errors, "paginate is only supported for GET requests")
+		}
+		if err := r.Paginate.Validate(); err != nil {
+			errors = append(errors, err.Error())
+		}
+	}
+
 	if len(errors) > 0 {
 		return httperr.BadRequest(strings.Join(errors, ", "))
 	}
@@ -142,9 +178,24 @@ type RawProxyRequest struct {
 	// request events, OTel) — same shape as ProxyRequest.Labels for the
 	// wrapped path.
 	Labels map[string]string
+	// Priority tags the request with a priority class for rate limiting —
+	// same as ProxyRequest.Priority for the wrapped path.
+	Priority common.RequestPriority
+	// ResponseUrlRewrite, when set, rewrites upstream URLs in the
+	// response's Location and Link headers to the caller-facing route, so
+	// redirects and pagination links keep going through the proxy.
+	ResponseUrlRewrite *UrlRewrite
+}
+
+// UrlRewrite maps URLs under From onto the same relative path under To.
+// URLs that aren't under From are left unchanged.
+type UrlRewrite struct {
+	From *url.URL
+	To   *url.URL
 }
 
 type Proxy interface {
 	ProxyRequest(ctx context.Context, reqType httpf.RequestType, req *ProxyRequest) (*ProxyResponse, error)
+	ProxyRequestPages(ctx context.Context, reqType httpf.RequestType, req *ProxyRequest, onPage PageCallback) (*PaginationResult, error)
 	ProxyRequestRaw(ctx context.Context, reqType httpf.RequestType, req *RawProxyRequest, w http.ResponseWriter) error
 }
@@ -0,0 +1,39 @@
+package iface
+
+import (
+	schemaapi "github.com/rmorlok/authproxy/internal/schema/api"
+)
+
+type ProxyPaginate = schemaapi.ProxyPaginateJson
+type ProxyPaginateFormat = schemaapi.ProxyPaginateFormat
+
+const (
+	ProxyPaginateFormatMerge  = schemaapi.ProxyPaginateFormatMerge
+	ProxyPaginateFormatNDJSON = schemaapi.ProxyPaginateFormatNDJSON
+)
+
+// ProxyPage is one page of a paginated proxy request. Items is nil when the
+// upstream answered the page with a status of 300 or above; the response is
+// then passed along as-is and pagination ends.
+type ProxyPage struct {
+	// Number is the 1-based page number.
+	Number   int
+	Response *ProxyResponse
+	Items    []any
+}
+
+// PageCallback receives each page of a paginated proxy request as it is
+// fetched. Returning an error stops pagination and is returned to the
+// caller.
+type PageCallback func(*ProxyPage) error
+
+// PaginationResult totals a paginated proxy request.
+type PaginationResult struct {
+	Pages int
+	Items int
+	Bytes int64
+
+	// Truncated is set when MaxPages or MaxBytes stopped pagination while
+	// the upstream still had more pages.
+	Truncated bool
+}
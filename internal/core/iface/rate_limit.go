@@ -105,3 +105,21 @@ type DryRunRateLimitNotMatched struct {
 	Namespace   string
 	Reason      string
 }
+
+// RateLimitUsage is the output of C.GetRateLimitUsage: how much of a
+// quota rule's allowance each bucket has consumed in the current period.
+type RateLimitUsage struct {
+	RateLimitId apid.ID
+	PeriodStart time.Time
+	PeriodEnd   time.Time
+	Limit       int
+
+	// Buckets holds every bucket that has consumed anything this period,
+	// most-used first.
+	Buckets []RateLimitBucketUsage
+}
+
+type RateLimitBucketUsage struct {
+	BucketKey string
+	Used      int
+}
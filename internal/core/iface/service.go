@@ -358,6 +358,11 @@ type C interface {
 	// post-hydration namespace and label snapshot.
 	DryRunRateLimit(ctx context.Context, req DryRunRateLimitRequest) (DryRunRateLimitResult, error)
 
+	// GetRateLimitUsage reports per-bucket consumption of a quota rule for
+	// the current period. Returns ErrInvalidArgument for rules that don't
+	// use the quota algorithm.
+	GetRateLimitUsage(ctx context.Context, id apid.ID) (RateLimitUsage, error)
+
 	/*
 	 *
 	 * Tasks
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"net/url"
 	"time"
 
 	"github.com/rmorlok/authproxy/internal/apid"
@@ -32,6 +33,7 @@ type Connection struct {
 	SetupError        *string
 	Configuration     map[string]any
 	JavascriptLibrary *apjs.Library
+	BaseUrl           *url.URL
 }
 
 func (m *Connection) GetId() apid.ID {
@@ -123,6 +125,15 @@ func (m *Connection) ProxyRequest(
 	return nil, nil
 }
 
+func (m *Connection) ProxyRequestPages(
+	ctx context.Context,
+	reqType httpf.RequestType,
+	req *iface.ProxyRequest,
+	onPage iface.PageCallback,
+) (*iface.PaginationResult, error) {
+	return nil, nil
+}
+
 func (m *Connection) ProxyRequestRaw(
 	ctx context.Context,
 	reqType httpf.RequestType,
@@ -203,6 +214,10 @@ func (m *Connection) GetMustacheContext(ctx context.Context) (map[string]any, er
 	return data, nil
 }
 
+func (m *Connection) GetBaseUrl(ctx context.Context) (*url.URL, error) {
+	return m.BaseUrl, nil
+}
+
 func (m *Connection) SubmitForm(ctx context.Context, req iface.SubmitConnectionRequest) (iface.ConnectionSetupResponse, error) {
 	return nil, fmt.Errorf("not implemented")
 }
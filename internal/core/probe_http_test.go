@@ -32,6 +32,10 @@ func (s *stubProxy) ProxyRequest(ctx context.Context, _ httpf.RequestType, req *
 	return s.resp, s.err
 }
 
+func (s *stubProxy) ProxyRequestPages(ctx context.Context, _ httpf.RequestType, _ *iface.ProxyRequest, _ iface.PageCallback) (*iface.PaginationResult, error) {
+	panic("ProxyRequestPages should not be invoked by probe_http")
+}
+
 func (s *stubProxy) ProxyRequestRaw(ctx context.Context, _ httpf.RequestType, _ *iface.RawProxyRequest, _ http.ResponseWriter) error {
 	panic("ProxyRequestRaw should not be invoked by probe_http")
 }
@@ -7,12 +7,15 @@ import (
 	"github.com/cschleiden/go-workflows/client"
 	wflib "github.com/cschleiden/go-workflows/workflow"
 	"github.com/rmorlok/authproxy/internal/apasynq"
+	"github.com/rmorlok/authproxy/internal/apid"
 	"github.com/rmorlok/authproxy/internal/apredis"
 	"github.com/rmorlok/authproxy/internal/aptelemetry"
 	"github.com/rmorlok/authproxy/internal/auth_methods"
 	"github.com/rmorlok/authproxy/internal/auth_methods/api_key"
 	"github.com/rmorlok/authproxy/internal/auth_methods/no_auth"
+	"github.com/rmorlok/authproxy/internal/auth_methods/oauth1"
 	"github.com/rmorlok/authproxy/internal/auth_methods/oauth2"
+	"github.com/rmorlok/authproxy/internal/auth_methods/request_signing"
 	"github.com/rmorlok/authproxy/internal/config"
 	"github.com/rmorlok/authproxy/internal/core/iface"
 	"github.com/rmorlok/authproxy/internal/database"
@@ -121,11 +124,14 @@ func (s *service) buildAuthMethodFactories() map[cschema.AuthType]auth_methods.F
 	if s.telProviders != nil {
 		oauth2Opts = append(oauth2Opts, oauth2.WithTelemetry(s.telProviders, s.telCfg))
 	}
+	oauth2Opts = append(oauth2Opts, oauth2.WithTaskClient(s.ac))
 
 	return map[cschema.AuthType]auth_methods.Factory{
-		cschema.AuthTypeOAuth2: oauth2.NewFactory(s.cfg, s.db, s.r, s, s.httpf, s.encrypt, s.logger, oauth2Opts...),
-		cschema.AuthTypeAPIKey: api_key.NewFactory(s.db, s.encrypt, s.httpf, s.logger),
-		cschema.AuthTypeNoAuth: no_auth.NewFactory(),
+		cschema.AuthTypeOAuth1:         oauth1.NewFactory(s.cfg, s.db, s.r, s, s.httpf, s.encrypt, s.logger),
+		cschema.AuthTypeOAuth2:         oauth2.NewFactory(s.cfg, s.db, s.r, s, s.httpf, s.encrypt, s.logger, oauth2Opts...),
+		cschema.AuthTypeAPIKey:         api_key.NewFactory(s.db, s.encrypt, s.httpf, s.logger),
+		cschema.AuthTypeNoAuth:         no_auth.NewFactory(),
+		cschema.AuthTypeRequestSigning: request_signing.NewFactory(s.db, s.encrypt, s.logger),
 	}
 }
 
@@ -141,3 +147,13 @@ func (s *service) getAuthMethodFactory(connector *cschema.Connector) auth_method
 }
 
 var _ iface.C = (*service)(nil)
+
+// releaseConnectionTransport drops the outbound transport httpf caches for a
+// connection, so a deleted connection doesn't keep its idle TLS connections
+// and a changed client certificate or TLS settings take effect on the next
+// handshake.
+func (s *service) releaseConnectionTransport(id apid.ID) {
+	if r, ok := s.httpf.(httpf.ConnectionReleaser); ok {
+		r.ReleaseConnection(id)
+	}
+}
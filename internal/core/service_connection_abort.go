@@ -41,6 +41,7 @@ func (s *service) AbortConnection(ctx context.Context, id apid.ID) error {
 	if err := s.db.DeleteConnection(ctx, id); err != nil {
 		return fmt.Errorf("failed to delete connection during abort: %w", err)
 	}
+	s.releaseConnectionTransport(id)
 
 	s.logger.Info("connection setup aborted", "id", id)
 	return nil
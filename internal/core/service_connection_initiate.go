@@ -122,7 +122,7 @@ func (s *service) InitiateConnection(
 	first, err := flow.FirstStep(ctx)
 	if err != nil {
 		val.MarkErrorReturn()
-		return nil, httperr.InternalServerError(httperr.WithInternalErrorf("failed to evaluate setup flow: %w", err))
+		return nil, setupFlowEvaluationError(err)
 	}
 	if first == nil {
 		// No setup steps to walk through — the connection is immediately
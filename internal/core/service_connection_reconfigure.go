@@ -28,7 +28,7 @@ func (c *connection) Reconfigure(ctx context.Context) (iface.ConnectionSetupResp
 		candidateId := connector.SetupFlow.Configure.Steps[i].Id
 		candidate, ok, err := flow.StepById(ctx, candidateId)
 		if err != nil {
-			return nil, httperr.InternalServerError(httperr.WithInternalErrorf("failed to evaluate setup flow: %w", err))
+			return nil, setupFlowEvaluationError(err)
 		}
 		if ok {
 			step = candidate
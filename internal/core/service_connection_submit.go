@@ -25,7 +25,7 @@ func (c *connection) SubmitForm(ctx context.Context, req iface.SubmitConnectionR
 	flow := c.s.buildManifestSetupFlow(c)
 	current, ok, err := flow.StepById(ctx, setupStep.Id())
 	if err != nil {
-		return nil, httperr.InternalServerError(httperr.WithInternalErrorf("failed to evaluate setup flow: %w", err))
+		return nil, setupFlowEvaluationError(err)
 	}
 	if !ok {
 		return nil, httperr.InternalServerErrorMsg("current setup step is not addressable in manifest")
@@ -40,7 +40,7 @@ func (c *connection) SubmitForm(ctx context.Context, req iface.SubmitConnectionR
 
 	next, hasNext, err := flow.NextStep(ctx, current.Id())
 	if err != nil {
-		return nil, httperr.InternalServerError(httperr.WithInternalErrorf("failed to evaluate setup flow: %w", err))
+		return nil, setupFlowEvaluationError(err)
 	}
 	if !hasNext {
 		resp, err := c.completeFlow(ctx)
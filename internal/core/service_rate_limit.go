@@ -227,6 +227,38 @@ func (s *service) ListRateLimitsFromCursor(ctx context.Context, cursor string) (
 
 var _ iface.ListRateLimitsBuilder = (*listRateLimitsWrapper)(nil)
 
+// GetRateLimitUsage reads the current period's per-bucket consumption of
+// a quota rule straight from Redis.
+func (s *service) GetRateLimitUsage(ctx context.Context, id apid.ID) (iface.RateLimitUsage, error) {
+	rl, err := s.db.GetRateLimit(ctx, id)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return iface.RateLimitUsage{}, ErrNotFound
+		}
+		return iface.RateLimitUsage{}, err
+	}
+
+	usage, err := ratelimit.GetQuotaUsage(ctx, s.r, rl)
+	if err != nil {
+		if errors.Is(err, ratelimit.ErrNotQuota) {
+			return iface.RateLimitUsage{}, fmt.Errorf("%w: %s", ErrInvalidArgument, err.Error())
+		}
+		return iface.RateLimitUsage{}, err
+	}
+
+	buckets := make([]iface.RateLimitBucketUsage, 0, len(usage.Buckets))
+	for _, b := range usage.Buckets {
+		buckets = append(buckets, iface.RateLimitBucketUsage{BucketKey: b.BucketKey, Used: b.Used})
+	}
+	return iface.RateLimitUsage{
+		RateLimitId: rl.Id,
+		PeriodStart: usage.PeriodStart,
+		PeriodEnd:   usage.PeriodEnd,
+		Limit:       usage.Limit,
+		Buckets:     buckets,
+	}, nil
+}
+
 // DryRunRateLimit evaluates which cached rules would apply to the
 // synthesized request and what Limiter.Peek says about each — without
 // writing to any counter. Hydration mirrors the runtime: when
@@ -250,6 +282,9 @@ func (s *service) DryRunRateLimit(ctx context.Context, req iface.DryRunRateLimit
 	if !common.IsValidRequestType(req.RequestType) {
 		return iface.DryRunRateLimitResult{}, fmt.Errorf("%w: invalid requestType %q", ErrInvalidArgument, req.RequestType)
 	}
+	if req.Request.Priority != "" && !common.IsValidRequestPriority(req.Request.Priority) {
+		return iface.DryRunRateLimitResult{}, fmt.Errorf("%w: invalid priority %q", ErrInvalidArgument, req.Request.Priority)
+	}
 	if req.Context.ConnectionId == nil && req.Context.Namespace == nil {
 		return iface.DryRunRateLimitResult{}, fmt.Errorf("%w: connectionId or namespace is required", ErrInvalidArgument)
 	}
@@ -298,7 +333,9 @@ func (s *service) DryRunRateLimit(ctx context.Context, req iface.DryRunRateLimit
 			continue
 		}
 
-		limiter, lErr := ratelimit.NewLimiter(rule, s.r, s.logger)
+		// Peek as the request's priority so WouldAllow and Remaining
+		// account for capacity the rule reserves for higher classes.
+		limiter, lErr := ratelimit.NewPriorityLimiter(rule, reqCtx.Priority, s.r, s.logger)
 		if lErr != nil {
 			// Skip; the cache shouldn't hold rules whose algorithm
 			// validation failed, so this is a should-not-happen.
@@ -339,9 +376,11 @@ func (s *service) hydrateDryRunContext(ctx context.Context, req iface.DryRunRate
 	}
 
 	rc := &ratelimit.RequestContext{
-		Type:        common.RequestType(req.RequestType),
-		Method:      strings.ToUpper(req.Request.Method),
-		UpstreamURL: u,
+		Type:             common.RequestType(req.RequestType),
+		Method:           strings.ToUpper(req.Request.Method),
+		UpstreamURL:      u,
+		GraphqlOperation: req.Request.GraphqlOperation(),
+		Priority:         req.Request.Priority,
 	}
 
 	if req.Context.ConnectionId != nil && !req.Context.ConnectionId.IsNil() {
@@ -409,6 +448,10 @@ func algorithmSummary(def rlschema.RateLimit) string {
 		return fmt.Sprintf("sliding window (%s) %d / %s",
 			a.SlidingWindow.Mode, a.SlidingWindow.Limit, a.SlidingWindow.Window.Duration,
 		)
+	case a.Concurrency != nil:
+		return fmt.Sprintf("concurrency %d in flight", a.Concurrency.Limit)
+	case a.Quota != nil:
+		return fmt.Sprintf("quota %d / %s (%s)", a.Quota.Limit, a.Quota.Period, a.Quota.GetTimezone())
 	}
 	return "—"
 }
@@ -133,6 +133,13 @@ func TestDryRunRateLimit_ValidationErrors(t *testing.T) {
 		require.ErrorIs(t, err, ErrInvalidArgument)
 	})
 
+	t.Run("invalid priority", func(t *testing.T) {
+		req := validBaseReq()
+		req.Request.Priority = "urgent"
+		_, err := svc.DryRunRateLimit(context.Background(), req)
+		require.ErrorIs(t, err, ErrInvalidArgument)
+	})
+
 	t.Run("no connection or namespace", func(t *testing.T) {
 		req := validBaseReq()
 		req.Context = iface.DryRunRequestContext{}
@@ -160,6 +167,91 @@ func TestDryRunRateLimit_PeekDoesNotMutate(t *testing.T) {
 	}
 }
 
+func TestDryRunRateLimit_ConcurrencyDoesNotAcquireLease(t *testing.T) {
+	svc, rlCache, done := newDryRunService(t)
+	defer done()
+
+	def := freshTokenBucket()
+	def.Algorithm = rlschema.Algorithm{Concurrency: &rlschema.Concurrency{Limit: 1}}
+	installRule(t, svc, rlCache, "root", def)
+
+	// A limit of one would reject the second request if the dry-run held
+	// a lease.
+	for i := 0; i < 3; i++ {
+		res, err := svc.DryRunRateLimit(context.Background(), validBaseReq())
+		require.NoError(t, err)
+		require.Len(t, res.Matched, 1)
+		require.True(t, res.Matched[0].WouldAllow, "iteration %d", i+1)
+		require.Equal(t, 0, res.Matched[0].Remaining)
+		require.Equal(t, "concurrency 1 in flight", res.Matched[0].AlgorithmSummary)
+	}
+}
+
+func TestDryRunRateLimit_QuotaDoesNotConsume(t *testing.T) {
+	svc, rlCache, done := newDryRunService(t)
+	defer done()
+
+	def := freshTokenBucket()
+	def.Algorithm = rlschema.Algorithm{Quota: &rlschema.Quota{Period: rlschema.QuotaPeriodDay, Limit: 1, Timezone: "Europe/Paris"}}
+	installRule(t, svc, rlCache, "root", def)
+
+	for i := 0; i < 3; i++ {
+		res, err := svc.DryRunRateLimit(context.Background(), validBaseReq())
+		require.NoError(t, err)
+		require.Len(t, res.Matched, 1)
+		require.True(t, res.Matched[0].WouldAllow, "iteration %d", i+1)
+		require.Equal(t, "quota 1 / day (Europe/Paris)", res.Matched[0].AlgorithmSummary)
+	}
+}
+
+func TestDryRunRateLimit_Priority(t *testing.T) {
+	svc, rlCache, done := newDryRunService(t)
+	defer done()
+
+	def := freshTokenBucket()
+	def.Algorithm = rlschema.Algorithm{TokenBucket: &rlschema.TokenBucket{Capacity: 10, RefillRate: 1.0}}
+	def.Priority = &rlschema.Priority{Reserve: map[common.RequestPriority]float64{
+		common.RequestPriorityInteractive: 0.5,
+	}}
+	installRule(t, svc, rlCache, "root", def)
+
+	remaining := func(priority common.RequestPriority) int {
+		req := validBaseReq()
+		req.Request.Priority = priority
+		res, err := svc.DryRunRateLimit(context.Background(), req)
+		require.NoError(t, err)
+		require.Len(t, res.Matched, 1)
+		require.True(t, res.Matched[0].WouldAllow)
+		return res.Matched[0].Remaining
+	}
+
+	require.Equal(t, 9, remaining(common.RequestPriorityInteractive))
+	require.Equal(t, 4, remaining(""))
+	require.Equal(t, 4, remaining(common.RequestPriorityBatch))
+}
+
+func TestDryRunRateLimit_PrioritySelector(t *testing.T) {
+	svc, rlCache, done := newDryRunService(t)
+	defer done()
+
+	def := freshTokenBucket()
+	def.Selector.Priorities = []common.RequestPriority{common.RequestPriorityBatch}
+	rule := installRule(t, svc, rlCache, "root", def)
+
+	res, err := svc.DryRunRateLimit(context.Background(), validBaseReq())
+	require.NoError(t, err)
+	require.Empty(t, res.Matched)
+	require.Len(t, res.NotMatched, 1)
+	require.Contains(t, res.NotMatched[0].Reason, "priority")
+
+	req := validBaseReq()
+	req.Request.Priority = common.RequestPriorityBatch
+	res, err = svc.DryRunRateLimit(context.Background(), req)
+	require.NoError(t, err)
+	require.Len(t, res.Matched, 1)
+	require.Equal(t, rule.Id, res.Matched[0].RateLimitId)
+}
+
 func TestDryRunRateLimit_NamespaceCascade(t *testing.T) {
 	svc, rlCache, done := newDryRunService(t)
 	defer done()
@@ -15,6 +15,8 @@ func (s *service) RegisterTasks(mux *asynq.ServeMux) {
 	mux.HandleFunc(taskTypeProbe, s.runProbeForConnection)
 	mux.HandleFunc(taskTypeVerifyConnection, s.verifyConnection)
 	mux.HandleFunc(taskTypeProbeOutcomeCleanup, s.runProbeOutcomeCleanup)
+	mux.HandleFunc(taskTypeClientCertificateExpiry, s.runClientCertificateExpiry)
+	mux.HandleFunc(taskTypeRateLimitQuotaSoftLimits, s.runRateLimitQuotaSoftLimits)
 }
 
 func (s *service) GetCronTasks() []*asynq.PeriodicTaskConfig {
@@ -89,5 +91,19 @@ func (s *service) GetCronTasks() []*asynq.PeriodicTaskConfig {
 		})
 	}
 
+	// Daily client certificate expiry check. Expiry warnings are measured in
+	// days, so a daily sweep raises them in good time.
+	periodTasks = append(periodTasks, &asynq.PeriodicTaskConfig{
+		Task:     newClientCertificateExpiryTask(),
+		Cronspec: "@every 24h",
+	})
+
+	// Quota soft-limit check. Frequent enough that a bucket burning through
+	// its allowance is flagged well before it runs out.
+	periodTasks = append(periodTasks, &asynq.PeriodicTaskConfig{
+		Task:     newRateLimitQuotaSoftLimitsTask(),
+		Cronspec: "@every 5m",
+	})
+
 	return periodTasks
 }
@@ -74,10 +74,12 @@ func TestGetCronTasks_SchedulesOnlyEnabledPeriodicProbes(t *testing.T) {
 		Return(string(connJSON), nil)
 
 	tasks := svc.GetCronTasks()
-	require.Len(t, tasks, 2)
+	require.Len(t, tasks, 4)
 	assert.Equal(t, taskTypeProbe, tasks[0].Task.Type())
 	assert.Equal(t, "@every 1m0s", tasks[0].Cronspec)
 	assert.Equal(t, taskTypeProbeOutcomeCleanup, tasks[1].Task.Type())
+	assert.Equal(t, taskTypeClientCertificateExpiry, tasks[2].Task.Type())
+	assert.Equal(t, taskTypeRateLimitQuotaSoftLimits, tasks[3].Task.Type())
 }
 
 type staticListConnectionsBuilder struct {
@@ -0,0 +1,62 @@
+package core
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hibiken/asynq"
+	"github.com/rmorlok/authproxy/internal/aplog"
+	"github.com/rmorlok/authproxy/internal/database"
+	"github.com/rmorlok/authproxy/internal/util/pagination"
+)
+
+const taskTypeClientCertificateExpiry = "core:client_certificate_expiry"
+
+func newClientCertificateExpiryTask() *asynq.Task {
+	return asynq.NewTask(taskTypeClientCertificateExpiry, nil)
+}
+
+// runClientCertificateExpiry walks every live connection whose connector
+// requires mutual TLS and raises (or resolves) the client certificate expiry
+// notification. Errors on individual connections are logged and skipped so
+// one bad certificate doesn't stop the sweep.
+func (s *service) runClientCertificateExpiry(ctx context.Context, t *asynq.Task) error {
+	logger := aplog.NewBuilder(s.logger).
+		WithTask(t).
+		WithCtx(ctx).
+		Build()
+	logger.Info("client certificate expiry check starting")
+
+	checked := 0
+	err := s.db.ListConnectionsBuilder().
+		WithDeletedHandling(database.DeletedHandlingExclude).
+		ForStates([]database.ConnectionState{
+			database.ConnectionStateSetup,
+			database.ConnectionStateConfigured,
+		}).
+		Enumerate(ctx, func(pr pagination.PageResult[database.Connection]) (pagination.KeepGoing, error) {
+			for _, dbConn := range pr.Results {
+				c, err := s.getConnectionForDb(ctx, &dbConn)
+				if err != nil {
+					logger.Error("failed to load connection for client certificate expiry check",
+						"connection_id", dbConn.Id, "error", err)
+					continue
+				}
+				if !c.RequiresClientCertificate() {
+					continue
+				}
+				checked++
+				if err := s.checkClientCertificateExpiry(ctx, c); err != nil {
+					logger.Error("failed to check client certificate expiry",
+						"connection_id", dbConn.Id, "error", err)
+				}
+			}
+			return pagination.Continue, nil
+		})
+	if err != nil {
+		return fmt.Errorf("enumerate connections: %w", err)
+	}
+
+	logger.Info("client certificate expiry check complete", "connections_checked", checked)
+	return nil
+}
@@ -9,6 +9,7 @@ import (
 
 	"github.com/hibiken/asynq"
 	"github.com/rmorlok/authproxy/internal/apid"
+	"github.com/rmorlok/authproxy/internal/apjs"
 	"github.com/rmorlok/authproxy/internal/aplog"
 	"github.com/rmorlok/authproxy/internal/core/iface"
 	"github.com/rmorlok/authproxy/internal/database"
@@ -102,6 +103,11 @@ func (s *service) runProbeInternal(ctx context.Context, logger *slog.Logger, con
 			logger.Info("probe disabled; skipping", "error", err)
 			return nil, fmt.Errorf("%s probe disabled: %w", taskTypeProbe, asynq.SkipRetry)
 		}
+		if apjs.IsBudgetExceeded(err) {
+			// Retrying won't bring the probe's condition within its budget.
+			logger.Error("probe condition script exceeded budget", "error", err)
+			return nil, fmt.Errorf("%s probe condition: %w: %w", taskTypeProbe, err, asynq.SkipRetry)
+		}
 
 		return probe, err
 	}
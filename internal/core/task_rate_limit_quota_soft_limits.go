@@ -0,0 +1,191 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/rmorlok/authproxy/internal/apid"
+	"github.com/rmorlok/authproxy/internal/aplog"
+	"github.com/rmorlok/authproxy/internal/database"
+	"github.com/rmorlok/authproxy/internal/ratelimit"
+	aschema "github.com/rmorlok/authproxy/internal/schema/auth"
+	"github.com/rmorlok/authproxy/internal/util/pagination"
+)
+
+const taskTypeRateLimitQuotaSoftLimits = "core:rate_limit_quota_soft_limits"
+
+// maxActiveQuotaSoftLimitNotifications bounds how many active soft-limit
+// notifications one sweep reconciles.
+const maxActiveQuotaSoftLimitNotifications = 10000
+
+func newRateLimitQuotaSoftLimitsTask() *asynq.Task {
+	return asynq.NewTask(taskTypeRateLimitQuotaSoftLimits, nil)
+}
+
+// quotaSoftLimitNotificationKeyPrefix is shared by every soft-limit
+// notification for a rule; the bucket is hashed onto the end because
+// bucket keys can be long and contain arbitrary label values.
+func quotaSoftLimitNotificationKeyPrefix(rateLimitID apid.ID) string {
+	return fmt.Sprintf("rate_limit:%s:%s:", rateLimitID, database.NotificationKeyQuotaSoftLimit)
+}
+
+func quotaSoftLimitNotificationKey(rateLimitID apid.ID, bucketKey string) string {
+	return quotaSoftLimitNotificationKeyPrefix(rateLimitID) + sha256Hex([]byte(bucketKey))[:16]
+}
+
+// runRateLimitQuotaSoftLimits walks every live quota rule with soft limits
+// and raises a notification for each bucket that has crossed one in the
+// current period. Notifications for buckets back under their soft limits —
+// typically because the period rolled over — are resolved. A rule whose
+// usage can't be read keeps its notifications as they were.
+func (s *service) runRateLimitQuotaSoftLimits(ctx context.Context, t *asynq.Task) error {
+	logger := aplog.NewBuilder(s.logger).
+		WithTask(t).
+		WithCtx(ctx).
+		Build()
+	logger.Info("rate limit quota soft limit check starting")
+
+	notifications, err := s.db.ListNotifications(ctx, database.ListNotificationsOptions{
+		States:       []database.NotificationState{database.NotificationStateActive},
+		ResourceType: "rate_limit",
+		Limit:        maxActiveQuotaSoftLimitNotifications,
+	})
+	if err != nil {
+		return fmt.Errorf("list notifications: %w", err)
+	}
+
+	// stale starts as every active soft-limit notification; checking a rule
+	// removes the ones that still apply.
+	stale := make(map[string]database.Notification, len(notifications))
+	for _, n := range notifications {
+		if strings.HasPrefix(n.Key, quotaSoftLimitNotificationKeyPrefix(n.ResourceId)) {
+			stale[n.Key] = n
+		}
+	}
+
+	checked := 0
+	err = s.db.ListRateLimitsBuilder().
+		Enumerate(ctx, func(pr pagination.PageResult[database.RateLimit]) (pagination.KeepGoing, error) {
+			for i := range pr.Results {
+				rl := &pr.Results[i]
+				q := rl.Definition.Algorithm.Quota
+				if q == nil || len(q.SoftLimits) == 0 {
+					continue
+				}
+				checked++
+				if err := s.checkQuotaSoftLimits(ctx, rl, stale); err != nil {
+					logger.Error("failed to check rate limit quota soft limits",
+						"rate_limit_id", rl.Id, "error", err)
+					prefix := quotaSoftLimitNotificationKeyPrefix(rl.Id)
+					for key := range stale {
+						if strings.HasPrefix(key, prefix) {
+							delete(stale, key)
+						}
+					}
+				}
+			}
+			return pagination.Continue, nil
+		})
+	if err != nil {
+		return fmt.Errorf("enumerate rate limits: %w", err)
+	}
+
+	resolve := make(map[apid.ID][]string)
+	for key, n := range stale {
+		resolve[n.ResourceId] = append(resolve[n.ResourceId], key)
+	}
+	for id, keys := range resolve {
+		if err := s.resolveNotificationsForResourceKeys(ctx, "rate_limit", id, keys); err != nil {
+			logger.Error("failed to resolve rate limit quota soft limit notifications",
+				"rate_limit_id", id, "error", err)
+		}
+	}
+
+	logger.Info("rate limit quota soft limit check complete", "rate_limits_checked", checked, "resolved", len(stale))
+	return nil
+}
+
+// checkQuotaSoftLimits raises or refreshes the soft-limit notification for
+// every bucket of rl at or above a soft limit, removing each from stale. A
+// notification that already reports the same threshold for the same period
+// is left alone.
+func (s *service) checkQuotaSoftLimits(ctx context.Context, rl *database.RateLimit, stale map[string]database.Notification) error {
+	q := rl.Definition.Algorithm.Quota
+	usage, err := ratelimit.GetQuotaUsage(ctx, s.r, rl)
+	if err != nil {
+		return err
+	}
+	lowest := slices.Min(q.SoftLimits)
+	periodStart := usage.PeriodStart.UTC().Format(time.RFC3339)
+
+	// Buckets arrive most-used first, so stop at the first one under every
+	// soft limit.
+	for _, b := range usage.Buckets {
+		pct := b.Used * 100 / usage.Limit
+		if pct < lowest {
+			break
+		}
+		threshold := 100
+		if b.Used < usage.Limit {
+			threshold = 0
+			for _, sl := range q.SoftLimits {
+				if sl <= pct && sl > threshold {
+					threshold = sl
+				}
+			}
+		}
+
+		key := quotaSoftLimitNotificationKey(rl.Id, b.BucketKey)
+		existing, ok := stale[key]
+		delete(stale, key)
+		if ok &&
+			fmt.Sprint(existing.Metadata["period_start"]) == periodStart &&
+			fmt.Sprint(existing.Metadata["threshold"]) == fmt.Sprint(threshold) {
+			continue
+		}
+
+		level := database.NotificationLevelWarning
+		title := "Rate limit quota nearly exhausted"
+		message := fmt.Sprintf("Bucket %s has used %d of its %d request %s quota (%d%%). Requests will be rejected once it is exhausted.",
+			b.BucketKey, b.Used, usage.Limit, q.Period, pct)
+		if threshold == 100 {
+			level = database.NotificationLevelError
+			title = "Rate limit quota exhausted"
+			message = fmt.Sprintf("Bucket %s has used all %d requests of its %s quota. Requests are rejected until %s.",
+				b.BucketKey, usage.Limit, q.Period, usage.PeriodEnd.UTC().Format(time.RFC1123))
+		}
+
+		_, err := s.upsertNotification(ctx, database.NotificationUpsert{
+			Key:          key,
+			Level:        level,
+			ResourceType: "rate_limit",
+			ResourceId:   rl.Id,
+			Namespace:    rl.Namespace,
+			Labels:       rl.Labels,
+			Title:        title,
+			Message:      message,
+			ViewPermissions: aschema.PermissionsSingleWithResourceIds(
+				rl.Namespace,
+				"rate_limits",
+				"get",
+				rl.Id.String(),
+			),
+			ActionPermissions: aschema.NoPermissions(),
+			Metadata: map[string]any{
+				"bucket_key":   b.BucketKey,
+				"period_start": periodStart,
+				"used":         b.Used,
+				"limit":        usage.Limit,
+				"threshold":    threshold,
+			},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rmorlok/authproxy/internal/apctx"
+	"github.com/rmorlok/authproxy/internal/aplog"
+	"github.com/rmorlok/authproxy/internal/database"
+	"github.com/rmorlok/authproxy/internal/ratelimit"
+	rlschema "github.com/rmorlok/authproxy/internal/schema/resources/rate_limit"
+	"github.com/stretchr/testify/require"
+	clock "k8s.io/utils/clock/testing"
+)
+
+func TestRunRateLimitQuotaSoftLimits(t *testing.T) {
+	svc, _, done := newDryRunService(t)
+	defer done()
+	s := svc.(*service)
+
+	fc := clock.NewFakeClock(time.Date(2026, 1, 15, 12, 0, 0, 0, time.UTC))
+	ctx := apctx.NewBuilderBackground().WithClock(fc).Build()
+
+	created, err := s.CreateRateLimit(ctx, "root", "", rlschema.RateLimit{
+		Bucket: rlschema.Bucket{Dimensions: []string{rlschema.DimensionActor}},
+		Algorithm: rlschema.Algorithm{
+			Quota: &rlschema.Quota{Period: rlschema.QuotaPeriodMonth, Limit: 10, SoftLimits: []int{50, 80}},
+		},
+	}, nil, nil)
+	require.NoError(t, err)
+	rl, err := s.db.GetRateLimit(ctx, created.GetId())
+	require.NoError(t, err)
+
+	l, err := ratelimit.NewLimiter(rl, s.r, aplog.NewNoopLogger())
+	require.NoError(t, err)
+	consume := func(actor string, n int) {
+		bk := ratelimit.BucketKey{Components: []ratelimit.BucketKeyComponent{{Name: rlschema.DimensionActor, Value: actor}}}
+		for i := 0; i < n; i++ {
+			_, err := l.Decide(ctx, bk)
+			require.NoError(t, err)
+		}
+	}
+	run := func() map[string]database.Notification {
+		require.NoError(t, s.runRateLimitQuotaSoftLimits(ctx, newRateLimitQuotaSoftLimitsTask()))
+		ns, err := s.db.ListNotifications(ctx, database.ListNotificationsOptions{
+			States:       []database.NotificationState{database.NotificationStateActive},
+			ResourceType: "rate_limit",
+			ResourceId:   rl.Id,
+		})
+		require.NoError(t, err)
+		out := make(map[string]database.Notification, len(ns))
+		for _, n := range ns {
+			out[n.Metadata["bucket_key"].(string)] = n
+		}
+		return out
+	}
+
+	consume("act_a", 6)
+	consume("act_b", 2)
+
+	active := run()
+	require.Len(t, active, 1, "act_b is under every soft limit")
+	require.Equal(t, database.NotificationLevelWarning, active["actor=act_a"].Level)
+	require.EqualValues(t, 50, active["actor=act_a"].Metadata["threshold"])
+	firstUpdate := active["actor=act_a"].UpdatedAt
+
+	fc.Step(time.Minute)
+	active = run()
+	require.Equal(t, firstUpdate, active["actor=act_a"].UpdatedAt, "an unchanged threshold isn't rewritten")
+
+	consume("act_a", 3)
+	consume("act_b", 8)
+
+	active = run()
+	require.Len(t, active, 2)
+	require.EqualValues(t, 80, active["actor=act_a"].Metadata["threshold"])
+	require.EqualValues(t, 9, active["actor=act_a"].Metadata["used"])
+	require.Equal(t, database.NotificationLevelError, active["actor=act_b"].Level)
+	require.EqualValues(t, 100, active["actor=act_b"].Metadata["threshold"])
+
+	// The new month starts every bucket over.
+	fc.SetTime(time.Date(2026, 2, 1, 0, 0, 1, 0, time.UTC))
+	require.Empty(t, run())
+}
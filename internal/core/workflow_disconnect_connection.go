@@ -259,6 +259,7 @@ func (s *service) finalizeDisconnectConnectionV1(ctx context.Context, id apid.ID
 		logger.Error("failed to delete connection", "error", err)
 		return err
 	}
+	s.releaseConnectionTransport(id)
 
 	return nil
 }
@@ -277,5 +278,6 @@ func (s *service) forceDisconnectConnectionV1(ctx context.Context, id apid.ID) e
 	if err := s.db.DeleteConnection(ctx, id); err != nil && !errors.Is(err, database.ErrNotFound) {
 		return err
 	}
+	s.releaseConnectionTransport(id)
 	return nil
 }
@@ -221,6 +221,7 @@ func (s *service) forceRemainingDisconnectConnectorConnectionsV1(ctx context.Con
 			log.Error("failed to delete connection", "connectionID", id, "error", err)
 			return err
 		}
+		s.releaseConnectionTransport(id)
 	}
 
 	return nil
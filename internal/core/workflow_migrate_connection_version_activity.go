@@ -74,6 +74,8 @@ func (s *service) applyMigrateConnectionVersionV1(
 	if err != nil {
 		return err
 	}
+	// The target version may change the connection's TLS settings.
+	s.releaseConnectionTransport(connectionID)
 
 	if candidate.RefreshAuth {
 		// Based on the transition (e.g. changing scopes, changing oauth
@@ -46,7 +46,7 @@ func (s *service) applyMigrationHookForVersion(
 		"cfg":         candidate.Config,
 		"labels":      candidate.UserLabels,
 		"annotations": candidate.Annotations,
-	}).EvaluateObject(hook.Javascript)
+	}).WithBudget(version.javascriptBudget()).EvaluateObject(hook.Javascript)
 	if err != nil {
 		return fmt.Errorf("evaluate migration hook for version %d: %w", version.Version, err)
 	}
@@ -3,8 +3,11 @@ package core
 import (
 	"context"
 	"testing"
+	"time"
 
+	"github.com/rmorlok/authproxy/internal/apjs"
 	"github.com/rmorlok/authproxy/internal/database"
+	scommon "github.com/rmorlok/authproxy/internal/schema/common"
 	cschema "github.com/rmorlok/authproxy/internal/schema/resources/connectors"
 	"github.com/stretchr/testify/require"
 )
@@ -46,6 +49,20 @@ func TestApplyMigrationHookForVersionSkipsMissingHook(t *testing.T) {
 	require.Empty(t, candidate.Config)
 }
 
+func TestApplyMigrationHookForVersionReportsExceededBudget(t *testing.T) {
+	version := NewTestConnector(cschema.Connector{
+		JavascriptBudget: &scommon.JavascriptBudget{Timeout: &scommon.HumanDuration{Duration: 20 * time.Millisecond}},
+		Migrations: &cschema.Migrations{
+			Up: &cschema.MigrationHook{Javascript: `(function() { while (true) {} })()`},
+		},
+	})
+	candidate := newMigrationTestCandidate(t)
+
+	err := version.s.applyMigrationHookForVersion(context.Background(), candidate, version, 1, 2)
+	require.True(t, apjs.IsBudgetExceeded(err))
+	require.ErrorContains(t, err, "script exceeded budget")
+}
+
 func TestDecodeMigrationHookPatchNotificationSetUnset(t *testing.T) {
 	patch, err := decodeMigrationHookPatch(map[string]any{
 		"notifications": map[string]any{
@@ -47,10 +47,42 @@ type OAuth2ClientCredentialsPlaintext struct {
 	ClientSecret string `json:"clientSecret,omitempty"`
 }
 
+// OAuth2JwtBearerKeyPlaintext is the plaintext stored for OAuth2 jwt-bearer
+// connections: the PEM-encoded private key used to sign assertions and, when
+// the provider needs one in the JWS header, its key id.
+type OAuth2JwtBearerKeyPlaintext struct {
+	PrivateKey string `json:"privateKey"`
+	KeyId      string `json:"keyId,omitempty"`
+}
+
+// RequestSigningCredentialPlaintext is the plaintext stored for request-signing
+// connections. SigV4 connectors populate the Aws* fields (and Region when the
+// connector leaves it to the user); HMAC connectors populate Secret and, when
+// the connector signs with a key id, KeyId.
+type RequestSigningCredentialPlaintext struct {
+	AwsAccessKeyId     string `json:"awsAccessKeyId,omitempty"`
+	AwsSecretAccessKey string `json:"awsSecretAccessKey,omitempty"`
+	AwsSessionToken    string `json:"awsSessionToken,omitempty"`
+	Region             string `json:"region,omitempty"`
+	KeyId              string `json:"keyId,omitempty"`
+	Secret             string `json:"secret,omitempty"`
+}
+
+// OAuth1TokenPlaintext is the plaintext stored for OAuth 1.0a connections: the
+// access token and token secret returned by the provider's access-token
+// endpoint. Both are needed to sign every request.
+type OAuth1TokenPlaintext struct {
+	Token       string `json:"token"`
+	TokenSecret string `json:"tokenSecret"`
+}
+
 // ApiKeyCredential is one row in the connection_credentials table — an
 // encrypted credential blob submitted by a user for a connection. API-key
 // connections store api key material here; OAuth2 client_credentials
-// connections store client id / secret material here. The encrypted_credentials
+// connections store client id / secret material here; OAuth2 jwt-bearer
+// connections store the assertion signing key here; request-signing
+// connections store signing keys here; OAuth 1.0a connections store the
+// access token and token secret here. The encrypted_credentials
 // column stores a single opaque encrypted blob; the substructure inside is
 // decided by the encrypt/decrypt layer that owns the plaintext shape — the
 // database is agnostic to it.
@@ -0,0 +1,263 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/hashicorp/go-multierror"
+	"github.com/rmorlok/authproxy/internal/apctx"
+	"github.com/rmorlok/authproxy/internal/apid"
+	"github.com/rmorlok/authproxy/internal/aplog"
+	"github.com/rmorlok/authproxy/internal/encfield"
+)
+
+func init() {
+	RegisterEncryptedField(EncryptedFieldRegistration{
+		Table:            ConnectionClientCertificatesTable,
+		PrimaryKeyCols:   []string{"id"},
+		EncryptedCols:    []string{"encrypted_certificate"},
+		JoinTable:        ConnectionsTable,
+		JoinLocalCol:     "connection_id",
+		JoinRemoteCol:    "id",
+		JoinNamespaceCol: "namespace",
+	})
+}
+
+const ConnectionClientCertificatesTable = "connection_client_certificates"
+
+// ClientCertificatePlaintext is the plaintext stored, encrypted, inside
+// ConnectionClientCertificate.EncryptedCertificate: the PEM-encoded
+// certificate chain and the private key for the leaf.
+type ClientCertificatePlaintext struct {
+	Certificate string `json:"certificate"`
+	PrivateKey  string `json:"privateKey"`
+}
+
+// ConnectionClientCertificate is one row in the connection_client_certificates
+// table — a TLS client certificate and key uploaded for a connection whose
+// connector requires mutual TLS. It lives alongside connection_credentials
+// rather than in it because the certificate composes with the auth method's
+// own credentials (e.g. an api key or OAuth2 client secret).
+//
+// NotAfter is copied out of the certificate in plaintext so expiry can be
+// checked without decrypting the pair.
+//
+// Rotation produces a new row and soft-deletes the prior. At most one row per
+// connection has deleted_at IS NULL at any given moment.
+type ConnectionClientCertificate struct {
+	Id                   apid.ID
+	ConnectionId         apid.ID                 // FK to Connection; not enforced by DB
+	EncryptedCertificate encfield.EncryptedField // Encrypted ClientCertificatePlaintext
+	NotAfter             time.Time               // Expiry of the leaf certificate
+	CreatedByActorId     *apid.ID                // Actor who uploaded (or rotated to) this certificate
+	CreatedAt            time.Time
+	EncryptedAt          *time.Time
+	DeletedAt            *time.Time
+}
+
+func (c *ConnectionClientCertificate) cols() []string {
+	return []string{
+		"id",
+		"connection_id",
+		"encrypted_certificate",
+		"not_after",
+		"created_by_actor_id",
+		"created_at",
+		"encrypted_at",
+		"deleted_at",
+	}
+}
+
+func (c *ConnectionClientCertificate) fields() []any {
+	return []any{
+		&c.Id,
+		&c.ConnectionId,
+		&c.EncryptedCertificate,
+		&c.NotAfter,
+		&c.CreatedByActorId,
+		&c.CreatedAt,
+		&c.EncryptedAt,
+		&c.DeletedAt,
+	}
+}
+
+func (c *ConnectionClientCertificate) values() []any {
+	return []any{
+		c.Id,
+		c.ConnectionId,
+		c.EncryptedCertificate,
+		c.NotAfter,
+		c.CreatedByActorId,
+		c.CreatedAt,
+		c.EncryptedAt,
+		c.DeletedAt,
+	}
+}
+
+func (c *ConnectionClientCertificate) Validate() error {
+	result := &multierror.Error{}
+
+	if c.Id == apid.Nil {
+		result = multierror.Append(result, errors.New("client certificate id is required"))
+	} else if err := c.Id.ValidatePrefix(apid.PrefixClientCertificate); err != nil {
+		result = multierror.Append(result, fmt.Errorf("invalid client certificate id: %w", err))
+	}
+
+	if c.ConnectionId == apid.Nil {
+		result = multierror.Append(result, errors.New("client certificate connection id is required"))
+	} else if err := c.ConnectionId.ValidatePrefix(apid.PrefixConnection); err != nil {
+		result = multierror.Append(result, fmt.Errorf("invalid client certificate connection id: %w", err))
+	}
+
+	if c.NotAfter.IsZero() {
+		result = multierror.Append(result, errors.New("client certificate not_after is required"))
+	}
+
+	if c.CreatedByActorId != nil {
+		if err := c.CreatedByActorId.ValidatePrefix(apid.PrefixActor); err != nil {
+			result = multierror.Append(result, fmt.Errorf("invalid client certificate created_by_actor_id: %w", err))
+		}
+	}
+
+	return result.ErrorOrNil()
+}
+
+// GetActiveConnectionClientCertificate returns the single non-deleted client
+// certificate for the connection, or ErrNotFound if none exists.
+func (s *service) GetActiveConnectionClientCertificate(
+	ctx context.Context,
+	connectionId apid.ID,
+) (*ConnectionClientCertificate, error) {
+	var result ConnectionClientCertificate
+	err := s.sq.
+		Select(result.cols()...).
+		From(ConnectionClientCertificatesTable).
+		Where(sq.Eq{
+			"connection_id": connectionId,
+			"deleted_at":    nil,
+		}).
+		OrderBy("created_at DESC").
+		Limit(1).
+		RunWith(s.db).
+		QueryRow().
+		Scan(result.fields()...)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("no active client certificate for connection: %w", ErrNotFound)
+		}
+		return nil, err
+	}
+	return &result, nil
+}
+
+// InsertConnectionClientCertificate stores a new encrypted certificate/key pair
+// for the connection, soft-deleting any previously-active row in the same
+// transaction so that exactly one certificate is active per connection.
+func (s *service) InsertConnectionClientCertificate(
+	ctx context.Context,
+	connectionId apid.ID,
+	encryptedCertificate encfield.EncryptedField,
+	notAfter time.Time,
+	createdByActorId *apid.ID,
+) (*ConnectionClientCertificate, error) {
+	logger := aplog.NewBuilder(s.logger).
+		WithCtx(ctx).
+		WithConnectionId(connectionId).
+		Build()
+	logger.Debug("inserting new client certificate")
+
+	now := apctx.GetClock(ctx).Now()
+	var newCert *ConnectionClientCertificate
+
+	err := s.transaction(func(tx *sql.Tx) error {
+		dbResult, err := s.sq.Update(ConnectionClientCertificatesTable).
+			Set("deleted_at", now).
+			Where(sq.Eq{
+				"connection_id": connectionId,
+				"deleted_at":    nil,
+			}).
+			RunWith(tx).
+			Exec()
+		if err != nil {
+			return fmt.Errorf("failed to soft delete prior client certificates: %w", err)
+		}
+		affected, err := dbResult.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("failed to soft delete prior client certificates: %w", err)
+		}
+		logger.Info("soft-deleted prior client certificates for connection", "affected", affected)
+
+		newCert = &ConnectionClientCertificate{
+			Id:                   apctx.GetIdGenerator(ctx).New(apid.PrefixClientCertificate),
+			ConnectionId:         connectionId,
+			EncryptedCertificate: encryptedCertificate,
+			NotAfter:             notAfter,
+			CreatedByActorId:     createdByActorId,
+			CreatedAt:            now,
+		}
+
+		if err := newCert.Validate(); err != nil {
+			return err
+		}
+
+		insertResult, err := s.sq.
+			Insert(ConnectionClientCertificatesTable).
+			Columns(newCert.cols()...).
+			Values(newCert.values()...).
+			RunWith(tx).
+			Exec()
+		if err != nil {
+			return fmt.Errorf("failed to create client certificate: %w", err)
+		}
+		inserted, err := insertResult.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("failed to create client certificate: %w", err)
+		}
+		if inserted == 0 {
+			return errors.New("failed to create client certificate; no rows inserted")
+		}
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	return newCert, nil
+}
+
+// DeleteAllConnectionClientCertificatesForConnection soft-deletes every client
+// certificate row for the connection. Used when revoking a connection.
+func (s *service) DeleteAllConnectionClientCertificatesForConnection(
+	ctx context.Context,
+	connectionId apid.ID,
+) error {
+	logger := aplog.NewBuilder(s.logger).
+		WithCtx(ctx).
+		WithConnectionId(connectionId).
+		Build()
+	logger.Debug("deleting all client certificates for connection")
+
+	now := apctx.GetClock(ctx).Now()
+	dbResult, err := s.sq.
+		Update(ConnectionClientCertificatesTable).
+		Set("deleted_at", now).
+		Where(sq.Eq{
+			"connection_id": connectionId,
+			"deleted_at":    nil,
+		}).
+		RunWith(s.db).
+		Exec()
+	if err != nil {
+		return fmt.Errorf("failed to soft delete client certificates for connection: %w", err)
+	}
+	affected, err := dbResult.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to soft delete client certificates for connection: %w", err)
+	}
+	logger.Info("deleted client certificates for connection", "affected", affected)
+	return nil
+}
@@ -0,0 +1,120 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rmorlok/authproxy/internal/apctx"
+	"github.com/rmorlok/authproxy/internal/apid"
+	"github.com/rmorlok/authproxy/internal/encfield"
+	"github.com/rmorlok/authproxy/internal/sqlh"
+	"github.com/stretchr/testify/require"
+	clock "k8s.io/utils/clock/testing"
+)
+
+func TestConnectionClientCertificate_Validate(t *testing.T) {
+	notAfter := time.Date(2025, time.March, 15, 10, 0, 0, 0, time.UTC)
+
+	t.Run("valid", func(t *testing.T) {
+		c := &ConnectionClientCertificate{
+			Id:           apid.New(apid.PrefixClientCertificate),
+			ConnectionId: apid.New(apid.PrefixConnection),
+			NotAfter:     notAfter,
+		}
+		require.NoError(t, c.Validate())
+	})
+	t.Run("wrong id prefix", func(t *testing.T) {
+		c := &ConnectionClientCertificate{
+			Id:           apid.New(apid.PrefixApiKeyCredential),
+			ConnectionId: apid.New(apid.PrefixConnection),
+			NotAfter:     notAfter,
+		}
+		require.Error(t, c.Validate())
+	})
+	t.Run("missing not_after", func(t *testing.T) {
+		c := &ConnectionClientCertificate{
+			Id:           apid.New(apid.PrefixClientCertificate),
+			ConnectionId: apid.New(apid.PrefixConnection),
+		}
+		require.ErrorContains(t, c.Validate(), "not_after")
+	})
+}
+
+func TestConnectionClientCertificates_RoundTrip(t *testing.T) {
+	_, db, rawDb := MustApplyBlankTestDbConfigRaw(t, nil)
+	now := time.Date(2024, time.March, 15, 10, 0, 0, 0, time.UTC)
+	ctx := apctx.NewBuilderBackground().WithClock(clock.NewFakeClock(now)).Build()
+
+	connectionId := apid.New(apid.PrefixConnection)
+	actorId := apid.New(apid.PrefixActor)
+	notAfter := now.Add(90 * 24 * time.Hour)
+	blob := encfield.EncryptedField{ID: "dek_test", Data: "encryptedCertificateBlob"}
+
+	_, err := db.GetActiveConnectionClientCertificate(ctx, connectionId)
+	require.ErrorIs(t, err, ErrNotFound)
+
+	cert, err := db.InsertConnectionClientCertificate(ctx, connectionId, blob, notAfter, &actorId)
+	require.NoError(t, err)
+	require.True(t, cert.Id.HasPrefix(apid.PrefixClientCertificate))
+	require.Equal(t, connectionId, cert.ConnectionId)
+	require.Equal(t, &actorId, cert.CreatedByActorId)
+
+	got, err := db.GetActiveConnectionClientCertificate(ctx, connectionId)
+	require.NoError(t, err)
+	require.Equal(t, cert.Id, got.Id)
+	require.Equal(t, blob, got.EncryptedCertificate)
+	require.True(t, notAfter.Equal(got.NotAfter))
+
+	// Rotating soft-deletes the prior row.
+	rotated, err := db.InsertConnectionClientCertificate(ctx, connectionId,
+		encfield.EncryptedField{ID: "dek_test", Data: "rotated"}, notAfter.Add(time.Hour), nil)
+	require.NoError(t, err)
+
+	got, err = db.GetActiveConnectionClientCertificate(ctx, connectionId)
+	require.NoError(t, err)
+	require.Equal(t, rotated.Id, got.Id)
+	require.Equal(t, 2, sqlh.MustCount(rawDb, "SELECT COUNT(*) FROM connection_client_certificates"))
+	require.Equal(t, 1, sqlh.MustCount(rawDb, "SELECT COUNT(*) FROM connection_client_certificates WHERE deleted_at IS NULL"))
+}
+
+func TestConnectionClientCertificates_DeleteAllForConnection(t *testing.T) {
+	_, db, rawDb := MustApplyBlankTestDbConfigRaw(t, nil)
+	now := time.Date(2024, time.March, 15, 10, 0, 0, 0, time.UTC)
+	ctx := apctx.NewBuilderBackground().WithClock(clock.NewFakeClock(now)).Build()
+
+	connectionId := apid.New(apid.PrefixConnection)
+	otherId := apid.New(apid.PrefixConnection)
+	notAfter := now.Add(24 * time.Hour)
+
+	_, err := db.InsertConnectionClientCertificate(ctx, connectionId,
+		encfield.EncryptedField{ID: "dek_test", Data: "c1"}, notAfter, nil)
+	require.NoError(t, err)
+	sibling, err := db.InsertConnectionClientCertificate(ctx, otherId,
+		encfield.EncryptedField{ID: "dek_test", Data: "sibling"}, notAfter, nil)
+	require.NoError(t, err)
+
+	require.NoError(t, db.DeleteAllConnectionClientCertificatesForConnection(ctx, connectionId))
+
+	_, err = db.GetActiveConnectionClientCertificate(ctx, connectionId)
+	require.ErrorIs(t, err, ErrNotFound)
+
+	got, err := db.GetActiveConnectionClientCertificate(ctx, otherId)
+	require.NoError(t, err)
+	require.Equal(t, sibling.Id, got.Id)
+	require.Equal(t, 1, sqlh.MustCount(rawDb, "SELECT COUNT(*) FROM connection_client_certificates WHERE deleted_at IS NULL"))
+}
+
+func TestConnectionClientCertificates_EncryptedFieldRegistration(t *testing.T) {
+	regs := GetEncryptedFieldRegistrations()
+	var found *EncryptedFieldRegistration
+	for i := range regs {
+		if regs[i].Table == ConnectionClientCertificatesTable {
+			found = &regs[i]
+			break
+		}
+	}
+	require.NotNil(t, found, "connection_client_certificates must register its encrypted column with the re-encryption registry")
+	require.ElementsMatch(t, []string{"encrypted_certificate"}, found.EncryptedCols)
+	require.Equal(t, ConnectionsTable, found.JoinTable)
+	require.Equal(t, "connection_id", found.JoinLocalCol)
+}
@@ -184,6 +184,20 @@ type DB interface {
 	UpdateApiKeyCredentialLastValidated(ctx context.Context, credentialId apid.ID, at time.Time) error
 	DeleteAllApiKeyCredentialsForConnection(ctx context.Context, connectionId apid.ID) error
 
+	/*
+	 * Connection client certificates — TLS client certificate/key pairs
+	 * uploaded for connectors that require mutual TLS to the upstream.
+	 */
+	GetActiveConnectionClientCertificate(ctx context.Context, connectionId apid.ID) (*ConnectionClientCertificate, error)
+	InsertConnectionClientCertificate(
+		ctx context.Context,
+		connectionId apid.ID,
+		encryptedCertificate encfield.EncryptedField,
+		notAfter time.Time,
+		createdByActorId *apid.ID,
+	) (*ConnectionClientCertificate, error)
+	DeleteAllConnectionClientCertificatesForConnection(ctx context.Context, connectionId apid.ID) error
+
 	/*
 	 * Connection probe outcomes — append-only event log that drives the
 	 * probe-driven health-check signal. The runtime walks the most-recent
@@ -6,112 +6,30 @@ import (
 	"strings"
 
 	sq "github.com/Masterminds/squirrel"
+	scommon "github.com/rmorlok/authproxy/internal/schema/common"
 	"github.com/rmorlok/authproxy/internal/schema/config"
 )
 
-type LabelOperator string
+type LabelOperator = scommon.LabelOperator
 
 const (
-	LabelOperatorEqual     LabelOperator = "="
-	LabelOperatorNotEqual  LabelOperator = "!="
-	LabelOperatorExists    LabelOperator = "exists"
-	LabelOperatorNotExists LabelOperator = "!exists"
+	LabelOperatorEqual     = scommon.LabelOperatorEqual
+	LabelOperatorNotEqual  = scommon.LabelOperatorNotEqual
+	LabelOperatorExists    = scommon.LabelOperatorExists
+	LabelOperatorNotExists = scommon.LabelOperatorNotExists
 )
 
-type LabelRequirement struct {
-	Key      string
-	Operator LabelOperator
-	Value    string
-}
+type LabelRequirement = scommon.LabelRequirement
 
 type LabelSelector []LabelRequirement
 
-// ParseLabelSelector parses a Kubernetes-style label selector string.
-// Supported syntax:
-// - key=value, key==value
-// - key!=value
-// - key (exists)
-// - !key (does not exist)
+// ParseLabelSelector parses a Kubernetes-style label selector string. See
+// scommon.ParseLabelSelector for the supported syntax.
 func ParseLabelSelector(selector string) (LabelSelector, error) {
-	if selector == "" {
-		return nil, nil
-	}
-
-	var requirements LabelSelector
-	parts := strings.Split(selector, ",")
-
-	for _, part := range parts {
-		part = strings.TrimSpace(part)
-		if part == "" {
-			return nil, fmt.Errorf("label selector contains an empty requirement")
-		}
-
-		if strings.Contains(part, "!=") {
-			opParts := strings.SplitN(part, "!=", 2)
-			key := strings.TrimSpace(opParts[0])
-			val := strings.TrimSpace(opParts[1])
-			if err := ValidateLabelKey(key); err != nil {
-				return nil, fmt.Errorf("invalid label key in selector %q: %w", part, err)
-			}
-			if err := validateValueForKey(key, val); err != nil {
-				return nil, fmt.Errorf("invalid label value in selector %q: %w", part, err)
-			}
-			requirements = append(requirements, LabelRequirement{
-				Key:      key,
-				Operator: LabelOperatorNotEqual,
-				Value:    val,
-			})
-		} else if strings.Contains(part, "==") {
-			opParts := strings.SplitN(part, "==", 2)
-			key := strings.TrimSpace(opParts[0])
-			val := strings.TrimSpace(opParts[1])
-			if err := ValidateLabelKey(key); err != nil {
-				return nil, fmt.Errorf("invalid label key in selector %q: %w", part, err)
-			}
-			if err := validateValueForKey(key, val); err != nil {
-				return nil, fmt.Errorf("invalid label value in selector %q: %w", part, err)
-			}
-			requirements = append(requirements, LabelRequirement{
-				Key:      key,
-				Operator: LabelOperatorEqual,
-				Value:    val,
-			})
-		} else if strings.Contains(part, "=") {
-			opParts := strings.SplitN(part, "=", 2)
-			key := strings.TrimSpace(opParts[0])
-			val := strings.TrimSpace(opParts[1])
-			if err := ValidateLabelKey(key); err != nil {
-				return nil, fmt.Errorf("invalid label key in selector %q: %w", part, err)
-			}
-			if err := validateValueForKey(key, val); err != nil {
-				return nil, fmt.Errorf("invalid label value in selector %q: %w", part, err)
-			}
-			requirements = append(requirements, LabelRequirement{
-				Key:      key,
-				Operator: LabelOperatorEqual,
-				Value:    val,
-			})
-		} else if strings.HasPrefix(part, "!") {
-			key := strings.TrimSpace(part[1:])
-			if err := ValidateLabelKey(key); err != nil {
-				return nil, fmt.Errorf("invalid label key in selector %q: %w", part, err)
-			}
-			requirements = append(requirements, LabelRequirement{
-				Key:      key,
-				Operator: LabelOperatorNotExists,
-			})
-		} else {
-			key := strings.TrimSpace(part)
-			if err := ValidateLabelKey(key); err != nil {
-				return nil, fmt.Errorf("invalid label key in selector %q: %w", part, err)
-			}
-			requirements = append(requirements, LabelRequirement{
-				Key:      key,
-				Operator: LabelOperatorExists,
-			})
-		}
+	requirements, err := scommon.ParseLabelSelector(selector)
+	if err != nil {
+		return nil, err
 	}
-
 	return requirements, nil
 }
 
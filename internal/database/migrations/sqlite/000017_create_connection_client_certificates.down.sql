@@ -0,0 +1,3 @@
+drop index if exists idx_connection_client_certificates_connection_active;
+drop index if exists idx_connection_client_certificates_deleted_at;
+drop table if exists connection_client_certificates;
@@ -0,0 +1,17 @@
+create table connection_client_certificates
+(
+    id                    text primary key,
+    connection_id         text not null,
+    encrypted_certificate text,
+    not_after             datetime not null,
+    created_by_actor_id   text,
+    created_at            datetime,
+    encrypted_at          datetime,
+    deleted_at            datetime
+);
+
+create index idx_connection_client_certificates_deleted_at
+    on connection_client_certificates (deleted_at);
+
+create index idx_connection_client_certificates_connection_active
+    on connection_client_certificates (connection_id, deleted_at);
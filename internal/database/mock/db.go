@@ -472,6 +472,20 @@ func (mr *MockDBMockRecorder) DeleteAllApiKeyCredentialsForConnection(ctx, conne
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteAllApiKeyCredentialsForConnection", reflect.TypeOf((*MockDB)(nil).DeleteAllApiKeyCredentialsForConnection), ctx, connectionId)
 }
 
+// DeleteAllConnectionClientCertificatesForConnection mocks base method.
+func (m *MockDB) DeleteAllConnectionClientCertificatesForConnection(ctx context.Context, connectionId apid.ID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteAllConnectionClientCertificatesForConnection", ctx, connectionId)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteAllConnectionClientCertificatesForConnection indicates an expected call of DeleteAllConnectionClientCertificatesForConnection.
+func (mr *MockDBMockRecorder) DeleteAllConnectionClientCertificatesForConnection(ctx, connectionId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteAllConnectionClientCertificatesForConnection", reflect.TypeOf((*MockDB)(nil).DeleteAllConnectionClientCertificatesForConnection), ctx, connectionId)
+}
+
 // DeleteAllOAuth2TokensForConnection mocks base method.
 func (m *MockDB) DeleteAllOAuth2TokensForConnection(ctx context.Context, connectionId apid.ID) error {
 	m.ctrl.T.Helper()
@@ -834,6 +848,21 @@ func (mr *MockDBMockRecorder) GetActiveApiKeyCredential(ctx, connectionId interf
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetActiveApiKeyCredential", reflect.TypeOf((*MockDB)(nil).GetActiveApiKeyCredential), ctx, connectionId)
 }
 
+// GetActiveConnectionClientCertificate mocks base method.
+func (m *MockDB) GetActiveConnectionClientCertificate(ctx context.Context, connectionId apid.ID) (*database.ConnectionClientCertificate, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetActiveConnectionClientCertificate", ctx, connectionId)
+	ret0, _ := ret[0].(*database.ConnectionClientCertificate)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetActiveConnectionClientCertificate indicates an expected call of GetActiveConnectionClientCertificate.
+func (mr *MockDBMockRecorder) GetActiveConnectionClientCertificate(ctx, connectionId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetActiveConnectionClientCertificate", reflect.TypeOf((*MockDB)(nil).GetActiveConnectionClientCertificate), ctx, connectionId)
+}
+
 // GetActor mocks base method.
 func (m *MockDB) GetActor(ctx context.Context, id apid.ID) (*database.Actor, error) {
 	m.ctrl.T.Helper()
@@ -1074,6 +1103,21 @@ func (mr *MockDBMockRecorder) InsertApiKeyCredential(ctx, connectionId, encrypte
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InsertApiKeyCredential", reflect.TypeOf((*MockDB)(nil).InsertApiKeyCredential), ctx, connectionId, encryptedCredentials, placement, createdByActorId)
 }
 
+// InsertConnectionClientCertificate mocks base method.
+func (m *MockDB) InsertConnectionClientCertificate(ctx context.Context, connectionId apid.ID, encryptedCertificate encfield.EncryptedField, notAfter time.Time, createdByActorId *apid.ID) (*database.ConnectionClientCertificate, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InsertConnectionClientCertificate", ctx, connectionId, encryptedCertificate, notAfter, createdByActorId)
+	ret0, _ := ret[0].(*database.ConnectionClientCertificate)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// InsertConnectionClientCertificate indicates an expected call of InsertConnectionClientCertificate.
+func (mr *MockDBMockRecorder) InsertConnectionClientCertificate(ctx, connectionId, encryptedCertificate, notAfter, createdByActorId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InsertConnectionClientCertificate", reflect.TypeOf((*MockDB)(nil).InsertConnectionClientCertificate), ctx, connectionId, encryptedCertificate, notAfter, createdByActorId)
+}
+
 // InsertOAuth2Token mocks base method.
 func (m *MockDB) InsertOAuth2Token(ctx context.Context, connectionId apid.ID, refreshedFrom *apid.ID, encryptedRefreshToken, encryptedAccessToken encfield.EncryptedField, accessTokenExpiresAt *time.Time, scopes, requestedScopes string, createdByActorId *apid.ID) (*database.OAuth2Token, error) {
 	m.ctrl.T.Helper()
@@ -36,6 +36,18 @@ const (
 	// connection requires additional setup, e.g.
 	// "connection:cxn_...:setup_required".
 	NotificationKeySetupRequired = "setup_required"
+
+	// NotificationKeyClientCertificateExpiring is the condition key suffix
+	// used when the TLS client certificate a connection presents to the
+	// upstream is close to, or past, its expiry, e.g.
+	// "connection:cxn_...:client_certificate_expiring".
+	NotificationKeyClientCertificateExpiring = "client_certificate_expiring"
+
+	// NotificationKeyQuotaSoftLimit is the condition key suffix used when a
+	// bucket of a quota rate limit has used enough of its allowance to cross
+	// one of the rule's soft limits, e.g.
+	// "rate_limit:rl_...:quota_soft_limit:<bucket hash>".
+	NotificationKeyQuotaSoftLimit = "quota_soft_limit"
 )
 
 func IsValidNotificationLevel[T string | NotificationLevel](level T) bool {
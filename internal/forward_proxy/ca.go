@@ -0,0 +1,173 @@
+package forward_proxy
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	sconfig "github.com/rmorlok/authproxy/internal/schema/config"
+)
+
+const (
+	// leafValidity is how long a minted leaf certificate is valid for. Leaves
+	// are cached and re-minted shortly before they expire.
+	leafValidity = 24 * time.Hour
+
+	// leafRenewBefore re-mints a cached leaf this long before it expires.
+	leafRenewBefore = time.Hour
+
+	// maxCachedLeaves bounds the leaf cache; it is reset when full.
+	maxCachedLeaves = 1024
+)
+
+// LoadCertificateAuthority reads the forward proxy's CA certificate and
+// private key from the key subsystem. Returns nil when the listener isn't
+// configured to intercept TLS.
+func LoadCertificateAuthority(ctx context.Context, cfg *sconfig.ForwardProxy) (*tls.Certificate, error) {
+	if !cfg.InterceptsTls() {
+		return nil, nil
+	}
+
+	certVersion, err := cfg.CaCertificate.GetCurrentVersion(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load forward proxy ca certificate: %w", err)
+	}
+	keyVersion, err := cfg.CaPrivateKey.GetCurrentVersion(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load forward proxy ca private key: %w", err)
+	}
+
+	ca, err := tls.X509KeyPair(certVersion.Data, keyVersion.Data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid forward proxy ca: %w", err)
+	}
+	return &ca, nil
+}
+
+// certificateAuthority mints leaf certificates for intercepted hosts, signed
+// by the configured CA. All leaves share one key; only the CA key needs
+// protecting.
+type certificateAuthority struct {
+	ca      *x509.Certificate
+	caKey   crypto.Signer
+	leafKey *ecdsa.PrivateKey
+
+	mu     sync.Mutex
+	leaves map[string]*tls.Certificate
+}
+
+func newCertificateAuthority(pair *tls.Certificate) (*certificateAuthority, error) {
+	if pair == nil || len(pair.Certificate) == 0 {
+		return nil, errors.New("ca certificate is required")
+	}
+
+	ca := pair.Leaf
+	if ca == nil {
+		var err error
+		if ca, err = x509.ParseCertificate(pair.Certificate[0]); err != nil {
+			return nil, fmt.Errorf("failed to parse ca certificate: %w", err)
+		}
+	}
+	if !ca.IsCA {
+		return nil, errors.New("ca certificate is not a certificate authority")
+	}
+
+	signer, ok := pair.PrivateKey.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("ca private key cannot sign certificates")
+	}
+
+	leafKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate leaf key: %w", err)
+	}
+
+	return &certificateAuthority{
+		ca:      ca,
+		caKey:   signer,
+		leafKey: leafKey,
+		leaves:  make(map[string]*tls.Certificate),
+	}, nil
+}
+
+// certificateFor returns a leaf certificate for host, minting one if there
+// isn't a cached leaf that is still comfortably valid.
+func (c *certificateAuthority) certificateFor(host string) (*tls.Certificate, error) {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if host == "" {
+		return nil, errors.New("no host to mint a certificate for")
+	}
+
+	now := time.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if leaf, ok := c.leaves[host]; ok && now.Before(leaf.Leaf.NotAfter.Add(-leafRenewBefore)) {
+		return leaf, nil
+	}
+
+	leaf, err := c.mint(host, now)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(c.leaves) >= maxCachedLeaves {
+		c.leaves = make(map[string]*tls.Certificate)
+	}
+	c.leaves[host] = leaf
+	return leaf, nil
+}
+
+func (c *certificateAuthority) mint(host string, now time.Time) (*tls.Certificate, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate serial number: %w", err)
+	}
+
+	notAfter := now.Add(leafValidity)
+	if notAfter.After(c.ca.NotAfter) {
+		notAfter = c.ca.NotAfter
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: host},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		template.IPAddresses = []net.IP{ip}
+	} else {
+		template.DNSNames = []string{host}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, c.ca, c.leafKey.Public(), c.caKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to mint certificate for %s: %w", host, err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tls.Certificate{
+		Certificate: [][]byte{der, c.ca.Raw},
+		PrivateKey:  c.leafKey,
+		Leaf:        leaf,
+	}, nil
+}
@@ -0,0 +1,376 @@
+package httpf
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/rmorlok/authproxy/internal/apctx"
+	"github.com/rmorlok/authproxy/internal/apid"
+	"github.com/rmorlok/authproxy/internal/schema/resources/connectors"
+)
+
+// connectionTransportIdleTTL is how long a connection's transport is kept
+// after it was last used. Evicting it closes its idle connections and drops
+// the client certificate provider, so connections that were deleted or
+// disabled don't hold on to either; an active connection rebuilds its
+// transport on its next request.
+const connectionTransportIdleTTL = 30 * time.Minute
+
+// ConnectionReleaser is implemented by factories that cache state per
+// connection. ReleaseConnection drops the connection's cached transport and
+// closes its idle connections. Call it when a connection is deleted or its
+// client certificate changes; entries not released are evicted once idle.
+type ConnectionReleaser interface {
+	ReleaseConnection(connectionId apid.ID)
+}
+
+// connectionTransports caches one *http.Transport per connection whose TLS
+// handshake differs from the default: mutual TLS, private CAs, a minimum
+// version, an SNI override or pinned keys. Factories are created per request,
+// so building a transport per factory would discard the TLS connection pool
+// on every call; caching by connection id keeps handshakes to the first
+// request on each pooled connection. Entries unused for
+// connectionTransportIdleTTL are evicted.
+type connectionTransports struct {
+	// base is cloned for each connection that has no shared transport of its
+	// own. nil means http.DefaultTransport, resolved when the transport is
+	// first built. Connections under an egress policy or outbound proxy clone
+	// the shared transport for it so its dialer checks and proxy still apply.
+	base *http.Transport
+
+	mu           sync.Mutex
+	byConnection map[apid.ID]*connectionTransport
+
+	// lastSweep is when idle entries were last evicted. Sweeps run from get
+	// at most every half idle TTL.
+	lastSweep time.Time
+}
+
+func newConnectionTransports(base *http.Transport) *connectionTransports {
+	return &connectionTransports{
+		base:         base,
+		byConnection: make(map[apid.ID]*connectionTransport),
+	}
+}
+
+// connectionTransport is the cached transport for one connection. The client
+// certificate provider is swapped on every lookup so handshakes always
+// resolve the certificate through the most recently loaded connection, which
+// picks up a rotated certificate for new TLS connections. Pooled connections
+// keep the certificate they were established with until they go idle and are
+// closed.
+type connectionTransport struct {
+	transport *http.Transport
+
+	// key identifies the TLS settings and shared transport the transport was
+	// built from. A lookup with different inputs replaces the transport.
+	key string
+
+	// lastUsed is when the transport was last returned from get. Guarded by
+	// connectionTransports.mu.
+	lastUsed time.Time
+
+	mu       sync.RWMutex
+	provider ClientCertificateProvider
+}
+
+func (t *connectionTransport) getClientCertificate(cri *tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	t.mu.RLock()
+	provider := t.provider
+	t.mu.RUnlock()
+
+	// Failing the handshake is preferable to sending no certificate: the
+	// upstream would reject the call anyway, and the error explains why.
+	return provider.GetClientCertificate(cri.Context())
+}
+
+// get returns the transport for a connection, building it if the connection
+// has no cached transport or its TLS settings or shared transport changed
+// since it was built. shared may be nil to use the default base.
+func (c *connectionTransports) get(
+	now time.Time,
+	connectionId apid.ID,
+	shared *http.Transport,
+	sharedKey string,
+	provider ClientCertificateProvider,
+	settings *connectors.TransportTLS,
+) (http.RoundTripper, error) {
+	key, err := connectionTransportKey(sharedKey, provider != nil, settings)
+	if err != nil {
+		return nil, err
+	}
+
+	base := c.base
+	if shared != nil {
+		base = shared
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.evictIdle(now)
+
+	existing, ok := c.byConnection[connectionId]
+	if ok && existing.key == key {
+		existing.mu.Lock()
+		existing.provider = provider
+		existing.mu.Unlock()
+		existing.lastUsed = now
+		return existing.transport, nil
+	}
+
+	t, err := newConnectionTransport(base, key, provider, settings)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		// In-flight requests finish on the old transport; idle connections
+		// were established with the old settings and must not be reused.
+		existing.transport.CloseIdleConnections()
+	}
+	t.lastUsed = now
+	c.byConnection[connectionId] = t
+	return t.transport, nil
+}
+
+// evictIdle drops the transports not used within connectionTransportIdleTTL
+// of now. Callers must hold c.mu.
+func (c *connectionTransports) evictIdle(now time.Time) {
+	if now.Sub(c.lastSweep) < connectionTransportIdleTTL/2 {
+		return
+	}
+	c.lastSweep = now
+
+	for id, t := range c.byConnection {
+		if now.Sub(t.lastUsed) > connectionTransportIdleTTL {
+			t.transport.CloseIdleConnections()
+			delete(c.byConnection, id)
+		}
+	}
+}
+
+// release drops the cached transport for a connection, closing its idle
+// connections. Requests already using it finish normally.
+func (c *connectionTransports) release(connectionId apid.ID) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if t, ok := c.byConnection[connectionId]; ok {
+		t.transport.CloseIdleConnections()
+		delete(c.byConnection, connectionId)
+	}
+}
+
+// connectionTransportKey fingerprints the inputs that shape a connection's
+// transport.
+func connectionTransportKey(sharedKey string, clientCertificate bool, settings *connectors.TransportTLS) (string, error) {
+	b, err := json.Marshal(settings)
+	if err != nil {
+		return "", fmt.Errorf("failed to fingerprint tls settings: %w", err)
+	}
+	sum := sha256.Sum256(append(b, sharedKey...))
+	return fmt.Sprintf("%t:%s", clientCertificate, hex.EncodeToString(sum[:])), nil
+}
+
+func newConnectionTransport(
+	base *http.Transport,
+	key string,
+	provider ClientCertificateProvider,
+	settings *connectors.TransportTLS,
+) (*connectionTransport, error) {
+	if base == nil {
+		if dt, ok := http.DefaultTransport.(*http.Transport); ok {
+			base = dt
+		} else {
+			base = &http.Transport{Proxy: http.ProxyFromEnvironment}
+		}
+	}
+
+	t := &connectionTransport{
+		transport: base.Clone(),
+		key:       key,
+		provider:  provider,
+	}
+	if t.transport.TLSClientConfig == nil {
+		t.transport.TLSClientConfig = &tls.Config{}
+	}
+	if provider != nil {
+		t.transport.TLSClientConfig.GetClientCertificate = t.getClientCertificate
+	}
+	if err := applyTransportTLS(t.transport.TLSClientConfig, settings); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// applyTransportTLS applies a connector's rendered TLS settings to cfg.
+func applyTransportTLS(cfg *tls.Config, settings *connectors.TransportTLS) error {
+	if settings == nil {
+		return nil
+	}
+
+	if len(settings.CaCertificates) > 0 {
+		// Private CAs are trusted in addition to whatever the base transport
+		// already trusts, so a connector that adds its own root can still
+		// reach public endpoints such as a SaaS token URL.
+		pool := cfg.RootCAs
+		if pool == nil {
+			var err error
+			if pool, err = x509.SystemCertPool(); err != nil {
+				pool = x509.NewCertPool()
+			}
+		} else {
+			pool = pool.Clone()
+		}
+		for i, ca := range settings.CaCertificates {
+			if !pool.AppendCertsFromPEM([]byte(ca)) {
+				return fmt.Errorf("tls ca certificate %d contains no valid certificates", i)
+			}
+		}
+		cfg.RootCAs = pool
+	}
+
+	if v := settings.GetMinVersion(); v != 0 {
+		cfg.MinVersion = v
+	}
+
+	if settings.ServerName != "" {
+		cfg.ServerName = settings.ServerName
+	}
+
+	if len(settings.PinnedSpkiSha256) > 0 {
+		pins := make([][]byte, 0, len(settings.PinnedSpkiSha256))
+		for _, p := range settings.PinnedSpkiSha256 {
+			b, err := connectors.DecodeSpkiPin(p)
+			if err != nil {
+				return err
+			}
+			pins = append(pins, b)
+		}
+		cfg.VerifyConnection = func(cs tls.ConnectionState) error {
+			return verifySpkiPins(cs, pins)
+		}
+	}
+
+	return nil
+}
+
+// ErrSpkiPinMismatch is returned from the handshake when no certificate in
+// the verified chain matches a configured pin.
+var ErrSpkiPinMismatch = errors.New("upstream certificate does not match any pinned public key")
+
+// verifySpkiPins runs after normal chain verification and requires one of
+// the certificates in a verified chain to carry a pinned public key.
+func verifySpkiPins(cs tls.ConnectionState, pins [][]byte) error {
+	for _, chain := range cs.VerifiedChains {
+		for _, cert := range chain {
+			sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+			for _, pin := range pins {
+				if bytes.Equal(sum[:], pin) {
+					return nil
+				}
+			}
+		}
+	}
+	return ErrSpkiPinMismatch
+}
+
+// transportTLSRoundTripper resolves a connection's TLS settings for each
+// request, since templated settings depend on the connection's configuration
+// and resolving them needs the request context, then dispatches to the
+// connection's cached transport.
+type transportTLSRoundTripper struct {
+	transports        *connectionTransports
+	connectionId      apid.ID
+	shared            *http.Transport
+	sharedKey         string
+	clientCertificate ClientCertificateProvider
+	transportTLS      TransportTLSProvider
+}
+
+func (rt *transportTLSRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	settings, err := rt.transportTLS.GetTransportTLS(req.Context())
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve tls settings: %w", err)
+	}
+
+	now := apctx.GetClock(req.Context()).Now()
+	t, err := rt.transports.get(now, rt.connectionId, rt.shared, rt.sharedKey, rt.clientCertificate, settings)
+	if err != nil {
+		return nil, err
+	}
+	return t.RoundTrip(req)
+}
+
+// sharedTransport returns the transport shared by every request under the
+// same egress policy and outbound proxy, and a key identifying it. A nil
+// transport means no policy or proxy applies.
+func (f *clientFactory) sharedTransport(proxy *connectors.TransportProxy) (*http.Transport, string, error) {
+	egress := f.egressPolicy()
+	if proxy != nil {
+		proxies := f.outboundProxies
+		if proxies == nil {
+			proxies = newOutboundProxies(nil)
+		}
+		return proxies.transport(egress, proxy)
+	}
+	if egress != nil {
+		return egress.transport, egress.key, nil
+	}
+	return nil, "", nil
+}
+
+// baseTransport returns the transport the middlewares are wrapped around.
+// proxy is the selected outbound proxy, nil for a direct connection.
+func (f *clientFactory) baseTransport(proxy *connectors.TransportProxy) http.RoundTripper {
+	shared, sharedKey, err := f.sharedTransport(proxy)
+	if err != nil {
+		return errRoundTripper{err: err}
+	}
+
+	if f.clientCertificate == nil && f.transportTLS == nil {
+		if shared != nil {
+			return shared
+		}
+		return http.DefaultTransport
+	}
+
+	transports := f.connectionTransports
+	if transports == nil {
+		transports = newConnectionTransports(nil)
+	}
+
+	if f.transportTLS != nil {
+		return &transportTLSRoundTripper{
+			transports:        transports,
+			connectionId:      f.requestInfo.ConnectionId,
+			shared:            shared,
+			sharedKey:         sharedKey,
+			clientCertificate: f.clientCertificate,
+			transportTLS:      f.transportTLS,
+		}
+	}
+
+	// Without TLS settings to resolve there is nothing that can fail.
+	t, _ := transports.get(time.Now(), f.requestInfo.ConnectionId, shared, sharedKey, f.clientCertificate, nil)
+	return t
+}
+
+// ReleaseConnection drops the transport cached for a connection; see
+// ConnectionReleaser.
+func (f *clientFactory) ReleaseConnection(connectionId apid.ID) {
+	if f.connectionTransports != nil {
+		f.connectionTransports.release(connectionId)
+	}
+}
+
+var _ ConnectionReleaser = (*clientFactory)(nil)
@@ -0,0 +1,264 @@
+package httpf
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rmorlok/authproxy/internal/apid"
+	"github.com/rmorlok/authproxy/internal/schema/resources/connectors"
+	"github.com/stretchr/testify/require"
+)
+
+// connectionWithClientCertificate is a stubConnection that also implements
+// httpf.ClientCertificateProvider.
+type connectionWithClientCertificate struct {
+	stubConnection
+	cert  *tls.Certificate
+	err   error
+	calls int
+}
+
+func (c *connectionWithClientCertificate) RequiresClientCertificate() bool { return true }
+
+func (c *connectionWithClientCertificate) GetClientCertificate(context.Context) (*tls.Certificate, error) {
+	c.calls++
+	return c.cert, c.err
+}
+
+func mustSelfSignedClientCertificate(t *testing.T) *tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "authproxy-test-client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	leaf, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return &tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
+
+// newMutualTLSServer starts a TLS server that requires a client certificate
+// signed by (here: equal to) clientCert, echoing the client's common name.
+func newMutualTLSServer(t *testing.T, clientCert *tls.Certificate) *httptest.Server {
+	t.Helper()
+	pool := x509.NewCertPool()
+	pool.AddCert(clientCert.Leaf)
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	srv.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: pool}
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestForConnectionPresentsClientCertificate(t *testing.T) {
+	clientCert := mustSelfSignedClientCertificate(t)
+	srv := newMutualTLSServer(t, clientCert)
+
+	root := newTestFactory()
+	root.connectionTransports = newConnectionTransports(srv.Client().Transport.(*http.Transport))
+
+	conn := &connectionWithClientCertificate{
+		stubConnection: stubConnection{
+			id:        apid.MustParse("cxn_test1234567890ab"),
+			namespace: "root",
+		},
+		cert: clientCert,
+	}
+
+	f := root.ForConnection(conn).ForRequestType(RequestTypeProxy).(*clientFactory)
+	require.Same(t, conn, f.clientCertificate, "derived factories keep the connection's certificate")
+
+	resp, err := f.New().URL(srv.URL).Request().Send()
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "authproxy-test-client", resp.String())
+
+	// A second factory for the same connection reuses the cached transport
+	// and its pooled TLS connection.
+	resp, err = root.ForConnection(conn).New().URL(srv.URL).Request().Send()
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, 1, conn.calls)
+}
+
+func TestForConnectionClientCertificateErrorFailsHandshake(t *testing.T) {
+	clientCert := mustSelfSignedClientCertificate(t)
+	srv := newMutualTLSServer(t, clientCert)
+
+	root := newTestFactory()
+	root.connectionTransports = newConnectionTransports(srv.Client().Transport.(*http.Transport))
+
+	conn := &connectionWithClientCertificate{
+		stubConnection: stubConnection{id: apid.MustParse("cxn_test1234567890ab")},
+		err:            errors.New("no client certificate uploaded"),
+	}
+
+	_, err := root.ForConnection(conn).New().URL(srv.URL).Request().Send()
+	require.ErrorContains(t, err, "no client certificate uploaded")
+}
+
+func TestForConnectionWithoutClientCertificateUsesDefaultTransport(t *testing.T) {
+	conn := &stubConnection{id: apid.MustParse("cxn_test1234567890ab")}
+	f := newTestFactory().ForConnection(conn).(*clientFactory)
+	require.Nil(t, f.clientCertificate)
+	require.Equal(t, http.DefaultTransport, f.baseTransport(nil))
+}
+
+// connectionWithTransportTLS is a stubConnection that also implements
+// httpf.TransportTLSProvider.
+type connectionWithTransportTLS struct {
+	stubConnection
+	settings *connectors.TransportTLS
+	err      error
+}
+
+func (c *connectionWithTransportTLS) HasTransportTLS() bool { return true }
+
+func (c *connectionWithTransportTLS) GetTransportTLS(context.Context) (*connectors.TransportTLS, error) {
+	return c.settings, c.err
+}
+
+// newPrivateCATLSServer starts a TLS server whose certificate (valid for
+// example.com and 127.0.0.1) is not trusted by the system roots. It returns
+// the server and the certificate as PEM.
+func newPrivateCATLSServer(t *testing.T) (*httptest.Server, string) {
+	t.Helper()
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.TLS.ServerName))
+	}))
+	srv.TLS = &tls.Config{MaxVersion: tls.VersionTLS12}
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	return srv, string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}))
+}
+
+func TestForConnectionTransportTLS(t *testing.T) {
+	srv, caPEM := newPrivateCATLSServer(t)
+	sum := sha256.Sum256(srv.Certificate().RawSubjectPublicKeyInfo)
+	pin := base64.StdEncoding.EncodeToString(sum[:])
+	otherPin := base64.StdEncoding.EncodeToString(make([]byte, 32))
+
+	send := func(t *testing.T, root *clientFactory, settings *connectors.TransportTLS) (string, error) {
+		t.Helper()
+		conn := &connectionWithTransportTLS{
+			stubConnection: stubConnection{id: apid.MustParse("cxn_test1234567890ab")},
+			settings:       settings,
+		}
+		resp, err := root.ForConnection(conn).New().URL(srv.URL).Request().Send()
+		if err != nil {
+			return "", err
+		}
+		return resp.String(), nil
+	}
+
+	t.Run("system roots alone reject the private CA", func(t *testing.T) {
+		_, err := send(t, newTestFactory(), &connectors.TransportTLS{})
+		require.ErrorContains(t, err, "certificate signed by unknown authority")
+	})
+
+	t.Run("additional CA is trusted", func(t *testing.T) {
+		_, err := send(t, newTestFactory(), &connectors.TransportTLS{CaCertificates: []string{caPEM}})
+		require.NoError(t, err)
+	})
+
+	t.Run("server name override", func(t *testing.T) {
+		body, err := send(t, newTestFactory(), &connectors.TransportTLS{CaCertificates: []string{caPEM}, ServerName: "example.com"})
+		require.NoError(t, err)
+		require.Equal(t, "example.com", body)
+
+		_, err = send(t, newTestFactory(), &connectors.TransportTLS{CaCertificates: []string{caPEM}, ServerName: "other.example.org"})
+		require.ErrorContains(t, err, "other.example.org")
+	})
+
+	t.Run("minimum version", func(t *testing.T) {
+		_, err := send(t, newTestFactory(), &connectors.TransportTLS{CaCertificates: []string{caPEM}, MinVersion: "1.3"})
+		require.ErrorContains(t, err, "protocol version")
+	})
+
+	t.Run("pinned public key", func(t *testing.T) {
+		_, err := send(t, newTestFactory(), &connectors.TransportTLS{CaCertificates: []string{caPEM}, PinnedSpkiSha256: []string{otherPin, pin}})
+		require.NoError(t, err)
+
+		_, err = send(t, newTestFactory(), &connectors.TransportTLS{CaCertificates: []string{caPEM}, PinnedSpkiSha256: []string{otherPin}})
+		require.ErrorContains(t, err, ErrSpkiPinMismatch.Error())
+	})
+
+	t.Run("changed settings replace the cached transport", func(t *testing.T) {
+		root := newTestFactory()
+		root.connectionTransports = newConnectionTransports(nil)
+		_, err := send(t, root, &connectors.TransportTLS{CaCertificates: []string{caPEM}})
+		require.NoError(t, err)
+
+		_, err = send(t, root, &connectors.TransportTLS{CaCertificates: []string{caPEM}, PinnedSpkiSha256: []string{otherPin}})
+		require.ErrorContains(t, err, ErrSpkiPinMismatch.Error())
+		require.Len(t, root.connectionTransports.byConnection, 1)
+	})
+
+	t.Run("settings error fails the request", func(t *testing.T) {
+		conn := &connectionWithTransportTLS{
+			stubConnection: stubConnection{id: apid.MustParse("cxn_test1234567890ab")},
+			err:            errors.New("configuration unavailable"),
+		}
+		_, err := newTestFactory().ForConnection(conn).New().URL(srv.URL).Request().Send()
+		require.ErrorContains(t, err, "configuration unavailable")
+	})
+}
+
+func TestConnectionTransportsEviction(t *testing.T) {
+	clientCert := mustSelfSignedClientCertificate(t)
+	provider := &connectionWithClientCertificate{cert: clientCert}
+	idle := apid.MustParse("cxn_test1234567890ab")
+	active := apid.MustParse("cxn_test1234567890cd")
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("idle transports are evicted", func(t *testing.T) {
+		c := newConnectionTransports(nil)
+		_, err := c.get(start, idle, nil, "", provider, nil)
+		require.NoError(t, err)
+		_, err = c.get(start, active, nil, "", provider, nil)
+		require.NoError(t, err)
+
+		// Only the active connection is used while the idle TTL passes.
+		_, err = c.get(start.Add(connectionTransportIdleTTL/2), active, nil, "", provider, nil)
+		require.NoError(t, err)
+		_, err = c.get(start.Add(connectionTransportIdleTTL+time.Minute), active, nil, "", provider, nil)
+		require.NoError(t, err)
+
+		require.Len(t, c.byConnection, 1)
+		require.Contains(t, c.byConnection, active)
+	})
+
+	t.Run("released transports are dropped", func(t *testing.T) {
+		root := newTestFactory()
+		root.connectionTransports = newConnectionTransports(nil)
+		_, err := root.connectionTransports.get(start, idle, nil, "", provider, nil)
+		require.NoError(t, err)
+
+		root.ForRequestType(RequestTypeProxy).(ConnectionReleaser).ReleaseConnection(idle)
+		require.Empty(t, root.connectionTransports.byConnection)
+	})
+}
@@ -0,0 +1,250 @@
+package httpf
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	sconfig "github.com/rmorlok/authproxy/internal/schema/config"
+	gcontext "gopkg.in/h2non/gentleman.v2/context"
+	"gopkg.in/h2non/gentleman.v2/plugin"
+)
+
+// EgressDeniedError is returned when an outbound call would connect to an
+// address the egress policy denies. It is raised from the dialer with the
+// address actually being dialed, so it also catches hostnames that resolve
+// to a denied address (including via DNS rebinding) and redirects to one.
+type EgressDeniedError struct {
+	// Host is the host that was requested, when known.
+	Host string
+
+	// Addr is the denied address.
+	Addr netip.Addr
+}
+
+func (e *EgressDeniedError) Error() string {
+	if e.Host != "" && e.Host != e.Addr.String() {
+		return fmt.Sprintf("egress to %s (%s) is denied by policy", e.Host, e.Addr)
+	}
+	return fmt.Sprintf("egress to %s is denied by policy", e.Addr)
+}
+
+// IsEgressDenied reports whether err was caused by the egress policy.
+func IsEgressDenied(err error) bool {
+	var denied *EgressDeniedError
+	return errors.As(err, &denied)
+}
+
+// egressPolicies resolves the egress policy for each namespace. Namespaces
+// with the same effective rules share a policy and therefore a transport, so
+// connection pools are shared as widely as the policy allows but a pooled
+// connection dialed under one policy is never reused under another.
+type egressPolicies struct {
+	cfg *sconfig.Egress
+
+	mu          sync.Mutex
+	byNamespace map[string]*egressPolicy
+	byKey       map[string]*egressPolicy
+}
+
+// newEgressPolicies applies the default policy when no egress block is
+// configured, so outbound calls are restricted unless an operator opts out
+// with an explicitly empty deniedCidrs.
+func newEgressPolicies(cfg *sconfig.Egress) *egressPolicies {
+	if cfg == nil {
+		cfg = &sconfig.Egress{}
+	}
+	return &egressPolicies{
+		cfg:         cfg,
+		byNamespace: make(map[string]*egressPolicy),
+		byKey:       make(map[string]*egressPolicy),
+	}
+}
+
+func (e *egressPolicies) forNamespace(ns string) *egressPolicy {
+	if e == nil {
+		return nil
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if p, ok := e.byNamespace[ns]; ok {
+		return p
+	}
+
+	rules := e.cfg.ForNamespace(ns)
+	key := fmt.Sprintf("deny=%s;allow=%s;redirects=%d",
+		strings.Join(rules.DeniedCidrs, ","),
+		strings.Join(rules.AllowedCidrs, ","),
+		rules.MaxRedirects,
+	)
+	p, ok := e.byKey[key]
+	if !ok {
+		p = newEgressPolicy(key, rules)
+		e.byKey[key] = p
+	}
+	e.byNamespace[ns] = p
+	return p
+}
+
+// egressPolicy is the parsed form of sconfig.EgressRules along with the
+// transport that enforces it.
+type egressPolicy struct {
+	key          string
+	denied       []netip.Prefix
+	allowed      []netip.Prefix
+	maxRedirects int
+	transport    *http.Transport
+}
+
+func newEgressPolicy(key string, rules sconfig.EgressRules) *egressPolicy {
+	p := &egressPolicy{
+		key:          key,
+		maxRedirects: rules.MaxRedirects,
+	}
+
+	// Config validation rejects malformed CIDRs, so anything that fails to
+	// parse here was never validated; skipping it rather than panicking
+	// matches how the rest of the config is consumed.
+	for _, c := range rules.DeniedCidrs {
+		if prefix, err := netip.ParsePrefix(c); err == nil {
+			p.denied = append(p.denied, prefix.Masked())
+		}
+	}
+	for _, c := range rules.AllowedCidrs {
+		if prefix, err := netip.ParsePrefix(c); err == nil {
+			p.allowed = append(p.allowed, prefix.Masked())
+		}
+	}
+
+	p.transport = p.newTransport()
+	return p
+}
+
+// allows reports whether addr may be dialed. Allowed ranges are exceptions
+// to denied ranges.
+func (p *egressPolicy) allows(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range p.allowed {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	for _, prefix := range p.denied {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// control runs after DNS resolution and before the socket connects, so
+// address is the IP actually being dialed.
+func (p *egressPolicy) control(_ context.Context, _ string, address string, _ syscall.RawConn) error {
+	ap, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("egress policy cannot parse dial address %q: %w", address, err)
+	}
+	if !p.allows(ap.Addr()) {
+		return &EgressDeniedError{Addr: ap.Addr().Unmap()}
+	}
+	return nil
+}
+
+// checkHost resolves host and checks every address it resolves to. It is
+// used where the dial isn't to the target itself (an HTTP proxy) and to fail
+// redirects early; the dial-time check remains authoritative.
+func (p *egressPolicy) checkHost(ctx context.Context, host string) error {
+	if len(p.denied) == 0 {
+		// Nothing is denied, so there is no need to resolve the host.
+		return nil
+	}
+	if addr, err := netip.ParseAddr(host); err == nil {
+		if !p.allows(addr) {
+			return &EgressDeniedError{Host: host, Addr: addr.Unmap()}
+		}
+		return nil
+	}
+
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return err
+	}
+	for _, addr := range addrs {
+		if !p.allows(addr) {
+			return &EgressDeniedError{Host: host, Addr: addr.Unmap()}
+		}
+	}
+	return nil
+}
+
+// checkRedirect caps the number of redirects and re-validates each hop
+// before it is followed.
+func (p *egressPolicy) checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) > p.maxRedirects {
+		return fmt.Errorf("stopped after %d redirects", p.maxRedirects)
+	}
+	if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+		return fmt.Errorf("redirect to unsupported scheme %q", req.URL.Scheme)
+	}
+	return p.checkHost(req.Context(), req.URL.Hostname())
+}
+
+// newTransport clones http.DefaultTransport with a dialer that enforces the
+// policy.
+func (p *egressPolicy) newTransport() *http.Transport {
+	var t *http.Transport
+	if dt, ok := http.DefaultTransport.(*http.Transport); ok {
+		t = dt.Clone()
+	} else {
+		t = &http.Transport{Proxy: http.ProxyFromEnvironment}
+	}
+
+	dialer := &net.Dialer{
+		Timeout:        30 * time.Second,
+		KeepAlive:      30 * time.Second,
+		ControlContext: p.control,
+	}
+	t.DialContext = dialer.DialContext
+
+	// When an HTTP proxy is used the dial is to the proxy, so the target is
+	// checked by resolving it here. The proxy's own address is still subject
+	// to the dial check and must be allowed if it is private.
+	if baseProxy := t.Proxy; baseProxy != nil {
+		t.Proxy = func(req *http.Request) (*url.URL, error) {
+			u, err := baseProxy(req)
+			if err != nil || u == nil {
+				return u, err
+			}
+			if err := p.checkHost(req.Context(), req.URL.Hostname()); err != nil {
+				return nil, err
+			}
+			return u, nil
+		}
+	}
+
+	return t
+}
+
+// redirectPlugin applies the policy's redirect check to gentleman clients.
+func (p *egressPolicy) redirectPlugin() plugin.Plugin {
+	return plugin.NewRequestPlugin(func(ctx *gcontext.Context, h gcontext.Handler) {
+		ctx.Client.CheckRedirect = p.checkRedirect
+		h.Next(ctx)
+	})
+}
+
+// egressPolicy returns the policy for the factory's namespace, or nil when
+// egress is unrestricted.
+func (f *clientFactory) egressPolicy() *egressPolicy {
+	return f.egress.forNamespace(f.requestInfo.Namespace)
+}
@@ -0,0 +1,166 @@
+package httpf
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"testing"
+
+	"github.com/rmorlok/authproxy/internal/apid"
+	"github.com/rmorlok/authproxy/internal/config"
+	sconfig "github.com/rmorlok/authproxy/internal/schema/config"
+	"github.com/rmorlok/authproxy/internal/schema/resources/connectors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// unrestrictedEgress opts out of the default egress policy so tests can
+// reach httptest servers on loopback.
+func unrestrictedEgress() *sconfig.Egress {
+	return &sconfig.Egress{DeniedCidrs: []string{}}
+}
+
+func newEgressFactory(e *sconfig.Egress) *clientFactory {
+	requestLog := &recordingFactory{skipBuild: true}
+	return CreateFactory(config.FromRoot(&sconfig.Root{Egress: e}), nil, requestLog, nil).(*clientFactory)
+}
+
+func TestEgressPolicy_Allows(t *testing.T) {
+	p := newEgressPolicy("test", (&sconfig.Egress{AllowedCidrs: []string{"10.20.0.0/16"}}).ForNamespace("root"))
+
+	tests := []struct {
+		addr  string
+		allow bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"::ffff:127.0.0.1", false},
+		{"0.0.0.0", false},
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		{"10.0.0.1", false},
+		{"172.16.5.4", false},
+		{"192.168.1.1", false},
+		{"100.64.0.1", false},
+		{"100.127.255.254", false},
+		{"100.128.0.1", true},
+		{"fd00::1", false},
+		{"10.20.1.1", true},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.allow, p.allows(netip.MustParseAddr(tt.addr)), tt.addr)
+	}
+}
+
+func TestEgressPolicies_ShareTransportsByRules(t *testing.T) {
+	e := newEgressPolicies(&sconfig.Egress{
+		Namespaces: map[string]*sconfig.EgressOverride{
+			"root.trusted": {DeniedCidrs: []string{}},
+		},
+	})
+
+	assert.Same(t, e.forNamespace("root"), e.forNamespace("root.other"))
+	assert.NotSame(t, e.forNamespace("root"), e.forNamespace("root.trusted"))
+	assert.Equal(t, newEgressPolicies(&sconfig.Egress{}).forNamespace("root").key, newEgressPolicies(nil).forNamespace("root").key)
+	assert.Empty(t, newEgressPolicies(unrestrictedEgress()).forNamespace("root").denied)
+}
+
+func TestCreateFactory_EgressPolicy(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/metadata":
+			http.Redirect(w, r, "http://169.254.169.254/latest/meta-data", http.StatusFound)
+		case "/loop":
+			http.Redirect(w, r, "/loop", http.StatusFound)
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer srv.Close()
+
+	t.Run("no egress block applies the defaults", func(t *testing.T) {
+		_, err := newEgressFactory(nil).New().URL(srv.URL).Request().Send()
+		require.True(t, IsEgressDenied(err), "got %v", err)
+
+		_, err = CreateFactory(nil, nil, &recordingFactory{skipBuild: true}, nil).NewHTTPClient().Get(srv.URL)
+		require.True(t, IsEgressDenied(err), "got %v", err)
+	})
+
+	t.Run("empty denied list is unrestricted", func(t *testing.T) {
+		resp, err := newEgressFactory(unrestrictedEgress()).New().URL(srv.URL).Request().Send()
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("loopback is denied by default", func(t *testing.T) {
+		_, err := newEgressFactory(&sconfig.Egress{}).New().URL(srv.URL).Request().Send()
+		require.True(t, IsEgressDenied(err), "got %v", err)
+	})
+
+	t.Run("checks the resolved address, not the hostname", func(t *testing.T) {
+		u, err := url.Parse(srv.URL)
+		require.NoError(t, err)
+		_, err = newEgressFactory(&sconfig.Egress{}).NewHTTPClient().Get("http://localhost:" + u.Port())
+		require.True(t, IsEgressDenied(err), "got %v", err)
+	})
+
+	t.Run("namespace override", func(t *testing.T) {
+		f := newEgressFactory(&sconfig.Egress{
+			Namespaces: map[string]*sconfig.EgressOverride{
+				"root.trusted": {AllowedCidrs: []string{"127.0.0.1/32"}},
+			},
+		})
+
+		resp, err := f.ForConnection(&stubConnection{id: apid.MustParse("cxn_test1234567890ab"), namespace: "root.trusted.team"}).
+			New().URL(srv.URL).Request().Send()
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		_, err = f.ForConnection(&stubConnection{id: apid.MustParse("cxn_test1234567890ac"), namespace: "root.other"}).
+			New().URL(srv.URL).Request().Send()
+		require.True(t, IsEgressDenied(err), "got %v", err)
+	})
+
+	t.Run("redirect hops are re-validated", func(t *testing.T) {
+		f := newEgressFactory(&sconfig.Egress{AllowedCidrs: []string{"127.0.0.1/32"}})
+
+		_, err := f.New().URL(srv.URL + "/metadata").Request().Send()
+		require.True(t, IsEgressDenied(err), "got %v", err)
+
+		_, err = f.NewHTTPClient().Get(srv.URL + "/metadata")
+		require.True(t, IsEgressDenied(err), "got %v", err)
+	})
+
+	t.Run("redirects are capped", func(t *testing.T) {
+		two := 2
+		f := newEgressFactory(&sconfig.Egress{AllowedCidrs: []string{"127.0.0.1/32"}, MaxRedirects: &two})
+
+		_, err := f.NewHTTPClient().Get(srv.URL + "/loop")
+		require.ErrorContains(t, err, "stopped after 2 redirects")
+	})
+
+	t.Run("connection transports keep the egress dialer", func(t *testing.T) {
+		conn := &connectionWithTransportTLS{
+			stubConnection: stubConnection{id: apid.MustParse("cxn_test1234567890ab"), namespace: "root"},
+			settings:       &connectors.TransportTLS{MinVersion: "1.2"},
+		}
+		_, err := newEgressFactory(&sconfig.Egress{}).ForConnection(conn).New().URL(srv.URL).Request().Send()
+		require.True(t, IsEgressDenied(err), "got %v", err)
+	})
+}
+
+func TestEgressPolicy_CheckHost(t *testing.T) {
+	p := newEgressPolicy("test", (&sconfig.Egress{}).ForNamespace("root"))
+
+	err := p.checkHost(context.Background(), "169.254.169.254")
+	require.True(t, IsEgressDenied(err))
+	require.NoError(t, p.checkHost(context.Background(), "93.184.216.34"))
+
+	// With nothing denied the host isn't resolved at all.
+	unrestricted := newEgressPolicy("test", unrestrictedEgress().ForNamespace("root"))
+	require.NoError(t, unrestricted.checkHost(context.Background(), "upstream.invalid"))
+}
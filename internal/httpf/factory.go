@@ -12,6 +12,7 @@ import (
 	"github.com/rmorlok/authproxy/internal/config"
 	"github.com/rmorlok/authproxy/internal/database"
 	sconfig "github.com/rmorlok/authproxy/internal/schema/config"
+	"github.com/rmorlok/authproxy/internal/schema/resources/connectors"
 	"gopkg.in/h2non/gentleman.v2"
 	"gopkg.in/h2non/gentleman.v2/plugins/transport"
 )
@@ -23,6 +24,31 @@ type clientFactory struct {
 	logger      *slog.Logger
 	requestInfo RequestInfo
 
+	// clientCertificate is set for connections that require mutual TLS; see
+	// ClientCertificateProvider.
+	clientCertificate ClientCertificateProvider
+
+	// transportTLS is set for connections whose connector customizes TLS
+	// verification; see TransportTLSProvider.
+	transportTLS TransportTLSProvider
+
+	// connectionTransports is shared by every factory derived from the same
+	// root so connections with a custom TLS handshake keep their connection
+	// pools across requests.
+	connectionTransports *connectionTransports
+
+	// egress applies the configured egress policy, or the default one when
+	// no egress block is configured.
+	egress *egressPolicies
+
+	// transportProxy is the connector's forward proxy, if it sets one; see
+	// TransportProxyProvider.
+	transportProxy *connectors.TransportProxy
+
+	// outboundProxies selects the forward proxy for each request and is
+	// shared by every factory derived from the same root.
+	outboundProxies *outboundProxies
+
 	// Cached at the object level
 
 	factoryParent     *gentleman.Client
@@ -53,7 +79,17 @@ func CreateFactory(
 			middlewares = append(middlewares, m)
 		}
 	}
-	middlewares = append(middlewares, requestLog)
+	// The rejecting round-tripper sits directly inside request-event logging
+	// so a request the caller has already decided to block is still logged,
+	// but never reaches the rate limiters, telemetry, or the network.
+	middlewares = append(middlewares, rejectionFactory{}, requestLog)
+
+	var egressCfg *sconfig.Egress
+	var outboundProxyCfg *sconfig.OutboundProxy
+	if cfg != nil && cfg.GetRoot() != nil {
+		egressCfg = cfg.GetRoot().Egress
+		outboundProxyCfg = cfg.GetRoot().OutboundProxy
+	}
 
 	return &clientFactory{
 		cfg:         cfg,
@@ -64,16 +100,29 @@ func CreateFactory(
 			Namespace: sconfig.RootNamespace,
 			Type:      RequestTypeGlobal,
 		},
+		connectionTransports: newConnectionTransports(nil),
+		egress:               newEgressPolicies(egressCfg),
+		outboundProxies:      newOutboundProxies(outboundProxyCfg),
 	}
 }
 
 func (f *clientFactory) ForRequestInfo(ri RequestInfo) F {
+	return f.withRequestInfo(ri)
+}
+
+func (f *clientFactory) withRequestInfo(ri RequestInfo) *clientFactory {
 	return &clientFactory{
-		cfg:         f.cfg,
-		r:           f.r,
-		middlewares: f.middlewares,
-		logger:      f.logger,
-		requestInfo: ri,
+		cfg:                  f.cfg,
+		r:                    f.r,
+		middlewares:          f.middlewares,
+		logger:               f.logger,
+		requestInfo:          ri,
+		clientCertificate:    f.clientCertificate,
+		transportTLS:         f.transportTLS,
+		connectionTransports: f.connectionTransports,
+		egress:               f.egress,
+		transportProxy:       f.transportProxy,
+		outboundProxies:      f.outboundProxies,
 	}
 }
 
@@ -118,11 +167,26 @@ func (f *clientFactory) ForConnection(c Connection) F {
 		ri.RateLimiting = rlp.GetRateLimitConfig()
 	}
 
+	if rcp, ok := c.(ResponseCacheConfigProvider); ok {
+		ri.ResponseCache = rcp.GetResponseCacheConfig()
+	}
+
 	if tpp, ok := c.(TracePropagationProvider); ok {
 		ri.PropagateTraceContext = tpp.PropagateTraceContext()
 	}
 
-	return fp.ForRequestInfo(ri)
+	next := fp.ForRequestInfo(ri).(*clientFactory)
+	if ccp, ok := c.(ClientCertificateProvider); ok && ccp.RequiresClientCertificate() {
+		next.clientCertificate = ccp
+	}
+	if tp, ok := c.(TransportTLSProvider); ok && tp.HasTransportTLS() {
+		next.transportTLS = tp
+	}
+	if pp, ok := c.(TransportProxyProvider); ok {
+		next.transportProxy = pp.GetTransportProxy()
+	}
+
+	return next
 }
 
 // ForActor attaches the initiating actor's identity and labels to the
@@ -210,6 +274,9 @@ func (f *clientFactory) New() *gentleman.Client {
 	f.factoryParentOnce.Do(func() {
 		f.factoryParent = gentleman.New()
 		f.factoryParent.Use(transport.Set(f.buildWrappedTransport()))
+		if p := f.egressPolicy(); p != nil {
+			f.factoryParent.Use(p.redirectPlugin())
+		}
 	})
 
 	return gentleman.New().UseParent(f.factoryParent)
@@ -219,17 +286,35 @@ func (f *clientFactory) New() *gentleman.Client {
 // wrapped RoundTripper used by New(). For the streaming raw-proxy path,
 // where we need direct net/http semantics (no gentleman body buffering).
 func (f *clientFactory) NewHTTPClient() *http.Client {
-	return &http.Client{Transport: f.buildWrappedTransport()}
+	c := &http.Client{Transport: f.buildWrappedTransport()}
+	if p := f.egressPolicy(); p != nil {
+		c.CheckRedirect = p.checkRedirect
+	}
+	return c
 }
 
-// buildWrappedTransport applies the registered middlewares around the
-// default transport. Cached per factory instance so the wrap (which can
-// allocate per-request-info state inside the middleware) happens once.
+// buildWrappedTransport applies the registered middlewares around the base
+// transport: the default transport, the transport for the request's egress
+// policy and outbound proxy, or the connection's own transport when its TLS
+// handshake is customized. The outbound proxy is selected here, once the
+// request labels are final, and recorded on the RequestInfo the middlewares
+// see. Cached per factory instance so the wrap
+// (which can allocate per-request-info state inside the middleware) happens
+// once.
 func (f *clientFactory) buildWrappedTransport() http.RoundTripper {
 	f.wrappedTransportOnce.Do(func() {
-		parent := http.DefaultTransport
+		ri := f.requestInfo
+
+		var parent http.RoundTripper
+		if proxy, err := f.outboundProxies.selectProxy(ri.Labels, f.transportProxy); err != nil {
+			parent = errRoundTripper{err: err}
+		} else {
+			ri.OutboundProxy = proxy.Display()
+			parent = f.baseTransport(proxy)
+		}
+
 		for _, m := range f.middlewares {
-			result := m.NewRoundTripper(f.requestInfo, parent)
+			result := m.NewRoundTripper(ri, parent)
 			if result != nil {
 				parent = result
 			}
@@ -6,6 +6,8 @@ import (
 	"sync"
 	"testing"
 
+	"github.com/rmorlok/authproxy/internal/config"
+	sconfig "github.com/rmorlok/authproxy/internal/schema/config"
 	"github.com/stretchr/testify/require"
 )
 
@@ -81,9 +83,10 @@ func TestCreateFactory_MiddlewareOrder(t *testing.T) {
 	mwB := &recordingFactory{name: "B", recorder: rec}
 	mwC := &recordingFactory{name: "C", recorder: rec}
 
-	// nil cfg / nil redis are fine — CreateFactory doesn't dereference
-	// them for this code path.
-	f := CreateFactory(nil, nil, requestLog, nil, mwA, mwB, mwC).(*clientFactory)
+	// nil redis is fine — CreateFactory doesn't dereference it for this
+	// code path. The upstream is on loopback, so egress is unrestricted.
+	cfg := config.FromRoot(&sconfig.Root{Egress: unrestrictedEgress()})
+	f := CreateFactory(cfg, nil, requestLog, nil, mwA, mwB, mwC).(*clientFactory)
 
 	// Build a request via gentleman so we hit the real chain.
 	client := f.New()
@@ -122,7 +125,8 @@ func TestCreateFactory_NilMiddlewareSkipped(t *testing.T) {
 	skipped := &recordingFactory{name: "skipped", recorder: rec, skipBuild: true}
 	mwA := &recordingFactory{name: "A", recorder: rec}
 
-	f := CreateFactory(nil, nil, requestLog, nil, skipped, mwA).(*clientFactory)
+	cfg := config.FromRoot(&sconfig.Root{Egress: unrestrictedEgress()})
+	f := CreateFactory(cfg, nil, requestLog, nil, skipped, mwA).(*clientFactory)
 	client := f.New()
 	_, err := client.Request().URL(upstream.URL).Send()
 	require.NoError(t, err)
@@ -1,6 +1,8 @@
 package httpf
 
 import (
+	"context"
+	"crypto/tls"
 	"net/http"
 
 	"github.com/rmorlok/authproxy/internal/apid"
@@ -25,6 +27,12 @@ type RateLimitConfigProvider interface {
 	GetRateLimitConfig() *connectors.RateLimiting
 }
 
+// ResponseCacheConfigProvider is an optional interface that connections can
+// implement to opt their proxied requests into the response cache.
+type ResponseCacheConfigProvider interface {
+	GetResponseCacheConfig() *connectors.ResponseCache
+}
+
 // TracePropagationProvider is an optional interface implemented by connections
 // whose connector definition specifies a per-connector override for outbound
 // W3C trace context injection. Return nil to inherit the global default.
@@ -32,6 +40,34 @@ type TracePropagationProvider interface {
 	PropagateTraceContext() *bool
 }
 
+// ClientCertificateProvider is an optional interface implemented by
+// connections whose connector requires mutual TLS with the upstream. When
+// RequiresClientCertificate returns true, outbound calls for the connection
+// use a transport that presents the certificate from GetClientCertificate
+// during the TLS handshake.
+type ClientCertificateProvider interface {
+	RequiresClientCertificate() bool
+	GetClientCertificate(ctx context.Context) (*tls.Certificate, error)
+}
+
+// TransportTLSProvider is an optional interface implemented by connections
+// whose connector customizes how the upstream's certificate is verified
+// (private CAs, minimum version, SNI override, pinned keys). When
+// HasTransportTLS returns true, GetTransportTLS is called per request with
+// the request context and must return the settings with any templates
+// already rendered.
+type TransportTLSProvider interface {
+	HasTransportTLS() bool
+	GetTransportTLS(ctx context.Context) (*connectors.TransportTLS, error)
+}
+
+// TransportProxyProvider is an optional interface implemented by connections
+// whose connector routes outbound calls through a forward proxy. Return nil
+// to use the global outbound proxy configuration.
+type TransportProxyProvider interface {
+	GetTransportProxy() *connectors.TransportProxy
+}
+
 type Connection interface {
 	GetId() apid.ID
 	GetNamespace() string
@@ -5,6 +5,8 @@
 package mock
 
 import (
+	context "context"
+	tls "crypto/tls"
 	http "net/http"
 	reflect "reflect"
 
@@ -192,6 +194,147 @@ func (mr *MockTracePropagationProviderMockRecorder) PropagateTraceContext() *gom
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PropagateTraceContext", reflect.TypeOf((*MockTracePropagationProvider)(nil).PropagateTraceContext))
 }
 
+// MockClientCertificateProvider is a mock of ClientCertificateProvider interface.
+type MockClientCertificateProvider struct {
+	ctrl     *gomock.Controller
+	recorder *MockClientCertificateProviderMockRecorder
+}
+
+// MockClientCertificateProviderMockRecorder is the mock recorder for MockClientCertificateProvider.
+type MockClientCertificateProviderMockRecorder struct {
+	mock *MockClientCertificateProvider
+}
+
+// NewMockClientCertificateProvider creates a new mock instance.
+func NewMockClientCertificateProvider(ctrl *gomock.Controller) *MockClientCertificateProvider {
+	mock := &MockClientCertificateProvider{ctrl: ctrl}
+	mock.recorder = &MockClientCertificateProviderMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockClientCertificateProvider) EXPECT() *MockClientCertificateProviderMockRecorder {
+	return m.recorder
+}
+
+// GetClientCertificate mocks base method.
+func (m *MockClientCertificateProvider) GetClientCertificate(ctx context.Context) (*tls.Certificate, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetClientCertificate", ctx)
+	ret0, _ := ret[0].(*tls.Certificate)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetClientCertificate indicates an expected call of GetClientCertificate.
+func (mr *MockClientCertificateProviderMockRecorder) GetClientCertificate(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetClientCertificate", reflect.TypeOf((*MockClientCertificateProvider)(nil).GetClientCertificate), ctx)
+}
+
+// RequiresClientCertificate mocks base method.
+func (m *MockClientCertificateProvider) RequiresClientCertificate() bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RequiresClientCertificate")
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// RequiresClientCertificate indicates an expected call of RequiresClientCertificate.
+func (mr *MockClientCertificateProviderMockRecorder) RequiresClientCertificate() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RequiresClientCertificate", reflect.TypeOf((*MockClientCertificateProvider)(nil).RequiresClientCertificate))
+}
+
+// MockTransportTLSProvider is a mock of TransportTLSProvider interface.
+type MockTransportTLSProvider struct {
+	ctrl     *gomock.Controller
+	recorder *MockTransportTLSProviderMockRecorder
+}
+
+// MockTransportTLSProviderMockRecorder is the mock recorder for MockTransportTLSProvider.
+type MockTransportTLSProviderMockRecorder struct {
+	mock *MockTransportTLSProvider
+}
+
+// NewMockTransportTLSProvider creates a new mock instance.
+func NewMockTransportTLSProvider(ctrl *gomock.Controller) *MockTransportTLSProvider {
+	mock := &MockTransportTLSProvider{ctrl: ctrl}
+	mock.recorder = &MockTransportTLSProviderMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockTransportTLSProvider) EXPECT() *MockTransportTLSProviderMockRecorder {
+	return m.recorder
+}
+
+// GetTransportTLS mocks base method.
+func (m *MockTransportTLSProvider) GetTransportTLS(ctx context.Context) (*connectors.TransportTLS, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTransportTLS", ctx)
+	ret0, _ := ret[0].(*connectors.TransportTLS)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTransportTLS indicates an expected call of GetTransportTLS.
+func (mr *MockTransportTLSProviderMockRecorder) GetTransportTLS(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTransportTLS", reflect.TypeOf((*MockTransportTLSProvider)(nil).GetTransportTLS), ctx)
+}
+
+// HasTransportTLS mocks base method.
+func (m *MockTransportTLSProvider) HasTransportTLS() bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "HasTransportTLS")
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// HasTransportTLS indicates an expected call of HasTransportTLS.
+func (mr *MockTransportTLSProviderMockRecorder) HasTransportTLS() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HasTransportTLS", reflect.TypeOf((*MockTransportTLSProvider)(nil).HasTransportTLS))
+}
+
+// MockTransportProxyProvider is a mock of TransportProxyProvider interface.
+type MockTransportProxyProvider struct {
+	ctrl     *gomock.Controller
+	recorder *MockTransportProxyProviderMockRecorder
+}
+
+// MockTransportProxyProviderMockRecorder is the mock recorder for MockTransportProxyProvider.
+type MockTransportProxyProviderMockRecorder struct {
+	mock *MockTransportProxyProvider
+}
+
+// NewMockTransportProxyProvider creates a new mock instance.
+func NewMockTransportProxyProvider(ctrl *gomock.Controller) *MockTransportProxyProvider {
+	mock := &MockTransportProxyProvider{ctrl: ctrl}
+	mock.recorder = &MockTransportProxyProviderMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockTransportProxyProvider) EXPECT() *MockTransportProxyProviderMockRecorder {
+	return m.recorder
+}
+
+// GetTransportProxy mocks base method.
+func (m *MockTransportProxyProvider) GetTransportProxy() *connectors.TransportProxy {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTransportProxy")
+	ret0, _ := ret[0].(*connectors.TransportProxy)
+	return ret0
+}
+
+// GetTransportProxy indicates an expected call of GetTransportProxy.
+func (mr *MockTransportProxyProviderMockRecorder) GetTransportProxy() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTransportProxy", reflect.TypeOf((*MockTransportProxyProvider)(nil).GetTransportProxy))
+}
+
 // MockConnection is a mock of Connection interface.
 type MockConnection struct {
 	ctrl     *gomock.Controller
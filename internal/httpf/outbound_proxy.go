@@ -0,0 +1,143 @@
+package httpf
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+
+	"github.com/rmorlok/authproxy/internal/database"
+	sconfig "github.com/rmorlok/authproxy/internal/schema/config"
+	"github.com/rmorlok/authproxy/internal/schema/resources/connectors"
+)
+
+// outboundProxies selects the forward proxy for each request and caches one
+// transport per egress policy and proxy, so proxied connections are pooled
+// across requests but never shared between proxies.
+type outboundProxies struct {
+	def       *connectors.TransportProxy
+	overrides []outboundProxyOverride
+
+	mu         sync.Mutex
+	transports map[string]*http.Transport
+}
+
+type outboundProxyOverride struct {
+	selector database.LabelSelector
+	err      error
+	proxy    *connectors.TransportProxy
+}
+
+func newOutboundProxies(cfg *sconfig.OutboundProxy) *outboundProxies {
+	o := &outboundProxies{
+		transports: make(map[string]*http.Transport),
+	}
+	if cfg == nil {
+		return o
+	}
+
+	o.def = cfg.Default
+	for _, override := range cfg.Overrides {
+		selector, err := database.ParseLabelSelector(override.LabelSelector)
+		o.overrides = append(o.overrides, outboundProxyOverride{
+			selector: selector,
+			err:      err,
+			proxy:    override.Proxy,
+		})
+	}
+	return o
+}
+
+// selectProxy returns the proxy for a request with the given labels, or nil
+// to connect directly. The first matching override wins, then the
+// connector's proxy, then the default.
+func (o *outboundProxies) selectProxy(labels map[string]string, connector *connectors.TransportProxy) (*connectors.TransportProxy, error) {
+	if o == nil {
+		return proxyOrDirect(connector), nil
+	}
+
+	for i, override := range o.overrides {
+		if override.err != nil {
+			// Failing the call is safer than letting traffic that should
+			// have been pinned to a proxy leave directly.
+			return nil, fmt.Errorf("outbound proxy override %d has an invalid label selector: %w", i, override.err)
+		}
+		if override.selector.Matches(labels) {
+			return proxyOrDirect(override.proxy), nil
+		}
+	}
+
+	if connector != nil {
+		return proxyOrDirect(connector), nil
+	}
+	return proxyOrDirect(o.def), nil
+}
+
+func proxyOrDirect(p *connectors.TransportProxy) *connectors.TransportProxy {
+	if p == nil || p.Direct {
+		return nil
+	}
+	return p
+}
+
+// transport returns the shared transport that routes through p, layered on
+// the egress policy's transport when there is one, along with a key that
+// identifies it.
+func (o *outboundProxies) transport(egress *egressPolicy, p *connectors.TransportProxy) (*http.Transport, string, error) {
+	b, err := json.Marshal(p)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to fingerprint outbound proxy: %w", err)
+	}
+	sum := sha256.Sum256(b)
+	key := "proxy=" + hex.EncodeToString(sum[:])
+	if egress != nil {
+		key = egress.key + ";" + key
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if t, ok := o.transports[key]; ok {
+		return t, key, nil
+	}
+
+	var t *http.Transport
+	if egress != nil {
+		t = egress.transport.Clone()
+	} else if dt, ok := http.DefaultTransport.(*http.Transport); ok {
+		t = dt.Clone()
+	} else {
+		t = &http.Transport{}
+	}
+
+	// The dial is to the proxy, so the egress policy can only check the
+	// proxy's address there; the target is checked here instead, the same
+	// way the egress transport treats an environment proxy.
+	t.Proxy = func(req *http.Request) (*url.URL, error) {
+		if egress != nil {
+			if err := egress.checkHost(req.Context(), req.URL.Hostname()); err != nil {
+				return nil, err
+			}
+		}
+		return p.GetProxyUrl(req.Context())
+	}
+
+	o.transports[key] = t
+	return t, key, nil
+}
+
+// errRoundTripper fails every request with err, for factories whose transport
+// could not be built.
+type errRoundTripper struct {
+	err error
+}
+
+func (rt errRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	return nil, rt.err
+}
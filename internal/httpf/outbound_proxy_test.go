@@ -0,0 +1,168 @@
+package httpf
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/rmorlok/authproxy/internal/apid"
+	"github.com/rmorlok/authproxy/internal/config"
+	"github.com/rmorlok/authproxy/internal/schema/common"
+	sconfig "github.com/rmorlok/authproxy/internal/schema/config"
+	"github.com/rmorlok/authproxy/internal/schema/resources/connectors"
+	"github.com/rmorlok/authproxy/internal/schema/resources/key"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// forwardProxy is a minimal HTTP forward proxy: plain-http requests arrive
+// with an absolute URL and are answered directly rather than forwarded.
+type forwardProxy struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	targets   []string
+	authorize []string
+}
+
+func newForwardProxy(t *testing.T, name string) *forwardProxy {
+	p := &forwardProxy{}
+	p.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.mu.Lock()
+		p.targets = append(p.targets, r.URL.String())
+		p.authorize = append(p.authorize, r.Header.Get("Proxy-Authorization"))
+		p.mu.Unlock()
+		w.Header().Set("X-Proxy", name)
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(p.Close)
+	return p
+}
+
+// requestInfoRecorder captures the RequestInfo the middlewares are built with.
+type requestInfoRecorder struct {
+	ri RequestInfo
+}
+
+func (r *requestInfoRecorder) NewRoundTripper(ri RequestInfo, _ http.RoundTripper) http.RoundTripper {
+	r.ri = ri
+	return nil
+}
+
+type connectionWithProxy struct {
+	stubConnection
+	proxy *connectors.TransportProxy
+}
+
+func (c *connectionWithProxy) GetTransportProxy() *connectors.TransportProxy { return c.proxy }
+
+func TestCreateFactory_OutboundProxy(t *testing.T) {
+	defaultProxy := newForwardProxy(t, "default")
+	tenantProxy := newForwardProxy(t, "tenant")
+	connectorProxy := newForwardProxy(t, "connector")
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Proxy", "none")
+	}))
+	defer upstream.Close()
+
+	root := &sconfig.Root{
+		Egress: unrestrictedEgress(),
+		OutboundProxy: &sconfig.OutboundProxy{
+			Default: &connectors.TransportProxy{
+				Url:      defaultProxy.URL,
+				Username: common.NewStringValueDirect("authproxy"),
+				Password: &key.KeyData{InnerVal: &key.KeyDataValue{Value: "s3cret"}},
+			},
+			Overrides: []sconfig.OutboundProxyOverride{
+				{LabelSelector: "apxy/ns/tenant=acme", Proxy: &connectors.TransportProxy{Url: tenantProxy.URL}},
+				{LabelSelector: "apxy/ns/egress=direct", Proxy: &connectors.TransportProxy{Direct: true}},
+			},
+		},
+	}
+
+	newFactory := func() (*clientFactory, *requestInfoRecorder) {
+		rec := &requestInfoRecorder{}
+		return CreateFactory(config.FromRoot(root), nil, rec, nil).(*clientFactory), rec
+	}
+	conn := func(labels map[string]string, proxy *connectors.TransportProxy) Connection {
+		return &connectionWithProxy{
+			stubConnection: stubConnection{id: apid.New(apid.PrefixConnection), namespace: "root", labels: labels},
+			proxy:          proxy,
+		}
+	}
+
+	t.Run("default proxy with credentials", func(t *testing.T) {
+		f, rec := newFactory()
+		resp, err := f.NewHTTPClient().Get("http://upstream.example/things")
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		assert.Equal(t, "default", resp.Header.Get("X-Proxy"))
+		assert.Equal(t, defaultProxy.URL, rec.ri.OutboundProxy)
+		require.Len(t, defaultProxy.targets, 1)
+		assert.Equal(t, "http://upstream.example/things", defaultProxy.targets[0])
+		assert.Equal(t, "Basic "+base64.StdEncoding.EncodeToString([]byte("authproxy:s3cret")), defaultProxy.authorize[0])
+	})
+
+	t.Run("namespace label override wins over the connector", func(t *testing.T) {
+		f, rec := newFactory()
+		c := conn(map[string]string{"apxy/ns/tenant": "acme"}, &connectors.TransportProxy{Url: connectorProxy.URL})
+		resp, err := f.ForConnection(c).NewHTTPClient().Get("http://upstream.example/things")
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		assert.Equal(t, "tenant", resp.Header.Get("X-Proxy"))
+		assert.Equal(t, tenantProxy.URL, rec.ri.OutboundProxy)
+	})
+
+	t.Run("connector proxy wins over the default", func(t *testing.T) {
+		f, rec := newFactory()
+		c := conn(map[string]string{"apxy/ns/tenant": "other"}, &connectors.TransportProxy{Url: connectorProxy.URL})
+		resp, err := f.ForConnection(c).NewHTTPClient().Get("http://upstream.example/things")
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		assert.Equal(t, "connector", resp.Header.Get("X-Proxy"))
+		assert.Equal(t, connectorProxy.URL, rec.ri.OutboundProxy)
+	})
+
+	t.Run("direct override bypasses the default", func(t *testing.T) {
+		f, rec := newFactory()
+		c := conn(map[string]string{"apxy/ns/egress": "direct"}, nil)
+		resp, err := f.ForConnection(c).New().URL(upstream.URL).Request().Send()
+		require.NoError(t, err)
+
+		assert.Equal(t, "none", resp.Header.Get("X-Proxy"))
+		assert.Empty(t, rec.ri.OutboundProxy)
+	})
+
+	t.Run("invalid selector fails the request", func(t *testing.T) {
+		bad := &sconfig.Root{
+			Egress: unrestrictedEgress(),
+			OutboundProxy: &sconfig.OutboundProxy{
+				Overrides: []sconfig.OutboundProxyOverride{
+					{LabelSelector: "apxy/ns/tenant=acme,", Proxy: &connectors.TransportProxy{Url: tenantProxy.URL}},
+				},
+			},
+		}
+		f := CreateFactory(config.FromRoot(bad), nil, &requestInfoRecorder{}, nil)
+		_, err := f.NewHTTPClient().Get(upstream.URL)
+		require.ErrorContains(t, err, "invalid label selector")
+	})
+
+	t.Run("egress policy still checks the target", func(t *testing.T) {
+		withEgress := *root
+		withEgress.Egress = &sconfig.Egress{AllowedCidrs: []string{"127.0.0.1/32"}}
+		f := CreateFactory(config.FromRoot(&withEgress), nil, &requestInfoRecorder{}, nil)
+
+		_, err := f.NewHTTPClient().Get("http://169.254.169.254/latest/meta-data")
+		require.True(t, IsEgressDenied(err), "got %v", err)
+
+		resp, err := f.NewHTTPClient().Get(upstream.URL)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, "default", resp.Header.Get("X-Proxy"))
+	})
+}
@@ -0,0 +1,88 @@
+package httpf
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+)
+
+// Rejection describes a request that AuthProxy refused to send upstream. Callers that decide to block a request
+// before it reaches the network (e.g. the proxy orchestrator's upstream allowlist check) still want the attempt to
+// show up in request events, so instead of returning early they send the request through the httpf client with a
+// Rejection on the context. The rejecting round-tripper, which sits directly inside request-event logging, answers
+// with a synthetic response and nothing further down the chain runs — no rate-limit counters are consumed and no
+// bytes leave the process.
+type Rejection struct {
+	// StatusCode is the status of the synthetic response. Defaults to 403.
+	StatusCode int
+
+	// Reason is a short, caller-safe explanation included in the synthetic response body.
+	Reason string
+}
+
+type rejectionKey struct{}
+
+// ContextWithRejection marks every request sent with the returned context as rejected.
+func ContextWithRejection(ctx context.Context, r *Rejection) context.Context {
+	if r == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, rejectionKey{}, r)
+}
+
+// RejectionFromContext returns the Rejection carried by ctx, or nil when the request should be sent normally.
+func RejectionFromContext(ctx context.Context) *Rejection {
+	if ctx == nil {
+		return nil
+	}
+	r, _ := ctx.Value(rejectionKey{}).(*Rejection)
+	return r
+}
+
+// rejectionFactory installs rejectingRoundTripper. It is added by CreateFactory rather than by callers so every
+// client built from the factory honours rejections.
+type rejectionFactory struct{}
+
+func (rejectionFactory) NewRoundTripper(_ RequestInfo, transport http.RoundTripper) http.RoundTripper {
+	return &rejectingRoundTripper{transport: transport}
+}
+
+type rejectingRoundTripper struct {
+	transport http.RoundTripper
+}
+
+func (rt *rejectingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	r := RejectionFromContext(req.Context())
+	if r == nil {
+		return rt.transport.RoundTrip(req)
+	}
+
+	if req.Body != nil {
+		_ = req.Body.Close()
+	}
+	return r.syntheticResponse(req), nil
+}
+
+func (r *Rejection) syntheticResponse(req *http.Request) *http.Response {
+	status := r.StatusCode
+	if status == 0 {
+		status = http.StatusForbidden
+	}
+
+	body, _ := json.Marshal(map[string]string{"error": r.Reason})
+	return &http.Response{
+		Status:        http.StatusText(status),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {"application/json"}, "X-Authproxy-Rejected": {"true"}},
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+var _ RoundTripperFactory = rejectionFactory{}
@@ -0,0 +1,53 @@
+package httpf
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestCreateFactory_RejectionShortCircuitsInsideRequestLog pins where the
+// rejecting round-tripper sits: request-event logging still sees the
+// request, but no other middleware and not the upstream does.
+func TestCreateFactory_RejectionShortCircuitsInsideRequestLog(t *testing.T) {
+	rec := &orderRecorder{}
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec.record("upstream")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	requestLog := &recordingFactory{name: "requestLog", recorder: rec}
+	mwA := &recordingFactory{name: "A", recorder: rec}
+
+	f := CreateFactory(nil, nil, requestLog, nil, mwA).(*clientFactory)
+
+	ctx := ContextWithRejection(context.Background(), &Rejection{Reason: "not allowed"})
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, upstream.URL, nil)
+	require.NoError(t, err)
+
+	resp, err := f.NewHTTPClient().Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+	require.Equal(t, "true", resp.Header.Get("X-Authproxy-Rejected"))
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"error":"not allowed"}`, string(body))
+
+	require.Equal(t, []string{"requestLog"}, rec.snapshot())
+}
+
+func TestRejectionFromContext(t *testing.T) {
+	require.Nil(t, RejectionFromContext(context.Background()))
+	require.Equal(t, context.Background(), ContextWithRejection(context.Background(), nil))
+
+	r := &Rejection{StatusCode: http.StatusBadGateway}
+	require.Same(t, r, RejectionFromContext(ContextWithRejection(context.Background(), r)))
+}
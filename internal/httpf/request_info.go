@@ -32,10 +32,19 @@ type RequestInfo struct {
 	// Nil means use default behavior (enabled with standard Retry-After parsing).
 	RateLimiting *connectors.RateLimiting
 
+	// ResponseCache is the connector's response cache configuration. Nil
+	// means responses for the connection are never cached.
+	ResponseCache *connectors.ResponseCache
+
 	// PropagateTraceContext is the per-connection / per-connector override
 	// for W3C trace context injection on outbound calls. nil means "use the
 	// global default from telemetry.propagation.inject_outbound_default".
 	// Populated by ForConnection from a connection whose connector defines
 	// telemetry.propagate_trace_context.
 	PropagateTraceContext *bool
+
+	// OutboundProxy identifies the forward proxy outbound calls are routed
+	// through, e.g. "socks5://proxy.internal:1080". Empty for direct
+	// connections. Set by the factory when the transport is built.
+	OutboundProxy string
 }
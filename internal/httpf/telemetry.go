@@ -196,7 +196,8 @@ func (rt *telemetryRoundTripper) RoundTrip(req *http.Request) (*http.Response, e
 
 // spanStartAttributes returns the attributes known at request start: HTTP
 // method, URL parts, request-type, connector/connection identity (when
-// present), and the projected span-attribute labels.
+// present), the outbound proxy (when one is used), and the projected
+// span-attribute labels.
 func (rt *telemetryRoundTripper) spanStartAttributes(req *http.Request) []attribute.KeyValue {
 	attrs := []attribute.KeyValue{
 		semconv.HTTPRequestMethodKey.String(req.Method),
@@ -213,6 +214,9 @@ func (rt *telemetryRoundTripper) spanStartAttributes(req *http.Request) []attrib
 		}
 	}
 	attrs = append(attrs, rt.identityAttributes()...)
+	if p := rt.requestInfo.OutboundProxy; p != "" {
+		attrs = append(attrs, attribute.String("authproxy.outbound_proxy", p))
+	}
 	attrs = append(attrs, rt.factory.projector.SpanAttrs(rt.requestInfo.Labels)...)
 	return attrs
 }
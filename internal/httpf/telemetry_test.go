@@ -281,6 +281,27 @@ func TestTelemetryRoundTripper_ConnectorIdentityFromRequestInfo(t *testing.T) {
 	requireDimEqual(t, dur, "authproxy.connector_id", connectorID.String())
 }
 
+func TestTelemetryRoundTripper_OutboundProxyOnSpan(t *testing.T) {
+	fx := newTelemetryFixture(t)
+	cfg := &sconfig.Telemetry{Enabled: enabledPtr(true)}
+
+	factory, err := NewTelemetryFactory(fx.providers, cfg)
+	require.NoError(t, err)
+
+	ri := RequestInfo{
+		Type:          RequestTypeProxy,
+		OutboundProxy: "socks5://proxy.internal:1080",
+	}
+	rt := factory.NewRoundTripper(ri, &stubTransport{status: http.StatusOK, body: "ok"})
+
+	_, err = rt.RoundTrip(newRequest(t, http.MethodGet, "https://api.example.com/v1/things", ""))
+	require.NoError(t, err)
+
+	gotSpans := fx.spans.Ended()
+	require.Len(t, gotSpans, 1)
+	require.Equal(t, "socks5://proxy.internal:1080", attrMap(gotSpans[0].Attributes())["authproxy.outbound_proxy"])
+}
+
 func TestTelemetryRoundTripper_NoBodySizeWhenContentLengthUnknown(t *testing.T) {
 	fx := newTelemetryFixture(t)
 	cfg := &sconfig.Telemetry{Enabled: enabledPtr(true)}
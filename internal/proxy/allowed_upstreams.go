@@ -0,0 +1,109 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	apauthcore "github.com/rmorlok/authproxy/internal/apauth/core"
+	"github.com/rmorlok/authproxy/internal/app_metrics"
+	"github.com/rmorlok/authproxy/internal/core/iface"
+	"github.com/rmorlok/authproxy/internal/httperr"
+	"github.com/rmorlok/authproxy/internal/httpf"
+	cschema "github.com/rmorlok/authproxy/internal/schema/resources/connectors"
+)
+
+// AllowedUpstreamsProvider is implemented by connections that can report the
+// connector's allowedUpstreams rendered for this connection (tenant
+// subdomains and the like resolved from cfg). A nil or empty result means
+// the connector imposes no restriction. Connections that don't implement
+// it are unrestricted, which keeps test fixtures and older callers working.
+type AllowedUpstreamsProvider interface {
+	GetAllowedUpstreams(ctx context.Context) (cschema.AllowedUpstreams, error)
+}
+
+const upstreamNotAllowedMsg = "upstream url is not allowed for this connector"
+
+// checkUpstreamAllowed reports whether target may receive this connection's
+// credentials. It must run before Authenticator.Resolve so a disallowed
+// request never causes a token load or refresh. When the allowlist can't be
+// rendered (e.g. a tenant-supplied subdomain that isn't a valid host) the
+// check fails closed.
+func (p *proxy) checkUpstreamAllowed(ctx context.Context, reqType httpf.RequestType, target *url.URL) bool {
+	provider, ok := p.conn.(AllowedUpstreamsProvider)
+	if !ok {
+		return true
+	}
+
+	allowed, err := provider.GetAllowedUpstreams(ctx)
+	if err != nil {
+		p.logger.WarnContext(ctx, "failed to resolve allowed upstreams; rejecting request",
+			"connection_id", p.conn.GetId().String(),
+			"request_type", reqType.String(),
+			"error", err,
+		)
+		return false
+	}
+	if len(allowed) == 0 {
+		return true
+	}
+
+	if target != nil && allowed.Allows(target) {
+		return true
+	}
+
+	host := ""
+	if target != nil {
+		host = target.Host
+	}
+	p.logger.WarnContext(ctx, "proxy request rejected by connector allowed upstreams",
+		"connection_id", p.conn.GetId().String(),
+		"request_type", reqType.String(),
+		"upstream_host", host,
+	)
+	return false
+}
+
+// rejectionContext marks requests sent with the returned context as
+// rejected so the httpf chain records a request event attributed to
+// ResponseSourceUpstreamNotAllowed without sending anything upstream.
+func rejectionContext(ctx context.Context) context.Context {
+	ctx = httpf.ContextWithRejection(ctx, &httpf.Rejection{
+		StatusCode: http.StatusForbidden,
+		Reason:     upstreamNotAllowedMsg,
+	})
+	return app_metrics.ContextWithAttribution(ctx, &app_metrics.Attribution{
+		Source: app_metrics.ResponseSourceUpstreamNotAllowed,
+	})
+}
+
+func errUpstreamNotAllowed() error {
+	return httperr.Forbidden(upstreamNotAllowedMsg)
+}
+
+// recordRejected sends the caller's request, without credentials, through
+// the httpf chain on a rejection context purely so the attempt lands in
+// request events. The synthetic response is discarded; the caller gets
+// errUpstreamNotAllowed.
+func (p *proxy) recordRejected(ctx context.Context, reqType httpf.RequestType, req *iface.ProxyRequest) {
+	r := p.httpf.
+		ForRequestType(reqType).
+		ForConnection(p.conn).
+		ForActor(apauthcore.ActorFromContext(ctx)).
+		ForLabels(req.Labels).
+		New().
+		UseContext(rejectionContext(ctx)).
+		Request()
+
+	req.Apply(r)
+	_, _ = r.Do()
+}
+
+// recordRejectedRaw is the raw-path twin of recordRejected. The outbound
+// body is never read; the rejecting round-tripper closes it.
+func (p *proxy) recordRejectedRaw(ctx context.Context, client *http.Client, outbound *http.Request) {
+	resp, err := client.Do(outbound.WithContext(rejectionContext(ctx)))
+	if err == nil {
+		drainAndClose(resp.Body)
+	}
+}
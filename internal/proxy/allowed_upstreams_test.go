@@ -0,0 +1,119 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/rmorlok/authproxy/internal/apid"
+	"github.com/rmorlok/authproxy/internal/app_metrics"
+	"github.com/rmorlok/authproxy/internal/core/iface"
+	mockCore "github.com/rmorlok/authproxy/internal/core/mock"
+	"github.com/rmorlok/authproxy/internal/httperr"
+	"github.com/rmorlok/authproxy/internal/httpf"
+	cschema "github.com/rmorlok/authproxy/internal/schema/resources/connectors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// allowlistConnection is a mock connection that also reports an allowlist.
+type allowlistConnection struct {
+	mockCore.Connection
+	allowed cschema.AllowedUpstreams
+	err     error
+}
+
+func (c *allowlistConnection) GetAllowedUpstreams(ctx context.Context) (cschema.AllowedUpstreams, error) {
+	return c.allowed, c.err
+}
+
+// rejectionObservingTransport stands in for the httpf chain: it answers
+// rejected requests the way the real rejecting round-tripper does and
+// records the attribution source the request-events layer would see.
+type rejectionObservingTransport struct {
+	next     http.RoundTripper
+	rejected atomic.Int32
+	source   atomic.Value
+}
+
+func (t *rejectionObservingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if httpf.RejectionFromContext(req.Context()) != nil {
+		t.rejected.Add(1)
+		if attr := app_metrics.AttributionFromContext(req.Context()); attr != nil {
+			t.source.Store(attr.Source)
+		}
+		return &http.Response{StatusCode: http.StatusForbidden, Body: http.NoBody, Request: req}, nil
+	}
+	return t.next.RoundTrip(req)
+}
+
+func newAllowlistTestProxy(t *testing.T, allowed cschema.AllowedUpstreams, renderErr error) (iface.Proxy, *fakeAuth, *rejectionObservingTransport, *atomic.Int32, string) {
+	t.Helper()
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		hits.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(srv.Close)
+
+	transport := &rejectionObservingTransport{next: srv.Client().Transport}
+	conn := &allowlistConnection{
+		Connection: mockCore.Connection{Id: apid.New(apid.PrefixConnection), Namespace: "root/"},
+		allowed:    allowed,
+		err:        renderErr,
+	}
+	auth := &fakeAuth{headers: map[string]string{"Authorization": "Bearer secret"}}
+	p := New(&stubHttpf{client: &http.Client{Transport: transport}}, conn, auth, nil)
+	return p, auth, transport, &hits, srv.URL
+}
+
+func requireUpstreamNotAllowed(t *testing.T, err error) {
+	t.Helper()
+	require.Error(t, err)
+	var herr *httperr.Error
+	require.True(t, errors.As(err, &herr))
+	assert.Equal(t, http.StatusForbidden, herr.Status)
+}
+
+func TestProxyRequestRaw_AllowedUpstreams(t *testing.T) {
+	t.Run("disallowed host is rejected before Resolve", func(t *testing.T) {
+		p, auth, transport, hits, srvURL := newAllowlistTestProxy(t, cschema.AllowedUpstreams{{Hosts: []string{"api.example.com"}}}, nil)
+
+		err := p.ProxyRequestRaw(context.Background(), httpf.RequestTypeProxy, &iface.RawProxyRequest{Outbound: mustNewRawRequest(t, srvURL+"/x")}, newRecordingResponseWriter())
+		requireUpstreamNotAllowed(t, err)
+		assert.Equal(t, int32(0), atomic.LoadInt32(&auth.resolveN))
+		assert.Equal(t, int32(0), hits.Load())
+		assert.Equal(t, int32(1), transport.rejected.Load())
+		assert.Equal(t, app_metrics.ResponseSourceUpstreamNotAllowed, transport.source.Load())
+	})
+
+	t.Run("allowed host is proxied", func(t *testing.T) {
+		p, auth, transport, hits, srvURL := newAllowlistTestProxy(t, nil, nil)
+		u := mustNewRawRequest(t, srvURL).URL
+		p.(*proxy).conn.(*allowlistConnection).allowed = cschema.AllowedUpstreams{{Hosts: []string{u.Host}, Schemes: []string{"http"}}}
+
+		rec := newRecordingResponseWriter()
+		require.NoError(t, p.ProxyRequestRaw(context.Background(), httpf.RequestTypeProxy, &iface.RawProxyRequest{Outbound: mustNewRawRequest(t, srvURL+"/x")}, rec))
+		assert.Equal(t, http.StatusOK, rec.status())
+		assert.Equal(t, int32(1), atomic.LoadInt32(&auth.resolveN))
+		assert.Equal(t, int32(1), hits.Load())
+		assert.Equal(t, int32(0), transport.rejected.Load())
+	})
+
+	t.Run("empty allowlist is unrestricted", func(t *testing.T) {
+		p, _, _, hits, srvURL := newAllowlistTestProxy(t, nil, nil)
+		require.NoError(t, p.ProxyRequestRaw(context.Background(), httpf.RequestTypeProxy, &iface.RawProxyRequest{Outbound: mustNewRawRequest(t, srvURL)}, newRecordingResponseWriter()))
+		assert.Equal(t, int32(1), hits.Load())
+	})
+
+	t.Run("render failure fails closed", func(t *testing.T) {
+		p, auth, _, hits, srvURL := newAllowlistTestProxy(t, nil, errors.New("bad subdomain"))
+		err := p.ProxyRequestRaw(context.Background(), httpf.RequestTypeProxy, &iface.RawProxyRequest{Outbound: mustNewRawRequest(t, srvURL)}, newRecordingResponseWriter())
+		requireUpstreamNotAllowed(t, err)
+		assert.Equal(t, int32(0), atomic.LoadInt32(&auth.resolveN))
+		assert.Equal(t, int32(0), hits.Load())
+	})
+}
@@ -0,0 +1,19 @@
+package proxy
+
+import (
+	"github.com/rmorlok/authproxy/internal/httperr"
+	"github.com/rmorlok/authproxy/internal/httpf"
+)
+
+const egressDeniedMsg = "upstream address is not allowed by the egress policy"
+
+// egressError converts an egress policy denial from the httpf dialer into a
+// 403 for the caller. The denied address is deliberately not echoed back so
+// the response can't be used to map internal DNS. Other errors are returned
+// unchanged.
+func egressError(err error) error {
+	if httpf.IsEgressDenied(err) {
+		return httperr.Forbidden(egressDeniedMsg, httperr.WithInternalErr(err))
+	}
+	return err
+}
@@ -0,0 +1,353 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/rmorlok/authproxy/internal/apjs"
+	"github.com/rmorlok/authproxy/internal/core/iface"
+	"github.com/rmorlok/authproxy/internal/httperr"
+	"github.com/rmorlok/authproxy/internal/httpf"
+	cschema "github.com/rmorlok/authproxy/internal/schema/resources/connectors"
+)
+
+const (
+	// PagesHeader is set on a merged paginated response to the number of
+	// pages that were fetched.
+	PagesHeader = "X-AuthProxy-Pages"
+
+	// PaginationTruncatedHeader is set to "true" on a merged paginated
+	// response when a limit stopped pagination while the upstream still had
+	// more pages.
+	PaginationTruncatedHeader = "X-AuthProxy-Pagination-Truncated"
+)
+
+// PaginationRulesProvider is implemented by connections whose connector
+// declares how its list endpoints paginate. Requests to connections that
+// don't implement it can't be paginated.
+type PaginationRulesProvider interface {
+	GetPaginationRules() cschema.PaginationRules
+}
+
+func (p *proxy) paginationRule(u *url.URL) *cschema.PaginationRule {
+	if provider, ok := p.conn.(PaginationRulesProvider); ok {
+		return provider.GetPaginationRules().Match(u.Path)
+	}
+	return nil
+}
+
+// ProxyRequestPages sends req and then requests each following page, as
+// described by the connector's pagination rule for the URL's path, until
+// the upstream has no more pages or req.Paginate's limits are reached.
+// Every page goes through ProxyRequest, so it is authorized, rate limited,
+// retried and recorded as a request event on its own.
+//
+// A page the upstream answers with a status of 300 or above is passed to
+// onPage without items and ends pagination.
+func (p *proxy) ProxyRequestPages(ctx context.Context, reqType httpf.RequestType, req *iface.ProxyRequest, onPage iface.PageCallback) (*iface.PaginationResult, error) {
+	opts := req.Paginate
+	if opts == nil {
+		opts = &iface.ProxyPaginate{}
+	}
+
+	current, err := url.Parse(req.URL)
+	if err != nil {
+		return nil, httperr.BadRequest("invalid url", httperr.WithInternalErr(err))
+	}
+
+	rule := p.paginationRule(current)
+	if rule == nil {
+		return nil, httperr.BadRequestf("connector does not declare pagination for path %q", current.Path)
+	}
+
+	jsctx, err := p.conn.GetJavascriptContext(ctx)
+	if err != nil {
+		return nil, httperr.InternalServerError(httperr.WithInternalErrorf("failed to get javascript context: %w", err))
+	}
+
+	pg := &paginator{rule: rule, first: current, js: jsctx}
+	if rule.Strategy == cschema.PaginationStrategyOffset {
+		current = pg.startOffset(current)
+	}
+
+	pageReq := *req
+	pageReq.Paginate = nil
+	pageReq.URL = current.String()
+
+	result := &iface.PaginationResult{}
+	for {
+		resp, err := p.ProxyRequest(ctx, reqType, &pageReq)
+		if err != nil {
+			return result, err
+		}
+
+		result.Pages++
+		result.Bytes += responseSize(resp)
+		page := &iface.ProxyPage{Number: result.Pages, Response: resp}
+
+		if resp.StatusCode >= http.StatusMultipleChoices {
+			return result, onPage(page)
+		}
+
+		items, err := pg.items(resp)
+		if err != nil {
+			return result, err
+		}
+		page.Items = items
+		result.Items += len(items)
+
+		if err := onPage(page); err != nil {
+			return result, err
+		}
+
+		next, err := pg.next(current, resp, items)
+		if err != nil {
+			return result, err
+		}
+		if next == nil {
+			return result, nil
+		}
+
+		if result.Pages >= opts.GetMaxPages() || result.Bytes >= opts.GetMaxBytes() {
+			result.Truncated = true
+			return result, nil
+		}
+
+		current = next
+		pageReq.URL = current.String()
+	}
+}
+
+// proxyRequestMerged follows the pages of req and returns one response whose
+// body is the items of every page in order. Headers are those of the first
+// page, less Link, with PagesHeader and PaginationTruncatedHeader added. If
+// a page fails, that page's response is returned instead.
+func (p *proxy) proxyRequestMerged(ctx context.Context, reqType httpf.RequestType, req *iface.ProxyRequest) (*iface.ProxyResponse, error) {
+	var first, failed *iface.ProxyResponse
+	merged := []any{}
+
+	result, err := p.ProxyRequestPages(ctx, reqType, req, func(page *iface.ProxyPage) error {
+		if page.Items == nil && page.Response.StatusCode >= http.StatusMultipleChoices {
+			failed = page.Response
+			return nil
+		}
+		if first == nil {
+			first = page.Response
+		}
+		merged = append(merged, page.Items...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if failed != nil {
+		return failed, nil
+	}
+
+	headers := maps.Clone(first.Headers)
+	if headers == nil {
+		headers = map[string]string{}
+	}
+	delete(headers, "Link")
+	delete(headers, "Content-Length")
+	headers[PagesHeader] = strconv.Itoa(result.Pages)
+	if result.Truncated {
+		headers[PaginationTruncatedHeader] = "true"
+	}
+
+	return &iface.ProxyResponse{
+		StatusCode: first.StatusCode,
+		Headers:    headers,
+		BodyJson:   merged,
+	}, nil
+}
+
+// paginator applies a pagination rule to successive pages of one request.
+type paginator struct {
+	rule  *cschema.PaginationRule
+	first *url.URL
+	js    apjs.Context
+
+	lastCursor string
+	offset     int
+	limit      int
+}
+
+func (pg *paginator) jsContext(resp *iface.ProxyResponse) apjs.Context {
+	headers := make(map[string]string, len(resp.Headers))
+	for k, v := range resp.Headers {
+		headers[strings.ToLower(k)] = v
+	}
+	return pg.js.WithVar("body", resp.BodyJson).WithVar("headers", headers)
+}
+
+// items returns the items on a successful page.
+func (pg *paginator) items(resp *iface.ProxyResponse) ([]any, error) {
+	if resp.BodyJson == nil {
+		if len(resp.BodyRaw) > 0 {
+			return nil, httperr.New(http.StatusBadGateway, "paginated upstream response is not JSON")
+		}
+		return []any{}, nil
+	}
+
+	value := resp.BodyJson
+	if pg.rule.Items != "" {
+		var err error
+		value, err = pg.jsContext(resp).Evaluate(pg.rule.Items)
+		if err != nil {
+			return nil, httperr.New(http.StatusBadGateway, "failed to read items from paginated upstream response", httperr.WithInternalErr(err))
+		}
+		if value == nil {
+			return []any{}, nil
+		}
+	}
+
+	items, ok := value.([]any)
+	if !ok {
+		return nil, httperr.New(http.StatusBadGateway, fmt.Sprintf("paginated upstream response items must be an array, got %T", value))
+	}
+	return items, nil
+}
+
+// next returns the URL of the page after current, or nil if there is none.
+func (pg *paginator) next(current *url.URL, resp *iface.ProxyResponse, items []any) (*url.URL, error) {
+	switch pg.rule.Strategy {
+	case cschema.PaginationStrategyLink:
+		ref := nextLink(resp.Headers["Link"])
+		if ref == "" {
+			return nil, nil
+		}
+		u, err := current.Parse(ref)
+		if err != nil {
+			return nil, httperr.New(http.StatusBadGateway, "invalid next page link", httperr.WithInternalErr(err))
+		}
+		// Credentials are applied to every page, so never follow a link
+		// off the host the caller asked for.
+		if !strings.EqualFold(u.Scheme, pg.first.Scheme) || !strings.EqualFold(u.Host, pg.first.Host) {
+			return nil, httperr.New(http.StatusBadGateway, "next page link points to a different host")
+		}
+		return u, nil
+
+	case cschema.PaginationStrategyCursor, cschema.PaginationStrategyPageToken:
+		value, err := pg.jsContext(resp).Evaluate(pg.rule.GetNext())
+		if err != nil {
+			return nil, httperr.New(http.StatusBadGateway, "failed to read next cursor from paginated upstream response", httperr.WithInternalErr(err))
+		}
+		cursor := cursorString(value)
+		if cursor == "" || cursor == pg.lastCursor {
+			return nil, nil
+		}
+		pg.lastCursor = cursor
+		return withQueryParam(current, pg.rule.GetParam(), cursor), nil
+
+	case cschema.PaginationStrategyOffset:
+		if len(items) == 0 || len(items) < pg.limit {
+			return nil, nil
+		}
+		pg.offset += len(items)
+		return withQueryParam(current, pg.rule.GetOffsetParam(), strconv.Itoa(pg.offset)), nil
+	}
+
+	return nil, nil
+}
+
+// startOffset reads the offset and limit the request starts from, adding the
+// rule's page size when the request doesn't set a limit.
+func (pg *paginator) startOffset(u *url.URL) *url.URL {
+	q := u.Query()
+	pg.offset, _ = strconv.Atoi(q.Get(pg.rule.GetOffsetParam()))
+
+	if limit, err := strconv.Atoi(q.Get(pg.rule.GetLimitParam())); err == nil && limit > 0 {
+		pg.limit = limit
+		return u
+	}
+
+	pg.limit = pg.rule.GetPageSize()
+	return withQueryParam(u, pg.rule.GetLimitParam(), strconv.Itoa(pg.limit))
+}
+
+func withQueryParam(u *url.URL, name, value string) *url.URL {
+	out := *u
+	q := out.Query()
+	q.Set(name, value)
+	out.RawQuery = q.Encode()
+	return &out
+}
+
+func cursorString(v any) string {
+	switch c := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return c
+	case float64:
+		return strconv.FormatFloat(c, 'f', -1, 64)
+	default:
+		return fmt.Sprint(c)
+	}
+}
+
+// nextLink returns the URI-reference of the rel="next" link-value in an RFC
+// 8288 Link header, or "" if there is none.
+func nextLink(header string) string {
+	for header != "" {
+		start := strings.IndexByte(header, '<')
+		if start < 0 {
+			return ""
+		}
+		end := strings.IndexByte(header[start:], '>')
+		if end < 0 {
+			return ""
+		}
+		ref := header[start+1 : start+end]
+		header = header[start+end+1:]
+
+		// The link's parameters run to the next link-value, which starts at
+		// a comma outside quotes.
+		params := header
+		inQuotes := false
+		for i := 0; i < len(header); i++ {
+			if header[i] == '"' {
+				inQuotes = !inQuotes
+			} else if header[i] == ',' && !inQuotes {
+				params, header = header[:i], header[i+1:]
+				break
+			}
+		}
+		if params == header {
+			header = ""
+		}
+
+		for _, param := range strings.Split(params, ";") {
+			name, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+			if !ok || !strings.EqualFold(strings.TrimSpace(name), "rel") {
+				continue
+			}
+			for _, rel := range strings.Fields(strings.Trim(strings.TrimSpace(value), `"`)) {
+				if strings.EqualFold(rel, "next") {
+					return strings.TrimSpace(ref)
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// responseSize is the size of a page's body, from its Content-Length when
+// the upstream sent one.
+func responseSize(resp *iface.ProxyResponse) int64 {
+	if n, err := strconv.ParseInt(resp.Headers["Content-Length"], 10, 64); err == nil && n >= 0 {
+		return n
+	}
+	if resp.BodyJson != nil {
+		data, _ := json.Marshal(resp.BodyJson)
+		return int64(len(data))
+	}
+	return int64(len(resp.BodyRaw))
+}
@@ -0,0 +1,254 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/rmorlok/authproxy/internal/apid"
+	"github.com/rmorlok/authproxy/internal/core/iface"
+	mockCore "github.com/rmorlok/authproxy/internal/core/mock"
+	"github.com/rmorlok/authproxy/internal/httperr"
+	"github.com/rmorlok/authproxy/internal/httpf"
+	cschema "github.com/rmorlok/authproxy/internal/schema/resources/connectors"
+	"github.com/rmorlok/authproxy/internal/schema/resources/rate_limit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type connectionWithPaginationRules struct {
+	*mockCore.Connection
+	rules cschema.PaginationRules
+}
+
+func (c *connectionWithPaginationRules) GetPaginationRules() cschema.PaginationRules { return c.rules }
+
+func newPaginateTestProxy(t *testing.T, h http.Handler, rules cschema.PaginationRules) (iface.Proxy, *attemptRecorder, string) {
+	t.Helper()
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+
+	recorder := &attemptRecorder{transport: srv.Client().Transport}
+	conn := &connectionWithPaginationRules{
+		Connection: &mockCore.Connection{
+			Id:        apid.New(apid.PrefixConnection),
+			Namespace: "root/",
+		},
+		rules: rules,
+	}
+	f := &recorderHttpf{stubHttpf: stubHttpf{client: &http.Client{Transport: recorder}}, recorder: recorder}
+	return New(f, conn, &fakeAuth{}, nil), recorder, srv.URL
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// numbered returns items n through m-1 as {"id": i} objects.
+func numbered(n, m int) []any {
+	items := []any{}
+	for i := n; i < m; i++ {
+		items = append(items, map[string]any{"id": float64(i)})
+	}
+	return items
+}
+
+func TestProxyRequest_PaginateLink(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if page == 0 {
+			page = 1
+		}
+		if page < 3 {
+			w.Header().Set("Link", fmt.Sprintf(`<%s?page=%d>; rel="next", <%s?page=1>; rel="first"`, r.URL.Path, page+1, r.URL.Path))
+		}
+		writeJSON(w, numbered((page-1)*2, page*2))
+	})
+	p, recorder, target := newPaginateTestProxy(t, h, cschema.PaginationRules{{Strategy: cschema.PaginationStrategyLink}})
+
+	resp, err := p.ProxyRequest(context.Background(), httpf.RequestTypeProxy, &iface.ProxyRequest{
+		Method:   http.MethodGet,
+		URL:      target + "/v1/users",
+		Paginate: &iface.ProxyPaginate{},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, numbered(0, 6), resp.BodyJson)
+	assert.Equal(t, "3", resp.Headers[PagesHeader])
+	assert.Empty(t, resp.Headers[PaginationTruncatedHeader])
+	assert.Empty(t, resp.Headers["Link"])
+
+	// Every page is its own request event.
+	require.Len(t, recorder.attrs, 3)
+	assert.NotEqual(t, recorder.attrs[0].RequestId, recorder.attrs[1].RequestId)
+}
+
+func TestProxyRequest_PaginateCursor(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "open", r.URL.Query().Get("status"))
+		switch r.URL.Query().Get("cursor") {
+		case "":
+			writeJSON(w, map[string]any{"data": numbered(0, 2), "meta": map[string]any{"next": "c2"}})
+		case "c2":
+			writeJSON(w, map[string]any{"data": numbered(2, 3), "meta": map[string]any{"next": nil}})
+		default:
+			t.Errorf("unexpected cursor %q", r.URL.Query().Get("cursor"))
+		}
+	})
+	p, recorder, target := newPaginateTestProxy(t, h, cschema.PaginationRules{{
+		PathMatch: &rate_limit.PathMatch{Kind: rate_limit.PathMatchKindPrefix, Value: "/v1/tickets"},
+		Strategy:  cschema.PaginationStrategyCursor,
+		Items:     "body.data",
+		Next:      "body.meta.next",
+		Param:     "cursor",
+	}})
+
+	var pages []*iface.ProxyPage
+	result, err := p.ProxyRequestPages(context.Background(), httpf.RequestTypeProxy, &iface.ProxyRequest{
+		Method: http.MethodGet,
+		URL:    target + "/v1/tickets?status=open",
+	}, func(page *iface.ProxyPage) error {
+		pages = append(pages, page)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Len(t, pages, 2)
+	assert.Equal(t, 1, pages[0].Number)
+	assert.Equal(t, numbered(0, 2), pages[0].Items)
+	assert.Equal(t, numbered(2, 3), pages[1].Items)
+	assert.Equal(t, 2, result.Pages)
+	assert.Equal(t, 3, result.Items)
+	assert.Positive(t, result.Bytes)
+	assert.False(t, result.Truncated)
+	assert.Len(t, recorder.attrs, 2)
+}
+
+func TestProxyRequest_PaginateOffset(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		offset, _ := strconv.Atoi(r.URL.Query().Get("skip"))
+		assert.Equal(t, "2", r.URL.Query().Get("limit"))
+		writeJSON(w, numbered(offset, min(offset+2, 5)))
+	})
+	p, _, target := newPaginateTestProxy(t, h, cschema.PaginationRules{{
+		Strategy:    cschema.PaginationStrategyOffset,
+		OffsetParam: "skip",
+		PageSize:    2,
+	}})
+
+	resp, err := p.ProxyRequest(context.Background(), httpf.RequestTypeProxy, &iface.ProxyRequest{
+		Method:   http.MethodGet,
+		URL:      target + "/v1/items",
+		Paginate: &iface.ProxyPaginate{},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, numbered(0, 5), resp.BodyJson)
+	assert.Equal(t, "3", resp.Headers[PagesHeader])
+}
+
+func TestProxyRequest_PaginateTruncatesAtMaxPages(t *testing.T) {
+	var calls int
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		writeJSON(w, map[string]any{"items": numbered(calls, calls+1), "nextPageToken": fmt.Sprintf("t%d", calls)})
+	})
+	p, _, target := newPaginateTestProxy(t, h, cschema.PaginationRules{{
+		Strategy: cschema.PaginationStrategyPageToken,
+		Items:    "body.items",
+	}})
+
+	resp, err := p.ProxyRequest(context.Background(), httpf.RequestTypeProxy, &iface.ProxyRequest{
+		Method:   http.MethodGet,
+		URL:      target + "/v1/files",
+		Paginate: &iface.ProxyPaginate{MaxPages: 2},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, calls)
+	assert.Equal(t, numbered(1, 3), resp.BodyJson)
+	assert.Equal(t, "2", resp.Headers[PagesHeader])
+	assert.Equal(t, "true", resp.Headers[PaginationTruncatedHeader])
+}
+
+func TestProxyRequest_PaginateStopsOnErrorPage(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") == "2" {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"error":"nope"}`))
+			return
+		}
+		w.Header().Set("Link", `</v1/users?page=2>; rel="next"`)
+		writeJSON(w, numbered(0, 2))
+	})
+	p, _, target := newPaginateTestProxy(t, h, cschema.PaginationRules{{Strategy: cschema.PaginationStrategyLink}})
+
+	resp, err := p.ProxyRequest(context.Background(), httpf.RequestTypeProxy, &iface.ProxyRequest{
+		Method:   http.MethodGet,
+		URL:      target + "/v1/users",
+		Paginate: &iface.ProxyPaginate{},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	assert.Equal(t, map[string]any{"error": "nope"}, resp.BodyJson)
+}
+
+func TestProxyRequest_PaginateRejectsLinkToOtherHost(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Link", `<https://evil.example.com/v1/users?page=2>; rel="next"`)
+		writeJSON(w, numbered(0, 2))
+	})
+	p, recorder, target := newPaginateTestProxy(t, h, cschema.PaginationRules{{Strategy: cschema.PaginationStrategyLink}})
+
+	_, err := p.ProxyRequest(context.Background(), httpf.RequestTypeProxy, &iface.ProxyRequest{
+		Method:   http.MethodGet,
+		URL:      target + "/v1/users",
+		Paginate: &iface.ProxyPaginate{},
+	})
+	require.Error(t, err)
+	assert.Equal(t, http.StatusBadGateway, httperr.FromError(err).Status)
+	assert.Len(t, recorder.attrs, 1)
+}
+
+func TestProxyRequest_PaginateWithoutRule(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("no request should be made")
+	})
+	p, _, target := newPaginateTestProxy(t, h, cschema.PaginationRules{{
+		PathMatch: &rate_limit.PathMatch{Kind: rate_limit.PathMatchKindPrefix, Value: "/v1/tickets"},
+		Strategy:  cschema.PaginationStrategyLink,
+	}})
+
+	_, err := p.ProxyRequest(context.Background(), httpf.RequestTypeProxy, &iface.ProxyRequest{
+		Method:   http.MethodGet,
+		URL:      target + "/v1/users",
+		Paginate: &iface.ProxyPaginate{},
+	})
+	require.Error(t, err)
+	assert.Equal(t, http.StatusBadRequest, httperr.FromError(err).Status)
+}
+
+func TestNextLink(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		want   string
+	}{
+		{name: "empty", header: "", want: ""},
+		{name: "only next", header: `<https://api.example.com/items?page=2>; rel="next"`, want: "https://api.example.com/items?page=2"},
+		{name: "next after others", header: `<https://api.example.com/items?page=1>; rel="prev", <https://api.example.com/items?page=3>; rel="next"`, want: "https://api.example.com/items?page=3"},
+		{name: "unquoted rel", header: `</items?page=2>; rel=next`, want: "/items?page=2"},
+		{name: "multiple rels", header: `</items?page=2>; rel="next last"`, want: "/items?page=2"},
+		{name: "comma in quoted param", header: `</a>; title="a, b"; rel="prev", </b>; rel="next"`, want: "/b"},
+		{name: "no next", header: `</items?page=1>; rel="first"`, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, nextLink(tt.header))
+		})
+	}
+}
@@ -1,8 +1,11 @@
 // Package proxy orchestrates a single proxied request through a connection:
-// resolve credentials via the auth method's Authenticator, send the request
+// check the URL against the connector's allowed upstreams, resolve
+// credentials via the auth method's Authenticator (and sign the final
+// request for auth methods that implement RequestSigner), send the request
 // through the httpf client (which carries rate-limit / telemetry /
 // request-events middleware), and on a 401 from the upstream attempt the
-// retry-once-after-recover dance. Owns both the wrapped (structured)
+// retry-once-after-recover dance, plus any retries of transient failures
+// the connector's retry policy asks for. Owns both the wrapped (structured)
 // ProxyRequest and the streaming ProxyRequestRaw paths so the
 // per-auth-method packages only have to describe "how to apply this
 // credential to a request" — not how to drive a proxy call.
@@ -14,9 +17,12 @@ import (
 	"io"
 	"log/slog"
 	"net/http"
+	"net/url"
 	"strconv"
 
 	apauthcore "github.com/rmorlok/authproxy/internal/apauth/core"
+	"github.com/rmorlok/authproxy/internal/apctx"
+	"github.com/rmorlok/authproxy/internal/apgraphql"
 	"github.com/rmorlok/authproxy/internal/apid"
 	"github.com/rmorlok/authproxy/internal/auth_methods"
 	"github.com/rmorlok/authproxy/internal/core/iface"
@@ -64,6 +70,15 @@ func proxyLogger(conn iface.Connection) *slog.Logger {
 	return slog.Default()
 }
 
+// contextWithPriority records the request's priority class so rate limits
+// and request events see it. Untagged requests leave ctx as it is.
+func contextWithPriority(ctx context.Context, priority common.RequestPriority) context.Context {
+	if priority == "" {
+		return ctx
+	}
+	return apctx.WithRequestPriority(ctx, priority)
+}
+
 // maybeAccelerateProbes fires a best-effort probe-now enqueue when the
 // upstream returns a credential-related status code on a user-initiated
 // request. Probe traffic itself is excluded — without that gate the
@@ -87,7 +102,8 @@ func (p *proxy) maybeAccelerateProbes(ctx context.Context, reqType httpf.Request
 	_ = p.accelerator.EnqueueProbeNow(ctx, p.conn.GetId())
 }
 
-// ProxyRequest resolves credentials, sends the request, and on a 401
+// ProxyRequest checks the URL against the connector's allowed upstreams,
+// resolves credentials, sends the request, and on a 401
 // from the upstream attempts to recover (e.g. refresh an OAuth2 token)
 // and replay the request exactly once.
 //
@@ -97,27 +113,99 @@ func (p *proxy) maybeAccelerateProbes(ctx context.Context, reqType httpf.Request
 // customer's app sees the same auth failure it would have without this
 // retry path, and the recovery failure was already classified inside
 // the authenticator.
+//
+// When the connector has a retry policy and the request may be retried
+// (see RetryPolicy.AllowsMethod), transient failures — the policy's
+// status codes, short Retry-After 429s and network errors — are retried
+// with backoff up to the policy's max attempts. Every attempt is recorded
+// as its own request event, linked to the first; see attempts.
+//
+// The connector's request transforms matching the URL's path rewrite req
+// before the first attempt, and its response transforms rewrite the final
+// response and the bodies recorded in the full request log; see transforms.
+//
+// A request with Paginate set follows the upstream's pages and returns them
+// merged into a single response; see ProxyRequestPages.
 func (p *proxy) ProxyRequest(ctx context.Context, reqType httpf.RequestType, req *iface.ProxyRequest) (*iface.ProxyResponse, error) {
-	resp, err := p.send(ctx, reqType, req)
+	ctx = contextWithPriority(ctx, req.Priority)
+
+	if req.Paginate != nil {
+		return p.proxyRequestMerged(ctx, reqType, req)
+	}
+
+	target, _ := url.Parse(req.URL)
+	if !p.checkUpstreamAllowed(ctx, reqType, target) {
+		p.recordRejected(ctx, reqType, req)
+		return nil, errUpstreamNotAllowed()
+	}
+
+	tr, err := p.transformsFor(ctx, req.Method, target)
 	if err != nil {
 		return nil, err
 	}
+	if req, err = tr.applyRequest(req); err != nil {
+		return nil, err
+	}
 
-	if resp.StatusCode == http.StatusUnauthorized {
-		recoverErr := p.auth.RecoverFrom401(ctx)
-		if recoverErr == nil {
-			p.logUpstreamRetryAttempted(ctx, reqType, resp.StatusCode)
-			retried, retryErr := p.send(ctx, reqType, req)
-			if retryErr == nil {
-				resp = retried
+	// The body is buffered here, so GraphQL requests can be identified by
+	// operation for rate limiting and request events.
+	ctx = apgraphql.ContextWithOperation(ctx, req.GraphqlOperation())
+
+	policy := p.retryPolicy()
+	retryable := policy.AllowsMethod(req.Method, proxyRequestHeader(req))
+	linked := &attempts{}
+	if tr != nil && len(tr.response) > 0 {
+		linked.transformLogged = tr.loggedResponse(req)
+	}
+	recovered := false
+
+	var resp *gentleman.Response
+	for retry := 0; ; retry++ {
+		resp, err = p.send(linked.next(ctx), reqType, req)
+
+		if err == nil && resp.StatusCode == http.StatusUnauthorized && !recovered {
+			recovered = true
+			recoverErr := p.auth.RecoverFrom401(ctx)
+			if recoverErr == nil {
+				p.logUpstreamRetryAttempted(ctx, reqType, resp.StatusCode, retryReasonUpstream401)
+				retried, retryErr := p.send(linked.next(ctx), reqType, req)
+				if retryErr == nil {
+					resp = retried
+				}
+			} else if !errors.Is(recoverErr, auth_methods.ErrCannotRecover) {
+				// Refresh failed for a recoverable auth method. Surface the
+				// original 401; the recovery failure already self-reported.
+				_ = recoverErr
 			}
-		} else if !errors.Is(recoverErr, auth_methods.ErrCannotRecover) {
-			// Refresh failed for a recoverable auth method. Surface the
-			// original 401; the recovery failure already self-reported.
-			_ = recoverErr
+		}
+
+		if !retryable || retry+1 >= policy.GetMaxAttempts() {
+			break
+		}
+
+		var statusCode int
+		var header http.Header
+		if resp != nil {
+			statusCode, header = resp.StatusCode, resp.Header
+		}
+		delay, ok := retryDelay(ctx, policy, retry, statusCode, header, err)
+		if !ok {
+			break
+		}
+
+		p.logUpstreamRetryAttempted(ctx, reqType, statusCode, retryReason(err))
+		if resp != nil {
+			_ = resp.Close()
+		}
+		if !waitToRetry(ctx, delay) {
+			return nil, ctx.Err()
 		}
 	}
 
+	if err != nil {
+		return nil, egressError(err)
+	}
+
 	// After the recover-and-retry dance has run its course, the final
 	// status code is what the customer will see. A persistent 401/403
 	// here means credentials are genuinely failing — accelerate probes
@@ -126,7 +214,14 @@ func (p *proxy) ProxyRequest(ctx context.Context, reqType httpf.RequestType, req
 	p.maybeAccelerateProbes(ctx, reqType, resp.StatusCode)
 	p.logFinalUpstreamStatus(ctx, reqType, resp)
 
-	return iface.ProxyResponseFromGentlemen(resp)
+	proxyResp, err := iface.ProxyResponseFromGentlemen(resp)
+	if err != nil {
+		return nil, err
+	}
+	if err := tr.applyResponse(req, proxyResp); err != nil {
+		return nil, err
+	}
+	return proxyResp, nil
 }
 
 // ProxyRequestRaw is the streaming raw-proxy path: the caller's inbound
@@ -134,17 +229,27 @@ func (p *proxy) ProxyRequest(ctx context.Context, reqType httpf.RequestType, req
 // response streams back into w with flushing after each successful read,
 // and trailers are passed through.
 //
-// On a 401 we may attempt a single retry, but only if the inbound body
-// has not yet been consumed (Resolve succeeded but the body reader is
-// still at position zero). For streaming inbound bodies — the common
-// case here — once any bytes have been sent the 401 is surfaced to the
-// caller. Real SSE / LLM / S3 callers typically send POST bodies from
-// buffered or seekable sources, so a more sophisticated rewind path can
-// be added later if the streaming-body 401-retry case actually bites.
+// On a 401 we may attempt a single retry, and the connector's retry
+// policy may retry transient failures as in ProxyRequest, but only when
+// the outbound body can be replayed: no body, or a GetBody to re-read it
+// (see canReplayRaw). For streaming inbound bodies — the common case
+// here — once any bytes have been sent the upstream response is surfaced
+// to the caller.
+//
+// When the route handler preserved an Upgrade request (e.g. a WebSocket
+// handshake) and the upstream answers 101, the caller's connection is
+// hijacked and bytes are pumped both ways; see proxyUpgrade. The handshake
+// goes through the same credential application and httpf chain as any
+// other request, so rate limits are enforced when the session connects.
+//
+// When req.ResponseUrlRewrite is set, Location and Link response headers
+// that point under the upstream base are rewritten to the caller-facing
+// route before the response is streamed; see rewriteResponseUrls.
 func (p *proxy) ProxyRequestRaw(ctx context.Context, reqType httpf.RequestType, req *iface.RawProxyRequest, w http.ResponseWriter) error {
 	if req == nil || req.Outbound == nil {
 		return errors.New("raw proxy request requires an outbound *http.Request")
 	}
+	ctx = contextWithPriority(ctx, req.Priority)
 
 	client := p.httpf.
 		ForRequestType(reqType).
@@ -153,46 +258,113 @@ func (p *proxy) ProxyRequestRaw(ctx context.Context, reqType httpf.RequestType,
 		ForLabels(req.Labels).
 		NewHTTPClient()
 
-	// Snapshot of the inbound body so we can issue a single retry if the
-	// upstream rejects with 401 *before* any inbound bytes were forwarded.
-	// Once forwarding starts the body's read position is unrecoverable;
-	// see the function comment for the rationale.
+	// Whether the body can be replayed has to be decided before the first
+	// attempt starts reading it.
 	outbound := req.Outbound.WithContext(ctx)
-	originalBody := outbound.Body
+	replayable := canReplayRaw(req.Outbound)
 
-	resp, err := p.sendRaw(ctx, client, outbound)
-	if err != nil {
-		return err
+	if !p.checkUpstreamAllowed(ctx, reqType, outbound.URL) {
+		p.recordRejectedRaw(ctx, client, outbound)
+		return errUpstreamNotAllowed()
 	}
 
-	if resp.StatusCode == http.StatusUnauthorized && canRetryRawAfter401(originalBody) {
-		recoverErr := p.auth.RecoverFrom401(ctx)
-		if recoverErr == nil {
-			p.logUpstreamRetryAttempted(ctx, reqType, resp.StatusCode)
-			drainAndClose(resp.Body)
-			retried, retryErr := p.sendRaw(ctx, client, req.Outbound.WithContext(ctx))
-			if retryErr == nil {
-				resp = retried
+	policy := p.retryPolicy()
+	retryable := replayable && policy.AllowsMethod(outbound.Method, outbound.Header)
+	linked := &attempts{}
+	recovered := false
+
+	var resp *http.Response
+	var err error
+	for retry := 0; ; retry++ {
+		attemptCtx := linked.next(ctx)
+		attempt := outbound.WithContext(attemptCtx)
+		if retry > 0 {
+			if attempt, err = replayRaw(attemptCtx, req.Outbound); err != nil {
+				return err
 			}
-		} else if !errors.Is(recoverErr, auth_methods.ErrCannotRecover) {
-			_ = recoverErr
+		}
+		resp, err = p.sendRaw(ctx, client, attempt)
+
+		if err == nil && resp.StatusCode == http.StatusUnauthorized && replayable && !recovered {
+			recovered = true
+			recoverErr := p.auth.RecoverFrom401(ctx)
+			if recoverErr == nil {
+				p.logUpstreamRetryAttempted(ctx, reqType, resp.StatusCode, retryReasonUpstream401)
+				replay, replayErr := replayRaw(linked.next(ctx), req.Outbound)
+				if replayErr == nil {
+					drainAndClose(resp.Body)
+					retried, retryErr := p.sendRaw(ctx, client, replay)
+					if retryErr == nil {
+						resp = retried
+					}
+				}
+			} else if !errors.Is(recoverErr, auth_methods.ErrCannotRecover) {
+				_ = recoverErr
+			}
+		}
+
+		if !retryable || retry+1 >= policy.GetMaxAttempts() {
+			break
+		}
+
+		var statusCode int
+		var header http.Header
+		if resp != nil {
+			statusCode, header = resp.StatusCode, resp.Header
+		}
+		delay, ok := retryDelay(ctx, policy, retry, statusCode, header, err)
+		if !ok {
+			break
+		}
+
+		p.logUpstreamRetryAttempted(ctx, reqType, statusCode, retryReason(err))
+		if resp != nil {
+			drainAndClose(resp.Body)
+		}
+		if !waitToRetry(ctx, delay) {
+			return ctx.Err()
 		}
 	}
 
+	if err != nil {
+		return egressError(err)
+	}
+
 	// Same 401/403 acceleration as the wrapped path. See ProxyRequest's
 	// comment for the rationale.
 	p.maybeAccelerateProbes(ctx, reqType, resp.StatusCode)
 	p.logFinalRawUpstreamStatus(ctx, reqType, resp)
 
+	rewriteResponseUrls(resp.Header, outbound.URL, req.ResponseUrlRewrite)
+
+	if requested := upgradeType(req.Outbound.Header); requested != "" && resp.StatusCode == http.StatusSwitchingProtocols {
+		return p.proxyUpgrade(ctx, reqType, requested, w, resp)
+	}
+
 	return streamResponse(w, resp)
 }
 
-func (p *proxy) logUpstreamRetryAttempted(ctx context.Context, reqType httpf.RequestType, statusCode int) {
+const (
+	retryReasonUpstream401    = "upstream_401"
+	retryReasonUpstreamStatus = "upstream_status"
+	retryReasonNetworkError   = "network_error"
+)
+
+// retryReason classifies a retry under the connector's retry policy for
+// logging: a failure without a response, or a retryable status.
+func retryReason(err error) string {
+	if err != nil {
+		return retryReasonNetworkError
+	}
+	return retryReasonUpstreamStatus
+}
+
+func (p *proxy) logUpstreamRetryAttempted(ctx context.Context, reqType httpf.RequestType, statusCode int, reason string) {
 	p.logger.InfoContext(ctx, "proxy upstream retry attempted",
 		"connection_id", p.conn.GetId().String(),
 		"request_type", reqType.String(),
 		"provider_status_code", statusCode,
-		"reason", "upstream_401",
+		"reason", reason,
 	)
 }
 
@@ -234,26 +406,6 @@ func (p *proxy) logFinalStatus(ctx context.Context, reqType httpf.RequestType, s
 	}
 }
 
-// canRetryRawAfter401 reports whether the inbound body is in a state
-// where a retry could replay it. With no body (GET/HEAD/DELETE) retry is
-// safe. With a body that's already been consumed we cannot rewind, so we
-// surface the 401. http.NoBody is the sentinel net/http uses for the
-// "no body" case after the request is built; nil also occurs in
-// constructed requests.
-func canRetryRawAfter401(body io.ReadCloser) bool {
-	if body == nil {
-		return true
-	}
-	if body == http.NoBody {
-		return true
-	}
-	// Conservative default: assume the body has been (or is being)
-	// consumed and the retry would not see the same bytes. Real callers
-	// that need streaming-body 401-retry will need a rewindable body
-	// abstraction — a future PR.
-	return false
-}
-
 // sendRaw applies the credentials to the outbound request and sends it
 // via the supplied client. Split out so the retry-after-401 path can
 // build a new request with a fresh body and reuse the same client.
@@ -277,6 +429,12 @@ func (p *proxy) sendRaw(ctx context.Context, client *http.Client, outbound *http
 		outbound.URL.RawQuery = q.Encode()
 	}
 
+	if signer, ok := p.auth.(auth_methods.RequestSigner); ok {
+		if err := signer.SignRequest(ctx, outbound, rawSigningPayload(outbound)); err != nil {
+			return nil, err
+		}
+	}
+
 	return client.Do(outbound)
 }
 
@@ -456,5 +614,8 @@ func (p *proxy) send(ctx context.Context, reqType httpf.RequestType, req *iface.
 	for k, v := range app.QueryParams {
 		r.SetQuery(k, v)
 	}
+	if signer, ok := p.auth.(auth_methods.RequestSigner); ok {
+		r.UseHandler("before dial", signingHandler(ctx, signer))
+	}
 	return r.Do()
 }
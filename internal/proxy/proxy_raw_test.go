@@ -27,6 +27,7 @@ import (
 // allowed exactly maxRecover times before returning ErrCannotRecover.
 type fakeAuth struct {
 	headers     map[string]string
+	query       map[string]string
 	maxRecover  int
 	resolveN    int32
 	recoverN    int32
@@ -42,7 +43,7 @@ func (a *fakeAuth) Resolve(ctx context.Context) (auth_methods.AuthApplication, e
 	for k, v := range a.headers {
 		hs[k] = v
 	}
-	return auth_methods.AuthApplication{Headers: hs}, nil
+	return auth_methods.AuthApplication{Headers: hs, QueryParams: a.query}, nil
 }
 
 func (a *fakeAuth) RecoverFrom401(ctx context.Context) error {
@@ -0,0 +1,201 @@
+package proxy
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+
+	"github.com/rmorlok/authproxy/internal/apctx"
+	"github.com/rmorlok/authproxy/internal/apid"
+	"github.com/rmorlok/authproxy/internal/app_metrics"
+	"github.com/rmorlok/authproxy/internal/core/iface"
+	"github.com/rmorlok/authproxy/internal/httpf"
+	"github.com/rmorlok/authproxy/internal/ratelimit"
+	cschema "github.com/rmorlok/authproxy/internal/schema/resources/connectors"
+)
+
+// RetryPolicyProvider is implemented by connections whose connector
+// configures retries of transient upstream failures. Connections that don't
+// implement it, or return nil, are not retried beyond the replay after a
+// 401.
+type RetryPolicyProvider interface {
+	GetRetryPolicy() *cschema.RetryPolicy
+}
+
+func (p *proxy) retryPolicy() *cschema.RetryPolicy {
+	if provider, ok := p.conn.(RetryPolicyProvider); ok {
+		return provider.GetRetryPolicy()
+	}
+	return nil
+}
+
+// attempts links the request events of every attempt of one proxied call.
+// Each attempt gets its own Attribution so the request-events round-tripper
+// can stamp the attempt number and, after the first, the id of the first
+// attempt's event.
+type attempts struct {
+	n       int
+	firstId apid.ID
+	last    *app_metrics.Attribution
+
+	// transformLogged is copied onto each attempt's Attribution; see
+	// Attribution.TransformResponseBody.
+	transformLogged func(statusCode int, header http.Header, body []byte) []byte
+}
+
+// next returns ctx carrying the attribution for the next attempt.
+func (a *attempts) next(ctx context.Context) context.Context {
+	if a.last != nil && a.firstId.IsNil() {
+		a.firstId = a.last.RequestId
+	}
+	a.n++
+	a.last = &app_metrics.Attribution{Attempt: a.n, RetryOf: a.firstId, TransformResponseBody: a.transformLogged}
+	return app_metrics.ContextWithAttribution(ctx, a.last)
+}
+
+// retryDelay decides whether an attempt that ended with statusCode/header
+// (or with err, when no response arrived) should be retried under policy,
+// and how long to wait first. retry is the zero-based index of the retry
+// being considered.
+//
+// A Retry-After on the response is honoured when it is no longer than the
+// policy's MaxRetryAfter; a longer one means the upstream wants the caller
+// to back off, so the response is returned instead. A 429 is retried only
+// when it carries such a short Retry-After, unless the policy lists 429 in
+// its status codes. A 429 that AuthProxy's own rate limiter produced is never
+// retried: another attempt would spend limiter budget again for the same
+// call, and the limiter has already waited as long as its rule allows.
+func retryDelay(ctx context.Context, policy *cschema.RetryPolicy, retry int, statusCode int, header http.Header, err error) (time.Duration, bool) {
+	if err != nil {
+		if !policy.GetNetworkErrors() || !isRetryableNetworkError(ctx, err) {
+			return 0, false
+		}
+		return policy.Backoff.Interval(retry), true
+	}
+
+	if header.Get(ratelimit.HeaderRateLimited) != "" {
+		return 0, false
+	}
+
+	listed := policy.RetriesStatus(statusCode)
+	if !listed && statusCode != http.StatusTooManyRequests {
+		return 0, false
+	}
+
+	retryAfter, found := ratelimit.ParseRetryAfter(header, []string{"Retry-After"}, apctx.GetClock(ctx).Now())
+	if found {
+		if retryAfter > policy.GetMaxRetryAfter() {
+			return 0, false
+		}
+		return max(retryAfter, 0), true
+	}
+
+	if !listed {
+		return 0, false
+	}
+	return policy.Backoff.Interval(retry), true
+}
+
+// isRetryableNetworkError reports whether err is a transient failure to
+// exchange the request with the upstream: a timeout, a refused, reset or
+// broken connection, a failed dial or a truncated response. Failures that
+// would recur on every attempt (certificate verification, pin mismatches,
+// TLS settings that can't be resolved, the redirect cap), credential
+// failures, egress policy refusals and the caller going away are not.
+func isRetryableNetworkError(ctx context.Context, err error) bool {
+	if ctx.Err() != nil || httpf.IsEgressDenied(err) {
+		return false
+	}
+	if errors.Is(err, context.Canceled) || isTLSVerificationError(err) {
+		return false
+	}
+
+	if errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// isTLSVerificationError reports whether err is the upstream's certificate
+// failing verification or a configured pin, or the upstream rejecting the
+// handshake.
+func isTLSVerificationError(err error) bool {
+	if errors.Is(err, httpf.ErrSpkiPinMismatch) {
+		return true
+	}
+
+	var verifyErr *tls.CertificateVerificationError
+	var alertErr tls.AlertError
+	var unknownAuthority x509.UnknownAuthorityError
+	var invalid x509.CertificateInvalidError
+	var hostname x509.HostnameError
+	return errors.As(err, &verifyErr) ||
+		errors.As(err, &alertErr) ||
+		errors.As(err, &unknownAuthority) ||
+		errors.As(err, &invalid) ||
+		errors.As(err, &hostname)
+}
+
+// waitToRetry sleeps for d, returning false if ctx is done first.
+func waitToRetry(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-apctx.GetClock(ctx).After(d):
+		return true
+	}
+}
+
+// proxyRequestHeader returns the caller-supplied headers of a wrapped proxy
+// request as an http.Header, for deciding whether it may be retried.
+func proxyRequestHeader(req *iface.ProxyRequest) http.Header {
+	h := make(http.Header, len(req.Headers))
+	for name, v := range req.Headers {
+		for _, hv := range v.Values() {
+			h.Add(name, hv)
+		}
+	}
+	return h
+}
+
+// canReplayRaw reports whether a raw outbound request can be sent again:
+// it has no body, or its body can be re-read through GetBody. A streamed
+// inbound body is consumed by the first attempt and cannot be replayed.
+func canReplayRaw(outbound *http.Request) bool {
+	return outbound.Body == nil || outbound.Body == http.NoBody || outbound.GetBody != nil
+}
+
+// replayRaw returns a copy of outbound for another attempt with a fresh body.
+func replayRaw(ctx context.Context, outbound *http.Request) (*http.Request, error) {
+	replay := outbound.WithContext(ctx)
+	if outbound.Body == nil || outbound.Body == http.NoBody {
+		return replay, nil
+	}
+
+	body, err := outbound.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	replay.Body = body
+	return replay, nil
+}
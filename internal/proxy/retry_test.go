@@ -0,0 +1,375 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/rmorlok/authproxy/internal/apid"
+	"github.com/rmorlok/authproxy/internal/app_metrics"
+	"github.com/rmorlok/authproxy/internal/core/iface"
+	mockCore "github.com/rmorlok/authproxy/internal/core/mock"
+	"github.com/rmorlok/authproxy/internal/httpf"
+	"github.com/rmorlok/authproxy/internal/ratelimit"
+	"github.com/rmorlok/authproxy/internal/schema/common"
+	cschema "github.com/rmorlok/authproxy/internal/schema/resources/connectors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	gentleman "gopkg.in/h2non/gentleman.v2"
+	"gopkg.in/h2non/gentleman.v2/plugins/transport"
+)
+
+type connectionWithRetryPolicy struct {
+	*mockCore.Connection
+	policy *cschema.RetryPolicy
+}
+
+func (c *connectionWithRetryPolicy) GetRetryPolicy() *cschema.RetryPolicy { return c.policy }
+
+// attemptRecorder stands in for the request-events round-tripper: it
+// assigns each request an id on its Attribution and records the attempt
+// linking the proxy stamped.
+type attemptRecorder struct {
+	transport http.RoundTripper
+
+	mu    sync.Mutex
+	attrs []app_metrics.Attribution
+}
+
+func (a *attemptRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	if attr := app_metrics.AttributionFromContext(req.Context()); attr != nil {
+		attr.RequestId = apid.New(apid.PrefixRequestEvents)
+		a.mu.Lock()
+		a.attrs = append(a.attrs, *attr)
+		a.mu.Unlock()
+	}
+	return a.transport.RoundTrip(req)
+}
+
+// recorderHttpf is stubHttpf with the attemptRecorder in front of the
+// upstream, for both the raw and wrapped paths.
+type recorderHttpf struct {
+	stubHttpf
+	recorder *attemptRecorder
+}
+
+func (s *recorderHttpf) New() *gentleman.Client {
+	return gentleman.New().Use(transport.Set(s.recorder))
+}
+
+func (s *recorderHttpf) ForRequestType(httpf.RequestType) httpf.F { return s }
+func (s *recorderHttpf) ForConnection(httpf.Connection) httpf.F   { return s }
+func (s *recorderHttpf) ForActor(httpf.Actor) httpf.F             { return s }
+func (s *recorderHttpf) ForLabels(map[string]string) httpf.F      { return s }
+
+func newRetryTestProxy(t *testing.T, h http.Handler, auth *fakeAuth, policy *cschema.RetryPolicy) (iface.Proxy, *attemptRecorder, string) {
+	t.Helper()
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+
+	p, recorder := newRetryTestProxyWithTransport(srv.Client().Transport, auth, policy)
+	return p, recorder, srv.URL
+}
+
+// newRetryTestProxyWithTransport builds a proxy that reaches the upstream
+// through transport, for tests that control the TLS handshake.
+func newRetryTestProxyWithTransport(transport http.RoundTripper, auth *fakeAuth, policy *cschema.RetryPolicy) (iface.Proxy, *attemptRecorder) {
+	recorder := &attemptRecorder{transport: transport}
+	conn := &connectionWithRetryPolicy{
+		Connection: &mockCore.Connection{
+			Id:        apid.New(apid.PrefixConnection),
+			Namespace: "root/",
+		},
+		policy: policy,
+	}
+	f := &recorderHttpf{stubHttpf: stubHttpf{client: &http.Client{Transport: recorder}}, recorder: recorder}
+	return New(f, conn, auth, nil), recorder
+}
+
+func fastRetryPolicy() *cschema.RetryPolicy {
+	return &cschema.RetryPolicy{
+		Backoff: &cschema.ExponentialBackoff{
+			InitialInterval: &common.HumanDuration{Duration: time.Millisecond},
+			MaxInterval:     &common.HumanDuration{Duration: 5 * time.Millisecond},
+		},
+	}
+}
+
+// failingThen returns a handler answering status for the first n calls and
+// 200 afterward.
+func failingThen(n int32, status int, header http.Header, calls *int32) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		if atomic.AddInt32(calls, 1) <= n {
+			for k, v := range header {
+				w.Header()[k] = v
+			}
+			w.WriteHeader(status)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	})
+}
+
+func TestProxyRequest_RetriesTransientStatus(t *testing.T) {
+	var calls int32
+	auth := &fakeAuth{headers: map[string]string{"Authorization": "Bearer t"}}
+	p, recorder, target := newRetryTestProxy(t, failingThen(2, http.StatusServiceUnavailable, nil, &calls), auth, fastRetryPolicy())
+
+	resp, err := p.ProxyRequest(context.Background(), httpf.RequestTypeProxy, &iface.ProxyRequest{Method: http.MethodGet, URL: target + "/x"})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+
+	require.Len(t, recorder.attrs, 3)
+	first := recorder.attrs[0]
+	assert.Equal(t, 1, first.Attempt)
+	assert.True(t, first.RetryOf.IsNil())
+	for i, attr := range recorder.attrs[1:] {
+		assert.Equal(t, i+2, attr.Attempt)
+		assert.Equal(t, first.RequestId, attr.RetryOf)
+	}
+}
+
+func TestProxyRequest_StopsAtMaxAttempts(t *testing.T) {
+	var calls int32
+	policy := fastRetryPolicy()
+	two := 2
+	policy.MaxAttempts = &two
+	auth := &fakeAuth{headers: map[string]string{"Authorization": "Bearer t"}}
+	p, _, target := newRetryTestProxy(t, failingThen(10, http.StatusBadGateway, nil, &calls), auth, policy)
+
+	resp, err := p.ProxyRequest(context.Background(), httpf.RequestTypeProxy, &iface.ProxyRequest{Method: http.MethodGet, URL: target + "/x"})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadGateway, resp.StatusCode)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
+
+func TestProxyRequest_NonIdempotentMethods(t *testing.T) {
+	auth := &fakeAuth{headers: map[string]string{"Authorization": "Bearer t"}}
+
+	t.Run("not retried without an idempotency key", func(t *testing.T) {
+		var calls int32
+		p, _, target := newRetryTestProxy(t, failingThen(1, http.StatusServiceUnavailable, nil, &calls), auth, fastRetryPolicy())
+
+		resp, err := p.ProxyRequest(context.Background(), httpf.RequestTypeProxy, &iface.ProxyRequest{Method: http.MethodPost, URL: target + "/x", BodyRaw: []byte("{}")})
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	})
+
+	t.Run("retried with an idempotency key", func(t *testing.T) {
+		var calls int32
+		p, _, target := newRetryTestProxy(t, failingThen(1, http.StatusServiceUnavailable, nil, &calls), auth, fastRetryPolicy())
+
+		resp, err := p.ProxyRequest(context.Background(), httpf.RequestTypeProxy, &iface.ProxyRequest{
+			Method:  http.MethodPost,
+			URL:     target + "/x",
+			Headers: map[string]iface.HeadersVal{"Idempotency-Key": common.NewHeadersVal("abc")},
+			BodyRaw: []byte("{}"),
+		})
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+	})
+}
+
+func TestProxyRequest_RetryAfter(t *testing.T) {
+	auth := &fakeAuth{headers: map[string]string{"Authorization": "Bearer t"}}
+
+	t.Run("short retry-after 429 is retried", func(t *testing.T) {
+		var calls int32
+		h := failingThen(1, http.StatusTooManyRequests, http.Header{"Retry-After": {"0"}}, &calls)
+		p, _, target := newRetryTestProxy(t, h, auth, fastRetryPolicy())
+
+		resp, err := p.ProxyRequest(context.Background(), httpf.RequestTypeProxy, &iface.ProxyRequest{Method: http.MethodGet, URL: target})
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+	})
+
+	t.Run("long retry-after is surfaced", func(t *testing.T) {
+		var calls int32
+		h := failingThen(1, http.StatusServiceUnavailable, http.Header{"Retry-After": {"3600"}}, &calls)
+		p, _, target := newRetryTestProxy(t, h, auth, fastRetryPolicy())
+
+		resp, err := p.ProxyRequest(context.Background(), httpf.RequestTypeProxy, &iface.ProxyRequest{Method: http.MethodGet, URL: target})
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	})
+
+	t.Run("429 without retry-after is surfaced", func(t *testing.T) {
+		var calls int32
+		h := failingThen(1, http.StatusTooManyRequests, nil, &calls)
+		p, _, target := newRetryTestProxy(t, h, auth, fastRetryPolicy())
+
+		resp, err := p.ProxyRequest(context.Background(), httpf.RequestTypeProxy, &iface.ProxyRequest{Method: http.MethodGet, URL: target})
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	})
+}
+
+func TestProxyRequest_LocalRateLimitNotRetried(t *testing.T) {
+	var calls int32
+	header := http.Header{"Retry-After": {"0"}, ratelimit.HeaderRateLimited: {"true"}}
+	auth := &fakeAuth{headers: map[string]string{"Authorization": "Bearer t"}}
+	p, recorder, target := newRetryTestProxy(t, failingThen(1, http.StatusTooManyRequests, header, &calls), auth, fastRetryPolicy())
+
+	resp, err := p.ProxyRequest(context.Background(), httpf.RequestTypeProxy, &iface.ProxyRequest{Method: http.MethodGet, URL: target})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	assert.Len(t, recorder.attrs, 1)
+}
+
+func TestProxyRequest_TLSFailuresNotRetried(t *testing.T) {
+	var calls int32
+	srv := httptest.NewTLSServer(failingThen(0, http.StatusOK, nil, &calls))
+	t.Cleanup(srv.Close)
+	auth := &fakeAuth{headers: map[string]string{"Authorization": "Bearer t"}}
+
+	t.Run("untrusted certificate", func(t *testing.T) {
+		p, recorder := newRetryTestProxyWithTransport(&http.Transport{}, auth, fastRetryPolicy())
+
+		_, err := p.ProxyRequest(context.Background(), httpf.RequestTypeProxy, &iface.ProxyRequest{Method: http.MethodGet, URL: srv.URL})
+		require.Error(t, err)
+		assert.Len(t, recorder.attrs, 1)
+	})
+
+	t.Run("pin mismatch", func(t *testing.T) {
+		transport := srv.Client().Transport.(*http.Transport).Clone()
+		transport.TLSClientConfig.VerifyConnection = func(tls.ConnectionState) error {
+			return httpf.ErrSpkiPinMismatch
+		}
+		p, recorder := newRetryTestProxyWithTransport(transport, auth, fastRetryPolicy())
+
+		_, err := p.ProxyRequest(context.Background(), httpf.RequestTypeProxy, &iface.ProxyRequest{Method: http.MethodGet, URL: srv.URL})
+		require.ErrorIs(t, err, httpf.ErrSpkiPinMismatch)
+		assert.Len(t, recorder.attrs, 1)
+	})
+
+	assert.Equal(t, int32(0), atomic.LoadInt32(&calls))
+}
+
+func TestProxyRequest_NoPolicyDoesNotRetry(t *testing.T) {
+	var calls int32
+	auth := &fakeAuth{headers: map[string]string{"Authorization": "Bearer t"}}
+	p, recorder, target := newRetryTestProxy(t, failingThen(1, http.StatusServiceUnavailable, nil, &calls), auth, nil)
+
+	resp, err := p.ProxyRequest(context.Background(), httpf.RequestTypeProxy, &iface.ProxyRequest{Method: http.MethodGet, URL: target})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	require.Len(t, recorder.attrs, 1)
+	assert.Equal(t, 1, recorder.attrs[0].Attempt)
+}
+
+func TestProxyRequestRaw_Retries(t *testing.T) {
+	auth := &fakeAuth{headers: map[string]string{"Authorization": "Bearer t"}}
+
+	t.Run("bodyless request is retried", func(t *testing.T) {
+		var calls int32
+		p, recorder, target := newRetryTestProxy(t, failingThen(1, http.StatusGatewayTimeout, nil, &calls), auth, fastRetryPolicy())
+
+		outbound, err := http.NewRequest(http.MethodGet, target+"/x", nil)
+		require.NoError(t, err)
+		rec := newRecordingResponseWriter()
+		require.NoError(t, p.ProxyRequestRaw(context.Background(), httpf.RequestTypeProxy, &iface.RawProxyRequest{Outbound: outbound}, rec))
+
+		assert.Equal(t, http.StatusOK, rec.status())
+		assert.Equal(t, "ok", rec.snapshot())
+		require.Len(t, recorder.attrs, 2)
+		assert.Equal(t, recorder.attrs[0].RequestId, recorder.attrs[1].RetryOf)
+	})
+
+	t.Run("replayable body is resent", func(t *testing.T) {
+		var bodies []string
+		var mu sync.Mutex
+		var calls int32
+		h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			b, _ := io.ReadAll(r.Body)
+			mu.Lock()
+			bodies = append(bodies, string(b))
+			mu.Unlock()
+			if atomic.AddInt32(&calls, 1) == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		})
+		p, _, target := newRetryTestProxy(t, h, auth, fastRetryPolicy())
+
+		outbound, err := http.NewRequest(http.MethodPut, target+"/x", bytes.NewReader([]byte("payload")))
+		require.NoError(t, err)
+		rec := newRecordingResponseWriter()
+		require.NoError(t, p.ProxyRequestRaw(context.Background(), httpf.RequestTypeProxy, &iface.RawProxyRequest{Outbound: outbound}, rec))
+
+		assert.Equal(t, http.StatusOK, rec.status())
+		assert.Equal(t, []string{"payload", "payload"}, bodies)
+	})
+
+	t.Run("streamed body is not retried", func(t *testing.T) {
+		var calls int32
+		p, _, target := newRetryTestProxy(t, failingThen(1, http.StatusServiceUnavailable, nil, &calls), auth, fastRetryPolicy())
+
+		outbound, err := http.NewRequest(http.MethodPut, target+"/x", io.NopCloser(bytes.NewReader([]byte("payload"))))
+		require.NoError(t, err)
+		rec := newRecordingResponseWriter()
+		require.NoError(t, p.ProxyRequestRaw(context.Background(), httpf.RequestTypeProxy, &iface.RawProxyRequest{Outbound: outbound}, rec))
+
+		assert.Equal(t, http.StatusServiceUnavailable, rec.status())
+		assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	})
+}
+
+func TestRetryDelay(t *testing.T) {
+	ctx := context.Background()
+	policy := fastRetryPolicy()
+
+	_, ok := retryDelay(ctx, policy, 0, http.StatusInternalServerError, nil, nil)
+	assert.False(t, ok, "500 is not retried by default")
+
+	_, ok = retryDelay(ctx, policy, 0, 0, nil, errors.New("resolve failed"))
+	assert.False(t, ok, "non-network errors are not retried")
+
+	denied := &url.Error{Op: "Get", URL: "http://10.0.0.1", Err: &httpf.EgressDeniedError{Addr: netip.MustParseAddr("10.0.0.1")}}
+	_, ok = retryDelay(ctx, policy, 0, 0, nil, denied)
+	assert.False(t, ok, "egress refusals are not retried")
+
+	redirects := &url.Error{Op: "Get", URL: "http://example.com", Err: errors.New("stopped after 10 redirects")}
+	_, ok = retryDelay(ctx, policy, 0, 0, nil, redirects)
+	assert.False(t, ok, "the redirect cap is not retried")
+
+	_, ok = retryDelay(ctx, policy, 0, 0, nil, &url.Error{Op: "Get", URL: "https://example.com", Err: x509.UnknownAuthorityError{}})
+	assert.False(t, ok, "certificate verification failures are not retried")
+
+	_, ok = retryDelay(ctx, policy, 0, 0, nil, &url.Error{Op: "Get", URL: "http://example.com", Err: syscall.ECONNRESET})
+	assert.True(t, ok, "reset connections are retried")
+
+	_, ok = retryDelay(ctx, policy, 0, http.StatusTooManyRequests, http.Header{"Retry-After": {"0"}, ratelimit.HeaderRateLimited: {"true"}}, nil)
+	assert.False(t, ok, "local rate limits are not retried")
+
+	d, ok := retryDelay(ctx, policy, 0, http.StatusTooManyRequests, http.Header{"Retry-After": {"2"}}, nil)
+	assert.True(t, ok)
+	assert.Equal(t, 2*time.Second, d)
+
+	disabled := false
+	policy.NetworkErrors = &disabled
+	_, ok = retryDelay(ctx, policy, 0, 0, nil, &net.OpError{Op: "dial", Err: errors.New("connection refused")})
+	assert.False(t, ok)
+}
@@ -0,0 +1,107 @@
+package proxy
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/rmorlok/authproxy/internal/core/iface"
+)
+
+// rewriteResponseUrls points the Location and Link headers of an upstream
+// response back at the caller-facing route. SDKs follow redirects and
+// pagination links as returned, so without this they would step around the
+// proxy and call the upstream directly, without credentials. Relative
+// references are resolved against the outbound request URL first.
+func rewriteResponseUrls(h http.Header, requestUrl *url.URL, rw *iface.UrlRewrite) {
+	if rw == nil || rw.From == nil || rw.To == nil {
+		return
+	}
+
+	if vv := h.Values("Location"); len(vv) > 0 {
+		rewritten := make([]string, len(vv))
+		for i, v := range vv {
+			rewritten[i] = rewriteUrl(v, requestUrl, rw)
+		}
+		h["Location"] = rewritten
+	}
+
+	if vv := h.Values("Link"); len(vv) > 0 {
+		rewritten := make([]string, len(vv))
+		for i, v := range vv {
+			rewritten[i] = rewriteLinkHeader(v, requestUrl, rw)
+		}
+		h["Link"] = rewritten
+	}
+}
+
+// rewriteLinkHeader rewrites the URI-reference of each link-value in an RFC
+// 8288 Link header, e.g. `<https://api.example.com/v1/items?page=2>;
+// rel="next"`. Angle brackets inside quoted parameter values are left alone.
+func rewriteLinkHeader(v string, requestUrl *url.URL, rw *iface.UrlRewrite) string {
+	var b strings.Builder
+	inQuotes := false
+	for i := 0; i < len(v); i++ {
+		c := v[i]
+		switch {
+		case c == '"':
+			inQuotes = !inQuotes
+		case c == '\\' && inQuotes && i+1 < len(v):
+			b.WriteByte(c)
+			i++
+			c = v[i]
+		case c == '<' && !inQuotes:
+			end := strings.IndexByte(v[i+1:], '>')
+			if end < 0 {
+				b.WriteString(v[i:])
+				return b.String()
+			}
+			b.WriteByte('<')
+			b.WriteString(rewriteUrl(v[i+1:i+1+end], requestUrl, rw))
+			b.WriteByte('>')
+			i += end + 1
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+// rewriteUrl maps raw onto rw.To when it resolves to a URL under rw.From,
+// matching on path segment boundaries. The query and fragment are kept.
+// Anything else, including URLs that fail to parse, is returned unchanged.
+func rewriteUrl(raw string, requestUrl *url.URL, rw *iface.UrlRewrite) string {
+	ref, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return raw
+	}
+
+	u := ref
+	if requestUrl != nil {
+		u = requestUrl.ResolveReference(ref)
+	}
+
+	if !strings.EqualFold(u.Scheme, rw.From.Scheme) || !strings.EqualFold(u.Host, rw.From.Host) {
+		return raw
+	}
+
+	fromPath := strings.TrimSuffix(rw.From.EscapedPath(), "/")
+	p := u.EscapedPath()
+	if p != fromPath && !strings.HasPrefix(p, fromPath+"/") {
+		return raw
+	}
+
+	out := *rw.To
+	escaped := strings.TrimSuffix(rw.To.EscapedPath(), "/") + p[len(fromPath):]
+	decoded, err := url.PathUnescape(escaped)
+	if err != nil {
+		return raw
+	}
+	out.Path = decoded
+	out.RawPath = escaped
+	out.RawQuery = u.RawQuery
+	out.ForceQuery = u.ForceQuery
+	out.Fragment = u.Fragment
+	out.RawFragment = u.RawFragment
+	return out.String()
+}
@@ -0,0 +1,85 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/rmorlok/authproxy/internal/core/iface"
+	"github.com/rmorlok/authproxy/internal/httpf"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRewriteUrl(t *testing.T) {
+	rw := &iface.UrlRewrite{
+		From: &url.URL{Scheme: "https", Host: "acme.zendesk.com", Path: "/api/v2"},
+		To:   &url.URL{Scheme: "https", Host: "authproxy.example.com", Path: "/api/v1/connections/cxn_1/upstream"},
+	}
+	requestUrl := &url.URL{Scheme: "https", Host: "acme.zendesk.com", Path: "/api/v2/tickets"}
+
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"https://acme.zendesk.com/api/v2/tickets?page=2", "https://authproxy.example.com/api/v1/connections/cxn_1/upstream/tickets?page=2"},
+		{"https://ACME.zendesk.com/api/v2", "https://authproxy.example.com/api/v1/connections/cxn_1/upstream"},
+		{"/api/v2/tickets/1", "https://authproxy.example.com/api/v1/connections/cxn_1/upstream/tickets/1"},
+		{"tickets/1", "https://authproxy.example.com/api/v1/connections/cxn_1/upstream/tickets/1"},
+		{"https://acme.zendesk.com/api/v2/users/a%2Fb", "https://authproxy.example.com/api/v1/connections/cxn_1/upstream/users/a%2Fb"},
+		{"https://acme.zendesk.com/api/v20/tickets", "https://acme.zendesk.com/api/v20/tickets"},
+		{"https://other.zendesk.com/api/v2/tickets", "https://other.zendesk.com/api/v2/tickets"},
+		{"http://acme.zendesk.com/api/v2/tickets", "http://acme.zendesk.com/api/v2/tickets"},
+		{"https://cdn.example.com/logo.png", "https://cdn.example.com/logo.png"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			assert.Equal(t, tt.want, rewriteUrl(tt.in, requestUrl, rw))
+		})
+	}
+}
+
+func TestRewriteLinkHeader(t *testing.T) {
+	rw := &iface.UrlRewrite{
+		From: &url.URL{Scheme: "https", Host: "api.github.com"},
+		To:   &url.URL{Scheme: "http", Host: "localhost:8080", Path: "/api/v1/connections/cxn_1/upstream"},
+	}
+
+	in := `<https://api.github.com/repos/o/r/issues?page=2>; rel="next", <https://api.github.com/repos/o/r/issues?page=5>; rel="last"; title="<not a link>"`
+	want := `<http://localhost:8080/api/v1/connections/cxn_1/upstream/repos/o/r/issues?page=2>; rel="next", <http://localhost:8080/api/v1/connections/cxn_1/upstream/repos/o/r/issues?page=5>; rel="last"; title="<not a link>"`
+	assert.Equal(t, want, rewriteLinkHeader(in, nil, rw))
+}
+
+// TestProxyRequestRaw_RewritesResponseUrls — redirects and pagination
+// links from the upstream must come back pointing at the proxy route so
+// SDKs that follow them keep sending requests through AuthProxy.
+func TestProxyRequestRaw_RewritesResponseUrls(t *testing.T) {
+	var upstreamBase string
+	upstream := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Location", "/v1/items/2")
+		w.Header().Add("Link", "<"+upstreamBase+"/v1/items?page=2>; rel=\"next\"")
+		w.WriteHeader(http.StatusCreated)
+	})
+	p, srv := newRawTestProxy(t, upstream, &fakeAuth{})
+	upstreamBase = srv.URL
+
+	from, err := url.Parse(srv.URL + "/v1")
+	require.NoError(t, err)
+	to, err := url.Parse("https://authproxy.example.com/api/v1/connections/cxn_1/upstream")
+	require.NoError(t, err)
+
+	outbound, err := http.NewRequest(http.MethodPost, srv.URL+"/v1/items", nil)
+	require.NoError(t, err)
+
+	rec := newRecordingResponseWriter()
+	require.NoError(t, p.ProxyRequestRaw(context.Background(), httpf.RequestTypeProxy, &iface.RawProxyRequest{
+		Outbound:           outbound,
+		ResponseUrlRewrite: &iface.UrlRewrite{From: from, To: to},
+	}, rec))
+
+	assert.Equal(t, http.StatusCreated, rec.status())
+	assert.Equal(t, "https://authproxy.example.com/api/v1/connections/cxn_1/upstream/items/2", rec.Header().Get("Location"))
+	assert.Equal(t, `<https://authproxy.example.com/api/v1/connections/cxn_1/upstream/items?page=2>; rel="next"`, rec.Header().Get("Link"))
+}
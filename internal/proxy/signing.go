@@ -0,0 +1,55 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+
+	"github.com/rmorlok/authproxy/internal/auth_methods"
+	gcontext "gopkg.in/h2non/gentleman.v2/context"
+)
+
+// signingHandler returns a gentleman "before dial" handler that signs the
+// fully-built *http.Request. It runs after every plugin has applied
+// headers, query and body, so the signature covers exactly what is sent.
+// The buffered body is read for hashing and replaced with an equivalent
+// reader.
+func signingHandler(ctx context.Context, signer auth_methods.RequestSigner) gcontext.HandlerFunc {
+	return func(gctx *gcontext.Context, h gcontext.Handler) {
+		req := gctx.Request
+
+		var body []byte
+		if req.Body != nil && req.Body != http.NoBody {
+			b, err := io.ReadAll(req.Body)
+			_ = req.Body.Close()
+			if err != nil {
+				h.Error(gctx, err)
+				return
+			}
+			body = b
+			req.Body = io.NopCloser(bytes.NewReader(body))
+			req.GetBody = func() (io.ReadCloser, error) {
+				return io.NopCloser(bytes.NewReader(body)), nil
+			}
+			req.ContentLength = int64(len(body))
+		}
+
+		if err := signer.SignRequest(ctx, req, auth_methods.SigningPayload{Body: body}); err != nil {
+			h.Error(gctx, err)
+			return
+		}
+		h.Next(gctx)
+	}
+}
+
+// rawSigningPayload describes the body of a raw (streaming) outbound
+// request. The inbound body is forwarded as it arrives, so it can't be
+// hashed up front: anything other than an empty body is signed as
+// UNSIGNED-PAYLOAD.
+func rawSigningPayload(outbound *http.Request) auth_methods.SigningPayload {
+	if outbound.Body == nil || outbound.Body == http.NoBody {
+		return auth_methods.SigningPayload{}
+	}
+	return auth_methods.SigningPayload{Unsigned: true}
+}
@@ -0,0 +1,137 @@
+package proxy
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/rmorlok/authproxy/internal/apid"
+	"github.com/rmorlok/authproxy/internal/auth_methods"
+	"github.com/rmorlok/authproxy/internal/core/iface"
+	mockCore "github.com/rmorlok/authproxy/internal/core/mock"
+	"github.com/rmorlok/authproxy/internal/httpf"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// signingAuth is a fakeAuth that also implements RequestSigner. It sets a
+// header derived from the payload so tests can assert on what was signed.
+type signingAuth struct {
+	fakeAuth
+	signN int32
+}
+
+func (a *signingAuth) SignRequest(ctx context.Context, req *http.Request, payload auth_methods.SigningPayload) error {
+	atomic.AddInt32(&a.signN, 1)
+	sig := auth_methods.UnsignedPayload
+	if !payload.Unsigned {
+		sum := sha256.Sum256(payload.Body)
+		sig = hex.EncodeToString(sum[:])
+	}
+	req.Header.Set("X-Test-Signature", req.Method+" "+req.URL.Path+" "+sig)
+	return nil
+}
+
+var _ auth_methods.RequestSigner = (*signingAuth)(nil)
+
+func newSigningTestProxy(t *testing.T, h http.Handler, auth *signingAuth) (iface.Proxy, *httptest.Server) {
+	t.Helper()
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+
+	conn := &mockCore.Connection{
+		Id:        apid.New(apid.PrefixConnection),
+		Namespace: "root/",
+	}
+	return New(&stubHttpf{client: srv.Client()}, conn, auth, nil), srv
+}
+
+func sha256Hex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestProxyRequest_SignsBufferedBody(t *testing.T) {
+	var receivedSig, receivedBody string
+	upstream := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		receivedSig = r.Header.Get("X-Test-Signature")
+		b, _ := io.ReadAll(r.Body)
+		receivedBody = string(b)
+		w.WriteHeader(http.StatusOK)
+	})
+	auth := &signingAuth{}
+	p, srv := newSigningTestProxy(t, upstream, auth)
+
+	resp, err := p.ProxyRequest(context.Background(), httpf.RequestTypeProxy, &iface.ProxyRequest{
+		URL:     srv.URL + "/things",
+		Method:  http.MethodPost,
+		BodyRaw: []byte(`{"a":1}`),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "POST /things "+sha256Hex(`{"a":1}`), receivedSig)
+	assert.Equal(t, `{"a":1}`, receivedBody, "signing must not consume the body")
+}
+
+func TestProxyRequest_ResignsOnRetry(t *testing.T) {
+	var calls int32
+	upstream := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	auth := &signingAuth{fakeAuth: fakeAuth{maxRecover: 1}}
+	p, srv := newSigningTestProxy(t, upstream, auth)
+
+	_, err := p.ProxyRequest(context.Background(), httpf.RequestTypeProxy, &iface.ProxyRequest{
+		URL:    srv.URL + "/retry",
+		Method: http.MethodGet,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&auth.signN), "each attempt must be signed")
+}
+
+func TestProxyRequestRaw_SignsStreamingBodyAsUnsigned(t *testing.T) {
+	var receivedSig, receivedBody string
+	upstream := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		receivedSig = r.Header.Get("X-Test-Signature")
+		b, _ := io.ReadAll(r.Body)
+		receivedBody = string(b)
+		w.WriteHeader(http.StatusOK)
+	})
+	auth := &signingAuth{}
+	p, srv := newSigningTestProxy(t, upstream, auth)
+
+	outbound, err := http.NewRequest(http.MethodPut, srv.URL+"/upload", io.NopCloser(strings.NewReader("streamed")))
+	require.NoError(t, err)
+
+	rec := newRecordingResponseWriter()
+	require.NoError(t, p.ProxyRequestRaw(context.Background(), httpf.RequestTypeProxy, &iface.RawProxyRequest{Outbound: outbound}, rec))
+	assert.Equal(t, "PUT /upload "+auth_methods.UnsignedPayload, receivedSig)
+	assert.Equal(t, "streamed", receivedBody)
+}
+
+func TestProxyRequestRaw_SignsEmptyBody(t *testing.T) {
+	var receivedSig string
+	upstream := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		receivedSig = r.Header.Get("X-Test-Signature")
+		w.WriteHeader(http.StatusOK)
+	})
+	auth := &signingAuth{}
+	p, srv := newSigningTestProxy(t, upstream, auth)
+
+	outbound, err := http.NewRequest(http.MethodGet, srv.URL+"/list", nil)
+	require.NoError(t, err)
+
+	rec := newRecordingResponseWriter()
+	require.NoError(t, p.ProxyRequestRaw(context.Background(), httpf.RequestTypeProxy, &iface.RawProxyRequest{Outbound: outbound}, rec))
+	assert.Equal(t, "GET /list "+sha256Hex(""), receivedSig)
+}
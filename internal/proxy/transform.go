@@ -0,0 +1,271 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/rmorlok/authproxy/internal/apjs"
+	"github.com/rmorlok/authproxy/internal/core/iface"
+	"github.com/rmorlok/authproxy/internal/httperr"
+	"github.com/rmorlok/authproxy/internal/schema/common"
+	cschema "github.com/rmorlok/authproxy/internal/schema/resources/connectors"
+)
+
+// ProxyTransformsProvider is implemented by connections whose connector
+// declares JavaScript transforms for wrapped proxy traffic.
+type ProxyTransformsProvider interface {
+	GetRequestTransforms() cschema.ProxyTransforms
+	GetResponseTransforms() cschema.ProxyTransforms
+}
+
+// transforms are the connector transforms matching one wrapped proxy
+// request, with the javascript context they run in.
+type transforms struct {
+	js       apjs.Context
+	method   string
+	target   *url.URL
+	request  []*cschema.ProxyTransform
+	response []*cschema.ProxyTransform
+}
+
+// transformsFor returns the transforms matching a request to target, or nil
+// when there are none.
+func (p *proxy) transformsFor(ctx context.Context, method string, target *url.URL) (*transforms, error) {
+	provider, ok := p.conn.(ProxyTransformsProvider)
+	if !ok || target == nil {
+		return nil, nil
+	}
+
+	t := &transforms{
+		method:   method,
+		target:   target,
+		request:  provider.GetRequestTransforms().Matching(target.Path),
+		response: provider.GetResponseTransforms().Matching(target.Path),
+	}
+	if len(t.request) == 0 && len(t.response) == 0 {
+		return nil, nil
+	}
+
+	js, err := p.conn.GetJavascriptContext(ctx)
+	if err != nil {
+		return nil, httperr.InternalServerError(httperr.WithInternalErrorf("failed to get javascript context: %w", err))
+	}
+	t.js = js
+
+	return t, nil
+}
+
+func (t *transforms) requestVar(req *iface.ProxyRequest, withBody bool) map[string]any {
+	headers := make(map[string]any, len(req.Headers))
+	for k, v := range req.Headers {
+		headers[k] = strings.Join(v.Values(), ", ")
+	}
+
+	v := map[string]any{
+		"method":  t.method,
+		"url":     t.target.String(),
+		"path":    t.target.Path,
+		"headers": headers,
+	}
+	if withBody {
+		v["bodyJson"] = req.BodyJson
+	}
+	return v
+}
+
+func (t *transforms) evaluate(transform *cschema.ProxyTransform, name string, value any, extra map[string]any) (map[string]any, error) {
+	js := t.js.WithTimeout(transform.GetTimeout()).WithVar(name, value)
+	for k, v := range extra {
+		js = js.WithVar(k, v)
+	}
+	return js.EvaluateObject(transform.Javascript)
+}
+
+// applyRequest returns req as rewritten by the request transforms. req is
+// not modified.
+func (t *transforms) applyRequest(req *iface.ProxyRequest) (*iface.ProxyRequest, error) {
+	if t == nil || len(t.request) == 0 {
+		return req, nil
+	}
+
+	out := *req
+	out.Headers = maps.Clone(req.Headers)
+
+	for _, transform := range t.request {
+		patch, err := t.evaluate(transform, "request", t.requestVar(&out, true), nil)
+		if err == nil {
+			err = patchRequest(&out, patch)
+		}
+		if err != nil {
+			return nil, httperr.InternalServerErrorMsg("connector request transform failed", httperr.WithInternalErr(err))
+		}
+	}
+
+	return &out, nil
+}
+
+func patchRequest(req *iface.ProxyRequest, patch map[string]any) error {
+	for field, value := range patch {
+		switch field {
+		case "headers":
+			headers, ok := value.(map[string]any)
+			if !ok {
+				return fmt.Errorf("headers must be an object, got %T", value)
+			}
+			for name, v := range headers {
+				if req.Headers == nil {
+					req.Headers = map[string]common.HeadersVal{}
+				}
+				deleteHeaderFold(req.Headers, name)
+				switch hv := v.(type) {
+				case nil:
+				case string:
+					req.Headers[name] = common.NewHeadersVal(hv)
+				case []any:
+					values := make([]string, 0, len(hv))
+					for _, s := range hv {
+						values = append(values, fmt.Sprint(s))
+					}
+					req.Headers[name] = common.NewHeadersValSlice(values)
+				default:
+					req.Headers[name] = common.NewHeadersVal(fmt.Sprint(hv))
+				}
+			}
+		case "bodyJson":
+			req.BodyJson = value
+			req.BodyRaw = nil
+		default:
+			return fmt.Errorf("unknown request transform field %q", field)
+		}
+	}
+	return nil
+}
+
+// applyResponse rewrites resp in place with the response transforms.
+func (t *transforms) applyResponse(req *iface.ProxyRequest, resp *iface.ProxyResponse) error {
+	if t == nil || len(t.response) == 0 {
+		return nil
+	}
+
+	reqVar := t.requestVar(req, false)
+	for _, transform := range t.response {
+		headers := make(map[string]any, len(resp.Headers))
+		for k, v := range resp.Headers {
+			headers[k] = v
+		}
+		respVar := map[string]any{
+			"statusCode": resp.StatusCode,
+			"headers":    headers,
+			"bodyJson":   resp.BodyJson,
+		}
+		patch, err := t.evaluate(transform, "response", respVar, map[string]any{"request": reqVar})
+		if err == nil {
+			err = patchResponse(resp, patch)
+		}
+		if err != nil {
+			return httperr.New(http.StatusBadGateway, "connector response transform failed", httperr.WithInternalErr(err))
+		}
+	}
+
+	return nil
+}
+
+func patchResponse(resp *iface.ProxyResponse, patch map[string]any) error {
+	for field, value := range patch {
+		switch field {
+		case "statusCode":
+			var code int
+			switch c := value.(type) {
+			case int64:
+				code = int(c)
+			case float64:
+				code = int(c)
+			default:
+				return fmt.Errorf("statusCode must be a number, got %T", value)
+			}
+			if code < 100 || code > 599 {
+				return fmt.Errorf("statusCode %d is not a valid HTTP status", code)
+			}
+			resp.StatusCode = code
+		case "headers":
+			headers, ok := value.(map[string]any)
+			if !ok {
+				return fmt.Errorf("headers must be an object, got %T", value)
+			}
+			for name, v := range headers {
+				if resp.Headers == nil {
+					resp.Headers = map[string]string{}
+				}
+				deleteHeaderFold(resp.Headers, name)
+				switch hv := v.(type) {
+				case nil:
+				case []any:
+					values := make([]string, 0, len(hv))
+					for _, s := range hv {
+						values = append(values, fmt.Sprint(s))
+					}
+					resp.Headers[http.CanonicalHeaderKey(name)] = strings.Join(values, ", ")
+				default:
+					resp.Headers[http.CanonicalHeaderKey(name)] = fmt.Sprint(hv)
+				}
+			}
+		case "bodyJson":
+			if resp.Headers == nil {
+				resp.Headers = map[string]string{}
+			}
+			if resp.BodyRaw != nil {
+				resp.Headers["Content-Type"] = "application/json"
+			}
+			delete(resp.Headers, "Content-Length")
+			resp.BodyJson = value
+			resp.BodyRaw = nil
+		default:
+			return fmt.Errorf("unknown response transform field %q", field)
+		}
+	}
+	return nil
+}
+
+// loggedResponse applies the response transforms to an upstream response
+// body as the full request log captured it, so the log never holds what the
+// transforms remove. If the transforms fail, nothing is logged.
+func (t *transforms) loggedResponse(req *iface.ProxyRequest) func(statusCode int, header http.Header, body []byte) []byte {
+	return func(statusCode int, header http.Header, body []byte) []byte {
+		resp := &iface.ProxyResponse{StatusCode: statusCode, Headers: map[string]string{}}
+		for name, values := range header {
+			resp.Headers[name] = strings.Join(values, ", ")
+		}
+		if strings.HasPrefix(header.Get("Content-Type"), "application/json") {
+			if err := json.Unmarshal(body, &resp.BodyJson); err != nil {
+				return nil
+			}
+		} else {
+			resp.BodyRaw = body
+		}
+
+		if err := t.applyResponse(req, resp); err != nil {
+			return nil
+		}
+		if resp.BodyJson != nil {
+			data, err := json.Marshal(resp.BodyJson)
+			if err != nil {
+				return nil
+			}
+			return data
+		}
+		return resp.BodyRaw
+	}
+}
+
+func deleteHeaderFold[V any](headers map[string]V, name string) {
+	for k := range headers {
+		if strings.EqualFold(k, name) {
+			delete(headers, k)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rmorlok/authproxy/internal/apid"
+	"github.com/rmorlok/authproxy/internal/core/iface"
+	mockCore "github.com/rmorlok/authproxy/internal/core/mock"
+	"github.com/rmorlok/authproxy/internal/httperr"
+	"github.com/rmorlok/authproxy/internal/httpf"
+	"github.com/rmorlok/authproxy/internal/schema/common"
+	cschema "github.com/rmorlok/authproxy/internal/schema/resources/connectors"
+	"github.com/rmorlok/authproxy/internal/schema/resources/rate_limit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type connectionWithTransforms struct {
+	*mockCore.Connection
+	request  cschema.ProxyTransforms
+	response cschema.ProxyTransforms
+}
+
+func (c *connectionWithTransforms) GetRequestTransforms() cschema.ProxyTransforms  { return c.request }
+func (c *connectionWithTransforms) GetResponseTransforms() cschema.ProxyTransforms { return c.response }
+
+func newTransformTestProxy(t *testing.T, h http.Handler, request, response cschema.ProxyTransforms) (iface.Proxy, *attemptRecorder, string) {
+	t.Helper()
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+
+	recorder := &attemptRecorder{transport: srv.Client().Transport}
+	conn := &connectionWithTransforms{
+		Connection: &mockCore.Connection{
+			Id:            apid.New(apid.PrefixConnection),
+			Namespace:     "root/",
+			Configuration: map[string]any{"tenantId": "acme"},
+		},
+		request:  request,
+		response: response,
+	}
+	f := &recorderHttpf{stubHttpf: stubHttpf{client: &http.Client{Transport: recorder}}, recorder: recorder}
+	return New(f, conn, &fakeAuth{}, nil), recorder, srv.URL
+}
+
+func prefixTransform(prefix, javascript string) cschema.ProxyTransform {
+	return cschema.ProxyTransform{
+		PathMatch:  &rate_limit.PathMatch{Kind: rate_limit.PathMatchKindPrefix, Value: prefix},
+		Javascript: javascript,
+	}
+}
+
+func TestProxyRequest_RequestTransform(t *testing.T) {
+	var got *http.Request
+	var gotBody map[string]any
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		_ = json.NewDecoder(r.Body).Decode(&gotBody)
+		w.WriteHeader(http.StatusNoContent)
+	})
+	p, _, target := newTransformTestProxy(t, h, cschema.ProxyTransforms{
+		prefixTransform("/v1/", `({headers: {"X-Tenant-Id": cfg.tenantId, "X-Drop": null}})`),
+		prefixTransform("/v1/contacts", `({bodyJson: Object.assign({}, request.bodyJson, {source: request.method})})`),
+		prefixTransform("/v2/", `({headers: {"X-Unused": "1"}})`),
+	}, nil)
+
+	req := &iface.ProxyRequest{
+		Method:   http.MethodPost,
+		URL:      target + "/v1/contacts",
+		Headers:  map[string]iface.HeadersVal{"x-drop": common.NewHeadersVal("secret")},
+		BodyJson: map[string]any{"name": "Ada"},
+	}
+	resp, err := p.ProxyRequest(context.Background(), httpf.RequestTypeProxy, req)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	assert.Equal(t, "acme", got.Header.Get("X-Tenant-Id"))
+	assert.Empty(t, got.Header.Get("X-Drop"))
+	assert.Empty(t, got.Header.Get("X-Unused"))
+	assert.Equal(t, map[string]any{"name": "Ada", "source": "POST"}, gotBody)
+
+	// The caller's request is left as it was.
+	assert.Contains(t, req.Headers, "x-drop")
+	assert.Equal(t, map[string]any{"name": "Ada"}, req.BodyJson)
+}
+
+func TestProxyRequest_ResponseTransform(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/missing" {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"errors":[{"detail":"no such contact"}]}`))
+			return
+		}
+		writeJSON(w, map[string]any{"id": "c_1", "email": "ada@example.com"})
+	})
+	p, _, target := newTransformTestProxy(t, h, nil, cschema.ProxyTransforms{
+		prefixTransform("/v1/", `(function(b) { b = Object.assign({}, b); delete b.email; return {bodyJson: b, headers: {"X-Redacted": "true"}}; })(response.bodyJson)`),
+		prefixTransform("/v1/missing", `response.statusCode >= 400 ? {statusCode: 422, bodyJson: {error: response.bodyJson.errors[0].detail}} : null`),
+	})
+
+	resp, err := p.ProxyRequest(context.Background(), httpf.RequestTypeProxy, &iface.ProxyRequest{Method: http.MethodGet, URL: target + "/v1/contacts/c_1"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"id": "c_1"}, resp.BodyJson)
+	assert.Equal(t, "true", resp.Headers["X-Redacted"])
+	assert.Empty(t, resp.Headers["Content-Length"])
+
+	resp, err = p.ProxyRequest(context.Background(), httpf.RequestTypeProxy, &iface.ProxyRequest{Method: http.MethodGet, URL: target + "/v1/missing"})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+	assert.Equal(t, map[string]any{"error": "no such contact"}, resp.BodyJson)
+}
+
+func TestProxyRequest_TransformFailures(t *testing.T) {
+	var calls int
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		writeJSON(w, map[string]any{"ok": true})
+	})
+
+	t.Run("request transform error fails before sending", func(t *testing.T) {
+		calls = 0
+		p, _, target := newTransformTestProxy(t, h, cschema.ProxyTransforms{prefixTransform("/", `({method: "DELETE"})`)}, nil)
+		_, err := p.ProxyRequest(context.Background(), httpf.RequestTypeProxy, &iface.ProxyRequest{Method: http.MethodGet, URL: target + "/x"})
+		require.Error(t, err)
+		assert.Equal(t, http.StatusInternalServerError, httperr.FromError(err).Status)
+		assert.Zero(t, calls)
+	})
+
+	t.Run("response transform error withholds the response", func(t *testing.T) {
+		p, _, target := newTransformTestProxy(t, h, nil, cschema.ProxyTransforms{prefixTransform("/", `response.bodyJson.missing.field`)})
+		_, err := p.ProxyRequest(context.Background(), httpf.RequestTypeProxy, &iface.ProxyRequest{Method: http.MethodGet, URL: target + "/x"})
+		require.Error(t, err)
+		assert.Equal(t, http.StatusBadGateway, httperr.FromError(err).Status)
+	})
+
+	t.Run("transform that runs too long is interrupted", func(t *testing.T) {
+		slow := prefixTransform("/", `(function() { while (true) {} })()`)
+		slow.Timeout = &common.HumanDuration{Duration: 10 * time.Millisecond}
+		p, _, target := newTransformTestProxy(t, h, nil, cschema.ProxyTransforms{slow})
+
+		_, err := p.ProxyRequest(context.Background(), httpf.RequestTypeProxy, &iface.ProxyRequest{Method: http.MethodGet, URL: target + "/x"})
+		require.Error(t, err)
+		assert.Equal(t, http.StatusBadGateway, httperr.FromError(err).Status)
+		assert.ErrorContains(t, err, "time limit")
+	})
+}
+
+func TestProxyRequest_ResponseTransformAppliesToLoggedBody(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]any{"id": "c_1", "email": "ada@example.com"})
+	})
+	p, recorder, target := newTransformTestProxy(t, h, nil, cschema.ProxyTransforms{
+		prefixTransform("/", `({bodyJson: {id: response.bodyJson.id}})`),
+	})
+
+	_, err := p.ProxyRequest(context.Background(), httpf.RequestTypeProxy, &iface.ProxyRequest{Method: http.MethodGet, URL: target + "/v1/contacts/c_1"})
+	require.NoError(t, err)
+
+	require.Len(t, recorder.attrs, 1)
+	transform := recorder.attrs[0].TransformResponseBody
+	require.NotNil(t, transform)
+
+	logged := transform(http.StatusOK, http.Header{"Content-Type": {"application/json"}}, []byte(`{"id":"c_1","email":"ada@example.com"}`))
+	assert.JSONEq(t, `{"id":"c_1"}`, string(logged))
+
+	assert.Nil(t, transform(http.StatusOK, http.Header{"Content-Type": {"application/json"}}, []byte(`not json`)))
+}
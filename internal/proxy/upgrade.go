@@ -0,0 +1,186 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rmorlok/authproxy/internal/httperr"
+	"github.com/rmorlok/authproxy/internal/httpf"
+	cschema "github.com/rmorlok/authproxy/internal/schema/resources/connectors"
+)
+
+// UpgradeIdleTimeoutProvider is implemented by connections whose connector
+// configures how long an upgraded connection may sit idle. Connections that
+// don't implement it get the connector default.
+type UpgradeIdleTimeoutProvider interface {
+	GetUpgradeIdleTimeout() time.Duration
+}
+
+func (p *proxy) upgradeIdleTimeout() time.Duration {
+	if provider, ok := p.conn.(UpgradeIdleTimeoutProvider); ok {
+		return provider.GetUpgradeIdleTimeout()
+	}
+	return (*cschema.Transport)(nil).GetUpgradeIdleTimeoutOrDefault()
+}
+
+// upgradeType returns the protocol a request or response asks to switch to
+// (e.g. "websocket"), or "" when the headers don't describe an HTTP upgrade.
+// Both Connection: upgrade and an Upgrade header are required, per RFC 7230
+// §6.7.
+func upgradeType(h http.Header) string {
+	for _, v := range h.Values("Connection") {
+		for _, token := range splitCommaTrim(v) {
+			if strings.EqualFold(token, "upgrade") {
+				return h.Get("Upgrade")
+			}
+		}
+	}
+	return ""
+}
+
+// proxyUpgrade completes a 101 Switching Protocols handshake with the caller
+// and then pumps bytes in both directions until either side closes or the
+// session goes idle. Credentials were applied to the handshake by sendRaw;
+// after the switch the bytes are opaque to the proxy.
+//
+// The upgraded upstream connection is resp.Body, which net/http makes
+// writable for 101 responses. The app_metrics round-tripper wraps it to count
+// bytes per direction and records the session when it is closed.
+func (p *proxy) proxyUpgrade(ctx context.Context, reqType httpf.RequestType, requested string, w http.ResponseWriter, resp *http.Response) error {
+	upstream, ok := resp.Body.(io.ReadWriteCloser)
+	if !ok {
+		drainAndClose(resp.Body)
+		return httperr.New(http.StatusBadGateway, "upstream upgrade response is not writable")
+	}
+
+	switched := upgradeType(resp.Header)
+	if !strings.EqualFold(switched, requested) {
+		upstream.Close()
+		return httperr.Newf(http.StatusBadGateway, "upstream switched to protocol %q, not the requested %q", switched, requested)
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		upstream.Close()
+		return httperr.InternalServerErrorMsg("response writer does not support protocol upgrades")
+	}
+
+	copyHeaderExceptHopByHop(w.Header(), resp.Header)
+	header := w.Header().Clone()
+	header.Set("Connection", "Upgrade")
+	header.Set("Upgrade", switched)
+
+	downstream, brw, err := hijacker.Hijack()
+	if err != nil {
+		upstream.Close()
+		return fmt.Errorf("failed to hijack connection for upgrade: %w", err)
+	}
+
+	handshake := &http.Response{
+		StatusCode: http.StatusSwitchingProtocols,
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     header,
+	}
+	if err := handshake.Write(brw); err == nil {
+		err = brw.Flush()
+	}
+	if err != nil {
+		upstream.Close()
+		downstream.Close()
+		// The connection is no longer ours to write an error to.
+		p.logger.WarnContext(ctx, "failed to complete upgrade handshake with caller",
+			"connection_id", p.conn.GetId().String(),
+			"request_type", reqType.String(),
+			"error", err,
+		)
+		return nil
+	}
+
+	idleTimeout := p.upgradeIdleTimeout()
+	start := time.Now()
+	idled := pumpUpgraded(ctx, downstream, brw, upstream, idleTimeout)
+
+	p.logger.DebugContext(ctx, "proxy upgraded session closed",
+		"connection_id", p.conn.GetId().String(),
+		"request_type", reqType.String(),
+		"protocol", switched,
+		"duration", time.Since(start).String(),
+		"idle_timeout", idled,
+	)
+	return nil
+}
+
+// pumpUpgraded copies bytes between the caller and the upstream until either
+// direction ends, ctx is cancelled, or neither direction sees traffic for
+// idleTimeout. Both connections are closed on return. Reports whether the
+// session was closed for being idle.
+//
+// When one direction ends the other is closed too rather than half-closed:
+// WebSocket and most other upgraded protocols close both directions
+// together, and waiting on a peer that never closes would leak the session.
+func pumpUpgraded(ctx context.Context, downstream net.Conn, brw *bufio.ReadWriter, upstream io.ReadWriteCloser, idleTimeout time.Duration) bool {
+	var (
+		closeOnce sync.Once
+		idleMu    sync.Mutex
+		idled     bool
+	)
+	closeBoth := func() {
+		closeOnce.Do(func() {
+			downstream.Close()
+			upstream.Close()
+		})
+	}
+
+	idle := time.AfterFunc(idleTimeout, func() {
+		idleMu.Lock()
+		idled = true
+		idleMu.Unlock()
+		closeBoth()
+	})
+	defer idle.Stop()
+
+	stop := context.AfterFunc(ctx, closeBoth)
+	defer stop()
+
+	done := make(chan struct{}, 2)
+	pump := func(dst io.Writer, src io.Reader) {
+		_, _ = io.Copy(dst, &activityReader{r: src, onRead: func() { idle.Reset(idleTimeout) }})
+		done <- struct{}{}
+	}
+
+	// Read the caller's side through brw so bytes it sent right after the
+	// handshake, already buffered by the server, are not lost.
+	go pump(upstream, brw.Reader)
+	go pump(downstream, upstream)
+
+	<-done
+	closeBoth()
+	<-done
+
+	idleMu.Lock()
+	defer idleMu.Unlock()
+	return idled
+}
+
+// activityReader calls onRead after every read that returns data, so the
+// idle timer only fires when neither direction is moving bytes.
+type activityReader struct {
+	r      io.Reader
+	onRead func()
+}
+
+func (a *activityReader) Read(p []byte) (int, error) {
+	n, err := a.r.Read(p)
+	if n > 0 {
+		a.onRead()
+	}
+	return n, err
+}
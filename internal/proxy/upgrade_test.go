@@ -0,0 +1,179 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rmorlok/authproxy/internal/apid"
+	"github.com/rmorlok/authproxy/internal/core/iface"
+	mockCore "github.com/rmorlok/authproxy/internal/core/mock"
+	"github.com/rmorlok/authproxy/internal/httpf"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type connectionWithIdleTimeout struct {
+	*mockCore.Connection
+	idle time.Duration
+}
+
+func (c *connectionWithIdleTimeout) GetUpgradeIdleTimeout() time.Duration { return c.idle }
+
+// upgradeEchoUpstream accepts a websocket upgrade and then echoes each line
+// back upper-cased until the connection closes.
+type upgradeEchoUpstream struct {
+	*httptest.Server
+
+	mu    sync.Mutex
+	token string
+	auth  string
+}
+
+func newUpgradeEchoUpstream(t *testing.T) *upgradeEchoUpstream {
+	u := &upgradeEchoUpstream{}
+	u.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u.mu.Lock()
+		u.token = r.URL.Query().Get("token")
+		u.auth = r.Header.Get("Authorization")
+		u.mu.Unlock()
+
+		if upgradeType(r.Header) != "websocket" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		conn, brw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_, _ = brw.WriteString("HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: websocket\r\nSec-WebSocket-Accept: accepted\r\n\r\n")
+		_ = brw.Flush()
+		for {
+			line, err := brw.ReadString('\n')
+			if err != nil {
+				return
+			}
+			_, _ = brw.WriteString(strings.ToUpper(line))
+			_ = brw.Flush()
+		}
+	}))
+	t.Cleanup(u.Close)
+	return u
+}
+
+// newUpgradeDownstream serves the proxy the way the /_proxyRaw route does,
+// so the caller side of the session is a real hijackable connection.
+func newUpgradeDownstream(t *testing.T, p iface.Proxy, upstreamURL string) (*httptest.Server, <-chan error) {
+	errs := make(chan error, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		outbound, err := http.NewRequestWithContext(r.Context(), http.MethodGet, upstreamURL, nil)
+		require.NoError(t, err)
+		outbound.Header.Set("Connection", "Upgrade")
+		outbound.Header.Set("Upgrade", r.Header.Get("Upgrade"))
+		errs <- p.ProxyRequestRaw(r.Context(), httpf.RequestTypeProxy, &iface.RawProxyRequest{Outbound: outbound}, w)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, errs
+}
+
+func dialUpgrade(t *testing.T, srv *httptest.Server) (net.Conn, *bufio.Reader, *http.Response) {
+	t.Helper()
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	_, err = io.WriteString(conn, "GET /socket HTTP/1.1\r\nHost: proxy.local\r\nConnection: Upgrade\r\nUpgrade: websocket\r\n\r\n")
+	require.NoError(t, err)
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	require.NoError(t, err)
+	return conn, br, resp
+}
+
+func TestProxyRequestRaw_Upgrade(t *testing.T) {
+	upstream := newUpgradeEchoUpstream(t)
+	auth := &fakeAuth{
+		headers: map[string]string{"Authorization": "Bearer ws"},
+		query:   map[string]string{"token": "query-secret"},
+	}
+	conn := &mockCore.Connection{Id: apid.New(apid.PrefixConnection), Namespace: "root"}
+	p := New(&stubHttpf{client: upstream.Client()}, conn, auth, nil)
+	downstream, errs := newUpgradeDownstream(t, p, upstream.URL+"/socket")
+
+	client, br, resp := dialUpgrade(t, downstream)
+	require.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode)
+	assert.Equal(t, "websocket", resp.Header.Get("Upgrade"))
+	assert.Equal(t, "accepted", resp.Header.Get("Sec-WebSocket-Accept"))
+
+	upstream.mu.Lock()
+	assert.Equal(t, "query-secret", upstream.token, "query-param credentials must be applied to the handshake")
+	assert.Equal(t, "Bearer ws", upstream.auth)
+	upstream.mu.Unlock()
+
+	for _, msg := range []string{"hello\n", "again\n"} {
+		_, err := io.WriteString(client, msg)
+		require.NoError(t, err)
+		line, err := br.ReadString('\n')
+		require.NoError(t, err)
+		assert.Equal(t, strings.ToUpper(msg), line)
+	}
+
+	require.NoError(t, client.Close())
+	select {
+	case err := <-errs:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("session did not end after the caller closed")
+	}
+}
+
+func TestProxyRequestRaw_UpgradeIdleTimeout(t *testing.T) {
+	upstream := newUpgradeEchoUpstream(t)
+	conn := &connectionWithIdleTimeout{
+		Connection: &mockCore.Connection{Id: apid.New(apid.PrefixConnection), Namespace: "root"},
+		idle:       100 * time.Millisecond,
+	}
+	p := New(&stubHttpf{client: upstream.Client()}, conn, &fakeAuth{}, nil)
+	downstream, errs := newUpgradeDownstream(t, p, upstream.URL+"/socket")
+
+	client, br, resp := dialUpgrade(t, downstream)
+	require.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode)
+
+	require.NoError(t, client.SetReadDeadline(time.Now().Add(5*time.Second)))
+	_, err := br.ReadByte()
+	require.ErrorIs(t, err, io.EOF, "idle session must be closed by the proxy")
+
+	select {
+	case err := <-errs:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("proxy did not return after the idle timeout")
+	}
+}
+
+func TestProxyRequestRaw_UpgradeRefused(t *testing.T) {
+	upstream := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte("no sockets for you"))
+	})
+	p, srv := newRawTestProxy(t, upstream, &fakeAuth{})
+
+	outbound, err := http.NewRequest(http.MethodGet, srv.URL+"/socket", nil)
+	require.NoError(t, err)
+	outbound.Header.Set("Connection", "Upgrade")
+	outbound.Header.Set("Upgrade", "websocket")
+
+	rec := newRecordingResponseWriter()
+	require.NoError(t, p.ProxyRequestRaw(context.Background(), httpf.RequestTypeProxy, &iface.RawProxyRequest{Outbound: outbound}, rec))
+	assert.Equal(t, http.StatusForbidden, rec.status())
+	assert.Equal(t, "no sockets for you", rec.snapshot())
+}
@@ -141,6 +141,13 @@ func resolveDimension(name string, ctx *RequestContext) string {
 		return ctx.Namespace
 	case rlschema.DimensionMethod:
 		return ctx.Method
+	case rlschema.DimensionGraphqlOperation:
+		// Anonymous operations and non-GraphQL requests share the ""
+		// bucket.
+		if ctx.GraphqlOperation == nil {
+			return ""
+		}
+		return ctx.GraphqlOperation.Name
 	}
 	if strings.HasPrefix(name, rlschema.LabelDimensionPrefix) {
 		key := strings.TrimPrefix(name, rlschema.LabelDimensionPrefix)
@@ -3,6 +3,7 @@ package ratelimit
 import (
 	"testing"
 
+	"github.com/rmorlok/authproxy/internal/apgraphql"
 	"github.com/rmorlok/authproxy/internal/apid"
 	rlschema "github.com/rmorlok/authproxy/internal/schema/resources/rate_limit"
 	"github.com/stretchr/testify/require"
@@ -118,6 +119,24 @@ func TestResolveBucketKey_ReservedDimensions(t *testing.T) {
 	)
 }
 
+func TestResolveBucketKey_GraphqlOperation(t *testing.T) {
+	rule := rlschema.RateLimit{Bucket: rlschema.Bucket{
+		Dimensions: []string{rlschema.DimensionConnection, rlschema.DimensionGraphqlOperation},
+	}}
+
+	ctx := &RequestContext{
+		ConnectionID:     apid.ID("cxn_1"),
+		GraphqlOperation: &apgraphql.Operation{Type: apgraphql.OperationTypeQuery, Name: "GetProducts"},
+	}
+	require.Equal(t, "connection=cxn_1|graphql_operation=GetProducts", ResolveBucketKey(rule, ctx).String())
+
+	// Anonymous operations and non-GraphQL requests share the empty bucket.
+	ctx.GraphqlOperation = &apgraphql.Operation{Type: apgraphql.OperationTypeQuery}
+	require.Equal(t, "connection=cxn_1|graphql_operation=", ResolveBucketKey(rule, ctx).String())
+	ctx.GraphqlOperation = nil
+	require.Equal(t, "connection=cxn_1|graphql_operation=", ResolveBucketKey(rule, ctx).String())
+}
+
 func TestResolveBucketKey_LabelDimensions(t *testing.T) {
 	rule := rlschema.RateLimit{Bucket: rlschema.Bucket{
 		Dimensions: []string{"labels/team", "labels/region"},
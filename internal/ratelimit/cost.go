@@ -0,0 +1,121 @@
+package ratelimit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"strings"
+)
+
+// maxCostResponseBytes bounds how much of a response the enforcer buffers
+// to read a reported cost. Larger responses pass through uncharged.
+const maxCostResponseBytes = 8 << 20
+
+// chargeCost debits, for each admitted rule with a Cost, whatever the
+// upstream reported the request cost beyond the one unit Decide already
+// charged. The response body is read to find the cost and then restored,
+// so callers downstream see it unchanged.
+func (rt *EnforcerRoundTripper) chargeCost(ctx context.Context, matched []matchedRule, resp *http.Response) {
+	var costed []*matchedRule
+	for i := range matched {
+		m := &matched[i]
+		// Rules that rejected, or failed open, never charged the first
+		// unit, so there is nothing to top up.
+		if m.rule.Definition.Cost == nil || m.limiter == nil || !m.decision.Allowed || m.decision.FailedOpen {
+			continue
+		}
+		costed = append(costed, m)
+	}
+	if len(costed) == 0 {
+		return
+	}
+
+	body, ok := peekJSONBody(resp)
+	if !ok {
+		return
+	}
+
+	for _, m := range costed {
+		cost, ok := responseCost(body, m.rule.Definition.Cost.Paths())
+		if !ok {
+			continue
+		}
+		extra := int(math.Ceil(cost)) - 1
+		if extra <= 0 {
+			continue
+		}
+		charger, ok := m.limiter.(Charger)
+		if !ok {
+			continue
+		}
+		if err := charger.Charge(ctx, m.bucket, extra); err != nil {
+			rt.logger.WarnContext(ctx, "rate-limit cost charge failed",
+				slog.String("rule_id", string(m.rule.Id)),
+				slog.Float64("cost", cost),
+				slog.String("error", err.Error()),
+			)
+			continue
+		}
+		m.charged = extra
+	}
+}
+
+// peekJSONBody decodes a JSON response body without consuming it: resp.Body
+// is replaced with one that replays what was read. Returns false for
+// non-JSON, encoded or oversized bodies.
+func peekJSONBody(resp *http.Response) (any, bool) {
+	if resp == nil || resp.Body == nil || resp.Body == http.NoBody {
+		return nil, false
+	}
+	if !strings.Contains(resp.Header.Get("Content-Type"), "json") {
+		return nil, false
+	}
+	if enc := resp.Header.Get("Content-Encoding"); enc != "" && enc != "identity" {
+		return nil, false
+	}
+
+	original := resp.Body
+	buf, err := io.ReadAll(io.LimitReader(original, maxCostResponseBytes+1))
+	if err != nil || len(buf) > maxCostResponseBytes {
+		// Hand back what was read followed by whatever is left, so a
+		// read error still surfaces to the caller where it happened.
+		resp.Body = replayBody{Reader: io.MultiReader(bytes.NewReader(buf), original), Closer: original}
+		return nil, false
+	}
+	resp.Body = replayBody{Reader: bytes.NewReader(buf), Closer: original}
+
+	var body any
+	if err := json.Unmarshal(buf, &body); err != nil {
+		return nil, false
+	}
+	return body, true
+}
+
+type replayBody struct {
+	io.Reader
+	io.Closer
+}
+
+// responseCost returns the number at the first of paths present in body.
+// Paths are dot-separated object keys, e.g. "extensions.cost.actualQueryCost".
+func responseCost(body any, paths []string) (float64, bool) {
+	for _, p := range paths {
+		v := body
+		for _, key := range strings.Split(p, ".") {
+			obj, ok := v.(map[string]any)
+			if !ok {
+				v = nil
+				break
+			}
+			v = obj[key]
+		}
+		if n, ok := v.(float64); ok {
+			return n, true
+		}
+	}
+	return 0, false
+}
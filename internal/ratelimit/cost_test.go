@@ -0,0 +1,227 @@
+package ratelimit
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/rmorlok/authproxy/internal/apgraphql"
+	"github.com/rmorlok/authproxy/internal/apid"
+	"github.com/rmorlok/authproxy/internal/app_metrics"
+	rlschema "github.com/rmorlok/authproxy/internal/schema/resources/rate_limit"
+	"github.com/stretchr/testify/require"
+)
+
+// jsonTransport answers every request with body as application/json.
+type jsonTransport struct {
+	body  string
+	calls int
+}
+
+func (j *jsonTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	j.calls++
+	return &http.Response{
+		StatusCode:    http.StatusOK,
+		Header:        http.Header{"Content-Type": {"application/json; charset=utf-8"}},
+		Body:          io.NopCloser(strings.NewReader(j.body)),
+		ContentLength: int64(len(j.body)),
+	}, nil
+}
+
+func graphqlCostDef(cost *rlschema.Cost) rlschema.RateLimit {
+	return rlschema.RateLimit{
+		Selector: rlschema.Selector{Graphql: &rlschema.GraphqlMatch{}},
+		Bucket:   rlschema.Bucket{Dimensions: []string{rlschema.DimensionConnection}},
+		Algorithm: rlschema.Algorithm{
+			TokenBucket: &rlschema.TokenBucket{Capacity: 100, RefillRate: 1},
+		},
+		Cost: cost,
+	}
+}
+
+func TestEnforcer_GraphqlCostChargedFromResponse(t *testing.T) {
+	env := newEnforcerEnv(t)
+	env.loadRules(mkEnfRule("rl_shopify", graphqlCostDef(&rlschema.Cost{Provider: rlschema.CostProviderShopify})))
+
+	const body = `{"data":{"shop":{"name":"Acme"}},"extensions":{"cost":{"requestedQueryCost":52,"actualQueryCost":50,"throttleStatus":{"maximumAvailable":1000,"currentlyAvailable":950,"restoreRate":50}}}}`
+	upstream := &jsonTransport{body: body}
+	rt := newEnforcer(t, env, proxyRI("cxn_a", "act_a"), upstream)
+
+	ctx, attr := env.ctxWithAttr()
+	ctx = apgraphql.ContextWithOperation(ctx, &apgraphql.Operation{Type: apgraphql.OperationTypeQuery, Name: "GetShop"})
+
+	// 100 tokens; each call costs 50, so the bucket is empty after two.
+	for i := 0; i < 2; i++ {
+		resp, err := rt.RoundTrip(mkProxyReq(t, ctx, "https://acme.myshopify.com/admin/api/graphql.json"))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		// The body the enforcer read to find the cost is handed on intact.
+		got, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		require.Equal(t, body, string(got))
+	}
+	require.Equal(t, apid.ID("rl_shopify"), attr.RateLimitMatched[0].Id)
+
+	resp, err := rt.RoundTrip(mkProxyReq(t, ctx, "https://acme.myshopify.com/admin/api/graphql.json"))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	require.Equal(t, 2, upstream.calls)
+}
+
+func TestEnforcer_GraphqlSelectorSkipsOtherRequests(t *testing.T) {
+	env := newEnforcerEnv(t)
+	def := graphqlCostDef(nil)
+	def.Algorithm.TokenBucket.Capacity = 1
+	env.loadRules(mkEnfRule("rl_graphql", def))
+
+	upstream := &jsonTransport{body: `{}`}
+	rt := newEnforcer(t, env, proxyRI("cxn_a", "act_a"), upstream)
+
+	// Requests not identified as GraphQL aren't governed by the rule.
+	for i := 0; i < 3; i++ {
+		ctx, attr := env.ctxWithAttr()
+		resp, err := rt.RoundTrip(mkProxyReq(t, ctx, "https://api.example.com/graphql"))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Empty(t, attr.RateLimitMatched)
+	}
+}
+
+func TestEnforcer_CostMissingFromResponseChargesOneUnit(t *testing.T) {
+	env := newEnforcerEnv(t)
+	def := graphqlCostDef(&rlschema.Cost{Provider: rlschema.CostProviderGithub})
+	def.Algorithm.TokenBucket.Capacity = 2
+	env.loadRules(mkEnfRule("rl_github", def))
+
+	// The query didn't select rateLimit, so GitHub reported no cost.
+	upstream := &jsonTransport{body: `{"data":{"viewer":{"login":"octocat"}}}`}
+	rt := newEnforcer(t, env, proxyRI("cxn_a", "act_a"), upstream)
+	ctx := apgraphql.ContextWithOperation(env.ctx(), &apgraphql.Operation{Type: apgraphql.OperationTypeQuery})
+
+	for i := 0; i < 2; i++ {
+		resp, err := rt.RoundTrip(mkProxyReq(t, ctx, "https://api.github.com/graphql"))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+	resp, err := rt.RoundTrip(mkProxyReq(t, ctx, "https://api.github.com/graphql"))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+}
+
+func TestResponseCost(t *testing.T) {
+	body := map[string]any{
+		"data": map[string]any{"rateLimit": map[string]any{"cost": float64(3), "remaining": float64(4997)}},
+		"extensions": map[string]any{
+			"cost": map[string]any{"requestedQueryCost": float64(12)},
+		},
+	}
+
+	cost, ok := responseCost(body, (&rlschema.Cost{Provider: rlschema.CostProviderGithub}).Paths())
+	require.True(t, ok)
+	require.Equal(t, float64(3), cost)
+
+	// Shopify falls back to the requested cost when the actual cost is absent.
+	cost, ok = responseCost(body, (&rlschema.Cost{Provider: rlschema.CostProviderShopify}).Paths())
+	require.True(t, ok)
+	require.Equal(t, float64(12), cost)
+
+	_, ok = responseCost(body, []string{"data.rateLimit"})
+	require.False(t, ok, "non-numeric values are not a cost")
+	_, ok = responseCost(body, []string{"data.rateLimit.cost.value"})
+	require.False(t, ok)
+	_, ok = responseCost([]any{float64(1)}, []string{"cost"})
+	require.False(t, ok)
+}
+
+type errorAfterReader struct {
+	data []byte
+	err  error
+}
+
+func (r *errorAfterReader) Read(p []byte) (int, error) {
+	if len(r.data) == 0 {
+		return 0, r.err
+	}
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+func TestPeekJSONBody(t *testing.T) {
+	mkResp := func(contentType string, body io.Reader) *http.Response {
+		return &http.Response{Header: http.Header{"Content-Type": {contentType}}, Body: io.NopCloser(body)}
+	}
+
+	resp := mkResp("application/json", strings.NewReader(`{"a":1}`))
+	v, ok := peekJSONBody(resp)
+	require.True(t, ok)
+	require.Equal(t, map[string]any{"a": float64(1)}, v)
+	got, _ := io.ReadAll(resp.Body)
+	require.Equal(t, `{"a":1}`, string(got))
+
+	_, ok = peekJSONBody(mkResp("text/html", strings.NewReader(`{"a":1}`)))
+	require.False(t, ok)
+
+	gzipped := mkResp("application/json", strings.NewReader("\x1f\x8b"))
+	gzipped.Header.Set("Content-Encoding", "gzip")
+	_, ok = peekJSONBody(gzipped)
+	require.False(t, ok)
+
+	// An oversized body is passed through whole without being decoded.
+	big := bytes.Repeat([]byte("x"), maxCostResponseBytes+10)
+	resp = mkResp("application/json", bytes.NewReader(big))
+	_, ok = peekJSONBody(resp)
+	require.False(t, ok)
+	got, _ = io.ReadAll(resp.Body)
+	require.Len(t, got, len(big))
+
+	// A read error still reaches the caller after the bytes read before it.
+	boom := errors.New("connection reset")
+	resp = mkResp("application/json", &errorAfterReader{data: []byte(`{"a":`), err: boom})
+	_, ok = peekJSONBody(resp)
+	require.False(t, ok)
+	got, err := io.ReadAll(resp.Body)
+	require.ErrorIs(t, err, boom)
+	require.Equal(t, `{"a":`, string(got))
+}
+
+func TestEnforcer_QuotaUsedAttributed(t *testing.T) {
+	env := newEnforcerEnv(t)
+	plain := rlschema.RateLimit{
+		Bucket:    rlschema.Bucket{Dimensions: []string{rlschema.DimensionConnection}},
+		Algorithm: rlschema.Algorithm{Quota: &rlschema.Quota{Period: rlschema.QuotaPeriodMonth, Limit: 1}},
+	}
+	costed := graphqlCostDef(&rlschema.Cost{Provider: rlschema.CostProviderShopify})
+	costed.Algorithm = rlschema.Algorithm{Quota: &rlschema.Quota{Period: rlschema.QuotaPeriodMonth, Limit: 1000}}
+	env.loadRules(mkEnfRule("rl_plain", plain), mkEnfRule("rl_costed", costed))
+
+	upstream := &jsonTransport{body: `{"data":{},"extensions":{"cost":{"actualQueryCost":50}}}`}
+	rt := newEnforcer(t, env, proxyRI("cxn_a", "act_a"), upstream)
+	quotaUsed := func(attrMatched []app_metrics.RateLimitMatch) map[apid.ID]int {
+		out := make(map[apid.ID]int, len(attrMatched))
+		for _, m := range attrMatched {
+			out[m.Id] = m.QuotaUsed
+		}
+		return out
+	}
+
+	ctx, attr := env.ctxWithAttr()
+	ctx = apgraphql.ContextWithOperation(ctx, &apgraphql.Operation{Type: apgraphql.OperationTypeQuery})
+	resp, err := rt.RoundTrip(mkProxyReq(t, ctx, "https://acme.myshopify.com/admin/api/graphql.json"))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, map[apid.ID]int{"rl_plain": 1, "rl_costed": 50}, quotaUsed(attr.RateLimitMatched))
+
+	// The exhausted rule consumes nothing. The other still counted the unit
+	// its Decide took, but no response came back to charge the rest.
+	ctx, attr = env.ctxWithAttr()
+	ctx = apgraphql.ContextWithOperation(ctx, &apgraphql.Operation{Type: apgraphql.OperationTypeQuery})
+	resp, err = rt.RoundTrip(mkProxyReq(t, ctx, "https://acme.myshopify.com/admin/api/graphql.json"))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	require.Equal(t, map[apid.ID]int{"rl_plain": 0, "rl_costed": 1}, quotaUsed(attr.RateLimitMatched))
+}
@@ -11,6 +11,8 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/rmorlok/authproxy/internal/apctx"
+	"github.com/rmorlok/authproxy/internal/apgraphql"
 	"github.com/rmorlok/authproxy/internal/apid"
 	"github.com/rmorlok/authproxy/internal/app_metrics"
 	"github.com/rmorlok/authproxy/internal/apredis"
@@ -66,16 +68,25 @@ func (f *EnforcerFactory) NewRoundTripper(ri httpf.RequestInfo, transport http.R
 // the upstream call. For each cached rule that matches the request:
 //
 //  1. The rule's algorithm is checked / incremented via Limiter.Decide.
-//  2. Enforce-mode rejections are collected; the one with the longest
-//     Retry-After wins (most-restrictive). On any enforce rejection the
+//     Shape-mode rules are evaluated first and hold the request in a
+//     per-bucket queue until they admit it (see waitForAdmission).
+//  2. Enforce-mode rejections, and shape-mode rules that couldn't admit
+//     the request in time, are collected; the one with the longest
+//     Retry-After wins (most-restrictive). On any rejection the
 //     round-tripper short-circuits with a synthetic 429.
 //  3. Observe-mode matches also call Decide() so observe counters stay
 //     hot — flipping a rule from observe to enforce shouldn't reset its
 //     bucket. Their decisions don't reject anything.
+//  4. Once the upstream responds, rules with a Cost charge their buckets
+//     whatever the response says the request cost beyond the one unit
+//     Decide took (see chargeCost).
+//  5. Concurrency rules' leases — observe-mode ones included — are held
+//     until the response body is closed, then released. A rejected or
+//     failed request releases them immediately.
 //
-// Every match (enforce or observe) is recorded on the request-event
-// Attribution so the firing rule, all matched rules, and the resolved
-// bucket are visible to operators downstream.
+// Every match (enforce, shape or observe) is recorded on the request-event
+// Attribution so the firing rule, all matched rules, the resolved bucket
+// and any time spent queued are visible to operators downstream.
 //
 // Coexistence with the connector-level reactive limiter (Factory in this
 // package): both run in the middleware chain. The enforcer runs before
@@ -104,35 +115,54 @@ func (rt *EnforcerRoundTripper) RoundTrip(req *http.Request) (*http.Response, er
 		return rt.transport.RoundTrip(req)
 	}
 
-	// Execute Decide for each match. Failures fail open inside the
-	// Limiter; here we just collect what each one returned and look for
-	// rejections.
+	// Shape-mode rules go first: the request waits in their queues until
+	// each admits it, so the remaining rules only count requests that are
+	// going ahead. One that can't admit it in time fires like an enforce
+	// rule.
 	var firing *matchedRule
-	matchedSet := make([]app_metrics.RateLimitMatch, 0, len(matched))
 
 	for i := range matched {
 		m := &matched[i]
-		limiter, err := NewLimiter(m.rule, rt.redis, rt.logger)
-		if err != nil {
-			rt.logger.WarnContext(ctx, "rate-limit limiter construction failed; skipping rule",
-				slog.String("rule_id", string(m.rule.Id)),
-				slog.String("error", err.Error()),
-			)
+		if m.effectiveMode != rlschema.ModeShape || !rt.newLimiter(ctx, m) {
+			continue
+		}
+		if m.rule.Definition.Shaping == nil {
+			// Validation requires Shaping in shape mode; without it there
+			// is no queue to wait in, so reject as enforce would.
+			m.decision, _ = m.limiter.Decide(ctx, m.bucket)
+		} else {
+			admitted, waited, err := rt.waitForAdmission(ctx, m, string(reqCtx.ActorID))
+			m.queueWait = waited
+			if err != nil {
+				// The caller gave up while the request was queued.
+				rt.attribute(ctx, matched, nil)
+				if leases := rt.leasesFor(ctx, matched); leases != nil {
+					leases.release()
+				}
+				return nil, err
+			}
+			m.decision.Allowed = admitted
+		}
+		if !m.decision.Allowed && (firing == nil || m.decision.RetryAfter > firing.decision.RetryAfter) {
+			firing = m
+		}
+	}
+
+	// Execute Decide for each remaining match. Failures fail open inside
+	// the Limiter; here we just collect what each one returned and look
+	// for rejections.
+	for i := range matched {
+		m := &matched[i]
+		if m.effectiveMode == rlschema.ModeShape || !rt.newLimiter(ctx, m) {
 			continue
 		}
 
-		decision, decideErr := limiter.Decide(ctx, m.bucket)
+		decision, decideErr := m.limiter.Decide(ctx, m.bucket)
 		// Limiter.Decide already fail-opens internally on Redis errors,
 		// so a non-nil error is informational. We still continue
 		// processing other rules.
 		_ = decideErr
-
 		m.decision = decision
-		matchedSet = append(matchedSet, app_metrics.RateLimitMatch{
-			Id:     m.rule.Id,
-			Mode:   string(m.effectiveMode),
-			Bucket: m.bucket.AsMap(),
-		})
 
 		// Only enforce-mode rejections can fire — observe-mode rules
 		// keep their counters hot but never reject.
@@ -143,41 +173,116 @@ func (rt *EnforcerRoundTripper) RoundTrip(req *http.Request) (*http.Response, er
 		}
 	}
 
-	// Stamp the request-event Attribution with the firing rule (if any)
-	// plus the full match set. When only observe rules matched, the
-	// top-level RateLimit* fields are still populated (with the first
-	// observe match) so a single log filter on rate_limit_id catches
-	// both enforce-rejected and observe-only entries.
-	if attr := app_metrics.AttributionFromContext(ctx); attr != nil {
-		attr.RateLimitMatched = matchedSet
-		switch {
-		case firing != nil:
-			attr.Source = app_metrics.ResponseSourceRateLimit
-			attr.RateLimitId = firing.rule.Id
-			attr.RateLimitMode = string(rlschema.ModeEnforce)
-			attr.RateLimitBucket = firing.bucket.AsMap()
-		default:
-			for i := range matched {
-				if matched[i].effectiveMode == rlschema.ModeObserve {
-					attr.RateLimitId = matched[i].rule.Id
-					attr.RateLimitMode = string(rlschema.ModeObserve)
-					attr.RateLimitBucket = matched[i].bucket.AsMap()
-					break
-				}
-			}
-		}
-	}
+	matchedSet := rt.attribute(ctx, matched, firing)
+
+	// Concurrency rules hold a lease per admitted request; they are given
+	// back when the request completes, or straight away if it never runs.
+	leases := rt.leasesFor(ctx, matched)
 
 	if firing != nil {
+		if leases != nil {
+			leases.release()
+		}
 		rt.logger.InfoContext(ctx, "request rejected by rate-limit resource",
 			slog.String("rule_id", string(firing.rule.Id)),
+			slog.String("mode", string(firing.effectiveMode)),
 			slog.Duration("retry_after", firing.decision.RetryAfter),
+			slog.Duration("queue_wait", totalQueueWait(matched)),
 			slog.Int("matched_rules", len(matchedSet)),
 		)
 		return rt.syntheticTooManyRequests(firing.rule.Id, firing.decision.RetryAfter), nil
 	}
 
-	return rt.transport.RoundTrip(req)
+	resp, err := rt.transport.RoundTrip(req)
+	if err != nil {
+		if leases != nil {
+			leases.release()
+		}
+		return resp, err
+	}
+
+	rt.chargeCost(ctx, matched, resp)
+	rt.attributeQuotaUsed(ctx, matched)
+	if leases != nil {
+		leases.holdUntilClosed(resp)
+	}
+	return resp, nil
+}
+
+// newLimiter builds m's Limiter, logging and returning false if the rule's
+// algorithm can't be built.
+func (rt *EnforcerRoundTripper) newLimiter(ctx context.Context, m *matchedRule) bool {
+	limiter, err := NewPriorityLimiter(m.rule, m.priority, rt.redis, rt.logger)
+	if err != nil {
+		rt.logger.WarnContext(ctx, "rate-limit limiter construction failed; skipping rule",
+			slog.String("rule_id", string(m.rule.Id)),
+			slog.String("error", err.Error()),
+		)
+		return false
+	}
+	m.limiter = limiter
+	return true
+}
+
+// totalQueueWait is how long shape-mode rules held the request.
+func totalQueueWait(matched []matchedRule) time.Duration {
+	var total time.Duration
+	for i := range matched {
+		total += matched[i].queueWait
+	}
+	return total
+}
+
+// attribute stamps the request-event Attribution with the firing rule (if
+// any), the full match set and the total queue wait, and returns the
+// match set. When nothing fired, the top-level RateLimit* fields are still
+// populated — with the first shape rule that held the request, else the
+// first observe match — so a single log filter on rate_limit_id catches
+// rejected, queued and observe-only entries alike.
+func (rt *EnforcerRoundTripper) attribute(ctx context.Context, matched []matchedRule, firing *matchedRule) []app_metrics.RateLimitMatch {
+	matchedSet := make([]app_metrics.RateLimitMatch, 0, len(matched))
+	for i := range matched {
+		if matched[i].limiter == nil {
+			continue
+		}
+		matchedSet = append(matchedSet, app_metrics.RateLimitMatch{
+			Id:        matched[i].rule.Id,
+			Mode:      string(matched[i].effectiveMode),
+			Bucket:    matched[i].bucket.AsMap(),
+			QuotaUsed: matched[i].quotaUsed(),
+		})
+	}
+
+	attr := app_metrics.AttributionFromContext(ctx)
+	if attr == nil {
+		return matchedSet
+	}
+	attr.RateLimitMatched = matchedSet
+	attr.RateLimitQueueWait = totalQueueWait(matched)
+
+	stamp := func(m *matchedRule) {
+		attr.RateLimitId = m.rule.Id
+		attr.RateLimitMode = string(m.effectiveMode)
+		attr.RateLimitBucket = m.bucket.AsMap()
+	}
+	if firing != nil {
+		attr.Source = app_metrics.ResponseSourceRateLimit
+		stamp(firing)
+		return matchedSet
+	}
+	for i := range matched {
+		if matched[i].queueWait > 0 {
+			stamp(&matched[i])
+			return matchedSet
+		}
+	}
+	for i := range matched {
+		if matched[i].effectiveMode == rlschema.ModeObserve && matched[i].limiter != nil {
+			stamp(&matched[i])
+			break
+		}
+	}
+	return matchedSet
 }
 
 // matchedRule pairs a rule with its match-time outputs so the per-rule
@@ -187,7 +292,46 @@ type matchedRule struct {
 	rule          *database.RateLimit
 	effectiveMode rlschema.Mode
 	bucket        BucketKey
+	limiter       Limiter
 	decision      Decision
+
+	// priority is the request's priority class, which decides how much of
+	// the rule's capacity reserved for other classes the limiter holds back.
+	priority common.RequestPriority
+
+	// queueWait is how long a shape-mode rule held the request.
+	queueWait time.Duration
+
+	// charged is what chargeCost debited beyond the unit Decide took.
+	charged int
+}
+
+// quotaUsed is how many units the request took from a quota rule's
+// allowance: the unit an allowed Decide counted plus any reported cost.
+func (m *matchedRule) quotaUsed() int {
+	if m.rule.Definition.Algorithm.Quota == nil || !m.decision.Allowed || m.decision.FailedOpen {
+		return 0
+	}
+	return 1 + m.charged
+}
+
+// attributeQuotaUsed updates the recorded match set once chargeCost has
+// charged quota rules for what the upstream reported.
+func (rt *EnforcerRoundTripper) attributeQuotaUsed(ctx context.Context, matched []matchedRule) {
+	attr := app_metrics.AttributionFromContext(ctx)
+	if attr == nil {
+		return
+	}
+	for i := range matched {
+		if matched[i].charged == 0 {
+			continue
+		}
+		for j := range attr.RateLimitMatched {
+			if attr.RateLimitMatched[j].Id == matched[i].rule.Id {
+				attr.RateLimitMatched[j].QuotaUsed = matched[i].quotaUsed()
+			}
+		}
+	}
 }
 
 // findMatches runs Match() over every cached rule and returns the
@@ -213,6 +357,7 @@ func (rt *EnforcerRoundTripper) findMatches(rules []*database.RateLimit, reqCtx
 			rule:          rule,
 			effectiveMode: rule.Definition.EffectiveMode(),
 			bucket:        bucket,
+			priority:      reqCtx.Priority,
 		})
 	}
 	return out
@@ -233,6 +378,8 @@ func (rt *EnforcerRoundTripper) buildRequestContext(req *http.Request) *RequestC
 		ConnectorID:      rt.ri.ConnectorId,
 		ConnectorVersion: rt.ri.ConnectorVersion,
 		Labels:           rt.ri.Labels,
+		GraphqlOperation: apgraphql.OperationFromContext(req.Context()),
+		Priority:         apctx.RequestPriority(req.Context()),
 	}
 	if rt.ri.Labels != nil {
 		if v, ok := rt.ri.Labels[actorIDLabelKey]; ok {
@@ -263,10 +410,10 @@ func (rt *EnforcerRoundTripper) syntheticTooManyRequests(ruleID apid.ID, retryAf
 	return &http.Response{
 		StatusCode: http.StatusTooManyRequests,
 		Header: http.Header{
-			"Content-Type":            {"application/json"},
-			"Retry-After":             {strconv.Itoa(retryAfterSeconds)},
-			"X-Authproxy-Ratelimited": {"true"},
-			"X-Authproxy-Ratelimit":   {string(ruleID)},
+			"Content-Type":          {"application/json"},
+			"Retry-After":           {strconv.Itoa(retryAfterSeconds)},
+			HeaderRateLimited:       {"true"},
+			"X-Authproxy-Ratelimit": {string(ruleID)},
 		},
 		Body:          io.NopCloser(bytes.NewBufferString(body)),
 		ContentLength: int64(len(body)),
@@ -0,0 +1,396 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/rmorlok/authproxy/internal/apid"
+	"github.com/rmorlok/authproxy/internal/schema/resources/connectors"
+)
+
+const (
+	headroomKeyPrefix = redisKeyPrefix + "headroom:"
+
+	// headroomWindowTolerance is how far a reported reset may drift later than
+	// the stored one and still be treated as the same window. Delta-second
+	// resets are truncated by the upstream, so consecutive responses from the
+	// same window routinely disagree by up to a second.
+	headroomWindowTolerance = 1500 * time.Millisecond
+
+	// unixSecondsThreshold separates delta seconds from Unix timestamps when
+	// the reset format is auto: no upstream window is anywhere near 30 years
+	// long, and no Unix timestamp in use is below it.
+	unixSecondsThreshold = 1_000_000_000
+)
+
+// Headroom is the request budget an upstream reported on its rate-limit
+// headers: how many requests remain in the current window and when the
+// window resets.
+type Headroom struct {
+	Remaining int64
+	// Limit is the total budget per window, or 0 when the upstream did not
+	// report one.
+	Limit   int64
+	ResetAt time.Time
+}
+
+func headroomKey(connectionID apid.ID) string {
+	return fmt.Sprintf("%s%s", headroomKeyPrefix, connectionID.String())
+}
+
+// ParseHeadroom reads the upstream's rate-limit headers according to cfg.
+// Returns false when the response doesn't carry both a remaining budget and
+// a reset time in the configured format.
+func ParseHeadroom(headers http.Header, cfg *connectors.RateLimitHeadroom, now time.Time) (Headroom, bool) {
+	if cfg.GetFormat() == connectors.RateLimitHeaderFormatIETF {
+		return parseIETFHeadroom(headers, now)
+	}
+	return parseXRateLimitHeadroom(headers, cfg, now)
+}
+
+func parseXRateLimitHeadroom(headers http.Header, cfg *connectors.RateLimitHeadroom, now time.Time) (Headroom, bool) {
+	remaining, ok := parseHeadroomInt(headers.Get(cfg.GetRemainingHeader()))
+	if !ok {
+		return Headroom{}, false
+	}
+
+	resetAt, ok := parseReset(strings.TrimSpace(headers.Get(cfg.GetResetHeader())), cfg.GetResetFormat(), now)
+	if !ok {
+		return Headroom{}, false
+	}
+
+	limit, _ := parseHeadroomInt(headers.Get(cfg.GetLimitHeader()))
+
+	return Headroom{Remaining: remaining, Limit: limit, ResetAt: resetAt}, true
+}
+
+// parseReset converts a reset header value to an absolute time. Besides
+// integer seconds, auto accepts fractional seconds, Go duration strings
+// ("1m30s", as sent by some AI providers), HTTP-dates and ISO 8601
+// timestamps.
+func parseReset(value string, format connectors.RateLimitResetFormat, now time.Time) (time.Time, bool) {
+	if value == "" {
+		return time.Time{}, false
+	}
+
+	seconds, numErr := strconv.ParseFloat(value, 64)
+	if numErr == nil && (seconds < 0 || math.IsNaN(seconds) || math.IsInf(seconds, 0)) {
+		return time.Time{}, false
+	}
+
+	switch format {
+	case connectors.RateLimitResetFormatDeltaSeconds:
+		if numErr != nil {
+			return time.Time{}, false
+		}
+		return now.Add(time.Duration(seconds * float64(time.Second))), true
+	case connectors.RateLimitResetFormatUnixSeconds:
+		if numErr != nil {
+			return time.Time{}, false
+		}
+		return time.UnixMilli(int64(seconds * 1000)), true
+	}
+
+	if numErr == nil {
+		if seconds >= unixSecondsThreshold {
+			return time.UnixMilli(int64(seconds * 1000)), true
+		}
+		return now.Add(time.Duration(seconds * float64(time.Second))), true
+	}
+
+	if d, err := time.ParseDuration(value); err == nil && d >= 0 {
+		return now.Add(d), true
+	}
+
+	if d, ok := parseAsHTTPDate(value, now); ok {
+		return now.Add(d), true
+	}
+
+	if d, ok := parseAsISO8601(value, now); ok {
+		return now.Add(d), true
+	}
+
+	return time.Time{}, false
+}
+
+// parseIETFHeadroom reads the IETF rate-limit headers. Three generations of
+// the draft are in use:
+//
+//	RateLimit: "default";r=50;t=30           (draft 08+, limit in RateLimit-Policy q=)
+//	RateLimit: limit=100, remaining=50, reset=30
+//	RateLimit-Remaining: 50 / RateLimit-Reset: 30 / RateLimit-Limit: 100
+//
+// When several policies are reported, the most constrained one wins.
+func parseIETFHeadroom(headers http.Header, now time.Time) (Headroom, bool) {
+	if v := headers.Get("RateLimit"); v != "" {
+		if h, ok := parseIETFRateLimitHeader(v, headers.Get("RateLimit-Policy"), now); ok {
+			return h, true
+		}
+	}
+
+	remaining, ok := parseHeadroomInt(headers.Get("RateLimit-Remaining"))
+	if !ok {
+		return Headroom{}, false
+	}
+	reset, ok := parseHeadroomInt(headers.Get("RateLimit-Reset"))
+	if !ok {
+		return Headroom{}, false
+	}
+	limit, _ := parseHeadroomInt(headers.Get("RateLimit-Limit"))
+
+	return Headroom{Remaining: remaining, Limit: limit, ResetAt: now.Add(time.Duration(reset) * time.Second)}, true
+}
+
+// ietfItem is one member of a structured-field list: an optional name
+// followed by key=value parameters.
+type ietfItem struct {
+	name   string
+	params map[string]string
+}
+
+func parseIETFList(value string) []ietfItem {
+	var items []ietfItem
+	for _, raw := range strings.Split(value, ",") {
+		item := ietfItem{params: map[string]string{}}
+		for i, part := range strings.Split(raw, ";") {
+			part = strings.TrimSpace(part)
+			if k, v, ok := strings.Cut(part, "="); ok {
+				item.params[strings.ToLower(strings.TrimSpace(k))] = strings.Trim(strings.TrimSpace(v), `"`)
+			} else if i == 0 {
+				item.name = strings.Trim(part, `"`)
+			}
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+func parseIETFRateLimitHeader(value, policy string, now time.Time) (Headroom, bool) {
+	items := parseIETFList(value)
+
+	// draft 08+: one item per policy
+	var best *Headroom
+	var bestName string
+	for _, item := range items {
+		remaining, ok := parseHeadroomInt(item.params["r"])
+		if !ok {
+			continue
+		}
+		reset, ok := parseHeadroomInt(item.params["t"])
+		if !ok {
+			continue
+		}
+		if best == nil || remaining < best.Remaining {
+			best = &Headroom{Remaining: remaining, ResetAt: now.Add(time.Duration(reset) * time.Second)}
+			bestName = item.name
+		}
+	}
+	if best != nil {
+		for _, p := range parseIETFList(policy) {
+			if p.name != bestName {
+				continue
+			}
+			if limit, ok := parseHeadroomInt(p.params["q"]); ok {
+				best.Limit = limit
+			}
+		}
+		return *best, true
+	}
+
+	// earlier drafts: a single dictionary
+	params := map[string]string{}
+	for _, item := range items {
+		for k, v := range item.params {
+			params[k] = v
+		}
+	}
+	remaining, ok := parseHeadroomInt(params["remaining"])
+	if !ok {
+		return Headroom{}, false
+	}
+	reset, ok := parseHeadroomInt(params["reset"])
+	if !ok {
+		return Headroom{}, false
+	}
+	limit, _ := parseHeadroomInt(params["limit"])
+
+	return Headroom{Remaining: remaining, Limit: limit, ResetAt: now.Add(time.Duration(reset) * time.Second)}, true
+}
+
+// parseHeadroomInt parses the leading integer of a header value. Older IETF
+// drafts append a quota policy to the limit ("100, 100;w=60"), so anything
+// after the first comma or semicolon is ignored. Negative values clamp to 0.
+func parseHeadroomInt(value string) (int64, bool) {
+	if i := strings.IndexAny(value, ",;"); i >= 0 {
+		value = value[:i]
+	}
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	if n < 0 {
+		n = 0
+	}
+	return n, true
+}
+
+// headroomRecordScript stores the headroom reported on a response. Within
+// the same window the lower remaining figure wins: responses can land out of
+// order, and requests admitted by headroomReserveScript may not be counted
+// by the upstream yet. A reset beyond the stored one (plus tolerance) starts
+// a new window and replaces the state.
+//
+// window_ms is the longest time to a reset seen so far, a lower bound on the
+// upstream's window length. The key outlives the reset by that long so
+// headroomReserveScript can roll the state into the next window.
+//
+// Returns the remaining figure now stored.
+var headroomRecordScript = redis.NewScript(`
+local key = KEYS[1]
+local remaining = tonumber(ARGV[1])
+local limit = tonumber(ARGV[2])
+local reset_ms = tonumber(ARGV[3])
+local now_ms = tonumber(ARGV[4])
+local tolerance_ms = tonumber(ARGV[5])
+
+local cur_remaining = redis.call('HGET', key, 'remaining')
+local cur_reset = tonumber(redis.call('HGET', key, 'reset_ms') or '0')
+if cur_remaining and cur_reset > now_ms and reset_ms <= cur_reset + tolerance_ms then
+    remaining = math.min(remaining, tonumber(cur_remaining))
+    reset_ms = math.max(reset_ms, cur_reset)
+end
+
+local window_ms = math.max(tonumber(redis.call('HGET', key, 'window_ms') or '0'), reset_ms - now_ms)
+
+redis.call('HSET', key, 'remaining', remaining, 'limit', limit, 'reset_ms', reset_ms, 'window_ms', window_ms)
+redis.call('PEXPIRE', key, math.max(reset_ms - now_ms + window_ms, 1))
+return remaining
+`)
+
+// headroomReserveScript spends one request of the learned headroom. Once the
+// window has reset, a known limit starts the next window at the full budget,
+// so requests held for the reset are still counted against it; without a
+// limit the state is dropped. Returns:
+//
+//	{1, -1}                when nothing is known, or the window has reset
+//	                       and the limit is unknown
+//	{1, remaining}         when allowed; remaining is what is left after this request
+//	{0, reset_after_ms}    when only the reserve is left
+var headroomReserveScript = redis.NewScript(`
+local key = KEYS[1]
+local reserve = tonumber(ARGV[1])
+local now_ms = tonumber(ARGV[2])
+
+local remaining = redis.call('HGET', key, 'remaining')
+if not remaining then
+    return {1, -1}
+end
+
+local reset_ms = tonumber(redis.call('HGET', key, 'reset_ms') or '0')
+if reset_ms <= now_ms then
+    local limit = tonumber(redis.call('HGET', key, 'limit') or '0')
+    local window_ms = tonumber(redis.call('HGET', key, 'window_ms') or '0')
+    if limit <= 0 or window_ms <= 0 then
+        redis.call('DEL', key)
+        return {1, -1}
+    end
+
+    reset_ms = reset_ms + window_ms * (math.floor((now_ms - reset_ms) / window_ms) + 1)
+    remaining = limit
+    redis.call('HSET', key, 'remaining', remaining, 'reset_ms', reset_ms)
+    redis.call('PEXPIRE', key, reset_ms - now_ms + window_ms)
+end
+
+remaining = tonumber(remaining)
+if remaining <= reserve then
+    return {0, reset_ms - now_ms}
+end
+
+redis.call('HINCRBY', key, 'remaining', -1)
+return {1, remaining - 1}
+`)
+
+// RecordHeadroom stores the headroom reported on an upstream response and
+// returns the headroom now tracked for the connection. Headroom whose reset
+// has already passed is not stored.
+func (s *Store) RecordHeadroom(ctx context.Context, connectionID apid.ID, h Headroom, now time.Time) (Headroom, error) {
+	if !h.ResetAt.After(now) {
+		return h, nil
+	}
+
+	remaining, err := headroomRecordScript.Run(
+		ctx,
+		s.r,
+		[]string{headroomKey(connectionID)},
+		h.Remaining,
+		h.Limit,
+		h.ResetAt.UnixMilli(),
+		now.UnixMilli(),
+		headroomWindowTolerance.Milliseconds(),
+	).Int64()
+	if err != nil {
+		return Headroom{}, err
+	}
+
+	h.Remaining = remaining
+	return h, nil
+}
+
+// ReserveHeadroom spends one request of the connection's learned headroom.
+// When the remaining budget is down to reserve, it returns false and how
+// long until the upstream window resets. Connections with nothing learned,
+// or whose window has reset without a known limit, are always allowed.
+func (s *Store) ReserveHeadroom(ctx context.Context, connectionID apid.ID, reserve int64, now time.Time) (time.Duration, bool, error) {
+	res, err := headroomReserveScript.Run(ctx, s.r, []string{headroomKey(connectionID)}, reserve, now.UnixMilli()).Result()
+	if err != nil {
+		return 0, false, err
+	}
+
+	allowed, value, err := parseDecisionResult(res)
+	if err != nil {
+		return 0, false, err
+	}
+	if allowed {
+		return 0, true, nil
+	}
+	return time.Duration(value) * time.Millisecond, false, nil
+}
+
+// GetHeadroom returns the headroom currently tracked for a connection, or
+// false when nothing has been learned for the current window.
+func (s *Store) GetHeadroom(ctx context.Context, connectionID apid.ID, now time.Time) (Headroom, bool, error) {
+	vals, err := s.r.HMGet(ctx, headroomKey(connectionID), "remaining", "limit", "reset_ms").Result()
+	if err != nil {
+		return Headroom{}, false, err
+	}
+
+	var fields [3]int64
+	for i, v := range vals {
+		str, ok := v.(string)
+		if !ok {
+			return Headroom{}, false, nil
+		}
+		n, err := strconv.ParseInt(str, 10, 64)
+		if err != nil {
+			return Headroom{}, false, fmt.Errorf("headroom field %d: %w", i, err)
+		}
+		fields[i] = n
+	}
+
+	h := Headroom{Remaining: fields[0], Limit: fields[1], ResetAt: time.UnixMilli(fields[2])}
+	if !h.ResetAt.After(now) {
+		return Headroom{}, false, nil
+	}
+	return h, true, nil
+}
@@ -0,0 +1,433 @@
+package ratelimit
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rmorlok/authproxy/internal/apctx"
+	"github.com/rmorlok/authproxy/internal/app_metrics"
+	"github.com/rmorlok/authproxy/internal/apid"
+	"github.com/rmorlok/authproxy/internal/aptelemetry"
+	"github.com/rmorlok/authproxy/internal/schema/common"
+	sconfig "github.com/rmorlok/authproxy/internal/schema/config"
+	"github.com/rmorlok/authproxy/internal/schema/resources/connectors"
+	"github.com/rmorlok/authproxy/internal/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	clock "k8s.io/utils/clock/testing"
+)
+
+var headroomNow = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func TestParseHeadroom_XRateLimit(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     *connectors.RateLimitHeadroom
+		headers http.Header
+		want    Headroom
+		wantOk  bool
+	}{
+		{
+			name: "delta seconds",
+			headers: http.Header{
+				"X-Ratelimit-Remaining": {"42"},
+				"X-Ratelimit-Reset":     {"30"},
+				"X-Ratelimit-Limit":     {"100"},
+			},
+			want:   Headroom{Remaining: 42, Limit: 100, ResetAt: headroomNow.Add(30 * time.Second)},
+			wantOk: true,
+		},
+		{
+			name: "unix seconds detected",
+			headers: http.Header{
+				"X-Ratelimit-Remaining": {"4999"},
+				"X-Ratelimit-Reset":     {"1767229200"},
+			},
+			want:   Headroom{Remaining: 4999, ResetAt: headroomNow.Add(time.Hour)},
+			wantOk: true,
+		},
+		{
+			name: "duration string",
+			headers: http.Header{
+				"X-Ratelimit-Remaining": {"10"},
+				"X-Ratelimit-Reset":     {"1m30s"},
+			},
+			want:   Headroom{Remaining: 10, ResetAt: headroomNow.Add(90 * time.Second)},
+			wantOk: true,
+		},
+		{
+			name: "custom headers",
+			cfg: &connectors.RateLimitHeadroom{
+				RemainingHeader: "X-Budget-Left",
+				ResetHeader:     "X-Budget-Reset",
+				ResetFormat:     connectors.RateLimitResetFormatDeltaSeconds,
+			},
+			headers: http.Header{
+				"X-Budget-Left":  {"3"},
+				"X-Budget-Reset": {"2000000000"},
+			},
+			want:   Headroom{Remaining: 3, ResetAt: headroomNow.Add(2000000000 * time.Second)},
+			wantOk: true,
+		},
+		{
+			name: "unix seconds format rejects dates",
+			cfg:  &connectors.RateLimitHeadroom{ResetFormat: connectors.RateLimitResetFormatUnixSeconds},
+			headers: http.Header{
+				"X-Ratelimit-Remaining": {"3"},
+				"X-Ratelimit-Reset":     {"2026-01-01T00:00:30Z"},
+			},
+		},
+		{
+			name: "negative remaining clamps to zero",
+			headers: http.Header{
+				"X-Ratelimit-Remaining": {"-2"},
+				"X-Ratelimit-Reset":     {"5"},
+			},
+			want:   Headroom{Remaining: 0, ResetAt: headroomNow.Add(5 * time.Second)},
+			wantOk: true,
+		},
+		{
+			name:    "missing reset",
+			headers: http.Header{"X-Ratelimit-Remaining": {"3"}},
+		},
+		{
+			name:    "missing remaining",
+			headers: http.Header{"X-Ratelimit-Reset": {"3"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ParseHeadroom(tt.headers, tt.cfg, headroomNow)
+			require.Equal(t, tt.wantOk, ok)
+			if ok {
+				assert.Equal(t, tt.want.Remaining, got.Remaining)
+				assert.Equal(t, tt.want.Limit, got.Limit)
+				assert.True(t, tt.want.ResetAt.Equal(got.ResetAt), "reset %s != %s", tt.want.ResetAt, got.ResetAt)
+			}
+		})
+	}
+}
+
+func TestParseHeadroom_IETF(t *testing.T) {
+	cfg := &connectors.RateLimitHeadroom{Format: connectors.RateLimitHeaderFormatIETF}
+
+	tests := []struct {
+		name    string
+		headers http.Header
+		want    Headroom
+		wantOk  bool
+	}{
+		{
+			name: "structured with policy",
+			headers: http.Header{
+				"Ratelimit":        {`"hour";r=900;t=1800, "minute";r=20;t=40`},
+				"Ratelimit-Policy": {`"hour";q=1000;w=3600, "minute";q=60;w=60`},
+			},
+			want:   Headroom{Remaining: 20, Limit: 60, ResetAt: headroomNow.Add(40 * time.Second)},
+			wantOk: true,
+		},
+		{
+			name:    "dictionary",
+			headers: http.Header{"Ratelimit": {"limit=100, remaining=50, reset=30"}},
+			want:    Headroom{Remaining: 50, Limit: 100, ResetAt: headroomNow.Add(30 * time.Second)},
+			wantOk:  true,
+		},
+		{
+			name: "separate headers",
+			headers: http.Header{
+				"Ratelimit-Limit":     {"100, 100;w=60"},
+				"Ratelimit-Remaining": {"7"},
+				"Ratelimit-Reset":     {"12"},
+			},
+			want:   Headroom{Remaining: 7, Limit: 100, ResetAt: headroomNow.Add(12 * time.Second)},
+			wantOk: true,
+		},
+		{
+			name:    "x-ratelimit headers are ignored",
+			headers: http.Header{"X-Ratelimit-Remaining": {"3"}, "X-Ratelimit-Reset": {"3"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ParseHeadroom(tt.headers, cfg, headroomNow)
+			require.Equal(t, tt.wantOk, ok)
+			if ok {
+				assert.Equal(t, tt.want, got)
+			}
+		})
+	}
+}
+
+func TestStore_Headroom(t *testing.T) {
+	store, _ := newTestStore(t)
+	connID := testConnectionID()
+	ctx := context.Background()
+	reset := headroomNow.Add(time.Minute)
+
+	_, ok, err := store.GetHeadroom(ctx, connID, headroomNow)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	_, allowed, err := store.ReserveHeadroom(ctx, connID, 0, headroomNow)
+	require.NoError(t, err)
+	assert.True(t, allowed, "nothing learned yet")
+
+	h, err := store.RecordHeadroom(ctx, connID, Headroom{Remaining: 2, Limit: 10, ResetAt: reset}, headroomNow)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), h.Remaining)
+
+	// An out-of-order response from the same window reporting more budget
+	// doesn't raise what's left.
+	h, err = store.RecordHeadroom(ctx, connID, Headroom{Remaining: 5, Limit: 10, ResetAt: reset.Add(-time.Second)}, headroomNow)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), h.Remaining)
+
+	_, allowed, err = store.ReserveHeadroom(ctx, connID, 0, headroomNow)
+	require.NoError(t, err)
+	assert.True(t, allowed)
+
+	got, ok, err := store.GetHeadroom(ctx, connID, headroomNow)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, Headroom{Remaining: 1, Limit: 10, ResetAt: time.UnixMilli(reset.UnixMilli())}, got)
+
+	wait, allowed, err := store.ReserveHeadroom(ctx, connID, 1, headroomNow.Add(15*time.Second))
+	require.NoError(t, err)
+	assert.False(t, allowed, "only the reserve is left")
+	assert.Equal(t, 45*time.Second, wait)
+
+	// Once the window resets, the known limit starts the next window. Its
+	// length is taken from the longest time to reset seen so far.
+	_, allowed, err = store.ReserveHeadroom(ctx, connID, 1, reset)
+	require.NoError(t, err)
+	assert.True(t, allowed)
+	got, ok, err = store.GetHeadroom(ctx, connID, reset)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, Headroom{Remaining: 9, Limit: 10, ResetAt: time.UnixMilli(reset.Add(time.Minute).UnixMilli())}, got)
+
+	// The upstream's report for the same window is merged with it.
+	h, err = store.RecordHeadroom(ctx, connID, Headroom{Remaining: 9, Limit: 10, ResetAt: reset.Add(time.Minute)}, reset)
+	require.NoError(t, err)
+	assert.Equal(t, int64(9), h.Remaining)
+
+	// Without a known limit the state is dropped at the reset.
+	other := apid.New(apid.PrefixConnection)
+	_, err = store.RecordHeadroom(ctx, other, Headroom{Remaining: 0, ResetAt: reset}, headroomNow)
+	require.NoError(t, err)
+	_, allowed, err = store.ReserveHeadroom(ctx, other, 1, reset)
+	require.NoError(t, err)
+	assert.True(t, allowed)
+	_, ok, err = store.GetHeadroom(ctx, other, reset)
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func headroomRoundTripper(store *Store, cfg *connectors.RateLimitHeadroom, transport http.RoundTripper) *RoundTripper {
+	return &RoundTripper{
+		connectionId: testConnectionID(),
+		config:       &connectors.RateLimiting{Headroom: cfg},
+		store:        store,
+		transport:    transport,
+		logger:       testLogger(),
+	}
+}
+
+func TestRoundTripper_HeadroomRejectsWhenExhausted(t *testing.T) {
+	store, _ := newTestStore(t)
+	ctx := testCtx()
+
+	transport := &mockTransport{
+		resp: &http.Response{
+			StatusCode: 200,
+			Header: http.Header{
+				"X-Ratelimit-Remaining": {"1"},
+				"X-Ratelimit-Reset":     {"30"},
+			},
+		},
+	}
+	rt := headroomRoundTripper(store, &connectors.RateLimitHeadroom{}, transport)
+
+	req, _ := http.NewRequestWithContext(ctx, "GET", "http://example.com", nil)
+	resp, err := rt.RoundTrip(req)
+	require.NoError(t, err)
+	require.Equal(t, 200, resp.StatusCode)
+
+	// The last request of the window is spent locally...
+	transport.called = false
+	resp, err = rt.RoundTrip(req)
+	require.NoError(t, err)
+	require.Equal(t, 200, resp.StatusCode)
+	require.True(t, transport.called)
+
+	h, ok, err := store.GetHeadroom(ctx, rt.connectionId, apctx.GetClock(ctx).Now())
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, int64(0), h.Remaining, "the stale header doesn't restore the reserved request")
+
+	// ...and the next is rejected without reaching the upstream.
+	attr := &app_metrics.Attribution{}
+	req, _ = http.NewRequestWithContext(app_metrics.ContextWithAttribution(ctx, attr), "GET", "http://example.com", nil)
+	transport.called = false
+	resp, err = rt.RoundTrip(req)
+	require.NoError(t, err)
+	assert.Equal(t, 429, resp.StatusCode)
+	assert.Equal(t, "30", resp.Header.Get("Retry-After"))
+	assert.Equal(t, "true", resp.Header.Get("X-Authproxy-Ratelimited"))
+	assert.False(t, transport.called)
+	assert.Equal(t, app_metrics.ResponseSourceConnectorRateLimiter, attr.Source)
+}
+
+func TestRoundTripper_HeadroomDelaysUntilReset(t *testing.T) {
+	store, _ := newTestStore(t)
+	fc := clock.NewFakeClock(headroomNow)
+	ctx := apctx.NewBuilderBackground().WithClock(fc).Build()
+
+	transport := &mockTransport{resp: &http.Response{StatusCode: 200, Header: http.Header{}}}
+	rt := headroomRoundTripper(store, &connectors.RateLimitHeadroom{
+		MaxDelay: &common.HumanDuration{Duration: 5 * time.Second},
+	}, transport)
+
+	_, err := store.RecordHeadroom(ctx, rt.connectionId, Headroom{Remaining: 0, ResetAt: headroomNow.Add(2 * time.Second)}, headroomNow)
+	require.NoError(t, err)
+
+	done := make(chan *http.Response)
+	go func() {
+		req, _ := http.NewRequestWithContext(ctx, "GET", "http://example.com", nil)
+		resp, _ := rt.RoundTrip(req)
+		done <- resp
+	}()
+
+	require.Eventually(t, fc.HasWaiters, time.Second, time.Millisecond)
+	fc.Step(2 * time.Second)
+
+	select {
+	case resp := <-done:
+		require.NotNil(t, resp)
+		assert.Equal(t, 200, resp.StatusCode)
+		assert.True(t, transport.called)
+	case <-time.After(5 * time.Second):
+		t.Fatal("request was not released at the reset")
+	}
+}
+
+// countingTransport answers 200 and counts the requests that reach it.
+type countingTransport struct {
+	calls atomic.Int32
+}
+
+func (c *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	c.calls.Add(1)
+	return &http.Response{StatusCode: 200, Header: http.Header{}, Body: http.NoBody, Request: req}, nil
+}
+
+// waitCountingClock is a fake clock that counts calls to After, so a test
+// can tell when every delayed request is waiting.
+type waitCountingClock struct {
+	*clock.FakeClock
+	waits atomic.Int32
+}
+
+func (c *waitCountingClock) After(d time.Duration) <-chan time.Time {
+	ch := c.FakeClock.After(d)
+	c.waits.Add(1)
+	return ch
+}
+
+func TestRoundTripper_HeadroomDelayedRequestsShareNextWindow(t *testing.T) {
+	store, _ := newTestStore(t)
+	fc := &waitCountingClock{FakeClock: clock.NewFakeClock(headroomNow)}
+	ctx := apctx.NewBuilderBackground().WithClock(fc).Build()
+
+	transport := &countingTransport{}
+	rt := headroomRoundTripper(store, &connectors.RateLimitHeadroom{
+		Reserve:  util.ToPtr(int64(1)),
+		MaxDelay: &common.HumanDuration{Duration: 10 * time.Second},
+	}, transport)
+
+	// Only the reserve is left; each 2s window allows three requests, two
+	// of them outside the reserve.
+	_, err := store.RecordHeadroom(ctx, rt.connectionId, Headroom{Remaining: 1, Limit: 3, ResetAt: headroomNow.Add(2 * time.Second)}, headroomNow)
+	require.NoError(t, err)
+
+	const n = 4
+	done := make(chan *http.Response, n)
+	for i := 0; i < n; i++ {
+		go func() {
+			req, _ := http.NewRequestWithContext(ctx, "GET", "http://example.com", nil)
+			resp, _ := rt.RoundTrip(req)
+			done <- resp
+		}()
+	}
+
+	require.Eventually(t, func() bool { return fc.waits.Load() == n }, time.Second, time.Millisecond)
+	assert.Equal(t, int32(0), transport.calls.Load())
+
+	// At the reset only the next window's budget goes out; the rest wait for
+	// the following reset.
+	fc.Step(2 * time.Second)
+	require.Eventually(t, func() bool { return fc.waits.Load() == n+2 && transport.calls.Load() == 2 }, time.Second, time.Millisecond)
+
+	fc.Step(2 * time.Second)
+	for i := 0; i < n; i++ {
+		select {
+		case resp := <-done:
+			require.NotNil(t, resp)
+			assert.Equal(t, 200, resp.StatusCode)
+		case <-time.After(5 * time.Second):
+			t.Fatal("delayed requests were not released")
+		}
+	}
+	assert.Equal(t, int32(n), transport.calls.Load())
+}
+
+func TestRoundTripper_HeadroomRecordsGauge(t *testing.T) {
+	store, _ := newTestStore(t)
+
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	t.Cleanup(func() { _ = mp.Shutdown(context.Background()) })
+
+	tel, err := NewTelemetry(
+		&aptelemetry.Providers{Enabled: true, MeterProvider: mp},
+		&sconfig.Telemetry{Enabled: util.ToPtr(true)},
+	)
+	require.NoError(t, err)
+
+	transport := &mockTransport{
+		resp: &http.Response{
+			StatusCode: 200,
+			Header: http.Header{
+				"X-Ratelimit-Remaining": {"41"},
+				"X-Ratelimit-Reset":     {"30"},
+			},
+		},
+	}
+	rt := headroomRoundTripper(store, &connectors.RateLimitHeadroom{}, transport)
+	rt.telemetry = tel
+
+	req, _ := http.NewRequestWithContext(testCtx(), "GET", "http://example.com", nil)
+	_, err = rt.RoundTrip(req)
+	require.NoError(t, err)
+
+	rm := metricdata.ResourceMetrics{}
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	require.Len(t, rm.ScopeMetrics, 1)
+	require.Len(t, rm.ScopeMetrics[0].Metrics, 1)
+	m := rm.ScopeMetrics[0].Metrics[0]
+	assert.Equal(t, metricUpstreamRemaining, m.Name)
+	gauge, ok := m.Data.(metricdata.Gauge[int64])
+	require.True(t, ok)
+	require.Len(t, gauge.DataPoints, 1)
+	assert.Equal(t, int64(41), gauge.DataPoints[0].Value)
+	v, ok := gauge.DataPoints[0].Attributes.Value("authproxy.connection_id")
+	require.True(t, ok)
+	assert.Equal(t, rt.connectionId.String(), v.AsString())
+}
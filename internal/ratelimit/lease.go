@@ -0,0 +1,155 @@
+package ratelimit
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+
+	"github.com/rmorlok/authproxy/internal/apctx"
+)
+
+// heldLease is a lease an allowed Decide acquired from a Leaser.
+type heldLease struct {
+	ruleID string
+	leaser Leaser
+	bucket BucketKey
+	id     string
+}
+
+// leaseSet is the leases acquired for one request. It renews them while
+// the request is running and releases them, once, when it completes.
+type leaseSet struct {
+	ctx    context.Context
+	logger *slog.Logger
+	leases []heldLease
+
+	once sync.Once
+	stop chan struct{}
+}
+
+// leasesFor collects the leases held by the matched rules. Returns nil if
+// no rule acquired one.
+func (rt *EnforcerRoundTripper) leasesFor(ctx context.Context, matched []matchedRule) *leaseSet {
+	var leases []heldLease
+	for i := range matched {
+		m := &matched[i]
+		if m.decision.LeaseID == "" {
+			continue
+		}
+		leaser, ok := m.limiter.(Leaser)
+		if !ok {
+			continue
+		}
+		leases = append(leases, heldLease{ruleID: string(m.rule.Id), leaser: leaser, bucket: m.bucket, id: m.decision.LeaseID})
+	}
+	if len(leases) == 0 {
+		return nil
+	}
+
+	return &leaseSet{
+		// Releases happen when the caller closes the response body,
+		// usually after the request's context has been cancelled.
+		ctx:    context.WithoutCancel(ctx),
+		logger: rt.logger,
+		leases: leases,
+		stop:   make(chan struct{}),
+	}
+}
+
+// renewEvery renews the leases every third of the shortest lease TTL until
+// release, so a long streamed response doesn't outlive its leases.
+func (s *leaseSet) renewEvery() {
+	interval := s.leases[0].leaser.LeaseTtl()
+	for _, l := range s.leases[1:] {
+		interval = min(interval, l.leaser.LeaseTtl())
+	}
+	interval /= 3
+
+	timer := apctx.GetClock(s.ctx).NewTimer(interval)
+	go func() {
+		defer timer.Stop()
+		for {
+			select {
+			case <-s.stop:
+				return
+			case <-timer.C():
+				timer.Reset(interval)
+				for _, l := range s.leases {
+					held, err := l.leaser.Renew(s.ctx, l.bucket, l.id)
+					switch {
+					case err != nil:
+						s.logger.WarnContext(s.ctx, "rate-limit concurrency lease renewal failed",
+							slog.String("rule_id", l.ruleID),
+							slog.String("error", err.Error()),
+						)
+					case !held:
+						// The slot may already belong to another request;
+						// this one now runs uncounted until it completes.
+						s.logger.WarnContext(s.ctx, "rate-limit concurrency lease expired while request was in flight",
+							slog.String("rule_id", l.ruleID),
+						)
+					}
+				}
+			}
+		}
+	}()
+}
+
+// release stops renewal and gives every lease back. Safe to call more
+// than once.
+func (s *leaseSet) release() {
+	s.once.Do(func() {
+		close(s.stop)
+		for _, l := range s.leases {
+			if err := l.leaser.Release(s.ctx, l.bucket, l.id); err != nil {
+				// The lease expires on its own after its TTL.
+				s.logger.WarnContext(s.ctx, "rate-limit concurrency lease release failed",
+					slog.String("rule_id", l.ruleID),
+					slog.String("error", err.Error()),
+				)
+			}
+		}
+	})
+}
+
+// holdUntilClosed keeps the leases held while resp's body is being read
+// and releases them when it is closed — for streamed raw responses, once
+// the stream ends. A response without a body completes immediately.
+func (s *leaseSet) holdUntilClosed(resp *http.Response) {
+	if resp.Body == nil || resp.Body == http.NoBody {
+		s.release()
+		return
+	}
+
+	s.renewEvery()
+
+	// Protocol upgrades hand back the upstream connection as the body;
+	// keep it writable so the caller can still use it as one.
+	if rwc, ok := resp.Body.(io.ReadWriteCloser); ok {
+		resp.Body = &leaseReadWriteBody{ReadWriteCloser: rwc, leases: s}
+		return
+	}
+	resp.Body = &leaseBody{ReadCloser: resp.Body, leases: s}
+}
+
+type leaseBody struct {
+	io.ReadCloser
+	leases *leaseSet
+}
+
+func (b *leaseBody) Close() error {
+	defer b.leases.release()
+	return b.ReadCloser.Close()
+}
+
+type leaseReadWriteBody struct {
+	io.ReadWriteCloser
+	leases *leaseSet
+}
+
+func (b *leaseReadWriteBody) Close() error {
+	defer b.leases.release()
+	return b.ReadWriteCloser.Close()
+}
@@ -0,0 +1,200 @@
+package ratelimit
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rmorlok/authproxy/internal/aplog"
+	"github.com/rmorlok/authproxy/internal/database"
+	"github.com/rmorlok/authproxy/internal/schema/common"
+	rlschema "github.com/rmorlok/authproxy/internal/schema/resources/rate_limit"
+	"github.com/stretchr/testify/require"
+)
+
+// streamingTransport hands back a fresh response per request so each
+// test controls when every body is closed.
+type streamingTransport struct {
+	err error
+}
+
+func (s *streamingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{},
+		Body:       io.NopCloser(strings.NewReader("data: chunk\n\n")),
+	}, nil
+}
+
+func concurrencyEnfDef(limit int, mode rlschema.Mode) rlschema.RateLimit {
+	return rlschema.RateLimit{
+		Mode:      mode,
+		Bucket:    rlschema.Bucket{Dimensions: []string{rlschema.DimensionConnection}},
+		Algorithm: rlschema.Algorithm{Concurrency: &rlschema.Concurrency{Limit: limit}},
+	}
+}
+
+var connectionA = BucketKey{Components: []BucketKeyComponent{{Name: rlschema.DimensionConnection, Value: "cxn_a"}}}
+
+// peekAllowed reports whether rule would admit another request for
+// connectionA, without acquiring anything.
+func peekAllowed(t *testing.T, env *enforcerEnv, rule *database.RateLimit) bool {
+	t.Helper()
+	l, err := NewLimiter(rule, env.rds, aplog.NewNoopLogger())
+	require.NoError(t, err)
+	d, err := l.Peek(env.ctx(), connectionA)
+	require.NoError(t, err)
+	return d.Allowed
+}
+
+func TestEnforcer_Concurrency_HeldUntilBodyClosed(t *testing.T) {
+	env := newEnforcerEnv(t)
+	env.loadRules(mkEnfRule("rl_cc", concurrencyEnfDef(1, rlschema.ModeEnforce)))
+	rt := newEnforcer(t, env, proxyRI("cxn_a", "act_a"), &streamingTransport{})
+
+	first, err := rt.RoundTrip(mkProxyReq(t, env.ctx(), "https://api.example.com/stream"))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, first.StatusCode)
+
+	// Reading the body to the end isn't completion; closing it is.
+	_, err = io.ReadAll(first.Body)
+	require.NoError(t, err)
+
+	second, err := rt.RoundTrip(mkProxyReq(t, env.ctx(), "https://api.example.com/stream"))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusTooManyRequests, second.StatusCode)
+	require.Equal(t, "1", second.Header.Get("Retry-After"))
+
+	require.NoError(t, first.Body.Close())
+	require.NoError(t, first.Body.Close(), "closing twice releases once")
+
+	third, err := rt.RoundTrip(mkProxyReq(t, env.ctx(), "https://api.example.com/stream"))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, third.StatusCode)
+	require.NoError(t, third.Body.Close())
+}
+
+func TestEnforcer_Concurrency_ReleasedOnTransportError(t *testing.T) {
+	env := newEnforcerEnv(t)
+	rule := mkEnfRule("rl_cc", concurrencyEnfDef(1, rlschema.ModeEnforce))
+	env.loadRules(rule)
+	rt := newEnforcer(t, env, proxyRI("cxn_a", "act_a"), &streamingTransport{err: errors.New("connection refused")})
+
+	_, err := rt.RoundTrip(mkProxyReq(t, env.ctx(), "https://api.example.com/x"))
+	require.Error(t, err)
+	require.True(t, peekAllowed(t, env, rule))
+}
+
+func TestEnforcer_Concurrency_ReleasedWhenAnotherRuleRejects(t *testing.T) {
+	env := newEnforcerEnv(t)
+	cc := mkEnfRule("rl_cc", concurrencyEnfDef(1, rlschema.ModeEnforce))
+	tb := mkEnfRule("rl_tb", minimalTokenBucketDef(1, rlschema.ModeEnforce))
+	env.loadRules(cc, tb)
+	rt := newEnforcer(t, env, proxyRI("cxn_a", "act_a"), &streamingTransport{})
+
+	resp, err := rt.RoundTrip(mkProxyReq(t, env.ctx(), "https://api.example.com/x"))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.NoError(t, resp.Body.Close())
+
+	// The token bucket rejects; the lease the concurrency rule took for
+	// the request that never ran must not linger.
+	resp, err = rt.RoundTrip(mkProxyReq(t, env.ctx(), "https://api.example.com/x"))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	require.Equal(t, "rl_tb", resp.Header.Get("X-Authproxy-Ratelimit"))
+	require.True(t, peekAllowed(t, env, cc))
+}
+
+func TestEnforcer_Concurrency_ObserveModeHoldsAndReleases(t *testing.T) {
+	env := newEnforcerEnv(t)
+	rule := mkEnfRule("rl_cc", concurrencyEnfDef(1, rlschema.ModeObserve))
+	env.loadRules(rule)
+	rt := newEnforcer(t, env, proxyRI("cxn_a", "act_a"), &streamingTransport{})
+
+	first, err := rt.RoundTrip(mkProxyReq(t, env.ctx(), "https://api.example.com/x"))
+	require.NoError(t, err)
+
+	ctx, attr := env.ctxWithAttr()
+	second, err := rt.RoundTrip(mkProxyReq(t, ctx, "https://api.example.com/x"))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, second.StatusCode, "observe never rejects")
+	require.Equal(t, string(rlschema.ModeObserve), attr.RateLimitMode)
+
+	require.NoError(t, first.Body.Close())
+	require.NoError(t, second.Body.Close())
+	require.True(t, peekAllowed(t, env, rule))
+}
+
+// upgradedConn stands in for the upstream connection net/http returns as
+// the body of a 101 response.
+type upgradedConn struct {
+	io.Reader
+	written strings.Builder
+	closed  bool
+}
+
+func (c *upgradedConn) Write(p []byte) (int, error) { return c.written.Write(p) }
+func (c *upgradedConn) Close() error                { c.closed = true; return nil }
+
+func TestEnforcer_Concurrency_UpgradedConnectionStaysWritable(t *testing.T) {
+	env := newEnforcerEnv(t)
+	rule := mkEnfRule("rl_cc", concurrencyEnfDef(1, rlschema.ModeEnforce))
+	env.loadRules(rule)
+
+	conn := &upgradedConn{Reader: strings.NewReader("")}
+	ft := &fakeTransport{resp: &http.Response{StatusCode: http.StatusSwitchingProtocols, Header: http.Header{}, Body: conn}}
+	rt := newEnforcer(t, env, proxyRI("cxn_a", "act_a"), ft)
+
+	resp, err := rt.RoundTrip(mkProxyReq(t, env.ctx(), "https://api.example.com/ws"))
+	require.NoError(t, err)
+
+	rwc, ok := resp.Body.(io.ReadWriteCloser)
+	require.True(t, ok)
+	_, err = rwc.Write([]byte("ping"))
+	require.NoError(t, err)
+	require.Equal(t, "ping", conn.written.String())
+	require.False(t, peekAllowed(t, env, rule))
+
+	require.NoError(t, rwc.Close())
+	require.True(t, conn.closed)
+	require.True(t, peekAllowed(t, env, rule))
+}
+
+func TestEnforcer_Concurrency_RenewsWhileStreaming(t *testing.T) {
+	env := newEnforcerEnv(t)
+	def := concurrencyEnfDef(1, rlschema.ModeEnforce)
+	def.Algorithm.Concurrency.LeaseTtl = &common.HumanDuration{Duration: 3 * time.Second}
+	rule := mkEnfRule("rl_cc", def)
+	env.loadRules(rule)
+	rt := newEnforcer(t, env, proxyRI("cxn_a", "act_a"), &streamingTransport{})
+
+	resp, err := rt.RoundTrip(mkProxyReq(t, env.ctx(), "https://api.example.com/stream"))
+	require.NoError(t, err)
+
+	// Hold the stream open well past the lease TTL. The renewal timer fires
+	// every TTL/3; wait for each renewal to land before moving on.
+	key := limiterKeyPrefix(rule.Id, connectionA) + ":cc"
+	members, err := env.server.ZMembers(key)
+	require.NoError(t, err)
+	require.Len(t, members, 1)
+	for i := 0; i < 10; i++ {
+		env.step(time.Second)
+		want := float64(env.clock.Now().Add(3 * time.Second).UnixMilli())
+		require.Eventually(t, func() bool {
+			score, err := env.server.ZScore(key, members[0])
+			return err == nil && score == want
+		}, time.Second, time.Millisecond)
+	}
+	require.False(t, peekAllowed(t, env, rule), "lease is still held after 10s with a 3s TTL")
+
+	require.NoError(t, resp.Body.Close())
+	require.True(t, peekAllowed(t, env, rule))
+	require.Eventually(t, func() bool { return !env.clock.HasWaiters() }, time.Second, time.Millisecond, "renewal stops on close")
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/rmorlok/authproxy/internal/apid"
 	"github.com/rmorlok/authproxy/internal/apredis"
 	"github.com/rmorlok/authproxy/internal/database"
+	"github.com/rmorlok/authproxy/internal/schema/common"
 )
 
 // Decision is the result of a per-request rate-limit check. The
@@ -39,6 +40,11 @@ type Decision struct {
 	// Callers should still log / metric this so chronic Redis failures
 	// remain visible.
 	FailedOpen bool
+
+	// LeaseID identifies the in-flight lease an allowed Decide acquired
+	// from a Leaser. The caller must Release it once the request
+	// completes. Empty for every other algorithm, and from Peek.
+	LeaseID string
 }
 
 // Limiter is the per-rule runtime evaluator. The enforcement layer holds
@@ -60,10 +66,51 @@ type Limiter interface {
 	Peek(ctx context.Context, bucketKey BucketKey) (Decision, error)
 }
 
+// Charger is implemented by Limiters that can debit a bucket after a
+// request has already been admitted. The enforcement layer uses it for
+// rules with a Cost: Decide charges the one unit that admits the request,
+// and once the upstream reports what the request actually cost, Charge
+// debits the rest. Charging never rejects anything — an overdrawn bucket
+// just rejects the requests that follow for longer.
+type Charger interface {
+	// Charge debits amount additional units from the bucket. Unlike
+	// Decide there is no fail-open decision to make; a Redis error is
+	// returned for the caller to log.
+	Charge(ctx context.Context, bucketKey BucketKey, amount int) error
+}
+
+// Leaser is implemented by Limiters that count requests in flight rather
+// than requests started. An allowed Decide acquires a lease (see
+// Decision.LeaseID) that holds a slot in the bucket until the caller
+// releases it. Leases expire after LeaseTtl so a process that dies
+// mid-request doesn't hold its slots forever; callers with a request still
+// running renew them well before then.
+type Leaser interface {
+	// LeaseTtl is how long a lease is held after it was acquired or last
+	// renewed.
+	LeaseTtl() time.Duration
+
+	// Renew extends a held lease by LeaseTtl. Returns false if the lease
+	// had already expired, in which case it is not re-acquired.
+	Renew(ctx context.Context, bucketKey BucketKey, leaseID string) (bool, error)
+
+	// Release gives the lease's slot back to the bucket. Releasing a
+	// lease that has expired or was already released is a no-op.
+	Release(ctx context.Context, bucketKey BucketKey, leaseID string) error
+}
+
 // NewLimiter builds a Limiter for a RateLimit row. The algorithm variant
 // is taken from rl.Definition.Algorithm; exactly one variant is required
-// (schema validation enforces this at write time).
+// (schema validation enforces this at write time). The Limiter may use the
+// rule's full capacity; see NewPriorityLimiter.
 func NewLimiter(rl *database.RateLimit, redis apredis.Client, logger *slog.Logger) (Limiter, error) {
+	return NewPriorityLimiter(rl, common.RequestPriorityInteractive, redis, logger)
+}
+
+// NewPriorityLimiter is like NewLimiter, but builds the Limiter for
+// requests of the given priority: it rejects requests that would use
+// capacity the rule's Priority reserves for the classes that outrank it.
+func NewPriorityLimiter(rl *database.RateLimit, priority common.RequestPriority, redis apredis.Client, logger *slog.Logger) (Limiter, error) {
 	if rl == nil {
 		return nil, fmt.Errorf("ratelimit: nil rate limit")
 	}
@@ -71,14 +118,24 @@ func NewLimiter(rl *database.RateLimit, redis apredis.Client, logger *slog.Logge
 		logger = slog.Default()
 	}
 	algo := rl.Definition.Algorithm
+	reserve := rl.Definition.Priority
 
 	switch {
 	case algo.FixedWindow != nil:
-		return newFixedWindowLimiter(rl.Id, *algo.FixedWindow, redis, logger), nil
+		floor := reserve.Floor(priority, algo.FixedWindow.Limit)
+		return newFixedWindowLimiter(rl.Id, *algo.FixedWindow, floor, redis, logger), nil
 	case algo.SlidingWindow != nil:
-		return newSlidingWindowLimiter(rl.Id, *algo.SlidingWindow, redis, logger), nil
+		floor := reserve.Floor(priority, algo.SlidingWindow.Limit)
+		return newSlidingWindowLimiter(rl.Id, *algo.SlidingWindow, floor, redis, logger), nil
 	case algo.TokenBucket != nil:
-		return newTokenBucketLimiter(rl.Id, *algo.TokenBucket, redis, logger), nil
+		floor := reserve.Floor(priority, algo.TokenBucket.Capacity)
+		return newTokenBucketLimiter(rl.Id, *algo.TokenBucket, floor, redis, logger), nil
+	case algo.Concurrency != nil:
+		floor := reserve.Floor(priority, algo.Concurrency.Limit)
+		return newConcurrencyLimiter(rl.Id, *algo.Concurrency, floor, redis, logger), nil
+	case algo.Quota != nil:
+		floor := reserve.Floor(priority, algo.Quota.Limit)
+		return newQuotaLimiter(rl.Id, *algo.Quota, floor, redis, logger), nil
 	}
 	return nil, fmt.Errorf("ratelimit: rule %s has no algorithm variant set", rl.Id)
 }
@@ -109,5 +166,14 @@ var (
 	_ Limiter = (*fixedWindowLimiter)(nil)
 	_ Limiter = (*slidingWindowLimiter)(nil)
 	_ Limiter = (*tokenBucketLimiter)(nil)
+	_ Limiter = (*concurrencyLimiter)(nil)
+	_ Limiter = (*quotaLimiter)(nil)
+
+	_ Charger = (*fixedWindowLimiter)(nil)
+	_ Charger = (*slidingWindowLimiter)(nil)
+	_ Charger = (*tokenBucketLimiter)(nil)
+	_ Charger = (*quotaLimiter)(nil)
+
+	_ Leaser = (*concurrencyLimiter)(nil)
 )
 
@@ -0,0 +1,132 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rmorlok/authproxy/internal/apid"
+	"github.com/rmorlok/authproxy/internal/aplog"
+	"github.com/rmorlok/authproxy/internal/database"
+	"github.com/rmorlok/authproxy/internal/schema/common"
+	rlschema "github.com/rmorlok/authproxy/internal/schema/resources/rate_limit"
+	"github.com/stretchr/testify/require"
+)
+
+// Each Charge test admits one request, charges the rest of a larger cost
+// and checks that the bucket now behaves as if that many requests had been
+// made.
+
+func mustCharge(t *testing.T, env *limiterEnv, l Limiter, amount int) {
+	t.Helper()
+	c, ok := l.(Charger)
+	require.True(t, ok, "limiter should implement Charger")
+	require.NoError(t, c.Charge(env.ctx(), mkBucket(), amount))
+}
+
+func TestCharge_FixedWindow(t *testing.T) {
+	env := newLimiterEnv(t)
+	l := mustNewLimiter(t, env, rlschema.RateLimit{Algorithm: rlschema.Algorithm{
+		FixedWindow: &rlschema.FixedWindow{Window: common.HumanDuration{Duration: time.Minute}, Limit: 10},
+	}})
+
+	d, err := l.Decide(env.ctx(), mkBucket())
+	require.NoError(t, err)
+	require.True(t, d.Allowed)
+	mustCharge(t, env, l, 8)
+
+	d, _ = l.Decide(env.ctx(), mkBucket())
+	require.True(t, d.Allowed)
+	require.Equal(t, 0, d.Remaining)
+
+	d, _ = l.Decide(env.ctx(), mkBucket())
+	require.False(t, d.Allowed)
+
+	// The charged units roll off with the window.
+	env.step(time.Minute)
+	d, _ = l.Decide(env.ctx(), mkBucket())
+	require.True(t, d.Allowed)
+}
+
+func TestCharge_FixedWindow_EstablishesTTLOnNewWindow(t *testing.T) {
+	env := newLimiterEnv(t)
+	l := mustNewLimiter(t, env, rlschema.RateLimit{Algorithm: rlschema.Algorithm{
+		FixedWindow: &rlschema.FixedWindow{Window: common.HumanDuration{Duration: time.Minute}, Limit: 5},
+	}})
+
+	// A charge landing before any Decide in the window still expires.
+	mustCharge(t, env, l, 5)
+	d, _ := l.Decide(env.ctx(), mkBucket())
+	require.False(t, d.Allowed)
+	require.LessOrEqual(t, d.RetryAfter, time.Minute)
+	require.Greater(t, d.RetryAfter, time.Duration(0))
+}
+
+func TestCharge_SlidingWindowLog(t *testing.T) {
+	env := newLimiterEnv(t)
+	l := mustNewLimiter(t, env, rlschema.RateLimit{Algorithm: rlschema.Algorithm{
+		SlidingWindow: &rlschema.SlidingWindow{Window: common.HumanDuration{Duration: time.Minute}, Limit: 5, Mode: rlschema.SlidingWindowModeLog},
+	}})
+
+	d, _ := l.Decide(env.ctx(), mkBucket())
+	require.True(t, d.Allowed)
+	mustCharge(t, env, l, 100)
+
+	d, _ = l.Decide(env.ctx(), mkBucket())
+	require.False(t, d.Allowed)
+
+	// Charges past the limit are capped, so they don't outlive the window.
+	env.step(time.Minute + time.Second)
+	d, _ = l.Decide(env.ctx(), mkBucket())
+	require.True(t, d.Allowed)
+}
+
+func TestCharge_SlidingWindowCounter(t *testing.T) {
+	env := newLimiterEnv(t)
+	l := mustNewLimiter(t, env, rlschema.RateLimit{Algorithm: rlschema.Algorithm{
+		SlidingWindow: &rlschema.SlidingWindow{Window: common.HumanDuration{Duration: time.Minute}, Limit: 5, Mode: rlschema.SlidingWindowModeCounter},
+	}})
+
+	d, _ := l.Decide(env.ctx(), mkBucket())
+	require.True(t, d.Allowed)
+	mustCharge(t, env, l, 4)
+
+	d, _ = l.Decide(env.ctx(), mkBucket())
+	require.False(t, d.Allowed)
+}
+
+func TestCharge_TokenBucket_GoesIntoDebt(t *testing.T) {
+	env := newLimiterEnv(t)
+	l := mustNewLimiter(t, env, rlschema.RateLimit{Algorithm: rlschema.Algorithm{
+		TokenBucket: &rlschema.TokenBucket{Capacity: 10, RefillRate: 1.0},
+	}})
+
+	d, _ := l.Decide(env.ctx(), mkBucket())
+	require.True(t, d.Allowed)
+	require.Equal(t, 9, d.Remaining)
+
+	// 9 tokens left; a request that cost 15 leaves the bucket 5 short.
+	mustCharge(t, env, l, 14)
+
+	d, _ = l.Decide(env.ctx(), mkBucket())
+	require.False(t, d.Allowed)
+	require.GreaterOrEqual(t, d.RetryAfter, 5900*time.Millisecond)
+	require.LessOrEqual(t, d.RetryAfter, 6100*time.Millisecond)
+
+	env.step(6 * time.Second)
+	d, _ = l.Decide(env.ctx(), mkBucket())
+	require.True(t, d.Allowed)
+}
+
+func TestCharge_ErrorOnRedisDown(t *testing.T) {
+	env := newLimiterEnv(t)
+	rl := &database.RateLimit{
+		Id: apid.New(apid.PrefixRateLimit),
+		Definition: rlschema.RateLimit{Algorithm: rlschema.Algorithm{
+			TokenBucket: &rlschema.TokenBucket{Capacity: 10, RefillRate: 1.0},
+		}},
+	}
+	l, err := NewLimiter(rl, newFailedRedis(t), aplog.NewNoopLogger())
+	require.NoError(t, err)
+
+	require.Error(t, l.(Charger).Charge(env.ctx(), mkBucket(), 5))
+}
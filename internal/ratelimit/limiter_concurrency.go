@@ -0,0 +1,200 @@
+package ratelimit
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/rmorlok/authproxy/internal/apctx"
+	"github.com/rmorlok/authproxy/internal/apid"
+	"github.com/rmorlok/authproxy/internal/apredis"
+	rlschema "github.com/rmorlok/authproxy/internal/schema/resources/rate_limit"
+)
+
+// concurrencyRetryAfter is the Retry-After reported when every lease is
+// held. Leases end when responses complete, which can't be predicted, so
+// callers are told to retry shortly — or sooner, if the oldest lease is
+// about to expire.
+const concurrencyRetryAfter = time.Second
+
+// concurrencyScript: ZSET of (score=lease_expiry_ms, member=lease id).
+// Expired leases — held by a process that died before releasing them —
+// are evicted first, then the remaining leases are counted against the
+// limit.
+//
+// Returns:
+//
+//	{1, remaining}      allowed; the lease has been added
+//	{0, retry_after_ms} rejected
+var concurrencyScript = redis.NewScript(`
+local key = KEYS[1]
+local limit = tonumber(ARGV[1])
+local now_ms = tonumber(ARGV[2])
+local ttl_ms = tonumber(ARGV[3])
+local max_retry_ms = tonumber(ARGV[4])
+local member = ARGV[5]
+
+redis.call('ZREMRANGEBYSCORE', key, '-inf', now_ms)
+local count = redis.call('ZCARD', key)
+
+if count >= limit then
+    local retry_ms = max_retry_ms
+    local oldest = redis.call('ZRANGE', key, 0, 0, 'WITHSCORES')
+    if #oldest == 2 then
+        local expires_in = tonumber(oldest[2]) - now_ms
+        if expires_in < retry_ms then retry_ms = expires_in end
+    end
+    if retry_ms < 1 then retry_ms = 1 end
+    return {0, retry_ms}
+end
+
+redis.call('ZADD', key, now_ms + ttl_ms, member)
+-- Every lease expires within ttl_ms of its last write, so the key can't
+-- outlive its newest lease by more than the slack.
+redis.call('PEXPIRE', key, ttl_ms + 1000)
+return {1, limit - count - 1}
+`)
+
+// concurrencyPeekScript mirrors concurrencyScript without evicting or
+// adding anything: expired leases are excluded from the count instead.
+var concurrencyPeekScript = redis.NewScript(`
+local key = KEYS[1]
+local limit = tonumber(ARGV[1])
+local now_ms = tonumber(ARGV[2])
+local max_retry_ms = tonumber(ARGV[3])
+
+local count = redis.call('ZCOUNT', key, '(' .. now_ms, '+inf')
+
+if count >= limit then
+    local retry_ms = max_retry_ms
+    local oldest = redis.call('ZRANGEBYSCORE', key, '(' .. now_ms, '+inf', 'WITHSCORES', 'LIMIT', 0, 1)
+    if #oldest == 2 then
+        local expires_in = tonumber(oldest[2]) - now_ms
+        if expires_in < retry_ms then retry_ms = expires_in end
+    end
+    if retry_ms < 1 then retry_ms = 1 end
+    return {0, retry_ms}
+end
+
+return {1, limit - count - 1}
+`)
+
+// concurrencyRenewScript pushes a held lease's expiry out by a full TTL.
+// A lease that has already expired is not resurrected — another request
+// may have taken its slot. Returns 1 if the lease was still held.
+var concurrencyRenewScript = redis.NewScript(`
+local key = KEYS[1]
+local now_ms = tonumber(ARGV[1])
+local ttl_ms = tonumber(ARGV[2])
+local member = ARGV[3]
+
+local expires = redis.call('ZSCORE', key, member)
+if not expires or tonumber(expires) <= now_ms then
+    return 0
+end
+redis.call('ZADD', key, now_ms + ttl_ms, member)
+redis.call('PEXPIRE', key, ttl_ms + 1000)
+return 1
+`)
+
+type concurrencyLimiter struct {
+	ruleID apid.ID
+	limit  int
+
+	// floor is the number of slots reserved for higher-priority traffic
+	// than the request's; requests are only admitted while they leave at
+	// least this many free.
+	floor    int
+	leaseTtl time.Duration
+	redis    apredis.Client
+	logger   *slog.Logger
+}
+
+func newConcurrencyLimiter(ruleID apid.ID, params rlschema.Concurrency, floor int, r apredis.Client, logger *slog.Logger) *concurrencyLimiter {
+	return &concurrencyLimiter{
+		ruleID:   ruleID,
+		limit:    params.Limit,
+		floor:    floor,
+		leaseTtl: params.GetLeaseTtl(),
+		redis:    r,
+		logger:   logger,
+	}
+}
+
+func (l *concurrencyLimiter) key(bucketKey BucketKey) string {
+	return limiterKeyPrefix(l.ruleID, bucketKey) + ":cc"
+}
+
+func (l *concurrencyLimiter) Decide(ctx context.Context, bucketKey BucketKey) (Decision, error) {
+	now := apctx.GetClock(ctx).Now()
+
+	leaseID, err := randomHex(8)
+	if err != nil {
+		return failOpen(l.logger, l.ruleID, err)
+	}
+
+	res, err := concurrencyScript.Run(ctx, l.redis, []string{l.key(bucketKey)},
+		l.limit-l.floor, now.UnixMilli(), l.leaseTtl.Milliseconds(), concurrencyRetryAfter.Milliseconds(), leaseID,
+	).Result()
+	if err != nil {
+		return failOpen(l.logger, l.ruleID, err)
+	}
+
+	allowed, value, err := parseDecisionResult(res)
+	if err != nil {
+		return failOpen(l.logger, l.ruleID, err)
+	}
+
+	if allowed {
+		return Decision{Allowed: true, Remaining: value, LeaseID: leaseID}, nil
+	}
+	return Decision{
+		Allowed:    false,
+		RetryAfter: time.Duration(value) * time.Millisecond,
+	}, nil
+}
+
+func (l *concurrencyLimiter) Peek(ctx context.Context, bucketKey BucketKey) (Decision, error) {
+	now := apctx.GetClock(ctx).Now()
+
+	res, err := concurrencyPeekScript.Run(ctx, l.redis, []string{l.key(bucketKey)},
+		l.limit-l.floor, now.UnixMilli(), concurrencyRetryAfter.Milliseconds(),
+	).Result()
+	if err != nil {
+		return failOpen(l.logger, l.ruleID, err)
+	}
+
+	allowed, value, err := parseDecisionResult(res)
+	if err != nil {
+		return failOpen(l.logger, l.ruleID, err)
+	}
+
+	if allowed {
+		return Decision{Allowed: true, Remaining: value}, nil
+	}
+	return Decision{
+		Allowed:    false,
+		RetryAfter: time.Duration(value) * time.Millisecond,
+	}, nil
+}
+
+func (l *concurrencyLimiter) LeaseTtl() time.Duration {
+	return l.leaseTtl
+}
+
+func (l *concurrencyLimiter) Renew(ctx context.Context, bucketKey BucketKey, leaseID string) (bool, error) {
+	now := apctx.GetClock(ctx).Now()
+
+	held, err := concurrencyRenewScript.Run(ctx, l.redis, []string{l.key(bucketKey)},
+		now.UnixMilli(), l.leaseTtl.Milliseconds(), leaseID,
+	).Int()
+	if err != nil {
+		return false, err
+	}
+	return held == 1, nil
+}
+
+func (l *concurrencyLimiter) Release(ctx context.Context, bucketKey BucketKey, leaseID string) error {
+	return l.redis.ZRem(ctx, l.key(bucketKey), leaseID).Err()
+}
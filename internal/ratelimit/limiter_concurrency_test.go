@@ -0,0 +1,182 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rmorlok/authproxy/internal/apid"
+	"github.com/rmorlok/authproxy/internal/aplog"
+	"github.com/rmorlok/authproxy/internal/database"
+	"github.com/rmorlok/authproxy/internal/schema/common"
+	rlschema "github.com/rmorlok/authproxy/internal/schema/resources/rate_limit"
+	"github.com/stretchr/testify/require"
+)
+
+func concurrencyDef(limit int, leaseTtl time.Duration) rlschema.RateLimit {
+	c := &rlschema.Concurrency{Limit: limit}
+	if leaseTtl > 0 {
+		c.LeaseTtl = &common.HumanDuration{Duration: leaseTtl}
+	}
+	return rlschema.RateLimit{Algorithm: rlschema.Algorithm{Concurrency: c}}
+}
+
+func TestConcurrency_AllowsUpToLimitInFlight(t *testing.T) {
+	env := newLimiterEnv(t)
+	l := mustNewLimiter(t, env, concurrencyDef(2, 0))
+
+	d1, err := l.Decide(env.ctx(), mkBucket())
+	require.NoError(t, err)
+	require.True(t, d1.Allowed)
+	require.Equal(t, 1, d1.Remaining)
+	require.NotEmpty(t, d1.LeaseID)
+
+	d2, _ := l.Decide(env.ctx(), mkBucket())
+	require.True(t, d2.Allowed)
+	require.Equal(t, 0, d2.Remaining)
+	require.NotEqual(t, d1.LeaseID, d2.LeaseID)
+
+	d3, _ := l.Decide(env.ctx(), mkBucket())
+	require.False(t, d3.Allowed)
+	require.Empty(t, d3.LeaseID)
+	require.Equal(t, concurrencyRetryAfter, d3.RetryAfter)
+
+	// Time passing doesn't free a slot — only completing a request does.
+	env.step(time.Minute)
+	d3, _ = l.Decide(env.ctx(), mkBucket())
+	require.False(t, d3.Allowed)
+
+	require.NoError(t, l.(Leaser).Release(env.ctx(), mkBucket(), d1.LeaseID))
+	d3, _ = l.Decide(env.ctx(), mkBucket())
+	require.True(t, d3.Allowed)
+}
+
+func TestConcurrency_ReleaseIsIdempotent(t *testing.T) {
+	env := newLimiterEnv(t)
+	l := mustNewLimiter(t, env, concurrencyDef(2, 0))
+	leaser := l.(Leaser)
+
+	d1, _ := l.Decide(env.ctx(), mkBucket())
+	d2, _ := l.Decide(env.ctx(), mkBucket())
+
+	// Releasing the same lease twice frees one slot, not two.
+	require.NoError(t, leaser.Release(env.ctx(), mkBucket(), d1.LeaseID))
+	require.NoError(t, leaser.Release(env.ctx(), mkBucket(), d1.LeaseID))
+
+	d, _ := l.Decide(env.ctx(), mkBucket())
+	require.True(t, d.Allowed)
+	d, _ = l.Decide(env.ctx(), mkBucket())
+	require.False(t, d.Allowed)
+
+	require.NoError(t, leaser.Release(env.ctx(), mkBucket(), d2.LeaseID))
+}
+
+func TestConcurrency_AbandonedLeasesExpire(t *testing.T) {
+	env := newLimiterEnv(t)
+	l := mustNewLimiter(t, env, concurrencyDef(1, 30*time.Second))
+
+	// A process that admits a request and dies never releases its lease.
+	d, _ := l.Decide(env.ctx(), mkBucket())
+	require.True(t, d.Allowed)
+
+	env.step(29 * time.Second)
+	d, _ = l.Decide(env.ctx(), mkBucket())
+	require.False(t, d.Allowed)
+	// The abandoned lease expires sooner than the usual retry hint.
+	require.Equal(t, time.Second, d.RetryAfter)
+
+	env.step(500 * time.Millisecond)
+	d, _ = l.Decide(env.ctx(), mkBucket())
+	require.False(t, d.Allowed)
+	require.Equal(t, 500*time.Millisecond, d.RetryAfter)
+
+	env.step(time.Second)
+	d, _ = l.Decide(env.ctx(), mkBucket())
+	require.True(t, d.Allowed)
+}
+
+func TestConcurrency_RenewExtendsLease(t *testing.T) {
+	env := newLimiterEnv(t)
+	l := mustNewLimiter(t, env, concurrencyDef(1, 30*time.Second))
+	leaser := l.(Leaser)
+	require.Equal(t, 30*time.Second, leaser.LeaseTtl())
+
+	d, _ := l.Decide(env.ctx(), mkBucket())
+	lease := d.LeaseID
+
+	env.step(20 * time.Second)
+	held, err := leaser.Renew(env.ctx(), mkBucket(), lease)
+	require.NoError(t, err)
+	require.True(t, held)
+
+	// Past the original expiry, the renewed lease is still held.
+	env.step(20 * time.Second)
+	d, _ = l.Decide(env.ctx(), mkBucket())
+	require.False(t, d.Allowed)
+
+	// Once it lapses it can't be renewed back into existence.
+	env.step(20 * time.Second)
+	held, err = leaser.Renew(env.ctx(), mkBucket(), lease)
+	require.NoError(t, err)
+	require.False(t, held)
+
+	d, _ = l.Decide(env.ctx(), mkBucket())
+	require.True(t, d.Allowed)
+}
+
+func TestConcurrency_PerBucketIsolation(t *testing.T) {
+	env := newLimiterEnv(t)
+	l := mustNewLimiter(t, env, concurrencyDef(1, 0))
+	bucketA := BucketKey{Components: []BucketKeyComponent{{Name: "connection", Value: "cxn_a"}}}
+	bucketB := BucketKey{Components: []BucketKeyComponent{{Name: "connection", Value: "cxn_b"}}}
+
+	d, _ := l.Decide(env.ctx(), bucketA)
+	require.True(t, d.Allowed)
+	d, _ = l.Decide(env.ctx(), bucketA)
+	require.False(t, d.Allowed)
+
+	d, _ = l.Decide(env.ctx(), bucketB)
+	require.True(t, d.Allowed)
+}
+
+func TestConcurrency_PeekDoesNotAcquire(t *testing.T) {
+	env := newLimiterEnv(t)
+	l := mustNewLimiter(t, env, concurrencyDef(1, 30*time.Second))
+
+	for i := 0; i < 3; i++ {
+		d, err := l.Peek(env.ctx(), mkBucket())
+		require.NoError(t, err)
+		require.True(t, d.Allowed)
+		require.Equal(t, 0, d.Remaining)
+		require.Empty(t, d.LeaseID)
+	}
+
+	d, _ := l.Decide(env.ctx(), mkBucket())
+	require.True(t, d.Allowed)
+
+	d, _ = l.Peek(env.ctx(), mkBucket())
+	require.False(t, d.Allowed)
+	require.Equal(t, concurrencyRetryAfter, d.RetryAfter)
+
+	// Expired leases don't count, even before Decide evicts them.
+	env.step(31 * time.Second)
+	d, _ = l.Peek(env.ctx(), mkBucket())
+	require.True(t, d.Allowed)
+}
+
+func TestConcurrency_FailOpenOnRedisDown(t *testing.T) {
+	env := newLimiterEnv(t)
+	rl := &database.RateLimit{
+		Id:         apid.New(apid.PrefixRateLimit),
+		Definition: concurrencyDef(1, 0),
+	}
+	l, err := NewLimiter(rl, newFailedRedis(t), aplog.NewNoopLogger())
+	require.NoError(t, err)
+
+	d, err := l.Decide(env.ctx(), mkBucket())
+	require.Error(t, err)
+	require.True(t, d.Allowed)
+	require.True(t, d.FailedOpen)
+	require.Empty(t, d.LeaseID, "a failed-open request holds no lease to release")
+
+	require.Error(t, l.(Leaser).Release(env.ctx(), mkBucket(), "abc"))
+}
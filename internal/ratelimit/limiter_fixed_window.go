@@ -16,9 +16,11 @@ import (
 
 // fixedWindowScript atomically increments the counter for the current
 // window. The first request to land in a window establishes the TTL —
-// subsequent requests inherit it. Returns:
+// subsequent requests inherit it. Requests that would eat into the floor
+// reserved for higher-priority traffic are rejected and their increment
+// given back, so they can't use up the reserve. Returns:
 //
-//	{1, remaining}            when allowed (count <= limit)
+//	{1, remaining}            when allowed (count <= limit - floor)
 //	{0, retry_after_ms}       when exceeded; retry_after = remaining ms
 //	                          in the current window (PTTL)
 //
@@ -28,13 +30,17 @@ var fixedWindowScript = redis.NewScript(`
 local key = KEYS[1]
 local limit = tonumber(ARGV[1])
 local window_ms = tonumber(ARGV[2])
+local floor = tonumber(ARGV[3])
 
 local count = redis.call('INCR', key)
 if count == 1 then
     redis.call('PEXPIRE', key, window_ms)
 end
 
-if count > limit then
+if count > limit - floor then
+    if floor > 0 then
+        redis.call('DECR', key)
+    end
     local pttl = redis.call('PTTL', key)
     if pttl < 0 then
         -- TTL not set yet (race against an EXPIRE that hasn't landed):
@@ -44,7 +50,7 @@ if count > limit then
     return {0, pttl}
 end
 
-return {1, limit - count}
+return {1, limit - floor - count}
 `)
 
 // fixedWindowPeekScript mirrors fixedWindowScript but writes nothing.
@@ -55,12 +61,13 @@ var fixedWindowPeekScript = redis.NewScript(`
 local key = KEYS[1]
 local limit = tonumber(ARGV[1])
 local window_ms = tonumber(ARGV[2])
+local floor = tonumber(ARGV[3])
 
 local count = tonumber(redis.call('GET', key) or '0')
 
 -- Decide does INCR-then-compare; we project that by checking whether
 -- the hypothetical incremented count would exceed the limit.
-if count + 1 > limit then
+if count + 1 > limit - floor then
     local pttl = redis.call('PTTL', key)
     if pttl < 0 then
         -- Either no key (-2) or no TTL (-1). Decide would establish a
@@ -71,21 +78,41 @@ if count + 1 > limit then
     return {0, pttl}
 end
 
-return {1, limit - count - 1}
+return {1, limit - floor - count - 1}
+`)
+
+// fixedWindowChargeScript adds amount to the current window's counter.
+// The key may not exist yet if the window rolled over since Decide, in
+// which case the charge establishes the window's TTL just as Decide would.
+var fixedWindowChargeScript = redis.NewScript(`
+local key = KEYS[1]
+local amount = tonumber(ARGV[1])
+local window_ms = tonumber(ARGV[2])
+
+redis.call('INCRBY', key, amount)
+if redis.call('PTTL', key) < 0 then
+    redis.call('PEXPIRE', key, window_ms)
+end
+return 1
 `)
 
 type fixedWindowLimiter struct {
 	ruleID apid.ID
 	limit  int
+	// floor is the capacity reserved for higher-priority traffic than
+	// the request's; requests are only admitted while they leave at least
+	// this much of the limit unused.
+	floor  int
 	window time.Duration
 	redis  apredis.Client
 	logger *slog.Logger
 }
 
-func newFixedWindowLimiter(ruleID apid.ID, params rlschema.FixedWindow, r apredis.Client, logger *slog.Logger) *fixedWindowLimiter {
+func newFixedWindowLimiter(ruleID apid.ID, params rlschema.FixedWindow, floor int, r apredis.Client, logger *slog.Logger) *fixedWindowLimiter {
 	return &fixedWindowLimiter{
 		ruleID: ruleID,
 		limit:  params.Limit,
+		floor:  floor,
 		window: params.Window.Duration,
 		redis:  r,
 		logger: logger,
@@ -101,7 +128,7 @@ func (l *fixedWindowLimiter) Decide(ctx context.Context, bucketKey BucketKey) (D
 	windowID := now.UnixMilli() / windowMs
 	key := fmt.Sprintf("%s:fw:%d", limiterKeyPrefix(l.ruleID, bucketKey), windowID)
 
-	res, err := fixedWindowScript.Run(ctx, l.redis, []string{key}, l.limit, windowMs).Result()
+	res, err := fixedWindowScript.Run(ctx, l.redis, []string{key}, l.limit, windowMs, l.floor).Result()
 	if err != nil {
 		return failOpen(l.logger, l.ruleID, err)
 	}
@@ -127,7 +154,7 @@ func (l *fixedWindowLimiter) Peek(ctx context.Context, bucketKey BucketKey) (Dec
 	windowID := now.UnixMilli() / windowMs
 	key := fmt.Sprintf("%s:fw:%d", limiterKeyPrefix(l.ruleID, bucketKey), windowID)
 
-	res, err := fixedWindowPeekScript.Run(ctx, l.redis, []string{key}, l.limit, windowMs).Result()
+	res, err := fixedWindowPeekScript.Run(ctx, l.redis, []string{key}, l.limit, windowMs, l.floor).Result()
 	if err != nil {
 		return failOpen(l.logger, l.ruleID, err)
 	}
@@ -146,6 +173,16 @@ func (l *fixedWindowLimiter) Peek(ctx context.Context, bucketKey BucketKey) (Dec
 	}, nil
 }
 
+func (l *fixedWindowLimiter) Charge(ctx context.Context, bucketKey BucketKey, amount int) error {
+	now := apctx.GetClock(ctx).Now()
+	windowMs := l.window.Milliseconds()
+
+	windowID := now.UnixMilli() / windowMs
+	key := fmt.Sprintf("%s:fw:%d", limiterKeyPrefix(l.ruleID, bucketKey), windowID)
+
+	return fixedWindowChargeScript.Run(ctx, l.redis, []string{key}, amount, windowMs).Err()
+}
+
 // parseDecisionResult unpacks the {allowed, value} pair returned by
 // every Lua script in this package. Centralised so the unmarshaling
 // edge cases (Redis returns int64 vs string depending on transport)
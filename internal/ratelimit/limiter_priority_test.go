@@ -0,0 +1,125 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rmorlok/authproxy/internal/apid"
+	"github.com/rmorlok/authproxy/internal/aplog"
+	"github.com/rmorlok/authproxy/internal/database"
+	"github.com/rmorlok/authproxy/internal/schema/common"
+	rlschema "github.com/rmorlok/authproxy/internal/schema/resources/rate_limit"
+	"github.com/stretchr/testify/require"
+)
+
+// testPriority reserves 20% of capacity for interactive traffic and a
+// further 10% for normal, leaving batch 70%.
+func testPriority() *rlschema.Priority {
+	return &rlschema.Priority{Reserve: map[common.RequestPriority]float64{
+		common.RequestPriorityInteractive: 0.2,
+		common.RequestPriorityNormal:      0.1,
+	}}
+}
+
+func mustNewPriorityLimiter(t *testing.T, env *limiterEnv, rl *database.RateLimit, priority common.RequestPriority) Limiter {
+	t.Helper()
+	l, err := NewPriorityLimiter(rl, priority, env.rds, aplog.NewNoopLogger())
+	require.NoError(t, err)
+	return l
+}
+
+// admitAll decides until l rejects, returning how many it admitted.
+func admitAll(t *testing.T, env *limiterEnv, l Limiter) int {
+	t.Helper()
+	for n := 0; n < 100; n++ {
+		peek, err := l.Peek(env.ctx(), mkBucket())
+		require.NoError(t, err)
+		d, err := l.Decide(env.ctx(), mkBucket())
+		require.NoError(t, err)
+		require.Equal(t, peek.Allowed, d.Allowed, "peek should agree with decide")
+		if !d.Allowed {
+			require.Positive(t, d.RetryAfter)
+			return n
+		}
+	}
+	t.Fatal("limiter never rejected")
+	return 0
+}
+
+func TestPriorityLimiter_ReservesCapacityPerClass(t *testing.T) {
+	window := common.HumanDuration{Duration: time.Minute}
+	cases := []struct {
+		name string
+		alg  rlschema.Algorithm
+	}{
+		{"fixed_window", rlschema.Algorithm{FixedWindow: &rlschema.FixedWindow{Window: window, Limit: 10}}},
+		{"sliding_window_log", rlschema.Algorithm{SlidingWindow: &rlschema.SlidingWindow{Window: window, Limit: 10, Mode: rlschema.SlidingWindowModeLog}}},
+		{"sliding_window_counter", rlschema.Algorithm{SlidingWindow: &rlschema.SlidingWindow{Window: window, Limit: 10, Mode: rlschema.SlidingWindowModeCounter}}},
+		{"token_bucket", rlschema.Algorithm{TokenBucket: &rlschema.TokenBucket{Capacity: 10, RefillRate: 0.001}}},
+		{"concurrency", rlschema.Algorithm{Concurrency: &rlschema.Concurrency{Limit: 10}}},
+		{"quota", rlschema.Algorithm{Quota: &rlschema.Quota{Period: rlschema.QuotaPeriodDay, Limit: 10}}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			env := newLimiterEnv(t)
+			rl := &database.RateLimit{
+				Id:         apid.New(apid.PrefixRateLimit),
+				Definition: rlschema.RateLimit{Algorithm: tc.alg, Priority: testPriority()},
+			}
+
+			// Batch stops short of the 30% reserved above it, normal short
+			// of interactive's 20%, and interactive uses what's left.
+			require.Equal(t, 7, admitAll(t, env, mustNewPriorityLimiter(t, env, rl, common.RequestPriorityBatch)))
+			require.Equal(t, 1, admitAll(t, env, mustNewPriorityLimiter(t, env, rl, "")))
+			require.Equal(t, 2, admitAll(t, env, mustNewPriorityLimiter(t, env, rl, common.RequestPriorityInteractive)))
+		})
+	}
+}
+
+func TestPriorityLimiter_RemainingExcludesReserve(t *testing.T) {
+	env := newLimiterEnv(t)
+	rl := &database.RateLimit{
+		Id: apid.New(apid.PrefixRateLimit),
+		Definition: rlschema.RateLimit{
+			Algorithm: rlschema.Algorithm{FixedWindow: &rlschema.FixedWindow{Window: common.HumanDuration{Duration: time.Minute}, Limit: 10}},
+			Priority:  testPriority(),
+		},
+	}
+
+	d, err := mustNewPriorityLimiter(t, env, rl, common.RequestPriorityBatch).Decide(env.ctx(), mkBucket())
+	require.NoError(t, err)
+	require.True(t, d.Allowed)
+	require.Equal(t, 6, d.Remaining)
+
+	d, err = mustNewPriorityLimiter(t, env, rl, common.RequestPriorityInteractive).Peek(env.ctx(), mkBucket())
+	require.NoError(t, err)
+	require.Equal(t, 8, d.Remaining)
+}
+
+func TestPriorityLimiter_FixedWindowRejectionsDoNotSpendReserve(t *testing.T) {
+	env := newLimiterEnv(t)
+	rl := &database.RateLimit{
+		Id: apid.New(apid.PrefixRateLimit),
+		Definition: rlschema.RateLimit{
+			Algorithm: rlschema.Algorithm{FixedWindow: &rlschema.FixedWindow{Window: common.HumanDuration{Duration: time.Minute}, Limit: 10}},
+			Priority:  testPriority(),
+		},
+	}
+
+	batch := mustNewPriorityLimiter(t, env, rl, common.RequestPriorityBatch)
+	for i := 0; i < 50; i++ {
+		_, _ = batch.Decide(env.ctx(), mkBucket())
+	}
+
+	require.Equal(t, 3, admitAll(t, env, mustNewPriorityLimiter(t, env, rl, common.RequestPriorityInteractive)))
+}
+
+func TestNewLimiter_IgnoresPriorityReserve(t *testing.T) {
+	env := newLimiterEnv(t)
+	l := mustNewLimiter(t, env, rlschema.RateLimit{
+		Algorithm: rlschema.Algorithm{TokenBucket: &rlschema.TokenBucket{Capacity: 10, RefillRate: 0.001}},
+		Priority:  testPriority(),
+	})
+	require.Equal(t, 10, admitAll(t, env, l))
+}
@@ -0,0 +1,252 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/rmorlok/authproxy/internal/apctx"
+	"github.com/rmorlok/authproxy/internal/apid"
+	"github.com/rmorlok/authproxy/internal/apredis"
+	"github.com/rmorlok/authproxy/internal/database"
+	rlschema "github.com/rmorlok/authproxy/internal/schema/resources/rate_limit"
+)
+
+// quotaKeySlack keeps a period's counters around a little past its end so
+// processes whose clocks run behind still find them.
+const quotaKeySlack = time.Hour
+
+// quotaScript counts one request against the bucket's field in the
+// period's hash. Unlike the window algorithms, rejected requests aren't
+// counted: a quota reports what was actually consumed. Returns:
+//
+//	{1, remaining}       allowed
+//	{0, retry_after_ms}  exhausted; retry once the period ends
+var quotaScript = redis.NewScript(`
+local key = KEYS[1]
+local field = ARGV[1]
+local limit = tonumber(ARGV[2])
+local retry_ms = tonumber(ARGV[3])
+local ttl_ms = tonumber(ARGV[4])
+
+local used = tonumber(redis.call('HGET', key, field) or '0')
+if used + 1 > limit then
+    return {0, retry_ms}
+end
+
+redis.call('HINCRBY', key, field, 1)
+redis.call('PEXPIRE', key, ttl_ms)
+return {1, limit - used - 1}
+`)
+
+// quotaPeekScript mirrors quotaScript but writes nothing.
+var quotaPeekScript = redis.NewScript(`
+local key = KEYS[1]
+local field = ARGV[1]
+local limit = tonumber(ARGV[2])
+local retry_ms = tonumber(ARGV[3])
+
+local used = tonumber(redis.call('HGET', key, field) or '0')
+if used + 1 > limit then
+    return {0, retry_ms}
+end
+return {1, limit - used - 1}
+`)
+
+// quotaChargeScript adds amount to the bucket's usage for the period.
+var quotaChargeScript = redis.NewScript(`
+redis.call('HINCRBY', KEYS[1], ARGV[1], tonumber(ARGV[2]))
+redis.call('PEXPIRE', KEYS[1], tonumber(ARGV[3]))
+return 1
+`)
+
+// quotaLocations caches the resolved timezone of each quota by name. Limiters
+// are built per request, and loading a zone reads the tz database.
+var quotaLocations = struct {
+	mu   sync.RWMutex
+	byTz map[string]*time.Location
+}{byTz: make(map[string]*time.Location)}
+
+// quotaLocation returns the location q's periods are aligned in.
+func quotaLocation(q rlschema.Quota) *time.Location {
+	tz := q.GetTimezone()
+
+	quotaLocations.mu.RLock()
+	loc, ok := quotaLocations.byTz[tz]
+	quotaLocations.mu.RUnlock()
+	if ok {
+		return loc
+	}
+
+	loc = q.GetLocation()
+	quotaLocations.mu.Lock()
+	quotaLocations.byTz[tz] = loc
+	quotaLocations.mu.Unlock()
+	return loc
+}
+
+// quotaPeriod returns the calendar period containing t: the day, the week
+// starting Monday, or the month, in loc.
+func quotaPeriod(period rlschema.QuotaPeriod, loc *time.Location, t time.Time) (start, end time.Time) {
+	local := t.In(loc)
+	y, m, d := local.Date()
+	switch period {
+	case rlschema.QuotaPeriodWeek:
+		// time.Weekday counts from Sunday; ISO weeks start on Monday.
+		offset := (int(local.Weekday()) + 6) % 7
+		start = time.Date(y, m, d-offset, 0, 0, 0, 0, local.Location())
+		return start, start.AddDate(0, 0, 7)
+	case rlschema.QuotaPeriodMonth:
+		start = time.Date(y, m, 1, 0, 0, 0, 0, local.Location())
+		return start, start.AddDate(0, 1, 0)
+	default:
+		start = time.Date(y, m, d, 0, 0, 0, 0, local.Location())
+		return start, start.AddDate(0, 0, 1)
+	}
+}
+
+// quotaKey is the hash holding every bucket's usage for the period that
+// starts at periodStart. The other algorithms key each bucket separately;
+// a quota keeps its buckets together so usage can be listed per bucket.
+func quotaKey(ruleID apid.ID, periodStart time.Time) string {
+	return fmt.Sprintf("ratelimit:rule:%s:q:%s", string(ruleID), periodStart.Format("20060102"))
+}
+
+type quotaLimiter struct {
+	ruleID apid.ID
+	quota  rlschema.Quota
+	loc    *time.Location
+
+	// floor is the part of the allowance reserved for higher-priority
+	// traffic than the request's; requests are only admitted while they
+	// leave at least this much of it unused.
+	floor  int
+	redis  apredis.Client
+	logger *slog.Logger
+}
+
+func newQuotaLimiter(ruleID apid.ID, params rlschema.Quota, floor int, r apredis.Client, logger *slog.Logger) *quotaLimiter {
+	return &quotaLimiter{
+		ruleID: ruleID,
+		quota:  params,
+		loc:    quotaLocation(params),
+		floor:  floor,
+		redis:  r,
+		logger: logger,
+	}
+}
+
+// period resolves the current period's key along with how long until it
+// ends and how long its key should live.
+func (l *quotaLimiter) period(ctx context.Context) (key string, untilEnd, ttl time.Duration) {
+	now := apctx.GetClock(ctx).Now()
+	start, end := quotaPeriod(l.quota.Period, l.loc, now)
+	untilEnd = end.Sub(now)
+	return quotaKey(l.ruleID, start), untilEnd, untilEnd + quotaKeySlack
+}
+
+func (l *quotaLimiter) Decide(ctx context.Context, bucketKey BucketKey) (Decision, error) {
+	key, untilEnd, ttl := l.period(ctx)
+
+	res, err := quotaScript.Run(ctx, l.redis, []string{key},
+		bucketKey.String(), l.quota.Limit-l.floor, untilEnd.Milliseconds(), ttl.Milliseconds(),
+	).Result()
+	if err != nil {
+		return failOpen(l.logger, l.ruleID, err)
+	}
+	return l.decision(res)
+}
+
+func (l *quotaLimiter) Peek(ctx context.Context, bucketKey BucketKey) (Decision, error) {
+	key, untilEnd, _ := l.period(ctx)
+
+	res, err := quotaPeekScript.Run(ctx, l.redis, []string{key},
+		bucketKey.String(), l.quota.Limit-l.floor, untilEnd.Milliseconds(),
+	).Result()
+	if err != nil {
+		return failOpen(l.logger, l.ruleID, err)
+	}
+	return l.decision(res)
+}
+
+func (l *quotaLimiter) decision(res interface{}) (Decision, error) {
+	allowed, value, err := parseDecisionResult(res)
+	if err != nil {
+		return failOpen(l.logger, l.ruleID, err)
+	}
+	if allowed {
+		return Decision{Allowed: true, Remaining: value}, nil
+	}
+	return Decision{RetryAfter: time.Duration(value) * time.Millisecond}, nil
+}
+
+func (l *quotaLimiter) Charge(ctx context.Context, bucketKey BucketKey, amount int) error {
+	key, _, ttl := l.period(ctx)
+	return quotaChargeScript.Run(ctx, l.redis, []string{key}, bucketKey.String(), amount, ttl.Milliseconds()).Err()
+}
+
+// ErrNotQuota is returned by GetQuotaUsage for a rule that doesn't use the
+// quota algorithm.
+var ErrNotQuota = errors.New("rate limit does not use the quota algorithm")
+
+// QuotaUsage is how much of a quota rule's allowance each bucket has
+// consumed in the current period.
+type QuotaUsage struct {
+	PeriodStart time.Time
+	PeriodEnd   time.Time
+	Limit       int
+
+	// Buckets holds every bucket that has consumed anything this period,
+	// most-used first.
+	Buckets []QuotaBucketUsage
+}
+
+// QuotaBucketUsage is one bucket's consumption. BucketKey is the bucket's
+// BucketKey.String() form.
+type QuotaBucketUsage struct {
+	BucketKey string
+	Used      int
+}
+
+// GetQuotaUsage reads the current period's consumption for a quota rule.
+// Unlike the limiters it doesn't fail open: a caller asking for usage
+// should learn that it couldn't be read.
+func GetQuotaUsage(ctx context.Context, r apredis.Client, rl *database.RateLimit) (QuotaUsage, error) {
+	if rl == nil || rl.Definition.Algorithm.Quota == nil {
+		return QuotaUsage{}, ErrNotQuota
+	}
+	q := *rl.Definition.Algorithm.Quota
+
+	start, end := quotaPeriod(q.Period, quotaLocation(q), apctx.GetClock(ctx).Now())
+	fields, err := r.HGetAll(ctx, quotaKey(rl.Id, start)).Result()
+	if err != nil {
+		return QuotaUsage{}, err
+	}
+
+	buckets := make([]QuotaBucketUsage, 0, len(fields))
+	for bucket, raw := range fields {
+		used, err := toInt(raw)
+		if err != nil {
+			return QuotaUsage{}, fmt.Errorf("bucket %q: %w", bucket, err)
+		}
+		buckets = append(buckets, QuotaBucketUsage{BucketKey: bucket, Used: used})
+	}
+	sort.Slice(buckets, func(i, j int) bool {
+		if buckets[i].Used != buckets[j].Used {
+			return buckets[i].Used > buckets[j].Used
+		}
+		return buckets[i].BucketKey < buckets[j].BucketKey
+	})
+
+	return QuotaUsage{
+		PeriodStart: start,
+		PeriodEnd:   end,
+		Limit:       q.Limit,
+		Buckets:     buckets,
+	}, nil
+}
@@ -0,0 +1,206 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rmorlok/authproxy/internal/apid"
+	"github.com/rmorlok/authproxy/internal/aplog"
+	"github.com/rmorlok/authproxy/internal/database"
+	rlschema "github.com/rmorlok/authproxy/internal/schema/resources/rate_limit"
+	"github.com/stretchr/testify/require"
+)
+
+func quotaDef(period rlschema.QuotaPeriod, limit int) rlschema.RateLimit {
+	return rlschema.RateLimit{Algorithm: rlschema.Algorithm{
+		Quota: &rlschema.Quota{Period: period, Limit: limit},
+	}}
+}
+
+func TestQuotaPeriod(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+
+	// Wednesday 2026-03-18 02:30 UTC is still Tuesday evening in New York.
+	at := time.Date(2026, 3, 18, 2, 30, 0, 0, time.UTC)
+	cases := []struct {
+		name       string
+		quota      rlschema.Quota
+		start, end time.Time
+	}{
+		{"day", rlschema.Quota{Period: rlschema.QuotaPeriodDay},
+			time.Date(2026, 3, 18, 0, 0, 0, 0, time.UTC), time.Date(2026, 3, 19, 0, 0, 0, 0, time.UTC)},
+		{"week starts monday", rlschema.Quota{Period: rlschema.QuotaPeriodWeek},
+			time.Date(2026, 3, 16, 0, 0, 0, 0, time.UTC), time.Date(2026, 3, 23, 0, 0, 0, 0, time.UTC)},
+		{"month", rlschema.Quota{Period: rlschema.QuotaPeriodMonth},
+			time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)},
+		{"day in timezone", rlschema.Quota{Period: rlschema.QuotaPeriodDay, Timezone: "America/New_York"},
+			time.Date(2026, 3, 17, 0, 0, 0, 0, ny), time.Date(2026, 3, 18, 0, 0, 0, 0, ny)},
+		// DST began on 2026-03-08, so this month is an hour short.
+		{"month in timezone", rlschema.Quota{Period: rlschema.QuotaPeriodMonth, Timezone: "America/New_York"},
+			time.Date(2026, 3, 1, 0, 0, 0, 0, ny), time.Date(2026, 4, 1, 0, 0, 0, 0, ny)},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			start, end := quotaPeriod(tc.quota.Period, quotaLocation(tc.quota), at)
+			require.True(t, tc.start.Equal(start), "start: want %s, got %s", tc.start, start)
+			require.True(t, tc.end.Equal(end), "end: want %s, got %s", tc.end, end)
+		})
+	}
+
+	t.Run("sunday belongs to the week before", func(t *testing.T) {
+		start, _ := quotaPeriod(rlschema.QuotaPeriodWeek, time.UTC, time.Date(2026, 3, 22, 23, 0, 0, 0, time.UTC))
+		require.True(t, time.Date(2026, 3, 16, 0, 0, 0, 0, time.UTC).Equal(start))
+	})
+}
+
+func TestQuotaLocation(t *testing.T) {
+	ny := rlschema.Quota{Period: rlschema.QuotaPeriodDay, Timezone: "America/New_York"}
+	loc := quotaLocation(ny)
+	require.Equal(t, "America/New_York", loc.String())
+	require.Same(t, loc, quotaLocation(ny), "the zone is loaded once and reused")
+
+	l := newQuotaLimiter(apid.New(apid.PrefixRateLimit), ny, 0, nil, aplog.NewNoopLogger())
+	require.Same(t, loc, l.loc)
+
+	require.Equal(t, time.UTC, quotaLocation(rlschema.Quota{Timezone: "Not/AZone"}))
+}
+
+func TestQuota_AllowsUpToLimitThenRejectsUntilPeriodEnds(t *testing.T) {
+	env := newLimiterEnv(t)
+	env.step(6 * time.Hour)
+	l := mustNewLimiter(t, env, quotaDef(rlschema.QuotaPeriodDay, 3))
+
+	for i := 0; i < 3; i++ {
+		d, err := l.Decide(env.ctx(), mkBucket())
+		require.NoError(t, err)
+		require.True(t, d.Allowed, "request %d should be allowed", i+1)
+		require.Equal(t, 3-(i+1), d.Remaining)
+	}
+
+	d, err := l.Decide(env.ctx(), mkBucket())
+	require.NoError(t, err)
+	require.False(t, d.Allowed)
+	require.Equal(t, 18*time.Hour, d.RetryAfter)
+
+	// Still exhausted later the same day.
+	env.step(17 * time.Hour)
+	d, _ = l.Decide(env.ctx(), mkBucket())
+	require.False(t, d.Allowed)
+	require.Equal(t, time.Hour, d.RetryAfter)
+
+	// A new day is a new allowance.
+	env.step(time.Hour)
+	d, _ = l.Decide(env.ctx(), mkBucket())
+	require.True(t, d.Allowed)
+	require.Equal(t, 2, d.Remaining)
+}
+
+func TestQuota_RejectedRequestsAreNotCounted(t *testing.T) {
+	env := newLimiterEnv(t)
+	rl := &database.RateLimit{Id: apid.New(apid.PrefixRateLimit), Definition: quotaDef(rlschema.QuotaPeriodMonth, 2)}
+	l, err := NewLimiter(rl, env.rds, aplog.NewNoopLogger())
+	require.NoError(t, err)
+
+	for i := 0; i < 5; i++ {
+		_, _ = l.Decide(env.ctx(), mkBucket())
+	}
+
+	usage, err := GetQuotaUsage(env.ctx(), env.rds, rl)
+	require.NoError(t, err)
+	require.Equal(t, []QuotaBucketUsage{{BucketKey: mkBucket().String(), Used: 2}}, usage.Buckets)
+}
+
+func TestQuota_PeekDoesNotConsume(t *testing.T) {
+	env := newLimiterEnv(t)
+	l := mustNewLimiter(t, env, quotaDef(rlschema.QuotaPeriodWeek, 1))
+
+	for i := 0; i < 3; i++ {
+		d, err := l.Peek(env.ctx(), mkBucket())
+		require.NoError(t, err)
+		require.True(t, d.Allowed)
+		require.Equal(t, 0, d.Remaining)
+	}
+
+	_, _ = l.Decide(env.ctx(), mkBucket())
+	d, err := l.Peek(env.ctx(), mkBucket())
+	require.NoError(t, err)
+	require.False(t, d.Allowed)
+	// t0 is a Thursday; the week ends at the start of Monday.
+	require.Equal(t, 4*24*time.Hour, d.RetryAfter)
+}
+
+func TestQuota_ChargeConsumesAllowance(t *testing.T) {
+	env := newLimiterEnv(t)
+	l := mustNewLimiter(t, env, quotaDef(rlschema.QuotaPeriodDay, 10))
+
+	d, _ := l.Decide(env.ctx(), mkBucket())
+	require.True(t, d.Allowed)
+	require.NoError(t, l.(Charger).Charge(env.ctx(), mkBucket(), 8))
+
+	d, _ = l.Decide(env.ctx(), mkBucket())
+	require.True(t, d.Allowed)
+	require.Equal(t, 0, d.Remaining)
+	d, _ = l.Decide(env.ctx(), mkBucket())
+	require.False(t, d.Allowed)
+}
+
+func TestGetQuotaUsage(t *testing.T) {
+	env := newLimiterEnv(t)
+	rl := &database.RateLimit{Id: apid.New(apid.PrefixRateLimit), Definition: quotaDef(rlschema.QuotaPeriodMonth, 100)}
+	l, err := NewLimiter(rl, env.rds, aplog.NewNoopLogger())
+	require.NoError(t, err)
+
+	bucket := func(actor string) BucketKey {
+		return BucketKey{Components: []BucketKeyComponent{{Name: rlschema.DimensionActor, Value: actor}}}
+	}
+	for actor, n := range map[string]int{"act_a": 2, "act_b": 5, "act_c": 2} {
+		for i := 0; i < n; i++ {
+			_, err := l.Decide(env.ctx(), bucket(actor))
+			require.NoError(t, err)
+		}
+	}
+
+	usage, err := GetQuotaUsage(env.ctx(), env.rds, rl)
+	require.NoError(t, err)
+	require.Equal(t, 100, usage.Limit)
+	require.True(t, time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC).Equal(usage.PeriodStart))
+	require.True(t, time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC).Equal(usage.PeriodEnd))
+	require.Equal(t, []QuotaBucketUsage{
+		{BucketKey: "actor=act_b", Used: 5},
+		{BucketKey: "actor=act_a", Used: 2},
+		{BucketKey: "actor=act_c", Used: 2},
+	}, usage.Buckets)
+
+	t.Run("next period is empty", func(t *testing.T) {
+		env.step(31 * 24 * time.Hour)
+		usage, err := GetQuotaUsage(env.ctx(), env.rds, rl)
+		require.NoError(t, err)
+		require.Empty(t, usage.Buckets)
+	})
+
+	t.Run("not a quota", func(t *testing.T) {
+		_, err := GetQuotaUsage(env.ctx(), env.rds, &database.RateLimit{
+			Id:         apid.New(apid.PrefixRateLimit),
+			Definition: rlschema.RateLimit{Algorithm: rlschema.Algorithm{TokenBucket: &rlschema.TokenBucket{Capacity: 1, RefillRate: 1}}},
+		})
+		require.ErrorIs(t, err, ErrNotQuota)
+	})
+
+	t.Run("redis unavailable", func(t *testing.T) {
+		_, err := GetQuotaUsage(env.ctx(), newFailedRedis(t), rl)
+		require.Error(t, err)
+	})
+}
+
+func TestQuota_RedisFailureFailsOpen(t *testing.T) {
+	env := newLimiterEnv(t)
+	rl := &database.RateLimit{Id: apid.New(apid.PrefixRateLimit), Definition: quotaDef(rlschema.QuotaPeriodDay, 1)}
+	l, err := NewLimiter(rl, newFailedRedis(t), aplog.NewNoopLogger())
+	require.NoError(t, err)
+
+	d, err := l.Decide(env.ctx(), mkBucket())
+	require.Error(t, err)
+	require.True(t, d.Allowed)
+	require.True(t, d.FailedOpen)
+}
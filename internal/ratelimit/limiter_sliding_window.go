@@ -151,19 +151,56 @@ if remaining < 0 then remaining = 0 end
 return {1, remaining}
 `)
 
+// slidingWindowLogChargeScript records amount more entries at now. All of
+// them share a timestamp, so they age out together and anything past the
+// limit would never change a decision; the count is capped there to keep
+// the ZSET bounded.
+var slidingWindowLogChargeScript = redis.NewScript(`
+local key = KEYS[1]
+local limit = tonumber(ARGV[1])
+local now_ms = tonumber(ARGV[2])
+local window_ms = tonumber(ARGV[3])
+local member = ARGV[4]
+local amount = math.min(tonumber(ARGV[5]), limit)
+
+for i = 1, amount do
+    redis.call('ZADD', key, now_ms, member .. ':' .. i)
+end
+redis.call('PEXPIRE', key, window_ms + 1000)
+return 1
+`)
+
+// slidingWindowCounterChargeScript adds amount to the current window's
+// counter, with the same TTL Decide gives it.
+var slidingWindowCounterChargeScript = redis.NewScript(`
+local key_curr = KEYS[1]
+local amount = tonumber(ARGV[1])
+local window_ms = tonumber(ARGV[2])
+
+redis.call('INCRBY', key_curr, amount)
+redis.call('PEXPIRE', key_curr, 2 * window_ms)
+return 1
+`)
+
 type slidingWindowLimiter struct {
 	ruleID apid.ID
 	limit  int
+
+	// floor is the capacity reserved for higher-priority traffic than
+	// the request's; requests are only admitted while they leave at least
+	// this much of the limit unused.
+	floor  int
 	window time.Duration
 	mode   rlschema.SlidingWindowMode
 	redis  apredis.Client
 	logger *slog.Logger
 }
 
-func newSlidingWindowLimiter(ruleID apid.ID, params rlschema.SlidingWindow, r apredis.Client, logger *slog.Logger) *slidingWindowLimiter {
+func newSlidingWindowLimiter(ruleID apid.ID, params rlschema.SlidingWindow, floor int, r apredis.Client, logger *slog.Logger) *slidingWindowLimiter {
 	return &slidingWindowLimiter{
 		ruleID: ruleID,
 		limit:  params.Limit,
+		floor:  floor,
 		window: params.Window.Duration,
 		mode:   params.Mode,
 		redis:  r,
@@ -199,7 +236,7 @@ func (l *slidingWindowLimiter) decideLog(ctx context.Context, bucketKey BucketKe
 	windowMs := l.window.Milliseconds()
 	res, err := slidingWindowLogScript.Run(ctx, l.redis,
 		[]string{key},
-		l.limit, now.UnixMilli(), windowMs, member,
+		l.limit-l.floor, now.UnixMilli(), windowMs, member,
 	).Result()
 	if err != nil {
 		return failOpen(l.logger, l.ruleID, err)
@@ -229,7 +266,7 @@ func (l *slidingWindowLimiter) decideCounter(ctx context.Context, bucketKey Buck
 
 	res, err := slidingWindowCounterScript.Run(ctx, l.redis,
 		[]string{keyCurr, keyPrev},
-		l.limit, windowMs, elapsedMs,
+		l.limit-l.floor, windowMs, elapsedMs,
 	).Result()
 	if err != nil {
 		return failOpen(l.logger, l.ruleID, err)
@@ -270,7 +307,7 @@ func (l *slidingWindowLimiter) peekLog(ctx context.Context, bucketKey BucketKey,
 
 	res, err := slidingWindowLogPeekScript.Run(ctx, l.redis,
 		[]string{key},
-		l.limit, now.UnixMilli(), windowMs,
+		l.limit-l.floor, now.UnixMilli(), windowMs,
 	).Result()
 	if err != nil {
 		return failOpen(l.logger, l.ruleID, err)
@@ -300,7 +337,7 @@ func (l *slidingWindowLimiter) peekCounter(ctx context.Context, bucketKey Bucket
 
 	res, err := slidingWindowCounterPeekScript.Run(ctx, l.redis,
 		[]string{keyCurr, keyPrev},
-		l.limit, windowMs, elapsedMs,
+		l.limit-l.floor, windowMs, elapsedMs,
 	).Result()
 	if err != nil {
 		return failOpen(l.logger, l.ruleID, err)
@@ -319,6 +356,31 @@ func (l *slidingWindowLimiter) peekCounter(ctx context.Context, bucketKey Bucket
 	}, nil
 }
 
+func (l *slidingWindowLimiter) Charge(ctx context.Context, bucketKey BucketKey, amount int) error {
+	now := apctx.GetClock(ctx).Now()
+	windowMs := l.window.Milliseconds()
+	prefix := limiterKeyPrefix(l.ruleID, bucketKey)
+
+	switch l.mode {
+	case rlschema.SlidingWindowModeLog:
+		member, err := randomHex(8)
+		if err != nil {
+			return err
+		}
+		return slidingWindowLogChargeScript.Run(ctx, l.redis,
+			[]string{fmt.Sprintf("%s:swl", prefix)},
+			l.limit, now.UnixMilli(), windowMs, member, amount,
+		).Err()
+	case rlschema.SlidingWindowModeCounter:
+		windowID := now.UnixMilli() / windowMs
+		return slidingWindowCounterChargeScript.Run(ctx, l.redis,
+			[]string{fmt.Sprintf("%s:swc:%d", prefix, windowID)},
+			amount, windowMs,
+		).Err()
+	}
+	return fmt.Errorf("unknown sliding window mode %q", l.mode)
+}
+
 func randomHex(n int) (string, error) {
 	b := make([]byte, n)
 	if _, err := rand.Read(b); err != nil {
@@ -18,13 +18,14 @@ import (
 // bucket. State (tokens, last_refill_ms) is stored as a Redis hash so the
 // two fields move atomically. New buckets are created full (tokens =
 // capacity) so the first burst gets the full benefit of the bucket
-// rather than starting empty.
+// rather than starting empty. A request is only admitted if taking its
+// token leaves at least the floor reserved for higher-priority traffic.
 //
 // Returns:
 //
-//	{1, remaining_tokens_floor}  allowed
+//	{1, remaining_tokens_floor}  allowed; remaining excludes the floor
 //	{0, retry_after_ms}          rejected; retry_after = ms until 1 token
-//	                             would be available
+//	                             above the floor would be available
 //
 // The refill_per_sec ARGV is encoded as a string with %g formatting so
 // fractional rates (e.g. 0.5 tokens/sec) round-trip cleanly.
@@ -34,6 +35,7 @@ local capacity = tonumber(ARGV[1])
 local refill_per_sec = tonumber(ARGV[2])
 local now_ms = tonumber(ARGV[3])
 local idle_ttl_ms = tonumber(ARGV[4])
+local floor = tonumber(ARGV[5])
 
 local data = redis.call('HMGET', key, 'tokens', 'last_refill_ms')
 local tokens = tonumber(data[1])
@@ -52,11 +54,11 @@ else
     last_refill_ms = now_ms
 end
 
-if tokens < 1 then
+if tokens < 1 + floor then
     -- Not enough; calculate how many ms until 1 full token accrues at
     -- the current refill rate. Persist updated state so we don't
     -- recompute the same partial refill on the next call.
-    local needed = 1 - tokens
+    local needed = 1 + floor - tokens
     local wait_ms = math.ceil(needed / refill_per_sec * 1000)
     if wait_ms < 1 then wait_ms = 1 end
     redis.call('HSET', key, 'tokens', tostring(tokens), 'last_refill_ms', tostring(last_refill_ms))
@@ -67,7 +69,7 @@ end
 tokens = tokens - 1
 redis.call('HSET', key, 'tokens', tostring(tokens), 'last_refill_ms', tostring(last_refill_ms))
 redis.call('PEXPIRE', key, idle_ttl_ms)
-return {1, math.floor(tokens)}
+return {1, math.floor(tokens - floor)}
 `)
 
 // tokenBucketPeekScript mirrors tokenBucketScript but writes nothing.
@@ -80,6 +82,7 @@ local key = KEYS[1]
 local capacity = tonumber(ARGV[1])
 local refill_per_sec = tonumber(ARGV[2])
 local now_ms = tonumber(ARGV[3])
+local floor = tonumber(ARGV[4])
 
 local data = redis.call('HMGET', key, 'tokens', 'last_refill_ms')
 local tokens = tonumber(data[1])
@@ -87,8 +90,9 @@ local last_refill_ms = tonumber(data[2])
 
 if tokens == nil or last_refill_ms == nil then
     -- No state yet: Decide would create a full bucket and consume one
-    -- token. Remaining = capacity - 1.
-    return {1, capacity - 1}
+    -- token from it.
+    tokens = capacity
+    last_refill_ms = now_ms
 end
 
 local elapsed_ms = now_ms - last_refill_ms
@@ -96,15 +100,45 @@ if elapsed_ms < 0 then elapsed_ms = 0 end
 local refill = (elapsed_ms / 1000.0) * refill_per_sec
 local projected = math.min(capacity, tokens + refill)
 
-if projected < 1 then
-    local needed = 1 - projected
+if projected < 1 + floor then
+    local needed = 1 + floor - projected
     local wait_ms = math.ceil(needed / refill_per_sec * 1000)
     if wait_ms < 1 then wait_ms = 1 end
     return {0, wait_ms}
 end
 
--- Match Decide's post-consume Remaining: floor(projected - 1).
-return {1, math.floor(projected - 1)}
+-- Match Decide's post-consume Remaining: floor(projected - 1 - floor).
+return {1, math.floor(projected - 1 - floor)}
+`)
+
+// tokenBucketChargeScript refills the bucket as Decide does and then takes
+// amount tokens out of it. The balance may go negative; Decide then waits
+// for the debt to be refilled before admitting the next request, which is
+// how an expensive request pushes later ones back.
+var tokenBucketChargeScript = redis.NewScript(`
+local key = KEYS[1]
+local capacity = tonumber(ARGV[1])
+local refill_per_sec = tonumber(ARGV[2])
+local now_ms = tonumber(ARGV[3])
+local idle_ttl_ms = tonumber(ARGV[4])
+local amount = tonumber(ARGV[5])
+
+local data = redis.call('HMGET', key, 'tokens', 'last_refill_ms')
+local tokens = tonumber(data[1])
+local last_refill_ms = tonumber(data[2])
+
+if tokens == nil or last_refill_ms == nil then
+    tokens = capacity
+else
+    local elapsed_ms = now_ms - last_refill_ms
+    if elapsed_ms < 0 then elapsed_ms = 0 end
+    tokens = math.min(capacity, tokens + (elapsed_ms / 1000.0) * refill_per_sec)
+end
+
+tokens = tokens - amount
+redis.call('HSET', key, 'tokens', tostring(tokens), 'last_refill_ms', tostring(now_ms))
+redis.call('PEXPIRE', key, idle_ttl_ms)
+return 1
 `)
 
 // tokenBucketIdleTTL is how long we keep a quiescent bucket before
@@ -116,15 +150,22 @@ type tokenBucketLimiter struct {
 	ruleID     apid.ID
 	capacity   int
 	refillRate float64
-	redis      apredis.Client
-	logger     *slog.Logger
+
+	// floor is the number of tokens reserved for higher-priority traffic
+	// than the request's; requests are only admitted while they leave at
+	// least this many in the bucket.
+	floor int
+
+	redis  apredis.Client
+	logger *slog.Logger
 }
 
-func newTokenBucketLimiter(ruleID apid.ID, params rlschema.TokenBucket, r apredis.Client, logger *slog.Logger) *tokenBucketLimiter {
+func newTokenBucketLimiter(ruleID apid.ID, params rlschema.TokenBucket, floor int, r apredis.Client, logger *slog.Logger) *tokenBucketLimiter {
 	return &tokenBucketLimiter{
 		ruleID:     ruleID,
 		capacity:   params.Capacity,
 		refillRate: params.RefillRate,
+		floor:      floor,
 		redis:      r,
 		logger:     logger,
 	}
@@ -141,7 +182,7 @@ func (l *tokenBucketLimiter) Decide(ctx context.Context, bucketKey BucketKey) (D
 
 	res, err := tokenBucketScript.Run(ctx, l.redis,
 		[]string{key},
-		l.capacity, rateStr, now.UnixMilli(), tokenBucketIdleTTL.Milliseconds(),
+		l.capacity, rateStr, now.UnixMilli(), tokenBucketIdleTTL.Milliseconds(), l.floor,
 	).Result()
 	if err != nil {
 		return failOpen(l.logger, l.ruleID, err)
@@ -169,7 +210,7 @@ func (l *tokenBucketLimiter) Peek(ctx context.Context, bucketKey BucketKey) (Dec
 
 	res, err := tokenBucketPeekScript.Run(ctx, l.redis,
 		[]string{key},
-		l.capacity, rateStr, now.UnixMilli(),
+		l.capacity, rateStr, now.UnixMilli(), l.floor,
 	).Result()
 	if err != nil {
 		return failOpen(l.logger, l.ruleID, err)
@@ -188,3 +229,15 @@ func (l *tokenBucketLimiter) Peek(ctx context.Context, bucketKey BucketKey) (Dec
 		RetryAfter: time.Duration(value) * time.Millisecond,
 	}, nil
 }
+
+func (l *tokenBucketLimiter) Charge(ctx context.Context, bucketKey BucketKey, amount int) error {
+	now := apctx.GetClock(ctx).Now()
+	key := fmt.Sprintf("%s:tb", limiterKeyPrefix(l.ruleID, bucketKey))
+
+	rateStr := strconv.FormatFloat(l.refillRate, 'g', -1, 64)
+
+	return tokenBucketChargeScript.Run(ctx, l.redis,
+		[]string{key},
+		l.capacity, rateStr, now.UnixMilli(), tokenBucketIdleTTL.Milliseconds(), amount,
+	).Err()
+}
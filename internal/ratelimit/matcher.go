@@ -2,9 +2,6 @@ package ratelimit
 
 import (
 	"fmt"
-	"path"
-	"regexp"
-	"strings"
 
 	"github.com/rmorlok/authproxy/internal/database"
 	"github.com/rmorlok/authproxy/internal/schema/common"
@@ -33,7 +30,7 @@ func Match(rule rlschema.RateLimit, ctx *RequestContext) (matched bool, key Buck
 // Reason strings stay narrow and human-readable; callers display them
 // verbatim. The same clause priority used by Match is preserved: the
 // first clause that fails wins (request_type → method → label_selector
-// → path_match).
+// → path_match → graphql → priorities).
 func MatchExplain(rule rlschema.RateLimit, ctx *RequestContext) (matched bool, key BucketKey, reason string, err error) {
 	if ctx == nil {
 		return false, BucketKey{}, "request context not provided", nil
@@ -75,9 +72,41 @@ func MatchExplain(rule rlschema.RateLimit, ctx *RequestContext) (matched bool, k
 		}
 	}
 
+	if rule.Selector.Graphql != nil && !rule.Selector.Graphql.Matches(ctx.GraphqlOperation) {
+		op := ctx.GraphqlOperation
+		switch {
+		case op == nil:
+			return false, BucketKey{}, "rule requires a GraphQL operation but the request carried none", nil
+		case op.Name == "":
+			return false, BucketKey{}, fmt.Sprintf("anonymous GraphQL %s does not match the rule's graphql clause", op.Type), nil
+		default:
+			return false, BucketKey{}, fmt.Sprintf("GraphQL %s %q does not match the rule's graphql clause", op.Type, op.Name), nil
+		}
+	}
+
+	if !matchPriorities(rule.Selector.Priorities, ctx.Priority) {
+		return false, BucketKey{}, fmt.Sprintf("priority %q does not match the rule's priority list", string(ctx.Priority.Effective())), nil
+	}
+
 	return true, ResolveBucketKey(rule, ctx), "", nil
 }
 
+// matchPriorities returns true when the rule has no priority restriction
+// (nil/empty list = any) or when the request's priority is in the list.
+// Untagged requests match as the default priority.
+func matchPriorities(allowed []common.RequestPriority, ctxPriority common.RequestPriority) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	p := ctxPriority.Effective()
+	for _, a := range allowed {
+		if a == p {
+			return true
+		}
+	}
+	return false
+}
+
 // matchRequestType returns true if ctxType is in the rule's allowed list.
 // allowed is the *effective* list — callers should pass
 // rule.Selector.EffectiveRequestTypes() so the default [proxy, probe] is
@@ -140,28 +169,8 @@ func matchPath(pm *rlschema.PathMatch, p string, urlPresent bool) (bool, error)
 	if !urlPresent {
 		return false, nil
 	}
-	switch pm.Kind {
-	case rlschema.PathMatchKindPrefix:
-		return strings.HasPrefix(p, pm.Value), nil
-	case rlschema.PathMatchKindGlob:
-		// path.Match returns ErrBadPattern for malformed globs. Schema
-		// validation doesn't yet round-trip the glob through path.Match,
-		// so propagate the error rather than silently failing closed.
-		ok, err := path.Match(pm.Value, p)
-		if err != nil {
-			return false, err
-		}
-		return ok, nil
-	case rlschema.PathMatchKindRegex:
-		// Compile-on-call. The schema validator already proved it
-		// compiles, so this is wasted work in steady state — fix when
-		// the enforcement layer has a place to cache compiled regexes.
-		re, err := regexp.Compile(pm.Value)
-		if err != nil {
-			return false, err
-		}
-		return re.MatchString(p), nil
-	default:
-		return false, fmt.Errorf("unknown path match kind %q", pm.Kind)
-	}
+	// Compile-on-call for regexes. The schema validator already proved
+	// they compile, so this is wasted work in steady state — fix when the
+	// enforcement layer has a place to cache compiled regexes.
+	return pm.Matches(p)
 }
@@ -4,6 +4,7 @@ import (
 	"net/url"
 	"testing"
 
+	"github.com/rmorlok/authproxy/internal/apgraphql"
 	"github.com/rmorlok/authproxy/internal/apid"
 	"github.com/rmorlok/authproxy/internal/schema/common"
 	rlschema "github.com/rmorlok/authproxy/internal/schema/resources/rate_limit"
@@ -290,6 +291,80 @@ func TestMatch_PathMatch_UnknownKindReturnsError(t *testing.T) {
 
 // --- AND combinations ---
 
+// --- GraphQL matching ---
+
+func TestMatch_Graphql(t *testing.T) {
+	rule := validRule(func(r *rlschema.RateLimit) {
+		r.Selector.Graphql = &rlschema.GraphqlMatch{
+			OperationTypes: []apgraphql.OperationType{apgraphql.OperationTypeMutation},
+		}
+	})
+	withOp := func(op *apgraphql.Operation) *RequestContext {
+		return proxyCtx(func(c *RequestContext) {
+			c.Method = "POST"
+			c.GraphqlOperation = op
+		})
+	}
+
+	matched, _, reason, err := MatchExplain(rule, withOp(&apgraphql.Operation{Type: apgraphql.OperationTypeMutation, Name: "CreateOrder"}))
+	require.NoError(t, err)
+	require.True(t, matched, reason)
+
+	matched, _, reason, err = MatchExplain(rule, withOp(&apgraphql.Operation{Type: apgraphql.OperationTypeQuery, Name: "GetProducts"}))
+	require.NoError(t, err)
+	require.False(t, matched)
+	require.Equal(t, `GraphQL query "GetProducts" does not match the rule's graphql clause`, reason)
+
+	matched, _, reason, err = MatchExplain(rule, withOp(&apgraphql.Operation{Type: apgraphql.OperationTypeQuery}))
+	require.NoError(t, err)
+	require.False(t, matched)
+	require.Equal(t, "anonymous GraphQL query does not match the rule's graphql clause", reason)
+
+	matched, _, reason, err = MatchExplain(rule, withOp(nil))
+	require.NoError(t, err)
+	require.False(t, matched)
+	require.Contains(t, reason, "carried none")
+}
+
+func TestMatch_Graphql_OmittedMatchesAnyRequest(t *testing.T) {
+	matched, _, err := Match(validRule(nil), proxyCtx(nil))
+	require.NoError(t, err)
+	require.True(t, matched)
+}
+
+// --- Priority matching ---
+
+func TestMatch_Priorities(t *testing.T) {
+	rule := validRule(func(r *rlschema.RateLimit) {
+		r.Selector.Priorities = []common.RequestPriority{common.RequestPriorityNormal, common.RequestPriorityBatch}
+	})
+	withPriority := func(p common.RequestPriority) *RequestContext {
+		return proxyCtx(func(c *RequestContext) { c.Priority = p })
+	}
+
+	matched, _, reason, err := MatchExplain(rule, withPriority(common.RequestPriorityBatch))
+	require.NoError(t, err)
+	require.True(t, matched, reason)
+
+	// Untagged requests are normal priority.
+	matched, _, reason, err = MatchExplain(rule, withPriority(""))
+	require.NoError(t, err)
+	require.True(t, matched, reason)
+
+	matched, _, reason, err = MatchExplain(rule, withPriority(common.RequestPriorityInteractive))
+	require.NoError(t, err)
+	require.False(t, matched)
+	require.Equal(t, `priority "interactive" does not match the rule's priority list`, reason)
+}
+
+func TestMatch_Priorities_EmptyMatchesAny(t *testing.T) {
+	for _, p := range common.AllRequestPriorities() {
+		matched, _, err := Match(validRule(nil), proxyCtx(func(c *RequestContext) { c.Priority = p }))
+		require.NoError(t, err)
+		require.True(t, matched, "priority %s should match an unrestricted rule", p)
+	}
+}
+
 func TestMatch_AllClausesANDed(t *testing.T) {
 	// All four clauses populated; one failure short-circuits the whole match.
 	rule := validRule(func(r *rlschema.RateLimit) {
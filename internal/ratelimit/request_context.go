@@ -3,6 +3,7 @@ package ratelimit
 import (
 	"net/url"
 
+	"github.com/rmorlok/authproxy/internal/apgraphql"
 	"github.com/rmorlok/authproxy/internal/apid"
 	"github.com/rmorlok/authproxy/internal/schema/common"
 )
@@ -47,4 +48,15 @@ type RequestContext struct {
 	// evaluated against. Carry-forward / system labels are expected to
 	// already be merged in by the caller.
 	Labels map[string]string
+
+	// GraphqlOperation is the GraphQL operation the proxy identified in the
+	// request body, or nil for requests that aren't GraphQL (or weren't
+	// buffered). Evaluated by the selector's graphql clause and the
+	// graphql_operation bucket dimension.
+	GraphqlOperation *apgraphql.Operation
+
+	// Priority is the priority class the request was tagged with. Empty
+	// for untagged traffic, which is treated as the default priority by
+	// the selector's priorities clause and by rules that reserve capacity.
+	Priority common.RequestPriority
 }
@@ -7,7 +7,6 @@ import (
 	"io"
 	"log/slog"
 	"math"
-	"math/rand"
 	"net/http"
 	"strconv"
 	"time"
@@ -21,8 +20,9 @@ import (
 
 // Factory implements httpf.RoundTripperFactory to create rate limiting roundtrippers.
 type Factory struct {
-	store  *Store
-	logger *slog.Logger
+	store     *Store
+	telemetry *Telemetry
+	logger    *slog.Logger
 }
 
 // NewFactory creates a new rate limiting middleware factory.
@@ -33,6 +33,13 @@ func NewFactory(store *Store, logger *slog.Logger) *Factory {
 	}
 }
 
+// WithTelemetry sets the metrics the roundtrippers emit. Returns the factory
+// for chaining.
+func (f *Factory) WithTelemetry(t *Telemetry) *Factory {
+	f.telemetry = t
+	return f
+}
+
 func (f *Factory) NewRoundTripper(ri httpf.RequestInfo, transport http.RoundTripper) http.RoundTripper {
 	// Only apply rate limiting for proxy and probe requests with a connection context
 	if ri.ConnectionId == apid.Nil {
@@ -48,19 +55,24 @@ func (f *Factory) NewRoundTripper(ri httpf.RequestInfo, transport http.RoundTrip
 	}
 
 	return &RoundTripper{
+		connectorId:  ri.ConnectorId,
 		connectionId: ri.ConnectionId,
 		config:       ri.RateLimiting, // nil means use defaults
 		store:        f.store,
+		telemetry:    f.telemetry,
 		transport:    transport,
 		logger:       f.logger,
 	}
 }
 
-// RoundTripper is an http.RoundTripper that enforces 429-based rate limiting per connection.
+// RoundTripper is an http.RoundTripper that enforces 429-based rate limiting per connection, and, when the
+// connector configures headroom, holds back requests once the upstream's reported budget runs out.
 type RoundTripper struct {
+	connectorId  apid.ID
 	connectionId apid.ID
 	config       *connectors.RateLimiting // nil means use defaults
 	store        *Store
+	telemetry    *Telemetry
 	transport    http.RoundTripper
 	logger       *slog.Logger
 }
@@ -92,12 +104,23 @@ func (rt *RoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
 		return rt.syntheticTooManyRequests(remaining), nil
 	}
 
+	headroom := rt.getConfig().GetHeadroom()
+	if headroom != nil {
+		if resp, err := rt.reserveHeadroom(ctx, headroom); resp != nil || err != nil {
+			return resp, err
+		}
+	}
+
 	// Execute the actual request
 	resp, err := rt.transport.RoundTrip(req)
 	if err != nil {
 		return resp, err
 	}
 
+	if headroom != nil {
+		rt.recordHeadroom(ctx, headroom, resp)
+	}
+
 	// Handle the response
 	if resp.StatusCode == http.StatusTooManyRequests {
 		rt.handle429(ctx, resp)
@@ -161,6 +184,74 @@ func (rt *RoundTripper) handle429(ctx context.Context, resp *http.Response) {
 	)
 }
 
+// reserveHeadroom spends one request of the headroom learned from the upstream's rate-limit headers. When
+// only the reserve is left, the request waits for the upstream window to reset if that is within the
+// configured max delay, then tries to reserve again: requests held for the same reset compete for the next
+// window's budget rather than all going out at once. Requests that can't be admitted within the max delay
+// are rejected with a synthetic 429. Returns a nil response when the request may proceed.
+func (rt *RoundTripper) reserveHeadroom(ctx context.Context, cfg *connectors.RateLimitHeadroom) (*http.Response, error) {
+	clock := apctx.GetClock(ctx)
+	deadline := clock.Now().Add(cfg.GetMaxDelay())
+
+	for {
+		now := clock.Now()
+		wait, allowed, err := rt.store.ReserveHeadroom(ctx, rt.connectionId, cfg.GetReserve(), now)
+		if err != nil {
+			rt.logger.WarnContext(ctx, "failed to reserve rate limit headroom",
+				slog.String("connection_id", rt.connectionId.String()),
+				slog.String("error", err.Error()),
+			)
+			// On Redis errors, allow the request through rather than blocking
+			return nil, nil
+		}
+		if allowed {
+			return nil, nil
+		}
+
+		if wait > deadline.Sub(now) {
+			rt.logger.InfoContext(ctx, "request blocked by upstream rate limit headroom",
+				slog.String("connection_id", rt.connectionId.String()),
+				slog.Duration("retry_after", wait),
+			)
+			if attr := app_metrics.AttributionFromContext(ctx); attr != nil {
+				attr.Source = app_metrics.ResponseSourceConnectorRateLimiter
+			}
+			return rt.syntheticTooManyRequests(wait), nil
+		}
+
+		rt.logger.InfoContext(ctx, "request delayed until upstream rate limit resets",
+			slog.String("connection_id", rt.connectionId.String()),
+			slog.Duration("delay", wait),
+		)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-clock.After(wait):
+		}
+	}
+}
+
+// recordHeadroom stores the headroom reported on the response's rate-limit headers, if any.
+func (rt *RoundTripper) recordHeadroom(ctx context.Context, cfg *connectors.RateLimitHeadroom, resp *http.Response) {
+	now := apctx.GetClock(ctx).Now()
+
+	h, ok := ParseHeadroom(resp.Header, cfg, now)
+	if !ok {
+		return
+	}
+
+	h, err := rt.store.RecordHeadroom(ctx, rt.connectionId, h, now)
+	if err != nil {
+		rt.logger.WarnContext(ctx, "failed to record rate limit headroom",
+			slog.String("connection_id", rt.connectionId.String()),
+			slog.String("error", err.Error()),
+		)
+		return
+	}
+
+	rt.telemetry.recordUpstreamRemaining(ctx, rt.connectorId, rt.connectionId, h.Remaining)
+}
+
 func (rt *RoundTripper) computeBackoff(ctx context.Context) time.Duration {
 	cfg := rt.getConfig()
 
@@ -180,26 +271,7 @@ func (rt *RoundTripper) computeBackoff(ctx context.Context) time.Duration {
 		return cfg.GetDefaultRetryAfter()
 	}
 
-	// Compute: initial * multiplier^count
-	initial := eb.GetInitialInterval()
-	multiplier := eb.GetMultiplier()
-	maxInterval := eb.GetMaxInterval()
-
-	backoff := float64(initial) * math.Pow(multiplier, float64(count))
-
-	// Apply jitter
-	jitter := eb.GetJitterFraction()
-	if jitter > 0 {
-		jitterRange := backoff * jitter
-		backoff = backoff - jitterRange + rand.Float64()*2*jitterRange
-	}
-
-	d := time.Duration(backoff)
-	if d > maxInterval {
-		d = maxInterval
-	}
-
-	return d
+	return eb.Interval(count)
 }
 
 func (rt *RoundTripper) getConfig() *connectors.RateLimiting {
@@ -210,6 +282,10 @@ func (rt *RoundTripper) getConfig() *connectors.RateLimiting {
 	return nil
 }
 
+// HeaderRateLimited marks a 429 that AuthProxy produced itself, rather than
+// one returned by the upstream.
+const HeaderRateLimited = "X-Authproxy-Ratelimited"
+
 func (rt *RoundTripper) syntheticTooManyRequests(retryAfter time.Duration) *http.Response {
 	retryAfterSeconds := int(math.Ceil(retryAfter.Seconds()))
 	body := fmt.Sprintf(`{"error":"rate limited","retryAfterSeconds":%d}`, retryAfterSeconds)
@@ -217,9 +293,9 @@ func (rt *RoundTripper) syntheticTooManyRequests(retryAfter time.Duration) *http
 	return &http.Response{
 		StatusCode: http.StatusTooManyRequests,
 		Header: http.Header{
-			"Content-Type":            {"application/json"},
-			"Retry-After":             {strconv.Itoa(retryAfterSeconds)},
-			"X-Authproxy-Ratelimited": {"true"},
+			"Content-Type":    {"application/json"},
+			"Retry-After":     {strconv.Itoa(retryAfterSeconds)},
+			HeaderRateLimited: {"true"},
 		},
 		Body:          io.NopCloser(bytes.NewBufferString(body)),
 		ContentLength: int64(len(body)),
@@ -0,0 +1,280 @@
+package ratelimit
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/rmorlok/authproxy/internal/apctx"
+	"github.com/rmorlok/authproxy/internal/apid"
+	"github.com/rmorlok/authproxy/internal/apredis"
+	"github.com/rmorlok/authproxy/internal/schema/common"
+)
+
+// shapePollInterval is how often a queued request checks whether it has
+// reached the head of its queue. The head re-checks the bucket at least
+// this often, sooner if the limiter says it will allow sooner.
+const shapePollInterval = 100 * time.Millisecond
+
+// shapeRoundWidth spaces the queue scores so every ticket in fairness
+// round n sorts before any in round n+1, with arrival order inside a round.
+const shapeRoundWidth = 1e9
+
+// shapePriorityWidth spaces the queue scores so every ticket of a higher
+// priority sorts before any of a lower one, whatever its fairness round.
+// Rounds are bounded by MaxShapingMaxQueueDepth, well below this.
+const shapePriorityWidth = 1e13
+
+// shapeQueue is the per-bucket queue a shape-mode rule holds requests in.
+// Four keys share the limiter prefix:
+//
+//	:sq ZSET ticket -> round*shapeRoundWidth + arrival sequence
+//	:sd ZSET ticket -> deadline ms, so tickets abandoned by a process that
+//	    died are evicted
+//	:sa HASH actor  -> tickets that actor has queued
+//	:ss counter     -> arrival sequence
+//
+// A ticket's round is how many tickets its actor already has queued, so
+// the queue admits one request from each waiting actor before a second
+// from any of them. Higher-priority tickets are admitted before any
+// lower-priority ones, so batch requests waiting on capacity reserved for
+// other classes don't hold up the requests that may use it. Tickets are
+// "<random>|<actor>".
+type shapeQueue struct {
+	prefix string
+	redis  apredis.Client
+}
+
+func newShapeQueue(ruleID apid.ID, bucketKey BucketKey, r apredis.Client) *shapeQueue {
+	return &shapeQueue{prefix: limiterKeyPrefix(ruleID, bucketKey), redis: r}
+}
+
+func (q *shapeQueue) keys() []string {
+	return []string{q.prefix + ":sq", q.prefix + ":sd", q.prefix + ":sa", q.prefix + ":ss"}
+}
+
+// shapeEvictLua removes tickets past their deadline. Shared by every script
+// that reads the queue so a dead process's tickets can't block it.
+const shapeEvictLua = `
+local function evict(q, d, a, now_ms)
+    local expired = redis.call('ZRANGEBYSCORE', d, '-inf', now_ms)
+    for _, ticket in ipairs(expired) do
+        if redis.call('ZREM', q, ticket) == 1 then
+            local actor = string.match(ticket, '^[^|]*|(.*)$') or ''
+            if redis.call('HINCRBY', a, actor, -1) <= 0 then
+                redis.call('HDEL', a, actor)
+            end
+        end
+        redis.call('ZREM', d, ticket)
+    end
+end
+`
+
+// shapeLenScript returns the number of live tickets in the queue.
+var shapeLenScript = redis.NewScript(shapeEvictLua + `
+evict(KEYS[1], KEYS[2], KEYS[3], tonumber(ARGV[1]))
+return redis.call('ZCARD', KEYS[1])
+`)
+
+// shapeJoinScript adds a ticket unless the queue is full. Returns 1 if
+// the ticket was queued, 0 if not.
+var shapeJoinScript = redis.NewScript(shapeEvictLua + `
+local now_ms = tonumber(ARGV[1])
+local deadline_ms = tonumber(ARGV[2])
+local max_depth = tonumber(ARGV[3])
+local actor = ARGV[4]
+local ticket = ARGV[5]
+local round_width = tonumber(ARGV[6])
+local priority_offset = tonumber(ARGV[7])
+
+evict(KEYS[1], KEYS[2], KEYS[3], now_ms)
+if redis.call('ZCARD', KEYS[1]) >= max_depth then
+    return 0
+end
+
+local round = tonumber(redis.call('HGET', KEYS[3], actor) or '0')
+local seq = redis.call('INCR', KEYS[4]) % round_width
+redis.call('ZADD', KEYS[1], priority_offset + round * round_width + seq, ticket)
+redis.call('ZADD', KEYS[2], deadline_ms, ticket)
+redis.call('HINCRBY', KEYS[3], actor, 1)
+
+-- Every ticket leaves by its deadline, so the keys can't outlive the
+-- newest one by more than the slack.
+local ttl_ms = deadline_ms - now_ms + 1000
+for i = 1, 4 do
+    redis.call('PEXPIRE', KEYS[i], ttl_ms)
+end
+return 1
+`)
+
+// shapeHeadScript returns 1 if ticket is at the head of the queue.
+var shapeHeadScript = redis.NewScript(shapeEvictLua + `
+evict(KEYS[1], KEYS[2], KEYS[3], tonumber(ARGV[1]))
+local head = redis.call('ZRANGE', KEYS[1], 0, 0)
+if #head == 1 and head[1] == ARGV[2] then
+    return 1
+end
+return 0
+`)
+
+// shapeLeaveScript removes a ticket. Safe to run for a ticket that was
+// already evicted.
+var shapeLeaveScript = redis.NewScript(`
+local ticket = ARGV[1]
+local actor = ARGV[2]
+redis.call('ZREM', KEYS[2], ticket)
+if redis.call('ZREM', KEYS[1], ticket) == 1 then
+    if redis.call('HINCRBY', KEYS[3], actor, -1) <= 0 then
+        redis.call('HDEL', KEYS[3], actor)
+    end
+end
+return 1
+`)
+
+// len returns how many requests are waiting.
+func (q *shapeQueue) len(ctx context.Context) (int, error) {
+	now := apctx.GetClock(ctx).Now()
+	return shapeLenScript.Run(ctx, q.redis, q.keys(), now.UnixMilli()).Int()
+}
+
+// join queues a ticket for actor, behind any of a higher priority, that is
+// evicted at deadline if it hasn't left by then. Returns "" if the queue is
+// full.
+func (q *shapeQueue) join(ctx context.Context, actor string, priority common.RequestPriority, deadline time.Time, maxDepth int) (string, error) {
+	now := apctx.GetClock(ctx).Now()
+	id, err := randomHex(8)
+	if err != nil {
+		return "", err
+	}
+	ticket := id + "|" + actor
+
+	joined, err := shapeJoinScript.Run(ctx, q.redis, q.keys(),
+		now.UnixMilli(), deadline.UnixMilli(), maxDepth, actor, ticket, int64(shapeRoundWidth),
+		int64(priority.Rank())*int64(shapePriorityWidth),
+	).Int()
+	if err != nil {
+		return "", err
+	}
+	if joined == 0 {
+		return "", nil
+	}
+	return ticket, nil
+}
+
+// atHead reports whether ticket is next to be admitted.
+func (q *shapeQueue) atHead(ctx context.Context, ticket string) (bool, error) {
+	now := apctx.GetClock(ctx).Now()
+	head, err := shapeHeadScript.Run(ctx, q.redis, q.keys(), now.UnixMilli(), ticket).Int()
+	if err != nil {
+		return false, err
+	}
+	return head == 1, nil
+}
+
+// leave removes ticket from the queue.
+func (q *shapeQueue) leave(ctx context.Context, actor, ticket string) error {
+	return shapeLeaveScript.Run(ctx, q.redis, q.keys(), ticket, actor).Err()
+}
+
+// waitForAdmission holds the request until m's shape-mode rule admits it,
+// returning whether it was admitted and how long it waited. Requests join
+// the bucket's queue when the rule rejects them — or straight away if
+// others are already waiting, so newcomers can't jump the queue — and
+// only the head of the queue retries the limiter. A request is rejected
+// if the queue is full or it can't be admitted within Shaping.MaxWait.
+//
+// A non-nil error means ctx ended while the request was waiting. Redis
+// errors fail open like the limiters do.
+func (rt *EnforcerRoundTripper) waitForAdmission(ctx context.Context, m *matchedRule, actor string) (bool, time.Duration, error) {
+	shaping := m.rule.Definition.Shaping
+	clock := apctx.GetClock(ctx)
+	start := clock.Now()
+	maxWait := shaping.MaxWait.Duration
+	deadline := start.Add(maxWait)
+	q := newShapeQueue(m.rule.Id, m.bucket, rt.redis)
+
+	waiting, err := q.len(ctx)
+	if err != nil {
+		return rt.shapeFailOpen(ctx, m, err), 0, nil
+	}
+	if waiting == 0 {
+		m.decision, _ = m.limiter.Decide(ctx, m.bucket)
+		if m.decision.Allowed {
+			return true, 0, nil
+		}
+		if m.decision.RetryAfter > maxWait {
+			return false, 0, nil
+		}
+	}
+
+	ticket, err := q.join(ctx, actor, m.priority, deadline.Add(shapePollInterval), shaping.GetMaxQueueDepth())
+	if err != nil {
+		return rt.shapeFailOpen(ctx, m, err), 0, nil
+	}
+	if ticket == "" {
+		// Full. Nothing was decided if others were already waiting, so
+		// suggest retrying once the queue has had time to drain.
+		if m.decision.RetryAfter == 0 {
+			m.decision = Decision{RetryAfter: maxWait}
+		}
+		return false, 0, nil
+	}
+	defer func() {
+		// Leave even when the caller has gone; the ticket would otherwise
+		// block the queue until its deadline.
+		if err := q.leave(context.WithoutCancel(ctx), actor, ticket); err != nil {
+			rt.logger.WarnContext(ctx, "rate-limit shaping queue leave failed",
+				slog.String("rule_id", string(m.rule.Id)),
+				slog.String("error", err.Error()),
+			)
+		}
+	}()
+
+	for {
+		wait := shapePollInterval
+		head, err := q.atHead(ctx, ticket)
+		if err != nil {
+			return rt.shapeFailOpen(ctx, m, err), clock.Since(start), nil
+		}
+		if head {
+			// Peek first so polling doesn't count against the bucket.
+			d, _ := m.limiter.Peek(ctx, m.bucket)
+			if d.Allowed {
+				d, _ = m.limiter.Decide(ctx, m.bucket)
+			}
+			if d.Allowed {
+				m.decision = d
+				return true, clock.Since(start), nil
+			}
+			m.decision = d
+			if d.RetryAfter > 0 {
+				wait = min(wait, d.RetryAfter)
+			}
+		}
+
+		remaining := deadline.Sub(clock.Now())
+		if remaining <= 0 {
+			if m.decision.RetryAfter == 0 {
+				m.decision = Decision{RetryAfter: maxWait}
+			}
+			return false, clock.Since(start), nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return false, clock.Since(start), ctx.Err()
+		case <-clock.After(min(wait, remaining)):
+		}
+	}
+}
+
+// shapeFailOpen admits a request whose queue couldn't be reached.
+func (rt *EnforcerRoundTripper) shapeFailOpen(ctx context.Context, m *matchedRule, err error) bool {
+	rt.logger.WarnContext(ctx, "rate-limit shaping queue unavailable; failing open",
+		slog.String("rule_id", string(m.rule.Id)),
+		slog.String("error", err.Error()),
+	)
+	m.decision = Decision{Allowed: true, FailedOpen: true}
+	return true
+}
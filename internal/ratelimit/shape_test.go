@@ -0,0 +1,340 @@
+package ratelimit
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/rmorlok/authproxy/internal/aplog"
+	"github.com/rmorlok/authproxy/internal/app_metrics"
+	"github.com/rmorlok/authproxy/internal/database"
+	"github.com/rmorlok/authproxy/internal/schema/common"
+	rlschema "github.com/rmorlok/authproxy/internal/schema/resources/rate_limit"
+	"github.com/stretchr/testify/require"
+)
+
+func shapeDef(alg rlschema.Algorithm, maxWait time.Duration, maxQueueDepth int) rlschema.RateLimit {
+	return rlschema.RateLimit{
+		Mode:      rlschema.ModeShape,
+		Bucket:    rlschema.Bucket{Dimensions: []string{rlschema.DimensionConnection}},
+		Algorithm: alg,
+		Shaping: &rlschema.Shaping{
+			MaxWait:       common.HumanDuration{Duration: maxWait},
+			MaxQueueDepth: maxQueueDepth,
+		},
+	}
+}
+
+func shapeTokenBucket(refillRate float64) rlschema.Algorithm {
+	return rlschema.Algorithm{TokenBucket: &rlschema.TokenBucket{Capacity: 1, RefillRate: refillRate}}
+}
+
+func shapeConcurrency() rlschema.Algorithm {
+	return rlschema.Algorithm{Concurrency: &rlschema.Concurrency{Limit: 1}}
+}
+
+// holdConcurrencySlot takes rule's only slot for connectionA outside the
+// enforcer and returns a func that gives it back.
+func holdConcurrencySlot(t *testing.T, env *enforcerEnv, rule *database.RateLimit) func() {
+	t.Helper()
+	l, err := NewLimiter(rule, env.rds, aplog.NewNoopLogger())
+	require.NoError(t, err)
+	d, err := l.Decide(env.ctx(), connectionA)
+	require.NoError(t, err)
+	require.True(t, d.Allowed)
+	return func() {
+		require.NoError(t, l.(Leaser).Release(env.ctx(), connectionA, d.LeaseID))
+	}
+}
+
+type roundTripResult struct {
+	resp *http.Response
+	err  error
+}
+
+func roundTripAsync(rt http.RoundTripper, req *http.Request) <-chan roundTripResult {
+	done := make(chan roundTripResult, 1)
+	go func() {
+		resp, err := rt.RoundTrip(req)
+		done <- roundTripResult{resp: resp, err: err}
+	}()
+	return done
+}
+
+// waitQueued blocks until a queued request is sleeping on the clock.
+func waitQueued(t *testing.T, env *enforcerEnv) {
+	t.Helper()
+	require.Eventually(t, env.clock.HasWaiters, time.Second, time.Millisecond)
+}
+
+// stepUntilDone advances the clock by d each time the queued request
+// sleeps, until its round trip finishes.
+func stepUntilDone(t *testing.T, env *enforcerEnv, done <-chan roundTripResult, d time.Duration) roundTripResult {
+	t.Helper()
+	for i := 0; i < 1000; i++ {
+		require.Eventually(t, func() bool { return env.clock.HasWaiters() || len(done) > 0 }, time.Second, time.Millisecond)
+		select {
+		case r := <-done:
+			return r
+		default:
+		}
+		env.step(d)
+	}
+	t.Fatal("round trip never finished")
+	return roundTripResult{}
+}
+
+func queueLen(t *testing.T, env *enforcerEnv, rule *database.RateLimit) int {
+	t.Helper()
+	n, err := newShapeQueue(rule.Id, connectionA, env.rds).len(env.ctx())
+	require.NoError(t, err)
+	return n
+}
+
+func TestEnforcer_Shape_WaitsUntilAdmitted(t *testing.T) {
+	env := newEnforcerEnv(t)
+	rule := mkEnfRule("rl_shape", shapeDef(shapeTokenBucket(1), 5*time.Second, 0))
+	env.loadRules(rule)
+	ft := newFakeTransport()
+	rt := newEnforcer(t, env, proxyRI("cxn_a", "act_a"), ft)
+
+	ctx, attr := env.ctxWithAttr()
+	resp, err := rt.RoundTrip(mkProxyReq(t, ctx, "https://api.example.com/x"))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Zero(t, attr.RateLimitQueueWait)
+
+	ft.called = false
+	ctx, attr = env.ctxWithAttr()
+	done := roundTripAsync(rt, mkProxyReq(t, ctx, "https://api.example.com/x"))
+	r := stepUntilDone(t, env, done, shapePollInterval)
+	require.NoError(t, r.err)
+	require.Equal(t, http.StatusOK, r.resp.StatusCode)
+	require.True(t, ft.called)
+
+	require.Equal(t, time.Second, attr.RateLimitQueueWait, "the bucket refills one token a second")
+	require.Equal(t, rule.Id, attr.RateLimitId)
+	require.Equal(t, string(rlschema.ModeShape), attr.RateLimitMode)
+	require.NotEqual(t, app_metrics.ResponseSourceRateLimit, attr.Source)
+	require.Zero(t, queueLen(t, env, rule))
+}
+
+func TestEnforcer_Shape_RejectsWhenWaitWouldExceedMaxWait(t *testing.T) {
+	env := newEnforcerEnv(t)
+	// One token every 10s can't be waited for within 5s.
+	rule := mkEnfRule("rl_shape", shapeDef(shapeTokenBucket(0.1), 5*time.Second, 0))
+	env.loadRules(rule)
+	ft := newFakeTransport()
+	rt := newEnforcer(t, env, proxyRI("cxn_a", "act_a"), ft)
+
+	_, err := rt.RoundTrip(mkProxyReq(t, env.ctx(), "https://api.example.com/x"))
+	require.NoError(t, err)
+
+	ft.called = false
+	ctx, attr := env.ctxWithAttr()
+	resp, err := rt.RoundTrip(mkProxyReq(t, ctx, "https://api.example.com/x"))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	require.Equal(t, "10", resp.Header.Get("Retry-After"))
+	require.Equal(t, "rl_shape", resp.Header.Get("X-Authproxy-Ratelimit"))
+	require.False(t, ft.called)
+
+	require.Equal(t, app_metrics.ResponseSourceRateLimit, attr.Source)
+	require.Equal(t, string(rlschema.ModeShape), attr.RateLimitMode)
+	require.Zero(t, attr.RateLimitQueueWait)
+	require.Zero(t, queueLen(t, env, rule))
+}
+
+func TestEnforcer_Shape_RejectsAtMaxWait(t *testing.T) {
+	env := newEnforcerEnv(t)
+	rule := mkEnfRule("rl_shape", shapeDef(shapeConcurrency(), 2*time.Second, 0))
+	env.loadRules(rule)
+	release := holdConcurrencySlot(t, env, rule)
+	defer release()
+	ft := newFakeTransport()
+	rt := newEnforcer(t, env, proxyRI("cxn_a", "act_a"), ft)
+
+	ctx, attr := env.ctxWithAttr()
+	done := roundTripAsync(rt, mkProxyReq(t, ctx, "https://api.example.com/x"))
+	r := stepUntilDone(t, env, done, shapePollInterval)
+	require.NoError(t, r.err)
+	require.Equal(t, http.StatusTooManyRequests, r.resp.StatusCode)
+	require.False(t, ft.called)
+
+	require.Equal(t, 2*time.Second, attr.RateLimitQueueWait)
+	require.Equal(t, app_metrics.ResponseSourceRateLimit, attr.Source)
+	require.Equal(t, string(rlschema.ModeShape), attr.RateLimitMode)
+	require.Zero(t, queueLen(t, env, rule), "a rejected request leaves the queue")
+}
+
+func TestEnforcer_Shape_RejectsWhenQueueFull(t *testing.T) {
+	env := newEnforcerEnv(t)
+	rule := mkEnfRule("rl_shape", shapeDef(shapeConcurrency(), 5*time.Second, 1))
+	env.loadRules(rule)
+	release := holdConcurrencySlot(t, env, rule)
+	rt := newEnforcer(t, env, proxyRI("cxn_a", "act_a"), newFakeTransport())
+
+	queued := roundTripAsync(rt, mkProxyReq(t, env.ctx(), "https://api.example.com/x"))
+	waitQueued(t, env)
+	require.Equal(t, 1, queueLen(t, env, rule))
+
+	ctx, attr := env.ctxWithAttr()
+	resp, err := rt.RoundTrip(mkProxyReq(t, ctx, "https://api.example.com/x"))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	require.Equal(t, "5", resp.Header.Get("Retry-After"))
+	require.Zero(t, attr.RateLimitQueueWait)
+
+	// Once the slot frees up, the queued request goes ahead.
+	release()
+	r := stepUntilDone(t, env, queued, shapePollInterval)
+	require.NoError(t, r.err)
+	require.Equal(t, http.StatusOK, r.resp.StatusCode)
+}
+
+func TestEnforcer_Shape_NewArrivalsQueueBehindWaiters(t *testing.T) {
+	env := newEnforcerEnv(t)
+	rule := mkEnfRule("rl_shape", shapeDef(shapeConcurrency(), 5*time.Second, 0))
+	env.loadRules(rule)
+	release := holdConcurrencySlot(t, env, rule)
+	rt := newEnforcer(t, env, proxyRI("cxn_a", "act_a"), newFakeTransport())
+
+	queued := roundTripAsync(rt, mkProxyReq(t, env.ctx(), "https://api.example.com/x"))
+	waitQueued(t, env)
+
+	// The slot is free, but a request is already waiting for it.
+	release()
+	ctx, attr := env.ctxWithAttr()
+	late := roundTripAsync(rt, mkProxyReq(t, ctx, "https://api.example.com/x"))
+	require.Eventually(t, func() bool { return queueLen(t, env, rule) == 2 }, time.Second, time.Millisecond)
+
+	r := stepUntilDone(t, env, queued, shapePollInterval)
+	require.Equal(t, http.StatusOK, r.resp.StatusCode)
+	require.NoError(t, r.resp.Body.Close())
+
+	r = stepUntilDone(t, env, late, shapePollInterval)
+	require.Equal(t, http.StatusOK, r.resp.StatusCode)
+	require.Positive(t, attr.RateLimitQueueWait)
+}
+
+func TestEnforcer_Shape_CancelledWhileQueued(t *testing.T) {
+	env := newEnforcerEnv(t)
+	rule := mkEnfRule("rl_shape", shapeDef(shapeConcurrency(), 5*time.Second, 0))
+	env.loadRules(rule)
+	release := holdConcurrencySlot(t, env, rule)
+	defer release()
+	ft := newFakeTransport()
+	rt := newEnforcer(t, env, proxyRI("cxn_a", "act_a"), ft)
+
+	attrCtx, attr := env.ctxWithAttr()
+	ctx, cancel := context.WithCancel(attrCtx)
+	done := roundTripAsync(rt, mkProxyReq(t, ctx, "https://api.example.com/x"))
+	waitQueued(t, env)
+	env.step(shapePollInterval)
+	waitQueued(t, env)
+	cancel()
+
+	r := <-done
+	require.ErrorIs(t, r.err, context.Canceled)
+	require.False(t, ft.called)
+	require.Equal(t, shapePollInterval, attr.RateLimitQueueWait)
+	require.Zero(t, queueLen(t, env, rule))
+}
+
+func TestEnforcer_Shape_FailsOpenOnRedisDown(t *testing.T) {
+	env := newEnforcerEnv(t)
+	env.loadRules(mkEnfRule("rl_shape", shapeDef(shapeTokenBucket(0.1), 5*time.Second, 0)))
+	ft := newFakeTransport()
+	f := NewEnforcerFactory(env.cache, newFailedRedis(t), aplog.NewNoopLogger())
+	rt := f.NewRoundTripper(proxyRI("cxn_a", "act_a"), ft)
+
+	for i := 0; i < 3; i++ {
+		resp, err := rt.RoundTrip(mkProxyReq(t, env.ctx(), "https://api.example.com/x"))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+}
+
+func TestShapeQueue_InterleavesActors(t *testing.T) {
+	env := newEnforcerEnv(t)
+	q := newShapeQueue("rl_shape", connectionA, env.rds)
+	deadline := env.clock.Now().Add(time.Minute)
+
+	a1, err := q.join(env.ctx(), "act_a", "", deadline, 10)
+	require.NoError(t, err)
+	a2, _ := q.join(env.ctx(), "act_a", "", deadline, 10)
+	a3, _ := q.join(env.ctx(), "act_a", "", deadline, 10)
+	b1, _ := q.join(env.ctx(), "act_b", "", deadline, 10)
+	b2, _ := q.join(env.ctx(), "act_b", "", deadline, 10)
+
+	// One from each actor per round, in arrival order within a round.
+	for _, want := range []struct{ actor, ticket string }{
+		{"act_a", a1}, {"act_b", b1}, {"act_a", a2}, {"act_b", b2}, {"act_a", a3},
+	} {
+		head, err := q.atHead(env.ctx(), want.ticket)
+		require.NoError(t, err)
+		require.True(t, head)
+		require.NoError(t, q.leave(env.ctx(), want.actor, want.ticket))
+	}
+
+	n, err := q.len(env.ctx())
+	require.NoError(t, err)
+	require.Zero(t, n)
+}
+
+func TestShapeQueue_HigherPriorityGoesFirst(t *testing.T) {
+	env := newEnforcerEnv(t)
+	q := newShapeQueue("rl_shape", connectionA, env.rds)
+	deadline := env.clock.Now().Add(time.Minute)
+
+	batch, err := q.join(env.ctx(), "act_a", common.RequestPriorityBatch, deadline, 10)
+	require.NoError(t, err)
+	normal, _ := q.join(env.ctx(), "act_a", "", deadline, 10)
+	interactive, _ := q.join(env.ctx(), "act_b", common.RequestPriorityInteractive, deadline, 10)
+	interactive2, _ := q.join(env.ctx(), "act_b", common.RequestPriorityInteractive, deadline, 10)
+
+	// Priority outranks both arrival order and the actor's fairness round.
+	for _, want := range []struct{ actor, ticket string }{
+		{"act_b", interactive}, {"act_b", interactive2}, {"act_a", normal}, {"act_a", batch},
+	} {
+		head, err := q.atHead(env.ctx(), want.ticket)
+		require.NoError(t, err)
+		require.True(t, head)
+		require.NoError(t, q.leave(env.ctx(), want.actor, want.ticket))
+	}
+}
+
+func TestShapeQueue_FullQueueRejectsJoin(t *testing.T) {
+	env := newEnforcerEnv(t)
+	q := newShapeQueue("rl_shape", connectionA, env.rds)
+	deadline := env.clock.Now().Add(time.Minute)
+
+	first, err := q.join(env.ctx(), "act_a", "", deadline, 1)
+	require.NoError(t, err)
+	require.NotEmpty(t, first)
+
+	second, err := q.join(env.ctx(), "act_b", "", deadline, 1)
+	require.NoError(t, err)
+	require.Empty(t, second)
+}
+
+func TestShapeQueue_EvictsAbandonedTickets(t *testing.T) {
+	env := newEnforcerEnv(t)
+	q := newShapeQueue("rl_shape", connectionA, env.rds)
+
+	// A process that queued a request and died never leaves the queue.
+	abandoned, _ := q.join(env.ctx(), "act_a", "", env.clock.Now().Add(time.Second), 10)
+	live, _ := q.join(env.ctx(), "act_b", "", env.clock.Now().Add(time.Minute), 10)
+
+	head, _ := q.atHead(env.ctx(), abandoned)
+	require.True(t, head)
+
+	env.step(2 * time.Second)
+	head, _ = q.atHead(env.ctx(), live)
+	require.True(t, head)
+
+	// The abandoned ticket no longer counts towards its actor's round.
+	require.False(t, env.server.Exists(q.prefix+":sa") && env.server.HGet(q.prefix+":sa", "act_a") != "")
+	require.NoError(t, q.leave(env.ctx(), "act_b", live))
+}
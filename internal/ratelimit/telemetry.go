@@ -0,0 +1,67 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/rmorlok/authproxy/internal/apid"
+	"github.com/rmorlok/authproxy/internal/aptelemetry"
+	sconfig "github.com/rmorlok/authproxy/internal/schema/config"
+)
+
+const (
+	telemetryInstrumentationName = "github.com/rmorlok/authproxy/internal/ratelimit"
+
+	metricUpstreamRemaining = "authproxy.ratelimit.upstream.remaining"
+)
+
+// Telemetry owns OTel metrics emitted by the connector-level rate limiter.
+// A nil instance is safe and emits nothing.
+type Telemetry struct {
+	metricsEnabled bool
+
+	upstreamRemaining metric.Int64Gauge
+}
+
+// NewTelemetry constructs the rate limiter's metrics from the providers +
+// config. providers being nil or in no-op mode, or metrics being off,
+// produces a Telemetry that records nothing.
+func NewTelemetry(providers *aptelemetry.Providers, cfg *sconfig.Telemetry) (*Telemetry, error) {
+	t := &Telemetry{}
+	if providers == nil || !providers.Enabled || !cfg.MetricsEnabled() || providers.MeterProvider == nil {
+		return t, nil
+	}
+
+	meter := providers.MeterProvider.Meter(telemetryInstrumentationName)
+
+	var err error
+	t.upstreamRemaining, err = meter.Int64Gauge(
+		metricUpstreamRemaining,
+		metric.WithUnit("{request}"),
+		metric.WithDescription("Requests remaining in the upstream's rate-limit window, as learned from its rate-limit headers."),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("ratelimit: create upstream remaining gauge: %w", err)
+	}
+
+	t.metricsEnabled = true
+	return t, nil
+}
+
+func (t *Telemetry) recordUpstreamRemaining(ctx context.Context, connectorID, connectionID apid.ID, remaining int64) {
+	if t == nil || !t.metricsEnabled {
+		return
+	}
+
+	attrs := []attribute.KeyValue{
+		attribute.String("authproxy.connection_id", connectionID.String()),
+	}
+	if !connectorID.IsNil() {
+		attrs = append(attrs, attribute.String("authproxy.connector_id", connectorID.String()))
+	}
+
+	t.upstreamRemaining.Record(ctx, remaining, metric.WithAttributes(attrs...))
+}
@@ -0,0 +1,127 @@
+package responsecache
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cacheControl is the parsed set of Cache-Control directives on a request or
+// response. Directive names are lower-cased; values are unquoted.
+type cacheControl map[string]string
+
+func parseCacheControl(h http.Header) cacheControl {
+	cc := cacheControl{}
+	for _, line := range h.Values("Cache-Control") {
+		for _, part := range strings.Split(line, ",") {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+			name, value, _ := strings.Cut(part, "=")
+			cc[strings.ToLower(strings.TrimSpace(name))] = strings.Trim(strings.TrimSpace(value), `"`)
+		}
+	}
+	return cc
+}
+
+func (cc cacheControl) has(directive string) bool {
+	_, ok := cc[directive]
+	return ok
+}
+
+// seconds returns the value of a delta-seconds directive such as max-age.
+func (cc cacheControl) seconds(directive string) (time.Duration, bool) {
+	v, ok := cc[directive]
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	return time.Duration(n) * time.Second, true
+}
+
+// freshnessLifetime is how long a response received at now is fresh. The
+// upstream's s-maxage, max-age and Expires take precedence, in that order, over
+// the connector rule's ttl. Time the response already spent in upstream caches,
+// per its Age header, is subtracted.
+func freshnessLifetime(h http.Header, cc cacheControl, ttl time.Duration, now time.Time) time.Duration {
+	if cc.has("no-cache") {
+		return 0
+	}
+
+	lifetime := ttl
+	if d, ok := cc.seconds("s-maxage"); ok {
+		lifetime = d
+	} else if d, ok := cc.seconds("max-age"); ok {
+		lifetime = d
+	} else if expires := h.Get("Expires"); expires != "" {
+		lifetime = 0
+		if t, err := http.ParseTime(expires); err == nil {
+			date := now
+			if d, err := http.ParseTime(h.Get("Date")); err == nil {
+				date = d
+			}
+			lifetime = max(t.Sub(date), 0)
+		}
+	}
+
+	if age, err := strconv.ParseInt(h.Get("Age"), 10, 64); err == nil && age > 0 {
+		lifetime -= time.Duration(age) * time.Second
+	}
+
+	return max(lifetime, 0)
+}
+
+// hasValidators reports whether a response can be revalidated with a
+// conditional request.
+func hasValidators(h http.Header) bool {
+	return h.Get("ETag") != "" || h.Get("Last-Modified") != ""
+}
+
+// isConditional reports whether the caller sent its own conditional or range
+// headers, in which case it is managing validation itself and the cache steps
+// aside.
+func isConditional(h http.Header) bool {
+	for _, name := range []string{"If-None-Match", "If-Modified-Since", "If-Match", "If-Unmodified-Since", "If-Range", "Range"} {
+		if h.Get(name) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// isUpgrade reports whether the request asks to switch protocols, e.g. a
+// WebSocket handshake. Either an Upgrade header or Connection: upgrade is
+// enough; the handshake must reach the upstream to get its 101.
+func isUpgrade(h http.Header) bool {
+	if h.Get("Upgrade") != "" {
+		return true
+	}
+	for _, v := range h.Values("Connection") {
+		for _, token := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), "upgrade") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// cacheableStatuses are the statuses RFC 9110 allows to be cached without
+// explicit freshness information.
+var cacheableStatuses = map[int]bool{
+	http.StatusOK:                   true,
+	http.StatusNonAuthoritativeInfo: true,
+	http.StatusNoContent:            true,
+	http.StatusMultipleChoices:      true,
+	http.StatusMovedPermanently:     true,
+	http.StatusNotFound:             true,
+	http.StatusMethodNotAllowed:     true,
+	http.StatusGone:                 true,
+	http.StatusRequestURITooLong:    true,
+	http.StatusNotImplemented:       true,
+}
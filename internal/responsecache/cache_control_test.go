@@ -0,0 +1,41 @@
+package responsecache
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFreshnessLifetime(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	ttl := time.Minute
+
+	tests := []struct {
+		name   string
+		header http.Header
+		want   time.Duration
+	}{
+		{name: "rule ttl", header: http.Header{}, want: time.Minute},
+		{name: "max-age", header: http.Header{"Cache-Control": {"public, max-age=300"}}, want: 5 * time.Minute},
+		{name: "s-maxage wins", header: http.Header{"Cache-Control": {"max-age=300, s-maxage=30"}}, want: 30 * time.Second},
+		{name: "no-cache", header: http.Header{"Cache-Control": {"no-cache"}}, want: 0},
+		{name: "age subtracted", header: http.Header{"Cache-Control": {"max-age=300"}, "Age": {"100"}}, want: 200 * time.Second},
+		{
+			name: "expires relative to date",
+			header: http.Header{
+				"Date":    {"Wed, 31 Dec 2025 23:00:00 GMT"},
+				"Expires": {"Wed, 31 Dec 2025 23:10:00 GMT"},
+			},
+			want: 10 * time.Minute,
+		},
+		{name: "invalid expires is already expired", header: http.Header{"Expires": {"0"}}, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, freshnessLifetime(tt.header, parseCacheControl(tt.header), ttl, now))
+		})
+	}
+}
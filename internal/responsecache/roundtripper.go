@@ -0,0 +1,328 @@
+package responsecache
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rmorlok/authproxy/internal/apctx"
+	"github.com/rmorlok/authproxy/internal/apid"
+	"github.com/rmorlok/authproxy/internal/app_metrics"
+	"github.com/rmorlok/authproxy/internal/httpf"
+	sconfig "github.com/rmorlok/authproxy/internal/schema/config"
+	"github.com/rmorlok/authproxy/internal/schema/resources/connectors"
+)
+
+const (
+	// CacheStatusHeader is set on responses returned from the cache: "hit" when
+	// served without contacting the upstream, "revalidated" when the upstream
+	// confirmed a stale entry with a 304.
+	CacheStatusHeader = "X-Authproxy-Cache"
+
+	cacheStatusHit         = "hit"
+	cacheStatusRevalidated = "revalidated"
+)
+
+// Factory implements httpf.RoundTripperFactory to cache proxied responses for
+// connections whose connector declares a responseCache.
+type Factory struct {
+	store  *Store
+	cfg    *sconfig.ResponseCache
+	logger *slog.Logger
+}
+
+// NewFactory creates a response cache middleware factory. cfg holds the global
+// limits and may be nil to use the defaults.
+func NewFactory(store *Store, cfg *sconfig.ResponseCache, logger *slog.Logger) *Factory {
+	return &Factory{
+		store:  store,
+		cfg:    cfg,
+		logger: logger,
+	}
+}
+
+func (f *Factory) NewRoundTripper(ri httpf.RequestInfo, transport http.RoundTripper) http.RoundTripper {
+	// Only proxy requests are cached; probes exist to reach the upstream.
+	if !f.cfg.IsEnabled() || ri.ResponseCache == nil {
+		return nil
+	}
+	if ri.ConnectionId == apid.Nil || ri.Type != httpf.RequestTypeProxy {
+		return nil
+	}
+
+	return &RoundTripper{
+		connectionId: ri.ConnectionId,
+		config:       ri.ResponseCache,
+		maxEntrySize: min(ri.ResponseCache.GetMaxEntrySize(), f.cfg.GetMaxEntrySize()),
+		store:        f.store,
+		transport:    transport,
+		logger:       f.logger,
+	}
+}
+
+// RoundTripper is an http.RoundTripper that serves GET and HEAD requests from
+// the connection's response cache and revalidates stale entries.
+type RoundTripper struct {
+	connectionId apid.ID
+	config       *connectors.ResponseCache
+	maxEntrySize uint64
+	store        *Store
+	transport    http.RoundTripper
+	logger       *slog.Logger
+}
+
+func (rt *RoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !isSafeMethod(req.Method) {
+		resp, err := rt.transport.RoundTrip(req)
+		if err == nil && resp.StatusCode >= 200 && resp.StatusCode < 400 {
+			rt.invalidate(req, resp)
+		}
+		return resp, err
+	}
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return rt.transport.RoundTrip(req)
+	}
+
+	reqCC := parseCacheControl(req.Header)
+	if reqCC.has("no-store") || isConditional(req.Header) || isUpgrade(req.Header) {
+		return rt.transport.RoundTrip(req)
+	}
+
+	rule := rt.config.MatchRule(req.URL.Path)
+	if rule == nil {
+		return rt.transport.RoundTrip(req)
+	}
+
+	ctx := req.Context()
+	now := apctx.GetClock(ctx).Now()
+	vary := rt.config.GetVaryHeaders()
+
+	cached, err := rt.store.Get(ctx, rt.connectionId, req, vary)
+	if err != nil {
+		// On Redis errors, go to the upstream rather than failing the request
+		rt.logWarn(ctx, "failed to read response cache", err)
+	}
+
+	outbound := req
+	if cached != nil {
+		if cached.IsFresh(now) && !requiresRevalidation(reqCC, now.Sub(cached.StoredAt)) {
+			// The proxy bootstrap installs an Attribution on every proxy
+			// request; when it isn't there this is a no-op.
+			if attr := app_metrics.AttributionFromContext(ctx); attr != nil {
+				attr.Source = app_metrics.ResponseSourceCache
+			}
+			return cachedResponse(req, cached, now, cacheStatusHit), nil
+		}
+
+		if hasValidators(cached.Header) {
+			outbound = req.Clone(ctx)
+			if etag := cached.Header.Get("ETag"); etag != "" {
+				outbound.Header.Set("If-None-Match", etag)
+			}
+			if lastModified := cached.Header.Get("Last-Modified"); lastModified != "" {
+				outbound.Header.Set("If-Modified-Since", lastModified)
+			}
+		} else {
+			cached = nil
+		}
+	}
+
+	resp, err := rt.transport.RoundTrip(outbound)
+	if err != nil {
+		return resp, err
+	}
+
+	if cached != nil && resp.StatusCode == http.StatusNotModified {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+		return rt.revalidated(ctx, req, rule, cached, resp.Header, now), nil
+	}
+
+	return rt.maybeStore(ctx, req, rule, resp, now), nil
+}
+
+// isSafeMethod reports whether method is safe in the sense of RFC 9110 §9.2.1,
+// i.e. not expected to change the resource.
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	default:
+		return false
+	}
+}
+
+// invalidate drops the entries cached for a URL an unsafe request changed,
+// as RFC 9111 §4.4 requires: the request URL and, when they are on the same
+// origin, the response's Location and Content-Location. Other origins are
+// left alone so an upstream can't evict entries it doesn't own.
+func (rt *RoundTripper) invalidate(req *http.Request, resp *http.Response) {
+	urls := []string{req.URL.String()}
+	for _, name := range []string{"Location", "Content-Location"} {
+		v := resp.Header.Get(name)
+		if v == "" {
+			continue
+		}
+		target, err := req.URL.Parse(v)
+		if err != nil || target.Scheme != req.URL.Scheme || target.Host != req.URL.Host {
+			continue
+		}
+		urls = append(urls, target.String())
+	}
+
+	ctx := req.Context()
+	if err := rt.store.Invalidate(ctx, rt.connectionId, urls...); err != nil {
+		rt.logWarn(ctx, "failed to invalidate response cache", err)
+	}
+}
+
+// requiresRevalidation reports whether the caller's own Cache-Control asks for
+// a response newer than age.
+func requiresRevalidation(reqCC cacheControl, age time.Duration) bool {
+	if reqCC.has("no-cache") {
+		return true
+	}
+	if maxAge, ok := reqCC.seconds("max-age"); ok && age >= maxAge {
+		return true
+	}
+	return false
+}
+
+// revalidated refreshes a stale entry the upstream confirmed with a 304,
+// updating its stored headers from the 304 as RFC 9111 requires.
+func (rt *RoundTripper) revalidated(ctx context.Context, req *http.Request, rule *connectors.ResponseCacheRule, cached *Entry, header http.Header, now time.Time) *http.Response {
+	for name, values := range header {
+		if name == "Content-Length" || name == "Set-Cookie" {
+			continue
+		}
+		cached.Header[name] = values
+	}
+	cached.StoredAt = now
+
+	cc := parseCacheControl(cached.Header)
+	if cc.has("no-store") {
+		if err := rt.store.Delete(ctx, rt.connectionId, req, rt.config.GetVaryHeaders(), cached); err != nil {
+			rt.logWarn(ctx, "failed to delete response cache entry", err)
+		}
+	} else {
+		cached.FreshFor = freshnessLifetime(cached.Header, cc, rule.TTL.Duration, now)
+		rt.put(ctx, req, cached)
+	}
+
+	return cachedResponse(req, cached, now, cacheStatusRevalidated)
+}
+
+// maybeStore caches resp when it is storable, returning a response the caller
+// can read in full either way. Bodies over the entry size cap are passed
+// through without being cached.
+func (rt *RoundTripper) maybeStore(ctx context.Context, req *http.Request, rule *connectors.ResponseCacheRule, resp *http.Response, now time.Time) *http.Response {
+	if !cacheableStatuses[resp.StatusCode] {
+		return resp
+	}
+
+	cc := parseCacheControl(resp.Header)
+	if cc.has("no-store") || resp.Header.Get("Vary") == "*" {
+		return resp
+	}
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		return resp
+	}
+	if resp.ContentLength > 0 && uint64(resp.ContentLength) > rt.maxEntrySize {
+		return resp
+	}
+
+	freshFor := freshnessLifetime(resp.Header, cc, rule.TTL.Duration, now)
+	if freshFor <= 0 && !hasValidators(resp.Header) {
+		return resp
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, int64(rt.maxEntrySize)+1))
+	if err != nil || uint64(len(body)) > rt.maxEntrySize {
+		// Hand back what was read followed by the rest of the stream, which
+		// will surface the read error to the caller if there was one.
+		resp.Body = &prefixedBody{Reader: io.MultiReader(bytes.NewReader(body), resp.Body), Closer: resp.Body}
+		return resp
+	}
+	_ = resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	header := resp.Header.Clone()
+	header.Del("Set-Cookie")
+
+	rt.put(ctx, req, &Entry{
+		StatusCode: resp.StatusCode,
+		Header:     header,
+		Body:       body,
+		StoredAt:   now,
+		FreshFor:   freshFor,
+		Vary:       varyHeaders(resp.Header),
+	})
+
+	return resp
+}
+
+func (rt *RoundTripper) put(ctx context.Context, req *http.Request, e *Entry) {
+	retain := e.FreshFor
+	if hasValidators(e.Header) {
+		retain += rt.config.GetRevalidateFor()
+	}
+	if retain <= 0 {
+		return
+	}
+
+	if err := rt.store.Put(ctx, rt.connectionId, req, rt.config.GetVaryHeaders(), e, retain); err != nil {
+		rt.logWarn(ctx, "failed to write response cache", err)
+	}
+}
+
+func (rt *RoundTripper) logWarn(ctx context.Context, msg string, err error) {
+	rt.logger.WarnContext(ctx, msg,
+		slog.String("connection_id", rt.connectionId.String()),
+		slog.String("error", err.Error()),
+	)
+}
+
+// varyHeaders returns the request header names listed in the response's Vary.
+func varyHeaders(h http.Header) []string {
+	var names []string
+	for _, line := range h.Values("Vary") {
+		for _, name := range strings.Split(line, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, http.CanonicalHeaderKey(name))
+			}
+		}
+	}
+	return names
+}
+
+func cachedResponse(req *http.Request, e *Entry, now time.Time, status string) *http.Response {
+	header := e.Header.Clone()
+	header.Set("Age", strconv.Itoa(int(now.Sub(e.StoredAt).Seconds())))
+	header.Set(CacheStatusHeader, status)
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", e.StatusCode, http.StatusText(e.StatusCode)),
+		StatusCode:    e.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(e.Body)),
+		ContentLength: int64(len(e.Body)),
+		Request:       req,
+	}
+}
+
+// prefixedBody re-assembles a response body that was partially read.
+type prefixedBody struct {
+	io.Reader
+	io.Closer
+}
+
+var _ httpf.RoundTripperFactory = (*Factory)(nil)
@@ -0,0 +1,347 @@
+package responsecache
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rmorlok/authproxy/internal/apctx"
+	"github.com/rmorlok/authproxy/internal/apid"
+	"github.com/rmorlok/authproxy/internal/app_metrics"
+	"github.com/rmorlok/authproxy/internal/apredis"
+	"github.com/rmorlok/authproxy/internal/httpf"
+	"github.com/rmorlok/authproxy/internal/schema/common"
+	sconfig "github.com/rmorlok/authproxy/internal/schema/config"
+	"github.com/rmorlok/authproxy/internal/schema/resources/connectors"
+	"github.com/rmorlok/authproxy/internal/schema/resources/rate_limit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	clock "k8s.io/utils/clock/testing"
+)
+
+// upstream is a scripted transport that records the requests it receives.
+type upstream struct {
+	requests  []*http.Request
+	responses []func(req *http.Request) *http.Response
+}
+
+func (u *upstream) RoundTrip(req *http.Request) (*http.Response, error) {
+	u.requests = append(u.requests, req)
+	i := min(len(u.requests), len(u.responses)) - 1
+	return u.responses[i](req), nil
+}
+
+func respond(status int, header http.Header, body string) func(*http.Request) *http.Response {
+	return func(req *http.Request) *http.Response {
+		return &http.Response{
+			StatusCode:    status,
+			Header:        header.Clone(),
+			Body:          io.NopCloser(strings.NewReader(body)),
+			ContentLength: int64(len(body)),
+			Request:       req,
+		}
+	}
+}
+
+func testConfig() *connectors.ResponseCache {
+	return &connectors.ResponseCache{
+		Rules: []connectors.ResponseCacheRule{
+			{
+				PathMatch: &rate_limit.PathMatch{Kind: rate_limit.PathMatchKindPrefix, Value: "/v1/projects"},
+				TTL:       common.HumanDuration{Duration: time.Minute},
+			},
+		},
+	}
+}
+
+type harness struct {
+	clock    *clock.FakeClock
+	store    *Store
+	upstream *upstream
+	factory  *Factory
+}
+
+func newHarness(t *testing.T, responses ...func(*http.Request) *http.Response) *harness {
+	_, r := apredis.MustApplyTestConfig(nil)
+	store := NewStore(r, 0)
+	return &harness{
+		clock:    clock.NewFakeClock(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)),
+		store:    store,
+		upstream: &upstream{responses: responses},
+		factory:  NewFactory(store, nil, slog.Default()),
+	}
+}
+
+func (h *harness) roundTripper(connectionId apid.ID, cfg *connectors.ResponseCache) http.RoundTripper {
+	return h.factory.NewRoundTripper(httpf.RequestInfo{
+		Type:          httpf.RequestTypeProxy,
+		ConnectionId:  connectionId,
+		ResponseCache: cfg,
+	}, h.upstream)
+}
+
+func (h *harness) do(t *testing.T, rt http.RoundTripper, method, url string, header http.Header) (*http.Response, string, *app_metrics.Attribution) {
+	attr := &app_metrics.Attribution{}
+	ctx := app_metrics.ContextWithAttribution(apctx.WithClock(context.Background(), h.clock), attr)
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	require.NoError(t, err)
+	for name, values := range header {
+		req.Header[name] = values
+	}
+
+	resp, err := rt.RoundTrip(req)
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	return resp, string(body), attr
+}
+
+func TestFactory_NewRoundTripper(t *testing.T) {
+	h := newHarness(t)
+	connectionId := apid.New(apid.PrefixConnection)
+
+	assert.NotNil(t, h.roundTripper(connectionId, testConfig()))
+	assert.Nil(t, h.roundTripper(connectionId, nil), "connector without responseCache")
+	assert.Nil(t, h.roundTripper(apid.Nil, testConfig()), "no connection")
+
+	assert.Nil(t, h.factory.NewRoundTripper(httpf.RequestInfo{
+		Type:          httpf.RequestTypeProbe,
+		ConnectionId:  connectionId,
+		ResponseCache: testConfig(),
+	}, h.upstream), "probes always reach the upstream")
+
+	disabled := NewFactory(h.store, &sconfig.ResponseCache{Disabled: true}, slog.Default())
+	assert.Nil(t, disabled.NewRoundTripper(httpf.RequestInfo{
+		Type:          httpf.RequestTypeProxy,
+		ConnectionId:  connectionId,
+		ResponseCache: testConfig(),
+	}, h.upstream))
+}
+
+func TestRoundTripper_ServesFreshHit(t *testing.T) {
+	h := newHarness(t, respond(http.StatusOK, http.Header{"Content-Type": {"application/json"}}, `{"id":1}`))
+	rt := h.roundTripper(apid.New(apid.PrefixConnection), testConfig())
+
+	resp, body, attr := h.do(t, rt, http.MethodGet, "https://api.example.com/v1/projects/1", nil)
+	assert.Equal(t, `{"id":1}`, body)
+	assert.Empty(t, resp.Header.Get(CacheStatusHeader))
+	assert.Empty(t, attr.Source)
+
+	h.clock.Step(30 * time.Second)
+	resp, body, attr = h.do(t, rt, http.MethodGet, "https://api.example.com/v1/projects/1", nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, `{"id":1}`, body)
+	assert.Equal(t, "hit", resp.Header.Get(CacheStatusHeader))
+	assert.Equal(t, "30", resp.Header.Get("Age"))
+	assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+	assert.Equal(t, app_metrics.ResponseSourceCache, attr.Source)
+	assert.Len(t, h.upstream.requests, 1)
+
+	// Past the rule's TTL without validators, the upstream is called again.
+	h.clock.Step(time.Minute)
+	_, _, _ = h.do(t, rt, http.MethodGet, "https://api.example.com/v1/projects/1", nil)
+	assert.Len(t, h.upstream.requests, 2)
+}
+
+func TestRoundTripper_Bypass(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		header http.Header
+		resp   http.Header
+	}{
+		{name: "non-GET", method: http.MethodPost, path: "/v1/projects"},
+		{name: "no matching rule", method: http.MethodGet, path: "/v1/users"},
+		{name: "request no-store", method: http.MethodGet, path: "/v1/projects", header: http.Header{"Cache-Control": {"no-store"}}},
+		{name: "caller conditional", method: http.MethodGet, path: "/v1/projects", header: http.Header{"If-None-Match": {`"abc"`}}},
+		{name: "websocket upgrade", method: http.MethodGet, path: "/v1/projects", header: http.Header{"Connection": {"Upgrade"}, "Upgrade": {"websocket"}}},
+		{name: "upgrade header", method: http.MethodGet, path: "/v1/projects", header: http.Header{"Upgrade": {"websocket"}}},
+		{name: "connection upgrade", method: http.MethodGet, path: "/v1/projects", header: http.Header{"Connection": {"keep-alive, upgrade"}}},
+		{name: "response no-store", method: http.MethodGet, path: "/v1/projects", resp: http.Header{"Cache-Control": {"private, no-store"}}},
+		{name: "vary star", method: http.MethodGet, path: "/v1/projects", resp: http.Header{"Vary": {"*"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newHarness(t, respond(http.StatusOK, tt.resp, "ok"))
+			rt := h.roundTripper(apid.New(apid.PrefixConnection), testConfig())
+
+			_, _, _ = h.do(t, rt, tt.method, "https://api.example.com"+tt.path, tt.header)
+			resp, body, _ := h.do(t, rt, tt.method, "https://api.example.com"+tt.path, tt.header)
+			assert.Equal(t, "ok", body)
+			assert.Empty(t, resp.Header.Get(CacheStatusHeader))
+			assert.Len(t, h.upstream.requests, 2)
+		})
+	}
+}
+
+func TestRoundTripper_UpgradeSkipsCachedEntry(t *testing.T) {
+	h := newHarness(t,
+		respond(http.StatusOK, http.Header{"Content-Type": {"application/json"}}, "ok"),
+		respond(http.StatusSwitchingProtocols, http.Header{"Connection": {"Upgrade"}, "Upgrade": {"websocket"}}, ""),
+	)
+	rt := h.roundTripper(apid.New(apid.PrefixConnection), testConfig())
+
+	_, _, _ = h.do(t, rt, http.MethodGet, "https://api.example.com/v1/projects", nil)
+
+	// The handshake reaches the upstream even though a GET is cached.
+	resp, _, _ := h.do(t, rt, http.MethodGet, "https://api.example.com/v1/projects", http.Header{"Connection": {"Upgrade"}, "Upgrade": {"websocket"}})
+	assert.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode)
+	assert.Empty(t, resp.Header.Get(CacheStatusHeader))
+	assert.Len(t, h.upstream.requests, 2)
+
+	// Plain GETs still get the original entry.
+	resp, body, _ := h.do(t, rt, http.MethodGet, "https://api.example.com/v1/projects", nil)
+	assert.Equal(t, "hit", resp.Header.Get(CacheStatusHeader))
+	assert.Equal(t, "ok", body)
+}
+
+func TestRoundTripper_UpstreamCacheControl(t *testing.T) {
+	h := newHarness(t, respond(http.StatusOK, http.Header{"Cache-Control": {"max-age=300"}}, "ok"))
+	rt := h.roundTripper(apid.New(apid.PrefixConnection), testConfig())
+
+	_, _, _ = h.do(t, rt, http.MethodGet, "https://api.example.com/v1/projects", nil)
+
+	// max-age outlives the rule's one-minute TTL.
+	h.clock.Step(4 * time.Minute)
+	resp, _, _ := h.do(t, rt, http.MethodGet, "https://api.example.com/v1/projects", nil)
+	assert.Equal(t, "hit", resp.Header.Get(CacheStatusHeader))
+
+	// The caller can ask for something newer.
+	resp, _, _ = h.do(t, rt, http.MethodGet, "https://api.example.com/v1/projects", http.Header{"Cache-Control": {"max-age=60"}})
+	assert.Empty(t, resp.Header.Get(CacheStatusHeader))
+	assert.Len(t, h.upstream.requests, 2)
+}
+
+func TestRoundTripper_Revalidates(t *testing.T) {
+	h := newHarness(t,
+		respond(http.StatusOK, http.Header{"Etag": {`"v1"`}, "Last-Modified": {"Wed, 31 Dec 2025 00:00:00 GMT"}}, "v1 body"),
+		respond(http.StatusNotModified, http.Header{"X-Upstream": {"checked"}}, ""),
+		respond(http.StatusOK, http.Header{"Etag": {`"v2"`}}, "v2 body"),
+	)
+	rt := h.roundTripper(apid.New(apid.PrefixConnection), testConfig())
+
+	_, _, _ = h.do(t, rt, http.MethodGet, "https://api.example.com/v1/projects", nil)
+
+	h.clock.Step(2 * time.Minute)
+	resp, body, attr := h.do(t, rt, http.MethodGet, "https://api.example.com/v1/projects", nil)
+	require.Len(t, h.upstream.requests, 2)
+	assert.Equal(t, `"v1"`, h.upstream.requests[1].Header.Get("If-None-Match"))
+	assert.Equal(t, "Wed, 31 Dec 2025 00:00:00 GMT", h.upstream.requests[1].Header.Get("If-Modified-Since"))
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "v1 body", body)
+	assert.Equal(t, "revalidated", resp.Header.Get(CacheStatusHeader))
+	assert.Equal(t, "checked", resp.Header.Get("X-Upstream"))
+	assert.Empty(t, attr.Source, "a revalidated response was confirmed by the upstream")
+
+	// Revalidation restarts the freshness lifetime.
+	h.clock.Step(30 * time.Second)
+	resp, _, _ = h.do(t, rt, http.MethodGet, "https://api.example.com/v1/projects", nil)
+	assert.Equal(t, "hit", resp.Header.Get(CacheStatusHeader))
+	assert.Len(t, h.upstream.requests, 2)
+
+	// A changed resource replaces the entry.
+	h.clock.Step(2 * time.Minute)
+	_, body, _ = h.do(t, rt, http.MethodGet, "https://api.example.com/v1/projects", nil)
+	assert.Equal(t, "v2 body", body)
+	_, body, _ = h.do(t, rt, http.MethodGet, "https://api.example.com/v1/projects", nil)
+	assert.Equal(t, "v2 body", body)
+	assert.Len(t, h.upstream.requests, 3)
+}
+
+func TestRoundTripper_Vary(t *testing.T) {
+	h := newHarness(t, func(req *http.Request) *http.Response {
+		return respond(http.StatusOK, http.Header{"Vary": {"Accept"}}, "as "+req.Header.Get("Accept"))(req)
+	})
+	rt := h.roundTripper(apid.New(apid.PrefixConnection), testConfig())
+
+	_, _, _ = h.do(t, rt, http.MethodGet, "https://api.example.com/v1/projects", http.Header{"Accept": {"application/json"}})
+	_, _, _ = h.do(t, rt, http.MethodGet, "https://api.example.com/v1/projects", http.Header{"Accept": {"text/csv"}})
+	assert.Len(t, h.upstream.requests, 2)
+
+	resp, body, _ := h.do(t, rt, http.MethodGet, "https://api.example.com/v1/projects", http.Header{"Accept": {"application/json"}})
+	assert.Equal(t, "hit", resp.Header.Get(CacheStatusHeader))
+	assert.Equal(t, "as application/json", body)
+	assert.Len(t, h.upstream.requests, 2)
+}
+
+func TestRoundTripper_NotSharedAcrossConnections(t *testing.T) {
+	h := newHarness(t, respond(http.StatusOK, nil, "ok"))
+	first := h.roundTripper(apid.New(apid.PrefixConnection), testConfig())
+	second := h.roundTripper(apid.New(apid.PrefixConnection), testConfig())
+
+	_, _, _ = h.do(t, first, http.MethodGet, "https://api.example.com/v1/projects", nil)
+	resp, _, _ := h.do(t, second, http.MethodGet, "https://api.example.com/v1/projects", nil)
+	assert.Empty(t, resp.Header.Get(CacheStatusHeader))
+	assert.Len(t, h.upstream.requests, 2)
+}
+
+func TestRoundTripper_MaxEntrySize(t *testing.T) {
+	h := newHarness(t, func(req *http.Request) *http.Response {
+		resp := respond(http.StatusOK, nil, "0123456789")(req)
+		resp.ContentLength = -1
+		return resp
+	})
+	cfg := testConfig()
+	size := common.HumanByteSize{}
+	require.NoError(t, size.UnmarshalJSON([]byte(`"8b"`)))
+	cfg.MaxEntrySize = &size
+	rt := h.roundTripper(apid.New(apid.PrefixConnection), cfg)
+
+	_, body, _ := h.do(t, rt, http.MethodGet, "https://api.example.com/v1/projects", nil)
+	assert.Equal(t, "0123456789", body, "oversized bodies pass through intact")
+	_, _, _ = h.do(t, rt, http.MethodGet, "https://api.example.com/v1/projects", nil)
+	assert.Len(t, h.upstream.requests, 2)
+}
+
+func TestRoundTripper_UnsafeMethodsInvalidate(t *testing.T) {
+	const projects = "https://api.example.com/v1/projects"
+
+	tests := []struct {
+		name        string
+		method      string
+		url         string
+		status      int
+		header      http.Header
+		invalidates bool
+	}{
+		{name: "write to the url", method: http.MethodPut, url: projects, status: http.StatusOK, invalidates: true},
+		{name: "delete of the url", method: http.MethodDelete, url: projects, status: http.StatusNoContent, invalidates: true},
+		{name: "location", method: http.MethodPost, url: "https://api.example.com/v1/jobs", status: http.StatusSeeOther, header: http.Header{"Location": {"/v1/projects"}}, invalidates: true},
+		{name: "content-location", method: http.MethodPatch, url: "https://api.example.com/v1/jobs", status: http.StatusOK, header: http.Header{"Content-Location": {projects}}, invalidates: true},
+		{name: "location on another origin", method: http.MethodPost, url: "https://api.example.com/v1/jobs", status: http.StatusCreated, header: http.Header{"Location": {"https://other.example.com/v1/projects"}}},
+		{name: "failed write", method: http.MethodPut, url: projects, status: http.StatusConflict},
+		{name: "other url", method: http.MethodPut, url: projects + "/1", status: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newHarness(t, func(req *http.Request) *http.Response {
+				if req.Method == http.MethodGet {
+					return respond(http.StatusOK, http.Header{"Vary": {"Accept"}}, "list")(req)
+				}
+				return respond(tt.status, tt.header, "")(req)
+			})
+			rt := h.roundTripper(apid.New(apid.PrefixConnection), testConfig())
+
+			_, _, _ = h.do(t, rt, http.MethodGet, projects, http.Header{"Accept": {"application/json"}})
+			_, _, _ = h.do(t, rt, http.MethodGet, projects, http.Header{"Accept": {"text/csv"}})
+			_, _, _ = h.do(t, rt, tt.method, tt.url, nil)
+
+			for _, accept := range []string{"application/json", "text/csv"} {
+				resp, _, _ := h.do(t, rt, http.MethodGet, projects, http.Header{"Accept": {accept}})
+				if tt.invalidates {
+					assert.Empty(t, resp.Header.Get(CacheStatusHeader), "Accept: %s", accept)
+				} else {
+					assert.Equal(t, "hit", resp.Header.Get(CacheStatusHeader), "Accept: %s", accept)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,256 @@
+package responsecache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/rmorlok/authproxy/internal/apctx"
+	"github.com/rmorlok/authproxy/internal/apid"
+	"github.com/rmorlok/authproxy/internal/apredis"
+)
+
+const redisKeyPrefix = "respcache:"
+
+// Entry is a cached upstream response.
+type Entry struct {
+	StatusCode int         `json:"statusCode"`
+	Header     http.Header `json:"header"`
+	Body       []byte      `json:"body,omitempty"`
+
+	// StoredAt is when the response was received or last revalidated.
+	StoredAt time.Time `json:"storedAt"`
+
+	// FreshFor is how long after StoredAt the entry is served without
+	// contacting the upstream.
+	FreshFor time.Duration `json:"freshFor"`
+
+	// Vary are the request headers the upstream listed in its Vary header.
+	Vary []string `json:"vary,omitempty"`
+}
+
+// IsFresh reports whether the entry may be served at now without
+// revalidation.
+func (e *Entry) IsFresh(now time.Time) bool {
+	return now.Sub(e.StoredAt) < e.FreshFor
+}
+
+// Store keeps cached responses in Redis. Every key is scoped to a connection,
+// so responses are never shared across connections.
+//
+// For each method and URL a variants key records the request headers the
+// upstream varies on; the entry itself is keyed by the method, URL and the
+// values of those headers plus the connector's varyHeaders. A per-connection
+// sorted set indexes entries by expiry so the connection can be held to
+// maxEntries, and a per-URL set records the entries cached for each URL so a
+// write to it can invalidate them.
+type Store struct {
+	r          apredis.Client
+	maxEntries int
+}
+
+// NewStore creates a response cache store that keeps at most
+// maxEntriesPerConnection entries for each connection.
+func NewStore(r apredis.Client, maxEntriesPerConnection int) *Store {
+	return &Store{r: r, maxEntries: maxEntriesPerConnection}
+}
+
+func connectionPrefix(connectionID apid.ID) string {
+	return redisKeyPrefix + connectionID.String() + ":"
+}
+
+func indexKey(connectionID apid.ID) string {
+	return connectionPrefix(connectionID) + "index"
+}
+
+func variantsKey(connectionID apid.ID, req *http.Request) string {
+	return variantsKeyFor(connectionID, req.Method, req.URL.String())
+}
+
+func variantsKeyFor(connectionID apid.ID, method, rawURL string) string {
+	return connectionPrefix(connectionID) + "vary:" + hashParts(method, rawURL)
+}
+
+func urlEntriesKey(connectionID apid.ID, rawURL string) string {
+	return connectionPrefix(connectionID) + "url:" + hashParts(rawURL)
+}
+
+func entryKey(connectionID apid.ID, req *http.Request, vary []string) string {
+	parts := []string{req.Method, req.URL.String()}
+	for _, name := range vary {
+		parts = append(parts, name, req.Header.Get(name))
+	}
+	return connectionPrefix(connectionID) + "entry:" + hashParts(parts...)
+}
+
+func hashParts(parts ...string) string {
+	h := sha256.New()
+	for _, p := range parts {
+		h.Write([]byte(strconv.Itoa(len(p))))
+		h.Write([]byte{':'})
+		h.Write([]byte(p))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// keyHeaders merges the connector's vary headers with the upstream's into the
+// sorted, de-duplicated list used to build the entry key.
+func keyHeaders(connectorVary, upstreamVary []string) []string {
+	names := make([]string, 0, len(connectorVary)+len(upstreamVary))
+	for _, name := range append(slices.Clone(connectorVary), upstreamVary...) {
+		names = append(names, http.CanonicalHeaderKey(name))
+	}
+	slices.Sort(names)
+	return slices.Compact(names)
+}
+
+// Get returns the entry cached for req on the connection, or nil if there is
+// none.
+func (s *Store) Get(ctx context.Context, connectionID apid.ID, req *http.Request, connectorVary []string) (*Entry, error) {
+	varyJson, err := s.r.Get(ctx, variantsKey(connectionID, req)).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var upstreamVary []string
+	if err := json.Unmarshal(varyJson, &upstreamVary); err != nil {
+		return nil, err
+	}
+
+	entryJson, err := s.r.Get(ctx, entryKey(connectionID, req, keyHeaders(connectorVary, upstreamVary))).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var e Entry
+	if err := json.Unmarshal(entryJson, &e); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// Put caches e as the response to req on the connection for retain. If the
+// connection then holds more than the store's maximum, the entries closest to
+// expiring are evicted.
+func (s *Store) Put(ctx context.Context, connectionID apid.ID, req *http.Request, connectorVary []string, e *Entry, retain time.Duration) error {
+	varyJson, err := json.Marshal(e.Vary)
+	if err != nil {
+		return err
+	}
+	entryJson, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	key := entryKey(connectionID, req, keyHeaders(connectorVary, e.Vary))
+	index := indexKey(connectionID)
+	urlEntries := urlEntriesKey(connectionID, req.URL.String())
+	now := apctx.GetClock(ctx).Now()
+
+	_, err = s.r.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		p.Set(ctx, variantsKey(connectionID, req), varyJson, retain)
+		p.Set(ctx, key, entryJson, retain)
+		p.ZAdd(ctx, index, redis.Z{Score: float64(now.Add(retain).UnixMilli()), Member: key})
+		p.ZRemRangeByScore(ctx, index, "-inf", strconv.FormatInt(now.UnixMilli(), 10))
+		p.ExpireNX(ctx, index, retain)
+		p.ExpireGT(ctx, index, retain)
+		p.SAdd(ctx, urlEntries, key)
+		p.ExpireNX(ctx, urlEntries, retain)
+		p.ExpireGT(ctx, urlEntries, retain)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.evict(ctx, connectionID)
+}
+
+func (s *Store) evict(ctx context.Context, connectionID apid.ID) error {
+	if s.maxEntries <= 0 {
+		return nil
+	}
+
+	index := indexKey(connectionID)
+	count, err := s.r.ZCard(ctx, index).Result()
+	if err != nil {
+		return err
+	}
+	if count <= int64(s.maxEntries) {
+		return nil
+	}
+
+	evicted, err := s.r.ZPopMin(ctx, index, count-int64(s.maxEntries)).Result()
+	if err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(evicted))
+	for _, z := range evicted {
+		if key, ok := z.Member.(string); ok {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	return s.r.Del(ctx, keys...).Err()
+}
+
+// Delete removes the entry cached for req on the connection.
+func (s *Store) Delete(ctx context.Context, connectionID apid.ID, req *http.Request, connectorVary []string, e *Entry) error {
+	key := entryKey(connectionID, req, keyHeaders(connectorVary, e.Vary))
+	_, err := s.r.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		p.Del(ctx, key)
+		p.ZRem(ctx, indexKey(connectionID), key)
+		p.SRem(ctx, urlEntriesKey(connectionID, req.URL.String()), key)
+		return nil
+	})
+	return err
+}
+
+// Invalidate removes every entry cached on the connection for the given URLs,
+// whatever the method or varied headers they were stored under.
+func (s *Store) Invalidate(ctx context.Context, connectionID apid.ID, rawURLs ...string) error {
+	for _, rawURL := range rawURLs {
+		urlEntries := urlEntriesKey(connectionID, rawURL)
+		keys, err := s.r.SMembers(ctx, urlEntries).Result()
+		if err != nil {
+			return err
+		}
+
+		_, err = s.r.TxPipelined(ctx, func(p redis.Pipeliner) error {
+			p.Del(ctx,
+				urlEntries,
+				variantsKeyFor(connectionID, http.MethodGet, rawURL),
+				variantsKeyFor(connectionID, http.MethodHead, rawURL),
+			)
+			if len(keys) > 0 {
+				members := make([]any, len(keys))
+				for i, key := range keys {
+					members[i] = key
+				}
+				p.Del(ctx, keys...)
+				p.ZRem(ctx, indexKey(connectionID), members...)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package responsecache
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/rmorlok/authproxy/internal/apctx"
+	"github.com/rmorlok/authproxy/internal/apid"
+	"github.com/rmorlok/authproxy/internal/apredis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore_EvictsClosestToExpiring(t *testing.T) {
+	_, r := apredis.MustApplyTestConfig(nil)
+	store := NewStore(r, 2)
+	ctx := apctx.WithFixedClock(context.Background(), time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	connectionId := apid.New(apid.PrefixConnection)
+
+	request := func(path string) *http.Request {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://api.example.com"+path, nil)
+		require.NoError(t, err)
+		return req
+	}
+
+	require.NoError(t, store.Put(ctx, connectionId, request("/a"), nil, &Entry{StatusCode: http.StatusOK}, time.Hour))
+	require.NoError(t, store.Put(ctx, connectionId, request("/b"), nil, &Entry{StatusCode: http.StatusOK}, time.Minute))
+	require.NoError(t, store.Put(ctx, connectionId, request("/c"), nil, &Entry{StatusCode: http.StatusOK}, 2*time.Hour))
+
+	for path, cached := range map[string]bool{"/a": true, "/b": false, "/c": true} {
+		e, err := store.Get(ctx, connectionId, request(path), nil)
+		require.NoError(t, err)
+		assert.Equal(t, cached, e != nil, path)
+	}
+
+	count, err := r.ZCard(ctx, indexKey(connectionId)).Result()
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+}
+
+func TestStore_ConnectorVaryHeaders(t *testing.T) {
+	_, r := apredis.MustApplyTestConfig(nil)
+	store := NewStore(r, 0)
+	ctx := context.Background()
+	connectionId := apid.New(apid.PrefixConnection)
+	vary := []string{"accept-language"}
+
+	request := func(lang string) *http.Request {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://api.example.com/v1/projects", nil)
+		require.NoError(t, err)
+		req.Header.Set("Accept-Language", lang)
+		return req
+	}
+
+	require.NoError(t, store.Put(ctx, connectionId, request("en"), vary, &Entry{StatusCode: http.StatusOK, Body: []byte("hello")}, time.Hour))
+
+	e, err := store.Get(ctx, connectionId, request("en"), vary)
+	require.NoError(t, err)
+	require.NotNil(t, e)
+	assert.Equal(t, "hello", string(e.Body))
+
+	e, err = store.Get(ctx, connectionId, request("fr"), vary)
+	require.NoError(t, err)
+	assert.Nil(t, e)
+}
@@ -9,13 +9,16 @@ import (
 )
 
 // RejectSnakeCaseQueryParams rejects legacy AuthProxy query parameter names.
-// Raw proxy requests are deliberately excluded because their query string is
-// an opaque third-party payload that AuthProxy forwards unchanged.
+// Raw and path-style proxy requests are deliberately excluded because their
+// query string is an opaque third-party payload that AuthProxy forwards
+// unchanged.
 func RejectSnakeCaseQueryParams() gin.HandlerFunc {
 	return func(gctx *gin.Context) {
 		if strings.HasPrefix(gctx.Request.URL.Path, "/oauth2/callback") ||
+			strings.HasPrefix(gctx.Request.URL.Path, "/oauth1/callback") ||
 			strings.HasSuffix(gctx.Request.URL.Path, "/_proxy") ||
-			strings.HasSuffix(gctx.Request.URL.Path, "/_proxyRaw") {
+			strings.HasSuffix(gctx.Request.URL.Path, "/_proxyRaw") ||
+			isUpstreamProxyPath(gctx.Request.URL.Path) {
 			gctx.Next()
 			return
 		}
@@ -31,3 +34,19 @@ func RejectSnakeCaseQueryParams() gin.HandlerFunc {
 		gctx.Next()
 	}
 }
+
+// isUpstreamProxyPath reports whether p is a path-style proxy route
+// (/connections/{id}/upstream/...), whose query belongs to the upstream.
+func isUpstreamProxyPath(p string) bool {
+	idx := strings.Index(p, "/connections/")
+	if idx < 0 {
+		return false
+	}
+	rest := p[idx+len("/connections/"):]
+	slash := strings.IndexByte(rest, '/')
+	if slash < 0 {
+		return false
+	}
+	rest = rest[slash:]
+	return rest == upstreamRouteSegment || strings.HasPrefix(rest, upstreamRouteSegment+"/")
+}
@@ -23,6 +23,8 @@ func TestRejectSnakeCaseQueryParams(t *testing.T) {
 		{name: "camel case", url: "/things?labelSelector=team%3Dapi", want: http.StatusNoContent},
 		{name: "legacy snake case", url: "/things?label_selector=team%3Dapi", want: http.StatusBadRequest},
 		{name: "raw proxy is opaque", url: "/connections/cxn_1/_proxyRaw?third_party_key=value", want: http.StatusNotFound},
+		{name: "upstream proxy is opaque", url: "/connections/cxn_1/upstream/v2/tickets?per_page=100", want: http.StatusNotFound},
+		{name: "upstream lookalike is checked", url: "/connections/cxn_1/upstreams?label_selector=x", want: http.StatusBadRequest},
 	} {
 		t.Run(test.name, func(t *testing.T) {
 			response := httptest.NewRecorder()
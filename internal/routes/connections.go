@@ -7,6 +7,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	auth "github.com/rmorlok/authproxy/internal/apauth/service"
+	"github.com/rmorlok/authproxy/internal/apctx"
 	"github.com/rmorlok/authproxy/internal/apgin"
 	"github.com/rmorlok/authproxy/internal/apid"
 	"github.com/rmorlok/authproxy/internal/apredis"
@@ -17,6 +18,7 @@ import (
 	"github.com/rmorlok/authproxy/internal/encrypt"
 	"github.com/rmorlok/authproxy/internal/httperr"
 	"github.com/rmorlok/authproxy/internal/httpf"
+	"github.com/rmorlok/authproxy/internal/ratelimit"
 	"github.com/rmorlok/authproxy/internal/routes/key_value"
 	schemaapi "github.com/rmorlok/authproxy/internal/schema/api"
 	schemaapiopenapi "github.com/rmorlok/authproxy/internal/schema/api/openapi"
@@ -59,6 +61,11 @@ type MigrateConnectionVersionResponseJson = schemaapi.MigrateConnectionVersionRe
 type ForceStateRequestJson = schemaapi.ForceConnectionStateRequestJson
 type UpdateConnectionRequestJson = schemaapi.UpdateConnectionRequestJson
 type ProxyResponse = schemaapi.ProxyResponseJson
+type BatchProxyRequest = schemaapi.BatchProxyRequestJson
+type BatchProxyResult = schemaapi.BatchProxyResultJson
+type BatchProxySummary = schemaapi.BatchProxySummaryJson
+type ProxyPage = schemaapi.ProxyPageJson
+type ProxyPaginationSummary = schemaapi.ProxyPaginationSummaryJson
 
 type OpenAPIConnectionJson = schemaapiopenapi.ConnectionJson
 type OpenAPIListConnectionResponseJson = schemaapiopenapi.ListConnectionResponseJson
@@ -68,6 +75,7 @@ type OpenAPIMigrateConnectionVersionRequestJson = schemaapiopenapi.MigrateConnec
 type OpenAPIMigrateConnectionVersionResponseJson = schemaapiopenapi.MigrateConnectionVersionResponseJson
 type ProxyRequest = schemaapiopenapi.ProxyRequestJson
 type OpenAPIProxyResponseJson = schemaapiopenapi.ProxyResponseJson
+type OpenAPIBatchProxyRequestJson = schemaapiopenapi.BatchProxyRequestJson
 
 // @Summary		Initiate connection
 // @Description	Initiate a new connection to an external service through a connector
@@ -1409,6 +1417,88 @@ func (r *ConnectionsRoutes) getScopes(gctx *gin.Context) {
 	})
 }
 
+// ConnectionRateLimitHeadroomJson exposes the request budget the upstream reported on its rate-limit
+// headers for a connection whose connector configures rateLimiting.headroom. Learned is false until a
+// response carrying those headers has been seen in the current window.
+type ConnectionRateLimitHeadroomJson struct {
+	Learned   bool       `json:"learned"`
+	Remaining *int64     `json:"remaining,omitempty"`
+	Limit     *int64     `json:"limit,omitempty"`
+	ResetAt   *time.Time `json:"resetAt,omitempty"`
+}
+
+// @Summary		Get learned rate limit headroom for a connection
+// @Description	Returns the remaining request budget and reset time learned from the upstream's rate-limit headers. Only valid for connections whose connector configures rateLimiting.headroom.
+// @Tags			connections
+// @Produce		json
+// @Param			id	path		string	true	"Connection ID"
+// @Success		200	{object}	ConnectionRateLimitHeadroomJson
+// @Failure		400	{object}	ErrorResponse
+// @Failure		401	{object}	ErrorResponse
+// @Failure		404	{object}	ErrorResponse
+// @Failure		422	{object}	ErrorResponse
+// @Failure		500	{object}	ErrorResponse
+// @Security		BearerAuth
+// @Router			/connections/{id}/rate-limit-headroom [get]
+func (r *ConnectionsRoutes) getRateLimitHeadroom(gctx *gin.Context) {
+	ctx := gctx.Request.Context()
+	val := auth.MustGetValidatorFromGinContext(gctx)
+
+	id, err := apid.Parse(gctx.Param("id"))
+	if err != nil {
+		apgin.WriteError(gctx, nil, httperr.BadRequest("invalid id format", httperr.WithInternalErr(err)))
+		val.MarkErrorReturn()
+		return
+	}
+
+	if id == apid.Nil {
+		apgin.WriteError(gctx, nil, httperr.BadRequest("id is required"))
+		val.MarkErrorReturn()
+		return
+	}
+
+	c, err := r.core.GetConnection(ctx, id)
+	if err != nil {
+		if errors.Is(err, coreIface.ErrNotFound) {
+			apgin.WriteError(gctx, nil, httperr.NotFound("connection not found"))
+		} else {
+			apgin.WriteErr(gctx, nil, err)
+		}
+		val.MarkErrorReturn()
+		return
+	}
+
+	if httpErr := val.ValidateHttpStatusError(c); httpErr != nil {
+		apgin.WriteError(gctx, nil, httpErr)
+		return
+	}
+
+	if c.GetConnector().GetDefinition().RateLimiting.GetHeadroom() == nil {
+		apgin.WriteError(gctx, nil, httperr.New(http.StatusUnprocessableEntity, "rate limit headroom is not configured for this connection's connector"))
+		val.MarkErrorReturn()
+		return
+	}
+
+	h, ok, err := ratelimit.NewStore(r.r).GetHeadroom(ctx, id, apctx.GetClock(ctx).Now())
+	if err != nil {
+		apgin.WriteErr(gctx, nil, err)
+		val.MarkErrorReturn()
+		return
+	}
+
+	resp := ConnectionRateLimitHeadroomJson{Learned: ok}
+	if ok {
+		resp.Remaining = &h.Remaining
+		if h.Limit > 0 {
+			resp.Limit = &h.Limit
+		}
+		resetAt := h.ResetAt.UTC()
+		resp.ResetAt = &resetAt
+	}
+
+	apgin.APIJSON(gctx, http.StatusOK, resp)
+}
+
 func (r *ConnectionsRoutes) Register(g gin.IRouter) {
 	g.POST(
 		"/connections/_initiate",
@@ -1624,6 +1714,15 @@ func (r *ConnectionsRoutes) Register(g gin.IRouter) {
 			Build(),
 		r.getScopes,
 	)
+	g.GET(
+		"/connections/:id/rate-limit-headroom",
+		r.auth.NewRequiredBuilder().
+			ForResource("connections").
+			ForVerb("get").
+			ForIdField("id").
+			Build(),
+		r.getRateLimitHeadroom,
+	)
 }
 
 func NewConnectionsRoutes(
@@ -30,12 +30,14 @@ type ConnectionsProxyRoutes struct {
 }
 
 // @Summary		Proxy request through connection
-// @Description	Proxy an HTTP request through an authenticated connection to the external service
+// @Description	Proxy an HTTP request through an authenticated connection to the external service. A GET request with paginate set follows the upstream's pages using the connector's pagination rules; with the ndjson format the pages are streamed back as newline-delimited JSON followed by a summary line. The request can be tagged with a rate-limit priority using the priority field or the X-AuthProxy-Priority header; interactive priority requires the connections:prioritize permission.
 // @Tags			proxy
 // @Accept			json
 // @Produce		json
-// @Param			id		path		string			true	"Connection UUID"
-// @Param			request	body		ProxyRequest	true	"Proxy request payload"
+// @Produce		application/x-ndjson
+// @Param			id						path		string			true	"Connection UUID"
+// @Param			request					body		ProxyRequest	true	"Proxy request payload"
+// @Param			X-AuthProxy-Priority	header		string			false	"Rate-limit priority: interactive, normal or batch"
 // @Success		200		{object}	OpenAPIProxyResponseJson
 // @Failure		400		{object}	ErrorResponse
 // @Failure		401		{object}	ErrorResponse
@@ -92,6 +94,22 @@ func (r *ConnectionsProxyRoutes) proxy(gctx *gin.Context) {
 		return
 	}
 
+	priority, herr := resolveProxyPriority(gctx.Request.Header, proxyRequest.Priority)
+	if herr != nil {
+		apgin.WriteError(gctx, r.logger, herr)
+		return
+	}
+	if herr := authorizeProxyPriority(auth.MustGetAuthFromGinContext(gctx), conn, priority); herr != nil {
+		apgin.WriteError(gctx, r.logger, herr)
+		return
+	}
+	proxyRequest.Priority = priority
+
+	if proxyRequest.Paginate != nil && proxyRequest.Paginate.GetFormat() == iface.ProxyPaginateFormatNDJSON {
+		r.proxyPages(gctx, conn, &proxyRequest)
+		return
+	}
+
 	resp, err := conn.ProxyRequest(ctx, httpf.RequestTypeProxy, &proxyRequest)
 	if err != nil {
 		apgin.WriteErr(gctx, r.logger, err)
@@ -108,8 +126,17 @@ func (r *ConnectionsProxyRoutes) Register(g gin.IRouter) {
 		ForIdField("id").
 		Build()
 
+	g.POST(
+		"/connections/_batchProxy",
+		r.auth.NewRequiredBuilder().
+			ForResource("connections").
+			ForVerb("proxy").
+			Build(),
+		r.batchProxy,
+	)
 	g.POST("/connections/:id/_proxy", proxyAuth, r.proxy)
 	g.Any("/connections/:id/_proxyRaw", proxyAuth, r.proxyRaw)
+	g.Any("/connections/:id/upstream/*path", proxyAuth, r.proxyUpstream)
 }
 
 func NewConnectionsProxyRoutes(
@@ -0,0 +1,340 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	auth "github.com/rmorlok/authproxy/internal/apauth/service"
+	"github.com/rmorlok/authproxy/internal/apctx"
+	"github.com/rmorlok/authproxy/internal/apgin"
+	"github.com/rmorlok/authproxy/internal/apid"
+	"github.com/rmorlok/authproxy/internal/apserde"
+	"github.com/rmorlok/authproxy/internal/core/iface"
+	"github.com/rmorlok/authproxy/internal/database"
+	"github.com/rmorlok/authproxy/internal/httperr"
+	"github.com/rmorlok/authproxy/internal/httpf"
+	schemaapi "github.com/rmorlok/authproxy/internal/schema/api"
+	"github.com/rmorlok/authproxy/internal/util/pagination"
+)
+
+// HeaderCorrelationId carries the correlation ID shared by every upstream
+// request a batch proxy call makes. Request events recorded for those
+// requests can be listed with the correlationId filter.
+const HeaderCorrelationId = "X-AuthProxy-Correlation-Id"
+
+const contentTypeNDJSON = "application/x-ndjson"
+
+// batchProxyTarget is a connection a batch proxy request fans out to. When
+// the connection could not be loaded or the caller may not proxy through
+// it, err is set and the target is reported as failed without a request
+// being made.
+type batchProxyTarget struct {
+	id   apid.ID
+	conn iface.Connection
+	err  *httperr.Error
+}
+
+// @Summary		Proxy request through many connections
+// @Description	Send the same HTTP request through each connection named by id or matched by a label selector. Results are streamed back as newline-delimited JSON: one result line per connection, in completion order, followed by a summary line. Every upstream request shares the correlation ID returned in the X-AuthProxy-Correlation-Id header.
+// @Tags			proxy
+// @Accept			json
+// @Produce		application/x-ndjson
+// @Param			request	body		OpenAPIBatchProxyRequestJson	true	"Batch proxy request payload"
+// @Success		200		{object}	BatchProxyResult
+// @Failure		400		{object}	ErrorResponse
+// @Failure		401		{object}	ErrorResponse
+// @Failure		403		{object}	ErrorResponse
+// @Failure		500		{object}	ErrorResponse
+// @Security		BearerAuth
+// @Router			/connections/_batchProxy [post]
+func (r *ConnectionsProxyRoutes) batchProxy(gctx *gin.Context) {
+	ctx := gctx.Request.Context()
+	val := auth.MustGetValidatorFromGinContext(gctx)
+
+	var req BatchProxyRequest
+	if err := bindJSONBody(gctx, &req); err != nil {
+		apgin.WriteError(gctx, r.logger, httperr.BadRequest("invalid batch proxy request payload", httperr.WithInternalErr(err)))
+		val.MarkErrorReturn()
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		apgin.WriteError(gctx, r.logger, httperr.BadRequest(err.Error(), httperr.WithInternalErr(err)))
+		val.MarkErrorReturn()
+		return
+	}
+
+	if req.LabelSelector != nil {
+		if _, err := database.ParseLabelSelector(*req.LabelSelector); err != nil {
+			apgin.WriteError(gctx, r.logger, httperr.BadRequest("invalid label selector", httperr.WithInternalErr(err)))
+			val.MarkErrorReturn()
+			return
+		}
+	}
+
+	template := proxyRequestFromJson(req.Request)
+	if err := template.Validate(); err != nil {
+		apgin.WriteErr(gctx, r.logger, err)
+		val.MarkErrorReturn()
+		return
+	}
+
+	// Each result is already one line of the stream, so pages can only be
+	// merged into it.
+	if template.Paginate != nil && template.Paginate.GetFormat() == iface.ProxyPaginateFormatNDJSON {
+		apgin.WriteError(gctx, r.logger, httperr.BadRequest("batch proxy requests can only paginate with the merge format"))
+		val.MarkErrorReturn()
+		return
+	}
+
+	priority, herr := resolveProxyPriority(gctx.Request.Header, template.Priority)
+	if herr != nil {
+		apgin.WriteError(gctx, r.logger, herr)
+		val.MarkErrorReturn()
+		return
+	}
+	template.Priority = priority
+
+	targets, herr := r.resolveBatchProxyTargets(ctx, val, &req)
+	if herr != nil {
+		apgin.WriteError(gctx, r.logger, herr)
+		val.MarkErrorReturn()
+		return
+	}
+
+	// Like the proxy permission, the priority is checked per connection and
+	// a connection the caller can't prioritize is reported as failed.
+	ra := auth.MustGetAuthFromGinContext(gctx)
+	for i := range targets {
+		if targets[i].err != nil {
+			continue
+		}
+		targets[i].err = authorizeProxyPriority(ra, targets[i].conn, priority)
+	}
+
+	correlationId := apctx.GetIdGenerator(ctx).New(apid.PrefixCorrelation).String()
+	ctx = apctx.WithCorrelationID(ctx, correlationId)
+
+	gctx.Header(HeaderCorrelationId, correlationId)
+	gctx.Header("Content-Type", contentTypeNDJSON)
+	gctx.Status(http.StatusOK)
+	gctx.Writer.WriteHeaderNow()
+	gctx.Writer.Flush()
+
+	var mu sync.Mutex
+	writeLine := func(v any) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		data, _, err := apserde.MarshalJSONForAPI(ctx, v)
+		if err != nil {
+			r.logger.ErrorContext(ctx, "failed to encode batch proxy result", "error", err)
+			return
+		}
+		if _, err := gctx.Writer.Write(data); err != nil {
+			return
+		}
+		gctx.Writer.Flush()
+	}
+
+	summary := executeBatchProxy(ctx, targets, &template, req.GetConcurrency(), func(res *BatchProxyResult) {
+		writeLine(res)
+	})
+	writeLine(summary)
+}
+
+// resolveBatchProxyTargets loads the connections a batch proxy request
+// targets. Explicit connection IDs the caller cannot proxy through become
+// failed targets so they are reported alongside the others; a label
+// selector only ever matches connections the caller can proxy through.
+func (r *ConnectionsProxyRoutes) resolveBatchProxyTargets(
+	ctx context.Context,
+	val *auth.ResourcePermissionValidator,
+	req *BatchProxyRequest,
+) ([]batchProxyTarget, *httperr.Error) {
+	// Validation is done per-connection below; failures are reported in the
+	// stream rather than failing the request.
+	val.MarkValidated()
+
+	if len(req.ConnectionIds) > 0 {
+		targets := make([]batchProxyTarget, 0, len(req.ConnectionIds))
+		seen := make(map[apid.ID]struct{}, len(req.ConnectionIds))
+		for _, id := range req.ConnectionIds {
+			if _, ok := seen[id]; ok {
+				continue
+			}
+			seen[id] = struct{}{}
+
+			conn, err := r.core.GetConnection(ctx, id)
+			if err != nil {
+				if errors.Is(err, iface.ErrConnectionNotFound) {
+					targets = append(targets, batchProxyTarget{id: id, err: httperr.NotFound("connection not found")})
+					continue
+				}
+				return nil, httperr.InternalServerError(httperr.WithInternalErr(err))
+			}
+
+			if httpErr := val.ValidateHttpStatusError(conn); httpErr != nil {
+				targets = append(targets, batchProxyTarget{id: id, err: httpErr})
+				continue
+			}
+
+			targets = append(targets, batchProxyTarget{id: id, conn: conn})
+		}
+		return targets, nil
+	}
+
+	var targets []batchProxyTarget
+	var tooMany bool
+	err := r.core.ListConnectionsBuilder().
+		ForLabelSelector(*req.LabelSelector).
+		ForNamespaceMatchers(val.GetEffectiveNamespaceMatchers(req.Namespace)).
+		ForState(database.ConnectionStateConfigured).
+		Enumerate(ctx, func(result pagination.PageResult[iface.Connection]) (pagination.KeepGoing, error) {
+			if result.Error != nil {
+				return pagination.Stop, result.Error
+			}
+			for _, conn := range auth.FilterForValidatedResources(val, result.Results) {
+				if len(targets) == schemaapi.MaxBatchProxyConnections {
+					tooMany = true
+					return pagination.Stop, nil
+				}
+				targets = append(targets, batchProxyTarget{id: conn.GetId(), conn: conn})
+			}
+			return pagination.Continue, nil
+		})
+	if err != nil {
+		return nil, httperr.InternalServerError(httperr.WithInternalErr(err))
+	}
+	if tooMany {
+		return nil, httperr.BadRequestf("label selector matches more than %d connections", schemaapi.MaxBatchProxyConnections)
+	}
+
+	return targets, nil
+}
+
+// executeBatchProxy sends template through each target, at most concurrency
+// at a time, calling emit with each result as it completes. emit may be
+// called from several goroutines at once. Each request passes through the
+// connection's usual middleware, so its rate limits, retries and caching all
+// apply; a request rejected by a rate limit comes back as a 429 response and
+// is counted as failed.
+func executeBatchProxy(
+	ctx context.Context,
+	targets []batchProxyTarget,
+	template *iface.ProxyRequest,
+	concurrency int,
+	emit func(*BatchProxyResult),
+) *BatchProxySummary {
+	correlationId := apctx.CorrelationID(ctx)
+	summary := &BatchProxySummary{
+		Type:          schemaapi.BatchProxyLineTypeSummary,
+		CorrelationId: correlationId,
+		Total:         len(targets),
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, max(concurrency, 1))
+
+	for _, target := range targets {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			res := &BatchProxyResult{
+				Type:          schemaapi.BatchProxyLineTypeResult,
+				CorrelationId: correlationId,
+				ConnectionId:  target.id,
+			}
+
+			resp, herr := proxyBatchTarget(ctx, target, template)
+			if herr != nil {
+				herr = herr.ForContext(ctx)
+				res.ErrorStatus = herr.Status
+				er := herr.ToErrorResponse(ctx)
+				res.Error = &ErrorResponse{Error: er.Error, StackTrace: er.StackTrace}
+			} else {
+				pr := ProxyResponse(*resp)
+				res.Response = &pr
+			}
+
+			mu.Lock()
+			if res.Response != nil && res.Response.StatusCode < http.StatusBadRequest {
+				summary.Succeeded++
+			} else {
+				summary.Failed++
+			}
+			mu.Unlock()
+
+			emit(res)
+		}()
+	}
+
+	wg.Wait()
+	return summary
+}
+
+// proxyBatchTarget makes the request for a single target of a batch.
+func proxyBatchTarget(ctx context.Context, target batchProxyTarget, template *iface.ProxyRequest) (*iface.ProxyResponse, *httperr.Error) {
+	if target.err != nil {
+		return nil, target.err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, httperr.FromError(err)
+	}
+
+	req := *template
+	u, err := url.Parse(template.URL)
+	if err != nil {
+		return nil, httperr.BadRequest("invalid url", httperr.WithInternalErr(err))
+	}
+
+	// A path-only URL is resolved under each connection's baseUrl so a batch
+	// can span connections whose upstream hosts differ.
+	if !u.IsAbs() && u.Host == "" {
+		base, err := target.conn.GetBaseUrl(ctx)
+		if err != nil {
+			return nil, httperr.BadRequest("could not resolve connector base url", httperr.WithInternalErr(err))
+		}
+		if base == nil {
+			return nil, httperr.BadRequest("connector does not declare a baseUrl; use an absolute url")
+		}
+
+		upstreamURL, uerr := upstreamURLForPath(base, u.EscapedPath(), u.RawQuery)
+		if uerr != nil {
+			return nil, uerr
+		}
+		req.URL = upstreamURL.String()
+	}
+
+	resp, err := target.conn.ProxyRequest(ctx, httpf.RequestTypeProxy, &req)
+	if err != nil {
+		return nil, httperr.FromError(err)
+	}
+
+	return resp, nil
+}
+
+// proxyRequestFromJson translates the wire request to the one the proxy
+// consumes.
+func proxyRequestFromJson(r schemaapi.ProxyRequestJson) iface.ProxyRequest {
+	return iface.ProxyRequest{
+		URL:      r.URL,
+		Method:   r.Method,
+		Headers:  r.Headers,
+		Labels:   r.Labels,
+		BodyRaw:  r.BodyRaw,
+		BodyJson: r.BodyJson,
+		Paginate: r.Paginate,
+		Priority: r.Priority,
+	}
+}
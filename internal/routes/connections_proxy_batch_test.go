@@ -0,0 +1,150 @@
+package routes
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rmorlok/authproxy/internal/apctx"
+	"github.com/rmorlok/authproxy/internal/apid"
+	"github.com/rmorlok/authproxy/internal/core/iface"
+	"github.com/rmorlok/authproxy/internal/core/mock"
+	"github.com/rmorlok/authproxy/internal/httperr"
+	"github.com/rmorlok/authproxy/internal/httpf"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type batchProxyConnection struct {
+	mock.Connection
+	respond func(req *iface.ProxyRequest) (*iface.ProxyResponse, error)
+}
+
+func (c *batchProxyConnection) ProxyRequest(ctx context.Context, reqType httpf.RequestType, req *iface.ProxyRequest) (*iface.ProxyResponse, error) {
+	return c.respond(req)
+}
+
+func TestExecuteBatchProxy(t *testing.T) {
+	ctx := apctx.WithCorrelationID(context.Background(), "cor_test")
+
+	collect := func(targets []batchProxyTarget, template *iface.ProxyRequest, concurrency int) (map[apid.ID]*BatchProxyResult, *BatchProxySummary) {
+		var mu sync.Mutex
+		results := map[apid.ID]*BatchProxyResult{}
+		summary := executeBatchProxy(ctx, targets, template, concurrency, func(res *BatchProxyResult) {
+			mu.Lock()
+			defer mu.Unlock()
+			results[res.ConnectionId] = res
+		})
+		return results, summary
+	}
+
+	t.Run("reports partial failures", func(t *testing.T) {
+		ok := &batchProxyConnection{respond: func(req *iface.ProxyRequest) (*iface.ProxyResponse, error) {
+			return &iface.ProxyResponse{StatusCode: http.StatusOK, BodyJson: map[string]any{"ok": true}}, nil
+		}}
+		upstream500 := &batchProxyConnection{respond: func(req *iface.ProxyRequest) (*iface.ProxyResponse, error) {
+			return &iface.ProxyResponse{StatusCode: http.StatusInternalServerError}, nil
+		}}
+		limited := &batchProxyConnection{respond: func(req *iface.ProxyRequest) (*iface.ProxyResponse, error) {
+			return nil, httperr.New(http.StatusTooManyRequests, "rate limited")
+		}}
+
+		targets := []batchProxyTarget{
+			{id: "cxn_ok", conn: ok},
+			{id: "cxn_500", conn: upstream500},
+			{id: "cxn_limited", conn: limited},
+			{id: "cxn_missing", err: httperr.NotFound("connection not found")},
+		}
+
+		results, summary := collect(targets, &iface.ProxyRequest{URL: "https://api.example.com/v1/me", Method: http.MethodGet}, 2)
+		require.Len(t, results, 4)
+
+		assert.Equal(t, http.StatusOK, results["cxn_ok"].Response.StatusCode)
+		assert.Nil(t, results["cxn_ok"].Error)
+		assert.Equal(t, "cor_test", results["cxn_ok"].CorrelationId)
+
+		assert.Equal(t, http.StatusInternalServerError, results["cxn_500"].Response.StatusCode)
+
+		assert.Nil(t, results["cxn_limited"].Response)
+		assert.Equal(t, http.StatusTooManyRequests, results["cxn_limited"].ErrorStatus)
+		assert.Equal(t, "rate limited", results["cxn_limited"].Error.Error)
+
+		assert.Equal(t, http.StatusNotFound, results["cxn_missing"].ErrorStatus)
+
+		assert.Equal(t, "cor_test", summary.CorrelationId)
+		assert.Equal(t, 4, summary.Total)
+		assert.Equal(t, 1, summary.Succeeded)
+		assert.Equal(t, 3, summary.Failed)
+	})
+
+	t.Run("bounds concurrency", func(t *testing.T) {
+		var inFlight, peak atomic.Int32
+		conn := &batchProxyConnection{respond: func(req *iface.ProxyRequest) (*iface.ProxyResponse, error) {
+			n := inFlight.Add(1)
+			defer inFlight.Add(-1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			return &iface.ProxyResponse{StatusCode: http.StatusOK}, nil
+		}}
+
+		var targets []batchProxyTarget
+		for i := 0; i < 20; i++ {
+			targets = append(targets, batchProxyTarget{id: apid.ID(fmt.Sprintf("cxn_%d", i)), conn: conn})
+		}
+
+		results, summary := collect(targets, &iface.ProxyRequest{URL: "https://api.example.com/v1/me", Method: http.MethodGet}, 3)
+		assert.Len(t, results, 20)
+		assert.Equal(t, 20, summary.Succeeded)
+		assert.LessOrEqual(t, peak.Load(), int32(3))
+	})
+
+	t.Run("resolves path-only urls against each connection's base url", func(t *testing.T) {
+		var mu sync.Mutex
+		seen := map[string]bool{}
+		newConn := func(base string) *batchProxyConnection {
+			c := &batchProxyConnection{respond: func(req *iface.ProxyRequest) (*iface.ProxyResponse, error) {
+				mu.Lock()
+				defer mu.Unlock()
+				seen[req.URL] = true
+				return &iface.ProxyResponse{StatusCode: http.StatusOK}, nil
+			}}
+			if base != "" {
+				u, err := url.Parse(base)
+				require.NoError(t, err)
+				c.BaseUrl = u
+			}
+			return c
+		}
+
+		targets := []batchProxyTarget{
+			{id: "cxn_a", conn: newConn("https://a.example.com/api/")},
+			{id: "cxn_b", conn: newConn("https://b.example.com/api")},
+			{id: "cxn_none", conn: newConn("")},
+		}
+
+		template := &iface.ProxyRequest{URL: "/v1/me?expand=org", Method: http.MethodGet}
+		results, summary := collect(targets, template, 3)
+
+		assert.True(t, seen["https://a.example.com/api/v1/me?expand=org"])
+		assert.True(t, seen["https://b.example.com/api/v1/me?expand=org"])
+		assert.Equal(t, http.StatusBadRequest, results["cxn_none"].ErrorStatus)
+		assert.Equal(t, 2, summary.Succeeded)
+		assert.Equal(t, "/v1/me?expand=org", template.URL)
+	})
+
+	t.Run("no targets", func(t *testing.T) {
+		results, summary := collect(nil, &iface.ProxyRequest{URL: "https://api.example.com", Method: http.MethodGet}, 10)
+		assert.Empty(t, results)
+		assert.Equal(t, 0, summary.Total)
+	})
+}
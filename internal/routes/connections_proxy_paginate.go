@@ -0,0 +1,83 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rmorlok/authproxy/internal/apgin"
+	"github.com/rmorlok/authproxy/internal/apserde"
+	"github.com/rmorlok/authproxy/internal/core/iface"
+	"github.com/rmorlok/authproxy/internal/httperr"
+	"github.com/rmorlok/authproxy/internal/httpf"
+	schemaapi "github.com/rmorlok/authproxy/internal/schema/api"
+)
+
+// proxyPages streams the pages of a paginated proxy request as
+// newline-delimited JSON: one ProxyPage line per page as it arrives, then a
+// ProxyPaginationSummary line. An error before the first page is written is
+// returned as a normal error response; after that it is reported in the
+// summary.
+func (r *ConnectionsProxyRoutes) proxyPages(gctx *gin.Context, conn iface.Connection, req *iface.ProxyRequest) {
+	ctx := gctx.Request.Context()
+
+	started := false
+	writeLine := func(v any) error {
+		data, _, err := apserde.MarshalJSONForAPI(ctx, v)
+		if err != nil {
+			return err
+		}
+		if !started {
+			started = true
+			gctx.Header("Content-Type", contentTypeNDJSON)
+			gctx.Status(http.StatusOK)
+			gctx.Writer.WriteHeaderNow()
+		}
+		if _, err := gctx.Writer.Write(data); err != nil {
+			return err
+		}
+		gctx.Writer.Flush()
+		return nil
+	}
+
+	result, err := conn.ProxyRequestPages(ctx, httpf.RequestTypeProxy, req, func(page *iface.ProxyPage) error {
+		return writeLine(proxyPageFromCore(page))
+	})
+	if err != nil && !started {
+		apgin.WriteErr(gctx, r.logger, err)
+		return
+	}
+
+	summary := &ProxyPaginationSummary{Type: schemaapi.ProxyPageLineTypeSummary}
+	if result != nil {
+		summary.Pages = result.Pages
+		summary.Items = result.Items
+		summary.Bytes = result.Bytes
+		summary.Truncated = result.Truncated
+	}
+	if err != nil {
+		er := httperr.FromError(err).ForContext(ctx).ToErrorResponse(ctx)
+		summary.Error = &ErrorResponse{Error: er.Error, StackTrace: er.StackTrace}
+	}
+
+	if err := writeLine(summary); err != nil {
+		r.logger.ErrorContext(ctx, "failed to write pagination summary", "error", err)
+	}
+}
+
+// proxyPageFromCore converts a fetched page to its stream line. Pages that
+// ended pagination with an error status carry the upstream body in place of
+// items.
+func proxyPageFromCore(page *iface.ProxyPage) *ProxyPage {
+	line := &ProxyPage{
+		Type:       schemaapi.ProxyPageLineTypePage,
+		Page:       page.Number,
+		StatusCode: page.Response.StatusCode,
+		Headers:    page.Response.Headers,
+		Items:      page.Items,
+	}
+	if page.Items == nil {
+		line.BodyRaw = page.Response.BodyRaw
+		line.BodyJson = page.Response.BodyJson
+	}
+	return line
+}
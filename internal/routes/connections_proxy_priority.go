@@ -0,0 +1,60 @@
+package routes
+
+import (
+	"net/http"
+	"strings"
+
+	authcore "github.com/rmorlok/authproxy/internal/apauth/core"
+	"github.com/rmorlok/authproxy/internal/core/iface"
+	"github.com/rmorlok/authproxy/internal/httperr"
+	"github.com/rmorlok/authproxy/internal/schema/common"
+)
+
+// HeaderPriority tags a proxied request with a priority class (interactive,
+// normal or batch) for rate limiting. It is the only way to set a priority
+// on the raw and upstream routes; the wrapped routes also accept a priority
+// field in the request body.
+const HeaderPriority = "X-AuthProxy-Priority"
+
+// ConnectionPrioritizeVerb is the permission verb, on the connections
+// resource, required to tag requests through a connection as interactive.
+// Interactive traffic can use capacity a rate limit holds back from other
+// classes, so it isn't open to every caller that can proxy.
+const ConnectionPrioritizeVerb = "prioritize"
+
+// resolveProxyPriority combines the priority from the request body with the
+// X-AuthProxy-Priority header. Either may be given; when both are they must
+// agree. Returns "" when the request is untagged.
+func resolveProxyPriority(header http.Header, requested common.RequestPriority) (common.RequestPriority, *httperr.Error) {
+	if requested != "" && !common.IsValidRequestPriority(requested) {
+		return "", httperr.BadRequestf("invalid priority %q", string(requested))
+	}
+
+	fromHeader := common.RequestPriority(strings.TrimSpace(header.Get(HeaderPriority)))
+	if fromHeader == "" {
+		return requested, nil
+	}
+	if !common.IsValidRequestPriority(fromHeader) {
+		return "", httperr.BadRequestf("invalid %s %q", HeaderPriority, string(fromHeader))
+	}
+	if requested != "" && requested != fromHeader {
+		return "", httperr.BadRequestf("priority %q conflicts with %s %q", string(requested), HeaderPriority, string(fromHeader))
+	}
+
+	return fromHeader, nil
+}
+
+// authorizeProxyPriority checks the caller may send requests through conn at
+// the given priority. Only interactive needs a grant; tagging a request
+// normal or batch never gives it more capacity than it would have untagged.
+func authorizeProxyPriority(ra *authcore.RequestAuth, conn iface.Connection, priority common.RequestPriority) *httperr.Error {
+	if priority != common.RequestPriorityInteractive {
+		return nil
+	}
+
+	if !ra.Allows(conn.GetNamespace(), "connections", ConnectionPrioritizeVerb, conn.GetId().String()) {
+		return httperr.Forbidden("connections:" + ConnectionPrioritizeVerb + " permission is required for interactive priority")
+	}
+
+	return nil
+}
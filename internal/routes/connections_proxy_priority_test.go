@@ -0,0 +1,86 @@
+package routes
+
+import (
+	"net/http"
+	"testing"
+
+	authcore "github.com/rmorlok/authproxy/internal/apauth/core"
+	"github.com/rmorlok/authproxy/internal/apid"
+	"github.com/rmorlok/authproxy/internal/core/mock"
+	aschema "github.com/rmorlok/authproxy/internal/schema/auth"
+	"github.com/rmorlok/authproxy/internal/schema/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveProxyPriority(t *testing.T) {
+	header := func(v string) http.Header {
+		h := http.Header{}
+		if v != "" {
+			h.Set(HeaderPriority, v)
+		}
+		return h
+	}
+
+	tests := []struct {
+		name      string
+		header    string
+		requested common.RequestPriority
+		want      common.RequestPriority
+		wantErr   string
+	}{
+		{name: "untagged"},
+		{name: "body only", requested: common.RequestPriorityBatch, want: common.RequestPriorityBatch},
+		{name: "header only", header: "interactive", want: common.RequestPriorityInteractive},
+		{name: "header trimmed", header: " batch ", want: common.RequestPriorityBatch},
+		{name: "both agree", header: "normal", requested: common.RequestPriorityNormal, want: common.RequestPriorityNormal},
+		{name: "both disagree", header: "interactive", requested: common.RequestPriorityBatch, wantErr: "conflicts with"},
+		{name: "invalid header", header: "urgent", wantErr: HeaderPriority},
+		{name: "invalid body", requested: "urgent", wantErr: "invalid priority"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveProxyPriority(header(tt.header), tt.requested)
+			if tt.wantErr != "" {
+				require.NotNil(t, err)
+				assert.Equal(t, http.StatusBadRequest, err.Status)
+				assert.Contains(t, err.ResponseMsg, tt.wantErr)
+				return
+			}
+			require.Nil(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestAuthorizeProxyPriority(t *testing.T) {
+	conn := &mock.Connection{Id: apid.New(apid.PrefixConnection), Namespace: "root.acme"}
+
+	proxyOnly := authcore.NewAuthenticatedRequestAuth(&authcore.Actor{
+		Id:         apid.New(apid.PrefixActor),
+		Namespace:  "root",
+		ExternalId: "sync-job",
+		Permissions: []aschema.Permission{
+			{Namespace: "root.**", Resources: []string{"connections"}, Verbs: []string{"proxy"}},
+		},
+	})
+	prioritize := authcore.NewAuthenticatedRequestAuth(&authcore.Actor{
+		Id:         apid.New(apid.PrefixActor),
+		Namespace:  "root",
+		ExternalId: "web-ui",
+		Permissions: []aschema.Permission{
+			{Namespace: "root.**", Resources: []string{"connections"}, Verbs: []string{"proxy", ConnectionPrioritizeVerb}},
+		},
+	})
+
+	for _, p := range []common.RequestPriority{"", common.RequestPriorityNormal, common.RequestPriorityBatch} {
+		assert.Nil(t, authorizeProxyPriority(proxyOnly, conn, p), "priority %q needs no grant", p)
+	}
+
+	err := authorizeProxyPriority(proxyOnly, conn, common.RequestPriorityInteractive)
+	require.NotNil(t, err)
+	assert.Equal(t, http.StatusForbidden, err.Status)
+
+	assert.Nil(t, authorizeProxyPriority(prioritize, conn, common.RequestPriorityInteractive))
+}
@@ -14,6 +14,7 @@ import (
 	"github.com/rmorlok/authproxy/internal/core/iface"
 	"github.com/rmorlok/authproxy/internal/httperr"
 	"github.com/rmorlok/authproxy/internal/httpf"
+	"github.com/rmorlok/authproxy/internal/schema/common"
 )
 
 const (
@@ -38,11 +39,12 @@ const (
 var (
 	headerUpstreamURLCanonical = http.CanonicalHeaderKey(HeaderUpstreamURL)
 	headerLabelCanonical       = http.CanonicalHeaderKey(HeaderLabel)
+	headerPriorityCanonical    = http.CanonicalHeaderKey(HeaderPriority)
 )
 
 // proxyRaw is the streaming raw-proxy handler. Parses the inbound
-// envelope (X-AuthProxy-Upstream-URL, repeated X-AuthProxy-Label, plus
-// the request body), builds an outbound *http.Request, and delegates to
+// envelope (X-AuthProxy-Upstream-URL, repeated X-AuthProxy-Label, an
+// optional X-AuthProxy-Priority, plus the request body), builds an outbound *http.Request, and delegates to
 // Connection.ProxyRequestRaw which applies the connection's credentials
 // and streams the upstream response back to the caller.
 //
@@ -51,6 +53,39 @@ var (
 //
 //	ANY /connections/{id}/_proxyRaw
 func (r *ConnectionsProxyRoutes) proxyRaw(gctx *gin.Context) {
+	conn, ok := r.loadRawProxyConnection(gctx)
+	if !ok {
+		return
+	}
+
+	parsed, perr := parseRawProxyEnvelope(gctx.Request)
+	if perr != nil {
+		apgin.WriteError(gctx, r.logger, perr)
+		return
+	}
+
+	if herr := authorizeProxyPriority(auth.MustGetAuthFromGinContext(gctx), conn, parsed.priority); herr != nil {
+		apgin.WriteError(gctx, r.logger, herr)
+		return
+	}
+
+	outbound, oerr := newRawProxyOutbound(gctx.Request, parsed.upstreamURL)
+	if oerr != nil {
+		apgin.WriteError(gctx, r.logger, oerr)
+		return
+	}
+
+	r.sendRawProxy(gctx, conn, &iface.RawProxyRequest{
+		Outbound: outbound,
+		Labels:   parsed.labels,
+		Priority: parsed.priority,
+	})
+}
+
+// loadRawProxyConnection resolves the :id connection and checks the
+// caller may proxy through it. Writes the error response and returns false
+// when the request can't proceed.
+func (r *ConnectionsProxyRoutes) loadRawProxyConnection(gctx *gin.Context) (iface.Connection, bool) {
 	ctx := gctx.Request.Context()
 	val := auth.MustGetValidatorFromGinContext(gctx)
 
@@ -58,7 +93,7 @@ func (r *ConnectionsProxyRoutes) proxyRaw(gctx *gin.Context) {
 	if err != nil || connectionUuid == apid.Nil {
 		apgin.WriteError(gctx, r.logger, httperr.BadRequest("invalid connection id", httperr.WithInternalErr(err)))
 		val.MarkErrorReturn()
-		return
+		return nil, false
 	}
 
 	conn, err := r.core.GetConnection(ctx, connectionUuid)
@@ -66,43 +101,43 @@ func (r *ConnectionsProxyRoutes) proxyRaw(gctx *gin.Context) {
 		if errors.Is(err, iface.ErrConnectionNotFound) {
 			apgin.WriteError(gctx, r.logger, httperr.NotFound("connection not found"))
 			val.MarkErrorReturn()
-			return
+			return nil, false
 		}
 		apgin.WriteError(gctx, r.logger, httperr.InternalServerError(httperr.WithInternalErr(err)))
 		val.MarkErrorReturn()
-		return
+		return nil, false
 	}
 
 	if httpErr := val.ValidateHttpStatusError(conn); httpErr != nil {
 		apgin.WriteError(gctx, r.logger, httpErr)
-		return
+		return nil, false
 	}
 
-	parsed, perr := parseRawProxyEnvelope(gctx.Request)
-	if perr != nil {
-		apgin.WriteError(gctx, r.logger, perr)
-		return
-	}
+	return conn, true
+}
 
-	outbound, oerr := http.NewRequestWithContext(ctx, gctx.Request.Method, parsed.upstreamURL.String(), gctx.Request.Body)
-	if oerr != nil {
-		apgin.WriteError(gctx, r.logger, httperr.BadRequest("could not build outbound request", httperr.WithInternalErr(oerr)))
-		return
+// newRawProxyOutbound builds the request sent upstream: the inbound
+// method, body and filtered headers aimed at upstreamURL.
+func newRawProxyOutbound(inbound *http.Request, upstreamURL *url.URL) (*http.Request, *httperr.Error) {
+	outbound, err := http.NewRequestWithContext(inbound.Context(), inbound.Method, upstreamURL.String(), inbound.Body)
+	if err != nil {
+		return nil, httperr.BadRequest("could not build outbound request", httperr.WithInternalErr(err))
 	}
 	// Forward the inbound Content-Length so chunked-vs-known framing
 	// survives the hop. Go's http.NewRequest only sets this for known
 	// body types; for our io.ReadCloser it stays unset.
-	outbound.ContentLength = gctx.Request.ContentLength
-	if hl := gctx.Request.Header.Get("Content-Length"); hl != "" {
+	outbound.ContentLength = inbound.ContentLength
+	if hl := inbound.Header.Get("Content-Length"); hl != "" {
 		outbound.Header.Set("Content-Length", hl)
 	}
-	copyInboundHeadersForRawProxy(outbound.Header, gctx.Request.Header)
-
-	rawReq := &iface.RawProxyRequest{
-		Outbound: outbound,
-		Labels:   parsed.labels,
-	}
+	copyInboundHeadersForRawProxy(outbound.Header, inbound.Header)
+	return outbound, nil
+}
 
+// sendRawProxy hands the request to the connection and streams the
+// upstream response back to the caller.
+func (r *ConnectionsProxyRoutes) sendRawProxy(gctx *gin.Context, conn iface.Connection, rawReq *iface.RawProxyRequest) {
+	ctx := gctx.Request.Context()
 	if err := conn.ProxyRequestRaw(ctx, httpf.RequestTypeProxy, rawReq, gctx.Writer); err != nil {
 		// If headers haven't been flushed yet we can still surface a
 		// JSON error; once they are on the wire we can only log.
@@ -120,6 +155,7 @@ func (r *ConnectionsProxyRoutes) proxyRaw(gctx *gin.Context) {
 type rawProxyEnvelope struct {
 	upstreamURL *url.URL
 	labels      map[string]string
+	priority    common.RequestPriority
 }
 
 func parseRawProxyEnvelope(req *http.Request) (*rawProxyEnvelope, *httperr.Error) {
@@ -143,7 +179,12 @@ func parseRawProxyEnvelope(req *http.Request) (*rawProxyEnvelope, *httperr.Error
 		return nil, lerr
 	}
 
-	return &rawProxyEnvelope{upstreamURL: u, labels: labels}, nil
+	priority, perr := resolveProxyPriority(req.Header, "")
+	if perr != nil {
+		return nil, perr
+	}
+
+	return &rawProxyEnvelope{upstreamURL: u, labels: labels, priority: priority}, nil
 }
 
 // parseLabelHeaders splits each X-AuthProxy-Label value at the first
@@ -175,6 +216,10 @@ func parseLabelHeaders(values []string) (map[string]string, *httperr.Error) {
 // hop: authentication (the connector's auth replaces it), our envelope
 // headers, and RFC 7230 §6.1 hop-by-hop headers. Host is set
 // automatically by http.NewRequest from the outbound URL.
+//
+// The exception is an upgrade request (e.g. a WebSocket handshake):
+// Upgrade and Connection: Upgrade are hop-by-hop, but the upgrade has to
+// be requested again on the upstream hop, so they are re-added.
 func copyInboundHeadersForRawProxy(dst, src http.Header) {
 	hopByConnection := map[string]struct{}{}
 	for _, v := range src.Values("Connection") {
@@ -187,7 +232,7 @@ func copyInboundHeadersForRawProxy(dst, src http.Header) {
 		switch canon {
 		case "Authorization":
 			continue
-		case headerUpstreamURLCanonical, headerLabelCanonical:
+		case headerUpstreamURLCanonical, headerLabelCanonical, headerPriorityCanonical:
 			continue
 		case "Host", "Content-Length":
 			continue
@@ -200,6 +245,25 @@ func copyInboundHeadersForRawProxy(dst, src http.Header) {
 		}
 		dst[canon] = append([]string(nil), vv...)
 	}
+
+	if upgrade := inboundUpgradeType(src); upgrade != "" {
+		dst.Set("Connection", "Upgrade")
+		dst.Set("Upgrade", upgrade)
+	}
+}
+
+// inboundUpgradeType returns the protocol the caller asked to switch to, or
+// "" when the request isn't an upgrade. Both Connection: upgrade and an
+// Upgrade header are required, per RFC 7230 §6.7.
+func inboundUpgradeType(h http.Header) string {
+	for _, v := range h.Values("Connection") {
+		for _, token := range splitCommaTrim(v) {
+			if strings.EqualFold(token, "upgrade") {
+				return h.Get("Upgrade")
+			}
+		}
+	}
+	return ""
 }
 
 // isHopByHopHeader mirrors the same list used inside internal/proxy for
@@ -69,6 +69,22 @@ func TestParseRawProxyEnvelope_AcceptsHTTPAndHTTPS(t *testing.T) {
 // characters that aren't safe inside a comma-delimited list. If this
 // regresses, namespace-scoped labels (org/key) silently lose their
 // separator.
+func TestParseRawProxyEnvelope_Priority(t *testing.T) {
+	parsed, err := parseRawProxyEnvelope(makeReq(t, map[string][]string{
+		HeaderUpstreamURL: {"https://api.example.com/v1/x"},
+		HeaderPriority:    {"batch"},
+	}))
+	require.Nil(t, err)
+	assert.Equal(t, "batch", string(parsed.priority))
+
+	_, err = parseRawProxyEnvelope(makeReq(t, map[string][]string{
+		HeaderUpstreamURL: {"https://api.example.com/v1/x"},
+		HeaderPriority:    {"urgent"},
+	}))
+	require.NotNil(t, err)
+	assert.Equal(t, http.StatusBadRequest, err.Status)
+}
+
 func TestParseLabelHeaders_RoundTripsKeysContainingSlash(t *testing.T) {
 	labels, err := parseLabelHeaders([]string{
 		"customer=acme",
@@ -127,6 +143,7 @@ func TestCopyInboundHeadersForRawProxy(t *testing.T) {
 	src.Set("Authorization", "Bearer secret")
 	src.Set(HeaderUpstreamURL, "https://api.example.com/x")
 	src.Add(HeaderLabel, "k=v")
+	src.Set(HeaderPriority, "batch")
 	src.Set("Content-Type", "application/json")
 	src.Set("Accept", "text/event-stream")
 	src.Set("Connection", "X-Custom-Hop")
@@ -146,6 +163,7 @@ func TestCopyInboundHeadersForRawProxy(t *testing.T) {
 	assert.Empty(t, dst.Get("Authorization"), "Authorization must be stripped — connector replaces it")
 	assert.Empty(t, dst.Get(HeaderUpstreamURL), "envelope header must not leak to upstream")
 	assert.Empty(t, dst.Get(HeaderLabel), "envelope header must not leak to upstream")
+	assert.Empty(t, dst.Get(HeaderPriority), "envelope header must not leak to upstream")
 	assert.Empty(t, dst.Get("Connection"), "hop-by-hop stripped")
 	assert.Empty(t, dst.Get("X-Custom-Hop"), "header named by Connection: ... stripped")
 	assert.Empty(t, dst.Get("Transfer-Encoding"), "hop-by-hop stripped")
@@ -163,3 +181,33 @@ func TestCopyInboundHeadersForRawProxy_PreservesRepeatedValues(t *testing.T) {
 
 	assert.Equal(t, []string{"one", "two"}, dst.Values("X-Multi"))
 }
+
+// TestCopyInboundHeadersForRawProxy_PreservesUpgrade — a WebSocket
+// handshake has to be requested again on the upstream hop, so the
+// Upgrade/Connection pair survives while other hop-by-hop headers named
+// by Connection are still stripped.
+func TestCopyInboundHeadersForRawProxy_PreservesUpgrade(t *testing.T) {
+	src := http.Header{}
+	src.Set("Connection", "keep-alive, Upgrade, X-Custom-Hop")
+	src.Set("Upgrade", "websocket")
+	src.Set("X-Custom-Hop", "should-be-stripped")
+	src.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	src.Set("Sec-WebSocket-Version", "13")
+
+	dst := http.Header{}
+	copyInboundHeadersForRawProxy(dst, src)
+
+	assert.Equal(t, "Upgrade", dst.Get("Connection"))
+	assert.Equal(t, "websocket", dst.Get("Upgrade"))
+	assert.Equal(t, "dGhlIHNhbXBsZSBub25jZQ==", dst.Get("Sec-WebSocket-Key"))
+	assert.Equal(t, "13", dst.Get("Sec-WebSocket-Version"))
+	assert.Empty(t, dst.Get("X-Custom-Hop"))
+
+	// Upgrade without Connection: upgrade is not an upgrade request.
+	src = http.Header{}
+	src.Set("Upgrade", "websocket")
+	dst = http.Header{}
+	copyInboundHeadersForRawProxy(dst, src)
+	assert.Empty(t, dst.Get("Upgrade"))
+	assert.Empty(t, dst.Get("Connection"))
+}
@@ -0,0 +1,160 @@
+package routes
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	auth "github.com/rmorlok/authproxy/internal/apauth/service"
+	"github.com/rmorlok/authproxy/internal/apgin"
+	"github.com/rmorlok/authproxy/internal/core/iface"
+	"github.com/rmorlok/authproxy/internal/httperr"
+)
+
+// upstreamRouteSegment separates the connection from the upstream path in
+// the path-style proxy route.
+const upstreamRouteSegment = "/upstream"
+
+// proxyUpstream is the path-style variant of proxyRaw. Instead of naming
+// the upstream URL in X-AuthProxy-Upstream-URL, the caller appends the
+// upstream path (and query) to the route, and it is resolved under the
+// connector's baseUrl. That lets an unmodified provider SDK be pointed at
+// .../connections/{id}/upstream as its base URL.
+//
+// Location and Link headers in the response that point under the base URL
+// are rewritten back to this route so redirects and pagination keep going
+// through the proxy.
+//
+//	ANY /connections/{id}/upstream/*path
+func (r *ConnectionsProxyRoutes) proxyUpstream(gctx *gin.Context) {
+	ctx := gctx.Request.Context()
+
+	conn, ok := r.loadRawProxyConnection(gctx)
+	if !ok {
+		return
+	}
+
+	base, err := conn.GetBaseUrl(ctx)
+	if err != nil {
+		apgin.WriteError(gctx, r.logger, httperr.BadRequest("could not resolve connector base url", httperr.WithInternalErr(err)))
+		return
+	}
+	if base == nil {
+		apgin.WriteError(gctx, r.logger, httperr.BadRequest("connector does not declare a baseUrl; use the _proxyRaw route instead"))
+		return
+	}
+
+	routeBase, rest, perr := splitUpstreamRoutePath(gctx.Request.URL.EscapedPath(), gctx.Param("id"))
+	if perr != nil {
+		apgin.WriteError(gctx, r.logger, perr)
+		return
+	}
+
+	upstreamURL, uerr := upstreamURLForPath(base, rest, gctx.Request.URL.RawQuery)
+	if uerr != nil {
+		apgin.WriteError(gctx, r.logger, uerr)
+		return
+	}
+
+	labels, lerr := parseLabelHeaders(gctx.Request.Header.Values(HeaderLabel))
+	if lerr != nil {
+		apgin.WriteError(gctx, r.logger, lerr)
+		return
+	}
+
+	priority, perr := resolveProxyPriority(gctx.Request.Header, "")
+	if perr != nil {
+		apgin.WriteError(gctx, r.logger, perr)
+		return
+	}
+	if herr := authorizeProxyPriority(auth.MustGetAuthFromGinContext(gctx), conn, priority); herr != nil {
+		apgin.WriteError(gctx, r.logger, herr)
+		return
+	}
+
+	outbound, oerr := newRawProxyOutbound(gctx.Request, upstreamURL)
+	if oerr != nil {
+		apgin.WriteError(gctx, r.logger, oerr)
+		return
+	}
+
+	r.sendRawProxy(gctx, conn, &iface.RawProxyRequest{
+		Outbound: outbound,
+		Labels:   labels,
+		Priority: priority,
+		ResponseUrlRewrite: &iface.UrlRewrite{
+			From: base,
+			To:   proxyRouteURL(gctx.Request, routeBase),
+		},
+	})
+}
+
+// splitUpstreamRoutePath splits the escaped request path into the route
+// prefix (everything up to and including /connections/{id}/upstream) and
+// the escaped upstream path that follows it. Working on the escaped path
+// keeps encoded characters such as %2F intact for the upstream.
+func splitUpstreamRoutePath(escapedPath, connectionId string) (string, string, *httperr.Error) {
+	marker := "/connections/" + connectionId + upstreamRouteSegment
+	idx := strings.Index(escapedPath, marker)
+	if idx < 0 {
+		return "", "", httperr.BadRequest("request path is not an upstream proxy route")
+	}
+	end := idx + len(marker)
+	return escapedPath[:end], escapedPath[end:], nil
+}
+
+// upstreamURLForPath resolves an escaped upstream path under base and
+// attaches the caller's query string. Dot segments are rejected, including
+// percent-encoded ones, since an upstream that normalizes them could
+// otherwise be reached outside the base path.
+func upstreamURLForPath(base *url.URL, escapedRest, rawQuery string) (*url.URL, *httperr.Error) {
+	for _, seg := range strings.Split(escapedRest, "/") {
+		decoded, err := url.PathUnescape(seg)
+		if err != nil {
+			return nil, httperr.BadRequest("invalid upstream path", httperr.WithInternalErr(err))
+		}
+		if decoded == "." || decoded == ".." {
+			return nil, httperr.BadRequest("upstream path must not contain dot segments")
+		}
+	}
+
+	escaped := strings.TrimSuffix(base.EscapedPath(), "/") + escapedRest
+	decoded, err := url.PathUnescape(escaped)
+	if err != nil {
+		return nil, httperr.BadRequest("invalid upstream path", httperr.WithInternalErr(err))
+	}
+
+	u := *base
+	u.Path = decoded
+	u.RawPath = escaped
+	u.RawQuery = rawQuery
+	return &u, nil
+}
+
+// proxyRouteURL is the absolute URL of the route prefix as the caller
+// addressed it, used as the target for rewritten response URLs. The host
+// is taken from the request so links work from whichever hostname the SDK
+// was configured with; X-Forwarded-Proto is honored for TLS-terminating
+// load balancers.
+func proxyRouteURL(req *http.Request, escapedRoutePath string) *url.URL {
+	scheme := "http"
+	if req.TLS != nil {
+		scheme = "https"
+	}
+	if proto := strings.ToLower(strings.TrimSpace(strings.Split(req.Header.Get("X-Forwarded-Proto"), ",")[0])); proto == "http" || proto == "https" {
+		scheme = proto
+	}
+
+	decoded, err := url.PathUnescape(escapedRoutePath)
+	if err != nil {
+		decoded = escapedRoutePath
+	}
+
+	return &url.URL{
+		Scheme:  scheme,
+		Host:    req.Host,
+		Path:    decoded,
+		RawPath: escapedRoutePath,
+	}
+}
@@ -0,0 +1,60 @@
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitUpstreamRoutePath(t *testing.T) {
+	prefix, rest, err := splitUpstreamRoutePath("/api/v1/connections/cxn_1/upstream/repos/o%2Fr/issues", "cxn_1")
+	require.Nil(t, err)
+	assert.Equal(t, "/api/v1/connections/cxn_1/upstream", prefix)
+	assert.Equal(t, "/repos/o%2Fr/issues", rest)
+
+	_, _, err = splitUpstreamRoutePath("/api/v1/connections/cxn_2/upstream/x", "cxn_1")
+	require.NotNil(t, err)
+}
+
+func TestUpstreamURLForPath(t *testing.T) {
+	base, err := url.Parse("https://acme.zendesk.com/api/v2/")
+	require.NoError(t, err)
+
+	t.Run("joins under the base path", func(t *testing.T) {
+		u, herr := upstreamURLForPath(base, "/tickets/1.json", "page=2&per_page=100")
+		require.Nil(t, herr)
+		assert.Equal(t, "https://acme.zendesk.com/api/v2/tickets/1.json?page=2&per_page=100", u.String())
+	})
+
+	t.Run("preserves encoded segments and trailing slash", func(t *testing.T) {
+		u, herr := upstreamURLForPath(base, "/users/a%2Fb/", "")
+		require.Nil(t, herr)
+		assert.Equal(t, "https://acme.zendesk.com/api/v2/users/a%2Fb/", u.String())
+	})
+
+	t.Run("empty path is the base", func(t *testing.T) {
+		u, herr := upstreamURLForPath(base, "", "")
+		require.Nil(t, herr)
+		assert.Equal(t, "https://acme.zendesk.com/api/v2", u.String())
+	})
+
+	for _, rest := range []string{"/../admin", "/tickets/%2e%2e/%2E%2E/admin", "/./tickets"} {
+		t.Run("rejects "+rest, func(t *testing.T) {
+			_, herr := upstreamURLForPath(base, rest, "")
+			require.NotNil(t, herr)
+			assert.Equal(t, http.StatusBadRequest, herr.Status)
+		})
+	}
+}
+
+func TestProxyRouteURL(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "http://authproxy.local:8080/api/v1/connections/cxn_1/upstream/x", nil)
+	assert.Equal(t, "http://authproxy.local:8080/api/v1/connections/cxn_1/upstream", proxyRouteURL(r, "/api/v1/connections/cxn_1/upstream").String())
+
+	r.Header.Set("X-Forwarded-Proto", "https")
+	assert.Equal(t, "https://authproxy.local:8080/api/v1/connections/cxn_1/upstream", proxyRouteURL(r, "/api/v1/connections/cxn_1/upstream").String())
+}
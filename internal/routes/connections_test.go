@@ -26,9 +26,11 @@ import (
 	"github.com/rmorlok/authproxy/internal/encfield"
 	"github.com/rmorlok/authproxy/internal/encrypt"
 	httpf2 "github.com/rmorlok/authproxy/internal/httpf"
+	"github.com/rmorlok/authproxy/internal/ratelimit"
 	"github.com/rmorlok/authproxy/internal/routes/key_value"
 	aschema "github.com/rmorlok/authproxy/internal/schema/auth"
 	sconfig "github.com/rmorlok/authproxy/internal/schema/config"
+	cschema "github.com/rmorlok/authproxy/internal/schema/resources/connectors"
 	"github.com/rmorlok/authproxy/internal/test_utils"
 	"github.com/rmorlok/authproxy/internal/util"
 	"github.com/stretchr/testify/assert"
@@ -42,6 +44,7 @@ func TestConnections(t *testing.T) {
 		Cfg      config.C
 		AuthUtil *auth2.AuthTestUtil
 		Db       database.DB
+		Redis    apredis.Client
 	}
 
 	connectorId := apid.MustParse("cxr_test0000000000001")
@@ -51,6 +54,8 @@ func TestConnections(t *testing.T) {
 	demoConnectorId := apid.MustParse("cxr_test0000000000003")
 	demoConnectorVersion := uint64(1)
 	demoConnectorNamespace := "root.demo"
+	headroomConnectorId := apid.MustParse("cxr_test0000000000004")
+	headroomConnectorVersion := uint64(1)
 
 	setup := func(t *testing.T, cfg config.C) (*TestSetup, func()) {
 		cfg = config.FromRoot(&sconfig.Root{
@@ -78,6 +83,15 @@ func TestConnections(t *testing.T) {
 						Labels:      map[string]string{"type": "demo-connector"},
 						DisplayName: "Demo Connector",
 					},
+					{
+						Id:          headroomConnectorId,
+						Version:     headroomConnectorVersion,
+						Labels:      map[string]string{"type": "headroom-connector"},
+						DisplayName: "Headroom Connector",
+						RateLimiting: &cschema.RateLimiting{
+							Headroom: &cschema.RateLimitHeadroom{},
+						},
+					},
 				},
 			},
 		})
@@ -101,6 +115,7 @@ func TestConnections(t *testing.T) {
 				Cfg:      cfg,
 				AuthUtil: authUtil,
 				Db:       db,
+				Redis:    rds,
 			}, func() {
 				ctrl.Finish()
 			}
@@ -2238,6 +2253,99 @@ func TestConnections(t *testing.T) {
 		})
 	})
 
+	t.Run("get connection rate limit headroom", func(t *testing.T) {
+		tu, done := setup(t, nil)
+		defer done()
+
+		headroomConnId := apid.New(apid.PrefixConnection)
+		require.NoError(t, tu.Db.CreateConnection(context.Background(), &database.Connection{
+			Id:               headroomConnId,
+			Namespace:        sconfig.RootNamespace,
+			ConnectorId:      headroomConnectorId,
+			ConnectorVersion: headroomConnectorVersion,
+			State:            database.ConnectionStateConfigured,
+		}))
+
+		plainConnId := apid.New(apid.PrefixConnection)
+		require.NoError(t, tu.Db.CreateConnection(context.Background(), &database.Connection{
+			Id:               plainConnId,
+			Namespace:        sconfig.RootNamespace,
+			ConnectorId:      connectorId,
+			ConnectorVersion: connectorVersion,
+			State:            database.ConnectionStateConfigured,
+		}))
+
+		get := func(t *testing.T, id string) *httptest.ResponseRecorder {
+			w := httptest.NewRecorder()
+			req, err := tu.AuthUtil.NewSignedRequestForActorExternalId(
+				http.MethodGet,
+				"/connections/"+id+"/rate-limit-headroom",
+				nil,
+				"root",
+				"some-actor",
+				aschema.AllPermissions(),
+			)
+			require.NoError(t, err)
+			tu.Gin.ServeHTTP(w, req)
+			return w
+		}
+
+		t.Run("unauthorized", func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, err := http.NewRequest(http.MethodGet, "/connections/"+headroomConnId.String()+"/rate-limit-headroom", nil)
+			require.NoError(t, err)
+
+			tu.Gin.ServeHTTP(w, req)
+			require.Equal(t, http.StatusUnauthorized, w.Code)
+		})
+
+		t.Run("bad uuid", func(t *testing.T) {
+			require.Equal(t, http.StatusBadRequest, get(t, "not-a-uuid").Code)
+		})
+
+		t.Run("connection not found", func(t *testing.T) {
+			require.Equal(t, http.StatusNotFound, get(t, apid.New(apid.PrefixConnection).String()).Code)
+		})
+
+		t.Run("connector without headroom returns 422", func(t *testing.T) {
+			require.Equal(t, http.StatusUnprocessableEntity, get(t, plainConnId.String()).Code)
+		})
+
+		t.Run("nothing learned yet", func(t *testing.T) {
+			w := get(t, headroomConnId.String())
+			require.Equal(t, http.StatusOK, w.Code)
+
+			var resp ConnectionRateLimitHeadroomJson
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+			assert.False(t, resp.Learned)
+			assert.Nil(t, resp.Remaining)
+		})
+
+		t.Run("learned headroom", func(t *testing.T) {
+			resetAt := time.Now().Add(time.Hour).Truncate(time.Second).UTC()
+			_, err := ratelimit.NewStore(tu.Redis).RecordHeadroom(
+				context.Background(),
+				headroomConnId,
+				ratelimit.Headroom{Remaining: 12, Limit: 100, ResetAt: resetAt},
+				time.Now(),
+			)
+			require.NoError(t, err)
+
+			w := get(t, headroomConnId.String())
+			require.Equal(t, http.StatusOK, w.Code)
+
+			var resp ConnectionRateLimitHeadroomJson
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+			assert.True(t, resp.Learned)
+			require.NotNil(t, resp.Remaining)
+			assert.Equal(t, int64(12), *resp.Remaining)
+			require.NotNil(t, resp.Limit)
+			assert.Equal(t, int64(100), *resp.Limit)
+			require.NotNil(t, resp.ResetAt)
+			assert.True(t, resetAt.Equal(*resp.ResetAt))
+		})
+	})
+
 	t.Run("resource name API", func(t *testing.T) {
 		tu, done := setup(t, nil)
 		defer done()
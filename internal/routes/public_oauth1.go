@@ -0,0 +1,187 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	auth "github.com/rmorlok/authproxy/internal/apauth/service"
+	"github.com/rmorlok/authproxy/internal/apid"
+	"github.com/rmorlok/authproxy/internal/apredis"
+	"github.com/rmorlok/authproxy/internal/auth_methods/oauth1"
+	"github.com/rmorlok/authproxy/internal/config"
+	"github.com/rmorlok/authproxy/internal/core/iface"
+	"github.com/rmorlok/authproxy/internal/database"
+	"github.com/rmorlok/authproxy/internal/encrypt"
+	"github.com/rmorlok/authproxy/internal/httpf"
+	sconfig "github.com/rmorlok/authproxy/internal/schema/config"
+)
+
+// PublicOauth1Routes are the browser-facing endpoints of the OAuth 1.0a three-legged flow. They mirror
+// PublicOauth2Routes: /oauth1/redirect obtains a request token and sends the user to the provider, and
+// /oauth1/callback exchanges the verifier for an access token.
+type PublicOauth1Routes struct {
+	cfg                         config.C
+	authService                 auth.A
+	sessionInitiateUrlGenerator SessionInitiateUrlGenerator
+	oauthf                      oauth1.Factory
+	logger                      *slog.Logger
+}
+
+func (r *PublicOauth1Routes) callback(gctx *gin.Context) {
+	ctx := gctx.Request.Context()
+
+	ra := auth.MustGetAuthFromGinContext(gctx)
+	// Permission was checked at the middleware level; there's no per-resource namespace to validate here.
+	auth.MustGetValidatorFromGinContext(gctx).MarkValidated()
+
+	if gctx.Query("state") == "" {
+		r.cfg.GetRoot().ErrorPages.RenderErrorOrRedirect(gctx, sconfig.ErrorTemplateValues{
+			Error: sconfig.ErrorPageInternalError,
+		}, errors.New("failed to bind state param"))
+		return
+	}
+
+	stateId, err := apid.Parse(gctx.Query("state"))
+	if err != nil {
+		r.cfg.GetRoot().ErrorPages.RenderErrorOrRedirect(gctx, sconfig.ErrorTemplateValues{
+			Error: sconfig.ErrorPageInternalError,
+		}, fmt.Errorf("failed to parse state param: %w", err))
+		return
+	}
+
+	oauthState, err := r.oauthf.GetOAuth1State(ctx, ra.MustGetActor(), stateId)
+	if err != nil {
+		r.cfg.GetRoot().ErrorPages.RenderErrorOrRedirect(gctx, sconfig.ErrorTemplateValues{
+			Error: sconfig.ErrorPageInternalError,
+		}, fmt.Errorf("failed to get oauth1 state: %w", err))
+		return
+	}
+
+	if oauthState.CancelSessionAfterAuth() {
+		err = r.authService.EndGinSession(gctx, ra)
+		if err != nil {
+			r.cfg.GetRoot().ErrorPages.RenderErrorOrRedirect(gctx, sconfig.ErrorTemplateValues{
+				Error: sconfig.ErrorPageInternalError,
+			}, fmt.Errorf("failed to end gin session: %w", err))
+			return
+		}
+	}
+
+	redirectUrl, err := oauthState.CallbackFrom3rdParty(ctx, gctx.Request.URL.Query())
+	if err != nil {
+		r.cfg.GetRoot().ErrorPages.RenderErrorOrRedirect(gctx, sconfig.ErrorTemplateValues{
+			Error: sconfig.ErrorPageInternalError,
+		}, fmt.Errorf("failed to handle oauth1 callback: %w", err))
+		return
+	}
+
+	gctx.Redirect(http.StatusFound, redirectUrl)
+}
+
+func (r *PublicOauth1Routes) redirect(gctx *gin.Context) {
+	ctx := gctx.Request.Context()
+
+	ra := auth.MustGetAuthFromGinContext(gctx)
+	// Permission was checked at the middleware level; there's no per-resource namespace to validate here.
+	auth.MustGetValidatorFromGinContext(gctx).MarkValidated()
+
+	// If we are not in a session, we create one, but cancel it after the oauth flow completes
+	shouldCancelSession := false
+	if !ra.IsSession() {
+		shouldCancelSession = true
+		err := r.authService.EstablishGinSession(gctx, ra)
+		if err != nil {
+			r.cfg.GetRoot().ErrorPages.RenderErrorOrRedirect(gctx, sconfig.ErrorTemplateValues{
+				Error: sconfig.ErrorPageInternalError,
+			}, fmt.Errorf("failed to establish gin session: %w", err))
+			return
+		}
+	}
+
+	var req RedirectParams
+	if err := gctx.ShouldBindQuery(&req); err != nil {
+		r.cfg.GetRoot().ErrorPages.RenderErrorOrRedirect(gctx, sconfig.ErrorTemplateValues{
+			Error: sconfig.ErrorPageInternalError,
+		}, fmt.Errorf("failed to bind redirect params: %w", err))
+		return
+	}
+
+	if req.StateId == "" {
+		r.cfg.GetRoot().ErrorPages.RenderErrorOrRedirect(gctx, sconfig.ErrorTemplateValues{
+			Error: sconfig.ErrorPageInternalError,
+		}, errors.New("stateId is required"))
+		return
+	}
+
+	stateId, err := apid.Parse(req.StateId)
+	if err != nil {
+		r.cfg.GetRoot().ErrorPages.RenderErrorOrRedirect(gctx, sconfig.ErrorTemplateValues{
+			Error: sconfig.ErrorPageInternalError,
+		}, fmt.Errorf("failed to parse stateId: %w", err))
+		return
+	}
+
+	o1, err := r.oauthf.GetOAuth1State(ctx, ra.MustGetActor(), stateId)
+	if err != nil {
+		r.cfg.GetRoot().ErrorPages.RenderErrorOrRedirect(gctx, sconfig.ErrorTemplateValues{
+			Error: sconfig.ErrorPageInternalError,
+		}, fmt.Errorf("failed to get oauth1 state: %w", err))
+		return
+	}
+
+	err = o1.RecordCancelSessionAfterAuth(ctx, shouldCancelSession)
+	if err != nil {
+		r.cfg.GetRoot().ErrorPages.RenderErrorOrRedirect(gctx, sconfig.ErrorTemplateValues{
+			Error: sconfig.ErrorPageInternalError,
+		}, fmt.Errorf("failed to record cancel session after auth: %w", err))
+		return
+	}
+
+	// Obtains the request token from the provider before redirecting, so a provider outage surfaces here rather
+	// than on the provider's authorize page.
+	redirectUrl, err := o1.GenerateAuthUrl(ctx, ra.MustGetActor())
+	if err != nil {
+		r.cfg.GetRoot().ErrorPages.RenderErrorOrRedirect(gctx, sconfig.ErrorTemplateValues{
+			Error: sconfig.ErrorPageInternalError,
+		}, fmt.Errorf("failed to generate oauth1 redirect url: %w", err))
+		return
+	}
+
+	gctx.Redirect(http.StatusFound, redirectUrl)
+}
+
+func (r *PublicOauth1Routes) Register(g *gin.Engine) {
+	// Same auth shape as the OAuth2 routes: browser-initiated, requires permission to create connections, and
+	// redirects through login on unauthenticated requests.
+	mw := r.authService.NewRequiredBuilder().
+		ForResource("connections").
+		ForVerb("create").
+		WithRedirectOnUnauthenticated(r.sessionInitiateUrlGenerator).
+		Build()
+
+	g.GET("/oauth1/callback", mw, r.callback)
+	g.GET("/oauth1/redirect", mw, RejectSnakeCaseQueryParams(), r.redirect)
+}
+
+func NewPublicOauth1Routes(
+	cfg config.C,
+	authService auth.A,
+	sessionInitiateUrlGenerator SessionInitiateUrlGenerator,
+	db database.DB,
+	r apredis.Client,
+	c iface.C,
+	httpf httpf.F,
+	encrypt encrypt.E,
+	logger *slog.Logger,
+) *PublicOauth1Routes {
+	return &PublicOauth1Routes{
+		cfg:                         cfg,
+		authService:                 authService,
+		sessionInitiateUrlGenerator: sessionInitiateUrlGenerator,
+		oauthf:                      oauth1.NewFactory(cfg, db, r, c, httpf, encrypt, logger),
+		logger:                      logger,
+	}
+}
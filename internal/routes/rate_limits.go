@@ -32,6 +32,8 @@ type DryRunContextJson = schemaapi.DryRunContextJson
 type DryRunResponseJson = schemaapi.DryRunResponseJson
 type DryRunMatchJson = schemaapi.DryRunMatchJson
 type DryRunNotMatchedJson = schemaapi.DryRunNotMatchedJson
+type RateLimitUsageJson = schemaapi.RateLimitUsageJson
+type RateLimitQuotaBucketUsageJson = schemaapi.RateLimitQuotaBucketUsageJson
 
 type OpenAPIRateLimitJson = schemaapiopenapi.RateLimitJson
 type OpenAPIListRateLimitsResponseJson = schemaapiopenapi.ListRateLimitsResponseJson
@@ -39,6 +41,7 @@ type OpenAPICreateRateLimitRequestJson = schemaapiopenapi.CreateRateLimitRequest
 type OpenAPIUpdateRateLimitRequestJson = schemaapiopenapi.UpdateRateLimitRequestJson
 type OpenAPIDryRunRequestJson = schemaapiopenapi.DryRunRequestJson
 type OpenAPIDryRunResponseJson = schemaapiopenapi.DryRunResponseJson
+type OpenAPIRateLimitUsageJson = schemaapiopenapi.RateLimitUsageJson
 
 type ListRateLimitsRequestQueryParams struct {
 	Cursor        *string `form:"cursor"`
@@ -62,6 +65,38 @@ func RateLimitToJson(r coreIface.RateLimit) RateLimitJson {
 	}
 }
 
+type GetRateLimitUsageRequestQueryParams struct {
+	LimitVal *int `form:"limit"`
+}
+
+const (
+	defaultRateLimitUsageBuckets = 100
+	maxRateLimitUsageBuckets     = 1000
+)
+
+// rateLimitUsageToJson renders usage with at most limit buckets. The
+// buckets arrive most-used first, so truncating keeps the ones closest to
+// running out; BucketCount still reports how many there are in total.
+func rateLimitUsageToJson(u coreIface.RateLimitUsage, limit int) RateLimitUsageJson {
+	n := min(len(u.Buckets), limit)
+	buckets := make([]RateLimitQuotaBucketUsageJson, n)
+	for i, b := range u.Buckets[:n] {
+		buckets[i] = RateLimitQuotaBucketUsageJson{
+			BucketKey: b.BucketKey,
+			Used:      b.Used,
+			Remaining: max(u.Limit-b.Used, 0),
+		}
+	}
+	return RateLimitUsageJson{
+		RateLimitId: u.RateLimitId,
+		PeriodStart: u.PeriodStart,
+		PeriodEnd:   u.PeriodEnd,
+		Limit:       u.Limit,
+		BucketCount: len(u.Buckets),
+		Buckets:     buckets,
+	}
+}
+
 type RateLimitsRoutes struct {
 	cfg           config.C
 	core          coreIface.C
@@ -74,14 +109,7 @@ type RateLimitsRoutes struct {
 // service consumes. Nothing here does business logic — it's just shape.
 func dryRunRequestToCore(r DryRunRequestJson) coreIface.DryRunRateLimitRequest {
 	return coreIface.DryRunRateLimitRequest{
-		Request: coreIface.ProxyRequest{
-			URL:      r.Request.URL,
-			Method:   r.Request.Method,
-			Headers:  r.Request.Headers,
-			Labels:   r.Request.Labels,
-			BodyRaw:  r.Request.BodyRaw,
-			BodyJson: r.Request.BodyJson,
-		},
+		Request:     proxyRequestFromJson(r.Request),
 		RequestType: r.RequestType,
 		Context: coreIface.DryRunRequestContext{
 			ConnectionId: r.Context.ConnectionId,
@@ -581,6 +609,78 @@ func (r *RateLimitsRoutes) dryRun(gctx *gin.Context) {
 	apgin.APIJSON(gctx, http.StatusOK, dryRunResponseFromCore(result))
 }
 
+// @Summary		Get quota usage
+// @Description	Report how much of a quota rate limit's allowance each bucket has consumed in the current period, most-used first. Only valid for rate limits using the quota algorithm.
+// @Tags			rate_limits
+// @Produce		json
+// @Param			id		path		string	true	"Rate limit ID"
+// @Param			limit	query		integer	false	"Maximum number of buckets to return (default 100, max 1000)"
+// @Success		200		{object}	OpenAPIRateLimitUsageJson
+// @Failure		400		{object}	ErrorResponse
+// @Failure		401		{object}	ErrorResponse
+// @Failure		404		{object}	ErrorResponse
+// @Failure		500		{object}	ErrorResponse
+// @Security		BearerAuth
+// @Router			/rate-limits/{id}/usage [get]
+func (r *RateLimitsRoutes) usage(gctx *gin.Context) {
+	ctx := gctx.Request.Context()
+	val := auth.MustGetValidatorFromGinContext(gctx)
+
+	id := apid.ID(gctx.Param("id"))
+	if id.IsNil() {
+		apgin.WriteError(gctx, nil, httperr.BadRequest("id is required"))
+		val.MarkErrorReturn()
+		return
+	}
+
+	var req GetRateLimitUsageRequestQueryParams
+	if err := gctx.ShouldBindQuery(&req); err != nil {
+		apgin.WriteError(gctx, nil, httperr.BadRequest(err.Error(), httperr.WithInternalErr(err)))
+		val.MarkErrorReturn()
+		return
+	}
+	limit := defaultRateLimitUsageBuckets
+	if req.LimitVal != nil {
+		if *req.LimitVal < 1 || *req.LimitVal > maxRateLimitUsageBuckets {
+			apgin.WriteError(gctx, nil, httperr.BadRequestf("limit must be between 1 and %d", maxRateLimitUsageBuckets))
+			val.MarkErrorReturn()
+			return
+		}
+		limit = *req.LimitVal
+	}
+
+	rl, err := r.core.GetRateLimit(ctx, id)
+	if err != nil {
+		if errors.Is(err, core.ErrNotFound) {
+			apgin.WriteError(gctx, nil, httperr.NotFound(fmt.Sprintf("rate limit '%s' not found", id), httperr.WithInternalErr(err)))
+			val.MarkErrorReturn()
+			return
+		}
+		apgin.WriteError(gctx, nil, httperr.InternalServerError(httperr.WithInternalErr(err)))
+		val.MarkErrorReturn()
+		return
+	}
+
+	if httpErr := val.ValidateHttpStatusError(rl); httpErr != nil {
+		apgin.WriteError(gctx, nil, httpErr)
+		return
+	}
+
+	usage, err := r.core.GetRateLimitUsage(ctx, id)
+	if err != nil {
+		if errors.Is(err, core.ErrInvalidArgument) {
+			apgin.WriteError(gctx, nil, httperr.BadRequestErr(err, httperr.WithPublicErr(err)))
+			val.MarkErrorReturn()
+			return
+		}
+		apgin.WriteError(gctx, nil, httperr.InternalServerError(httperr.WithInternalErr(err)))
+		val.MarkErrorReturn()
+		return
+	}
+
+	apgin.APIJSON(gctx, http.StatusOK, rateLimitUsageToJson(usage, limit))
+}
+
 // Label and annotation handlers delegate to the shared key_value adapter.
 
 // @Summary		Get all labels for a rate limit
@@ -769,6 +869,16 @@ func (r *RateLimitsRoutes) Register(g gin.IRouter) {
 			Build(),
 		r.delete,
 	)
+	g.GET(
+		"/rate-limits/:id/usage",
+		r.authService.NewRequiredBuilder().
+			ForResource("rate_limits").
+			ForIdField("id").
+			ForIdExtractor(idExtractor).
+			ForVerb("get").
+			Build(),
+		r.usage,
+	)
 	g.GET(
 		"/rate-limits/:id/labels",
 		r.authService.NewRequiredBuilder().
@@ -42,6 +42,7 @@ func TestRateLimits(t *testing.T) {
 		Db       database.DB
 		Core     coreIface.C
 		RlCache  ratelimit.MutableCache
+		Redis    apredis.Client
 	}
 
 	setup := func(t *testing.T) (*TestSetup, func()) {
@@ -75,6 +76,7 @@ func TestRateLimits(t *testing.T) {
 				Db:       db,
 				Core:     c,
 				RlCache:  rlCache,
+				Redis:    rds,
 			}, func() {
 				ctrl.Finish()
 			}
@@ -956,6 +958,88 @@ func TestRateLimits(t *testing.T) {
 		})
 	})
 
+	t.Run("usage", func(t *testing.T) {
+		tu, done := setup(t)
+		defer done()
+
+		quota := installRules(t, tu, "root", rlschema.RateLimit{
+			Bucket: rlschema.Bucket{Dimensions: []string{rlschema.DimensionActor}},
+			Algorithm: rlschema.Algorithm{
+				Quota: &rlschema.Quota{Period: rlschema.QuotaPeriodMonth, Limit: 10},
+			},
+		})[0]
+		tokenBucket := installRules(t, tu, "root", tokenBucketRule())[0]
+
+		l, err := ratelimit.NewLimiter(quota, tu.Redis, aplog.NewNoopLogger())
+		require.NoError(t, err)
+		consume := func(actor string, n int) {
+			bk := ratelimit.BucketKey{Components: []ratelimit.BucketKeyComponent{{Name: rlschema.DimensionActor, Value: actor}}}
+			for i := 0; i < n; i++ {
+				_, err := l.Decide(context.Background(), bk)
+				require.NoError(t, err)
+			}
+		}
+		consume("act_a", 3)
+		consume("act_b", 7)
+
+		getUsage := func(t *testing.T, path string, perms []aschema.Permission) (int, []byte) {
+			w := httptest.NewRecorder()
+			req, err := tu.AuthUtil.NewSignedRequestForActorExternalId(
+				http.MethodGet, path, nil, "root", "some-actor", perms,
+			)
+			require.NoError(t, err)
+			tu.Gin.ServeHTTP(w, req)
+			return w.Code, w.Body.Bytes()
+		}
+
+		t.Run("valid", func(t *testing.T) {
+			code, raw := getUsage(t, "/rate-limits/"+quota.Id.String()+"/usage", aschema.AllPermissions())
+			require.Equal(t, http.StatusOK, code, string(raw))
+
+			var resp RateLimitUsageJson
+			require.NoError(t, json.Unmarshal(raw, &resp))
+			require.Equal(t, quota.Id, resp.RateLimitId)
+			require.Equal(t, 10, resp.Limit)
+			require.Equal(t, 2, resp.BucketCount)
+			require.True(t, resp.PeriodEnd.After(resp.PeriodStart))
+			require.Equal(t, []RateLimitQuotaBucketUsageJson{
+				{BucketKey: "actor=act_b", Used: 7, Remaining: 3},
+				{BucketKey: "actor=act_a", Used: 3, Remaining: 7},
+			}, resp.Buckets)
+		})
+
+		t.Run("limit truncates to the most-used buckets", func(t *testing.T) {
+			code, raw := getUsage(t, "/rate-limits/"+quota.Id.String()+"/usage?limit=1", aschema.AllPermissions())
+			require.Equal(t, http.StatusOK, code, string(raw))
+
+			var resp RateLimitUsageJson
+			require.NoError(t, json.Unmarshal(raw, &resp))
+			require.Equal(t, 2, resp.BucketCount)
+			require.Len(t, resp.Buckets, 1)
+			require.Equal(t, "actor=act_b", resp.Buckets[0].BucketKey)
+		})
+
+		t.Run("invalid limit", func(t *testing.T) {
+			code, _ := getUsage(t, "/rate-limits/"+quota.Id.String()+"/usage?limit=0", aschema.AllPermissions())
+			require.Equal(t, http.StatusBadRequest, code)
+		})
+
+		t.Run("not a quota", func(t *testing.T) {
+			code, raw := getUsage(t, "/rate-limits/"+tokenBucket.Id.String()+"/usage", aschema.AllPermissions())
+			require.Equal(t, http.StatusBadRequest, code, string(raw))
+		})
+
+		t.Run("not found", func(t *testing.T) {
+			code, _ := getUsage(t, "/rate-limits/"+apid.New(apid.PrefixRateLimit).String()+"/usage", aschema.AllPermissions())
+			require.Equal(t, http.StatusNotFound, code)
+		})
+
+		t.Run("forbidden wrong verb", func(t *testing.T) {
+			code, _ := getUsage(t, "/rate-limits/"+quota.Id.String()+"/usage", aschema.PermissionsSingle("root.**", "rate_limits", "list"))
+			require.Equal(t, http.StatusForbidden, code)
+		})
+	})
+
 	t.Run("resource name API", func(t *testing.T) {
 		tu, done := setup(t)
 		defer done()
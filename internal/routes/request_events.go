@@ -17,6 +17,7 @@ import (
 	"github.com/rmorlok/authproxy/internal/httpf"
 	sapi "github.com/rmorlok/authproxy/internal/schema/api"
 	schemaapiopenapi "github.com/rmorlok/authproxy/internal/schema/api/openapi"
+	"github.com/rmorlok/authproxy/internal/schema/common"
 	"github.com/rmorlok/authproxy/internal/schema/resources/namespace"
 	"github.com/rmorlok/authproxy/internal/util"
 	"github.com/rmorlok/authproxy/internal/util/pagination"
@@ -56,6 +57,10 @@ type ListRequestEventsQuery struct {
 	LabelSelector            *string  `form:"labelSelector"`
 	ResponseSource           *string  `form:"responseSource"`
 	RateLimitId              *apid.ID `form:"rateLimitId" swaggertype:"string"`
+	RetryOf                  *apid.ID `form:"retryOf" swaggertype:"string"`
+	GraphqlOperationType     *string  `form:"graphqlOperationType"`
+	GraphqlOperationName     *string  `form:"graphqlOperationName"`
+	Priority                 *string  `form:"priority"`
 }
 
 func (q *ListRequestEventsQuery) ApplyToBuilder(
@@ -149,6 +154,25 @@ func (q *ListRequestEventsQuery) ApplyToBuilder(
 		b = b.ForRateLimitId(*q.RateLimitId)
 	}
 
+	if q.RetryOf != nil {
+		b = b.ForRetryOf(*q.RetryOf)
+	}
+
+	if q.GraphqlOperationType != nil {
+		b = b.ForGraphqlOperationType(*q.GraphqlOperationType)
+	}
+
+	if q.GraphqlOperationName != nil {
+		b = b.ForGraphqlOperationName(*q.GraphqlOperationName)
+	}
+
+	if q.Priority != nil {
+		if !common.IsValidRequestPriority(*q.Priority) {
+			return nil, httperr.BadRequestf("invalid priority %q", *q.Priority)
+		}
+		b = b.ForPriority(*q.Priority)
+	}
+
 	return b, nil
 }
 
@@ -162,45 +186,57 @@ func requestEventToJson(r *app_metrics.LogRecord) *sapi.RequestEventJson {
 	matches := make([]sapi.RequestEventRateLimit, len(r.RateLimitMatched))
 	for i, m := range r.RateLimitMatched {
 		matches[i] = sapi.RequestEventRateLimit{
-			Id:     m.Id,
-			Mode:   m.Mode,
-			Bucket: m.Bucket,
+			Id:        m.Id,
+			Mode:      m.Mode,
+			Bucket:    m.Bucket,
+			QuotaUsed: m.QuotaUsed,
 		}
 	}
 
 	return &sapi.RequestEventJson{
-		Namespace:           r.Namespace,
-		Type:                string(r.Type),
-		RequestId:           r.RequestId,
-		CorrelationId:       r.CorrelationId,
-		Timestamp:           r.Timestamp,
-		MillisecondDuration: int64(r.MillisecondDuration.Duration() / time.Millisecond),
-		ConnectionId:        r.ConnectionId,
-		ConnectorId:         r.ConnectorId,
-		ConnectorVersion:    r.ConnectorVersion,
-		Method:              r.Method,
-		Host:                r.Host,
-		Scheme:              r.Scheme,
-		Path:                r.Path,
-		RequestHttpVersion:  r.RequestHttpVersion,
-		RequestSizeBytes:    r.RequestSizeBytes,
-		RequestMimeType:     r.RequestMimeType,
-		RequestBodySkipped:  string(r.RequestBodySkipped),
-		ResponseStatusCode:  r.ResponseStatusCode,
-		ResponseError:       r.ResponseError,
-		ResponseHttpVersion: r.ResponseHttpVersion,
-		ResponseSizeBytes:   r.ResponseSizeBytes,
-		ResponseMimeType:    r.ResponseMimeType,
-		ResponseBodySkipped: string(r.ResponseBodySkipped),
-		InternalTimeout:     r.InternalTimeout,
-		RequestCancelled:    r.RequestCancelled,
-		FullRequestRecorded: r.FullRequestRecorded,
-		Labels:              r.Labels,
-		ResponseSource:      string(r.ResponseSource),
-		RateLimitId:         r.RateLimitId,
-		RateLimitMode:       r.RateLimitMode,
-		RateLimitBucket:     r.RateLimitBucket,
-		RateLimitMatched:    matches,
+		Namespace:            r.Namespace,
+		Type:                 string(r.Type),
+		RequestId:            r.RequestId,
+		CorrelationId:        r.CorrelationId,
+		Timestamp:            r.Timestamp,
+		MillisecondDuration:  int64(r.MillisecondDuration.Duration() / time.Millisecond),
+		ConnectionId:         r.ConnectionId,
+		ConnectorId:          r.ConnectorId,
+		ConnectorVersion:     r.ConnectorVersion,
+		Method:               r.Method,
+		Host:                 r.Host,
+		Scheme:               r.Scheme,
+		Path:                 r.Path,
+		RequestHttpVersion:   r.RequestHttpVersion,
+		RequestSizeBytes:     r.RequestSizeBytes,
+		RequestMimeType:      r.RequestMimeType,
+		RequestBodySkipped:   string(r.RequestBodySkipped),
+		ResponseStatusCode:   r.ResponseStatusCode,
+		ResponseError:        r.ResponseError,
+		ResponseHttpVersion:  r.ResponseHttpVersion,
+		ResponseSizeBytes:    r.ResponseSizeBytes,
+		ResponseMimeType:     r.ResponseMimeType,
+		ResponseBodySkipped:  string(r.ResponseBodySkipped),
+		InternalTimeout:      r.InternalTimeout,
+		RequestCancelled:     r.RequestCancelled,
+		FullRequestRecorded:  r.FullRequestRecorded,
+		Labels:               r.Labels,
+		OutboundProxy:        r.OutboundProxy,
+		UpgradeProtocol:      r.UpgradeProtocol,
+		SessionDuration:      int64(r.SessionMillisecondDuration.Duration() / time.Millisecond),
+		SessionBytesSent:     r.SessionBytesSent,
+		SessionBytesReceived: r.SessionBytesReceived,
+		Attempt:              r.Attempt,
+		RetryOf:              r.RetryOf,
+		GraphqlOperationType: r.GraphqlOperationType,
+		GraphqlOperationName: r.GraphqlOperationName,
+		Priority:             r.Priority,
+		ResponseSource:       string(r.ResponseSource),
+		RateLimitId:          r.RateLimitId,
+		RateLimitMode:        r.RateLimitMode,
+		RateLimitBucket:      r.RateLimitBucket,
+		RateLimitMatched:     matches,
+		RateLimitQueueWait:   int64(r.RateLimitQueueWait.Duration() / time.Millisecond),
 	}
 }
 
@@ -223,7 +259,8 @@ func metricsQueryToRequestEventQuery(
 			app_metrics.RequestEventGroupByMethod,
 			app_metrics.RequestEventGroupByResponseStatusCode,
 			app_metrics.RequestEventGroupByResponseSource,
-			app_metrics.RequestEventGroupByConnectorID:
+			app_metrics.RequestEventGroupByConnectorID,
+			app_metrics.RequestEventGroupByRateLimitID:
 			groupBy = append(groupBy, gb)
 		default:
 			return app_metrics.RequestEventMetricsQuery{}, httperr.BadRequestf("invalid group_by %q", raw)
@@ -262,6 +299,17 @@ func requestEventMetricFromAPI(metric, aggregation string) (app_metrics.RequestE
 		case "p95":
 			return app_metrics.RequestEventMetricDurationP95MS, nil
 		}
+	case "request_events.rate_limit_queue_wait_ms":
+		switch aggregation {
+		case "avg":
+			return app_metrics.RequestEventMetricRateLimitQueueWaitAvgMS, nil
+		case "p95":
+			return app_metrics.RequestEventMetricRateLimitQueueWaitP95MS, nil
+		}
+	case "request_events.rate_limit_quota_used":
+		if aggregation == "sum" {
+			return app_metrics.RequestEventMetricRateLimitQuotaUsedSum, nil
+		}
 	}
 	return "", httperr.BadRequestf("unsupported metric aggregation %q/%q", metric, aggregation)
 }
@@ -338,6 +386,7 @@ func metricsSchemaResponse() sapi.MetricsSchemaResponseJson {
 		string(app_metrics.RequestEventGroupByResponseStatusCode),
 		string(app_metrics.RequestEventGroupByResponseSource),
 		string(app_metrics.RequestEventGroupByConnectorID),
+		string(app_metrics.RequestEventGroupByRateLimitID),
 	}
 
 	return sapi.MetricsSchemaResponseJson{
@@ -360,6 +409,18 @@ func metricsSchemaResponse() sapi.MetricsSchemaResponseJson {
 				Aggregations: []string{"avg", "p95"},
 				GroupBy:      requestEventGroupBy,
 			},
+			{
+				Metric:       "request_events.rate_limit_queue_wait_ms",
+				Kind:         "gauge",
+				Aggregations: []string{"avg", "p95"},
+				GroupBy:      requestEventGroupBy,
+			},
+			{
+				Metric:       "request_events.rate_limit_quota_used",
+				Kind:         "counter",
+				Aggregations: []string{"sum"},
+				GroupBy:      requestEventGroupBy,
+			},
 			{
 				Metric:       "resources.connections",
 				Kind:         "gauge",
@@ -597,6 +658,10 @@ func (r *RequestEventsRoutes) get(gctx *gin.Context) {
 // @Param			path				query		string	false	"Filter by exact path"
 // @Param			pathRegex			query		string	false	"Filter by path regex"
 // @Param			labelSelector		query		string	false	"Filter by label selector (e.g., 'env=prod,team=api')"
+// @Param			retryOf			query		string	false	"Filter to every attempt of a retried proxy call, given the request ID of its first attempt"
+// @Param			graphqlOperationType	query		string	false	"Filter by GraphQL operation type (query, mutation, subscription)"
+// @Param			graphqlOperationName	query		string	false	"Filter by GraphQL operation name"
+// @Param			priority			query		string	false	"Filter by rate-limit priority (interactive, normal, batch); normal includes untagged requests"
 // @Success		200					{object}	OpenAPIListRequestEventsResponse
 // @Failure		400					{object}	ErrorResponse
 // @Failure		401					{object}	ErrorResponse
@@ -830,7 +830,13 @@ func TestRequestEventsRoutes(t *testing.T) {
 				Metric:       "request_events.duration_ms",
 				Kind:         "gauge",
 				Aggregations: []string{"avg", "p95"},
-				GroupBy:      []string{"type", "method", "response_status_code", "response_source", "connector_id"},
+				GroupBy:      []string{"type", "method", "response_status_code", "response_source", "connector_id", "rate_limit_id"},
+			})
+			require.Contains(t, resp.Metrics, sapi.MetricsSchemaMetricJson{
+				Metric:       "request_events.rate_limit_quota_used",
+				Kind:         "counter",
+				Aggregations: []string{"sum"},
+				GroupBy:      []string{"type", "method", "response_status_code", "response_source", "connector_id", "rate_limit_id"},
 			})
 			require.Contains(t, resp.Metrics, sapi.MetricsSchemaMetricJson{
 				Metric:       "resources.connections",
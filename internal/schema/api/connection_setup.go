@@ -3,6 +3,7 @@ package api
 import (
 	"encoding/json"
 	"fmt"
+	"time"
 
 	"github.com/hashicorp/go-multierror"
 	"github.com/rmorlok/authproxy/internal/apid"
@@ -64,11 +65,12 @@ func (icr *InitiateConnectionRequest) HasIntoNamespace() bool {
 type ConnectionSetupResponseType string
 
 const (
-	ConnectionSetupResponseTypeRedirect  ConnectionSetupResponseType = "redirect"
-	ConnectionSetupResponseTypeForm      ConnectionSetupResponseType = "form"
-	ConnectionSetupResponseTypeComplete  ConnectionSetupResponseType = "complete"
-	ConnectionSetupResponseTypeVerifying ConnectionSetupResponseType = "verifying"
-	ConnectionSetupResponseTypeError     ConnectionSetupResponseType = "error"
+	ConnectionSetupResponseTypeRedirect   ConnectionSetupResponseType = "redirect"
+	ConnectionSetupResponseTypeForm       ConnectionSetupResponseType = "form"
+	ConnectionSetupResponseTypeComplete   ConnectionSetupResponseType = "complete"
+	ConnectionSetupResponseTypeVerifying  ConnectionSetupResponseType = "verifying"
+	ConnectionSetupResponseTypeDeviceCode ConnectionSetupResponseType = "device_code"
+	ConnectionSetupResponseTypeError      ConnectionSetupResponseType = "error"
 )
 
 type ConnectionSetupResponse interface {
@@ -171,6 +173,51 @@ func (icv *ConnectionSetupVerifying) GetType() ConnectionSetupResponseType {
 	return icv.Type
 }
 
+// ConnectionSetupDeviceCode is returned while a device authorization grant is waiting for the user to approve
+// on a second device. The UI should display the user code and verification URI, then poll /_setup_step; the
+// connection advances on its own once the user approves, or moves to auth_failed when the code expires.
+//
+//	@Description	Device authorization response for connection setup
+type ConnectionSetupDeviceCode struct {
+	// Connection UUID.
+	Id apid.ID `json:"id" yaml:"id" swaggertype:"string" example:"cxn_test550e8400abcde"`
+
+	// Response type.
+	Type ConnectionSetupResponseType `json:"type" yaml:"type" swaggertype:"string" example:"device_code"`
+
+	// Step ID of the device authorization step.
+	StepId string `json:"stepId" yaml:"stepId" example:"apxy:auth:oauth2_device_code"`
+
+	// Step title.
+	StepTitle string `json:"stepTitle,omitempty" yaml:"stepTitle,omitempty" example:"Authorize on another device"`
+
+	// Step description.
+	StepDescription string `json:"stepDescription,omitempty" yaml:"stepDescription,omitempty"`
+
+	// Code the user enters at the verification URI.
+	UserCode string `json:"userCode" yaml:"userCode" example:"WDJB-MJHT"`
+
+	// URI the user visits on a second device to enter the user code.
+	VerificationUri string `json:"verificationUri" yaml:"verificationUri" example:"https://example.com/device"`
+
+	// Optional URI that embeds the user code, suitable for rendering as a QR code.
+	VerificationUriComplete string `json:"verificationUriComplete,omitempty" yaml:"verificationUriComplete,omitempty" example:"https://example.com/device?user_code=WDJB-MJHT"`
+
+	// When the user code expires.
+	ExpiresAt time.Time `json:"expiresAt" yaml:"expiresAt" example:"2025-01-01T00:15:00Z"`
+
+	// Suggested polling interval for /_setup_step, in seconds.
+	Interval int `json:"interval" yaml:"interval" example:"5"`
+}
+
+func (icd *ConnectionSetupDeviceCode) GetId() apid.ID {
+	return icd.Id
+}
+
+func (icd *ConnectionSetupDeviceCode) GetType() ConnectionSetupResponseType {
+	return icd.Type
+}
+
 // ConnectionSetupError is a terminal error response during setup, e.g. when probe verification
 // fails. The UI should show the error and offer retry (POST /_retry) or cancel (POST /_abort).
 type ConnectionSetupError struct {
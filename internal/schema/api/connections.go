@@ -1,8 +1,10 @@
 package api
 
 import (
+	"fmt"
 	"time"
 
+	"github.com/hashicorp/go-multierror"
 	"github.com/rmorlok/authproxy/internal/apid"
 	"github.com/rmorlok/authproxy/internal/schema/common"
 	cschema "github.com/rmorlok/authproxy/internal/schema/resources/connectors"
@@ -105,3 +107,228 @@ type ProxyResponseJson struct {
 	BodyRaw    []byte            `json:"bodyRaw,omitempty" yaml:"bodyRaw,omitempty"`
 	BodyJson   interface{}       `json:"bodyJson,omitempty" yaml:"bodyJson,omitempty"`
 }
+
+// ProxyPaginateFormat is how the pages of a paginated proxy request are returned.
+type ProxyPaginateFormat string
+
+const (
+	// ProxyPaginateFormatMerge returns one response whose bodyJson is the items of every page in order.
+	ProxyPaginateFormatMerge ProxyPaginateFormat = "merge"
+
+	// ProxyPaginateFormatNDJSON streams one line per page followed by a summary line.
+	ProxyPaginateFormatNDJSON ProxyPaginateFormat = "ndjson"
+)
+
+const (
+	DefaultProxyPaginateMaxPages = 10
+	MaxProxyPaginateMaxPages     = 100
+	DefaultProxyPaginateMaxBytes = 10 * int64(common.MiB)
+	MaxProxyPaginateMaxBytes     = 100 * int64(common.MiB)
+)
+
+// ProxyPaginateJson asks the wrapped proxy to follow the pages of a GET request, using the pagination rule the
+// connector declares for the request's path. Pages are followed until there are no more or a limit is reached.
+//
+//	@Description	Options for following the pages of a proxied request
+type ProxyPaginateJson struct {
+	// Most pages to fetch. Defaults to 10; at most 100.
+	MaxPages int `json:"maxPages,omitempty" yaml:"maxPages,omitempty" example:"10"`
+
+	// Most response body bytes to fetch across pages. No page is fetched once this is reached. Defaults to 10MiB; at
+	// most 100MiB.
+	MaxBytes int64 `json:"maxBytes,omitempty" yaml:"maxBytes,omitempty" example:"10485760"`
+
+	// How the pages are returned. Defaults to merge.
+	Format ProxyPaginateFormat `json:"format,omitempty" yaml:"format,omitempty" example:"merge"`
+}
+
+func (p *ProxyPaginateJson) Validate() error {
+	result := &multierror.Error{}
+
+	if p.MaxPages < 0 || p.MaxPages > MaxProxyPaginateMaxPages {
+		result = multierror.Append(result, fmt.Errorf("paginate.max_pages must be between 0 and %d", MaxProxyPaginateMaxPages))
+	}
+
+	if p.MaxBytes < 0 || p.MaxBytes > MaxProxyPaginateMaxBytes {
+		result = multierror.Append(result, fmt.Errorf("paginate.max_bytes must be between 0 and %d", MaxProxyPaginateMaxBytes))
+	}
+
+	switch p.Format {
+	case "", ProxyPaginateFormatMerge, ProxyPaginateFormatNDJSON:
+	default:
+		result = multierror.Append(result, fmt.Errorf("paginate.format must be %q or %q", ProxyPaginateFormatMerge, ProxyPaginateFormatNDJSON))
+	}
+
+	return result.ErrorOrNil()
+}
+
+// GetMaxPages returns the most pages to fetch.
+func (p *ProxyPaginateJson) GetMaxPages() int {
+	if p.MaxPages <= 0 {
+		return DefaultProxyPaginateMaxPages
+	}
+	return p.MaxPages
+}
+
+// GetMaxBytes returns the most response body bytes to fetch across pages.
+func (p *ProxyPaginateJson) GetMaxBytes() int64 {
+	if p.MaxBytes <= 0 {
+		return DefaultProxyPaginateMaxBytes
+	}
+	return p.MaxBytes
+}
+
+// GetFormat returns how the pages are returned.
+func (p *ProxyPaginateJson) GetFormat() ProxyPaginateFormat {
+	if p.Format == "" {
+		return ProxyPaginateFormatMerge
+	}
+	return p.Format
+}
+
+// ProxyPageLineType distinguishes the lines of a paginated proxy response stream.
+type ProxyPageLineType string
+
+const (
+	ProxyPageLineTypePage    ProxyPageLineType = "page"
+	ProxyPageLineTypeSummary ProxyPageLineType = "summary"
+)
+
+// ProxyPageJson is one line of the NDJSON stream returned by a proxy request paginated with the ndjson format. A
+// page the upstream answered with an error status carries its body instead of items, and ends pagination.
+//
+//	@Description	One page of a paginated proxied request
+type ProxyPageJson struct {
+	Type       ProxyPageLineType `json:"type" yaml:"type" example:"page"`
+	Page       int               `json:"page" yaml:"page" example:"1"`
+	StatusCode int               `json:"statusCode" yaml:"statusCode" example:"200"`
+	Headers    map[string]string `json:"headers,omitempty" yaml:"headers,omitempty"`
+	Items      []interface{}     `json:"items,omitempty" yaml:"items,omitempty"`
+	BodyRaw    []byte            `json:"bodyRaw,omitempty" yaml:"bodyRaw,omitempty"`
+	BodyJson   interface{}       `json:"bodyJson,omitempty" yaml:"bodyJson,omitempty"`
+}
+
+// ProxyPaginationSummaryJson is the final line of the NDJSON stream returned by a proxy request paginated with the
+// ndjson format. Truncated is set when a limit stopped pagination while the upstream had more pages; Error is set
+// when a page could not be fetched.
+//
+//	@Description	Totals for a paginated proxied request
+type ProxyPaginationSummaryJson struct {
+	Type      ProxyPageLineType `json:"type" yaml:"type" example:"summary"`
+	Pages     int               `json:"pages" yaml:"pages" example:"3"`
+	Items     int               `json:"items" yaml:"items" example:"250"`
+	Bytes     int64             `json:"bytes" yaml:"bytes" example:"48213"`
+	Truncated bool              `json:"truncated" yaml:"truncated"`
+	Error     *ErrorResponse    `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+const (
+	// DefaultBatchProxyConcurrency is how many connections a batch proxy request calls at once when the request does
+	// not say.
+	DefaultBatchProxyConcurrency = 10
+
+	// MaxBatchProxyConcurrency caps how many connections a single batch proxy request calls at once.
+	MaxBatchProxyConcurrency = 50
+
+	// MaxBatchProxyConnections caps how many connections a single batch proxy request may target.
+	MaxBatchProxyConnections = 1000
+)
+
+// BatchProxyRequestJson is the request body for POST /connections/_batchProxy. The same request is sent through
+// every targeted connection; a path-only URL is resolved against each connection's connector baseUrl.
+//
+//	@Description	Request to proxy the same HTTP request through many connections
+type BatchProxyRequestJson struct {
+	// Connections to send the request through. Mutually exclusive with LabelSelector.
+	ConnectionIds []apid.ID `json:"connectionIds,omitempty" yaml:"connectionIds,omitempty" swaggertype:"array,string" example:"cxn_test550e8400abcde"`
+
+	// Label selector choosing the configured connections to send the request through. Mutually exclusive with
+	// ConnectionIds.
+	LabelSelector *string `json:"labelSelector,omitempty" yaml:"labelSelector,omitempty" example:"tier=enterprise"`
+
+	// Namespace matcher narrowing the connections matched by LabelSelector.
+	Namespace *string `json:"namespace,omitempty" yaml:"namespace,omitempty" example:"root.acme.**"`
+
+	// The request sent through each connection.
+	Request ProxyRequestJson `json:"request" yaml:"request"`
+
+	// How many connections are called at once. Defaults to 10; at most 50.
+	Concurrency *int `json:"concurrency,omitempty" yaml:"concurrency,omitempty" example:"10"`
+}
+
+func (r *BatchProxyRequestJson) Validate() error {
+	result := &multierror.Error{}
+
+	hasIds := len(r.ConnectionIds) > 0
+	hasSelector := r.LabelSelector != nil && *r.LabelSelector != ""
+
+	if hasIds == hasSelector {
+		result = multierror.Append(result, fmt.Errorf("exactly one of connection_ids or label_selector is required"))
+	}
+
+	if len(r.ConnectionIds) > MaxBatchProxyConnections {
+		result = multierror.Append(result, fmt.Errorf("connection_ids may list at most %d connections", MaxBatchProxyConnections))
+	}
+
+	for i, id := range r.ConnectionIds {
+		if id == apid.Nil {
+			result = multierror.Append(result, fmt.Errorf("connection_ids[%d] must not be empty", i))
+		} else if err := id.ValidatePrefix(apid.PrefixConnection); err != nil {
+			result = multierror.Append(result, fmt.Errorf("connection_ids[%d]: %w", i, err))
+		}
+	}
+
+	if r.Namespace != nil && !hasSelector {
+		result = multierror.Append(result, fmt.Errorf("namespace can only be used with label_selector"))
+	}
+
+	if r.Concurrency != nil && (*r.Concurrency < 1 || *r.Concurrency > MaxBatchProxyConcurrency) {
+		result = multierror.Append(result, fmt.Errorf("concurrency must be between 1 and %d", MaxBatchProxyConcurrency))
+	}
+
+	return result.ErrorOrNil()
+}
+
+// GetConcurrency returns how many connections are called at once.
+func (r *BatchProxyRequestJson) GetConcurrency() int {
+	if r.Concurrency == nil {
+		return DefaultBatchProxyConcurrency
+	}
+	return *r.Concurrency
+}
+
+// BatchProxyLineType distinguishes the lines of a batch proxy response stream.
+type BatchProxyLineType string
+
+const (
+	BatchProxyLineTypeResult  BatchProxyLineType = "result"
+	BatchProxyLineTypeSummary BatchProxyLineType = "summary"
+)
+
+// BatchProxyResultJson is one line of the NDJSON stream returned by POST /connections/_batchProxy, reporting the
+// outcome of the request for one connection. Exactly one of Response and Error is set: Response when the upstream
+// answered, whatever its status, and Error when the request could not be made through the connection.
+//
+//	@Description	Outcome of a batch proxy request for one connection
+type BatchProxyResultJson struct {
+	Type          BatchProxyLineType `json:"type" yaml:"type" example:"result"`
+	CorrelationId string             `json:"correlationId" yaml:"correlationId" example:"cor_test550e8400abcde"`
+	ConnectionId  apid.ID            `json:"connectionId" yaml:"connectionId" swaggertype:"string" example:"cxn_test550e8400abcde"`
+	Response      *ProxyResponseJson `json:"response,omitempty" yaml:"response,omitempty"`
+
+	// The HTTP status the error would have had on the single-connection proxy endpoint.
+	ErrorStatus int            `json:"errorStatus,omitempty" yaml:"errorStatus,omitempty" example:"429"`
+	Error       *ErrorResponse `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// BatchProxySummaryJson is the final line of the NDJSON stream returned by POST /connections/_batchProxy. Results
+// with an upstream response below 400 count as succeeded; errors and upstream 4xx/5xx responses count as failed.
+//
+//	@Description	Totals for a batch proxy request
+type BatchProxySummaryJson struct {
+	Type          BatchProxyLineType `json:"type" yaml:"type" example:"summary"`
+	CorrelationId string             `json:"correlationId" yaml:"correlationId" example:"cor_test550e8400abcde"`
+	Total         int                `json:"total" yaml:"total" example:"3"`
+	Succeeded     int                `json:"succeeded" yaml:"succeeded" example:"2"`
+	Failed        int                `json:"failed" yaml:"failed" example:"1"`
+}
@@ -358,6 +358,8 @@ type ProxyRequestJson struct {
 	Labels   map[string]string `json:"labels,omitempty"`
 	BodyRaw  []byte            `json:"bodyRaw,omitempty"`
 	BodyJson interface{}       `json:"bodyJson,omitempty"`
+	Paginate interface{}       `json:"paginate,omitempty"`
+	Priority string            `json:"priority,omitempty" enums:"interactive,normal,batch" example:"normal"`
 }
 
 // DryRunRequestJson documents the rate-limit dry-run request body.
@@ -405,6 +407,24 @@ type DryRunNotMatchedJson struct {
 	Reason      string `json:"reason"`
 }
 
+// RateLimitUsageJson documents the quota usage response.
+//
+//	@Description	Per-bucket consumption of a quota rate limit in the current period
+type RateLimitUsageJson struct {
+	RateLimitId string        `json:"rateLimitId" swaggertype:"string" example:"rl_test550e8400abcde"`
+	PeriodStart string        `json:"periodStart" example:"2026-01-01T00:00:00Z"`
+	PeriodEnd   string        `json:"periodEnd" example:"2026-02-01T00:00:00Z"`
+	Limit       int           `json:"limit" example:"10000"`
+	BucketCount int           `json:"bucketCount" example:"1"`
+	Buckets     []interface{} `json:"buckets"`
+}
+
+type RateLimitQuotaBucketUsageJson struct {
+	BucketKey string `json:"bucketKey" example:"actor=act_abc"`
+	Used      int    `json:"used" example:"8200"`
+	Remaining int    `json:"remaining" example:"1800"`
+}
+
 // ProxyResponseJson documents the response from a proxied request.
 //
 //	@Description	Response from a proxied HTTP request
@@ -414,3 +434,14 @@ type ProxyResponseJson struct {
 	BodyRaw    []byte            `json:"bodyRaw,omitempty"`
 	BodyJson   interface{}       `json:"bodyJson,omitempty"`
 }
+
+// BatchProxyRequestJson documents the batch proxy request body.
+//
+//	@Description	Request to proxy the same HTTP request through many connections
+type BatchProxyRequestJson struct {
+	ConnectionIds []string    `json:"connectionIds,omitempty" example:"cxn_test550e8400abcde"`
+	LabelSelector string      `json:"labelSelector,omitempty" example:"tier=enterprise"`
+	Namespace     string      `json:"namespace,omitempty" example:"root.acme.**"`
+	Request       interface{} `json:"request"`
+	Concurrency   int         `json:"concurrency,omitempty" example:"10"`
+}
@@ -59,6 +59,8 @@ type ProxyRequestJson struct {
 	Labels   map[string]string            `json:"labels,omitempty" yaml:"labels,omitempty"`
 	BodyRaw  []byte                       `json:"bodyRaw,omitempty" yaml:"bodyRaw,omitempty"`
 	BodyJson interface{}                  `json:"bodyJson,omitempty" yaml:"bodyJson,omitempty"`
+	Paginate *ProxyPaginateJson           `json:"paginate,omitempty" yaml:"paginate,omitempty"`
+	Priority common.RequestPriority       `json:"priority,omitempty" yaml:"priority,omitempty" swaggertype:"string" enums:"interactive,normal,batch" example:"normal"`
 }
 
 // DryRunRequestJson is the request body for POST /rate-limits/_dry_run.
@@ -99,3 +101,21 @@ type DryRunNotMatchedJson struct {
 	Namespace   string  `json:"namespace" yaml:"namespace" example:"root.acme"`
 	Reason      string  `json:"reason" yaml:"reason" example:"method did not match"`
 }
+
+// RateLimitUsageJson is the response for GET /rate-limits/:id/usage.
+//
+//	@Description	Per-bucket consumption of a quota rate limit in the current period
+type RateLimitUsageJson struct {
+	RateLimitId apid.ID                         `json:"rateLimitId" yaml:"rateLimitId" swaggertype:"string" example:"rl_test550e8400abcde"`
+	PeriodStart time.Time                       `json:"periodStart" yaml:"periodStart"`
+	PeriodEnd   time.Time                       `json:"periodEnd" yaml:"periodEnd"`
+	Limit       int                             `json:"limit" yaml:"limit" example:"10000"`
+	BucketCount int                             `json:"bucketCount" yaml:"bucketCount" example:"1"`
+	Buckets     []RateLimitQuotaBucketUsageJson `json:"buckets" yaml:"buckets"`
+}
+
+type RateLimitQuotaBucketUsageJson struct {
+	BucketKey string `json:"bucketKey" yaml:"bucketKey" example:"actor=act_abc"`
+	Used      int    `json:"used" yaml:"used" example:"8200"`
+	Remaining int    `json:"remaining" yaml:"remaining" example:"1800"`
+}
@@ -8,6 +8,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/rmorlok/authproxy/internal/apid"
 	jsonschemav5 "github.com/santhosh-tekuri/jsonschema/v5"
 	"github.com/stretchr/testify/require"
 )
@@ -106,8 +107,15 @@ func TestSchemaSamples(t *testing.T) {
 		{name: "list rate limits", ref: "./schema.json#/$defs/ListRateLimitsResponse", file: "valid-list-rate-limits.json"},
 		{name: "create rate limit", ref: "./schema.json#/$defs/CreateRateLimitRequest", file: "valid-create-rate-limit.json"},
 		{name: "update rate limit", ref: "./schema.json#/$defs/UpdateRateLimitRequest", file: "valid-update-rate-limit.json"},
+		{name: "batch proxy request", ref: "./schema.json#/$defs/BatchProxyRequest", file: "valid-batch-proxy-request.json"},
+		{name: "batch proxy result", ref: "./schema.json#/$defs/BatchProxyResult", file: "valid-batch-proxy-result.json"},
+		{name: "batch proxy summary", ref: "./schema.json#/$defs/BatchProxySummary", file: "valid-batch-proxy-summary.json"},
+		{name: "paginated proxy request", ref: "./schema.json#/$defs/ProxyRequest", file: "valid-proxy-request-paginate.json"},
+		{name: "proxy page", ref: "./schema.json#/$defs/ProxyPage", file: "valid-proxy-page.json"},
+		{name: "proxy pagination summary", ref: "./schema.json#/$defs/ProxyPaginationSummary", file: "valid-proxy-pagination-summary.json"},
 		{name: "dry-run request", ref: "./schema.json#/$defs/DryRunRequest", file: "valid-dry-run-request.json"},
 		{name: "dry-run response", ref: "./schema.json#/$defs/DryRunResponse", file: "valid-dry-run-response.json"},
+		{name: "rate limit usage", ref: "./schema.json#/$defs/RateLimitUsage", file: "valid-rate-limit-usage.json"},
 		{name: "key", ref: "./schema.json#/$defs/Key", file: "valid-key.json"},
 		{name: "list keys", ref: "./schema.json#/$defs/ListKeysResponse", file: "valid-list-keys.json"},
 		{name: "create key", ref: "./schema.json#/$defs/CreateKeyRequest", file: "valid-create-key.json"},
@@ -168,3 +176,73 @@ func TestInitiateConnectionRequestValidate(t *testing.T) {
 		require.Error(t, req.Validate())
 	})
 }
+
+func TestBatchProxyRequestValidate(t *testing.T) {
+	selector := "tier=enterprise"
+	namespace := "root.acme"
+	request := ProxyRequestJson{URL: "/v1/me", Method: "GET"}
+
+	t.Run("valid with connection ids", func(t *testing.T) {
+		req := BatchProxyRequestJson{ConnectionIds: []apid.ID{"cxn_test0000000000001"}, Request: request}
+		require.NoError(t, req.Validate())
+		require.Equal(t, DefaultBatchProxyConcurrency, req.GetConcurrency())
+	})
+
+	t.Run("valid with label selector", func(t *testing.T) {
+		concurrency := 5
+		req := BatchProxyRequestJson{LabelSelector: &selector, Namespace: &namespace, Request: request, Concurrency: &concurrency}
+		require.NoError(t, req.Validate())
+		require.Equal(t, 5, req.GetConcurrency())
+	})
+
+	t.Run("requires a target", func(t *testing.T) {
+		req := BatchProxyRequestJson{Request: request}
+		require.ErrorContains(t, req.Validate(), "exactly one of connection_ids or label_selector is required")
+	})
+
+	t.Run("rejects both targets", func(t *testing.T) {
+		req := BatchProxyRequestJson{ConnectionIds: []apid.ID{"cxn_test0000000000001"}, LabelSelector: &selector, Request: request}
+		require.ErrorContains(t, req.Validate(), "exactly one of connection_ids or label_selector is required")
+	})
+
+	t.Run("rejects other id types", func(t *testing.T) {
+		req := BatchProxyRequestJson{ConnectionIds: []apid.ID{"cxr_test0000000000001"}, Request: request}
+		require.ErrorContains(t, req.Validate(), "connection_ids[0]")
+	})
+
+	t.Run("namespace requires label selector", func(t *testing.T) {
+		req := BatchProxyRequestJson{ConnectionIds: []apid.ID{"cxn_test0000000000001"}, Namespace: &namespace, Request: request}
+		require.ErrorContains(t, req.Validate(), "namespace can only be used with label_selector")
+	})
+
+	t.Run("bounds concurrency", func(t *testing.T) {
+		concurrency := MaxBatchProxyConcurrency + 1
+		req := BatchProxyRequestJson{LabelSelector: &selector, Request: request, Concurrency: &concurrency}
+		require.ErrorContains(t, req.Validate(), "concurrency must be between 1 and 50")
+	})
+}
+
+func TestProxyPaginateValidate(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		p := ProxyPaginateJson{}
+		require.NoError(t, p.Validate())
+		require.Equal(t, DefaultProxyPaginateMaxPages, p.GetMaxPages())
+		require.Equal(t, DefaultProxyPaginateMaxBytes, p.GetMaxBytes())
+		require.Equal(t, ProxyPaginateFormatMerge, p.GetFormat())
+	})
+
+	t.Run("bounds max pages", func(t *testing.T) {
+		p := ProxyPaginateJson{MaxPages: MaxProxyPaginateMaxPages + 1}
+		require.ErrorContains(t, p.Validate(), "paginate.max_pages")
+	})
+
+	t.Run("bounds max bytes", func(t *testing.T) {
+		p := ProxyPaginateJson{MaxBytes: -1}
+		require.ErrorContains(t, p.Validate(), "paginate.max_bytes")
+	})
+
+	t.Run("rejects unknown format", func(t *testing.T) {
+		p := ProxyPaginateJson{Format: "csv"}
+		require.ErrorContains(t, p.Validate(), "paginate.format")
+	})
+}
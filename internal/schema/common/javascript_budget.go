@@ -0,0 +1,80 @@
+package common
+
+import (
+	"github.com/hashicorp/go-multierror"
+	"github.com/rmorlok/authproxy/internal/apjs"
+)
+
+// JavascriptBudget overrides the limits connector JavaScript runs under. It can be set globally in the config and
+// per connector; unset fields inherit from the level above, and ultimately from apjs.DefaultBudget.
+type JavascriptBudget struct {
+	// Timeout is how long a single evaluation may run before it is interrupted.
+	Timeout *HumanDuration `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+
+	// MaxCallStackSize is the deepest the JavaScript call stack may grow.
+	MaxCallStackSize *int `json:"maxCallStackSize,omitempty" yaml:"maxCallStackSize,omitempty"`
+
+	// MaxOutputSize caps the JSON size of the value an expression returns.
+	MaxOutputSize *HumanByteSize `json:"maxOutputSize,omitempty" yaml:"maxOutputSize,omitempty"`
+}
+
+func (b *JavascriptBudget) Validate(vc *ValidationContext) error {
+	if b == nil {
+		return nil
+	}
+
+	result := &multierror.Error{}
+
+	if b.Timeout != nil && b.Timeout.Duration <= 0 {
+		result = multierror.Append(result, vc.NewErrorForField("timeout", "must be positive"))
+	}
+
+	if b.MaxCallStackSize != nil && *b.MaxCallStackSize < 1 {
+		result = multierror.Append(result, vc.NewErrorForField("max_call_stack_size", "must be at least 1"))
+	}
+
+	if b.MaxOutputSize != nil && b.MaxOutputSize.Value() == 0 {
+		result = multierror.Append(result, vc.NewErrorForField("max_output_size", "must be positive"))
+	}
+
+	return result.ErrorOrNil()
+}
+
+func (b *JavascriptBudget) Clone() *JavascriptBudget {
+	if b == nil {
+		return nil
+	}
+
+	clone := &JavascriptBudget{}
+	if b.Timeout != nil {
+		d := *b.Timeout
+		clone.Timeout = &d
+	}
+	if b.MaxCallStackSize != nil {
+		n := *b.MaxCallStackSize
+		clone.MaxCallStackSize = &n
+	}
+	if b.MaxOutputSize != nil {
+		s := *b.MaxOutputSize
+		clone.MaxOutputSize = &s
+	}
+	return clone
+}
+
+// Apply returns base with the fields this budget sets replaced.
+func (b *JavascriptBudget) Apply(base apjs.Budget) apjs.Budget {
+	if b == nil {
+		return base
+	}
+
+	if b.Timeout != nil {
+		base.Timeout = b.Timeout.Duration
+	}
+	if b.MaxCallStackSize != nil {
+		base.MaxCallStackSize = *b.MaxCallStackSize
+	}
+	if b.MaxOutputSize != nil {
+		base.MaxOutputSize = b.MaxOutputSize.Value()
+	}
+	return base
+}
@@ -0,0 +1,53 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rmorlok/authproxy/internal/apjs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestJavascriptBudget(t *testing.T) {
+	t.Run("nil leaves the base unchanged", func(t *testing.T) {
+		var b *JavascriptBudget
+		assert.Equal(t, apjs.DefaultBudget, b.Apply(apjs.DefaultBudget))
+		assert.NoError(t, b.Validate(&ValidationContext{}))
+		assert.Nil(t, b.Clone())
+	})
+
+	t.Run("set fields override the base", func(t *testing.T) {
+		var b JavascriptBudget
+		require.NoError(t, yaml.Unmarshal([]byte("timeout: 250ms\nmaxOutputSize: 64kib\n"), &b))
+		require.NoError(t, b.Validate(&ValidationContext{}))
+
+		got := b.Apply(apjs.DefaultBudget)
+		assert.Equal(t, 250*time.Millisecond, got.Timeout)
+		assert.Equal(t, apjs.DefaultBudget.MaxCallStackSize, got.MaxCallStackSize)
+		assert.Equal(t, 64*KiB, got.MaxOutputSize)
+	})
+
+	t.Run("validate", func(t *testing.T) {
+		zero := 0
+		b := &JavascriptBudget{
+			Timeout:          &HumanDuration{},
+			MaxCallStackSize: &zero,
+			MaxOutputSize:    &HumanByteSize{},
+		}
+		err := b.Validate(&ValidationContext{Path: "$.javascript_budget"})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "timeout")
+		assert.Contains(t, err.Error(), "max_call_stack_size")
+		assert.Contains(t, err.Error(), "max_output_size")
+	})
+
+	t.Run("clone is independent", func(t *testing.T) {
+		stack := 10
+		b := &JavascriptBudget{MaxCallStackSize: &stack}
+		clone := b.Clone()
+		*clone.MaxCallStackSize = 20
+		assert.Equal(t, 10, *b.MaxCallStackSize)
+	})
+}
@@ -0,0 +1,110 @@
+package common
+
+import (
+	"fmt"
+	"strings"
+)
+
+type LabelOperator string
+
+const (
+	LabelOperatorEqual     LabelOperator = "="
+	LabelOperatorNotEqual  LabelOperator = "!="
+	LabelOperatorExists    LabelOperator = "exists"
+	LabelOperatorNotExists LabelOperator = "!exists"
+)
+
+type LabelRequirement struct {
+	Key      string
+	Operator LabelOperator
+	Value    string
+}
+
+// ParseLabelSelector parses a Kubernetes-style label selector string.
+// Supported syntax:
+// - key=value, key==value
+// - key!=value
+// - key (exists)
+// - !key (does not exist)
+func ParseLabelSelector(selector string) ([]LabelRequirement, error) {
+	if selector == "" {
+		return nil, nil
+	}
+
+	var requirements []LabelRequirement
+	parts := strings.Split(selector, ",")
+
+	for _, part := range parts {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			return nil, fmt.Errorf("label selector contains an empty requirement")
+		}
+
+		if strings.Contains(part, "!=") {
+			opParts := strings.SplitN(part, "!=", 2)
+			key := strings.TrimSpace(opParts[0])
+			val := strings.TrimSpace(opParts[1])
+			if err := ValidateLabelKey(key); err != nil {
+				return nil, fmt.Errorf("invalid label key in selector %q: %w", part, err)
+			}
+			if err := ValidateLabelValueForKey(key, val); err != nil {
+				return nil, fmt.Errorf("invalid label value in selector %q: %w", part, err)
+			}
+			requirements = append(requirements, LabelRequirement{
+				Key:      key,
+				Operator: LabelOperatorNotEqual,
+				Value:    val,
+			})
+		} else if strings.Contains(part, "==") {
+			opParts := strings.SplitN(part, "==", 2)
+			key := strings.TrimSpace(opParts[0])
+			val := strings.TrimSpace(opParts[1])
+			if err := ValidateLabelKey(key); err != nil {
+				return nil, fmt.Errorf("invalid label key in selector %q: %w", part, err)
+			}
+			if err := ValidateLabelValueForKey(key, val); err != nil {
+				return nil, fmt.Errorf("invalid label value in selector %q: %w", part, err)
+			}
+			requirements = append(requirements, LabelRequirement{
+				Key:      key,
+				Operator: LabelOperatorEqual,
+				Value:    val,
+			})
+		} else if strings.Contains(part, "=") {
+			opParts := strings.SplitN(part, "=", 2)
+			key := strings.TrimSpace(opParts[0])
+			val := strings.TrimSpace(opParts[1])
+			if err := ValidateLabelKey(key); err != nil {
+				return nil, fmt.Errorf("invalid label key in selector %q: %w", part, err)
+			}
+			if err := ValidateLabelValueForKey(key, val); err != nil {
+				return nil, fmt.Errorf("invalid label value in selector %q: %w", part, err)
+			}
+			requirements = append(requirements, LabelRequirement{
+				Key:      key,
+				Operator: LabelOperatorEqual,
+				Value:    val,
+			})
+		} else if strings.HasPrefix(part, "!") {
+			key := strings.TrimSpace(part[1:])
+			if err := ValidateLabelKey(key); err != nil {
+				return nil, fmt.Errorf("invalid label key in selector %q: %w", part, err)
+			}
+			requirements = append(requirements, LabelRequirement{
+				Key:      key,
+				Operator: LabelOperatorNotExists,
+			})
+		} else {
+			key := strings.TrimSpace(part)
+			if err := ValidateLabelKey(key); err != nil {
+				return nil, fmt.Errorf("invalid label key in selector %q: %w", part, err)
+			}
+			requirements = append(requirements, LabelRequirement{
+				Key:      key,
+				Operator: LabelOperatorExists,
+			})
+		}
+	}
+
+	return requirements, nil
+}
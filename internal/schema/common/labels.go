@@ -0,0 +1,209 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Kubernetes-style label restrictions
+const (
+	// LabelKeyNameMaxLength is the maximum length for the name portion of a label key
+	LabelKeyNameMaxLength = 63
+
+	// LabelKeyPrefixMaxLength is the maximum length for the optional prefix portion of a label key
+	LabelKeyPrefixMaxLength = 253
+
+	// LabelValueMaxLength is the maximum length for a label value
+	LabelValueMaxLength = 63
+
+	// ApxyLabelValueMaxLength is the maximum length for a label value stored
+	// under an apxy/-prefixed key. System-managed labels such as
+	// apxy/<rt>/-/ns can hold a namespace path that may exceed the standard
+	// LabelValueMaxLength. User-supplied values are still capped at
+	// LabelValueMaxLength via ValidateLabelValue.
+	ApxyLabelValueMaxLength = 253
+
+	// ApxyReservedPrefix is the reserved label-key prefix for system-managed
+	// labels (implicit identifier labels and parent carry-forward labels).
+	// User-supplied label keys may not begin with this prefix.
+	ApxyReservedPrefix = "apxy/"
+)
+
+var (
+	// labelKeyNameRegex validates the name portion of a label key:
+	// - 1-63 characters
+	// - must start and end with alphanumeric
+	// - may contain alphanumeric, '-', '_', '.'
+	labelKeyNameRegex = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9._-]{0,61}[a-zA-Z0-9])?$|^[a-zA-Z0-9]$`)
+
+	// labelKeyPrefixRegex validates the prefix portion of a label key (DNS subdomain):
+	// - max 253 characters
+	// - one or more DNS labels separated by '.'
+	// - each label: starts/ends with alphanumeric, may contain alphanumeric and '-'
+	labelKeyPrefixRegex = regexp.MustCompile(`^([a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?\.)*[a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?$`)
+
+	// labelValueRegex validates a label value:
+	// - 0-63 characters (can be empty)
+	// - if non-empty: must start and end with alphanumeric, may contain alphanumeric, '-', '_', '.'
+	labelValueRegex = regexp.MustCompile(`^([a-zA-Z0-9]([a-zA-Z0-9._-]{0,61}[a-zA-Z0-9])?)?$|^[a-zA-Z0-9]?$`)
+
+	// apxyPathSegmentRegex validates a single segment inside an apxy/ path.
+	// A segment is either a DNS-label-like token (alphanumeric start/end, may
+	// contain '-' in the middle) or the literal "-" sentinel used to mark
+	// implicit identifier labels (e.g. apxy/cxr/-/id).
+	apxyPathSegmentRegex = regexp.MustCompile(`^([a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?|-)$`)
+
+	// apxyLabelValueRegex accepts the trusted system-value character set and
+	// allows leading/trailing underscores or hyphens used by the established
+	// namespace-segment grammar. User label values retain the stricter
+	// Kubernetes-style boundary rules.
+	apxyLabelValueRegex = regexp.MustCompile(`^[a-zA-Z0-9._-]{0,253}$`)
+)
+
+// ValidateLabelKey validates a single label key.
+//
+// Two grammars are accepted:
+//
+//  1. Standard Kubernetes-style key: [prefix/]name
+//     - prefix (optional): valid DNS subdomain, max 253 characters
+//     - name (required): 1-63 characters, must start/end with alphanumeric,
+//     may contain '-', '_', '.'
+//
+//  2. Reserved apxy/ multi-segment key: apxy/<seg>(/<seg>)*/<name>
+//     - each <seg> is a DNS-label-like token or the literal "-" sentinel
+//     - <name> follows the standard name rule above
+//     - total prefix portion (everything before the final '/') still capped
+//     at LabelKeyPrefixMaxLength characters
+//
+// This function accepts apxy/ keys; user-input call sites should use
+// database.ValidateUserLabelKey to additionally reject the reserved namespace.
+func ValidateLabelKey(key string) error {
+	if key == "" {
+		return errors.New("label key cannot be empty")
+	}
+
+	if strings.HasPrefix(key, ApxyReservedPrefix) {
+		return validateApxyLabelKey(key)
+	}
+
+	var prefix, name string
+	if idx := strings.LastIndex(key, "/"); idx != -1 {
+		prefix = key[:idx]
+		name = key[idx+1:]
+
+		// If there's a slash, the prefix must not be empty
+		if prefix == "" {
+			return errors.New("label key prefix cannot be empty when slash is present")
+		}
+	} else {
+		name = key
+	}
+
+	// Validate prefix if present
+	if prefix != "" {
+		if len(prefix) > LabelKeyPrefixMaxLength {
+			return fmt.Errorf("label key prefix exceeds maximum length of %d characters", LabelKeyPrefixMaxLength)
+		}
+		if !labelKeyPrefixRegex.MatchString(prefix) {
+			return fmt.Errorf("label key prefix %q is not a valid DNS subdomain", prefix)
+		}
+	}
+
+	// Validate name
+	if name == "" {
+		return errors.New("label key name cannot be empty")
+	}
+	if len(name) > LabelKeyNameMaxLength {
+		return fmt.Errorf("label key name exceeds maximum length of %d characters", LabelKeyNameMaxLength)
+	}
+	if !labelKeyNameRegex.MatchString(name) {
+		return fmt.Errorf("label key name %q must start and end with alphanumeric and contain only alphanumeric, '-', '_', or '.'", name)
+	}
+
+	return nil
+}
+
+// validateApxyLabelKey validates a key already known to start with apxy/.
+// The grammar is apxy/<seg>(/<seg>)*/<name>.
+func validateApxyLabelKey(key string) error {
+	idx := strings.LastIndex(key, "/")
+	// idx must exist because the key starts with "apxy/"; the final '/'
+	// separates the (possibly multi-segment) prefix from the name.
+	prefix := key[:idx]
+	name := key[idx+1:]
+
+	if len(prefix) > LabelKeyPrefixMaxLength {
+		return fmt.Errorf("label key prefix exceeds maximum length of %d characters", LabelKeyPrefixMaxLength)
+	}
+
+	// Trim the leading "apxy" segment (we know it's there) and require at
+	// least one further segment before the name — apxy/<rt>/...
+	innerPath := strings.TrimPrefix(prefix, "apxy")
+	if innerPath == "" {
+		return errors.New("apxy/ label key requires at least one segment after apxy/")
+	}
+	// innerPath now starts with '/'.
+	innerSegments := strings.Split(innerPath[1:], "/")
+	for _, seg := range innerSegments {
+		if seg == "" {
+			return errors.New("apxy/ label key has empty path segment")
+		}
+		if !apxyPathSegmentRegex.MatchString(seg) {
+			return fmt.Errorf("apxy/ label key segment %q must be a DNS label or the '-' sentinel", seg)
+		}
+	}
+
+	if name == "" {
+		return errors.New("label key name cannot be empty")
+	}
+	if len(name) > LabelKeyNameMaxLength {
+		return fmt.Errorf("label key name exceeds maximum length of %d characters", LabelKeyNameMaxLength)
+	}
+	if !labelKeyNameRegex.MatchString(name) {
+		return fmt.Errorf("label key name %q must start and end with alphanumeric and contain only alphanumeric, '-', '_', or '.'", name)
+	}
+
+	return nil
+}
+
+// ValidateLabelValue validates a single label value according to Kubernetes restrictions.
+// - 0-63 characters (can be empty)
+// - if non-empty: must start and end with alphanumeric, may contain alphanumeric, '-', '_', '.'
+func ValidateLabelValue(value string) error {
+	if len(value) > LabelValueMaxLength {
+		return fmt.Errorf("label value exceeds maximum length of %d characters", LabelValueMaxLength)
+	}
+
+	if value != "" && !labelValueRegex.MatchString(value) {
+		return fmt.Errorf("label value %q must start and end with alphanumeric and contain only alphanumeric, '-', '_', or '.'", value)
+	}
+
+	return nil
+}
+
+// ValidateApxyLabelValue validates a label value stored under an apxy/-prefixed
+// key. It allows up to ApxyLabelValueMaxLength characters so namespace paths
+// (e.g. root.foo.bar.baz...) can fit, including the leading underscores and
+// trailing hyphens accepted in namespace path segments.
+func ValidateApxyLabelValue(value string) error {
+	if len(value) > ApxyLabelValueMaxLength {
+		return fmt.Errorf("apxy label value exceeds maximum length of %d characters", ApxyLabelValueMaxLength)
+	}
+
+	if value != "" && !apxyLabelValueRegex.MatchString(value) {
+		return fmt.Errorf("apxy label value %q may contain only alphanumeric characters, '-', '_', or '.'", value)
+	}
+
+	return nil
+}
+
+// ValidateLabelValueForKey selects the appropriate value validator based on
+// whether the key is in the apxy/ namespace.
+func ValidateLabelValueForKey(key, value string) error {
+	if strings.HasPrefix(key, ApxyReservedPrefix) {
+		return ValidateApxyLabelValue(value)
+	}
+	return ValidateLabelValue(value)
+}
@@ -0,0 +1,89 @@
+package common
+
+import "fmt"
+
+// RequestPriority is the class a caller tags proxied traffic with so rate
+// limits can hold capacity back for the traffic that matters most. Classes
+// are ordered: interactive outranks normal, which outranks batch.
+type RequestPriority string
+
+const (
+	// RequestPriorityInteractive is latency-sensitive traffic driven by a
+	// user waiting on the result. Tagging a request interactive requires
+	// the connections:prioritize permission.
+	RequestPriorityInteractive RequestPriority = "interactive"
+
+	// RequestPriorityNormal is the priority of untagged traffic.
+	RequestPriorityNormal RequestPriority = "normal"
+
+	// RequestPriorityBatch is bulk traffic (syncs, backfills) that should
+	// only use capacity nothing else needs.
+	RequestPriorityBatch RequestPriority = "batch"
+)
+
+// DefaultRequestPriority is the priority of requests that aren't tagged.
+const DefaultRequestPriority = RequestPriorityNormal
+
+// AllRequestPriorities returns every recognised RequestPriority, highest
+// first.
+func AllRequestPriorities() []RequestPriority {
+	return []RequestPriority{
+		RequestPriorityInteractive,
+		RequestPriorityNormal,
+		RequestPriorityBatch,
+	}
+}
+
+// IsValidRequestPriority reports whether p is a recognised RequestPriority
+// value.
+func IsValidRequestPriority[T string | RequestPriority](p T) bool {
+	switch RequestPriority(p) {
+	case RequestPriorityInteractive,
+		RequestPriorityNormal,
+		RequestPriorityBatch:
+		return true
+	default:
+		return false
+	}
+}
+
+// Validate returns nil if p is a recognised value, or a descriptive error
+// otherwise.
+func (p RequestPriority) Validate() error {
+	if !IsValidRequestPriority(p) {
+		return fmt.Errorf("unknown request priority %q", string(p))
+	}
+	return nil
+}
+
+// Effective returns p, falling back to DefaultRequestPriority when unset.
+func (p RequestPriority) Effective() RequestPriority {
+	if p == "" {
+		return DefaultRequestPriority
+	}
+	return p
+}
+
+// Outranks reports whether p is a strictly higher class than other. Unset
+// values rank as DefaultRequestPriority.
+func (p RequestPriority) Outranks(other RequestPriority) bool {
+	return p.Rank() < other.Rank()
+}
+
+// Rank orders the classes from 0, lower being higher priority. Unset and
+// unrecognised values rank alongside the default.
+func (p RequestPriority) Rank() int {
+	switch p {
+	case RequestPriorityInteractive:
+		return 0
+	case RequestPriorityBatch:
+		return 2
+	default:
+		return 1
+	}
+}
+
+// String returns the string representation of the RequestPriority.
+func (p RequestPriority) String() string {
+	return string(p)
+}
@@ -0,0 +1,62 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestPriority_IsValid(t *testing.T) {
+	cases := []struct {
+		name string
+		p    RequestPriority
+		want bool
+	}{
+		{"interactive", RequestPriorityInteractive, true},
+		{"normal", RequestPriorityNormal, true},
+		{"batch", RequestPriorityBatch, true},
+		{"empty", "", false},
+		{"unknown", "urgent", false},
+		{"case-mismatch", "Batch", false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.want, IsValidRequestPriority(tc.p))
+			require.Equal(t, tc.want, IsValidRequestPriority(string(tc.p)))
+		})
+	}
+}
+
+func TestRequestPriority_Validate(t *testing.T) {
+	require.NoError(t, RequestPriorityBatch.Validate())
+
+	err := RequestPriority("urgent").Validate()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), `"urgent"`)
+}
+
+func TestRequestPriority_Effective(t *testing.T) {
+	require.Equal(t, RequestPriorityNormal, RequestPriority("").Effective())
+	require.Equal(t, RequestPriorityBatch, RequestPriorityBatch.Effective())
+}
+
+func TestRequestPriority_Outranks(t *testing.T) {
+	require.True(t, RequestPriorityInteractive.Outranks(RequestPriorityNormal))
+	require.True(t, RequestPriorityNormal.Outranks(RequestPriorityBatch))
+	require.True(t, RequestPriority("").Outranks(RequestPriorityBatch))
+	require.False(t, RequestPriorityNormal.Outranks(""))
+	require.False(t, RequestPriorityBatch.Outranks(RequestPriorityInteractive))
+	require.False(t, RequestPriorityInteractive.Outranks(RequestPriorityInteractive))
+}
+
+func TestAllRequestPriorities(t *testing.T) {
+	all := AllRequestPriorities()
+	require.Len(t, all, 3)
+	for i, p := range all {
+		require.True(t, IsValidRequestPriority(p), "expected %q to be valid", p)
+		if i > 0 {
+			require.True(t, all[i-1].Outranks(p), "expected %q to outrank %q", all[i-1], p)
+		}
+	}
+}
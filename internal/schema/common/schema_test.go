@@ -250,6 +250,29 @@ func TestSchema(t *testing.T) {
 				{Name: "probe", Valid: true, Data: `{"test": "probe"}`},
 			},
 		},
+		{
+			Name: "RequestPriority",
+			Schema: `
+{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "$id": "https://raw.githubusercontent.com/rmorlok/authproxy/refs/heads/main/schema/common/test.json",
+  "type": "object",
+  "additionalProperties": false,
+  "required": ["test"],
+  "properties": {
+	"test": {
+		"$ref": "./schema.json#/$defs/RequestPriority"
+    }
+  }
+}`,
+			Tests: []test{
+				{Name: "wrong type", Valid: false, Data: `{"test": 1}`},
+				{Name: "unknown value", Valid: false, Data: `{"test": "urgent"}`},
+				{Name: "interactive", Valid: true, Data: `{"test": "interactive"}`},
+				{Name: "normal", Valid: true, Data: `{"test": "normal"}`},
+				{Name: "batch", Valid: true, Data: `{"test": "batch"}`},
+			},
+		},
 		{
 			Name: "HumanDuration",
 			Schema: `
@@ -0,0 +1,162 @@
+package config
+
+import (
+	"net/netip"
+	"slices"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/rmorlok/authproxy/internal/schema/common"
+	"github.com/rmorlok/authproxy/internal/schema/resources/namespace"
+)
+
+// DefaultEgressDeniedCidrs are the address ranges outbound calls may not
+// reach when egress.deniedCidrs is not configured: unspecified, loopback,
+// link-local (including cloud metadata endpoints such as 169.254.169.254),
+// RFC1918 private ranges, the RFC 6598 carrier-grade NAT range that some
+// clouds and Kubernetes networks use internally, and their IPv6 equivalents.
+var DefaultEgressDeniedCidrs = []string{
+	"0.0.0.0/8",
+	"127.0.0.0/8",
+	"10.0.0.0/8",
+	"172.16.0.0/12",
+	"192.168.0.0/16",
+	"100.64.0.0/10",
+	"169.254.0.0/16",
+	"::/128",
+	"::1/128",
+	"fe80::/10",
+	"fc00::/7",
+}
+
+// DefaultEgressMaxRedirects is the number of redirects an outbound call
+// follows when egress.maxRedirects is not configured. Matches net/http.
+const DefaultEgressMaxRedirects = 10
+
+// Egress restricts where outbound calls to 3rd parties (proxied requests,
+// probes, data sources, OAuth token exchange, ...) may connect. Because those
+// URLs can be templated from values a tenant enters during setup, without a
+// policy a tenant could point AuthProxy at cloud metadata endpoints or
+// internal services.
+//
+// The policy is enforced on the address actually dialed, after DNS
+// resolution, so a hostname that resolves to a public address when checked
+// and a private one when dialed (DNS rebinding) is still blocked. When the
+// block is absent the defaults apply; set deniedCidrs to an empty list to
+// enforce no policy.
+type Egress struct {
+	// DeniedCidrs are the address ranges outbound calls may not connect to.
+	// Defaults to DefaultEgressDeniedCidrs. An explicitly empty list denies
+	// nothing.
+	DeniedCidrs []string `json:"deniedCidrs,omitempty" yaml:"deniedCidrs,omitempty"`
+
+	// AllowedCidrs are exceptions to DeniedCidrs, e.g. the private address
+	// of a self-hosted instance that connectors are expected to reach.
+	AllowedCidrs []string `json:"allowedCidrs,omitempty" yaml:"allowedCidrs,omitempty"`
+
+	// MaxRedirects is the number of redirects followed before the call
+	// fails. Every hop is checked against the policy. Defaults to 10.
+	MaxRedirects *int `json:"maxRedirects,omitempty" yaml:"maxRedirects,omitempty"`
+
+	// Namespaces override the policy for calls made on behalf of connections
+	// in a namespace and its descendants, keyed by namespace path. When
+	// several keys match, the most specific override wins field by field;
+	// fields an override omits are inherited.
+	Namespaces map[string]*EgressOverride `json:"namespaces,omitempty" yaml:"namespaces,omitempty"`
+}
+
+// EgressOverride replaces parts of the global egress policy for a namespace.
+type EgressOverride struct {
+	DeniedCidrs  []string `json:"deniedCidrs,omitempty" yaml:"deniedCidrs,omitempty"`
+	AllowedCidrs []string `json:"allowedCidrs,omitempty" yaml:"allowedCidrs,omitempty"`
+	MaxRedirects *int     `json:"maxRedirects,omitempty" yaml:"maxRedirects,omitempty"`
+}
+
+// EgressRules is the effective egress policy for one namespace.
+type EgressRules struct {
+	DeniedCidrs  []string
+	AllowedCidrs []string
+	MaxRedirects int
+}
+
+// ForNamespace resolves the effective rules for calls made in ns by applying
+// the namespace overrides from root down to ns over the global policy.
+func (e *Egress) ForNamespace(ns string) EgressRules {
+	rules := EgressRules{
+		DeniedCidrs:  DefaultEgressDeniedCidrs,
+		AllowedCidrs: e.AllowedCidrs,
+		MaxRedirects: DefaultEgressMaxRedirects,
+	}
+	if e.DeniedCidrs != nil {
+		rules.DeniedCidrs = e.DeniedCidrs
+	}
+	if e.MaxRedirects != nil {
+		rules.MaxRedirects = *e.MaxRedirects
+	}
+
+	for _, prefix := range namespace.SplitPathToPrefixes(ns) {
+		o := e.Namespaces[prefix]
+		if o == nil {
+			continue
+		}
+		if o.DeniedCidrs != nil {
+			rules.DeniedCidrs = o.DeniedCidrs
+		}
+		if o.AllowedCidrs != nil {
+			rules.AllowedCidrs = o.AllowedCidrs
+		}
+		if o.MaxRedirects != nil {
+			rules.MaxRedirects = *o.MaxRedirects
+		}
+	}
+
+	rules.DeniedCidrs = slices.Clone(rules.DeniedCidrs)
+	rules.AllowedCidrs = slices.Clone(rules.AllowedCidrs)
+	return rules
+}
+
+func (e *Egress) Validate(vc *common.ValidationContext) error {
+	if e == nil {
+		return nil
+	}
+
+	result := &multierror.Error{}
+
+	if err := validateEgressRules(vc, e.DeniedCidrs, e.AllowedCidrs, e.MaxRedirects); err != nil {
+		result = multierror.Append(result, err)
+	}
+
+	for ns, o := range e.Namespaces {
+		nvc := vc.PushField("namespaces").PushField(ns)
+		if err := namespace.ValidatePath(ns); err != nil {
+			result = multierror.Append(result, nvc.NewErrorf("invalid namespace: %s", err.Error()))
+		}
+		if o == nil {
+			continue
+		}
+		if err := validateEgressRules(nvc, o.DeniedCidrs, o.AllowedCidrs, o.MaxRedirects); err != nil {
+			result = multierror.Append(result, err)
+		}
+	}
+
+	return result.ErrorOrNil()
+}
+
+func validateEgressRules(vc *common.ValidationContext, denied, allowed []string, maxRedirects *int) error {
+	result := &multierror.Error{}
+
+	for i, c := range denied {
+		if _, err := netip.ParsePrefix(c); err != nil {
+			result = multierror.Append(result, vc.PushField("denied_cidrs").PushIndex(i).NewErrorf("invalid CIDR: %s", err.Error()))
+		}
+	}
+	for i, c := range allowed {
+		if _, err := netip.ParsePrefix(c); err != nil {
+			result = multierror.Append(result, vc.PushField("allowed_cidrs").PushIndex(i).NewErrorf("invalid CIDR: %s", err.Error()))
+		}
+	}
+	if maxRedirects != nil && *maxRedirects < 0 {
+		result = multierror.Append(result, vc.NewErrorfForField("max_redirects", "must not be negative"))
+	}
+
+	return result.ErrorOrNil()
+}
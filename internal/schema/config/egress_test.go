@@ -0,0 +1,83 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/rmorlok/authproxy/internal/schema/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestEgress_ForNamespace(t *testing.T) {
+	var e Egress
+	require.NoError(t, yaml.Unmarshal([]byte(`
+allowedCidrs:
+  - 10.20.0.0/16
+maxRedirects: 5
+namespaces:
+  root.acme:
+    deniedCidrs: []
+  root.acme.eu:
+    maxRedirects: 0
+  root.partners:
+    allowedCidrs:
+      - 192.168.50.10/32
+`), &e))
+
+	t.Run("global defaults", func(t *testing.T) {
+		rules := e.ForNamespace("root")
+		assert.Equal(t, DefaultEgressDeniedCidrs, rules.DeniedCidrs)
+		assert.Equal(t, []string{"10.20.0.0/16"}, rules.AllowedCidrs)
+		assert.Equal(t, 5, rules.MaxRedirects)
+	})
+
+	t.Run("override applies to descendants and inherits omitted fields", func(t *testing.T) {
+		rules := e.ForNamespace("root.acme.eu.team")
+		assert.Equal(t, []string{}, rules.DeniedCidrs)
+		assert.Equal(t, []string{"10.20.0.0/16"}, rules.AllowedCidrs)
+		assert.Equal(t, 0, rules.MaxRedirects)
+	})
+
+	t.Run("sibling namespaces are unaffected", func(t *testing.T) {
+		rules := e.ForNamespace("root.acmecorp")
+		assert.Equal(t, DefaultEgressDeniedCidrs, rules.DeniedCidrs)
+		assert.Equal(t, 5, rules.MaxRedirects)
+
+		rules = e.ForNamespace("root.partners")
+		assert.Equal(t, []string{"192.168.50.10/32"}, rules.AllowedCidrs)
+	})
+
+	t.Run("empty block uses defaults", func(t *testing.T) {
+		rules := (&Egress{}).ForNamespace("root")
+		assert.Equal(t, DefaultEgressDeniedCidrs, rules.DeniedCidrs)
+		assert.Equal(t, DefaultEgressMaxRedirects, rules.MaxRedirects)
+	})
+}
+
+func TestEgress_Validate(t *testing.T) {
+	negative := -1
+
+	require.NoError(t, (*Egress)(nil).Validate(&common.ValidationContext{}))
+	require.NoError(t, (&Egress{DeniedCidrs: DefaultEgressDeniedCidrs}).Validate(&common.ValidationContext{}))
+
+	err := (&Egress{
+		DeniedCidrs:  []string{"10.0.0.0"},
+		AllowedCidrs: []string{"not-a-cidr"},
+		MaxRedirects: &negative,
+		Namespaces: map[string]*EgressOverride{
+			"acme":      {},
+			"root.acme": {AllowedCidrs: []string{"300.0.0.0/8"}},
+		},
+	}).Validate(&common.ValidationContext{Path: "$.egress"})
+	require.Error(t, err)
+	for _, sub := range []string{
+		"denied_cidrs[0]",
+		"allowed_cidrs[0]",
+		"max_redirects",
+		"namespaces.acme",
+		"namespaces.root.acme.allowed_cidrs[0]",
+	} {
+		assert.Contains(t, err.Error(), sub)
+	}
+}
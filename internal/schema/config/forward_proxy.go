@@ -0,0 +1,63 @@
+package config
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/rmorlok/authproxy/internal/schema/common"
+)
+
+// ForwardProxy runs an HTTP forward proxy listener alongside the API service,
+// for workloads that can only be pointed at AuthProxy with HTTP_PROXY /
+// HTTPS_PROXY. Requests are sent through the connection's raw proxy route, so
+// auth, rate limits and request events work the same as calling the API.
+//
+// The caller authenticates with Proxy-Authorization, either Basic with the
+// connection ID as the username and an API bearer token as the password, or
+// Bearer with the connection ID in X-AuthProxy-Connection.
+type ForwardProxy struct {
+	// PortVal is the port the forward proxy listens on. It must differ from
+	// the API service's own ports.
+	PortVal *IntegerValue `json:"port" yaml:"port"`
+
+	// CaCertificate and CaPrivateKey are the PEM-encoded CA used to mint
+	// certificates for intercepted HTTPS (CONNECT) traffic, so credentials
+	// can be injected into requests the caller encrypts. Callers must trust
+	// this CA. Without it, CONNECT requests are refused and only plain-http
+	// upstreams can be proxied.
+	CaCertificate *KeyData `json:"caCertificate,omitempty" yaml:"caCertificate,omitempty"`
+	CaPrivateKey  *KeyData `json:"caPrivateKey,omitempty" yaml:"caPrivateKey,omitempty"`
+}
+
+func (f *ForwardProxy) Port() uint64 {
+	port, err := f.PortVal.GetUint64Value(context.Background())
+	if err != nil {
+		panic(fmt.Sprintf("failed to obtain port from forward proxy config: %v", err))
+	}
+
+	return port
+}
+
+// InterceptsTls reports whether a CA is configured for HTTPS interception.
+func (f *ForwardProxy) InterceptsTls() bool {
+	return f != nil && f.CaCertificate != nil && f.CaPrivateKey != nil
+}
+
+func (f *ForwardProxy) Validate(vc *common.ValidationContext) error {
+	if f == nil {
+		return nil
+	}
+
+	result := &multierror.Error{}
+
+	if f.PortVal == nil {
+		result = multierror.Append(result, vc.NewErrorForField("port", "port must be specified"))
+	}
+
+	if (f.CaCertificate == nil) != (f.CaPrivateKey == nil) {
+		result = multierror.Append(result, vc.NewErrorForField("ca_certificate", "ca_certificate and ca_private_key must be specified together"))
+	}
+
+	return result.ErrorOrNil()
+}
@@ -0,0 +1,54 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/rmorlok/authproxy/internal/schema/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestServiceApi_ForwardProxy(t *testing.T) {
+	t.Run("parses", func(t *testing.T) {
+		var s ServiceApi
+		require.NoError(t, yaml.Unmarshal([]byte(`
+port: 8081
+forwardProxy:
+  port: 8888
+  caCertificate:
+    value: cert
+  caPrivateKey:
+    value: key
+`), &s))
+		require.NotNil(t, s.ForwardProxy)
+		assert.Equal(t, uint64(8081), s.Port())
+		assert.Equal(t, uint64(8888), s.ForwardProxy.Port())
+		assert.True(t, s.ForwardProxy.InterceptsTls())
+		require.NoError(t, s.ForwardProxy.Validate(&common.ValidationContext{Path: "$.api.forward_proxy"}))
+	})
+
+	t.Run("rejects unknown fields", func(t *testing.T) {
+		var s ServiceApi
+		require.Error(t, yaml.Unmarshal([]byte(`
+port: 8081
+forwardProxy:
+  port: 8888
+  listen: 0.0.0.0
+`), &s))
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		f := ForwardProxy{CaCertificate: &KeyData{}}
+		err := f.Validate(&common.ValidationContext{Path: "$.api.forward_proxy"})
+		require.ErrorContains(t, err, "$.api.forward_proxy.port")
+		require.ErrorContains(t, err, "$.api.forward_proxy.ca_certificate")
+		assert.False(t, f.InterceptsTls())
+	})
+
+	t.Run("nil", func(t *testing.T) {
+		var f *ForwardProxy
+		require.NoError(t, f.Validate(&common.ValidationContext{}))
+		assert.False(t, f.InterceptsTls())
+	})
+}
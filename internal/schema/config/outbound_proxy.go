@@ -0,0 +1,61 @@
+package config
+
+import (
+	"github.com/hashicorp/go-multierror"
+	"github.com/rmorlok/authproxy/internal/schema/common"
+)
+
+// OutboundProxy routes outbound calls to 3rd parties through a forward proxy,
+// for deployments where the upstream allowlists the IPs traffic leaves from.
+//
+// The proxy for a call is the first override whose label selector matches the
+// request labels, then the connector's transport.proxy, then Default. Request
+// labels include the connection's namespace labels as apxy/ns/<key>, so a
+// tenant namespace can be pinned to its own proxy regardless of connector.
+type OutboundProxy struct {
+	// Default is the proxy used when neither an override nor the connector
+	// selects one. Omit to connect directly by default.
+	Default *TransportProxy `json:"default,omitempty" yaml:"default,omitempty"`
+
+	// Overrides are evaluated in order and the first match wins.
+	Overrides []OutboundProxyOverride `json:"overrides,omitempty" yaml:"overrides,omitempty"`
+}
+
+// OutboundProxyOverride selects a proxy for requests whose labels match.
+type OutboundProxyOverride struct {
+	// LabelSelector is a Kubernetes-style selector evaluated against the
+	// request labels, e.g. "apxy/ns/tenant=acme".
+	LabelSelector string `json:"labelSelector" yaml:"labelSelector"`
+
+	// Proxy is used for matching requests. Set direct to exempt them from the
+	// default proxy.
+	Proxy *TransportProxy `json:"proxy" yaml:"proxy"`
+}
+
+func (o *OutboundProxy) Validate(vc *common.ValidationContext) error {
+	if o == nil {
+		return nil
+	}
+
+	result := &multierror.Error{}
+
+	if err := o.Default.Validate(vc.PushField("default")); err != nil {
+		result = multierror.Append(result, err)
+	}
+
+	for i, override := range o.Overrides {
+		ovc := vc.PushField("overrides").PushIndex(i)
+		if override.LabelSelector == "" {
+			result = multierror.Append(result, ovc.NewErrorForField("label_selector", "label_selector must be specified"))
+		} else if _, err := common.ParseLabelSelector(override.LabelSelector); err != nil {
+			result = multierror.Append(result, ovc.NewErrorfForField("label_selector", "invalid label_selector: %s", err.Error()))
+		}
+		if override.Proxy == nil {
+			result = multierror.Append(result, ovc.NewErrorForField("proxy", "proxy must be specified"))
+		} else if err := override.Proxy.Validate(ovc.PushField("proxy")); err != nil {
+			result = multierror.Append(result, err)
+		}
+	}
+
+	return result.ErrorOrNil()
+}
@@ -0,0 +1,52 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/rmorlok/authproxy/internal/schema/common"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestOutboundProxy_Validate(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		var o OutboundProxy
+		require.NoError(t, yaml.Unmarshal([]byte(`
+default:
+  url: http://egress-proxy.internal:3128
+  username:
+    value: authproxy
+  password:
+    value: secret
+overrides:
+  - labelSelector: apxy/ns/tenant=acme
+    proxy:
+      url: socks5h://acme-egress.internal:1080
+  - labelSelector: apxy/ns/egress=direct
+    proxy:
+      direct: true
+`), &o))
+		require.NoError(t, o.Validate(&common.ValidationContext{Path: "$.outbound_proxy"}))
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		o := OutboundProxy{
+			Default: &TransportProxy{Url: "ftp://egress-proxy.internal"},
+			Overrides: []OutboundProxyOverride{
+				{Proxy: &TransportProxy{Direct: true}},
+				{LabelSelector: "apxy/ns/tenant=acme"},
+				{LabelSelector: "tenant=acme,,", Proxy: &TransportProxy{Direct: true}},
+			},
+		}
+		err := o.Validate(&common.ValidationContext{Path: "$.outbound_proxy"})
+		require.ErrorContains(t, err, "$.outbound_proxy.default.url")
+		require.ErrorContains(t, err, "$.outbound_proxy.overrides[0].label_selector")
+		require.ErrorContains(t, err, "$.outbound_proxy.overrides[1].proxy")
+		require.ErrorContains(t, err, "$.outbound_proxy.overrides[2].label_selector: invalid label_selector")
+	})
+
+	t.Run("nil", func(t *testing.T) {
+		var o *OutboundProxy
+		require.NoError(t, o.Validate(&common.ValidationContext{}))
+	})
+}
@@ -11,6 +11,7 @@ import (
 type (
 	HumanDuration           = common.HumanDuration
 	HumanByteSize           = common.HumanByteSize
+	JavascriptBudget        = common.JavascriptBudget
 	Image                   = common.Image
 	ImageBase64             = common.ImageBase64
 	ImagePublicUrl          = common.ImagePublicUrl
@@ -49,23 +50,33 @@ var (
 
 // Re-export types from the connectors sub-package
 type (
-	Auth                    = connectors.Auth
-	AuthType                = connectors.AuthType
-	AuthApiKey              = connectors.AuthApiKey
-	ApiKeyPlacement         = connectors.ApiKeyPlacement
-	AuthOAuth2              = connectors.AuthOAuth2
-	AuthNoAuth              = connectors.AuthNoAuth
-	AuthOauth2Authorization = connectors.AuthOauth2Authorization
-	AuthOauth2PKCE          = connectors.AuthOauth2PKCE
-	AuthOauth2Token         = connectors.AuthOauth2Token
-	Connector               = connectors.Connector
-	Connectors              = connectors.Connectors
-	PKCEMethod              = connectors.PKCEMethod
-	OAuth2GrantType         = connectors.OAuth2GrantType
-	Predicate               = common.Predicate
-	Scope                   = connectors.Scope
-	ScopeRequired           = connectors.ScopeRequired
-	TokenEndpointAuthMethod = connectors.TokenEndpointAuthMethod
+	Auth                          = connectors.Auth
+	AuthType                      = connectors.AuthType
+	AuthApiKey                    = connectors.AuthApiKey
+	ApiKeyPlacement               = connectors.ApiKeyPlacement
+	AuthOAuth1                    = connectors.AuthOAuth1
+	AuthOAuth2                    = connectors.AuthOAuth2
+	AuthNoAuth                    = connectors.AuthNoAuth
+	AuthRequestSigning            = connectors.AuthRequestSigning
+	AuthOAuth2Assertion           = connectors.AuthOAuth2Assertion
+	AuthOauth2Authorization       = connectors.AuthOauth2Authorization
+	AuthOauth2DeviceAuthorization = connectors.AuthOauth2DeviceAuthorization
+	AuthOauth2Oidc                = connectors.AuthOauth2Oidc
+	AuthOauth2PKCE                = connectors.AuthOauth2PKCE
+	AuthOauth2Token               = connectors.AuthOauth2Token
+	Connector                     = connectors.Connector
+	Connectors                    = connectors.Connectors
+	PKCEMethod                    = connectors.PKCEMethod
+	OAuth2GrantType               = connectors.OAuth2GrantType
+	OidcDuplicateSubjectPolicy    = connectors.OidcDuplicateSubjectPolicy
+	Predicate                     = common.Predicate
+	Scope                         = connectors.Scope
+	ScopeRequired                 = connectors.ScopeRequired
+	TokenEndpointAuthMethod       = connectors.TokenEndpointAuthMethod
+	Transport                     = connectors.Transport
+	TransportClientCertificate    = connectors.TransportClientCertificate
+	TransportProxy                = connectors.TransportProxy
+	TransportTLS                  = connectors.TransportTLS
 )
 
 var (
@@ -75,9 +86,12 @@ var (
 
 // Re-export constants from the connectors sub-package
 const (
+	AuthTypeOAuth1 = connectors.AuthTypeOAuth1
 	AuthTypeOAuth2 = connectors.AuthTypeOAuth2
 	AuthTypeAPIKey = connectors.AuthTypeAPIKey
 
+	AuthTypeRequestSigning = connectors.AuthTypeRequestSigning
+
 	ApiKeyPlacementBearer = connectors.ApiKeyPlacementBearer
 	ApiKeyPlacementHeader = connectors.ApiKeyPlacementHeader
 	ApiKeyPlacementQuery  = connectors.ApiKeyPlacementQuery
@@ -88,6 +102,12 @@ const (
 
 	OAuth2GrantAuthorizationCode = connectors.OAuth2GrantAuthorizationCode
 	OAuth2GrantClientCredentials = connectors.OAuth2GrantClientCredentials
+	OAuth2GrantJwtBearer         = connectors.OAuth2GrantJwtBearer
+	OAuth2GrantDeviceCode        = connectors.OAuth2GrantDeviceCode
+
+	OidcDuplicateSubjectAllow  = connectors.OidcDuplicateSubjectAllow
+	OidcDuplicateSubjectReject = connectors.OidcDuplicateSubjectReject
+	OidcDuplicateSubjectMerge  = connectors.OidcDuplicateSubjectMerge
 
 	TokenEndpointAuthClientSecretPost  = connectors.TokenEndpointAuthClientSecretPost
 	TokenEndpointAuthClientSecretBasic = connectors.TokenEndpointAuthClientSecretBasic
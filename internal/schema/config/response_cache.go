@@ -0,0 +1,60 @@
+package config
+
+import (
+	"github.com/hashicorp/go-multierror"
+	"github.com/rmorlok/authproxy/internal/schema/common"
+)
+
+const (
+	DefaultResponseCacheMaxEntrySize            = 1 * common.MiB
+	DefaultResponseCacheMaxEntriesPerConnection = 1000
+)
+
+// ResponseCache bounds the Redis storage used by the proxy response cache.
+// Connectors opt in to caching individually with their responseCache block;
+// these limits apply across all of them.
+type ResponseCache struct {
+	// Disabled turns the response cache off for every connector.
+	Disabled bool `json:"disabled,omitempty" yaml:"disabled,omitempty"`
+
+	// MaxEntrySize caps the size of a cached response body, whatever the
+	// connector asks for. Defaults to 1MiB.
+	MaxEntrySize *HumanByteSize `json:"maxEntrySize,omitempty" yaml:"maxEntrySize,omitempty"`
+
+	// MaxEntriesPerConnection is the number of responses cached for a
+	// connection before the entries closest to expiring are evicted.
+	// Defaults to 1000.
+	MaxEntriesPerConnection *int `json:"maxEntriesPerConnection,omitempty" yaml:"maxEntriesPerConnection,omitempty"`
+}
+
+func (r *ResponseCache) Validate(vc *common.ValidationContext) error {
+	if r == nil {
+		return nil
+	}
+
+	result := &multierror.Error{}
+
+	if r.MaxEntriesPerConnection != nil && *r.MaxEntriesPerConnection < 1 {
+		result = multierror.Append(result, vc.PushField("max_entries_per_connection").NewError("must be at least 1"))
+	}
+
+	return result.ErrorOrNil()
+}
+
+func (r *ResponseCache) IsEnabled() bool {
+	return r == nil || !r.Disabled
+}
+
+func (r *ResponseCache) GetMaxEntrySize() uint64 {
+	if r == nil || r.MaxEntrySize == nil {
+		return DefaultResponseCacheMaxEntrySize
+	}
+	return r.MaxEntrySize.Value()
+}
+
+func (r *ResponseCache) GetMaxEntriesPerConnection() int {
+	if r == nil || r.MaxEntriesPerConnection == nil {
+		return DefaultResponseCacheMaxEntriesPerConnection
+	}
+	return *r.MaxEntriesPerConnection
+}
@@ -9,24 +9,28 @@ import (
 )
 
 type Root struct {
-	AdminApi        ServiceAdminApi `json:"adminApi" yaml:"adminApi"`
-	Api             ServiceApi      `json:"api" yaml:"api"`
-	Public          ServicePublic   `json:"public" yaml:"public"`
-	Worker          ServiceWorker   `json:"worker" yaml:"worker"`
-	Marketplace     *Marketplace    `json:"marketplace,omitempty" yaml:"marketplace,omitempty"`
-	HostApplication HostApplication `json:"hostApplication" yaml:"hostApplication"`
-	SystemAuth      SystemAuth      `json:"systemAuth" yaml:"systemAuth"`
-	Database        *Database       `json:"database" yaml:"database"`
-	Logging         *LoggingConfig  `json:"logging,omitempty" yaml:"logging,omitempty"`
-	Redis           *Redis          `json:"redis" yaml:"redis"`
-	Oauth           OAuth           `json:"oauth" yaml:"oauth"`
-	ErrorPages      ErrorPages      `json:"errorPages,omitempty" yaml:"errorPages,omitempty"`
-	Connectors      *Connectors     `json:"connectors" yaml:"connectors"`
-	AppMetrics      *AppMetrics     `json:"appMetrics,omitempty" yaml:"appMetrics,omitempty"`
-	Connections     *Connections    `json:"connections,omitempty" yaml:"connections,omitempty"`
-	Tasks           *Tasks          `json:"tasks,omitempty" yaml:"tasks,omitempty"`
-	Telemetry       *Telemetry      `json:"telemetry,omitempty" yaml:"telemetry,omitempty"`
-	DevSettings     *DevSettings    `json:"devSettings,omitempty" yaml:"devSettings,omitempty"`
+	AdminApi         ServiceAdminApi   `json:"adminApi" yaml:"adminApi"`
+	Api              ServiceApi        `json:"api" yaml:"api"`
+	Public           ServicePublic     `json:"public" yaml:"public"`
+	Worker           ServiceWorker     `json:"worker" yaml:"worker"`
+	Marketplace      *Marketplace      `json:"marketplace,omitempty" yaml:"marketplace,omitempty"`
+	HostApplication  HostApplication   `json:"hostApplication" yaml:"hostApplication"`
+	SystemAuth       SystemAuth        `json:"systemAuth" yaml:"systemAuth"`
+	Database         *Database         `json:"database" yaml:"database"`
+	Logging          *LoggingConfig    `json:"logging,omitempty" yaml:"logging,omitempty"`
+	Redis            *Redis            `json:"redis" yaml:"redis"`
+	Oauth            OAuth             `json:"oauth" yaml:"oauth"`
+	ErrorPages       ErrorPages        `json:"errorPages,omitempty" yaml:"errorPages,omitempty"`
+	Connectors       *Connectors       `json:"connectors" yaml:"connectors"`
+	AppMetrics       *AppMetrics       `json:"appMetrics,omitempty" yaml:"appMetrics,omitempty"`
+	Connections      *Connections      `json:"connections,omitempty" yaml:"connections,omitempty"`
+	Tasks            *Tasks            `json:"tasks,omitempty" yaml:"tasks,omitempty"`
+	Telemetry        *Telemetry        `json:"telemetry,omitempty" yaml:"telemetry,omitempty"`
+	Egress           *Egress           `json:"egress,omitempty" yaml:"egress,omitempty"`
+	OutboundProxy    *OutboundProxy    `json:"outboundProxy,omitempty" yaml:"outboundProxy,omitempty"`
+	ResponseCache    *ResponseCache    `json:"responseCache,omitempty" yaml:"responseCache,omitempty"`
+	JavascriptBudget *JavascriptBudget `json:"javascriptBudget,omitempty" yaml:"javascriptBudget,omitempty"`
+	DevSettings      *DevSettings      `json:"devSettings,omitempty" yaml:"devSettings,omitempty"`
 }
 
 func (r *Root) GetRootLogger() *slog.Logger {
@@ -55,6 +59,26 @@ func (r *Root) Validate() error {
 		result = multierror.Append(result, err)
 	}
 
+	if err := r.Egress.Validate(vc.PushField("egress")); err != nil {
+		result = multierror.Append(result, err)
+	}
+
+	if err := r.OutboundProxy.Validate(vc.PushField("outbound_proxy")); err != nil {
+		result = multierror.Append(result, err)
+	}
+
+	if err := r.ResponseCache.Validate(vc.PushField("response_cache")); err != nil {
+		result = multierror.Append(result, err)
+	}
+
+	if err := r.JavascriptBudget.Validate(vc.PushField("javascript_budget")); err != nil {
+		result = multierror.Append(result, err)
+	}
+
+	if err := r.Api.ForwardProxy.Validate(vc.PushField("api").PushField("forward_proxy")); err != nil {
+		result = multierror.Append(result, err)
+	}
+
 	if r.Database == nil {
 		result = multierror.Append(result, vc.NewError("database block is required"))
 	} else if err := r.Database.Validate(vc.PushField("database")); err != nil {
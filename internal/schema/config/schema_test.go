@@ -41,6 +41,7 @@ func Test_SchemaAgainstRealData(t *testing.T) {
 	_ = loadSchema(t, c, "../resources/connectors/schema-oauth.json")
 	_ = loadSchema(t, c, "../resources/connectors/schema.json")
 	_ = loadSchema(t, c, "../resources/key/schema.json")
+	_ = loadSchema(t, c, "../resources/rate_limit/schema.json")
 	schemaId := loadSchema(t, c, "./schema.json")
 
 	require.Equal(t, SchemaIdConfig, schemaId, "schema ID should be the same as the one in the schema")
@@ -104,6 +105,7 @@ func Test_SchemaAppMetricsShape(t *testing.T) {
 	_ = loadSchema(t, c, "../resources/connectors/schema-oauth.json")
 	_ = loadSchema(t, c, "../resources/connectors/schema.json")
 	_ = loadSchema(t, c, "../resources/key/schema.json")
+	_ = loadSchema(t, c, "../resources/rate_limit/schema.json")
 	schemaId := loadSchema(t, c, "./schema.json")
 
 	schema, err := c.Compile(schemaId)
@@ -169,6 +171,7 @@ func compileTestSchema(t *testing.T, schemaJSON string) *jsonschemav5.Schema {
 	_ = loadSchema(t, c, "../resources/connectors/schema-oauth.json")
 	_ = loadSchema(t, c, "../resources/connectors/schema.json")
 	_ = loadSchema(t, c, "../resources/key/schema.json")
+	_ = loadSchema(t, c, "../resources/rate_limit/schema.json")
 
 	sid := loadSchema(t, c, "./schema.json")
 	require.Equal(t, SchemaIdConfig, sid)
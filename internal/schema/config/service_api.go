@@ -3,11 +3,16 @@ package config
 import (
 	"fmt"
 
+	"github.com/rmorlok/authproxy/internal/util"
 	"gopkg.in/yaml.v3"
 )
 
 type ServiceApi struct {
 	ServiceHttp
+
+	// ForwardProxy optionally runs an HTTP forward proxy listener that sends
+	// requests through a connection. See ForwardProxy.
+	ForwardProxy *ForwardProxy `json:"forwardProxy,omitempty" yaml:"forwardProxy,omitempty"`
 }
 
 func (s *ServiceApi) UnmarshalYAML(value *yaml.Node) error {
@@ -15,7 +20,10 @@ func (s *ServiceApi) UnmarshalYAML(value *yaml.Node) error {
 	if value.Kind != yaml.MappingNode {
 		return fmt.Errorf("service worker expected a mapping node, got %s", KindToString(value.Kind))
 	}
-	if err := validateYAMLMappingFields(value, httpServiceYAMLFields...); err != nil {
+	apiFields := []string{
+		"forwardProxy",
+	}
+	if err := validateYAMLMappingFields(value, append(httpServiceYAMLFields, apiFields...)...); err != nil {
 		return err
 	}
 
@@ -24,7 +32,16 @@ func (s *ServiceApi) UnmarshalYAML(value *yaml.Node) error {
 		return err
 	}
 
+	type rawServiceApi struct {
+		ForwardProxy *ForwardProxy `yaml:"forwardProxy,omitempty"`
+	}
+	raw := &rawServiceApi{}
+	if err := util.DecodeYAMLNodeStrict(yamlMappingWithFields(value, apiFields...), raw); err != nil {
+		return err
+	}
+
 	s.ServiceHttp = hs
+	s.ForwardProxy = raw.ForwardProxy
 
 	return nil
 }
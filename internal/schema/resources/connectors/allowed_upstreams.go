@@ -0,0 +1,324 @@
+package connectors
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/rmorlok/authproxy/internal/aptmpl"
+	"github.com/rmorlok/authproxy/internal/schema/common"
+)
+
+// DefaultAllowedUpstreamSchemes is used when an AllowedUpstream does not list schemes. Plain http is opt-in so a
+// connector never sends credentials over an unencrypted hop by accident.
+var DefaultAllowedUpstreamSchemes = []string{"https"}
+
+// AllowedUpstream declares a set of upstream URLs that the proxy may forward a connection's credentials to. A URL is
+// allowed when its scheme, host and path all match the entry.
+//
+// Hosts and PathPrefixes are mustache templates rendered against the connection's cfg, labels and annotations, so a
+// connector whose tenants live on their own subdomain can declare `{{cfg.subdomain}}.example.com`. Templates may only
+// reference preconnect fields because the proxy can be used as soon as credentials are established.
+type AllowedUpstream struct {
+	// Hosts are the host patterns this entry allows. A pattern is either an exact host (`api.example.com`) or a
+	// wildcard whose leftmost label is `*` (`*.example.com`), which matches any host with at least one additional
+	// label in front of the suffix but not the suffix itself. A pattern may carry an explicit port
+	// (`api.example.com:8443`); without one, only the scheme's default port is allowed.
+	Hosts []string `json:"hosts" yaml:"hosts"`
+
+	// Schemes are the URL schemes this entry allows. Defaults to ["https"].
+	Schemes []string `json:"schemes,omitempty" yaml:"schemes,omitempty"`
+
+	// PathPrefixes optionally restricts the entry to URL paths under one of these prefixes. Prefixes match on path
+	// segment boundaries: `/v1` matches `/v1` and `/v1/users` but not `/v10`. Paths are cleaned before matching so
+	// dot-segments cannot be used to escape a prefix.
+	PathPrefixes []string `json:"pathPrefixes,omitempty" yaml:"pathPrefixes,omitempty"`
+}
+
+// AllowedUpstreams is the connector-level allowlist of upstream URLs. An empty allowlist imposes no restriction so
+// existing connectors keep working; once any entry is declared, every proxied request must match at least one.
+type AllowedUpstreams []AllowedUpstream
+
+func (a AllowedUpstreams) Clone() AllowedUpstreams {
+	if a == nil {
+		return nil
+	}
+
+	clone := make(AllowedUpstreams, len(a))
+	for i, u := range a {
+		clone[i] = AllowedUpstream{
+			Hosts:        cloneStrings(u.Hosts),
+			Schemes:      cloneStrings(u.Schemes),
+			PathPrefixes: cloneStrings(u.PathPrefixes),
+		}
+	}
+	return clone
+}
+
+func (a AllowedUpstreams) Validate(vc *common.ValidationContext) error {
+	result := &multierror.Error{}
+
+	for i, u := range a {
+		uvc := vc.PushIndex(i)
+		if len(u.Hosts) == 0 {
+			result = multierror.Append(result, uvc.NewErrorfForField("hosts", "at least one host pattern is required"))
+		}
+
+		for j, h := range u.Hosts {
+			hvc := uvc.PushField("hosts").PushIndex(j)
+			if h == "" {
+				result = multierror.Append(result, hvc.NewErrorf("host pattern must not be empty"))
+				continue
+			}
+			if aptmpl.ContainsMustache(h) {
+				// Templated hosts are checked again after rendering; only the static shape can be checked here.
+				continue
+			}
+			if err := validateHostPattern(h); err != nil {
+				result = multierror.Append(result, hvc.NewErrorf("%s", err.Error()))
+			}
+		}
+
+		for j, s := range u.Schemes {
+			if s != "http" && s != "https" {
+				result = multierror.Append(result, uvc.PushField("schemes").PushIndex(j).NewErrorf("scheme must be http or https"))
+			}
+		}
+
+		for j, p := range u.PathPrefixes {
+			if !strings.HasPrefix(p, "/") && !aptmpl.ContainsMustache(p) {
+				result = multierror.Append(result, uvc.PushField("path_prefixes").PushIndex(j).NewErrorf("path prefix must begin with /"))
+			}
+		}
+	}
+
+	return result.ErrorOrNil()
+}
+
+// ValidateMustacheReferences checks that templated hosts and path prefixes only reference preconnect fields.
+func (a AllowedUpstreams) ValidateMustacheReferences(vc *common.ValidationContext, mctx *MustacheValidationContext) error {
+	if len(a) == 0 || mctx == nil {
+		return nil
+	}
+
+	result := &multierror.Error{}
+	for i, u := range a {
+		for j, h := range u.Hosts {
+			checkMustacheTemplate(vc.PushIndex(i).PushField("hosts").PushIndex(j), h, mctx.PreconnectFields, "preconnect", result)
+		}
+		for j, p := range u.PathPrefixes {
+			checkMustacheTemplate(vc.PushIndex(i).PushField("path_prefixes").PushIndex(j), p, mctx.PreconnectFields, "preconnect", result)
+		}
+	}
+	return result.ErrorOrNil()
+}
+
+// IsTemplated reports whether any host or path prefix contains a mustache template, i.e. whether Render needs the
+// connection's mustache context.
+func (a AllowedUpstreams) IsTemplated() bool {
+	for _, u := range a {
+		for _, h := range u.Hosts {
+			if aptmpl.ContainsMustache(h) {
+				return true
+			}
+		}
+		for _, p := range u.PathPrefixes {
+			if aptmpl.ContainsMustache(p) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Render returns a copy of the allowlist with every host and path prefix template rendered against data. Rendered
+// hosts that are not valid host patterns (e.g. a tenant-supplied value containing `/`) are reported as errors rather
+// than silently widening or narrowing the allowlist.
+func (a AllowedUpstreams) Render(data map[string]any) (AllowedUpstreams, error) {
+	if a == nil {
+		return nil, nil
+	}
+
+	rendered := a.Clone()
+	for i := range rendered {
+		for j, h := range rendered[i].Hosts {
+			r, err := aptmpl.RenderMustache(h, data)
+			if err != nil {
+				return nil, fmt.Errorf("failed to render allowed upstream host %q: %w", h, err)
+			}
+			r = strings.ToLower(strings.TrimSpace(r))
+			if err := validateHostPattern(r); err != nil {
+				return nil, fmt.Errorf("allowed upstream host %q rendered to invalid pattern %q: %w", h, r, err)
+			}
+			rendered[i].Hosts[j] = r
+		}
+		for j, p := range rendered[i].PathPrefixes {
+			r, err := aptmpl.RenderMustache(p, data)
+			if err != nil {
+				return nil, fmt.Errorf("failed to render allowed upstream path prefix %q: %w", p, err)
+			}
+			if !strings.HasPrefix(r, "/") {
+				return nil, fmt.Errorf("allowed upstream path prefix %q rendered to %q, which does not begin with /", p, r)
+			}
+			rendered[i].PathPrefixes[j] = r
+		}
+	}
+	return rendered, nil
+}
+
+// Allows reports whether u matches at least one entry. Callers should Render the allowlist first; unrendered
+// templates never match a real host.
+func (a AllowedUpstreams) Allows(u *url.URL) bool {
+	if u == nil {
+		return false
+	}
+	for _, entry := range a {
+		if entry.allows(u) {
+			return true
+		}
+	}
+	return false
+}
+
+func (u *AllowedUpstream) allows(target *url.URL) bool {
+	scheme := strings.ToLower(target.Scheme)
+	schemes := u.Schemes
+	if len(schemes) == 0 {
+		schemes = DefaultAllowedUpstreamSchemes
+	}
+	schemeOk := false
+	for _, s := range schemes {
+		if strings.EqualFold(s, scheme) {
+			schemeOk = true
+			break
+		}
+	}
+	if !schemeOk {
+		return false
+	}
+
+	// Userinfo in the URL is never legitimate for a proxied call and is a common trick for confusing host parsers.
+	if target.User != nil {
+		return false
+	}
+
+	host := strings.ToLower(target.Hostname())
+	port := target.Port()
+	hostOk := false
+	for _, pattern := range u.Hosts {
+		if hostPatternMatches(pattern, scheme, host, port) {
+			hostOk = true
+			break
+		}
+	}
+	if !hostOk {
+		return false
+	}
+
+	if len(u.PathPrefixes) == 0 {
+		return true
+	}
+
+	p := target.Path
+	if p == "" {
+		p = "/"
+	}
+	cleaned := path.Clean(p)
+	for _, prefix := range u.PathPrefixes {
+		if pathHasPrefix(cleaned, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func hostPatternMatches(pattern, scheme, host, port string) bool {
+	patternHost, patternPort := splitHostPattern(pattern)
+	if patternPort != "" {
+		if port != patternPort {
+			return false
+		}
+	} else if port != "" && port != defaultPortForScheme(scheme) {
+		return false
+	}
+
+	if strings.HasPrefix(patternHost, "*.") {
+		suffix := patternHost[1:]
+		return len(host) > len(suffix) && strings.HasSuffix(host, suffix)
+	}
+	return host == patternHost
+}
+
+func splitHostPattern(pattern string) (string, string) {
+	pattern = strings.ToLower(pattern)
+	if idx := strings.LastIndexByte(pattern, ':'); idx >= 0 {
+		return pattern[:idx], pattern[idx+1:]
+	}
+	return pattern, ""
+}
+
+func defaultPortForScheme(scheme string) string {
+	switch scheme {
+	case "http":
+		return "80"
+	case "https":
+		return "443"
+	}
+	return ""
+}
+
+// pathHasPrefix matches on segment boundaries. A prefix ending in / is a plain string prefix.
+func pathHasPrefix(p, prefix string) bool {
+	if strings.HasSuffix(prefix, "/") {
+		return strings.HasPrefix(p, prefix) || p == strings.TrimSuffix(prefix, "/")
+	}
+	return p == prefix || strings.HasPrefix(p, prefix+"/")
+}
+
+func validateHostPattern(pattern string) error {
+	host, port := splitHostPattern(pattern)
+	if strings.Contains(pattern, ":") {
+		if port == "" {
+			return fmt.Errorf("host pattern has an empty port")
+		}
+		for _, r := range port {
+			if r < '0' || r > '9' {
+				return fmt.Errorf("host pattern port must be numeric")
+			}
+		}
+	}
+
+	if host == "" {
+		return fmt.Errorf("host pattern must include a host")
+	}
+
+	labels := strings.Split(host, ".")
+	for i, label := range labels {
+		if label == "*" {
+			if i != 0 || len(labels) < 3 {
+				return fmt.Errorf("wildcard must be the leftmost label of a host with at least two other labels")
+			}
+			continue
+		}
+		if label == "" {
+			return fmt.Errorf("host pattern has an empty label")
+		}
+		for _, r := range label {
+			if !(r >= 'a' && r <= 'z') && !(r >= 'A' && r <= 'Z') && !(r >= '0' && r <= '9') && r != '-' {
+				return fmt.Errorf("host pattern contains invalid character %q", r)
+			}
+		}
+	}
+	return nil
+}
+
+func cloneStrings(s []string) []string {
+	if s == nil {
+		return nil
+	}
+	out := make([]string, len(s))
+	copy(out, s)
+	return out
+}
@@ -13,9 +13,11 @@ import (
 type AuthType string
 
 const (
-	AuthTypeOAuth2 = AuthType("OAuth2")
-	AuthTypeAPIKey = AuthType("api-key")
-	AuthTypeNoAuth = AuthType("no-auth")
+	AuthTypeOAuth1         = AuthType("OAuth1")
+	AuthTypeOAuth2         = AuthType("OAuth2")
+	AuthTypeAPIKey         = AuthType("api-key")
+	AuthTypeNoAuth         = AuthType("no-auth")
+	AuthTypeRequestSigning = AuthType("request-signing")
 )
 
 type AuthImpl interface {
@@ -73,6 +75,9 @@ fieldLoop:
 
 		if keyNode.Value == "type" {
 			switch AuthType(valueNode.Value) {
+			case AuthTypeOAuth1:
+				auth = &AuthOAuth1{}
+				break fieldLoop
 			case AuthTypeOAuth2:
 				auth = &AuthOAuth2{}
 				break fieldLoop
@@ -82,13 +87,16 @@ fieldLoop:
 			case AuthTypeNoAuth:
 				auth = &AuthNoAuth{}
 				break fieldLoop
+			case AuthTypeRequestSigning:
+				auth = &AuthRequestSigning{}
+				break fieldLoop
 			}
 		}
 
 	}
 
 	if auth == nil {
-		return fmt.Errorf("invalid auth type must be: %s, %s, %s", AuthTypeAPIKey, AuthTypeOAuth2, AuthTypeNoAuth)
+		return fmt.Errorf("invalid auth type must be: %s, %s, %s, %s, %s", AuthTypeAPIKey, AuthTypeOAuth1, AuthTypeOAuth2, AuthTypeNoAuth, AuthTypeRequestSigning)
 	}
 
 	if err := util.DecodeYAMLNodeStrict(value, auth); err != nil {
@@ -121,16 +129,20 @@ func (a *Auth) UnmarshalJSON(data []byte) error {
 	var ai AuthImpl
 
 	switch AuthType(m["type"].(string)) {
+	case AuthTypeOAuth1:
+		ai = &AuthOAuth1{}
 	case AuthTypeOAuth2:
 		ai = &AuthOAuth2{}
 	case AuthTypeAPIKey:
 		ai = &AuthApiKey{}
 	case AuthTypeNoAuth:
 		ai = &AuthNoAuth{}
+	case AuthTypeRequestSigning:
+		ai = &AuthRequestSigning{}
 	}
 
 	if ai == nil {
-		return fmt.Errorf("invalid auth type '%s', possible types are: %s, %s, %s", m["type"], AuthTypeAPIKey, AuthTypeOAuth2, AuthTypeNoAuth)
+		return fmt.Errorf("invalid auth type '%s', possible types are: %s, %s, %s, %s, %s", m["type"], AuthTypeAPIKey, AuthTypeOAuth1, AuthTypeOAuth2, AuthTypeNoAuth, AuthTypeRequestSigning)
 	}
 
 	if err := util.DecodeJSONStrict(data, ai); err != nil {
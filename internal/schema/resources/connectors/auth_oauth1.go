@@ -0,0 +1,185 @@
+package connectors
+
+import (
+	"github.com/hashicorp/go-multierror"
+	"github.com/rmorlok/authproxy/internal/schema/common"
+)
+
+// OAuth1SignatureMethod selects how OAuth 1.0a requests are signed. Values follow RFC 5849 §3.4.
+type OAuth1SignatureMethod string
+
+const (
+	// OAuth1SignatureHmacSha1 signs the signature base string with HMAC-SHA1 keyed by the consumer secret and token
+	// secret. This is the default and what nearly every provider supports.
+	OAuth1SignatureHmacSha1 = OAuth1SignatureMethod("HMAC-SHA1")
+	// OAuth1SignatureRsaSha1 signs the signature base string with the consumer's RSA private key. The provider holds
+	// the matching public key, registered out of band.
+	OAuth1SignatureRsaSha1 = OAuth1SignatureMethod("RSA-SHA1")
+	// OAuth1SignaturePlaintext sends the consumer secret and token secret as the signature. Only safe over TLS.
+	OAuth1SignaturePlaintext = OAuth1SignatureMethod("PLAINTEXT")
+)
+
+// AuthOAuth1Endpoint is a provider endpoint that receives a signed OAuth 1.0a request.
+type AuthOAuth1Endpoint struct {
+	Endpoint string `json:"endpoint" yaml:"endpoint"`
+}
+
+// AuthOAuth1Authorization is the resource-owner authorization endpoint the user is redirected to with the temporary
+// request token.
+type AuthOAuth1Authorization struct {
+	Endpoint       string            `json:"endpoint" yaml:"endpoint"`
+	QueryOverrides map[string]string `json:"queryOverrides,omitempty" yaml:"queryOverrides,omitempty"`
+}
+
+// AuthOAuth1 describes a connector that authenticates with an OAuth 1.0a three-legged flow (RFC 5849): a temporary
+// request token is obtained, the user authorizes it at the provider, and the verifier is exchanged for a token and
+// token secret that are used to sign every proxied request.
+type AuthOAuth1 struct {
+	Type AuthType `json:"type" yaml:"type"`
+
+	// SignatureMethod selects the signing algorithm. Defaults to HMAC-SHA1.
+	SignatureMethod OAuth1SignatureMethod `json:"signatureMethod,omitempty" yaml:"signatureMethod,omitempty"`
+
+	// ConsumerKey identifies this application to the provider. Required.
+	ConsumerKey *common.StringValue `json:"consumerKey,omitempty" yaml:"consumerKey,omitempty"`
+
+	// ConsumerSecret is the shared secret issued with the consumer key. Required for HMAC-SHA1 and PLAINTEXT.
+	ConsumerSecret *common.StringValue `json:"consumerSecret,omitempty" yaml:"consumerSecret,omitempty" apiredact:"secret"`
+
+	// PrivateKey is the PEM-encoded RSA private key used for RSA-SHA1. Required for RSA-SHA1.
+	PrivateKey *common.StringValue `json:"privateKey,omitempty" yaml:"privateKey,omitempty" apiredact:"secret"`
+
+	// Realm, when set, is included in the Authorization header of every signed request.
+	Realm string `json:"realm,omitempty" yaml:"realm,omitempty"`
+
+	// RequestToken is the temporary credential request endpoint (RFC 5849 §2.1).
+	RequestToken AuthOAuth1Endpoint `json:"requestToken" yaml:"requestToken"`
+
+	// Authorization is the resource owner authorization endpoint (RFC 5849 §2.2).
+	Authorization AuthOAuth1Authorization `json:"authorization" yaml:"authorization"`
+
+	// AccessToken is the token request endpoint (RFC 5849 §2.3).
+	AccessToken AuthOAuth1Endpoint `json:"accessToken" yaml:"accessToken"`
+
+	// Revocation, when set, is called with a signed POST to invalidate the token before it is deleted locally. OAuth
+	// 1.0a does not standardize revocation; this covers the common "invalidate token" endpoint shape.
+	Revocation *AuthOAuth1Endpoint `json:"revocation,omitempty" yaml:"revocation,omitempty"`
+}
+
+// GetSignatureMethodOrDefault returns the configured signature method, defaulting to HMAC-SHA1.
+func (a *AuthOAuth1) GetSignatureMethodOrDefault() OAuth1SignatureMethod {
+	if a == nil || a.SignatureMethod == "" {
+		return OAuth1SignatureHmacSha1
+	}
+	return a.SignatureMethod
+}
+
+func (a *AuthOAuth1) GetType() AuthType {
+	return AuthTypeOAuth1
+}
+
+func (a *AuthOAuth1) Clone() AuthImpl {
+	if a == nil {
+		return nil
+	}
+
+	clone := *a
+	if a.ConsumerKey != nil {
+		clone.ConsumerKey = a.ConsumerKey.CloneValue()
+	}
+	if a.ConsumerSecret != nil {
+		clone.ConsumerSecret = a.ConsumerSecret.CloneValue()
+	}
+	if a.PrivateKey != nil {
+		clone.PrivateKey = a.PrivateKey.CloneValue()
+	}
+	if a.Authorization.QueryOverrides != nil {
+		clone.Authorization.QueryOverrides = make(map[string]string, len(a.Authorization.QueryOverrides))
+		for k, v := range a.Authorization.QueryOverrides {
+			clone.Authorization.QueryOverrides[k] = v
+		}
+	}
+	if a.Revocation != nil {
+		revocation := *a.Revocation
+		clone.Revocation = &revocation
+	}
+	return &clone
+}
+
+// Validate enforces the OAuth 1.0a schema invariants: the three flow endpoints are present, the signature method is
+// known, and the key material that method needs is configured.
+func (a *AuthOAuth1) Validate(vc *common.ValidationContext) error {
+	if a == nil {
+		return nil
+	}
+
+	result := &multierror.Error{}
+
+	method := a.GetSignatureMethodOrDefault()
+	switch method {
+	case OAuth1SignatureHmacSha1, OAuth1SignatureRsaSha1, OAuth1SignaturePlaintext:
+	default:
+		result = multierror.Append(result, vc.NewErrorfForField("signature_method",
+			"%q is not a valid signature method; must be one of %q, %q, %q",
+			method, OAuth1SignatureHmacSha1, OAuth1SignatureRsaSha1, OAuth1SignaturePlaintext,
+		))
+	}
+
+	if a.ConsumerKey == nil || a.ConsumerKey.InnerVal == nil {
+		result = multierror.Append(result, vc.NewErrorfForField("consumer_key", "is required"))
+	}
+
+	hasSecret := a.ConsumerSecret != nil && a.ConsumerSecret.InnerVal != nil
+	hasPrivateKey := a.PrivateKey != nil && a.PrivateKey.InnerVal != nil
+	if method == OAuth1SignatureRsaSha1 {
+		if !hasPrivateKey {
+			result = multierror.Append(result, vc.NewErrorfForField("private_key", "is required when signature_method is %q", method))
+		}
+	} else if !hasSecret {
+		result = multierror.Append(result, vc.NewErrorfForField("consumer_secret", "is required when signature_method is %q", method))
+	}
+
+	if a.RequestToken.Endpoint == "" {
+		result = multierror.Append(result, vc.PushField("request_token").NewErrorfForField("endpoint", "is required"))
+	}
+	if a.Authorization.Endpoint == "" {
+		result = multierror.Append(result, vc.PushField("authorization").NewErrorfForField("endpoint", "is required"))
+	}
+	if a.AccessToken.Endpoint == "" {
+		result = multierror.Append(result, vc.PushField("access_token").NewErrorfForField("endpoint", "is required"))
+	}
+	if a.Revocation != nil && a.Revocation.Endpoint == "" {
+		result = multierror.Append(result, vc.PushField("revocation").NewErrorfForField("endpoint", "is required"))
+	}
+
+	return result.ErrorOrNil()
+}
+
+// ValidateMustacheReferences cross-checks the templated endpoints against the field-availability data in mctx. The
+// flow endpoints render during the auth phase and may only reference preconnect fields; the revocation endpoint
+// renders after setup completes and may reference any cfg field.
+func (a *AuthOAuth1) ValidateMustacheReferences(vc *common.ValidationContext, mctx *MustacheValidationContext) error {
+	if a == nil || mctx == nil {
+		return nil
+	}
+
+	result := &multierror.Error{}
+	preconnectFields := mctx.PreconnectFields
+
+	checkMustacheTemplate(vc.PushField("request_token").PushField("endpoint"), a.RequestToken.Endpoint, preconnectFields, "preconnect", result)
+	checkMustacheTemplate(vc.PushField("authorization").PushField("endpoint"), a.Authorization.Endpoint, preconnectFields, "preconnect", result)
+	for k, v := range a.Authorization.QueryOverrides {
+		checkMustacheTemplate(vc.PushField("authorization").PushField("query_overrides").PushField(k), v, preconnectFields, "preconnect", result)
+	}
+	checkMustacheTemplate(vc.PushField("access_token").PushField("endpoint"), a.AccessToken.Endpoint, preconnectFields, "preconnect", result)
+
+	if a.Revocation != nil {
+		checkMustacheTemplate(vc.PushField("revocation").PushField("endpoint"), a.Revocation.Endpoint, mctx.AllConfigFields, "setup flow", result)
+	}
+
+	return result.ErrorOrNil()
+}
+
+var _ AuthImpl = (*AuthOAuth1)(nil)
+var _ AuthValidator = (*AuthOAuth1)(nil)
+var _ MustacheValidator = (*AuthOAuth1)(nil)
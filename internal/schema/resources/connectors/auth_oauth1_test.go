@@ -0,0 +1,163 @@
+package connectors
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/rmorlok/authproxy/internal/schema/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func validOAuth1() *AuthOAuth1 {
+	return &AuthOAuth1{
+		Type:           AuthTypeOAuth1,
+		ConsumerKey:    &common.StringValue{InnerVal: &common.StringValueDirect{Value: "key"}},
+		ConsumerSecret: &common.StringValue{InnerVal: &common.StringValueDirect{Value: "secret"}},
+		RequestToken:   AuthOAuth1Endpoint{Endpoint: "https://api.example.com/oauth/request_token"},
+		Authorization:  AuthOAuth1Authorization{Endpoint: "https://www.example.com/oauth/authorize"},
+		AccessToken:    AuthOAuth1Endpoint{Endpoint: "https://api.example.com/oauth/access_token"},
+	}
+}
+
+func TestAuthOAuth1_Unmarshal(t *testing.T) {
+	t.Run("yaml", func(t *testing.T) {
+		var a Auth
+		require.NoError(t, yaml.Unmarshal([]byte(`
+type: OAuth1
+signatureMethod: RSA-SHA1
+consumerKey:
+  value: key
+privateKey:
+  envVar: PRIVATE_KEY
+requestToken:
+  endpoint: https://api.example.com/oauth/request_token
+authorization:
+  endpoint: https://www.example.com/oauth/authorize
+accessToken:
+  endpoint: https://api.example.com/oauth/access_token
+`), &a))
+		o1, ok := a.Inner().(*AuthOAuth1)
+		require.True(t, ok)
+		assert.Equal(t, AuthTypeOAuth1, a.GetType())
+		assert.Equal(t, OAuth1SignatureRsaSha1, o1.GetSignatureMethodOrDefault())
+		assert.Equal(t, "https://www.example.com/oauth/authorize", o1.Authorization.Endpoint)
+		assert.Nil(t, o1.Revocation)
+	})
+
+	t.Run("json", func(t *testing.T) {
+		var a Auth
+		require.NoError(t, json.Unmarshal([]byte(`{
+			"type": "OAuth1",
+			"consumerKey": {"value": "key"},
+			"consumerSecret": {"value": "secret"},
+			"requestToken": {"endpoint": "https://api.example.com/oauth/request_token"},
+			"authorization": {"endpoint": "https://www.example.com/oauth/authorize"},
+			"accessToken": {"endpoint": "https://api.example.com/oauth/access_token"},
+			"revocation": {"endpoint": "https://api.example.com/oauth/invalidate_token"}
+		}`), &a))
+		o1, ok := a.Inner().(*AuthOAuth1)
+		require.True(t, ok)
+		assert.Equal(t, OAuth1SignatureHmacSha1, o1.GetSignatureMethodOrDefault())
+		require.NotNil(t, o1.Revocation)
+		assert.Equal(t, "https://api.example.com/oauth/invalidate_token", o1.Revocation.Endpoint)
+	})
+}
+
+func TestAuthOAuth1_Clone(t *testing.T) {
+	orig := validOAuth1()
+	orig.Authorization.QueryOverrides = map[string]string{"scope": "read"}
+	orig.Revocation = &AuthOAuth1Endpoint{Endpoint: "https://api.example.com/revoke"}
+
+	clone := orig.Clone().(*AuthOAuth1)
+	clone.Authorization.QueryOverrides["scope"] = "write"
+	clone.Revocation.Endpoint = "https://other.example.com"
+
+	assert.Equal(t, "read", orig.Authorization.QueryOverrides["scope"])
+	assert.Equal(t, "https://api.example.com/revoke", orig.Revocation.Endpoint)
+}
+
+func TestAuthOAuth1_Validate(t *testing.T) {
+	tests := []struct {
+		name        string
+		mutate      func(a *AuthOAuth1) *AuthOAuth1
+		wantErrSubs []string
+	}{
+		{
+			name:   "nil receiver",
+			mutate: func(a *AuthOAuth1) *AuthOAuth1 { return nil },
+		},
+		{
+			name:   "valid hmac",
+			mutate: func(a *AuthOAuth1) *AuthOAuth1 { return a },
+		},
+		{
+			name: "valid rsa",
+			mutate: func(a *AuthOAuth1) *AuthOAuth1 {
+				a.SignatureMethod = OAuth1SignatureRsaSha1
+				a.ConsumerSecret = nil
+				a.PrivateKey = &common.StringValue{InnerVal: &common.StringValueDirect{Value: "pem"}}
+				return a
+			},
+		},
+		{
+			name: "unknown signature method",
+			mutate: func(a *AuthOAuth1) *AuthOAuth1 {
+				a.SignatureMethod = "HMAC-SHA256"
+				return a
+			},
+			wantErrSubs: []string{"signature_method", `"HMAC-SHA256" is not a valid signature method`},
+		},
+		{
+			name: "rsa without private key",
+			mutate: func(a *AuthOAuth1) *AuthOAuth1 {
+				a.SignatureMethod = OAuth1SignatureRsaSha1
+				return a
+			},
+			wantErrSubs: []string{"private_key", "is required"},
+		},
+		{
+			name: "plaintext without secret",
+			mutate: func(a *AuthOAuth1) *AuthOAuth1 {
+				a.SignatureMethod = OAuth1SignaturePlaintext
+				a.ConsumerSecret = nil
+				return a
+			},
+			wantErrSubs: []string{"consumer_secret", "is required"},
+		},
+		{
+			name: "missing endpoints and key",
+			mutate: func(a *AuthOAuth1) *AuthOAuth1 {
+				a.ConsumerKey = nil
+				a.RequestToken.Endpoint = ""
+				a.Authorization.Endpoint = ""
+				a.AccessToken.Endpoint = ""
+				a.Revocation = &AuthOAuth1Endpoint{}
+				return a
+			},
+			wantErrSubs: []string{
+				"consumer_key",
+				"request_token.endpoint",
+				"authorization.endpoint",
+				"access_token.endpoint",
+				"revocation.endpoint",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.mutate(validOAuth1()).Validate(&common.ValidationContext{})
+			if len(tt.wantErrSubs) == 0 {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			msg := err.Error()
+			for _, sub := range tt.wantErrSubs {
+				assert.Contains(t, msg, sub)
+			}
+		})
+	}
+}
@@ -36,6 +36,14 @@ type OAuth2GrantType string
 const (
 	OAuth2GrantAuthorizationCode = OAuth2GrantType("authorization_code")
 	OAuth2GrantClientCredentials = OAuth2GrantType("client_credentials")
+	// OAuth2GrantJwtBearer exchanges a signed JWT assertion for an access
+	// token (RFC 7523 §2.1). Used by service accounts and server-to-server
+	// apps; the signing key is collected during connection setup.
+	OAuth2GrantJwtBearer = OAuth2GrantType("urn:ietf:params:oauth:grant-type:jwt-bearer")
+	// OAuth2GrantDeviceCode is the device authorization grant (RFC 8628) for
+	// input-constrained or headless clients. The user approves on a second
+	// device and the proxy polls the token endpoint in the background.
+	OAuth2GrantDeviceCode = OAuth2GrantType("urn:ietf:params:oauth:grant-type:device_code")
 )
 
 type AuthOAuth2 struct {
@@ -57,6 +65,16 @@ type AuthOAuth2 struct {
 	Authorization           AuthOauth2Authorization  `json:"authorization" yaml:"authorization"`
 	Token                   AuthOauth2Token          `json:"token" yaml:"token"`
 	Revocation              *AuthOauth2Revocation    `json:"revocation,omitempty" yaml:"revocation,omitempty"`
+	// Assertion configures the JWT minted for the jwt-bearer grant. Required
+	// for that grant and must be omitted for the others.
+	Assertion *AuthOAuth2Assertion `json:"assertion,omitempty" yaml:"assertion,omitempty"`
+	// DeviceAuthorization configures the device authorization endpoint for the
+	// device_code grant. Required for that grant and must be omitted for the others.
+	DeviceAuthorization *AuthOauth2DeviceAuthorization `json:"deviceAuthorization,omitempty" yaml:"deviceAuthorization,omitempty"`
+	// Oidc enables OpenID Connect id_token validation and records the identity
+	// of the connected account on the connection. Only valid for the
+	// authorization_code grant.
+	Oidc *AuthOauth2Oidc `json:"oidc,omitempty" yaml:"oidc,omitempty"`
 }
 
 // NewTokenEndpointAuthMethod returns a pointer to m. Convenience constructor
@@ -91,7 +109,8 @@ func (a *AuthOAuth2) GetGrantTypeOrDefault() OAuth2GrantType {
 }
 
 func (a *AuthOAuth2) SupportsRefreshToken() bool {
-	return a.GetGrantTypeOrDefault() == OAuth2GrantAuthorizationCode
+	grantType := a.GetGrantTypeOrDefault()
+	return grantType == OAuth2GrantAuthorizationCode || grantType == OAuth2GrantDeviceCode
 }
 
 func (a *AuthOAuth2) GetType() AuthType {
@@ -99,8 +118,8 @@ func (a *AuthOAuth2) GetType() AuthType {
 }
 
 // ValidateMustacheReferences cross-checks every templated field on the OAuth2 auth
-// definition against the field-availability data in mctx. Authorization and Token
-// templates render during the auth phase and may only reference preconnect fields;
+// definition against the field-availability data in mctx. Authorization, Token and
+// Assertion and DeviceAuthorization templates render during the auth phase and may only reference preconnect fields;
 // Revocation templates render after setup completes and may reference any cfg field.
 func (a *AuthOAuth2) ValidateMustacheReferences(vc *common.ValidationContext, mctx *MustacheValidationContext) error {
 	if a == nil || mctx == nil {
@@ -111,6 +130,8 @@ func (a *AuthOAuth2) ValidateMustacheReferences(vc *common.ValidationContext, mc
 	preconnectFields := mctx.PreconnectFields
 	allConfigFields := mctx.AllConfigFields
 
+	a.Assertion.validateMustacheReferences(vc.PushField("assertion"), preconnectFields, result)
+
 	if a.GetGrantTypeOrDefault() == OAuth2GrantAuthorizationCode {
 		checkMustacheTemplate(vc.PushField("authorization").PushField("endpoint"), a.Authorization.Endpoint, preconnectFields, "preconnect", result)
 		for k, v := range a.Authorization.QueryOverrides {
@@ -118,6 +139,16 @@ func (a *AuthOAuth2) ValidateMustacheReferences(vc *common.ValidationContext, mc
 		}
 	}
 
+	if a.DeviceAuthorization != nil {
+		checkMustacheTemplate(vc.PushField("device_authorization").PushField("endpoint"), a.DeviceAuthorization.Endpoint, preconnectFields, "preconnect", result)
+		for k, v := range a.DeviceAuthorization.QueryOverrides {
+			checkMustacheTemplate(vc.PushField("device_authorization").PushField("query_overrides").PushField(k), v, preconnectFields, "preconnect", result)
+		}
+		for k, v := range a.DeviceAuthorization.FormOverrides {
+			checkMustacheTemplate(vc.PushField("device_authorization").PushField("form_overrides").PushField(k), v, preconnectFields, "preconnect", result)
+		}
+	}
+
 	checkMustacheTemplate(vc.PushField("token").PushField("endpoint"), a.Token.Endpoint, preconnectFields, "preconnect", result)
 	for k, v := range a.Token.QueryOverrides {
 		checkMustacheTemplate(vc.PushField("token").PushField("query_overrides").PushField(k), v, preconnectFields, "preconnect", result)
@@ -163,6 +194,9 @@ func (a *AuthOAuth2) Clone() AuthImpl {
 	clone.Scopes = scopes
 
 	clone.Authorization.PKCE = a.Authorization.PKCE.Clone()
+	clone.Assertion = a.Assertion.Clone()
+	clone.DeviceAuthorization = a.DeviceAuthorization.Clone()
+	clone.Oidc = a.Oidc.Clone()
 
 	return &clone
 }
@@ -182,22 +216,84 @@ func (a *AuthOAuth2) ValidateWithJavascript(vc *common.ValidationContext, librar
 	result := &multierror.Error{}
 	grantType := a.GetGrantTypeOrDefault()
 	switch grantType {
-	case OAuth2GrantAuthorizationCode, OAuth2GrantClientCredentials:
+	case OAuth2GrantAuthorizationCode, OAuth2GrantClientCredentials, OAuth2GrantJwtBearer, OAuth2GrantDeviceCode:
 	case "":
 		result = multierror.Append(result, vc.NewErrorfForField("grant_type",
-			"must not be empty; omit the field to use the default (%q), or set one of %q, %q",
+			"must not be empty; omit the field to use the default (%q), or set one of %q, %q, %q, %q",
 			OAuth2GrantAuthorizationCode,
-			OAuth2GrantAuthorizationCode, OAuth2GrantClientCredentials,
+			OAuth2GrantAuthorizationCode, OAuth2GrantClientCredentials, OAuth2GrantJwtBearer, OAuth2GrantDeviceCode,
 		))
 		return result.ErrorOrNil()
 	default:
 		result = multierror.Append(result, vc.NewErrorfForField("grant_type",
-			"%q is not a valid grant type; must be %q or %q",
+			"%q is not a valid grant type; must be %q, %q, %q, or %q",
 			grantType,
-			OAuth2GrantAuthorizationCode, OAuth2GrantClientCredentials,
+			OAuth2GrantAuthorizationCode, OAuth2GrantClientCredentials, OAuth2GrantJwtBearer, OAuth2GrantDeviceCode,
 		))
 	}
 
+	if grantType == OAuth2GrantJwtBearer {
+		if a.Assertion == nil {
+			result = multierror.Append(result, vc.NewErrorfForField("assertion",
+				"is required when grant_type is %q", OAuth2GrantJwtBearer,
+			))
+		} else if err := a.Assertion.Validate(vc.PushField("assertion")); err != nil {
+			result = multierror.Append(result, err)
+		}
+		if a.Authorization.Endpoint != "" || len(a.Authorization.QueryOverrides) > 0 || a.Authorization.PKCE != nil {
+			result = multierror.Append(result, vc.NewErrorfForField("authorization",
+				"must be omitted when grant_type is %q", OAuth2GrantJwtBearer,
+			))
+		}
+		if a.ClientSecret != nil && a.ClientId == nil {
+			result = multierror.Append(result, vc.NewErrorfForField("client_secret",
+				"must be omitted when client_id is omitted",
+			))
+		}
+	} else if a.Assertion != nil {
+		result = multierror.Append(result, vc.NewErrorfForField("assertion",
+			"must be omitted unless grant_type is %q", OAuth2GrantJwtBearer,
+		))
+	}
+
+	if grantType == OAuth2GrantDeviceCode {
+		if a.DeviceAuthorization == nil {
+			result = multierror.Append(result, vc.NewErrorfForField("device_authorization",
+				"is required when grant_type is %q", OAuth2GrantDeviceCode,
+			))
+		} else {
+			if a.DeviceAuthorization.Endpoint == "" {
+				result = multierror.Append(result, vc.PushField("device_authorization").NewErrorfForField("endpoint",
+					"is required when grant_type is %q", OAuth2GrantDeviceCode,
+				))
+			}
+			if a.DeviceAuthorization.PollInterval != nil && a.DeviceAuthorization.PollInterval.Duration <= 0 {
+				result = multierror.Append(result, vc.PushField("device_authorization").NewErrorfForField("poll_interval",
+					"must be positive",
+				))
+			}
+		}
+		if a.Authorization.Endpoint != "" || len(a.Authorization.QueryOverrides) > 0 || a.Authorization.PKCE != nil {
+			result = multierror.Append(result, vc.NewErrorfForField("authorization",
+				"must be omitted when grant_type is %q", OAuth2GrantDeviceCode,
+			))
+		}
+	} else if a.DeviceAuthorization != nil {
+		result = multierror.Append(result, vc.NewErrorfForField("device_authorization",
+			"must be omitted unless grant_type is %q", OAuth2GrantDeviceCode,
+		))
+	}
+
+	if a.Oidc != nil {
+		if grantType != OAuth2GrantAuthorizationCode {
+			result = multierror.Append(result, vc.NewErrorfForField("oidc",
+				"must be omitted unless grant_type is %q", OAuth2GrantAuthorizationCode,
+			))
+		} else if err := a.Oidc.validate(vc.PushField("oidc"), a.Scopes); err != nil {
+			result = multierror.Append(result, err)
+		}
+	}
+
 	if grantType == OAuth2GrantClientCredentials {
 		if a.Authorization.Endpoint != "" || len(a.Authorization.QueryOverrides) > 0 || a.Authorization.PKCE != nil {
 			result = multierror.Append(result, vc.NewErrorfForField("authorization",
@@ -216,7 +312,7 @@ func (a *AuthOAuth2) ValidateWithJavascript(vc *common.ValidationContext, librar
 				OAuth2GrantClientCredentials,
 			))
 		}
-	} else if a.Authorization.Endpoint == "" {
+	} else if grantType == OAuth2GrantAuthorizationCode && a.Authorization.Endpoint == "" {
 		result = multierror.Append(result, vc.PushField("authorization").NewErrorfForField("endpoint",
 			"is required when grant_type is %q", OAuth2GrantAuthorizationCode,
 		))
@@ -250,7 +346,7 @@ func (a *AuthOAuth2) ValidateWithJavascript(vc *common.ValidationContext, librar
 
 	hasClientId := a.ClientId != nil && a.ClientId.InnerVal != nil
 	hasSecret := a.ClientSecret != nil && a.ClientSecret.InnerVal != nil
-	if grantType == OAuth2GrantAuthorizationCode && !hasClientId {
+	if (grantType == OAuth2GrantAuthorizationCode || grantType == OAuth2GrantDeviceCode) && !hasClientId {
 		result = multierror.Append(result, vc.NewErrorfForField("client_id", "is required"))
 	}
 
@@ -263,7 +359,12 @@ func (a *AuthOAuth2) ValidateWithJavascript(vc *common.ValidationContext, librar
 	method := a.GetTokenEndpointAuthMethodOrDefault()
 	switch method {
 	case TokenEndpointAuthClientSecretPost, TokenEndpointAuthClientSecretBasic:
-		if grantType == OAuth2GrantAuthorizationCode && !hasSecret {
+		// jwt-bearer connectors only authenticate the client when they
+		// declare a client_id; the assertion is the grant either way.
+		needsSecret := grantType == OAuth2GrantAuthorizationCode ||
+			grantType == OAuth2GrantDeviceCode ||
+			(grantType == OAuth2GrantJwtBearer && hasClientId)
+		if needsSecret && !hasSecret {
 			result = multierror.Append(result, vc.NewErrorfForField("client_secret",
 				"is required when token_endpoint_auth_method is %q", method,
 			))
@@ -0,0 +1,155 @@
+package connectors
+
+import (
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/rmorlok/authproxy/internal/schema/common"
+)
+
+// JwtAssertionAlgorithm is the JWS algorithm used to sign the JWT-bearer
+// assertion (RFC 7518 §3.1). Only asymmetric algorithms are supported; the
+// private key is uploaded by the tenant during connection setup.
+type JwtAssertionAlgorithm string
+
+const (
+	JwtAssertionAlgorithmRS256 = JwtAssertionAlgorithm("RS256")
+	JwtAssertionAlgorithmRS384 = JwtAssertionAlgorithm("RS384")
+	JwtAssertionAlgorithmRS512 = JwtAssertionAlgorithm("RS512")
+	JwtAssertionAlgorithmPS256 = JwtAssertionAlgorithm("PS256")
+	JwtAssertionAlgorithmES256 = JwtAssertionAlgorithm("ES256")
+	JwtAssertionAlgorithmES384 = JwtAssertionAlgorithm("ES384")
+)
+
+// defaultJwtAssertionLifetime is the assertion lifetime when the connector
+// doesn't specify one. Google rejects assertions valid for more than an hour.
+const defaultJwtAssertionLifetime = time.Hour
+
+// AuthOAuth2Assertion configures the signed JWT minted for the
+// urn:ietf:params:oauth:grant-type:jwt-bearer grant (RFC 7523 §2.1). Every
+// string claim is a mustache template rendered against the connection's
+// preconnect configuration.
+type AuthOAuth2Assertion struct {
+	// Algorithm is the signing algorithm. Defaults to RS256.
+	Algorithm JwtAssertionAlgorithm `json:"algorithm,omitempty" yaml:"algorithm,omitempty"`
+
+	// Issuer is the `iss` claim, typically the service account or client id.
+	Issuer string `json:"issuer" yaml:"issuer"`
+
+	// Subject is the `sub` claim, the principal the token is requested for.
+	// Omitted from the assertion when empty.
+	Subject string `json:"subject,omitempty" yaml:"subject,omitempty"`
+
+	// Audience is the `aud` claim. Defaults to the token endpoint.
+	Audience string `json:"audience,omitempty" yaml:"audience,omitempty"`
+
+	// Scope is the `scope` claim for providers, like Google, that take scopes
+	// in the assertion rather than the token request. Omitted when empty.
+	Scope string `json:"scope,omitempty" yaml:"scope,omitempty"`
+
+	// Lifetime is how long the assertion is valid for. Defaults to 1 hour.
+	Lifetime *common.HumanDuration `json:"lifetime,omitempty" yaml:"lifetime,omitempty"`
+
+	// AdditionalClaims are extra string claims added to the assertion.
+	AdditionalClaims map[string]string `json:"additionalClaims,omitempty" yaml:"additionalClaims,omitempty"`
+}
+
+func (a *AuthOAuth2Assertion) GetAlgorithmOrDefault() JwtAssertionAlgorithm {
+	if a == nil || a.Algorithm == "" {
+		return JwtAssertionAlgorithmRS256
+	}
+	return a.Algorithm
+}
+
+func (a *AuthOAuth2Assertion) GetLifetimeOrDefault() time.Duration {
+	if a == nil || a.Lifetime == nil {
+		return defaultJwtAssertionLifetime
+	}
+	return a.Lifetime.Duration
+}
+
+func (a *AuthOAuth2Assertion) Clone() *AuthOAuth2Assertion {
+	if a == nil {
+		return nil
+	}
+
+	clone := *a
+
+	if a.Lifetime != nil {
+		lifetime := *a.Lifetime
+		clone.Lifetime = &lifetime
+	}
+
+	if a.AdditionalClaims != nil {
+		clone.AdditionalClaims = make(map[string]string, len(a.AdditionalClaims))
+		for k, v := range a.AdditionalClaims {
+			clone.AdditionalClaims[k] = v
+		}
+	}
+
+	return &clone
+}
+
+// reservedAssertionClaims are set by the proxy and can't be overridden
+// through AdditionalClaims.
+var reservedAssertionClaims = map[string]bool{
+	"iss": true,
+	"sub": true,
+	"aud": true,
+	"exp": true,
+	"iat": true,
+	"nbf": true,
+	"jti": true,
+}
+
+func (a *AuthOAuth2Assertion) Validate(vc *common.ValidationContext) error {
+	if a == nil {
+		return nil
+	}
+
+	result := &multierror.Error{}
+
+	switch a.GetAlgorithmOrDefault() {
+	case JwtAssertionAlgorithmRS256, JwtAssertionAlgorithmRS384, JwtAssertionAlgorithmRS512,
+		JwtAssertionAlgorithmPS256, JwtAssertionAlgorithmES256, JwtAssertionAlgorithmES384:
+	default:
+		result = multierror.Append(result, vc.NewErrorfForField("algorithm",
+			"%q is not a supported algorithm; must be one of %q, %q, %q, %q, %q, %q",
+			a.Algorithm,
+			JwtAssertionAlgorithmRS256, JwtAssertionAlgorithmRS384, JwtAssertionAlgorithmRS512,
+			JwtAssertionAlgorithmPS256, JwtAssertionAlgorithmES256, JwtAssertionAlgorithmES384,
+		))
+	}
+
+	if a.Issuer == "" {
+		result = multierror.Append(result, vc.NewErrorfForField("issuer", "is required"))
+	}
+
+	if a.Lifetime != nil && a.Lifetime.Duration <= 0 {
+		result = multierror.Append(result, vc.NewErrorfForField("lifetime", "must be positive"))
+	}
+
+	for k := range a.AdditionalClaims {
+		if reservedAssertionClaims[k] {
+			result = multierror.Append(result, vc.PushField("additional_claims").NewErrorfForField(k,
+				"is set by the proxy and cannot be overridden",
+			))
+		}
+	}
+
+	return result.ErrorOrNil()
+}
+
+func (a *AuthOAuth2Assertion) validateMustacheReferences(vc *common.ValidationContext, fields map[string]bool, result *multierror.Error) {
+	if a == nil {
+		return
+	}
+
+	checkMustacheTemplate(vc.PushField("issuer"), a.Issuer, fields, "preconnect", result)
+	checkMustacheTemplate(vc.PushField("subject"), a.Subject, fields, "preconnect", result)
+	checkMustacheTemplate(vc.PushField("audience"), a.Audience, fields, "preconnect", result)
+	checkMustacheTemplate(vc.PushField("scope"), a.Scope, fields, "preconnect", result)
+	for k, v := range a.AdditionalClaims {
+		checkMustacheTemplate(vc.PushField("additional_claims").PushField(k), v, fields, "preconnect", result)
+	}
+}
@@ -0,0 +1,53 @@
+package connectors
+
+import (
+	"time"
+
+	"github.com/rmorlok/authproxy/internal/schema/common"
+)
+
+// defaultDeviceCodePollInterval is the polling interval used when the device
+// authorization response omits `interval` (RFC 8628 §3.2).
+const defaultDeviceCodePollInterval = 5 * time.Second
+
+// AuthOauth2DeviceAuthorization configures the device authorization endpoint
+// for the urn:ietf:params:oauth:grant-type:device_code grant (RFC 8628 §3.1).
+// The endpoint issues the device_code / user_code pair that the user enters on
+// a second device; the token endpoint is then polled until they approve.
+type AuthOauth2DeviceAuthorization struct {
+	// Endpoint is the device authorization endpoint on the 3rd party.
+	Endpoint string `json:"endpoint" yaml:"endpoint"`
+
+	// QueryOverrides are query parameters that are applied on top of the request.
+	QueryOverrides map[string]string `json:"queryOverrides,omitempty" yaml:"queryOverrides,omitempty"`
+
+	// FormOverrides are additional parameters that are included in the form data posted to the 3rd party. These
+	// override the standard client_id / scope parameters if there is overlap.
+	FormOverrides map[string]string `json:"formOverrides,omitempty" yaml:"formOverrides,omitempty"`
+
+	// PollInterval is the minimum time between token endpoint polls. The provider's `interval` is used when it is
+	// larger. Defaults to 5s, the RFC 8628 default.
+	PollInterval *common.HumanDuration `json:"pollInterval,omitempty" yaml:"pollInterval,omitempty"`
+}
+
+func (d *AuthOauth2DeviceAuthorization) GetPollIntervalOrDefault() time.Duration {
+	if d == nil || d.PollInterval == nil {
+		return defaultDeviceCodePollInterval
+	}
+	return d.PollInterval.Duration
+}
+
+func (d *AuthOauth2DeviceAuthorization) Clone() *AuthOauth2DeviceAuthorization {
+	if d == nil {
+		return nil
+	}
+
+	clone := *d
+
+	if d.PollInterval != nil {
+		interval := *d.PollInterval
+		clone.PollInterval = &interval
+	}
+
+	return &clone
+}
@@ -0,0 +1,161 @@
+package connectors
+
+import (
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/rmorlok/authproxy/internal/schema/common"
+)
+
+// defaultOidcJwksCacheTtl is how long discovery documents and JWKS key sets
+// are cached before being re-fetched from the provider.
+const defaultOidcJwksCacheTtl = time.Hour
+
+// OidcDuplicateSubjectPolicy controls what happens when a connection
+// authenticates as an upstream account that another connection for the same
+// connector in the same namespace is already connected to.
+type OidcDuplicateSubjectPolicy string
+
+const (
+	// OidcDuplicateSubjectAllow permits any number of connections for the same
+	// subject. This is the default.
+	OidcDuplicateSubjectAllow = OidcDuplicateSubjectPolicy("allow")
+	// OidcDuplicateSubjectReject fails the auth phase of the new connection so
+	// the user can retry with a different account.
+	OidcDuplicateSubjectReject = OidcDuplicateSubjectPolicy("reject")
+	// OidcDuplicateSubjectMerge keeps the new connection and, once its
+	// credentials are stored, disconnects the existing connections for the same
+	// subject, annotating them with the id of the connection that replaced them.
+	OidcDuplicateSubjectMerge = OidcDuplicateSubjectPolicy("merge")
+)
+
+// AuthOauth2Oidc enables OpenID Connect handling on top of the
+// authorization_code grant. The id_token returned from the token endpoint is
+// validated (signature, issuer, audience, nonce, expiry) and the identity of
+// the connected upstream account is recorded on the connection as apxy/oidc/*
+// annotations.
+type AuthOauth2Oidc struct {
+	// Issuer is the OpenID Provider issuer identifier. The id_token's iss claim
+	// must match it exactly.
+	Issuer string `json:"issuer" yaml:"issuer"`
+
+	// DiscoveryUrl overrides where the discovery document is fetched from.
+	// Defaults to <issuer>/.well-known/openid-configuration.
+	DiscoveryUrl string `json:"discoveryUrl,omitempty" yaml:"discoveryUrl,omitempty"`
+
+	// JwksUri overrides the jwks_uri from the discovery document. When set
+	// along with UserinfoEndpoint (or with userinfo disabled) discovery is
+	// skipped entirely.
+	JwksUri string `json:"jwksUri,omitempty" yaml:"jwksUri,omitempty"`
+
+	// FetchUserinfo calls the userinfo endpoint with the new access token to
+	// fill in claims, such as email, that the provider leaves out of the
+	// id_token.
+	FetchUserinfo bool `json:"fetchUserinfo,omitempty" yaml:"fetchUserinfo,omitempty"`
+
+	// UserinfoEndpoint overrides the userinfo_endpoint from the discovery
+	// document.
+	UserinfoEndpoint string `json:"userinfoEndpoint,omitempty" yaml:"userinfoEndpoint,omitempty"`
+
+	// TenantClaim names the claim that identifies the upstream tenant or
+	// workspace, e.g. "hd" for Google Workspace or "tid" for Microsoft Entra.
+	TenantClaim string `json:"tenantClaim,omitempty" yaml:"tenantClaim,omitempty"`
+
+	// DuplicateSubjects selects the policy applied when another connection in
+	// the namespace is already connected to the same subject. Defaults to allow.
+	DuplicateSubjects *OidcDuplicateSubjectPolicy `json:"duplicateSubjects,omitempty" yaml:"duplicateSubjects,omitempty"`
+
+	// JwksCacheTtl is how long the discovery document and JWKS are cached.
+	// Defaults to 1h. A key id that isn't in the cached set always triggers a
+	// re-fetch, so provider key rotation doesn't wait for the TTL.
+	JwksCacheTtl *common.HumanDuration `json:"jwksCacheTtl,omitempty" yaml:"jwksCacheTtl,omitempty"`
+}
+
+func (o *AuthOauth2Oidc) GetDiscoveryUrlOrDefault() string {
+	if o == nil {
+		return ""
+	}
+	if o.DiscoveryUrl != "" {
+		return o.DiscoveryUrl
+	}
+	return strings.TrimSuffix(o.Issuer, "/") + "/.well-known/openid-configuration"
+}
+
+func (o *AuthOauth2Oidc) GetDuplicateSubjectsOrDefault() OidcDuplicateSubjectPolicy {
+	if o == nil || o.DuplicateSubjects == nil {
+		return OidcDuplicateSubjectAllow
+	}
+	return *o.DuplicateSubjects
+}
+
+func (o *AuthOauth2Oidc) GetJwksCacheTtlOrDefault() time.Duration {
+	if o == nil || o.JwksCacheTtl == nil {
+		return defaultOidcJwksCacheTtl
+	}
+	return o.JwksCacheTtl.Duration
+}
+
+func (o *AuthOauth2Oidc) Clone() *AuthOauth2Oidc {
+	if o == nil {
+		return nil
+	}
+
+	clone := *o
+
+	if o.DuplicateSubjects != nil {
+		policy := *o.DuplicateSubjects
+		clone.DuplicateSubjects = &policy
+	}
+
+	if o.JwksCacheTtl != nil {
+		ttl := *o.JwksCacheTtl
+		clone.JwksCacheTtl = &ttl
+	}
+
+	return &clone
+}
+
+// validate checks the OIDC block. scopes are the connector's declared scopes;
+// providers only return an id_token when the openid scope is requested.
+func (o *AuthOauth2Oidc) validate(vc *common.ValidationContext, scopes []Scope) error {
+	if o == nil {
+		return nil
+	}
+
+	result := &multierror.Error{}
+
+	if o.Issuer == "" {
+		result = multierror.Append(result, vc.NewErrorfForField("issuer", "is required"))
+	} else if u, err := url.Parse(o.Issuer); err != nil || u.Scheme == "" || u.Host == "" {
+		result = multierror.Append(result, vc.NewErrorfForField("issuer", "must be an absolute URL"))
+	}
+
+	switch o.GetDuplicateSubjectsOrDefault() {
+	case OidcDuplicateSubjectAllow, OidcDuplicateSubjectReject, OidcDuplicateSubjectMerge:
+	default:
+		result = multierror.Append(result, vc.NewErrorfForField("duplicate_subjects",
+			"%q is not a valid policy; must be %q, %q, or %q",
+			*o.DuplicateSubjects,
+			OidcDuplicateSubjectAllow, OidcDuplicateSubjectReject, OidcDuplicateSubjectMerge,
+		))
+	}
+
+	if o.JwksCacheTtl != nil && o.JwksCacheTtl.Duration <= 0 {
+		result = multierror.Append(result, vc.NewErrorfForField("jwks_cache_ttl", "must be positive"))
+	}
+
+	hasOpenId := false
+	for i := range scopes {
+		if scopes[i].Id == "openid" {
+			hasOpenId = true
+			break
+		}
+	}
+	if !hasOpenId {
+		result = multierror.Append(result, vc.NewErrorf("requires the %q scope", "openid"))
+	}
+
+	return result.ErrorOrNil()
+}
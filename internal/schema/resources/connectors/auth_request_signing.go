@@ -0,0 +1,241 @@
+package connectors
+
+import (
+	"strings"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/rmorlok/authproxy/internal/aptmpl"
+	"github.com/rmorlok/authproxy/internal/schema/common"
+)
+
+// HmacAlgorithm identifies the hash function used for a request-signing HMAC.
+type HmacAlgorithm string
+
+const (
+	HmacAlgorithmSHA1   = HmacAlgorithm("sha1")
+	HmacAlgorithmSHA256 = HmacAlgorithm("sha256")
+	HmacAlgorithmSHA512 = HmacAlgorithm("sha512")
+)
+
+// HmacSignatureEncoding identifies how the raw HMAC bytes are encoded in the signature header.
+type HmacSignatureEncoding string
+
+const (
+	HmacSignatureEncodingHex    = HmacSignatureEncoding("hex")
+	HmacSignatureEncodingBase64 = HmacSignatureEncoding("base64")
+)
+
+// HmacTimestampFormat identifies how the signing timestamp is rendered into the canonical string and timestamp
+// header.
+type HmacTimestampFormat string
+
+const (
+	HmacTimestampFormatUnix       = HmacTimestampFormat("unix")
+	HmacTimestampFormatUnixMillis = HmacTimestampFormat("unix-millis")
+	HmacTimestampFormatRFC3339    = HmacTimestampFormat("rfc3339")
+)
+
+// HmacCanonicalStringVariables are the top-level variables available to an HMAC canonical string template, in
+// addition to `headers.<lowercase-name>` for any request header.
+//
+//   - method: upper-case HTTP method
+//   - host: request host, including a non-default port
+//   - path: escaped URL path (`/` when empty)
+//   - query: raw query string without the leading `?`
+//   - timestamp: the signing time, formatted per timestampFormat
+//   - body_sha256: hex SHA-256 of the request body, or `UNSIGNED-PAYLOAD` when the body is streamed
+//   - key_id: the key id captured during setup
+var HmacCanonicalStringVariables = []string{"method", "host", "path", "query", "timestamp", "body_sha256", "key_id"}
+
+// RequestSigningSigV4 configures AWS Signature Version 4 signing. Access key id, secret access key and optional
+// session token are always collected from the user during setup.
+type RequestSigningSigV4 struct {
+	// Service is the AWS signing name of the service, e.g. `execute-api` or `s3`. Required.
+	Service string `json:"service" yaml:"service"`
+
+	// Region is the AWS region to sign for. When omitted the region is collected from the user during setup along
+	// with the credentials.
+	Region string `json:"region,omitempty" yaml:"region,omitempty"`
+}
+
+// RequestSigningHmac configures a generic HMAC signature over a connector-defined canonical string.
+type RequestSigningHmac struct {
+	// Algorithm is the HMAC hash function. Defaults to sha256.
+	Algorithm HmacAlgorithm `json:"algorithm,omitempty" yaml:"algorithm,omitempty"`
+
+	// CanonicalString is a mustache template rendered per request to produce the string that is signed. See
+	// HmacCanonicalStringVariables for the available variables. Values are not HTML-escaped. Required.
+	CanonicalString string `json:"canonicalString" yaml:"canonicalString"`
+
+	// SignatureHeader is the header that carries the signature. Required.
+	SignatureHeader string `json:"signatureHeader" yaml:"signatureHeader"`
+
+	// SignaturePrefix is an optional literal prepended to the encoded signature, e.g. "HMAC ".
+	SignaturePrefix string `json:"signaturePrefix,omitempty" yaml:"signaturePrefix,omitempty"`
+
+	// Encoding is how the signature bytes are encoded. Defaults to hex.
+	Encoding HmacSignatureEncoding `json:"encoding,omitempty" yaml:"encoding,omitempty"`
+
+	// TimestampHeader, when set, carries the signing timestamp so the upstream can rebuild the canonical string.
+	TimestampHeader string `json:"timestampHeader,omitempty" yaml:"timestampHeader,omitempty"`
+
+	// TimestampFormat is how the signing timestamp is rendered. Defaults to unix.
+	TimestampFormat HmacTimestampFormat `json:"timestampFormat,omitempty" yaml:"timestampFormat,omitempty"`
+
+	// KeyIdHeader, when set, carries the key id captured during setup. Setting it (or referencing key_id in the
+	// canonical string) makes the key id a required setup field.
+	KeyIdHeader string `json:"keyIdHeader,omitempty" yaml:"keyIdHeader,omitempty"`
+}
+
+// UsesKeyId reports whether the connector needs a key id in addition to the secret.
+func (h *RequestSigningHmac) UsesKeyId() bool {
+	if h == nil {
+		return false
+	}
+	if h.KeyIdHeader != "" {
+		return true
+	}
+	vars, err := aptmpl.ExtractVariables(h.CanonicalString)
+	if err != nil {
+		return false
+	}
+	for _, v := range vars {
+		if v == "key_id" {
+			return true
+		}
+	}
+	return false
+}
+
+// AuthRequestSigning describes a connector whose requests are authenticated by signing each outbound request rather
+// than attaching a static credential. Exactly one of SigV4 and Hmac must be configured.
+type AuthRequestSigning struct {
+	Type  AuthType             `json:"type" yaml:"type"`
+	SigV4 *RequestSigningSigV4 `json:"sigV4,omitempty" yaml:"sigV4,omitempty"`
+	Hmac  *RequestSigningHmac  `json:"hmac,omitempty" yaml:"hmac,omitempty"`
+}
+
+func (a *AuthRequestSigning) GetType() AuthType {
+	return AuthTypeRequestSigning
+}
+
+func (a *AuthRequestSigning) Clone() AuthImpl {
+	if a == nil {
+		return nil
+	}
+
+	clone := *a
+	if a.SigV4 != nil {
+		sigV4 := *a.SigV4
+		clone.SigV4 = &sigV4
+	}
+	if a.Hmac != nil {
+		hmac := *a.Hmac
+		clone.Hmac = &hmac
+	}
+	return &clone
+}
+
+// Validate enforces the request-signing schema invariants:
+//   - exactly one of sigV4 / hmac is configured;
+//   - sigV4 declares a service;
+//   - hmac declares a canonical string that parses and only references known variables, a valid signature header,
+//     and known algorithm / encoding / timestamp format values.
+func (a *AuthRequestSigning) Validate(vc *common.ValidationContext) error {
+	if a == nil {
+		return nil
+	}
+
+	result := &multierror.Error{}
+
+	if a.SigV4 == nil && a.Hmac == nil {
+		result = multierror.Append(result, vc.NewErrorf("one of sigV4 or hmac is required"))
+		return result.ErrorOrNil()
+	}
+	if a.SigV4 != nil && a.Hmac != nil {
+		result = multierror.Append(result, vc.NewErrorf("only one of sigV4 or hmac may be configured"))
+		return result.ErrorOrNil()
+	}
+
+	if a.SigV4 != nil {
+		svc := vc.PushField("sig_v4")
+		if a.SigV4.Service == "" {
+			result = multierror.Append(result, svc.NewErrorfForField("service", "is required"))
+		}
+	}
+
+	if a.Hmac != nil {
+		hvc := vc.PushField("hmac")
+		h := a.Hmac
+
+		switch h.Algorithm {
+		case "", HmacAlgorithmSHA1, HmacAlgorithmSHA256, HmacAlgorithmSHA512:
+		default:
+			result = multierror.Append(result, hvc.NewErrorfForField("algorithm",
+				"%q is not a valid hmac algorithm; must be one of %q, %q, %q",
+				h.Algorithm, HmacAlgorithmSHA1, HmacAlgorithmSHA256, HmacAlgorithmSHA512,
+			))
+		}
+
+		switch h.Encoding {
+		case "", HmacSignatureEncodingHex, HmacSignatureEncodingBase64:
+		default:
+			result = multierror.Append(result, hvc.NewErrorfForField("encoding",
+				"%q is not a valid signature encoding; must be one of %q, %q",
+				h.Encoding, HmacSignatureEncodingHex, HmacSignatureEncodingBase64,
+			))
+		}
+
+		switch h.TimestampFormat {
+		case "", HmacTimestampFormatUnix, HmacTimestampFormatUnixMillis, HmacTimestampFormatRFC3339:
+		default:
+			result = multierror.Append(result, hvc.NewErrorfForField("timestamp_format",
+				"%q is not a valid timestamp format; must be one of %q, %q, %q",
+				h.TimestampFormat, HmacTimestampFormatUnix, HmacTimestampFormatUnixMillis, HmacTimestampFormatRFC3339,
+			))
+		}
+
+		if h.CanonicalString == "" {
+			result = multierror.Append(result, hvc.NewErrorfForField("canonical_string", "is required"))
+		} else if vars, err := aptmpl.ExtractVariables(h.CanonicalString); err != nil {
+			result = multierror.Append(result, hvc.NewErrorfForField("canonical_string", "invalid template: %s", err.Error()))
+		} else {
+			for _, v := range vars {
+				if !isHmacCanonicalStringVariable(v) {
+					result = multierror.Append(result, hvc.NewErrorfForField("canonical_string",
+						"unknown variable %q; must be one of %s or headers.<name>", v, strings.Join(HmacCanonicalStringVariables, ", "),
+					))
+				}
+			}
+		}
+
+		if h.SignatureHeader == "" {
+			result = multierror.Append(result, hvc.NewErrorfForField("signature_header", "is required"))
+		} else if !isValidHttpHeaderFieldName(h.SignatureHeader) {
+			result = multierror.Append(result, hvc.NewErrorfForField("signature_header", "%q is not a valid HTTP header name", h.SignatureHeader))
+		}
+		if h.TimestampHeader != "" && !isValidHttpHeaderFieldName(h.TimestampHeader) {
+			result = multierror.Append(result, hvc.NewErrorfForField("timestamp_header", "%q is not a valid HTTP header name", h.TimestampHeader))
+		}
+		if h.KeyIdHeader != "" && !isValidHttpHeaderFieldName(h.KeyIdHeader) {
+			result = multierror.Append(result, hvc.NewErrorfForField("key_id_header", "%q is not a valid HTTP header name", h.KeyIdHeader))
+		}
+	}
+
+	return result.ErrorOrNil()
+}
+
+func isHmacCanonicalStringVariable(v string) bool {
+	if name, ok := strings.CutPrefix(v, "headers."); ok {
+		return isValidHttpHeaderFieldName(name)
+	}
+	for _, known := range HmacCanonicalStringVariables {
+		if v == known {
+			return true
+		}
+	}
+	return false
+}
+
+var _ AuthImpl = (*AuthRequestSigning)(nil)
+var _ AuthValidator = (*AuthRequestSigning)(nil)
@@ -0,0 +1,157 @@
+package connectors
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/rmorlok/authproxy/internal/schema/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestAuthRequestSigning_Unmarshal(t *testing.T) {
+	t.Run("yaml sigv4", func(t *testing.T) {
+		var a Auth
+		require.NoError(t, yaml.Unmarshal([]byte(`
+type: request-signing
+sigV4:
+  service: execute-api
+  region: us-east-1
+`), &a))
+		rs, ok := a.Inner().(*AuthRequestSigning)
+		require.True(t, ok)
+		assert.Equal(t, AuthTypeRequestSigning, a.GetType())
+		assert.Equal(t, "execute-api", rs.SigV4.Service)
+		assert.Equal(t, "us-east-1", rs.SigV4.Region)
+		assert.Nil(t, rs.Hmac)
+	})
+
+	t.Run("json hmac", func(t *testing.T) {
+		var a Auth
+		require.NoError(t, json.Unmarshal([]byte(`{
+			"type": "request-signing",
+			"hmac": {"canonicalString": "{{method}}\n{{path}}", "signatureHeader": "X-Signature"}
+		}`), &a))
+		rs, ok := a.Inner().(*AuthRequestSigning)
+		require.True(t, ok)
+		assert.Equal(t, "X-Signature", rs.Hmac.SignatureHeader)
+		assert.Equal(t, "{{method}}\n{{path}}", rs.Hmac.CanonicalString)
+	})
+}
+
+func TestAuthRequestSigning_Clone(t *testing.T) {
+	orig := &AuthRequestSigning{
+		Type: AuthTypeRequestSigning,
+		Hmac: &RequestSigningHmac{CanonicalString: "{{method}}", SignatureHeader: "X-Signature"},
+	}
+	clone := orig.Clone().(*AuthRequestSigning)
+	clone.Hmac.SignatureHeader = "X-Other"
+	assert.Equal(t, "X-Signature", orig.Hmac.SignatureHeader)
+}
+
+func TestAuthRequestSigning_Validate(t *testing.T) {
+	tests := []struct {
+		name        string
+		auth        *AuthRequestSigning
+		wantErrSubs []string
+	}{
+		{
+			name: "nil receiver",
+			auth: nil,
+		},
+		{
+			name: "valid sigv4",
+			auth: &AuthRequestSigning{
+				Type:  AuthTypeRequestSigning,
+				SigV4: &RequestSigningSigV4{Service: "execute-api"},
+			},
+		},
+		{
+			name: "valid hmac",
+			auth: &AuthRequestSigning{
+				Type: AuthTypeRequestSigning,
+				Hmac: &RequestSigningHmac{
+					Algorithm:       HmacAlgorithmSHA512,
+					CanonicalString: "{{method}}\n{{path}}?{{query}}\n{{headers.content-type}}\n{{timestamp}}\n{{key_id}}\n{{body_sha256}}",
+					SignatureHeader: "X-Signature",
+					TimestampHeader: "X-Timestamp",
+					TimestampFormat: HmacTimestampFormatRFC3339,
+				},
+			},
+		},
+		{
+			name:        "no mode",
+			auth:        &AuthRequestSigning{Type: AuthTypeRequestSigning},
+			wantErrSubs: []string{"one of sigV4 or hmac is required"},
+		},
+		{
+			name: "both modes",
+			auth: &AuthRequestSigning{
+				Type:  AuthTypeRequestSigning,
+				SigV4: &RequestSigningSigV4{Service: "s3"},
+				Hmac:  &RequestSigningHmac{CanonicalString: "{{method}}", SignatureHeader: "X-Signature"},
+			},
+			wantErrSubs: []string{"only one of sigV4 or hmac"},
+		},
+		{
+			name: "sigv4 missing service",
+			auth: &AuthRequestSigning{
+				Type:  AuthTypeRequestSigning,
+				SigV4: &RequestSigningSigV4{},
+			},
+			wantErrSubs: []string{"sig_v4.service", "is required"},
+		},
+		{
+			name: "hmac unknown variable",
+			auth: &AuthRequestSigning{
+				Type: AuthTypeRequestSigning,
+				Hmac: &RequestSigningHmac{CanonicalString: "{{method}}\n{{secret}}", SignatureHeader: "X-Signature"},
+			},
+			wantErrSubs: []string{"hmac.canonical_string", `unknown variable "secret"`},
+		},
+		{
+			name: "hmac bad values",
+			auth: &AuthRequestSigning{
+				Type: AuthTypeRequestSigning,
+				Hmac: &RequestSigningHmac{
+					Algorithm:       "md5",
+					Encoding:        "base32",
+					TimestampFormat: "iso",
+					CanonicalString: "{{method}}",
+					SignatureHeader: "X Signature",
+				},
+			},
+			wantErrSubs: []string{"hmac.algorithm", "hmac.encoding", "hmac.timestamp_format", "hmac.signature_header"},
+		},
+		{
+			name: "hmac missing required",
+			auth: &AuthRequestSigning{
+				Type: AuthTypeRequestSigning,
+				Hmac: &RequestSigningHmac{},
+			},
+			wantErrSubs: []string{"hmac.canonical_string", "hmac.signature_header"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.auth.Validate(&common.ValidationContext{})
+			if len(tt.wantErrSubs) == 0 {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			msg := err.Error()
+			for _, sub := range tt.wantErrSubs {
+				assert.Contains(t, msg, sub)
+			}
+		})
+	}
+}
+
+func TestRequestSigningHmac_UsesKeyId(t *testing.T) {
+	assert.False(t, (&RequestSigningHmac{CanonicalString: "{{method}}"}).UsesKeyId())
+	assert.True(t, (&RequestSigningHmac{CanonicalString: "{{method}}", KeyIdHeader: "X-Key-Id"}).UsesKeyId())
+	assert.True(t, (&RequestSigningHmac{CanonicalString: "{{key_id}}:{{method}}"}).UsesKeyId())
+}
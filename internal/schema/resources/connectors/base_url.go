@@ -0,0 +1,122 @@
+package connectors
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/rmorlok/authproxy/internal/aptmpl"
+	"github.com/rmorlok/authproxy/internal/schema/common"
+)
+
+// BaseUrl is the root of a connector's upstream API. It lets callers use the path-style proxy route
+// (/connections/{id}/upstream/*path) so an unmodified SDK can be pointed at AuthProxy as its base URL; the path
+// after /upstream is resolved under the base URL's path.
+//
+// The base URL is a mustache template rendered against the connection's cfg, labels and annotations, so a connector
+// whose tenants live on their own host can declare `https://{{cfg.subdomain}}.zendesk.com/api/v2`. Templates may
+// only reference preconnect fields because the proxy can be used as soon as credentials are established.
+type BaseUrl string
+
+func (b BaseUrl) Validate(vc *common.ValidationContext) error {
+	if b == "" || aptmpl.ContainsMustache(string(b)) {
+		// Templated base URLs are checked again after rendering; there is no static shape to check here.
+		return nil
+	}
+
+	if _, err := parseBaseUrl(string(b)); err != nil {
+		return vc.NewErrorf("%s", err.Error())
+	}
+	return nil
+}
+
+// ValidateMustacheReferences checks that a templated base URL only references preconnect fields.
+func (b BaseUrl) ValidateMustacheReferences(vc *common.ValidationContext, mctx *MustacheValidationContext) error {
+	if b == "" || mctx == nil {
+		return nil
+	}
+
+	result := &multierror.Error{}
+	checkMustacheTemplate(vc, string(b), mctx.PreconnectFields, "preconnect", result)
+	return result.ErrorOrNil()
+}
+
+// IsTemplated reports whether the base URL contains a mustache template, i.e. whether Render needs the connection's
+// mustache context.
+func (b BaseUrl) IsTemplated() bool {
+	return aptmpl.ContainsMustache(string(b))
+}
+
+// Render returns the base URL with its template rendered against data. Values are path-escaped before they are
+// substituted, so a tenant-supplied value can fill in a host label or path segment but cannot introduce a `/`, `?`
+// or `#` that would move the request to a different host or path.
+func (b BaseUrl) Render(data map[string]any) (*url.URL, error) {
+	if b == "" {
+		return nil, nil
+	}
+
+	raw := string(b)
+	if b.IsTemplated() {
+		r, err := aptmpl.RenderMustacheRaw(raw, pathEscapeValues(data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to render base url %q: %w", raw, err)
+		}
+		raw = r
+	}
+
+	u, err := parseBaseUrl(raw)
+	if err != nil {
+		return nil, fmt.Errorf("base url %q rendered to invalid url %q: %w", string(b), raw, err)
+	}
+	return u, nil
+}
+
+func parseBaseUrl(raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("base url is not a valid url: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("base url scheme must be http or https")
+	}
+	if u.User != nil {
+		return nil, fmt.Errorf("base url must not include credentials")
+	}
+	if u.RawQuery != "" || u.ForceQuery || u.Fragment != "" {
+		return nil, fmt.Errorf("base url must not include a query or fragment")
+	}
+	if strings.Contains(u.Hostname(), "*") {
+		return nil, fmt.Errorf("base url host must not be a wildcard")
+	}
+	if err := validateHostPattern(u.Host); err != nil {
+		return nil, fmt.Errorf("base url has an invalid host: %w", err)
+	}
+	return u, nil
+}
+
+// pathEscapeValues returns a copy of data with every string leaf path-escaped.
+func pathEscapeValues(data map[string]any) map[string]any {
+	if data == nil {
+		return nil
+	}
+
+	out := make(map[string]any, len(data))
+	for k, v := range data {
+		switch tv := v.(type) {
+		case string:
+			out[k] = url.PathEscape(tv)
+		case map[string]any:
+			out[k] = pathEscapeValues(tv)
+		case map[string]string:
+			m := make(map[string]any, len(tv))
+			for mk, mv := range tv {
+				m[mk] = url.PathEscape(mv)
+			}
+			out[k] = m
+		default:
+			out[k] = v
+		}
+	}
+	return out
+}
@@ -76,15 +76,49 @@ type Connector struct {
 	// and functions available to connector-authored predicates and transforms.
 	Javascript string `json:"javascript,omitempty" yaml:"javascript,omitempty"`
 
+	// JavascriptBudget overrides the global limits on how long, how deep and how large this connector's JavaScript
+	// may run. Unset fields inherit the global budget.
+	JavascriptBudget *common.JavascriptBudget `json:"javascriptBudget,omitempty" yaml:"javascriptBudget,omitempty"`
+
 	// Migrations are optional connector-authored hooks used to migrate an
 	// existing connection's stored configuration, labels, and annotations
 	// between connector versions.
 	Migrations *Migrations `json:"migrations,omitempty" yaml:"migrations,omitempty"`
 
+	// AllowedUpstreams restricts which upstream URLs the proxy will forward this connector's credentials to. When
+	// empty, any URL is allowed. See AllowedUpstream for the matching rules.
+	AllowedUpstreams AllowedUpstreams `json:"allowedUpstreams,omitempty" yaml:"allowedUpstreams,omitempty"`
+
+	// BaseUrl is the root of the connector's upstream API, used by the path-style proxy route so SDKs can be pointed
+	// at AuthProxy without setting a per-request upstream URL. See BaseUrl for templating rules.
+	BaseUrl BaseUrl `json:"baseUrl,omitempty" yaml:"baseUrl,omitempty"`
+
+	// Transport configures the connection-level transport for outbound calls, such as a TLS client certificate for
+	// upstreams that require mutual TLS. It composes with Auth.
+	Transport *Transport `json:"transport,omitempty" yaml:"transport,omitempty"`
+
 	// RateLimiting configures how 429 rate limiting responses from the 3rd party are handled.
 	// If unset, default behavior is enabled (parse Retry-After header, 60s default backoff).
 	RateLimiting *RateLimiting `json:"rateLimiting,omitempty" yaml:"rateLimiting,omitempty"`
 
+	// RetryPolicy configures retries of transient upstream failures by the proxy. If unset, requests are only
+	// replayed once after recovering from a 401.
+	RetryPolicy *RetryPolicy `json:"retryPolicy,omitempty" yaml:"retryPolicy,omitempty"`
+
+	// ResponseCache opts proxied GET and HEAD requests into a per-connection response cache. If unset, responses are
+	// never cached.
+	ResponseCache *ResponseCache `json:"responseCache,omitempty" yaml:"responseCache,omitempty"`
+
+	// Pagination declares how list endpoints page their results, so proxied requests that ask to be paginated can
+	// be followed across pages.
+	Pagination PaginationRules `json:"pagination,omitempty" yaml:"pagination,omitempty"`
+
+	// RequestTransform rewrites wrapped proxy requests before they are sent upstream.
+	RequestTransform ProxyTransforms `json:"requestTransform,omitempty" yaml:"requestTransform,omitempty"`
+
+	// ResponseTransform rewrites wrapped proxy responses before they reach the caller or the full request log.
+	ResponseTransform ProxyTransforms `json:"responseTransform,omitempty" yaml:"responseTransform,omitempty"`
+
 	// Probes are a list of probes to run against connections of this connector type to validation the connection.
 	Probes []Probe `json:"probes,omitempty" yaml:"probes,omitempty"`
 
@@ -115,10 +149,26 @@ func (c *Connector) Clone() *Connector {
 		clone.Auth = c.Auth.CloneValue()
 	}
 
+	clone.JavascriptBudget = c.JavascriptBudget.Clone()
+
+	clone.AllowedUpstreams = c.AllowedUpstreams.Clone()
+
+	clone.Transport = c.Transport.Clone()
+
 	if c.RateLimiting != nil {
 		clone.RateLimiting = c.RateLimiting.Clone()
 	}
 
+	clone.RetryPolicy = c.RetryPolicy.Clone()
+
+	clone.ResponseCache = c.ResponseCache.Clone()
+
+	clone.Pagination = c.Pagination.Clone()
+
+	clone.RequestTransform = c.RequestTransform.Clone()
+
+	clone.ResponseTransform = c.ResponseTransform.Clone()
+
 	if c.Migrations != nil {
 		clone.Migrations = c.Migrations.Clone()
 	}
@@ -160,12 +210,50 @@ func (c *Connector) Validate(vc *common.ValidationContext) error {
 		}
 	}
 
+	if err := c.JavascriptBudget.Validate(vc.PushField("javascript_budget")); err != nil {
+		result = multierror.Append(result, err)
+	}
+
+	if err := c.AllowedUpstreams.Validate(vc.PushField("allowed_upstreams")); err != nil {
+		result = multierror.Append(result, err)
+	}
+
+	if err := c.BaseUrl.Validate(vc.PushField("base_url")); err != nil {
+		result = multierror.Append(result, err)
+	}
+
+	if c.Transport != nil {
+		if err := c.Transport.Validate(vc.PushField("transport")); err != nil {
+			result = multierror.Append(result, err)
+		}
+	}
+
 	if c.RateLimiting != nil {
 		if err := c.RateLimiting.Validate(vc.PushField("rate_limiting")); err != nil {
 			result = multierror.Append(result, err)
 		}
 	}
 
+	if err := c.RetryPolicy.Validate(vc.PushField("retry_policy")); err != nil {
+		result = multierror.Append(result, err)
+	}
+
+	if err := c.ResponseCache.Validate(vc.PushField("response_cache")); err != nil {
+		result = multierror.Append(result, err)
+	}
+
+	if err := c.Pagination.Validate(vc.PushField("pagination")); err != nil {
+		result = multierror.Append(result, err)
+	}
+
+	if err := c.RequestTransform.Validate(vc.PushField("request_transform")); err != nil {
+		result = multierror.Append(result, err)
+	}
+
+	if err := c.ResponseTransform.Validate(vc.PushField("response_transform")); err != nil {
+		result = multierror.Append(result, err)
+	}
+
 	if c.Migrations != nil {
 		if err := c.Migrations.Validate(vc.PushField("migrations")); err != nil {
 			result = multierror.Append(result, err)
@@ -17,7 +17,9 @@ const cfgPrefix = "cfg."
 // validateMustacheReferences orchestrates per-field mustache cross-validation across
 // the connector. It computes the cfg.X field sets available at each lifecycle point
 // and delegates to the validators that live next to the templated fields themselves
-// (AuthOAuth2.ValidateMustacheReferences, DataSourceProxyRequest.ValidateMustacheReferences).
+// (AuthOAuth2.ValidateMustacheReferences, AllowedUpstreams.ValidateMustacheReferences,
+// BaseUrl.ValidateMustacheReferences, Transport.ValidateMustacheReferences,
+// DataSourceProxyRequest.ValidateMustacheReferences).
 //
 // {{labels.X}} and {{annotations.X}} references are not validated since those are set
 // freely on each connection and are not constrained by the connector definition.
@@ -35,6 +37,18 @@ func (c *Connector) validateMustacheReferences(vc *common.ValidationContext) err
 		}
 	}
 
+	if err := c.AllowedUpstreams.ValidateMustacheReferences(vc.PushField("allowed_upstreams"), mctx); err != nil {
+		result = multierror.Append(result, err)
+	}
+
+	if err := c.BaseUrl.ValidateMustacheReferences(vc.PushField("base_url"), mctx); err != nil {
+		result = multierror.Append(result, err)
+	}
+
+	if err := c.Transport.ValidateMustacheReferences(vc.PushField("transport"), mctx); err != nil {
+		result = multierror.Append(result, err)
+	}
+
 	if c.SetupFlow != nil && c.SetupFlow.Configure != nil {
 		for stepIdx, step := range c.SetupFlow.Configure.Steps {
 			if len(step.DataSources) == 0 {
@@ -0,0 +1,206 @@
+package connectors
+
+import (
+	"github.com/hashicorp/go-multierror"
+	"github.com/rmorlok/authproxy/internal/apjs"
+	"github.com/rmorlok/authproxy/internal/schema/common"
+	"github.com/rmorlok/authproxy/internal/schema/resources/rate_limit"
+)
+
+// PaginationStrategy is how the next page of a paginated upstream response is requested.
+type PaginationStrategy string
+
+const (
+	// PaginationStrategyLink follows the rel="next" URL of the RFC 8288 Link response header.
+	PaginationStrategyLink PaginationStrategy = "link"
+
+	// PaginationStrategyCursor sends a cursor read from the response body in a query parameter.
+	PaginationStrategyCursor PaginationStrategy = "cursor"
+
+	// PaginationStrategyOffset advances an offset query parameter by the number of items returned.
+	PaginationStrategyOffset PaginationStrategy = "offset"
+
+	// PaginationStrategyPageToken is a cursor named the way Google-style APIs name it: nextPageToken in the body,
+	// sent back as the pageToken query parameter.
+	PaginationStrategyPageToken PaginationStrategy = "page_token"
+)
+
+const (
+	DefaultPaginationPageTokenNext  = "body.nextPageToken"
+	DefaultPaginationPageTokenParam = "pageToken"
+	DefaultPaginationOffsetParam    = "offset"
+	DefaultPaginationLimitParam     = "limit"
+	DefaultPaginationPageSize       = 100
+)
+
+func (s PaginationStrategy) IsValid() bool {
+	switch s {
+	case PaginationStrategyLink, PaginationStrategyCursor, PaginationStrategyOffset, PaginationStrategyPageToken:
+		return true
+	}
+	return false
+}
+
+// PaginationRule declares how a list endpoint pages its results, so the proxy can follow the pages of a request that
+// asks to be paginated. JavaScript expressions run with the connector's javascript library, the parsed JSON response
+// body as `body` and the response headers, keyed by lower-cased name, as `headers`.
+type PaginationRule struct {
+	// PathMatch restricts the rule to a path on the upstream URL. Omit to match every path.
+	PathMatch *rate_limit.PathMatch `json:"pathMatch,omitempty" yaml:"pathMatch,omitempty"`
+
+	// Strategy is how the next page is requested.
+	Strategy PaginationStrategy `json:"strategy" yaml:"strategy"`
+
+	// Items is a JavaScript expression returning the array of items on a page, e.g. `body.data`. Omit when the body
+	// is itself the array.
+	Items string `json:"items,omitempty" yaml:"items,omitempty"`
+
+	// Next is a JavaScript expression returning the cursor for the next page, e.g. `body.meta.next_cursor`. A null,
+	// undefined or empty result ends pagination. Required for the cursor strategy; defaults to `body.nextPageToken`
+	// for page_token.
+	Next string `json:"next,omitempty" yaml:"next,omitempty"`
+
+	// Param is the query parameter the cursor is sent in. Required for the cursor strategy; defaults to `pageToken`
+	// for page_token.
+	Param string `json:"param,omitempty" yaml:"param,omitempty"`
+
+	// OffsetParam is the query parameter holding the offset for the offset strategy. Defaults to `offset`.
+	OffsetParam string `json:"offsetParam,omitempty" yaml:"offsetParam,omitempty"`
+
+	// LimitParam is the query parameter holding the page size for the offset strategy. Defaults to `limit`.
+	LimitParam string `json:"limitParam,omitempty" yaml:"limitParam,omitempty"`
+
+	// PageSize is the limit sent with the offset strategy when the request doesn't set one. Defaults to 100.
+	PageSize int `json:"pageSize,omitempty" yaml:"pageSize,omitempty"`
+}
+
+func (r *PaginationRule) Validate(vc *common.ValidationContext) error {
+	result := &multierror.Error{}
+
+	if !r.Strategy.IsValid() {
+		result = multierror.Append(result, vc.NewErrorfForField("strategy", "invalid pagination strategy %q", r.Strategy))
+	}
+
+	if err := r.PathMatch.Validate(vc.PushField("path_match")); err != nil {
+		result = multierror.Append(result, err)
+	}
+
+	usesCursor := r.Strategy == PaginationStrategyCursor || r.Strategy == PaginationStrategyPageToken
+	if r.Strategy == PaginationStrategyCursor {
+		if r.Next == "" {
+			result = multierror.Append(result, vc.NewErrorForField("next", "required for the cursor strategy"))
+		}
+		if r.Param == "" {
+			result = multierror.Append(result, vc.NewErrorForField("param", "required for the cursor strategy"))
+		}
+	}
+	if !usesCursor && (r.Next != "" || r.Param != "") {
+		result = multierror.Append(result, vc.NewErrorForField("next", "only used with the cursor and page_token strategies"))
+	}
+
+	if r.Strategy != PaginationStrategyOffset && (r.OffsetParam != "" || r.LimitParam != "" || r.PageSize != 0) {
+		result = multierror.Append(result, vc.NewErrorForField("page_size", "offset_param, limit_param and page_size are only used with the offset strategy"))
+	}
+	if r.PageSize < 0 {
+		result = multierror.Append(result, vc.NewErrorForField("page_size", "must not be negative"))
+	}
+
+	if r.Items != "" {
+		if err := apjs.ValidateExpressionSyntax(r.Items); err != nil {
+			result = multierror.Append(result, vc.NewErrorfForField("items", "invalid javascript expression: %v", err))
+		}
+	}
+	if r.Next != "" {
+		if err := apjs.ValidateExpressionSyntax(r.Next); err != nil {
+			result = multierror.Append(result, vc.NewErrorfForField("next", "invalid javascript expression: %v", err))
+		}
+	}
+
+	return result.ErrorOrNil()
+}
+
+// GetNext returns the expression producing the next cursor.
+func (r *PaginationRule) GetNext() string {
+	if r.Next == "" && r.Strategy == PaginationStrategyPageToken {
+		return DefaultPaginationPageTokenNext
+	}
+	return r.Next
+}
+
+// GetParam returns the query parameter the cursor is sent in.
+func (r *PaginationRule) GetParam() string {
+	if r.Param == "" && r.Strategy == PaginationStrategyPageToken {
+		return DefaultPaginationPageTokenParam
+	}
+	return r.Param
+}
+
+// GetOffsetParam returns the query parameter holding the offset.
+func (r *PaginationRule) GetOffsetParam() string {
+	if r.OffsetParam == "" {
+		return DefaultPaginationOffsetParam
+	}
+	return r.OffsetParam
+}
+
+// GetLimitParam returns the query parameter holding the page size.
+func (r *PaginationRule) GetLimitParam() string {
+	if r.LimitParam == "" {
+		return DefaultPaginationLimitParam
+	}
+	return r.LimitParam
+}
+
+// GetPageSize returns the limit sent when the request doesn't set one.
+func (r *PaginationRule) GetPageSize() int {
+	if r.PageSize <= 0 {
+		return DefaultPaginationPageSize
+	}
+	return r.PageSize
+}
+
+// PaginationRules are a connector's pagination declarations. The first rule whose path matches a request applies.
+type PaginationRules []PaginationRule
+
+func (rs PaginationRules) Clone() PaginationRules {
+	if rs == nil {
+		return nil
+	}
+
+	clone := make(PaginationRules, len(rs))
+	for i, rule := range rs {
+		clone[i] = rule
+		if rule.PathMatch != nil {
+			pm := *rule.PathMatch
+			clone[i].PathMatch = &pm
+		}
+	}
+	return clone
+}
+
+func (rs PaginationRules) Validate(vc *common.ValidationContext) error {
+	result := &multierror.Error{}
+
+	for i := range rs {
+		if err := rs[i].Validate(vc.PushIndex(i)); err != nil {
+			result = multierror.Append(result, err)
+		}
+	}
+
+	return result.ErrorOrNil()
+}
+
+// Match returns the first rule whose path matches p, or nil if none does. Rules whose path expression fails to
+// evaluate are skipped.
+func (rs PaginationRules) Match(p string) *PaginationRule {
+	for i := range rs {
+		rule := &rs[i]
+		if rule.PathMatch == nil {
+			return rule
+		}
+		if ok, err := rule.PathMatch.Matches(p); err == nil && ok {
+			return rule
+		}
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package connectors
+
+import (
+	"testing"
+
+	"github.com/rmorlok/authproxy/internal/schema/common"
+	"github.com/rmorlok/authproxy/internal/schema/resources/rate_limit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestPagination_Unmarshal(t *testing.T) {
+	var c Connector
+	require.NoError(t, yaml.Unmarshal([]byte(`
+pagination:
+  - pathMatch:
+      kind: prefix
+      value: /v1/tickets
+    strategy: cursor
+    items: body.data
+    next: body.meta.next_cursor
+    param: cursor
+  - strategy: page_token
+`), &c))
+	require.Len(t, c.Pagination, 2)
+
+	cursor := c.Pagination.Match("/v1/tickets")
+	require.NotNil(t, cursor)
+	assert.Equal(t, PaginationStrategyCursor, cursor.Strategy)
+	assert.Equal(t, "body.data", cursor.Items)
+	assert.Equal(t, "body.meta.next_cursor", cursor.GetNext())
+	assert.Equal(t, "cursor", cursor.GetParam())
+
+	token := c.Pagination.Match("/v1/files")
+	require.NotNil(t, token)
+	assert.Equal(t, DefaultPaginationPageTokenNext, token.GetNext())
+	assert.Equal(t, DefaultPaginationPageTokenParam, token.GetParam())
+}
+
+func TestPagination_Defaults(t *testing.T) {
+	var none PaginationRules
+	assert.Nil(t, none.Match("/v1/users"))
+
+	r := &PaginationRule{Strategy: PaginationStrategyOffset}
+	assert.Equal(t, DefaultPaginationOffsetParam, r.GetOffsetParam())
+	assert.Equal(t, DefaultPaginationLimitParam, r.GetLimitParam())
+	assert.Equal(t, DefaultPaginationPageSize, r.GetPageSize())
+}
+
+func TestPagination_Validate(t *testing.T) {
+	tests := []struct {
+		name        string
+		rules       PaginationRules
+		wantErrSubs []string
+	}{
+		{
+			name: "nil",
+		},
+		{
+			name:  "valid link",
+			rules: PaginationRules{{Strategy: PaginationStrategyLink, Items: "body.items"}},
+		},
+		{
+			name:  "valid offset",
+			rules: PaginationRules{{Strategy: PaginationStrategyOffset, LimitParam: "per_page", PageSize: 25}},
+		},
+		{
+			name:        "unknown strategy",
+			rules:       PaginationRules{{Strategy: "page_number"}},
+			wantErrSubs: []string{"[0].strategy", "invalid pagination strategy"},
+		},
+		{
+			name:        "cursor without next",
+			rules:       PaginationRules{{Strategy: PaginationStrategyCursor, Param: "cursor"}},
+			wantErrSubs: []string{"[0].next", "required for the cursor strategy"},
+		},
+		{
+			name:        "cursor fields on link",
+			rules:       PaginationRules{{Strategy: PaginationStrategyLink, Next: "body.next"}},
+			wantErrSubs: []string{"[0].next", "only used with the cursor and page_token strategies"},
+		},
+		{
+			name:        "offset fields on cursor",
+			rules:       PaginationRules{{Strategy: PaginationStrategyCursor, Next: "body.next", Param: "cursor", PageSize: 10}},
+			wantErrSubs: []string{"[0].page_size", "only used with the offset strategy"},
+		},
+		{
+			name:        "bad items expression",
+			rules:       PaginationRules{{Strategy: PaginationStrategyLink, Items: "body.("}},
+			wantErrSubs: []string{"[0].items", "invalid javascript expression"},
+		},
+		{
+			name: "bad path match",
+			rules: PaginationRules{{
+				Strategy:  PaginationStrategyLink,
+				PathMatch: &rate_limit.PathMatch{Kind: rate_limit.PathMatchKindRegex, Value: "("},
+			}},
+			wantErrSubs: []string{"[0].path_match.value", "invalid regex"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.rules.Validate(&common.ValidationContext{})
+			if len(tt.wantErrSubs) == 0 {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			msg := err.Error()
+			for _, sub := range tt.wantErrSubs {
+				assert.Contains(t, msg, sub)
+			}
+		})
+	}
+}
+
+func TestPagination_Clone(t *testing.T) {
+	orig := PaginationRules{{
+		Strategy:  PaginationStrategyLink,
+		PathMatch: &rate_limit.PathMatch{Kind: rate_limit.PathMatchKindPrefix, Value: "/v1/"},
+	}}
+	clone := orig.Clone()
+	require.Equal(t, orig, clone)
+
+	clone[0].PathMatch.Value = "/v2/"
+	assert.Equal(t, "/v1/", orig[0].PathMatch.Value)
+	assert.Nil(t, PaginationRules(nil).Clone())
+}
@@ -0,0 +1,121 @@
+package connectors
+
+import (
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/rmorlok/authproxy/internal/apjs"
+	"github.com/rmorlok/authproxy/internal/schema/common"
+	"github.com/rmorlok/authproxy/internal/schema/resources/rate_limit"
+)
+
+const (
+	// DefaultProxyTransformTimeout bounds a transform that doesn't declare its own timeout.
+	DefaultProxyTransformTimeout = 50 * time.Millisecond
+
+	// MaxProxyTransformTimeout is the longest timeout a transform may declare. Transforms run inline on every matching
+	// proxied request.
+	MaxProxyTransformTimeout = time.Second
+)
+
+// ProxyTransform is a JavaScript hook that rewrites buffered proxy traffic for the paths it declares. The expression
+// runs with the connector's javascript library, `cfg`, `labels` and `annotations`.
+//
+// A request transform sees `request` as `{method, url, path, headers, bodyJson}`. A response transform sees the same
+// `request`, less its body, and `response` as `{statusCode, headers, bodyJson}`. Either returns a patch: `headers`
+// entries are set on the message, or removed when null; `bodyJson` replaces the body; a response transform may also
+// set `statusCode`. Returning null or undefined leaves the message unchanged.
+//
+// Transforms only apply to the wrapped proxy; raw and streaming requests are passed through unchanged.
+type ProxyTransform struct {
+	// PathMatch is the upstream path the transform applies to.
+	PathMatch *rate_limit.PathMatch `json:"pathMatch" yaml:"pathMatch"`
+
+	// Javascript is the expression returning the patch.
+	Javascript string `json:"javascript" yaml:"javascript"`
+
+	// Timeout bounds how long the expression may run before the request fails. Defaults to 50ms; at most 1s.
+	Timeout *common.HumanDuration `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+}
+
+func (t *ProxyTransform) Validate(vc *common.ValidationContext) error {
+	result := &multierror.Error{}
+
+	if t.PathMatch == nil {
+		result = multierror.Append(result, vc.NewErrorForField("path_match", "transforms must declare the paths they apply to"))
+	} else if err := t.PathMatch.Validate(vc.PushField("path_match")); err != nil {
+		result = multierror.Append(result, err)
+	}
+
+	if t.Javascript == "" {
+		result = multierror.Append(result, vc.NewErrorForField("javascript", "transform javascript is required"))
+	} else if err := apjs.ValidateExpressionSyntax(t.Javascript); err != nil {
+		result = multierror.Append(result, vc.NewErrorfForField("javascript", "invalid transform javascript expression: %v", err))
+	}
+
+	if t.Timeout != nil && (t.Timeout.Duration <= 0 || t.Timeout.Duration > MaxProxyTransformTimeout) {
+		result = multierror.Append(result, vc.NewErrorfForField("timeout", "must be positive and at most %s", MaxProxyTransformTimeout))
+	}
+
+	return result.ErrorOrNil()
+}
+
+// GetTimeout returns how long the expression may run.
+func (t *ProxyTransform) GetTimeout() time.Duration {
+	if t.Timeout == nil || t.Timeout.Duration <= 0 {
+		return DefaultProxyTransformTimeout
+	}
+	return t.Timeout.Duration
+}
+
+// ProxyTransforms are a connector's request or response transforms. Every transform whose path matches a request
+// applies, in the order declared.
+type ProxyTransforms []ProxyTransform
+
+func (ts ProxyTransforms) Clone() ProxyTransforms {
+	if ts == nil {
+		return nil
+	}
+
+	clone := make(ProxyTransforms, len(ts))
+	for i, t := range ts {
+		clone[i] = t
+		if t.PathMatch != nil {
+			pm := *t.PathMatch
+			clone[i].PathMatch = &pm
+		}
+		if t.Timeout != nil {
+			d := *t.Timeout
+			clone[i].Timeout = &d
+		}
+	}
+	return clone
+}
+
+func (ts ProxyTransforms) Validate(vc *common.ValidationContext) error {
+	result := &multierror.Error{}
+
+	for i := range ts {
+		if err := ts[i].Validate(vc.PushIndex(i)); err != nil {
+			result = multierror.Append(result, err)
+		}
+	}
+
+	return result.ErrorOrNil()
+}
+
+// Matching returns the transforms whose path matches p, in order. Transforms whose path expression fails to evaluate
+// are skipped.
+func (ts ProxyTransforms) Matching(p string) []*ProxyTransform {
+	var matched []*ProxyTransform
+	for i := range ts {
+		t := &ts[i]
+		if t.PathMatch == nil {
+			continue
+		}
+		if ok, err := t.PathMatch.Matches(p); err == nil && ok {
+			matched = append(matched, t)
+		}
+	}
+	return matched
+}
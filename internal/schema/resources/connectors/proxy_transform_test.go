@@ -0,0 +1,104 @@
+package connectors
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rmorlok/authproxy/internal/schema/common"
+	"github.com/rmorlok/authproxy/internal/schema/resources/rate_limit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProxyTransforms_Validate(t *testing.T) {
+	prefix := &rate_limit.PathMatch{Kind: rate_limit.PathMatchKindPrefix, Value: "/v1/"}
+
+	tests := []struct {
+		name        string
+		transforms  ProxyTransforms
+		wantErrSubs []string
+	}{
+		{
+			name: "nil",
+		},
+		{
+			name: "valid",
+			transforms: ProxyTransforms{{
+				PathMatch:  prefix,
+				Javascript: `({headers: {"X-Tenant": cfg.tenant}})`,
+				Timeout:    &common.HumanDuration{Duration: 100 * time.Millisecond},
+			}},
+		},
+		{
+			name:        "missing path match",
+			transforms:  ProxyTransforms{{Javascript: `null`}},
+			wantErrSubs: []string{"[0].path_match", "must declare the paths"},
+		},
+		{
+			name:        "missing javascript",
+			transforms:  ProxyTransforms{{PathMatch: prefix}},
+			wantErrSubs: []string{"[0].javascript", "required"},
+		},
+		{
+			name:        "bad javascript",
+			transforms:  ProxyTransforms{{PathMatch: prefix, Javascript: `({`}},
+			wantErrSubs: []string{"[0].javascript", "invalid transform javascript expression"},
+		},
+		{
+			name: "timeout too long",
+			transforms: ProxyTransforms{{
+				PathMatch:  prefix,
+				Javascript: `null`,
+				Timeout:    &common.HumanDuration{Duration: 2 * time.Second},
+			}},
+			wantErrSubs: []string{"[0].timeout", "at most 1s"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.transforms.Validate(&common.ValidationContext{})
+			if len(tt.wantErrSubs) == 0 {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			msg := err.Error()
+			for _, sub := range tt.wantErrSubs {
+				assert.Contains(t, msg, sub)
+			}
+		})
+	}
+}
+
+func TestProxyTransforms_Matching(t *testing.T) {
+	transforms := ProxyTransforms{
+		{PathMatch: &rate_limit.PathMatch{Kind: rate_limit.PathMatchKindPrefix, Value: "/"}, Javascript: "a"},
+		{PathMatch: &rate_limit.PathMatch{Kind: rate_limit.PathMatchKindGlob, Value: "/v1/contacts/*"}, Javascript: "b"},
+		{PathMatch: &rate_limit.PathMatch{Kind: rate_limit.PathMatchKindPrefix, Value: "/v2/"}, Javascript: "c"},
+	}
+
+	matched := transforms.Matching("/v1/contacts/42")
+	require.Len(t, matched, 2)
+	assert.Equal(t, "a", matched[0].Javascript)
+	assert.Equal(t, "b", matched[1].Javascript)
+
+	assert.Len(t, transforms.Matching("/v1/deals"), 1)
+	assert.Equal(t, DefaultProxyTransformTimeout, matched[0].GetTimeout())
+}
+
+func TestProxyTransforms_Clone(t *testing.T) {
+	orig := ProxyTransforms{{
+		PathMatch:  &rate_limit.PathMatch{Kind: rate_limit.PathMatchKindPrefix, Value: "/v1/"},
+		Javascript: "null",
+		Timeout:    &common.HumanDuration{Duration: time.Millisecond},
+	}}
+	clone := orig.Clone()
+	require.Equal(t, orig, clone)
+
+	clone[0].PathMatch.Value = "/v2/"
+	clone[0].Timeout.Duration = time.Second
+	assert.Equal(t, "/v1/", orig[0].PathMatch.Value)
+	assert.Equal(t, time.Millisecond, orig[0].Timeout.Duration)
+	assert.Nil(t, ProxyTransforms(nil).Clone())
+}
@@ -1,6 +1,8 @@
 package connectors
 
 import (
+	"math"
+	"math/rand"
 	"time"
 
 	"github.com/hashicorp/go-multierror"
@@ -41,6 +43,10 @@ type RateLimiting struct {
 	// ExponentialBackoff configures backoff behavior when no retry-after header is present and
 	// consecutive 429s are received. If unset, DefaultRetryAfter is used as a flat backoff.
 	ExponentialBackoff *ExponentialBackoff `json:"exponentialBackoff,omitempty" yaml:"exponentialBackoff,omitempty"`
+
+	// Headroom enables pre-emptive rate limiting based on the rate-limit headers the 3rd party returns on
+	// every response, so requests are held or rejected before the upstream starts returning 429s.
+	Headroom *RateLimitHeadroom `json:"headroom,omitempty" yaml:"headroom,omitempty"`
 }
 
 // ExponentialBackoff configures exponential backoff for 429 responses that lack retry-after headers.
@@ -85,6 +91,10 @@ func (r *RateLimiting) Clone() *RateLimiting {
 		clone.ExponentialBackoff = r.ExponentialBackoff.Clone()
 	}
 
+	if r.Headroom != nil {
+		clone.Headroom = r.Headroom.Clone()
+	}
+
 	return &clone
 }
 
@@ -109,6 +119,12 @@ func (r *RateLimiting) Validate(vc *common.ValidationContext) error {
 		}
 	}
 
+	if r.Headroom != nil {
+		if err := r.Headroom.Validate(vc.PushField("headroom")); err != nil {
+			result = multierror.Append(result, err)
+		}
+	}
+
 	return result.ErrorOrNil()
 }
 
@@ -136,6 +152,14 @@ func (r *RateLimiting) GetDefaultRetryAfter() time.Duration {
 	return r.DefaultRetryAfter.Duration
 }
 
+// GetHeadroom returns the headroom configuration, or nil if headroom tracking is not enabled.
+func (r *RateLimiting) GetHeadroom() *RateLimitHeadroom {
+	if r == nil {
+		return nil
+	}
+	return r.Headroom
+}
+
 func (eb *ExponentialBackoff) Clone() *ExponentialBackoff {
 	if eb == nil {
 		return nil
@@ -223,3 +247,22 @@ func (eb *ExponentialBackoff) GetJitterFraction() float64 {
 	}
 	return *eb.JitterFraction
 }
+
+// Interval returns the backoff before retry number n (zero-based):
+// InitialInterval * Multiplier^n with jitter applied, capped at MaxInterval.
+func (eb *ExponentialBackoff) Interval(n int) time.Duration {
+	backoff := float64(eb.GetInitialInterval()) * math.Pow(eb.GetMultiplier(), float64(n))
+
+	jitter := eb.GetJitterFraction()
+	if jitter > 0 {
+		jitterRange := backoff * jitter
+		backoff = backoff - jitterRange + rand.Float64()*2*jitterRange
+	}
+
+	d := time.Duration(backoff)
+	if maxInterval := eb.GetMaxInterval(); d > maxInterval || d < 0 {
+		d = maxInterval
+	}
+
+	return d
+}
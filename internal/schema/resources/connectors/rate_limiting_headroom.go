@@ -0,0 +1,195 @@
+package connectors
+
+import (
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/rmorlok/authproxy/internal/schema/common"
+)
+
+// RateLimitHeaderFormat identifies the family of rate-limit headers the 3rd party returns.
+type RateLimitHeaderFormat string
+
+const (
+	// RateLimitHeaderFormatXRateLimit reads the de-facto X-RateLimit-Remaining / X-RateLimit-Reset /
+	// X-RateLimit-Limit headers (GitHub, Twitter, Shopify, ...). Header names can be overridden.
+	RateLimitHeaderFormatXRateLimit RateLimitHeaderFormat = "x-ratelimit"
+
+	// RateLimitHeaderFormatIETF reads the IETF RateLimit / RateLimit-Policy headers
+	// (draft-ietf-httpapi-ratelimit-headers), including the earlier drafts' RateLimit-Remaining /
+	// RateLimit-Reset / RateLimit-Limit headers. Resets are always delta seconds.
+	RateLimitHeaderFormatIETF RateLimitHeaderFormat = "ietf"
+)
+
+// RateLimitResetFormat describes how the reset header of the x-ratelimit format is encoded.
+type RateLimitResetFormat string
+
+const (
+	// RateLimitResetFormatAuto treats integers that look like a Unix timestamp as one and any other
+	// integer as delta seconds. HTTP-dates and ISO 8601 timestamps are also accepted.
+	RateLimitResetFormatAuto RateLimitResetFormat = "auto"
+
+	// RateLimitResetFormatDeltaSeconds treats the reset value as seconds from now.
+	RateLimitResetFormatDeltaSeconds RateLimitResetFormat = "delta_seconds"
+
+	// RateLimitResetFormatUnixSeconds treats the reset value as a Unix timestamp in seconds.
+	RateLimitResetFormatUnixSeconds RateLimitResetFormat = "unix_seconds"
+)
+
+const (
+	DefaultRateLimitRemainingHeader = "X-RateLimit-Remaining"
+	DefaultRateLimitResetHeader     = "X-RateLimit-Reset"
+	DefaultRateLimitLimitHeader     = "X-RateLimit-Limit"
+)
+
+// RateLimitHeadroom configures pre-emptive rate limiting learned from the rate-limit headers the 3rd party
+// returns on every response. The remaining budget and reset time are tracked per connection; once the
+// remaining budget falls to Reserve, requests are delayed until the reset (if it is within MaxDelay) or
+// rejected with a 429 rather than being sent upstream to fail.
+type RateLimitHeadroom struct {
+	// Format selects the header family to parse. Defaults to x-ratelimit.
+	Format RateLimitHeaderFormat `json:"format,omitempty" yaml:"format,omitempty"`
+
+	// RemainingHeader overrides the header carrying the remaining request budget. Only valid for the
+	// x-ratelimit format. Defaults to X-RateLimit-Remaining.
+	RemainingHeader string `json:"remainingHeader,omitempty" yaml:"remainingHeader,omitempty"`
+
+	// ResetHeader overrides the header carrying when the budget resets. Only valid for the x-ratelimit
+	// format. Defaults to X-RateLimit-Reset.
+	ResetHeader string `json:"resetHeader,omitempty" yaml:"resetHeader,omitempty"`
+
+	// LimitHeader overrides the header carrying the total budget per window. Only valid for the
+	// x-ratelimit format. Defaults to X-RateLimit-Limit. The limit is informational only.
+	LimitHeader string `json:"limitHeader,omitempty" yaml:"limitHeader,omitempty"`
+
+	// ResetFormat describes how the reset header is encoded. Only valid for the x-ratelimit format.
+	// Defaults to auto.
+	ResetFormat RateLimitResetFormat `json:"resetFormat,omitempty" yaml:"resetFormat,omitempty"`
+
+	// Reserve is the portion of the remaining budget that is never spent, leaving room for other consumers
+	// of the same upstream credentials. Defaults to 0.
+	Reserve *int64 `json:"reserve,omitempty" yaml:"reserve,omitempty"`
+
+	// MaxDelay is the longest a request is held waiting for the budget to reset. Requests that would have
+	// to wait longer are rejected with a 429. Defaults to 0, rejecting immediately.
+	MaxDelay *common.HumanDuration `json:"maxDelay,omitempty" yaml:"maxDelay,omitempty"`
+}
+
+func (h *RateLimitHeadroom) Clone() *RateLimitHeadroom {
+	if h == nil {
+		return nil
+	}
+
+	clone := *h
+
+	if h.Reserve != nil {
+		v := *h.Reserve
+		clone.Reserve = &v
+	}
+
+	if h.MaxDelay != nil {
+		v := *h.MaxDelay
+		clone.MaxDelay = &v
+	}
+
+	return &clone
+}
+
+func (h *RateLimitHeadroom) Validate(vc *common.ValidationContext) error {
+	if h == nil {
+		return nil
+	}
+
+	result := &multierror.Error{}
+
+	switch h.Format {
+	case "", RateLimitHeaderFormatXRateLimit:
+	case RateLimitHeaderFormatIETF:
+		if h.RemainingHeader != "" {
+			result = multierror.Append(result, vc.PushField("remaining_header").NewError("not supported by the ietf format"))
+		}
+		if h.ResetHeader != "" {
+			result = multierror.Append(result, vc.PushField("reset_header").NewError("not supported by the ietf format"))
+		}
+		if h.LimitHeader != "" {
+			result = multierror.Append(result, vc.PushField("limit_header").NewError("not supported by the ietf format"))
+		}
+		if h.ResetFormat != "" {
+			result = multierror.Append(result, vc.PushField("reset_format").NewError("not supported by the ietf format"))
+		}
+	default:
+		result = multierror.Append(result, vc.PushField("format").NewErrorf("unsupported format '%s'", h.Format))
+	}
+
+	switch h.ResetFormat {
+	case "", RateLimitResetFormatAuto, RateLimitResetFormatDeltaSeconds, RateLimitResetFormatUnixSeconds:
+	default:
+		result = multierror.Append(result, vc.PushField("reset_format").NewErrorf("unsupported reset format '%s'", h.ResetFormat))
+	}
+
+	if h.Reserve != nil && *h.Reserve < 0 {
+		result = multierror.Append(result, vc.PushField("reserve").NewError("must not be negative"))
+	}
+
+	if h.MaxDelay != nil && h.MaxDelay.Duration < 0 {
+		result = multierror.Append(result, vc.PushField("max_delay").NewError("must not be negative"))
+	}
+
+	return result.ErrorOrNil()
+}
+
+// GetFormat returns the configured header format, defaulting to x-ratelimit.
+func (h *RateLimitHeadroom) GetFormat() RateLimitHeaderFormat {
+	if h == nil || h.Format == "" {
+		return RateLimitHeaderFormatXRateLimit
+	}
+	return h.Format
+}
+
+// GetRemainingHeader returns the configured remaining header, defaulting to X-RateLimit-Remaining.
+func (h *RateLimitHeadroom) GetRemainingHeader() string {
+	if h == nil || h.RemainingHeader == "" {
+		return DefaultRateLimitRemainingHeader
+	}
+	return h.RemainingHeader
+}
+
+// GetResetHeader returns the configured reset header, defaulting to X-RateLimit-Reset.
+func (h *RateLimitHeadroom) GetResetHeader() string {
+	if h == nil || h.ResetHeader == "" {
+		return DefaultRateLimitResetHeader
+	}
+	return h.ResetHeader
+}
+
+// GetLimitHeader returns the configured limit header, defaulting to X-RateLimit-Limit.
+func (h *RateLimitHeadroom) GetLimitHeader() string {
+	if h == nil || h.LimitHeader == "" {
+		return DefaultRateLimitLimitHeader
+	}
+	return h.LimitHeader
+}
+
+// GetResetFormat returns the configured reset format, defaulting to auto.
+func (h *RateLimitHeadroom) GetResetFormat() RateLimitResetFormat {
+	if h == nil || h.ResetFormat == "" {
+		return RateLimitResetFormatAuto
+	}
+	return h.ResetFormat
+}
+
+// GetReserve returns the configured reserve, defaulting to 0.
+func (h *RateLimitHeadroom) GetReserve() int64 {
+	if h == nil || h.Reserve == nil {
+		return 0
+	}
+	return *h.Reserve
+}
+
+// GetMaxDelay returns the configured max delay, defaulting to 0.
+func (h *RateLimitHeadroom) GetMaxDelay() time.Duration {
+	if h == nil || h.MaxDelay == nil {
+		return 0
+	}
+	return h.MaxDelay.Duration
+}
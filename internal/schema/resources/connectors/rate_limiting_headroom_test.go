@@ -0,0 +1,122 @@
+package connectors
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rmorlok/authproxy/internal/schema/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestRateLimitHeadroom_Unmarshal(t *testing.T) {
+	var c Connector
+	require.NoError(t, yaml.Unmarshal([]byte(`
+rateLimiting:
+  headroom:
+    remainingHeader: X-Budget-Left
+    resetFormat: unix_seconds
+    reserve: 25
+    maxDelay: 2s
+`), &c))
+	h := c.RateLimiting.GetHeadroom()
+	require.NotNil(t, h)
+	assert.Equal(t, RateLimitHeaderFormatXRateLimit, h.GetFormat())
+	assert.Equal(t, "X-Budget-Left", h.GetRemainingHeader())
+	assert.Equal(t, DefaultRateLimitResetHeader, h.GetResetHeader())
+	assert.Equal(t, RateLimitResetFormatUnixSeconds, h.GetResetFormat())
+	assert.Equal(t, int64(25), h.GetReserve())
+	assert.Equal(t, 2*time.Second, h.GetMaxDelay())
+}
+
+func TestRateLimitHeadroom_Defaults(t *testing.T) {
+	assert.Nil(t, (*RateLimiting)(nil).GetHeadroom())
+
+	var h *RateLimitHeadroom
+	assert.Equal(t, RateLimitHeaderFormatXRateLimit, h.GetFormat())
+	assert.Equal(t, DefaultRateLimitRemainingHeader, h.GetRemainingHeader())
+	assert.Equal(t, DefaultRateLimitResetHeader, h.GetResetHeader())
+	assert.Equal(t, DefaultRateLimitLimitHeader, h.GetLimitHeader())
+	assert.Equal(t, RateLimitResetFormatAuto, h.GetResetFormat())
+	assert.Equal(t, int64(0), h.GetReserve())
+	assert.Equal(t, time.Duration(0), h.GetMaxDelay())
+}
+
+func TestRateLimitHeadroom_Clone(t *testing.T) {
+	reserve := int64(10)
+	orig := &RateLimiting{
+		Headroom: &RateLimitHeadroom{
+			Reserve:  &reserve,
+			MaxDelay: &common.HumanDuration{Duration: time.Second},
+		},
+	}
+	clone := orig.Clone()
+	*clone.Headroom.Reserve = 1
+	clone.Headroom.MaxDelay.Duration = time.Minute
+	assert.Equal(t, int64(10), *orig.Headroom.Reserve)
+	assert.Equal(t, time.Second, orig.Headroom.MaxDelay.Duration)
+	assert.Nil(t, (*RateLimitHeadroom)(nil).Clone())
+}
+
+func TestRateLimitHeadroom_Validate(t *testing.T) {
+	negative := int64(-1)
+
+	tests := []struct {
+		name        string
+		rl          *RateLimiting
+		wantErrSubs []string
+	}{
+		{
+			name: "defaults",
+			rl:   &RateLimiting{Headroom: &RateLimitHeadroom{}},
+		},
+		{
+			name: "ietf",
+			rl:   &RateLimiting{Headroom: &RateLimitHeadroom{Format: RateLimitHeaderFormatIETF}},
+		},
+		{
+			name:        "unknown format",
+			rl:          &RateLimiting{Headroom: &RateLimitHeadroom{Format: "draft-7"}},
+			wantErrSubs: []string{"headroom.format", "unsupported format"},
+		},
+		{
+			name: "header override with ietf",
+			rl: &RateLimiting{Headroom: &RateLimitHeadroom{
+				Format:          RateLimitHeaderFormatIETF,
+				RemainingHeader: "X-Left",
+			}},
+			wantErrSubs: []string{"headroom.remaining_header", "not supported by the ietf format"},
+		},
+		{
+			name:        "unknown reset format",
+			rl:          &RateLimiting{Headroom: &RateLimitHeadroom{ResetFormat: "millis"}},
+			wantErrSubs: []string{"headroom.reset_format", "unsupported reset format"},
+		},
+		{
+			name:        "negative reserve",
+			rl:          &RateLimiting{Headroom: &RateLimitHeadroom{Reserve: &negative}},
+			wantErrSubs: []string{"headroom.reserve", "must not be negative"},
+		},
+		{
+			name:        "negative max delay",
+			rl:          &RateLimiting{Headroom: &RateLimitHeadroom{MaxDelay: &common.HumanDuration{Duration: -time.Second}}},
+			wantErrSubs: []string{"headroom.max_delay"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.rl.Validate(&common.ValidationContext{})
+			if len(tt.wantErrSubs) == 0 {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			msg := err.Error()
+			for _, sub := range tt.wantErrSubs {
+				assert.Contains(t, msg, sub)
+			}
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package connectors
+
+import (
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/rmorlok/authproxy/internal/schema/common"
+	"github.com/rmorlok/authproxy/internal/schema/resources/rate_limit"
+)
+
+const (
+	DefaultResponseCacheMaxEntrySize  = 1 * common.MiB
+	DefaultResponseCacheRevalidateFor = 1 * time.Hour
+)
+
+// ResponseCache opts a connector's proxied GET and HEAD requests into a response cache. Responses are cached per
+// connection and never shared across connections, so responses the upstream marks private are still cached: every
+// caller of a connection already acts as the same upstream identity.
+//
+// A request is cached only when it matches one of Rules. The upstream's Cache-Control and Expires take precedence
+// over the rule's TTL, and responses carrying an ETag or Last-Modified are revalidated with a conditional request once
+// they go stale rather than fetched again.
+type ResponseCache struct {
+	// Rules select the requests that are cached. The first rule whose path matches applies.
+	Rules []ResponseCacheRule `json:"rules" yaml:"rules"`
+
+	// VaryHeaders are request headers always included in the cache key, in addition to any the upstream lists in
+	// Vary, e.g. Accept-Language for an upstream that localizes without saying so.
+	VaryHeaders []string `json:"varyHeaders,omitempty" yaml:"varyHeaders,omitempty"`
+
+	// MaxEntrySize is the largest response body that is cached. Larger responses are passed through uncached.
+	// Defaults to 1MiB and is capped by the global responseCache.maxEntrySize.
+	MaxEntrySize *common.HumanByteSize `json:"maxEntrySize,omitempty" yaml:"maxEntrySize,omitempty"`
+
+	// RevalidateFor is how long a stale response with an ETag or Last-Modified is kept for conditional
+	// revalidation. Defaults to 1 hour.
+	RevalidateFor *common.HumanDuration `json:"revalidateFor,omitempty" yaml:"revalidateFor,omitempty"`
+}
+
+// ResponseCacheRule sets how long matching responses are fresh when the upstream doesn't say.
+type ResponseCacheRule struct {
+	// PathMatch restricts the rule to a path on the upstream URL. Omit to match every path.
+	PathMatch *rate_limit.PathMatch `json:"pathMatch,omitempty" yaml:"pathMatch,omitempty"`
+
+	// TTL is how long a response is served from the cache when the upstream sends no max-age or Expires.
+	TTL common.HumanDuration `json:"ttl" yaml:"ttl"`
+}
+
+func (r *ResponseCache) Clone() *ResponseCache {
+	if r == nil {
+		return nil
+	}
+
+	clone := *r
+
+	if r.Rules != nil {
+		clone.Rules = make([]ResponseCacheRule, len(r.Rules))
+		for i, rule := range r.Rules {
+			clone.Rules[i] = rule
+			if rule.PathMatch != nil {
+				pm := *rule.PathMatch
+				clone.Rules[i].PathMatch = &pm
+			}
+		}
+	}
+
+	if r.VaryHeaders != nil {
+		clone.VaryHeaders = slices.Clone(r.VaryHeaders)
+	}
+
+	if r.MaxEntrySize != nil {
+		v := *r.MaxEntrySize
+		clone.MaxEntrySize = &v
+	}
+
+	if r.RevalidateFor != nil {
+		v := *r.RevalidateFor
+		clone.RevalidateFor = &v
+	}
+
+	return &clone
+}
+
+func (r *ResponseCache) Validate(vc *common.ValidationContext) error {
+	if r == nil {
+		return nil
+	}
+
+	result := &multierror.Error{}
+
+	if len(r.Rules) == 0 {
+		result = multierror.Append(result, vc.NewErrorForField("rules", "at least one rule must be specified"))
+	}
+
+	for i, rule := range r.Rules {
+		rvc := vc.PushField("rules").PushIndex(i)
+		if rule.TTL.Duration <= 0 {
+			result = multierror.Append(result, rvc.NewErrorForField("ttl", "must be positive"))
+		}
+		if err := rule.PathMatch.Validate(rvc.PushField("path_match")); err != nil {
+			result = multierror.Append(result, err)
+		}
+	}
+
+	for i, h := range r.VaryHeaders {
+		if h == "" {
+			result = multierror.Append(result, vc.PushField("vary_headers").PushIndex(i).NewError("must not be empty"))
+		}
+	}
+
+	if r.RevalidateFor != nil && r.RevalidateFor.Duration < 0 {
+		result = multierror.Append(result, vc.PushField("revalidate_for").NewError("must not be negative"))
+	}
+
+	return result.ErrorOrNil()
+}
+
+// MatchRule returns the first rule whose path matches p, or nil if none does. Rules whose path expression fails to
+// evaluate are skipped.
+func (r *ResponseCache) MatchRule(p string) *ResponseCacheRule {
+	if r == nil {
+		return nil
+	}
+
+	for i := range r.Rules {
+		rule := &r.Rules[i]
+		if rule.PathMatch == nil {
+			return rule
+		}
+		if ok, err := rule.PathMatch.Matches(p); err == nil && ok {
+			return rule
+		}
+	}
+
+	return nil
+}
+
+// GetVaryHeaders returns the canonicalized request headers always included in the cache key.
+func (r *ResponseCache) GetVaryHeaders() []string {
+	if r == nil {
+		return nil
+	}
+
+	headers := make([]string, 0, len(r.VaryHeaders))
+	for _, h := range r.VaryHeaders {
+		headers = append(headers, http.CanonicalHeaderKey(h))
+	}
+	return headers
+}
+
+// GetMaxEntrySize returns the largest cacheable body in bytes, defaulting to 1MiB.
+func (r *ResponseCache) GetMaxEntrySize() uint64 {
+	if r == nil || r.MaxEntrySize == nil {
+		return DefaultResponseCacheMaxEntrySize
+	}
+	return r.MaxEntrySize.Value()
+}
+
+// GetRevalidateFor returns how long stale responses with validators are retained, defaulting to 1 hour.
+func (r *ResponseCache) GetRevalidateFor() time.Duration {
+	if r == nil || r.RevalidateFor == nil {
+		return DefaultResponseCacheRevalidateFor
+	}
+	return r.RevalidateFor.Duration
+}
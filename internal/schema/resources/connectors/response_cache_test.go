@@ -0,0 +1,132 @@
+package connectors
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rmorlok/authproxy/internal/schema/common"
+	"github.com/rmorlok/authproxy/internal/schema/resources/rate_limit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestResponseCache_Unmarshal(t *testing.T) {
+	var c Connector
+	require.NoError(t, yaml.Unmarshal([]byte(`
+responseCache:
+  rules:
+    - pathMatch:
+        kind: prefix
+        value: /v1/projects
+      ttl: 5m
+  varyHeaders: [accept-language]
+  maxEntrySize: 64kib
+  revalidateFor: 10m
+`), &c))
+	rc := c.ResponseCache
+	require.NotNil(t, rc)
+	require.Len(t, rc.Rules, 1)
+	assert.Equal(t, 5*time.Minute, rc.Rules[0].TTL.Duration)
+	assert.Equal(t, []string{"Accept-Language"}, rc.GetVaryHeaders())
+	assert.Equal(t, 64*common.KiB, rc.GetMaxEntrySize())
+	assert.Equal(t, 10*time.Minute, rc.GetRevalidateFor())
+}
+
+func TestResponseCache_Defaults(t *testing.T) {
+	var nilCache *ResponseCache
+	assert.Nil(t, nilCache.MatchRule("/v1/projects"))
+
+	rc := &ResponseCache{}
+	assert.Equal(t, DefaultResponseCacheMaxEntrySize, rc.GetMaxEntrySize())
+	assert.Equal(t, DefaultResponseCacheRevalidateFor, rc.GetRevalidateFor())
+}
+
+func TestResponseCache_MatchRule(t *testing.T) {
+	rc := &ResponseCache{
+		Rules: []ResponseCacheRule{
+			{PathMatch: &rate_limit.PathMatch{Kind: rate_limit.PathMatchKindGlob, Value: "/v1/users/*"}, TTL: common.HumanDuration{Duration: time.Second}},
+			{PathMatch: &rate_limit.PathMatch{Kind: rate_limit.PathMatchKindPrefix, Value: "/v1/"}, TTL: common.HumanDuration{Duration: time.Minute}},
+		},
+	}
+	assert.Equal(t, time.Second, rc.MatchRule("/v1/users/42").TTL.Duration)
+	assert.Equal(t, time.Minute, rc.MatchRule("/v1/users/42/roles").TTL.Duration)
+	assert.Nil(t, rc.MatchRule("/v2/users"))
+
+	rc.Rules = append(rc.Rules, ResponseCacheRule{TTL: common.HumanDuration{Duration: time.Hour}})
+	assert.Equal(t, time.Hour, rc.MatchRule("/v2/users").TTL.Duration)
+}
+
+func TestResponseCache_Validate(t *testing.T) {
+	tests := []struct {
+		name        string
+		cache       *ResponseCache
+		wantErrSubs []string
+	}{
+		{
+			name:  "nil receiver",
+			cache: nil,
+		},
+		{
+			name:  "valid",
+			cache: &ResponseCache{Rules: []ResponseCacheRule{{TTL: common.HumanDuration{Duration: time.Minute}}}},
+		},
+		{
+			name:        "no rules",
+			cache:       &ResponseCache{},
+			wantErrSubs: []string{"rules", "at least one rule"},
+		},
+		{
+			name:        "zero ttl",
+			cache:       &ResponseCache{Rules: []ResponseCacheRule{{}}},
+			wantErrSubs: []string{"rules[0].ttl", "must be positive"},
+		},
+		{
+			name: "bad path match",
+			cache: &ResponseCache{Rules: []ResponseCacheRule{{
+				PathMatch: &rate_limit.PathMatch{Kind: rate_limit.PathMatchKindRegex, Value: "("},
+				TTL:       common.HumanDuration{Duration: time.Minute},
+			}}},
+			wantErrSubs: []string{"rules[0].path_match.value", "invalid regex"},
+		},
+		{
+			name: "negative revalidate for",
+			cache: &ResponseCache{
+				Rules:         []ResponseCacheRule{{TTL: common.HumanDuration{Duration: time.Minute}}},
+				RevalidateFor: &common.HumanDuration{Duration: -time.Minute},
+			},
+			wantErrSubs: []string{"revalidate_for"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cache.Validate(&common.ValidationContext{})
+			if len(tt.wantErrSubs) == 0 {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			msg := err.Error()
+			for _, sub := range tt.wantErrSubs {
+				assert.Contains(t, msg, sub)
+			}
+		})
+	}
+}
+
+func TestResponseCache_Clone(t *testing.T) {
+	orig := &ResponseCache{
+		Rules: []ResponseCacheRule{{
+			PathMatch: &rate_limit.PathMatch{Kind: rate_limit.PathMatchKindPrefix, Value: "/v1"},
+			TTL:       common.HumanDuration{Duration: time.Minute},
+		}},
+		VaryHeaders: []string{"Accept"},
+	}
+	clone := orig.Clone()
+	clone.Rules[0].PathMatch.Value = "/v2"
+	clone.VaryHeaders[0] = "Accept-Language"
+	assert.Equal(t, "/v1", orig.Rules[0].PathMatch.Value)
+	assert.Equal(t, []string{"Accept"}, orig.VaryHeaders)
+	assert.Nil(t, (*ResponseCache)(nil).Clone())
+}
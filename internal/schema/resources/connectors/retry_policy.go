@@ -0,0 +1,175 @@
+package connectors
+
+import (
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/rmorlok/authproxy/internal/schema/common"
+)
+
+const (
+	DefaultRetryMaxAttempts          = 3
+	DefaultRetryMaxRetryAfter        = 10 * time.Second
+	DefaultRetryIdempotencyKeyHeader = "Idempotency-Key"
+)
+
+// DefaultRetryStatusCodes are the upstream statuses retried when a retry policy does not list its own.
+var DefaultRetryStatusCodes = []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout}
+
+// RetryPolicy configures how the proxy retries transient upstream failures on the caller's behalf. When unset, the
+// proxy does not retry beyond replaying a request once after recovering from a 401.
+//
+// By default only idempotent methods (GET, HEAD, OPTIONS, TRACE, PUT, DELETE) are retried. Other methods are retried
+// when the caller sends an idempotency key, or when RetryNonIdempotent is set. Raw proxy requests are only retried when
+// their body can be replayed.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first. Defaults to 3.
+	MaxAttempts *int `json:"maxAttempts,omitempty" yaml:"maxAttempts,omitempty"`
+
+	// StatusCodes are the upstream response statuses that are retried. Defaults to [502, 503, 504].
+	StatusCodes []int `json:"statusCodes,omitempty" yaml:"statusCodes,omitempty"`
+
+	// NetworkErrors retries requests that failed without a response, e.g. a refused or reset connection. Defaults to
+	// true. Requests refused by the egress policy are never retried.
+	NetworkErrors *bool `json:"networkErrors,omitempty" yaml:"networkErrors,omitempty"`
+
+	// MaxRetryAfter is the longest Retry-After the proxy will wait out. A 429 whose Retry-After is at or below this
+	// is retried after that delay; a retryable status asking for a longer wait is returned to the caller instead.
+	// Defaults to 10 seconds.
+	MaxRetryAfter *common.HumanDuration `json:"maxRetryAfter,omitempty" yaml:"maxRetryAfter,omitempty"`
+
+	// Backoff is the delay between attempts when the upstream doesn't send a Retry-After. Defaults to the
+	// ExponentialBackoff defaults.
+	Backoff *ExponentialBackoff `json:"backoff,omitempty" yaml:"backoff,omitempty"`
+
+	// RetryNonIdempotent allows methods such as POST and PATCH to be retried even without an idempotency key.
+	RetryNonIdempotent bool `json:"retryNonIdempotent,omitempty" yaml:"retryNonIdempotent,omitempty"`
+
+	// IdempotencyKeyHeader is the request header that marks a non-idempotent request as safe to retry. Defaults to
+	// Idempotency-Key.
+	IdempotencyKeyHeader string `json:"idempotencyKeyHeader,omitempty" yaml:"idempotencyKeyHeader,omitempty"`
+}
+
+func (r *RetryPolicy) Clone() *RetryPolicy {
+	if r == nil {
+		return nil
+	}
+
+	clone := *r
+
+	if r.MaxAttempts != nil {
+		v := *r.MaxAttempts
+		clone.MaxAttempts = &v
+	}
+
+	if r.StatusCodes != nil {
+		clone.StatusCodes = slices.Clone(r.StatusCodes)
+	}
+
+	if r.NetworkErrors != nil {
+		v := *r.NetworkErrors
+		clone.NetworkErrors = &v
+	}
+
+	if r.MaxRetryAfter != nil {
+		v := *r.MaxRetryAfter
+		clone.MaxRetryAfter = &v
+	}
+
+	clone.Backoff = r.Backoff.Clone()
+
+	return &clone
+}
+
+func (r *RetryPolicy) Validate(vc *common.ValidationContext) error {
+	if r == nil {
+		return nil
+	}
+
+	result := &multierror.Error{}
+
+	if r.MaxAttempts != nil && *r.MaxAttempts < 1 {
+		result = multierror.Append(result, vc.PushField("max_attempts").NewError("must be at least 1"))
+	}
+
+	for i, code := range r.StatusCodes {
+		if code < 400 || code > 599 {
+			result = multierror.Append(result, vc.PushField("status_codes").PushIndex(i).NewError("must be a 4xx or 5xx status code"))
+		}
+	}
+
+	if r.MaxRetryAfter != nil && r.MaxRetryAfter.Duration < 0 {
+		result = multierror.Append(result, vc.PushField("max_retry_after").NewError("must not be negative"))
+	}
+
+	if err := r.Backoff.Validate(vc.PushField("backoff")); err != nil {
+		result = multierror.Append(result, err)
+	}
+
+	return result.ErrorOrNil()
+}
+
+// GetMaxAttempts returns the configured number of attempts, defaulting to 3. A nil policy makes a single attempt.
+func (r *RetryPolicy) GetMaxAttempts() int {
+	if r == nil {
+		return 1
+	}
+	if r.MaxAttempts == nil {
+		return DefaultRetryMaxAttempts
+	}
+	return *r.MaxAttempts
+}
+
+// GetStatusCodes returns the configured retryable statuses, defaulting to DefaultRetryStatusCodes.
+func (r *RetryPolicy) GetStatusCodes() []int {
+	if r == nil || len(r.StatusCodes) == 0 {
+		return DefaultRetryStatusCodes
+	}
+	return r.StatusCodes
+}
+
+// GetNetworkErrors reports whether failures without a response are retried, defaulting to true.
+func (r *RetryPolicy) GetNetworkErrors() bool {
+	if r == nil || r.NetworkErrors == nil {
+		return true
+	}
+	return *r.NetworkErrors
+}
+
+// GetMaxRetryAfter returns the longest Retry-After that will be waited out, defaulting to 10 seconds.
+func (r *RetryPolicy) GetMaxRetryAfter() time.Duration {
+	if r == nil || r.MaxRetryAfter == nil {
+		return DefaultRetryMaxRetryAfter
+	}
+	return r.MaxRetryAfter.Duration
+}
+
+// GetIdempotencyKeyHeader returns the idempotency key header, defaulting to Idempotency-Key.
+func (r *RetryPolicy) GetIdempotencyKeyHeader() string {
+	if r == nil || r.IdempotencyKeyHeader == "" {
+		return DefaultRetryIdempotencyKeyHeader
+	}
+	return r.IdempotencyKeyHeader
+}
+
+// RetriesStatus reports whether statusCode is in the policy's retryable statuses.
+func (r *RetryPolicy) RetriesStatus(statusCode int) bool {
+	return slices.Contains(r.GetStatusCodes(), statusCode)
+}
+
+// AllowsMethod reports whether a request with the given method and headers may be retried: idempotent methods always
+// may, others only with an idempotency key or when RetryNonIdempotent is set.
+func (r *RetryPolicy) AllowsMethod(method string, header http.Header) bool {
+	if r == nil {
+		return false
+	}
+
+	switch method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+
+	return r.RetryNonIdempotent || header.Get(r.GetIdempotencyKeyHeader()) != ""
+}
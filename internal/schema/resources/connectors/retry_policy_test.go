@@ -0,0 +1,152 @@
+package connectors
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/rmorlok/authproxy/internal/schema/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestRetryPolicy_Unmarshal(t *testing.T) {
+	var c Connector
+	require.NoError(t, yaml.Unmarshal([]byte(`
+retryPolicy:
+  maxAttempts: 5
+  statusCodes: [500, 503]
+  networkErrors: false
+  maxRetryAfter: 30s
+  backoff:
+    initialInterval: 200ms
+    multiplier: 3
+  idempotencyKeyHeader: X-Request-Key
+`), &c))
+	rp := c.RetryPolicy
+	require.NotNil(t, rp)
+	assert.Equal(t, 5, rp.GetMaxAttempts())
+	assert.Equal(t, []int{500, 503}, rp.GetStatusCodes())
+	assert.False(t, rp.GetNetworkErrors())
+	assert.Equal(t, 30*time.Second, rp.GetMaxRetryAfter())
+	assert.Equal(t, 200*time.Millisecond, rp.Backoff.GetInitialInterval())
+	assert.Equal(t, "X-Request-Key", rp.GetIdempotencyKeyHeader())
+}
+
+func TestRetryPolicy_Defaults(t *testing.T) {
+	var nilPolicy *RetryPolicy
+	assert.Equal(t, 1, nilPolicy.GetMaxAttempts())
+	assert.False(t, nilPolicy.AllowsMethod(http.MethodGet, nil))
+
+	rp := &RetryPolicy{}
+	assert.Equal(t, DefaultRetryMaxAttempts, rp.GetMaxAttempts())
+	assert.True(t, rp.RetriesStatus(http.StatusBadGateway))
+	assert.False(t, rp.RetriesStatus(http.StatusInternalServerError))
+	assert.True(t, rp.GetNetworkErrors())
+	assert.Equal(t, DefaultRetryMaxRetryAfter, rp.GetMaxRetryAfter())
+	assert.Equal(t, "Idempotency-Key", rp.GetIdempotencyKeyHeader())
+}
+
+func TestRetryPolicy_AllowsMethod(t *testing.T) {
+	rp := &RetryPolicy{}
+	assert.True(t, rp.AllowsMethod(http.MethodGet, http.Header{}))
+	assert.True(t, rp.AllowsMethod(http.MethodPut, http.Header{}))
+	assert.False(t, rp.AllowsMethod(http.MethodPost, http.Header{}))
+	assert.True(t, rp.AllowsMethod(http.MethodPost, http.Header{"Idempotency-Key": {"abc"}}))
+
+	rp = &RetryPolicy{IdempotencyKeyHeader: "X-Request-Key"}
+	assert.False(t, rp.AllowsMethod(http.MethodPatch, http.Header{"Idempotency-Key": {"abc"}}))
+	assert.True(t, rp.AllowsMethod(http.MethodPatch, http.Header{"X-Request-Key": {"abc"}}))
+
+	rp = &RetryPolicy{RetryNonIdempotent: true}
+	assert.True(t, rp.AllowsMethod(http.MethodPost, http.Header{}))
+}
+
+func TestRetryPolicy_Clone(t *testing.T) {
+	attempts := 4
+	orig := &RetryPolicy{
+		MaxAttempts: &attempts,
+		StatusCodes: []int{503},
+		Backoff:     &ExponentialBackoff{InitialInterval: &common.HumanDuration{Duration: time.Second}},
+	}
+	clone := orig.Clone()
+	*clone.MaxAttempts = 1
+	clone.StatusCodes[0] = 504
+	clone.Backoff.InitialInterval.Duration = time.Minute
+	assert.Equal(t, 4, *orig.MaxAttempts)
+	assert.Equal(t, []int{503}, orig.StatusCodes)
+	assert.Equal(t, time.Second, orig.Backoff.InitialInterval.Duration)
+	assert.Nil(t, (*RetryPolicy)(nil).Clone())
+}
+
+func TestRetryPolicy_Validate(t *testing.T) {
+	zero := 0
+	negativeMultiplier := -1.0
+
+	tests := []struct {
+		name        string
+		policy      *RetryPolicy
+		wantErrSubs []string
+	}{
+		{
+			name:   "nil receiver",
+			policy: nil,
+		},
+		{
+			name:   "defaults",
+			policy: &RetryPolicy{},
+		},
+		{
+			name:        "zero attempts",
+			policy:      &RetryPolicy{MaxAttempts: &zero},
+			wantErrSubs: []string{"max_attempts", "must be at least 1"},
+		},
+		{
+			name:        "non-error status code",
+			policy:      &RetryPolicy{StatusCodes: []int{503, 302}},
+			wantErrSubs: []string{"status_codes[1]", "4xx or 5xx"},
+		},
+		{
+			name:        "negative max retry after",
+			policy:      &RetryPolicy{MaxRetryAfter: &common.HumanDuration{Duration: -time.Second}},
+			wantErrSubs: []string{"max_retry_after"},
+		},
+		{
+			name:        "invalid backoff",
+			policy:      &RetryPolicy{Backoff: &ExponentialBackoff{Multiplier: &negativeMultiplier}},
+			wantErrSubs: []string{"backoff.multiplier", "must be positive"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.Validate(&common.ValidationContext{})
+			if len(tt.wantErrSubs) == 0 {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			msg := err.Error()
+			for _, sub := range tt.wantErrSubs {
+				assert.Contains(t, msg, sub)
+			}
+		})
+	}
+}
+
+func TestExponentialBackoff_Interval(t *testing.T) {
+	noJitter := 0.0
+	eb := &ExponentialBackoff{
+		InitialInterval: &common.HumanDuration{Duration: 100 * time.Millisecond},
+		MaxInterval:     &common.HumanDuration{Duration: time.Second},
+		JitterFraction:  &noJitter,
+	}
+	assert.Equal(t, 100*time.Millisecond, eb.Interval(0))
+	assert.Equal(t, 400*time.Millisecond, eb.Interval(2))
+	assert.Equal(t, time.Second, eb.Interval(10))
+
+	d := (*ExponentialBackoff)(nil).Interval(0)
+	assert.GreaterOrEqual(t, d, 900*time.Millisecond)
+	assert.LessOrEqual(t, d, 1100*time.Millisecond)
+}
@@ -37,6 +37,8 @@ func Test_SchemaAgainstRealData(t *testing.T) {
 
 	_ = loadSchema(t, c, "../namespace/schema.json")
 	_ = loadSchema(t, c, "../../common/schema.json")
+	_ = loadSchema(t, c, "../key/schema.json")
+	_ = loadSchema(t, c, "../rate_limit/schema.json")
 	_ = loadSchema(t, c, "./schema-oauth.json")
 	schemaId := loadSchema(t, c, "./schema.json")
 
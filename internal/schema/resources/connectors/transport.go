@@ -0,0 +1,413 @@
+package connectors
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/rmorlok/authproxy/internal/aptmpl"
+	"github.com/rmorlok/authproxy/internal/schema/common"
+	"github.com/rmorlok/authproxy/internal/schema/resources/key"
+)
+
+// defaultClientCertificateExpiryWarning is how long before a client
+// certificate's notAfter a connection notification is raised.
+const defaultClientCertificateExpiryWarning = 30 * 24 * time.Hour
+
+// defaultUpgradeIdleTimeout is how long an upgraded connection (e.g. a
+// WebSocket) may go without traffic in either direction before the proxy
+// closes it.
+const defaultUpgradeIdleTimeout = 5 * time.Minute
+
+// Transport configures the connection-level transport used for outbound calls
+// to the upstream. It is orthogonal to Auth: a connector can combine a client
+// certificate with any auth method, e.g. OAuth2 over mutual TLS for FAPI-style
+// APIs.
+type Transport struct {
+	// ClientCertificate presents a TLS client certificate to the upstream
+	// (mutual TLS).
+	ClientCertificate *TransportClientCertificate `json:"clientCertificate,omitempty" yaml:"clientCertificate,omitempty"`
+
+	// TLS customizes how the upstream's server certificate is verified, e.g.
+	// to reach a self-hosted instance behind a private CA.
+	TLS *TransportTLS `json:"tls,omitempty" yaml:"tls,omitempty"`
+
+	// Proxy routes the connector's outbound calls through a forward proxy,
+	// overriding the global outboundProxy default.
+	Proxy *TransportProxy `json:"proxy,omitempty" yaml:"proxy,omitempty"`
+
+	// UpgradeIdleTimeout closes a connection proxied through an HTTP
+	// upgrade (e.g. a WebSocket) after this long without traffic in either
+	// direction. Defaults to 5 minutes.
+	UpgradeIdleTimeout *common.HumanDuration `json:"upgradeIdleTimeout,omitempty" yaml:"upgradeIdleTimeout,omitempty"`
+}
+
+func (t *Transport) GetUpgradeIdleTimeoutOrDefault() time.Duration {
+	if t == nil || t.UpgradeIdleTimeout == nil {
+		return defaultUpgradeIdleTimeout
+	}
+	return t.UpgradeIdleTimeout.Duration
+}
+
+func (t *Transport) Clone() *Transport {
+	if t == nil {
+		return nil
+	}
+
+	clone := *t
+	clone.ClientCertificate = t.ClientCertificate.Clone()
+	clone.TLS = t.TLS.Clone()
+	clone.Proxy = t.Proxy.Clone()
+
+	if t.UpgradeIdleTimeout != nil {
+		d := *t.UpgradeIdleTimeout
+		clone.UpgradeIdleTimeout = &d
+	}
+
+	return &clone
+}
+
+func (t *Transport) Validate(vc *common.ValidationContext) error {
+	if t == nil {
+		return nil
+	}
+
+	result := &multierror.Error{}
+
+	if t.ClientCertificate != nil {
+		if err := t.ClientCertificate.Validate(vc.PushField("client_certificate")); err != nil {
+			result = multierror.Append(result, err)
+		}
+	}
+
+	if t.TLS != nil {
+		if err := t.TLS.Validate(vc.PushField("tls")); err != nil {
+			result = multierror.Append(result, err)
+		}
+	}
+
+	if t.Proxy != nil {
+		if err := t.Proxy.Validate(vc.PushField("proxy")); err != nil {
+			result = multierror.Append(result, err)
+		}
+	}
+
+	if t.UpgradeIdleTimeout != nil && t.UpgradeIdleTimeout.Duration <= 0 {
+		result = multierror.Append(result, vc.NewErrorfForField("upgrade_idle_timeout", "must be positive"))
+	}
+
+	return result.ErrorOrNil()
+}
+
+// ValidateMustacheReferences checks that templated TLS settings only reference
+// preconnect fields, since the settings apply to every call made once
+// credentials exist.
+func (t *Transport) ValidateMustacheReferences(vc *common.ValidationContext, mctx *MustacheValidationContext) error {
+	if t == nil || t.TLS == nil || mctx == nil {
+		return nil
+	}
+
+	result := &multierror.Error{}
+	tvc := vc.PushField("tls")
+	for i, ca := range t.TLS.CaCertificates {
+		checkMustacheTemplate(tvc.PushField("ca_certificates").PushIndex(i), ca, mctx.PreconnectFields, "preconnect", result)
+	}
+	checkMustacheTemplate(tvc.PushField("min_version"), t.TLS.MinVersion, mctx.PreconnectFields, "preconnect", result)
+	checkMustacheTemplate(tvc.PushField("server_name"), t.TLS.ServerName, mctx.PreconnectFields, "preconnect", result)
+	for i, pin := range t.TLS.PinnedSpkiSha256 {
+		checkMustacheTemplate(tvc.PushField("pinned_spki_sha256").PushIndex(i), pin, mctx.PreconnectFields, "preconnect", result)
+	}
+	return result.ErrorOrNil()
+}
+
+// TransportClientCertificate configures the client certificate presented for
+// mutual TLS. When Certificate and PrivateKey are set the pair is shared by
+// every connection of the connector. When both are omitted, each connection
+// uploads its own PEM-encoded pair during setup and it is stored encrypted.
+type TransportClientCertificate struct {
+	// Certificate is the PEM-encoded certificate chain, leaf first.
+	Certificate *key.KeyData `json:"certificate,omitempty" yaml:"certificate,omitempty"`
+
+	// PrivateKey is the PEM-encoded private key for the leaf certificate.
+	PrivateKey *key.KeyData `json:"privateKey,omitempty" yaml:"privateKey,omitempty"`
+
+	// ExpiryWarning is how long before the certificate expires that a
+	// connection notification is raised. Defaults to 30 days.
+	ExpiryWarning *common.HumanDuration `json:"expiryWarning,omitempty" yaml:"expiryWarning,omitempty"`
+}
+
+// IsUploaded is true when the pair is supplied per connection during setup
+// rather than by the connector definition.
+func (c *TransportClientCertificate) IsUploaded() bool {
+	return c != nil && c.Certificate == nil && c.PrivateKey == nil
+}
+
+func (c *TransportClientCertificate) GetExpiryWarningOrDefault() time.Duration {
+	if c == nil || c.ExpiryWarning == nil {
+		return defaultClientCertificateExpiryWarning
+	}
+	return c.ExpiryWarning.Duration
+}
+
+func (c *TransportClientCertificate) Clone() *TransportClientCertificate {
+	if c == nil {
+		return nil
+	}
+
+	clone := *c
+
+	if c.ExpiryWarning != nil {
+		d := *c.ExpiryWarning
+		clone.ExpiryWarning = &d
+	}
+
+	return &clone
+}
+
+func (c *TransportClientCertificate) Validate(vc *common.ValidationContext) error {
+	if c == nil {
+		return nil
+	}
+
+	result := &multierror.Error{}
+
+	if (c.Certificate == nil) != (c.PrivateKey == nil) {
+		result = multierror.Append(result, vc.NewError("certificate and private_key must both be specified, or both omitted to upload the pair during setup"))
+	}
+
+	if c.ExpiryWarning != nil && c.ExpiryWarning.Duration <= 0 {
+		result = multierror.Append(result, vc.NewErrorfForField("expiry_warning", "must be positive"))
+	}
+
+	return result.ErrorOrNil()
+}
+
+// tlsVersions maps the accepted minVersion values to their crypto/tls
+// constants.
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// TransportTLS customizes the TLS handshake with the upstream. Every string
+// field is a mustache template rendered against the connection's cfg, labels
+// and annotations, so a connector for a self-hosted product can collect the
+// tenant's CA bundle during preconnect with `{{cfg.ca_certificate}}`.
+// Templates may only reference preconnect fields.
+type TransportTLS struct {
+	// CaCertificates are PEM-encoded CA certificates trusted in addition to
+	// the system roots. An entry may contain several certificates. Entries
+	// that render to an empty string are ignored so an optional preconnect
+	// field can be left blank.
+	CaCertificates []string `json:"caCertificates,omitempty" yaml:"caCertificates,omitempty"`
+
+	// MinVersion is the minimum TLS version accepted from the upstream: one
+	// of "1.0", "1.1", "1.2" or "1.3". Defaults to the Go default (1.2).
+	MinVersion string `json:"minVersion,omitempty" yaml:"minVersion,omitempty"`
+
+	// ServerName overrides the server name sent in SNI and used to verify
+	// the upstream's certificate, for upstreams addressed by IP or through
+	// an internal alias.
+	ServerName string `json:"serverName,omitempty" yaml:"serverName,omitempty"`
+
+	// PinnedSpkiSha256 are base64-encoded SHA-256 hashes of a
+	// SubjectPublicKeyInfo. When set, the verified chain must contain a
+	// certificate whose public key matches one of the pins. Pinning is in
+	// addition to normal chain verification, not a replacement for it.
+	PinnedSpkiSha256 []string `json:"pinnedSpkiSha256,omitempty" yaml:"pinnedSpkiSha256,omitempty"`
+}
+
+func (t *TransportTLS) Clone() *TransportTLS {
+	if t == nil {
+		return nil
+	}
+
+	clone := *t
+	clone.CaCertificates = cloneStrings(t.CaCertificates)
+	clone.PinnedSpkiSha256 = cloneStrings(t.PinnedSpkiSha256)
+	return &clone
+}
+
+func (t *TransportTLS) Validate(vc *common.ValidationContext) error {
+	if t == nil {
+		return nil
+	}
+
+	result := &multierror.Error{}
+
+	for i, ca := range t.CaCertificates {
+		if aptmpl.ContainsMustache(ca) {
+			// Templated values are checked again after rendering.
+			continue
+		}
+		if err := validateCaCertificates(ca); err != nil {
+			result = multierror.Append(result, vc.PushField("ca_certificates").PushIndex(i).NewErrorf("%s", err.Error()))
+		}
+	}
+
+	if t.MinVersion != "" && !aptmpl.ContainsMustache(t.MinVersion) {
+		if _, err := ParseTLSVersion(t.MinVersion); err != nil {
+			result = multierror.Append(result, vc.NewErrorfForField("min_version", "%s", err.Error()))
+		}
+	}
+
+	for i, pin := range t.PinnedSpkiSha256 {
+		if aptmpl.ContainsMustache(pin) {
+			continue
+		}
+		if _, err := DecodeSpkiPin(pin); err != nil {
+			result = multierror.Append(result, vc.PushField("pinned_spki_sha256").PushIndex(i).NewErrorf("%s", err.Error()))
+		}
+	}
+
+	return result.ErrorOrNil()
+}
+
+// IsTemplated reports whether any field contains a mustache template, i.e.
+// whether Render needs the connection's mustache context.
+func (t *TransportTLS) IsTemplated() bool {
+	if t == nil {
+		return false
+	}
+	for _, ca := range t.CaCertificates {
+		if aptmpl.ContainsMustache(ca) {
+			return true
+		}
+	}
+	for _, pin := range t.PinnedSpkiSha256 {
+		if aptmpl.ContainsMustache(pin) {
+			return true
+		}
+	}
+	return aptmpl.ContainsMustache(t.MinVersion) || aptmpl.ContainsMustache(t.ServerName)
+}
+
+// Render returns a copy of the settings with every template rendered against
+// data. Rendered values are validated the same way static values are, so a
+// malformed tenant-supplied CA or pin is reported rather than silently
+// ignored.
+func (t *TransportTLS) Render(data map[string]any) (*TransportTLS, error) {
+	if t == nil {
+		return nil, nil
+	}
+
+	render := func(field, tmpl string) (string, error) {
+		r, err := aptmpl.RenderMustacheRaw(tmpl, data)
+		if err != nil {
+			return "", fmt.Errorf("failed to render tls %s %q: %w", field, tmpl, err)
+		}
+		return strings.TrimSpace(r), nil
+	}
+
+	rendered := &TransportTLS{}
+	for _, ca := range t.CaCertificates {
+		r, err := render("ca certificate", ca)
+		if err != nil {
+			return nil, err
+		}
+		if r == "" {
+			continue
+		}
+		if err := validateCaCertificates(r); err != nil {
+			return nil, fmt.Errorf("tls ca certificate rendered to an invalid value: %w", err)
+		}
+		rendered.CaCertificates = append(rendered.CaCertificates, r)
+	}
+
+	var err error
+	if rendered.MinVersion, err = render("min version", t.MinVersion); err != nil {
+		return nil, err
+	}
+	if rendered.MinVersion != "" {
+		if _, err := ParseTLSVersion(rendered.MinVersion); err != nil {
+			return nil, fmt.Errorf("tls min version rendered to an invalid value: %w", err)
+		}
+	}
+
+	if rendered.ServerName, err = render("server name", t.ServerName); err != nil {
+		return nil, err
+	}
+
+	for _, pin := range t.PinnedSpkiSha256 {
+		r, err := render("pin", pin)
+		if err != nil {
+			return nil, err
+		}
+		if r == "" {
+			continue
+		}
+		if _, err := DecodeSpkiPin(r); err != nil {
+			return nil, fmt.Errorf("tls pin rendered to an invalid value: %w", err)
+		}
+		rendered.PinnedSpkiSha256 = append(rendered.PinnedSpkiSha256, r)
+	}
+
+	return rendered, nil
+}
+
+// GetMinVersion returns the crypto/tls constant for MinVersion, or 0 to use
+// the Go default. Only meaningful on rendered settings.
+func (t *TransportTLS) GetMinVersion() uint16 {
+	if t == nil {
+		return 0
+	}
+	v, _ := ParseTLSVersion(t.MinVersion)
+	return v
+}
+
+// ParseTLSVersion converts a minVersion value such as "1.2" to its crypto/tls
+// constant. The empty string maps to 0.
+func ParseTLSVersion(s string) (uint16, error) {
+	if s == "" {
+		return 0, nil
+	}
+	v, ok := tlsVersions[s]
+	if !ok {
+		return 0, fmt.Errorf("unsupported tls version %q; must be one of 1.0, 1.1, 1.2, 1.3", s)
+	}
+	return v, nil
+}
+
+// DecodeSpkiPin decodes a base64-encoded SHA-256 SPKI pin.
+func DecodeSpkiPin(pin string) ([]byte, error) {
+	b, err := base64.StdEncoding.DecodeString(pin)
+	if err != nil {
+		return nil, fmt.Errorf("pin must be base64 encoded: %w", err)
+	}
+	if len(b) != 32 {
+		return nil, fmt.Errorf("pin must be a sha256 hash (32 bytes), got %d bytes", len(b))
+	}
+	return b, nil
+}
+
+// validateCaCertificates checks that value contains at least one PEM
+// certificate and that every PEM block in it is a parseable certificate.
+func validateCaCertificates(value string) error {
+	rest := []byte(value)
+	found := 0
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			return fmt.Errorf("unexpected PEM block %q; only CERTIFICATE blocks are allowed", block.Type)
+		}
+		if _, err := x509.ParseCertificate(block.Bytes); err != nil {
+			return fmt.Errorf("invalid certificate: %w", err)
+		}
+		found++
+	}
+	if found == 0 {
+		return fmt.Errorf("no PEM certificates found")
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package connectors
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/rmorlok/authproxy/internal/schema/common"
+	"github.com/rmorlok/authproxy/internal/schema/resources/key"
+)
+
+// TransportProxy routes outbound calls through a forward proxy, typically so
+// traffic leaves from a fixed IP an upstream has allowlisted. The same type is
+// used by the connector's transport.proxy and by the global outboundProxy
+// config.
+type TransportProxy struct {
+	// Url is the proxy address. http:// and https:// select an HTTP proxy
+	// (CONNECT for https upstreams); socks5:// and socks5h:// select a SOCKS5
+	// proxy. Credentials must not be embedded in the URL.
+	Url string `json:"url,omitempty" yaml:"url,omitempty"`
+
+	// Username authenticates to the proxy, via Proxy-Authorization basic auth
+	// for HTTP proxies or username/password auth for SOCKS5.
+	Username *common.StringValue `json:"username,omitempty" yaml:"username,omitempty"`
+
+	// Password is read through a key provider so it can be kept in a secrets
+	// manager. Resolved per request, so a rotated password is picked up by new
+	// proxy connections.
+	Password *key.KeyData `json:"password,omitempty" yaml:"password,omitempty" apiredact:"secret"`
+
+	// Direct connects to the upstream without a proxy, opting out of a proxy
+	// the global config would otherwise select. Mutually exclusive with the
+	// other fields.
+	Direct bool `json:"direct,omitempty" yaml:"direct,omitempty"`
+}
+
+func (p *TransportProxy) Clone() *TransportProxy {
+	if p == nil {
+		return nil
+	}
+
+	clone := *p
+	clone.Username = p.Username.CloneValue()
+	return &clone
+}
+
+func (p *TransportProxy) Validate(vc *common.ValidationContext) error {
+	if p == nil {
+		return nil
+	}
+
+	result := &multierror.Error{}
+
+	if p.Direct {
+		if p.Url != "" || p.Username != nil || p.Password != nil {
+			result = multierror.Append(result, vc.NewError("direct cannot be combined with url, username or password"))
+		}
+		return result.ErrorOrNil()
+	}
+
+	if p.Url == "" {
+		result = multierror.Append(result, vc.NewErrorForField("url", "url must be specified unless direct is set"))
+	} else if _, err := ParseProxyUrl(p.Url); err != nil {
+		result = multierror.Append(result, vc.NewErrorfForField("url", "%s", err.Error()))
+	}
+
+	if p.Password != nil && p.Username == nil {
+		result = multierror.Append(result, vc.NewErrorForField("password", "password requires username"))
+	}
+
+	return result.ErrorOrNil()
+}
+
+// ParseProxyUrl parses and checks a proxy URL.
+func ParseProxyUrl(s string) (*url.URL, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy url: %w", err)
+	}
+
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("proxy url scheme must be http, https, socks5 or socks5h, got %q", u.Scheme)
+	}
+
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("proxy url must include a host")
+	}
+	if u.User != nil {
+		return nil, fmt.Errorf("proxy url must not include credentials; use username and password")
+	}
+	if (u.Path != "" && u.Path != "/") || u.RawQuery != "" || u.Fragment != "" {
+		return nil, fmt.Errorf("proxy url must not include a path, query or fragment")
+	}
+
+	return u, nil
+}
+
+// Display identifies the proxy in request events and spans. Never includes
+// credentials.
+func (p *TransportProxy) Display() string {
+	if p == nil || p.Direct {
+		return ""
+	}
+	u, err := ParseProxyUrl(p.Url)
+	if err != nil {
+		return ""
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+// GetProxyUrl returns the proxy URL with the credentials resolved, in the
+// form net/http expects. Returns nil for a direct connection.
+func (p *TransportProxy) GetProxyUrl(ctx context.Context) (*url.URL, error) {
+	if p == nil || p.Direct {
+		return nil, nil
+	}
+
+	u, err := ParseProxyUrl(p.Url)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.Username == nil {
+		return u, nil
+	}
+
+	username, err := p.Username.GetValue(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve proxy username: %w", err)
+	}
+	if p.Password == nil {
+		u.User = url.User(username)
+		return u, nil
+	}
+
+	password, err := p.Password.GetCurrentVersion(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve proxy password: %w", err)
+	}
+	u.User = url.UserPassword(username, string(password.Data))
+	return u, nil
+}
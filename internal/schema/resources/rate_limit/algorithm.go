@@ -1,6 +1,8 @@
 package rate_limit
 
 import (
+	"time"
+
 	"github.com/hashicorp/go-multierror"
 	"github.com/rmorlok/authproxy/internal/schema/common"
 )
@@ -97,12 +99,58 @@ func (t *TokenBucket) Validate(vc *common.ValidationContext) error {
 	return result.ErrorOrNil()
 }
 
-// Algorithm is a tagged union: exactly one of FixedWindow, SlidingWindow, or
-// TokenBucket must be set.
+// DefaultConcurrencyLeaseTtl is how long an in-flight lease is held when
+// Concurrency.LeaseTtl is unset.
+const DefaultConcurrencyLeaseTtl = 5 * time.Minute
+
+// Concurrency rejects once Limit requests are in flight at the same time.
+// Unlike the other algorithms it counts requests that haven't finished
+// rather than requests started: each admitted request holds a lease until
+// its response completes.
+type Concurrency struct {
+	Limit int `json:"limit" yaml:"limit"`
+
+	// LeaseTtl bounds how long a lease outlives a proxy process that died
+	// without releasing it. Leases are renewed while a response is still
+	// streaming, so this doesn't cap request duration. Defaults to
+	// DefaultConcurrencyLeaseTtl.
+	LeaseTtl *common.HumanDuration `json:"leaseTtl,omitempty" yaml:"leaseTtl,omitempty"`
+}
+
+// GetLeaseTtl returns LeaseTtl, or DefaultConcurrencyLeaseTtl when unset.
+func (c *Concurrency) GetLeaseTtl() time.Duration {
+	if c == nil || c.LeaseTtl == nil {
+		return DefaultConcurrencyLeaseTtl
+	}
+
+	return c.LeaseTtl.Duration
+}
+
+// Validate ensures Limit is positive and LeaseTtl, when set, is at least a
+// second.
+func (c *Concurrency) Validate(vc *common.ValidationContext) error {
+	if c == nil {
+		return nil
+	}
+	result := &multierror.Error{}
+
+	if c.Limit <= 0 {
+		result = multierror.Append(result, vc.NewErrorForField("limit", "must be positive"))
+	}
+	if c.LeaseTtl != nil && c.LeaseTtl.Duration < time.Second {
+		result = multierror.Append(result, vc.NewErrorForField("lease_ttl", "must be at least 1s"))
+	}
+	return result.ErrorOrNil()
+}
+
+// Algorithm is a tagged union: exactly one of FixedWindow, SlidingWindow,
+// TokenBucket, Concurrency, or Quota must be set.
 type Algorithm struct {
 	FixedWindow   *FixedWindow   `json:"fixedWindow,omitempty" yaml:"fixedWindow,omitempty"`
 	SlidingWindow *SlidingWindow `json:"slidingWindow,omitempty" yaml:"slidingWindow,omitempty"`
 	TokenBucket   *TokenBucket   `json:"tokenBucket,omitempty" yaml:"tokenBucket,omitempty"`
+	Concurrency   *Concurrency   `json:"concurrency,omitempty" yaml:"concurrency,omitempty"`
+	Quota         *Quota         `json:"quota,omitempty" yaml:"quota,omitempty"`
 }
 
 // Validate ensures exactly one variant is set and that the chosen variant
@@ -120,10 +168,16 @@ func (a *Algorithm) Validate(vc *common.ValidationContext) error {
 	if a.TokenBucket != nil {
 		count++
 	}
+	if a.Concurrency != nil {
+		count++
+	}
+	if a.Quota != nil {
+		count++
+	}
 
 	switch count {
 	case 0:
-		result = multierror.Append(result, vc.NewError("exactly one of fixed_window, sliding_window, or token_bucket must be set"))
+		result = multierror.Append(result, vc.NewError("exactly one of fixed_window, sliding_window, token_bucket, concurrency, or quota must be set"))
 	case 1:
 		// Validate the chosen variant.
 		if err := a.FixedWindow.Validate(vc.PushField("fixed_window")); err != nil {
@@ -135,8 +189,14 @@ func (a *Algorithm) Validate(vc *common.ValidationContext) error {
 		if err := a.TokenBucket.Validate(vc.PushField("token_bucket")); err != nil {
 			result = multierror.Append(result, err)
 		}
+		if err := a.Concurrency.Validate(vc.PushField("concurrency")); err != nil {
+			result = multierror.Append(result, err)
+		}
+		if err := a.Quota.Validate(vc.PushField("quota")); err != nil {
+			result = multierror.Append(result, err)
+		}
 	default:
-		result = multierror.Append(result, vc.NewError("exactly one of fixed_window, sliding_window, or token_bucket must be set"))
+		result = multierror.Append(result, vc.NewError("exactly one of fixed_window, sliding_window, token_bucket, concurrency, or quota must be set"))
 	}
 
 	return result.ErrorOrNil()
@@ -16,6 +16,7 @@ const (
 	DimensionConnectorVersion = "connector_version"
 	DimensionNamespace        = "namespace"
 	DimensionMethod           = "method"
+	DimensionGraphqlOperation = "graphql_operation"
 )
 
 // LabelDimensionPrefix marks a dimension whose value comes from the
@@ -29,6 +30,7 @@ var reservedDimensions = map[string]bool{
 	DimensionConnectorVersion: true,
 	DimensionNamespace:        true,
 	DimensionMethod:           true,
+	DimensionGraphqlOperation: true,
 }
 
 // IsReservedDimension reports whether name refers to a request-context field.
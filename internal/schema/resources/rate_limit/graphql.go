@@ -0,0 +1,158 @@
+package rate_limit
+
+import (
+	"strings"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/rmorlok/authproxy/internal/apgraphql"
+	"github.com/rmorlok/authproxy/internal/schema/common"
+)
+
+// GraphqlMatch restricts a rule to GraphQL requests. The proxy identifies
+// the operation from buffered request bodies; requests it can't identify
+// (non-GraphQL traffic, raw streaming proxy requests) never match. An empty
+// GraphqlMatch matches any identified GraphQL operation.
+type GraphqlMatch struct {
+	// OperationTypes restricts the rule to queries, mutations and/or
+	// subscriptions. Empty / nil means any.
+	OperationTypes []apgraphql.OperationType `json:"operationTypes,omitempty" yaml:"operationTypes,omitempty"`
+
+	// OperationNames restricts the rule to named operations. Empty / nil
+	// means any, including anonymous operations.
+	OperationNames []string `json:"operationNames,omitempty" yaml:"operationNames,omitempty"`
+}
+
+// Validate ensures every operation type is recognised and every name is
+// non-empty.
+func (g *GraphqlMatch) Validate(vc *common.ValidationContext) error {
+	if g == nil {
+		return nil
+	}
+	result := &multierror.Error{}
+
+	for i, t := range g.OperationTypes {
+		if !apgraphql.IsValidOperationType(t) {
+			result = multierror.Append(result, vc.PushField("operation_types").PushIndex(i).NewErrorf("unknown operation type %q", string(t)))
+		}
+	}
+
+	for i, n := range g.OperationNames {
+		if n == "" {
+			result = multierror.Append(result, vc.PushField("operation_names").PushIndex(i).NewError("must not be empty"))
+		}
+	}
+
+	return result.ErrorOrNil()
+}
+
+// Matches reports whether op satisfies the clause. A nil op — a request
+// that wasn't identified as GraphQL — never matches.
+func (g *GraphqlMatch) Matches(op *apgraphql.Operation) bool {
+	if op == nil {
+		return false
+	}
+
+	if len(g.OperationTypes) > 0 {
+		found := false
+		for _, t := range g.OperationTypes {
+			if t == op.Type {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if len(g.OperationNames) > 0 {
+		for _, n := range g.OperationNames {
+			if n == op.Name {
+				return true
+			}
+		}
+		return false
+	}
+
+	return true
+}
+
+// CostProvider names an upstream whose GraphQL responses report the cost of
+// the query in a well-known place.
+type CostProvider string
+
+const (
+	// CostProviderShopify reads extensions.cost.actualQueryCost, falling
+	// back to requestedQueryCost when the actual cost isn't reported (e.g.
+	// the query was throttled).
+	CostProviderShopify CostProvider = "shopify"
+
+	// CostProviderGithub reads data.rateLimit.cost, which GitHub reports
+	// when the query selects the rateLimit field.
+	CostProviderGithub CostProvider = "github"
+)
+
+var costProviderPaths = map[CostProvider][]string{
+	CostProviderShopify: {"extensions.cost.actualQueryCost", "extensions.cost.requestedQueryCost"},
+	CostProviderGithub:  {"data.rateLimit.cost"},
+}
+
+// IsValidCostProvider reports whether p is a recognised CostProvider.
+func IsValidCostProvider(p CostProvider) bool {
+	_, ok := costProviderPaths[p]
+	return ok
+}
+
+// Cost makes a rule charge each request what the upstream says it cost,
+// rather than one unit. The limiter admits the request by charging one unit
+// as usual; once the upstream responds, the rest of the reported cost is
+// charged to the same bucket, so expensive queries push later requests
+// back. Responses that don't report a cost are left at one unit.
+//
+// Exactly one of Provider or Path must be set.
+type Cost struct {
+	// Provider reads the cost from where a known upstream reports it.
+	Provider CostProvider `json:"provider,omitempty" yaml:"provider,omitempty"`
+
+	// Path is a dot-separated path to a number in the JSON response body,
+	// e.g. "extensions.cost.actualQueryCost".
+	Path string `json:"path,omitempty" yaml:"path,omitempty"`
+}
+
+// Paths returns the response paths to read the cost from, in order of
+// preference.
+func (c *Cost) Paths() []string {
+	if c == nil {
+		return nil
+	}
+	if c.Path != "" {
+		return []string{c.Path}
+	}
+	return costProviderPaths[c.Provider]
+}
+
+// Validate ensures exactly one source is set and that it is well-formed.
+func (c *Cost) Validate(vc *common.ValidationContext) error {
+	if c == nil {
+		return nil
+	}
+	result := &multierror.Error{}
+
+	switch {
+	case c.Provider == "" && c.Path == "":
+		result = multierror.Append(result, vc.NewError("one of provider or path must be set"))
+	case c.Provider != "" && c.Path != "":
+		result = multierror.Append(result, vc.NewError("only one of provider or path may be set"))
+	case c.Provider != "" && !IsValidCostProvider(c.Provider):
+		result = multierror.Append(result, vc.NewErrorfForField("provider", "unknown provider %q", string(c.Provider)))
+	case c.Path != "":
+		for _, part := range strings.Split(c.Path, ".") {
+			if part == "" {
+				result = multierror.Append(result, vc.NewErrorfForField("path", "invalid path %q", c.Path))
+				break
+			}
+		}
+	}
+
+	return result.ErrorOrNil()
+}
@@ -0,0 +1,88 @@
+package rate_limit
+
+import (
+	"math"
+	"slices"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/rmorlok/authproxy/internal/schema/common"
+)
+
+// Priority holds part of a rule's capacity back for higher-priority
+// traffic. Reserve maps a priority class to the fraction of capacity only
+// that class and the classes above it may use, so a request may not take
+// the capacity reserved for any class that outranks it. With
+// {interactive: 0.2, normal: 0.1}, interactive requests can use the whole
+// bucket, normal requests all but the last 20% and batch requests all but
+// the last 30%.
+type Priority struct {
+	Reserve map[common.RequestPriority]float64 `json:"reserve" yaml:"reserve"`
+}
+
+// ReservedFrom returns the fraction of capacity held back from requests of
+// the given priority: the sum of the reserves of every class that outranks
+// it. Untagged requests are treated as the default priority.
+func (p *Priority) ReservedFrom(priority common.RequestPriority) float64 {
+	if p == nil {
+		return 0
+	}
+	var reserved float64
+	for class, fraction := range p.Reserve {
+		if class.Outranks(priority) {
+			reserved += fraction
+		}
+	}
+	return reserved
+}
+
+// Floor returns how many of capacity's units requests of the given
+// priority must leave unused, rounded to the nearest unit.
+func (p *Priority) Floor(priority common.RequestPriority, capacity int) int {
+	return int(math.Round(p.ReservedFrom(priority) * float64(capacity)))
+}
+
+// Validate ensures every class is recognised and can hold a reserve, and
+// that the reserves leave some capacity for the lowest class.
+func (p *Priority) Validate(vc *common.ValidationContext) error {
+	if p == nil {
+		return nil
+	}
+	result := &multierror.Error{}
+
+	if len(p.Reserve) == 0 {
+		result = multierror.Append(result, vc.NewErrorForField("reserve", "must reserve capacity for at least one priority"))
+	}
+
+	classes := make([]common.RequestPriority, 0, len(p.Reserve))
+	for class := range p.Reserve {
+		classes = append(classes, class)
+	}
+	slices.Sort(classes)
+
+	var total float64
+	for _, class := range classes {
+		fraction := p.Reserve[class]
+		fvc := vc.PushField("reserve").PushField(string(class))
+		switch {
+		case !common.IsValidRequestPriority(class):
+			result = multierror.Append(result, fvc.NewErrorf("unknown priority %q", string(class)))
+			continue
+		case class == common.RequestPriorityBatch:
+			// Every other class outranks batch, so capacity reserved for it
+			// would be open to all traffic anyway.
+			result = multierror.Append(result, fvc.NewError("batch is the lowest priority and cannot reserve capacity"))
+			continue
+		}
+		if fraction <= 0 || fraction >= 1 {
+			result = multierror.Append(result, fvc.NewError("must be greater than 0 and less than 1"))
+			continue
+		}
+		total += fraction
+	}
+
+	if total >= 1 {
+		result = multierror.Append(result, vc.NewErrorForField("reserve", "reserves must add up to less than 1"))
+	}
+
+	return result.ErrorOrNil()
+}
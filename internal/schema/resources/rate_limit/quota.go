@@ -0,0 +1,102 @@
+package rate_limit
+
+import (
+	"time"
+	// Quota timezones are resolved from the embedded database so they
+	// validate and behave the same on hosts without zoneinfo installed.
+	_ "time/tzdata"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/rmorlok/authproxy/internal/schema/common"
+)
+
+// QuotaPeriod is the calendar period a quota's allowance resets on.
+type QuotaPeriod string
+
+const (
+	QuotaPeriodDay   QuotaPeriod = "day"
+	QuotaPeriodWeek  QuotaPeriod = "week"
+	QuotaPeriodMonth QuotaPeriod = "month"
+)
+
+// IsValidQuotaPeriod reports whether p is a recognised quota period.
+func IsValidQuotaPeriod(p QuotaPeriod) bool {
+	switch p {
+	case QuotaPeriodDay, QuotaPeriodWeek, QuotaPeriodMonth:
+		return true
+	default:
+		return false
+	}
+}
+
+// DefaultQuotaTimezone is used when Quota.Timezone is unset.
+const DefaultQuotaTimezone = "UTC"
+
+// Quota rejects once Limit requests have been counted in the current
+// calendar period. Periods are aligned to the calendar in Timezone: days
+// start at midnight, weeks on Monday, and months on the 1st.
+type Quota struct {
+	Period QuotaPeriod `json:"period" yaml:"period"`
+	Limit  int         `json:"limit" yaml:"limit"`
+
+	// Timezone is the IANA zone periods are aligned in, e.g.
+	// "America/New_York". Defaults to DefaultQuotaTimezone.
+	Timezone string `json:"timezone,omitempty" yaml:"timezone,omitempty"`
+
+	// SoftLimits are percentages of Limit (1-99). A bucket whose usage
+	// reaches one raises a notification on the rate limit, so operators
+	// hear about a quota running out before requests start failing.
+	SoftLimits []int `json:"softLimits,omitempty" yaml:"softLimits,omitempty"`
+}
+
+// GetTimezone returns Timezone, or DefaultQuotaTimezone when unset.
+func (q *Quota) GetTimezone() string {
+	if q == nil || q.Timezone == "" {
+		return DefaultQuotaTimezone
+	}
+	return q.Timezone
+}
+
+// GetLocation resolves GetTimezone. Falls back to UTC for a zone that
+// doesn't load; validation rejects those at write time.
+func (q *Quota) GetLocation() *time.Location {
+	loc, err := time.LoadLocation(q.GetTimezone())
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// Validate ensures Period is recognised, Limit is positive, Timezone
+// loads, and every soft limit is a distinct percentage below 100.
+func (q *Quota) Validate(vc *common.ValidationContext) error {
+	if q == nil {
+		return nil
+	}
+	result := &multierror.Error{}
+
+	if !IsValidQuotaPeriod(q.Period) {
+		result = multierror.Append(result, vc.NewErrorfForField("period", "invalid period %q (expected %q, %q, or %q)", string(q.Period), string(QuotaPeriodDay), string(QuotaPeriodWeek), string(QuotaPeriodMonth)))
+	}
+	if q.Limit <= 0 {
+		result = multierror.Append(result, vc.NewErrorForField("limit", "must be positive"))
+	}
+	if q.Timezone != "" {
+		if _, err := time.LoadLocation(q.Timezone); err != nil {
+			result = multierror.Append(result, vc.NewErrorfForField("timezone", "unknown timezone %q", q.Timezone))
+		}
+	}
+
+	seen := make(map[int]bool, len(q.SoftLimits))
+	for i, pct := range q.SoftLimits {
+		if pct < 1 || pct > 99 {
+			result = multierror.Append(result, vc.PushField("soft_limits").PushIndex(i).NewError("must be between 1 and 99"))
+			continue
+		}
+		if seen[pct] {
+			result = multierror.Append(result, vc.PushField("soft_limits").PushIndex(i).NewErrorf("duplicate soft limit %d", pct))
+		}
+		seen[pct] = true
+	}
+	return result.ErrorOrNil()
+}
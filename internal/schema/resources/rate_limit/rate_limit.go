@@ -9,12 +9,18 @@ import (
 	"github.com/rmorlok/authproxy/internal/schema/common"
 )
 
-// Mode controls whether a matching rule rejects requests or only observes them.
+// Mode controls whether a matching rule rejects requests, holds them until
+// they can be admitted, or only observes them.
 type Mode string
 
 const (
 	ModeEnforce Mode = "enforce"
 	ModeObserve Mode = "observe"
+
+	// ModeShape queues requests the rule would reject and admits them once
+	// the bucket allows, rejecting only those that can't be admitted
+	// within Shaping.MaxWait. Requires Shaping.
+	ModeShape Mode = "shape"
 )
 
 // DefaultMode is used when a RateLimit's Mode is unset.
@@ -23,7 +29,7 @@ const DefaultMode = ModeEnforce
 // IsValidMode reports whether m is a recognised mode value.
 func IsValidMode(m Mode) bool {
 	switch m {
-	case ModeEnforce, ModeObserve:
+	case ModeEnforce, ModeObserve, ModeShape:
 		return true
 	default:
 		return false
@@ -37,6 +43,18 @@ type RateLimit struct {
 	Selector  Selector  `json:"selector" yaml:"selector"`
 	Bucket    Bucket    `json:"bucket" yaml:"bucket"`
 	Algorithm Algorithm `json:"algorithm" yaml:"algorithm"`
+
+	// Cost, when set, charges each request the cost the upstream reports
+	// for it instead of one unit.
+	Cost *Cost `json:"cost,omitempty" yaml:"cost,omitempty"`
+
+	// Shaping configures the queue requests wait in. Required in shape
+	// mode and not allowed in any other.
+	Shaping *Shaping `json:"shaping,omitempty" yaml:"shaping,omitempty"`
+
+	// Priority, when set, reserves part of the rule's capacity for
+	// higher-priority traffic.
+	Priority *Priority `json:"priority,omitempty" yaml:"priority,omitempty"`
 }
 
 // EffectiveMode returns Mode, falling back to DefaultMode when unset.
@@ -72,5 +90,30 @@ func (r *RateLimit) validate(vc *common.ValidationContext) error {
 		result = multierror.Append(result, err)
 	}
 
+	if err := r.Cost.Validate(vc.PushField("cost")); err != nil {
+		result = multierror.Append(result, err)
+	}
+
+	// A concurrency lease is either held or not; there is no count for a
+	// reported cost to add to.
+	if r.Cost != nil && r.Algorithm.Concurrency != nil {
+		result = multierror.Append(result, vc.NewErrorForField("cost", "cannot be combined with the concurrency algorithm"))
+	}
+
+	if err := r.Shaping.Validate(vc.PushField("shaping")); err != nil {
+		result = multierror.Append(result, err)
+	}
+
+	if err := r.Priority.Validate(vc.PushField("priority")); err != nil {
+		result = multierror.Append(result, err)
+	}
+
+	switch {
+	case r.Mode == ModeShape && r.Shaping == nil:
+		result = multierror.Append(result, vc.NewErrorForField("shaping", "is required in shape mode"))
+	case r.Mode != ModeShape && r.Shaping != nil:
+		result = multierror.Append(result, vc.NewErrorForField("shaping", "is only allowed in shape mode"))
+	}
+
 	return result.ErrorOrNil()
 }
@@ -4,6 +4,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/rmorlok/authproxy/internal/apgraphql"
 	"github.com/rmorlok/authproxy/internal/schema/common"
 	"github.com/stretchr/testify/require"
 )
@@ -364,6 +365,181 @@ func TestTokenBucket_Validate_Direct(t *testing.T) {
 	require.Contains(t, err.Error(), "refill_rate")
 }
 
+func TestConcurrency_Validate_Direct(t *testing.T) {
+	require.NoError(t, (*Concurrency)(nil).Validate(vc()))
+
+	ok := &Concurrency{Limit: 5}
+	require.NoError(t, ok.Validate(vc()))
+	require.Equal(t, DefaultConcurrencyLeaseTtl, ok.GetLeaseTtl())
+
+	withTtl := &Concurrency{Limit: 5, LeaseTtl: &common.HumanDuration{Duration: 30 * time.Second}}
+	require.NoError(t, withTtl.Validate(vc()))
+	require.Equal(t, 30*time.Second, withTtl.GetLeaseTtl())
+
+	zeroLimit := &Concurrency{Limit: 0}
+	err := zeroLimit.Validate(vc())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "limit")
+
+	shortTtl := &Concurrency{Limit: 5, LeaseTtl: &common.HumanDuration{Duration: 500 * time.Millisecond}}
+	err = shortTtl.Validate(vc())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "lease_ttl")
+}
+
+func TestQuota_Validate_Direct(t *testing.T) {
+	require.NoError(t, (*Quota)(nil).Validate(vc()))
+
+	ok := &Quota{Period: QuotaPeriodMonth, Limit: 1000}
+	require.NoError(t, ok.Validate(vc()))
+	require.Equal(t, DefaultQuotaTimezone, ok.GetTimezone())
+	require.Equal(t, time.UTC, ok.GetLocation())
+
+	zoned := &Quota{Period: QuotaPeriodDay, Limit: 10, Timezone: "America/New_York", SoftLimits: []int{50, 90}}
+	require.NoError(t, zoned.Validate(vc()))
+	require.Equal(t, "America/New_York", zoned.GetLocation().String())
+
+	badPeriod := &Quota{Period: "year", Limit: 10}
+	err := badPeriod.Validate(vc())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "period")
+
+	zeroLimit := &Quota{Period: QuotaPeriodWeek, Limit: 0}
+	err = zeroLimit.Validate(vc())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "limit")
+
+	badZone := &Quota{Period: QuotaPeriodDay, Limit: 10, Timezone: "Mars/Olympus_Mons"}
+	err = badZone.Validate(vc())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "timezone")
+
+	badSoft := &Quota{Period: QuotaPeriodDay, Limit: 10, SoftLimits: []int{80, 100}}
+	err = badSoft.Validate(vc())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "soft_limits[1]")
+
+	dupSoft := &Quota{Period: QuotaPeriodDay, Limit: 10, SoftLimits: []int{80, 80}}
+	err = dupSoft.Validate(vc())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "duplicate")
+}
+
+func TestRateLimit_Validate_CostRejectsConcurrency(t *testing.T) {
+	rl := RateLimit{
+		Algorithm: Algorithm{Concurrency: &Concurrency{Limit: 5}},
+		Cost:      &Cost{Provider: CostProviderShopify},
+	}
+	err := rl.Validate()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "concurrency")
+}
+
+func TestShaping_Validate_Direct(t *testing.T) {
+	require.NoError(t, (*Shaping)(nil).Validate(vc()))
+
+	ok := &Shaping{MaxWait: common.HumanDuration{Duration: 5 * time.Second}}
+	require.NoError(t, ok.Validate(vc()))
+	require.Equal(t, DefaultShapingMaxQueueDepth, ok.GetMaxQueueDepth())
+
+	ok.MaxQueueDepth = 10
+	require.NoError(t, ok.Validate(vc()))
+	require.Equal(t, 10, ok.GetMaxQueueDepth())
+
+	err := (&Shaping{}).Validate(vc())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "max_wait")
+
+	err = (&Shaping{MaxWait: common.HumanDuration{Duration: 2 * time.Minute}}).Validate(vc())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "max_wait")
+
+	err = (&Shaping{MaxWait: common.HumanDuration{Duration: time.Second}, MaxQueueDepth: MaxShapingMaxQueueDepth + 1}).Validate(vc())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "max_queue_depth")
+}
+
+func TestRateLimit_Validate_ShapingOnlyInShapeMode(t *testing.T) {
+	rl := validRateLimit()
+	rl.Mode = ModeShape
+	err := rl.Validate()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "shaping: is required in shape mode")
+
+	rl.Shaping = &Shaping{MaxWait: common.HumanDuration{Duration: 5 * time.Second}}
+	require.NoError(t, rl.Validate())
+
+	rl.Mode = ModeEnforce
+	err = rl.Validate()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "shaping: is only allowed in shape mode")
+}
+
+func TestPriority_Validate_Direct(t *testing.T) {
+	require.NoError(t, (*Priority)(nil).Validate(vc()))
+
+	ok := &Priority{Reserve: map[common.RequestPriority]float64{
+		common.RequestPriorityInteractive: 0.2,
+		common.RequestPriorityNormal:      0.1,
+	}}
+	require.NoError(t, ok.Validate(vc()))
+
+	cases := []struct {
+		name    string
+		reserve map[common.RequestPriority]float64
+		wantErr string
+	}{
+		{"empty", map[common.RequestPriority]float64{}, "reserve: must reserve capacity for at least one priority"},
+		{"unknown class", map[common.RequestPriority]float64{"urgent": 0.1}, "reserve.urgent"},
+		{"batch", map[common.RequestPriority]float64{common.RequestPriorityBatch: 0.1}, "reserve.batch: batch is the lowest priority"},
+		{"zero", map[common.RequestPriority]float64{common.RequestPriorityNormal: 0}, "reserve.normal: must be greater than 0"},
+		{"whole capacity", map[common.RequestPriority]float64{common.RequestPriorityInteractive: 1}, "reserve.interactive: must be greater than 0 and less than 1"},
+		{"sum too large", map[common.RequestPriority]float64{
+			common.RequestPriorityInteractive: 0.6,
+			common.RequestPriorityNormal:      0.4,
+		}, "reserves must add up to less than 1"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := (&Priority{Reserve: tc.reserve}).Validate(vc())
+			require.Error(t, err)
+			require.Contains(t, err.Error(), tc.wantErr)
+		})
+	}
+}
+
+func TestPriority_Floor(t *testing.T) {
+	p := &Priority{Reserve: map[common.RequestPriority]float64{
+		common.RequestPriorityInteractive: 0.2,
+		common.RequestPriorityNormal:      0.1,
+	}}
+
+	require.Equal(t, 0, p.Floor(common.RequestPriorityInteractive, 10))
+	require.Equal(t, 2, p.Floor(common.RequestPriorityNormal, 10))
+	require.Equal(t, 2, p.Floor("", 10))
+	require.Equal(t, 3, p.Floor(common.RequestPriorityBatch, 10))
+	require.Equal(t, 30, p.Floor(common.RequestPriorityBatch, 100))
+	require.Equal(t, 0, (*Priority)(nil).Floor(common.RequestPriorityBatch, 10))
+}
+
+func TestRateLimit_Validate_Priority(t *testing.T) {
+	rl := validRateLimit()
+	rl.Selector.Priorities = []common.RequestPriority{common.RequestPriorityBatch}
+	rl.Priority = &Priority{Reserve: map[common.RequestPriority]float64{common.RequestPriorityInteractive: 0.25}}
+	require.NoError(t, rl.Validate())
+
+	rl.Selector.Priorities = []common.RequestPriority{"urgent"}
+	err := rl.Validate()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "selector.priorities[0]")
+
+	rl.Selector.Priorities = nil
+	rl.Priority.Reserve[common.RequestPriorityBatch] = 0.1
+	err = rl.Validate()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "priority.reserve.batch")
+}
+
 func TestSelector_Validate_Direct(t *testing.T) {
 	// Empty selector validates — every clause is optional.
 	require.NoError(t, (&Selector{}).Validate(vc()))
@@ -415,3 +591,81 @@ func TestRateLimit_Validate_ErrorPathPrefix(t *testing.T) {
 	require.Error(t, err)
 	require.Contains(t, err.Error(), "selector.methods")
 }
+
+func TestGraphqlMatch_Validate(t *testing.T) {
+	require.NoError(t, (&GraphqlMatch{}).Validate(vc()))
+	require.NoError(t, (&GraphqlMatch{
+		OperationTypes: []apgraphql.OperationType{apgraphql.OperationTypeQuery, apgraphql.OperationTypeMutation},
+		OperationNames: []string{"GetProducts"},
+	}).Validate(vc()))
+
+	err := (&GraphqlMatch{OperationTypes: []apgraphql.OperationType{"fragment"}}).Validate(vc())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), `unknown operation type "fragment"`)
+
+	err = (&GraphqlMatch{OperationNames: []string{"GetProducts", ""}}).Validate(vc())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "operation_names[1]")
+}
+
+func TestGraphqlMatch_Matches(t *testing.T) {
+	getProducts := &apgraphql.Operation{Type: apgraphql.OperationTypeQuery, Name: "GetProducts"}
+	anonymous := &apgraphql.Operation{Type: apgraphql.OperationTypeQuery}
+	createOrder := &apgraphql.Operation{Type: apgraphql.OperationTypeMutation, Name: "CreateOrder"}
+
+	anyOperation := &GraphqlMatch{}
+	require.True(t, anyOperation.Matches(getProducts))
+	require.True(t, anyOperation.Matches(anonymous))
+	require.False(t, anyOperation.Matches(nil))
+
+	queries := &GraphqlMatch{OperationTypes: []apgraphql.OperationType{apgraphql.OperationTypeQuery}}
+	require.True(t, queries.Matches(getProducts))
+	require.False(t, queries.Matches(createOrder))
+
+	named := &GraphqlMatch{OperationNames: []string{"GetProducts", "CreateOrder"}}
+	require.True(t, named.Matches(getProducts))
+	require.True(t, named.Matches(createOrder))
+	require.False(t, named.Matches(anonymous))
+
+	both := &GraphqlMatch{
+		OperationTypes: []apgraphql.OperationType{apgraphql.OperationTypeMutation},
+		OperationNames: []string{"GetProducts", "CreateOrder"},
+	}
+	require.False(t, both.Matches(getProducts))
+	require.True(t, both.Matches(createOrder))
+}
+
+func TestCost_Validate(t *testing.T) {
+	cases := []struct {
+		name    string
+		cost    Cost
+		errPart string
+	}{
+		{"shopify", Cost{Provider: CostProviderShopify}, ""},
+		{"github", Cost{Provider: CostProviderGithub}, ""},
+		{"path", Cost{Path: "extensions.cost.actualQueryCost"}, ""},
+		{"neither", Cost{}, "one of provider or path must be set"},
+		{"both", Cost{Provider: CostProviderShopify, Path: "cost"}, "only one of provider or path"},
+		{"unknown provider", Cost{Provider: "linear"}, `unknown provider "linear"`},
+		{"empty segment", Cost{Path: "extensions..cost"}, "invalid path"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.cost.Validate(vc())
+			if tc.errPart == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			require.Contains(t, err.Error(), tc.errPart)
+		})
+	}
+}
+
+func TestCost_Paths(t *testing.T) {
+	require.Nil(t, (*Cost)(nil).Paths())
+	require.Equal(t, []string{"extensions.cost.actualQueryCost", "extensions.cost.requestedQueryCost"}, (&Cost{Provider: CostProviderShopify}).Paths())
+	require.Equal(t, []string{"data.rateLimit.cost"}, (&Cost{Provider: CostProviderGithub}).Paths())
+	require.Equal(t, []string{"meta.cost"}, (&Cost{Path: "meta.cost"}).Paths())
+}
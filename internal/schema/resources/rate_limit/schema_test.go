@@ -70,6 +70,7 @@ func TestSchema(t *testing.T) {
 			Tests: []testCase{
 				{"enforce", true, `{"test": "enforce"}`},
 				{"observe", true, `{"test": "observe"}`},
+				{"shape", true, `{"test": "shape"}`},
 				{"empty rejected", false, `{"test": ""}`},
 				{"unknown rejected", false, `{"test": "audit"}`},
 				{"wrong type", false, `{"test": 1}`},
@@ -98,9 +99,15 @@ func TestSchema(t *testing.T) {
 				{"methods ok", true, `{"test": {"methods": ["GET", "POST"]}}`},
 				{"unknown method", false, `{"test": {"methods": ["FROBNICATE"]}}`},
 				{"path_match ok", true, `{"test": {"pathMatch": {"kind": "prefix", "value": "/x"}}}`},
+				{"graphql ok", true, `{"test": {"graphql": {"operationTypes": ["mutation"], "operationNames": ["CreateIssue"]}}}`},
+				{"graphql any operation ok", true, `{"test": {"graphql": {}}}`},
+				{"graphql unknown type rejected", false, `{"test": {"graphql": {"operationTypes": ["fragment"]}}}`},
+				{"graphql empty name rejected", false, `{"test": {"graphql": {"operationNames": [""]}}}`},
 				{"request_types ok", true, `{"test": {"requestTypes": ["proxy", "probe"]}}`},
 				{"request_types empty rejected", false, `{"test": {"requestTypes": []}}`},
 				{"request_types unknown rejected", false, `{"test": {"requestTypes": ["bogus"]}}`},
+				{"priorities ok", true, `{"test": {"priorities": ["normal", "batch"]}}`},
+				{"priorities unknown rejected", false, `{"test": {"priorities": ["urgent"]}}`},
 				{"extra prop rejected", false, `{"test": {"unknown": 1}}`},
 			},
 		},
@@ -110,6 +117,7 @@ func TestSchema(t *testing.T) {
 			Tests: []testCase{
 				{"empty ok", true, `{"test": {}}`},
 				{"reserved ok", true, `{"test": {"dimensions": ["actor", "connection"]}}`},
+				{"graphql operation ok", true, `{"test": {"dimensions": ["connection", "graphql_operation"]}}`},
 				{"label key ok", true, `{"test": {"dimensions": ["labels/team"]}}`},
 				{"unknown reserved rejected", false, `{"test": {"dimensions": ["team"]}}`},
 				{"missing label key rejected", false, `{"test": {"dimensions": ["labels/"]}}`},
@@ -150,6 +158,29 @@ func TestSchema(t *testing.T) {
 				{"refill_rate zero", false, `{"test": {"capacity": 10, "refillRate": 0}}`},
 			},
 		},
+		{
+			Name:   "Concurrency",
+			Schema: mkSchema("./schema.json#/$defs/Concurrency"),
+			Tests: []testCase{
+				{"ok", true, `{"test": {"limit": 5}}`},
+				{"with lease ttl ok", true, `{"test": {"limit": 5, "leaseTtl": "30s"}}`},
+				{"missing limit", false, `{"test": {"leaseTtl": "30s"}}`},
+				{"limit zero", false, `{"test": {"limit": 0}}`},
+			},
+		},
+		{
+			Name:   "Quota",
+			Schema: mkSchema("./schema.json#/$defs/Quota"),
+			Tests: []testCase{
+				{"ok", true, `{"test": {"period": "month", "limit": 10000}}`},
+				{"with timezone and soft limits ok", true, `{"test": {"period": "day", "limit": 100, "timezone": "America/New_York", "softLimits": [50, 80]}}`},
+				{"missing period", false, `{"test": {"limit": 100}}`},
+				{"unknown period", false, `{"test": {"period": "year", "limit": 100}}`},
+				{"limit zero", false, `{"test": {"period": "week", "limit": 0}}`},
+				{"soft limit 100 rejected", false, `{"test": {"period": "day", "limit": 100, "softLimits": [100]}}`},
+				{"duplicate soft limits rejected", false, `{"test": {"period": "day", "limit": 100, "softLimits": [80, 80]}}`},
+			},
+		},
 		{
 			Name:   "Algorithm",
 			Schema: mkSchema("./schema.json#/$defs/Algorithm"),
@@ -158,15 +189,48 @@ func TestSchema(t *testing.T) {
 				{"fixed_window only ok", true, `{"test": {"fixedWindow": {"window": "1m", "limit": 10}}}`},
 				{"sliding_window only ok", true, `{"test": {"slidingWindow": {"window": "1m", "limit": 10, "mode": "log"}}}`},
 				{"token_bucket only ok", true, `{"test": {"tokenBucket": {"capacity": 5, "refillRate": 0.5}}}`},
+				{"concurrency only ok", true, `{"test": {"concurrency": {"limit": 5}}}`},
+				{"concurrency with window rejected", false, `{"test": {"concurrency": {"limit": 5}, "fixedWindow": {"window": "1m", "limit": 1}}}`},
+				{"quota only ok", true, `{"test": {"quota": {"period": "month", "limit": 1000}}}`},
 				{"two set rejected", false, `{"test": {"fixedWindow": {"window": "1m", "limit": 1}, "tokenBucket": {"capacity": 1, "refillRate": 1}}}`},
 				{"all set rejected", false, `{"test": {"fixedWindow": {"window": "1m", "limit": 1}, "slidingWindow": {"window": "1m", "limit": 1, "mode": "log"}, "tokenBucket": {"capacity": 1, "refillRate": 1}}}`},
 				{"extra prop rejected", false, `{"test": {"fixedWindow": {"window": "1m", "limit": 1}, "extra": 1}}`},
 			},
 		},
+		{
+			Name:   "Cost",
+			Schema: mkSchema("./schema.json#/$defs/Cost"),
+			Tests: []testCase{
+				{"provider ok", true, `{"test": {"provider": "shopify"}}`},
+				{"path ok", true, `{"test": {"path": "extensions.cost.actualQueryCost"}}`},
+				{"empty rejected", false, `{"test": {}}`},
+				{"both rejected", false, `{"test": {"provider": "github", "path": "data.rateLimit.cost"}}`},
+				{"unknown provider rejected", false, `{"test": {"provider": "linear"}}`},
+				{"empty path segment rejected", false, `{"test": {"path": "extensions..cost"}}`},
+			},
+		},
+		{
+			Name:   "Priority",
+			Schema: mkSchema("./schema.json#/$defs/Priority"),
+			Tests: []testCase{
+				{"ok", true, `{"test": {"reserve": {"interactive": 0.2, "normal": 0.1}}}`},
+				{"single class ok", true, `{"test": {"reserve": {"interactive": 0.5}}}`},
+				{"missing reserve", false, `{"test": {}}`},
+				{"empty reserve rejected", false, `{"test": {"reserve": {}}}`},
+				{"batch rejected", false, `{"test": {"reserve": {"batch": 0.1}}}`},
+				{"zero rejected", false, `{"test": {"reserve": {"interactive": 0}}}`},
+				{"whole capacity rejected", false, `{"test": {"reserve": {"interactive": 1}}}`},
+			},
+		},
 		{
 			Name:   "RateLimit",
 			Schema: mkSchema("./schema.json#/$defs/RateLimit"),
 			Tests: []testCase{
+				{
+					"valid graphql cost",
+					true,
+					`{"test": {"selector": {"graphql": {"operationTypes": ["query"]}}, "bucket": {"dimensions": ["connection"]}, "algorithm": {"tokenBucket": {"capacity": 1000, "refillRate": 50}}, "cost": {"provider": "shopify"}}}`,
+				},
 				{
 					"valid token_bucket",
 					true,
@@ -177,6 +241,26 @@ func TestSchema(t *testing.T) {
 					true,
 					`{"test": {"mode": "observe", "selector": {}, "bucket": {}, "algorithm": {"fixedWindow": {"window": "1m", "limit": 10}}}}`,
 				},
+				{
+					"valid shape mode",
+					true,
+					`{"test": {"mode": "shape", "selector": {}, "bucket": {}, "algorithm": {"tokenBucket": {"capacity": 10, "refillRate": 1}}, "shaping": {"maxWait": "5s", "maxQueueDepth": 20}}}`,
+				},
+				{
+					"valid priority reserve",
+					true,
+					`{"test": {"selector": {"priorities": ["interactive", "normal", "batch"]}, "bucket": {"dimensions": ["connection"]}, "algorithm": {"tokenBucket": {"capacity": 100, "refillRate": 10}}, "priority": {"reserve": {"interactive": 0.2}}}}`,
+				},
+				{
+					"shape mode without shaping",
+					false,
+					`{"test": {"mode": "shape", "selector": {}, "bucket": {}, "algorithm": {"tokenBucket": {"capacity": 10, "refillRate": 1}}}}`,
+				},
+				{
+					"shaping without shape mode",
+					false,
+					`{"test": {"selector": {}, "bucket": {}, "algorithm": {"tokenBucket": {"capacity": 10, "refillRate": 1}}, "shaping": {"maxWait": "5s"}}}`,
+				},
 				{
 					"missing selector",
 					false,
@@ -1,8 +1,11 @@
 package rate_limit
 
 import (
+	"fmt"
 	"net/http"
+	"path"
 	"regexp"
+	"strings"
 
 	"github.com/hashicorp/go-multierror"
 	"github.com/rmorlok/authproxy/internal/schema/common"
@@ -63,6 +66,27 @@ func (p *PathMatch) Validate(vc *common.ValidationContext) error {
 	return result.ErrorOrNil()
 }
 
+// Matches reports whether p matches the URL path. Glob semantics use Go's
+// path.Match, where '*' does not cross '/'; use kind=regex for doublestar
+// behaviour. An error means the expression escaped validation, e.g. a
+// malformed glob.
+func (pm *PathMatch) Matches(p string) (bool, error) {
+	switch pm.Kind {
+	case PathMatchKindPrefix:
+		return strings.HasPrefix(p, pm.Value), nil
+	case PathMatchKindGlob:
+		return path.Match(pm.Value, p)
+	case PathMatchKindRegex:
+		re, err := regexp.Compile(pm.Value)
+		if err != nil {
+			return false, err
+		}
+		return re.MatchString(p), nil
+	default:
+		return false, fmt.Errorf("unknown path match kind %q", pm.Kind)
+	}
+}
+
 // Selector matches proxy/probe requests against a rule's criteria. All
 // non-empty clauses are combined with logical AND.
 type Selector struct {
@@ -77,6 +101,15 @@ type Selector struct {
 	// PathMatch restricts the rule to a path on the final upstream URL.
 	PathMatch *PathMatch `json:"pathMatch,omitempty" yaml:"pathMatch,omitempty"`
 
+	// Graphql restricts the rule to GraphQL requests, optionally by
+	// operation type and name.
+	Graphql *GraphqlMatch `json:"graphql,omitempty" yaml:"graphql,omitempty"`
+
+	// Priorities restricts the rule to requests tagged with specific
+	// priority classes. Untagged requests are normal priority. Empty / nil
+	// means any.
+	Priorities []common.RequestPriority `json:"priorities,omitempty" yaml:"priorities,omitempty"`
+
 	// RequestTypes restricts the rule to specific request types. nil means
 	// "use DefaultRequestTypes()". An explicit empty slice is rejected at
 	// validation so an operator can't accidentally create an inert rule.
@@ -120,6 +153,16 @@ func (s *Selector) Validate(vc *common.ValidationContext) error {
 		result = multierror.Append(result, err)
 	}
 
+	if err := s.Graphql.Validate(vc.PushField("graphql")); err != nil {
+		result = multierror.Append(result, err)
+	}
+
+	for i, p := range s.Priorities {
+		if !common.IsValidRequestPriority(p) {
+			result = multierror.Append(result, vc.PushField("priorities").PushIndex(i).NewErrorf("unknown priority %q", string(p)))
+		}
+	}
+
 	// nil = "use default"; explicit empty = configuration mistake.
 	if s.RequestTypes != nil && len(s.RequestTypes) == 0 {
 		result = multierror.Append(result, vc.NewErrorForField("request_types", "must not be an empty list; omit the field to use the default"))
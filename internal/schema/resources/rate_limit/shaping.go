@@ -0,0 +1,62 @@
+package rate_limit
+
+import (
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/rmorlok/authproxy/internal/schema/common"
+)
+
+const (
+	// DefaultShapingMaxQueueDepth is used when Shaping.MaxQueueDepth is unset.
+	DefaultShapingMaxQueueDepth = 50
+
+	// MaxShapingMaxQueueDepth caps Shaping.MaxQueueDepth. Every queued
+	// request holds a proxy connection open and polls Redis while it waits.
+	MaxShapingMaxQueueDepth = 1000
+
+	// MaxShapingMaxWait caps Shaping.MaxWait. Past this, callers are better
+	// served by a 429 and their own retry policy.
+	MaxShapingMaxWait = time.Minute
+)
+
+// Shaping configures how a shape-mode rule queues the requests its
+// algorithm would reject. Each bucket has its own queue; requests are
+// admitted in order, interleaving actors so one busy actor can't hold the
+// whole queue.
+type Shaping struct {
+	// MaxWait is the longest a request waits in the queue before it is
+	// rejected with a 429.
+	MaxWait common.HumanDuration `json:"maxWait" yaml:"maxWait"`
+
+	// MaxQueueDepth is how many requests can wait per bucket. Requests
+	// arriving at a full queue are rejected immediately.
+	MaxQueueDepth int `json:"maxQueueDepth,omitempty" yaml:"maxQueueDepth,omitempty"`
+}
+
+// GetMaxQueueDepth returns MaxQueueDepth, falling back to
+// DefaultShapingMaxQueueDepth when unset.
+func (s *Shaping) GetMaxQueueDepth() int {
+	if s == nil || s.MaxQueueDepth == 0 {
+		return DefaultShapingMaxQueueDepth
+	}
+	return s.MaxQueueDepth
+}
+
+// Validate ensures MaxWait and MaxQueueDepth are within bounds.
+func (s *Shaping) Validate(vc *common.ValidationContext) error {
+	if s == nil {
+		return nil
+	}
+	result := &multierror.Error{}
+
+	if s.MaxWait.Duration <= 0 {
+		result = multierror.Append(result, vc.NewErrorForField("max_wait", "must be positive"))
+	} else if s.MaxWait.Duration > MaxShapingMaxWait {
+		result = multierror.Append(result, vc.NewErrorfForField("max_wait", "must be at most %s", MaxShapingMaxWait))
+	}
+	if s.MaxQueueDepth < 0 || s.MaxQueueDepth > MaxShapingMaxQueueDepth {
+		result = multierror.Append(result, vc.NewErrorfForField("max_queue_depth", "must be between 1 and %d", MaxShapingMaxQueueDepth))
+	}
+	return result.ErrorOrNil()
+}
@@ -2,6 +2,7 @@ package admin_api
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 	"strings"
 	"sync"
@@ -12,6 +13,7 @@ import (
 	"github.com/rmorlok/authproxy/internal/apgin"
 	"github.com/rmorlok/authproxy/internal/aplog"
 	"github.com/rmorlok/authproxy/internal/config"
+	"github.com/rmorlok/authproxy/internal/forward_proxy"
 	common_routes "github.com/rmorlok/authproxy/internal/routes"
 	"github.com/rmorlok/authproxy/internal/service"
 	_ "github.com/rmorlok/authproxy/internal/service/api/swagger"
@@ -168,6 +170,30 @@ func GetGinServer(dm *service.DependencyManager) (httpServer *http.Server, httpH
 	return service.GetServerAndHealthChecker(server, healthChecker)
 }
 
+// GetForwardProxyServer returns the forward proxy listener, which serves
+// requests through apiHandler, or nil when api.forwardProxy isn't configured.
+func GetForwardProxyServer(dm *service.DependencyManager, apiHandler http.Handler) (*http.Server, error) {
+	fp := dm.GetConfigRoot().Api.ForwardProxy
+	if fp == nil {
+		return nil, nil
+	}
+
+	ca, err := forward_proxy.LoadCertificateAuthority(context.Background(), fp)
+	if err != nil {
+		return nil, err
+	}
+
+	handler, err := forward_proxy.New(apiHandler, ca, dm.GetLogger())
+	if err != nil {
+		return nil, err
+	}
+
+	return &http.Server{
+		Addr:    fmt.Sprintf(":%d", fp.Port()),
+		Handler: handler,
+	}, nil
+}
+
 func Serve(cfg config.C) {
 	dm := service.NewDependencyManager("api", cfg)
 	aplog.SetDefaultLog(dm.GetRootLogger())
@@ -193,6 +219,11 @@ func Serve(cfg config.C) {
 		panic(err)
 	}
 
+	forwardProxy, err := GetForwardProxyServer(dm, server.Handler)
+	if err != nil {
+		panic(err)
+	}
+
 	// Boot the rate-limit cache refresher before serving traffic so the
 	// initial snapshot lands before the proxy starts evaluating rules.
 	stopRateLimitRefresher := dm.StartRateLimitRefresher(context.Background())
@@ -221,6 +252,18 @@ func Serve(cfg config.C) {
 		}()
 	}
 
+	if forwardProxy != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			logger.Info("running forward proxy", "addr", forwardProxy.Addr)
+			err := apgin.RunServer(forwardProxy, logger)
+			if err != nil {
+				logger.Error(err.Error(), "error", err)
+			}
+		}()
+	}
+
 	wg.Wait()
 	logger.Info("API shutting down")
 	defer logger.Info("API shutdown complete")
@@ -25,6 +25,7 @@ import (
 	"github.com/rmorlok/authproxy/internal/encrypt"
 	"github.com/rmorlok/authproxy/internal/httpf"
 	"github.com/rmorlok/authproxy/internal/ratelimit"
+	"github.com/rmorlok/authproxy/internal/responsecache"
 	sconfig "github.com/rmorlok/authproxy/internal/schema/config"
 	"github.com/rmorlok/authproxy/internal/sqlh"
 	"github.com/rmorlok/authproxy/internal/util/pagination"
@@ -460,7 +461,11 @@ func (dm *DependencyManager) GetAppMetricsService() *app_metrics.StorageService
 
 func (dm *DependencyManager) GetRateLimitFactory() *ratelimit.Factory {
 	store := ratelimit.NewStore(dm.GetRedisClient())
-	return ratelimit.NewFactory(store, dm.GetLogger())
+	telemetry, err := ratelimit.NewTelemetry(dm.GetTelemetry(), dm.GetConfigRoot().Telemetry)
+	if err != nil {
+		panic(fmt.Errorf("failed to construct rate limit telemetry: %w", err))
+	}
+	return ratelimit.NewFactory(store, dm.GetLogger()).WithTelemetry(telemetry)
 }
 
 // GetRateLimitEnforcerFactory returns the middleware factory that
@@ -476,6 +481,15 @@ func (dm *DependencyManager) GetRateLimitEnforcerFactory() *ratelimit.EnforcerFa
 	)
 }
 
+// GetResponseCacheFactory returns the middleware factory that serves proxied
+// requests from the per-connection response cache for connectors that
+// declare one.
+func (dm *DependencyManager) GetResponseCacheFactory() *responsecache.Factory {
+	cfg := dm.GetConfigRoot().ResponseCache
+	store := responsecache.NewStore(dm.GetRedisClient(), cfg.GetMaxEntriesPerConnection())
+	return responsecache.NewFactory(store, cfg, dm.GetLogBuilder().WithComponent("response-cache").Build())
+}
+
 func (dm *DependencyManager) GetHttpf() httpf.F {
 	if dm.httpf == nil {
 		// Ordering matters: each entry wraps the previous, so the *last*
@@ -492,6 +506,9 @@ func (dm *DependencyManager) GetHttpf() httpf.F {
 		//     still covered by the telemetry span that wraps it
 		//   - telemetry wraps both rate-limit middlewares so the client
 		//     span covers any retries / rate-limit waits they emit
+		//   - the response cache is outermost so a cache hit neither
+		//     counts against rate limits nor opens a client span, but is
+		//     still recorded by request-event logging
 		// NewTelemetryFactory returns (nil, nil) when telemetry is
 		// disabled, in which case telemetry simply drops out of the chain.
 		middlewares := []httpf.RoundTripperFactory{
@@ -505,6 +522,7 @@ func (dm *DependencyManager) GetHttpf() httpf.F {
 		if telemetryRT != nil {
 			middlewares = append(middlewares, telemetryRT)
 		}
+		middlewares = append(middlewares, dm.GetResponseCacheFactory())
 
 		dm.httpf = httpf.CreateFactory(
 			dm.GetConfig(),
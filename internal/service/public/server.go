@@ -133,6 +133,19 @@ func GetGinServer(dm *service.DependencyManager) (httpServer *http.Server, httpH
 	)
 	routesOauth2.Register(server)
 
+	routesOauth1 := common_routes.NewPublicOauth1Routes(
+		dm.GetConfig(),
+		authService,
+		&root.HostApplication,
+		dm.GetDatabase(),
+		dm.GetRedisClient(),
+		dm.GetCoreService(),
+		dm.GetHttpf(),
+		dm.GetEncryptService(),
+		logger,
+	)
+	routesOauth1.Register(server)
+
 	// /setup/connections/{id}/{advance,abort} — token-authorized
 	// transitions for the schema-defined redirect-step pattern. Requires
 	// session + token: session establishes the actor; token binds that
@@ -63,6 +63,13 @@ export interface Connection {
     updatedAt: string;
 }
 
+export interface ConnectionRateLimitHeadroom {
+    learned: boolean;
+    remaining?: number;
+    limit?: number;
+    resetAt?: string;
+}
+
 export function canBeDisconnected(connection: Connection): boolean {
     return (
         connection.state !== ConnectionState.DISCONNECTING &&
@@ -83,6 +90,7 @@ export enum ConnectionSetupResponseType {
     FORM = 'form',
     COMPLETE = 'complete',
     VERIFYING = 'verifying',
+    DEVICE_CODE = 'device_code',
     ERROR = 'error',
 }
 
@@ -114,6 +122,18 @@ export interface ConnectionSetupVerifyingResponse extends ConnectionSetupRespons
     type: ConnectionSetupResponseType.VERIFYING;
 }
 
+export interface ConnectionSetupDeviceCodeResponse extends ConnectionSetupResponse {
+    type: ConnectionSetupResponseType.DEVICE_CODE;
+    stepId: string;
+    stepTitle?: string;
+    stepDescription?: string;
+    userCode: string;
+    verificationUri: string;
+    verificationUriComplete?: string;
+    expiresAt: string;
+    interval: number;
+}
+
 export interface ConnectionSetupErrorResponse extends ConnectionSetupResponse {
     type: ConnectionSetupResponseType.ERROR;
     error: string;
@@ -136,6 +156,10 @@ export function isVerifyingResponse(response: ConnectionSetupResponse): response
     return response.type === ConnectionSetupResponseType.VERIFYING;
 }
 
+export function isDeviceCodeResponse(response: ConnectionSetupResponse): response is ConnectionSetupDeviceCodeResponse {
+    return response.type === ConnectionSetupResponseType.DEVICE_CODE;
+}
+
 export function isErrorResponse(response: ConnectionSetupResponse): response is ConnectionSetupErrorResponse {
     return response.type === ConnectionSetupResponseType.ERROR;
 }
@@ -353,6 +377,14 @@ export const deleteConnectionAnnotation = (id: string, annotationKey: string) =>
     return client.delete(`/api/v1/connections/${id}/annotations/${annotationKey}`);
 };
 
+/**
+ * Get the request budget learned from the upstream's rate-limit headers for a connection whose connector
+ * configures rate limit headroom
+ */
+export const getConnectionRateLimitHeadroom = (id: string) => {
+    return client.get<ConnectionRateLimitHeadroom>(`/api/v1/connections/${id}/rate-limit-headroom`);
+};
+
 /**
  * Abort a connection that is still in setup
  */
@@ -445,4 +477,5 @@ export const connections = {
     getAnnotation: getConnectionAnnotation,
     putAnnotation: putConnectionAnnotation,
     deleteAnnotation: deleteConnectionAnnotation,
+    getRateLimitHeadroom: getConnectionRateLimitHeadroom,
 };
@@ -1,8 +1,13 @@
 import {AxiosRequestConfig} from 'axios';
 import {client} from './client';
 
-export type MetricsAggregation = 'count' | 'avg' | 'p95';
-export type RequestEventMetricsMetric = 'request_events' | 'request_events.errors' | 'request_events.duration_ms';
+export type MetricsAggregation = 'count' | 'avg' | 'p95' | 'sum';
+export type RequestEventMetricsMetric =
+    | 'request_events'
+    | 'request_events.errors'
+    | 'request_events.duration_ms'
+    | 'request_events.rate_limit_queue_wait_ms'
+    | 'request_events.rate_limit_quota_used';
 export type ResourceMetricsMetric =
     | 'resources.connections'
     | 'resources.actors'
@@ -17,7 +22,8 @@ export type RequestEventMetricsGroupBy =
     | 'method'
     | 'response_status_code'
     | 'response_source'
-    | 'connector_id';
+    | 'connector_id'
+    | 'rate_limit_id';
 export type ResourceMetricsGroupBy =
     | 'state'
     | 'health_state'
@@ -6,6 +6,13 @@
 // that builds an HTTP request to send through an AuthProxy connection
 // should produce one of these.
 
+/**
+ * Rate-limit priority class, highest first. Untagged requests are
+ * `normal`; tagging a request `interactive` requires the
+ * `connections:prioritize` permission.
+ */
+export type RequestPriority = 'interactive' | 'normal' | 'batch';
+
 export interface ProxyRequest {
     url: string;
     method: string;
@@ -23,8 +30,55 @@ export interface ProxyRequest {
     bodyRaw?: string;
     /** JSON body. Alternative to bodyRaw. */
     bodyJson?: unknown;
+    /**
+     * Follow the upstream's pages using the connector's pagination rule
+     * for the url's path. GET only.
+     */
+    paginate?: ProxyPaginate;
+    /**
+     * Priority class for rate limiting. Equivalent to the
+     * X-AuthProxy-Priority header; when both are sent they must agree.
+     */
+    priority?: RequestPriority;
+}
+
+export interface ProxyPaginate {
+    /** Most pages to fetch. Defaults to 10; at most 100. */
+    maxPages?: number;
+    /** Most body bytes to fetch across pages. Defaults to 10MiB; at most 100MiB. */
+    maxBytes?: number;
+    /**
+     * `merge` (default) returns one ProxyResponse whose bodyJson is every
+     * page's items. `ndjson` streams a ProxyPage line per page, then a
+     * ProxyPaginationSummary.
+     */
+    format?: 'merge' | 'ndjson';
+}
+
+export interface ProxyPage {
+    type: 'page';
+    page: number;
+    statusCode: number;
+    headers?: Record<string, string>;
+    items?: unknown[];
+    /** Set instead of items when the upstream answered with an error status. */
+    bodyRaw?: string;
+    bodyJson?: unknown;
+}
+
+export interface ProxyPaginationSummary {
+    type: 'summary';
+    pages: number;
+    items: number;
+    bytes: number;
+    /** A limit stopped pagination while the upstream had more pages. */
+    truncated: boolean;
+    /** Set when a page could not be fetched after streaming began. */
+    error?: { error: string; stackTrace?: string };
 }
 
+export type ProxyPageLine = ProxyPage | ProxyPaginationSummary;
+
 export interface ProxyResponse {
     statusCode: number;
     headers: Record<string, string>;
@@ -32,6 +86,47 @@ export interface ProxyResponse {
     bodyJson?: unknown;
 }
 
+// Batch proxy shapes for POST /connections/_batchProxy. The response is
+// newline-delimited JSON: one BatchProxyResult per connection, in
+// completion order, then a single BatchProxySummary.
+
+export interface BatchProxyRequest {
+    /** Connections to call. Mutually exclusive with labelSelector. */
+    connectionIds?: string[];
+    /** Label selector over configured connections. */
+    labelSelector?: string;
+    /** Namespace matcher narrowing labelSelector. */
+    namespace?: string;
+    /**
+     * Sent through every connection. A path-only url is resolved against
+     * each connection's connector baseUrl.
+     */
+    request: ProxyRequest;
+    /** Connections called at once. Defaults to 10; at most 50. */
+    concurrency?: number;
+}
+
+export interface BatchProxyResult {
+    type: 'result';
+    correlationId: string;
+    connectionId: string;
+    /** Set when the upstream answered, whatever its status. */
+    response?: ProxyResponse;
+    /** Set when the request could not be made through the connection. */
+    errorStatus?: number;
+    error?: { error: string; stackTrace?: string };
+}
+
+export interface BatchProxySummary {
+    type: 'summary';
+    correlationId: string;
+    total: number;
+    succeeded: number;
+    failed: number;
+}
+
+export type BatchProxyLine = BatchProxyResult | BatchProxySummary;
+
 // Valid HTTP methods accepted by the proxy. Mirrors the server's
 // validation set in internal/core/iface/proxy.go.
 export const PROXY_METHODS = [
@@ -1,6 +1,6 @@
 import { client } from './client';
 import { ListResponse } from './common';
-import { ProxyRequest } from './proxy';
+import { ProxyRequest, RequestPriority } from './proxy';
 
 // Rate-limit models. Mirror the server's routes.RateLimitJson shape and
 // the internal rate_limit schema package — kept here verbatim so SDK
@@ -9,6 +9,7 @@ import { ProxyRequest } from './proxy';
 export enum RateLimitMode {
     ENFORCE = 'enforce',
     OBSERVE = 'observe',
+    SHAPE = 'shape',
 }
 
 export enum PathMatchKind {
@@ -36,6 +37,8 @@ export interface RateLimitSelector {
      * rejected at validation.
      */
     requestTypes?: string[];
+    /** Priority classes the rule applies to. Untagged requests are 'normal'. Empty / omitted = any. */
+    priorities?: RequestPriority[];
 }
 
 export interface RateLimitBucket {
@@ -65,6 +68,30 @@ export interface RateLimitTokenBucket {
     refillRate: number;
 }
 
+export interface RateLimitConcurrency {
+    /** Maximum requests in flight at once. */
+    limit: number;
+    /**
+     * Human-duration string. How long a lease survives a proxy process that
+     * died without releasing it; renewed while a response streams. Defaults
+     * to '5m'.
+     */
+    leaseTtl?: string;
+}
+
+export type RateLimitQuotaPeriod = 'day' | 'week' | 'month';
+
+export interface RateLimitQuota {
+    /** Calendar period the allowance resets on. Weeks start on Monday. */
+    period: RateLimitQuotaPeriod;
+    /** Requests allowed per period. */
+    limit: number;
+    /** IANA timezone periods are aligned in (e.g. 'America/New_York'). Defaults to 'UTC'. */
+    timezone?: string;
+    /** Percentages of limit (1-99) at which a bucket raises a notification. */
+    softLimits?: number[];
+}
+
 /**
  * Tagged union — exactly one variant must be set. The server (and the
  * Terraform provider) validate this at write time.
@@ -73,6 +100,26 @@ export interface RateLimitAlgorithm {
     fixedWindow?: RateLimitFixedWindow;
     slidingWindow?: RateLimitSlidingWindow;
     tokenBucket?: RateLimitTokenBucket;
+    concurrency?: RateLimitConcurrency;
+    quota?: RateLimitQuota;
+}
+
+/**
+ * Queue configuration for shape mode. Required when mode is 'shape' and
+ * rejected otherwise.
+ */
+export interface RateLimitShaping {
+    maxWait: string; // e.g. "5s"; at most "1m"
+    maxQueueDepth?: number; // Requests that can wait per bucket; defaults to 50
+}
+
+/**
+ * Capacity held back for higher-priority traffic. Maps 'interactive' and
+ * 'normal' to the fraction of capacity (0-1, exclusive) reserved for them;
+ * a request may not use capacity reserved for a class that outranks it.
+ */
+export interface RateLimitPriority {
+    reserve: Partial<Record<Exclude<RequestPriority, 'batch'>, number>>;
 }
 
 export interface RateLimitDefinition {
@@ -80,6 +127,8 @@ export interface RateLimitDefinition {
     selector: RateLimitSelector;
     bucket: RateLimitBucket;
     algorithm: RateLimitAlgorithm;
+    shaping?: RateLimitShaping;
+    priority?: RateLimitPriority;
 }
 
 export interface RateLimit {
@@ -211,6 +260,38 @@ export const dryRunRateLimit = (req: DryRunRateLimitRequest) => {
     return client.post<DryRunRateLimitResponse>('/api/v1/rate-limits/_dryRun', req);
 };
 
+// --- Quota usage ---
+
+export interface RateLimitQuotaBucketUsage {
+    bucketKey: string;
+    used: number;
+    remaining: number;
+}
+
+export interface RateLimitUsage {
+    rateLimitId: string;
+    periodStart: string;
+    periodEnd: string;
+    limit: number;
+    /** Buckets that have consumed anything this period, including any not returned. */
+    bucketCount: number;
+    /** Most-used first. */
+    buckets: RateLimitQuotaBucketUsage[];
+}
+
+export interface GetRateLimitUsageParams {
+    /** Maximum buckets to return; defaults to 100, at most 1000. */
+    limit?: number;
+}
+
+/**
+ * Get how much of a quota rate limit's allowance each bucket has consumed
+ * in the current period. Only quota rate limits report usage.
+ */
+export const getRateLimitUsage = (id: string, params?: GetRateLimitUsageParams) => {
+    return client.get<RateLimitUsage>(`/api/v1/rate-limits/${id}/usage`, { params });
+};
+
 // --- Label & annotation sub-resources, identical shape to keys. ---
 
 export interface RateLimitLabel {
@@ -254,6 +335,7 @@ export const rateLimits = {
     update: updateRateLimit,
     delete: deleteRateLimit,
     dryRun: dryRunRateLimit,
+    getUsage: getRateLimitUsage,
     getLabels: getRateLimitLabels,
     getLabel: getRateLimitLabel,
     putLabel: putRateLimitLabel,
@@ -15,11 +15,18 @@ export enum RequestType {
 // any 429) came from the 3rd-party. "connector_rate_limiter" means the
 // connector-level reactive 429 limiter short-circuited the request before
 // reaching the 3rd party. "rate_limit" means a proxy-side RateLimit
-// resource matched and rejected the request.
+// resource matched and rejected the request. "upstream_not_allowed" means
+// the request URL did not match the connector's allowedUpstreams and was
+// never sent. "egress_denied" means the egress policy blocked the address the
+// request would have connected to. "cache" means a fresh response was served
+// from the connection's response cache without calling the 3rd party.
 export enum ResponseSource {
     UPSTREAM = 'upstream',
     CONNECTOR_RATE_LIMITER = 'connector_rate_limiter',
     RATE_LIMIT = 'rate_limit',
+    UPSTREAM_NOT_ALLOWED = 'upstream_not_allowed',
+    EGRESS_DENIED = 'egress_denied',
+    CACHE = 'cache',
 }
 
 // A single rate-limit rule that matched a request. The full set of
@@ -28,8 +35,9 @@ export enum ResponseSource {
 // that ultimately rejected the request.
 export interface RateLimitMatch {
     id: string; // The ID of the rate-limit resource that matched
-    mode: string; // 'enforce' or 'observe'
+    mode: string; // 'enforce', 'shape' or 'observe'
     bucket?: Record<string, string>; // Resolved bucket dimensions (dimension name → value)
+    quotaUsed?: number; // Units the request took from a quota rule's allowance
 }
 
 // RequestEventRecord is the log data recorded for every request event. It does not contain header and body data,
@@ -60,6 +68,16 @@ export interface RequestEventRecord {
     requestCancelled?: boolean; // If the caller cancelled the request before the full body was consumed
     fullRequestRecorded?: boolean; // If the full request body was recorded; This means you may be able to get the full request
     labels?: Record<string, string>; // Labels associated with the request (merged from connection and per-request labels)
+    outboundProxy?: string; // The forward proxy the request was routed through, e.g. socks5://proxy.internal:1080; absent for direct connections
+    upgradeProtocol?: string; // The protocol the connection switched to after a 101 response, e.g. websocket; absent for ordinary requests
+    sessionDuration?: number; // How long the upgraded connection stayed open, in milliseconds
+    sessionBytesSent?: number; // Bytes sent from the caller to the upstream over the upgraded connection
+    sessionBytesReceived?: number; // Bytes received from the upstream over the upgraded connection
+    attempt?: number; // 1-based attempt number when the proxy sent the request more than once (retries and the replay after a 401)
+    retryOf?: string; // Request ID of the first attempt, on every later attempt of the same proxied call
+    graphqlOperationType?: 'query' | 'mutation' | 'subscription'; // GraphQL operation type, when the request body was recognised as GraphQL
+    graphqlOperationName?: string; // GraphQL operation name; empty for anonymous operations
+    priority?: 'interactive' | 'normal' | 'batch'; // Rate-limit priority the request was tagged with; omitted when untagged
 
     // Rate-limit attribution. Defaults to ResponseSource.UPSTREAM for any
     // request that was not short-circuited by a rate limiter. The
@@ -68,9 +86,10 @@ export interface RequestEventRecord {
     // connector_rate_limiter responses.
     responseSource?: ResponseSource;
     rateLimitId?: string; // ID of the RateLimit resource that fired (when response_source = rate_limit)
-    rateLimitMode?: string; // 'enforce' or 'observe' (when response_source = rate_limit)
+    rateLimitMode?: string; // 'enforce', 'shape' or 'observe'
     rateLimitBucket?: Record<string, string>; // Resolved bucket dimensions for the firing rule
     rateLimitMatched?: RateLimitMatch[]; // Full set of rate-limit rules that matched this request
+    rateLimitQueueWait?: number; // How long shape-mode rate limits held the request, in milliseconds
 }
 
 // RequestEvent is the full data for a single request event. It contains header and body data.
@@ -129,6 +148,10 @@ export interface ListRequestEventsParams {
     pathRegex?: string; // Changed to string to match Go's format
     responseSource?: ResponseSource; // Filter by who produced the response
     rateLimitId?: string; // Filter for entries that fired a specific RateLimit resource
+    retryOf?: string; // Filter for every attempt of a retried proxy call, given the request ID of its first attempt
+    graphqlOperationType?: 'query' | 'mutation' | 'subscription'; // Filter by GraphQL operation type
+    graphqlOperationName?: string; // Filter by GraphQL operation name
+    priority?: 'interactive' | 'normal' | 'batch'; // Filter by rate-limit priority; 'normal' includes untagged requests
 }
 
 /**
@@ -21,6 +21,7 @@ type RateLimitSelector struct {
 	Methods       []string            `json:"methods,omitempty"`
 	PathMatch     *RateLimitPathMatch `json:"pathMatch,omitempty"`
 	RequestTypes  []string            `json:"requestTypes,omitempty"`
+	Priorities    []string            `json:"priorities,omitempty"`
 }
 
 type RateLimitBucket struct {
@@ -43,6 +44,18 @@ type RateLimitTokenBucket struct {
 	RefillRate float64 `json:"refillRate"`
 }
 
+type RateLimitConcurrency struct {
+	Limit    int    `json:"limit"`
+	LeaseTtl string `json:"leaseTtl,omitempty"`
+}
+
+type RateLimitQuota struct {
+	Period     string `json:"period"`
+	Limit      int    `json:"limit"`
+	Timezone   string `json:"timezone,omitempty"`
+	SoftLimits []int  `json:"softLimits,omitempty"`
+}
+
 // RateLimitAlgorithm is a tagged union: exactly one variant is set per
 // rule. The server's schema validator enforces this at write time; the
 // provider-side ConfigValidator on the resource enforces it at plan time
@@ -51,6 +64,20 @@ type RateLimitAlgorithm struct {
 	FixedWindow   *RateLimitFixedWindow   `json:"fixedWindow,omitempty"`
 	SlidingWindow *RateLimitSlidingWindow `json:"slidingWindow,omitempty"`
 	TokenBucket   *RateLimitTokenBucket   `json:"tokenBucket,omitempty"`
+	Concurrency   *RateLimitConcurrency   `json:"concurrency,omitempty"`
+	Quota         *RateLimitQuota         `json:"quota,omitempty"`
+}
+
+// RateLimitShaping is the queue configuration for shape mode.
+type RateLimitShaping struct {
+	MaxWait       string `json:"maxWait"`
+	MaxQueueDepth int    `json:"maxQueueDepth,omitempty"`
+}
+
+// RateLimitPriority holds a fraction of the rule's capacity back for each
+// priority class named in Reserve.
+type RateLimitPriority struct {
+	Reserve map[string]float64 `json:"reserve"`
 }
 
 // RateLimitDefinition is the JSON-serialised "definition" payload of a
@@ -60,6 +87,8 @@ type RateLimitDefinition struct {
 	Selector  RateLimitSelector  `json:"selector"`
 	Bucket    RateLimitBucket    `json:"bucket"`
 	Algorithm RateLimitAlgorithm `json:"algorithm"`
+	Shaping   *RateLimitShaping  `json:"shaping,omitempty"`
+	Priority  *RateLimitPriority `json:"priority,omitempty"`
 }
 
 // RateLimit is the server's RateLimitJson envelope.
@@ -35,6 +35,8 @@ type RateLimitResourceModel struct {
 	Selector    *rateLimitSelectorModel      `tfsdk:"selector"`
 	Bucket      *rateLimitBucketModel        `tfsdk:"bucket"`
 	Algorithm   *rateLimitAlgorithmModel     `tfsdk:"algorithm"`
+	Shaping     *rateLimitShapingModel       `tfsdk:"shaping"`
+	Priority    *rateLimitPriorityModel      `tfsdk:"priority"`
 	CreatedAt   types.String                 `tfsdk:"created_at"`
 	UpdatedAt   types.String                 `tfsdk:"updated_at"`
 }
@@ -43,6 +45,7 @@ type rateLimitSelectorModel struct {
 	LabelSelector types.String              `tfsdk:"label_selector"`
 	Methods       types.List                `tfsdk:"methods"`
 	RequestTypes  types.List                `tfsdk:"request_types"`
+	Priorities    types.List                `tfsdk:"priorities"`
 	PathMatch     *rateLimitPathMatchModel  `tfsdk:"path_match"`
 }
 
@@ -59,6 +62,8 @@ type rateLimitAlgorithmModel struct {
 	FixedWindow   *rateLimitFixedWindowModel   `tfsdk:"fixed_window"`
 	SlidingWindow *rateLimitSlidingWindowModel `tfsdk:"sliding_window"`
 	TokenBucket   *rateLimitTokenBucketModel   `tfsdk:"token_bucket"`
+	Concurrency   *rateLimitConcurrencyModel   `tfsdk:"concurrency"`
+	Quota         *rateLimitQuotaModel         `tfsdk:"quota"`
 }
 
 type rateLimitFixedWindowModel struct {
@@ -77,6 +82,27 @@ type rateLimitTokenBucketModel struct {
 	RefillRate types.Float64 `tfsdk:"refill_rate"`
 }
 
+type rateLimitConcurrencyModel struct {
+	Limit    types.Int64  `tfsdk:"limit"`
+	LeaseTtl types.String `tfsdk:"lease_ttl"`
+}
+
+type rateLimitQuotaModel struct {
+	Period     types.String `tfsdk:"period"`
+	Limit      types.Int64  `tfsdk:"limit"`
+	Timezone   types.String `tfsdk:"timezone"`
+	SoftLimits types.List   `tfsdk:"soft_limits"`
+}
+
+type rateLimitPriorityModel struct {
+	Reserve types.Map `tfsdk:"reserve"`
+}
+
+type rateLimitShapingModel struct {
+	MaxWait       types.String `tfsdk:"max_wait"`
+	MaxQueueDepth types.Int64  `tfsdk:"max_queue_depth"`
+}
+
 func NewRateLimitResource() resource.Resource {
 	return &RateLimitResource{}
 }
@@ -104,7 +130,7 @@ func (r *RateLimitResource) Schema(_ context.Context, _ resource.SchemaRequest,
 				},
 			},
 			"mode": schema.StringAttribute{
-				Description: "Either 'enforce' (default) or 'observe'. In observe mode the rule evaluates and records matches but never returns a 429.",
+				Description: "One of 'enforce' (default), 'shape' or 'observe'. In shape mode requests the rule would reject are queued until it admits them; requires a shaping block. In observe mode the rule evaluates and records matches but never returns a 429.",
 				Optional:    true,
 				Computed:    true,
 			},
@@ -141,6 +167,11 @@ func (r *RateLimitResource) Schema(_ context.Context, _ resource.SchemaRequest,
 						Optional:    true,
 						ElementType: types.StringType,
 					},
+					"priorities": schema.ListAttribute{
+						Description: "Priority classes (interactive, normal, batch) the rule applies to. Untagged requests are normal. Empty / omitted = any.",
+						Optional:    true,
+						ElementType: types.StringType,
+					},
 				},
 				Blocks: map[string]schema.Block{
 					"path_match": schema.SingleNestedBlock{
@@ -169,7 +200,7 @@ func (r *RateLimitResource) Schema(_ context.Context, _ resource.SchemaRequest,
 				},
 			},
 			"algorithm": schema.SingleNestedBlock{
-				Description: "Tagged union: exactly one of fixed_window, sliding_window, or token_bucket must be set.",
+				Description: "Tagged union: exactly one of fixed_window, sliding_window, token_bucket, concurrency, or quota must be set.",
 				Blocks: map[string]schema.Block{
 					"fixed_window": schema.SingleNestedBlock{
 						Description: "Fixed-window counter. Resets at floor(now/window) boundaries.",
@@ -214,6 +245,64 @@ func (r *RateLimitResource) Schema(_ context.Context, _ resource.SchemaRequest,
 							},
 						},
 					},
+					"concurrency": schema.SingleNestedBlock{
+						Description: "Concurrency limit. Counts requests in flight rather than requests started; each admitted request holds a lease until its response completes.",
+						Attributes: map[string]schema.Attribute{
+							"limit": schema.Int64Attribute{
+								Description: "Maximum requests in flight at once.",
+								Optional:    true,
+							},
+							"lease_ttl": schema.StringAttribute{
+								Description: "How long a lease survives a proxy process that died without releasing it, as a HumanDuration. Renewed while a response streams. Server default: 5m.",
+								Optional:    true,
+							},
+						},
+					},
+					"quota": schema.SingleNestedBlock{
+						Description: "Calendar quota. Counts requests per day, week (starting Monday), or month, resetting on the calendar boundary in the configured timezone.",
+						Attributes: map[string]schema.Attribute{
+							"period": schema.StringAttribute{
+								Description: "'day', 'week', or 'month'.",
+								Optional:    true,
+							},
+							"limit": schema.Int64Attribute{
+								Description: "Maximum requests per period.",
+								Optional:    true,
+							},
+							"timezone": schema.StringAttribute{
+								Description: "IANA timezone periods are aligned in (e.g. 'America/New_York'). Server default: UTC.",
+								Optional:    true,
+							},
+							"soft_limits": schema.ListAttribute{
+								Description: "Percentages of limit (1-99). A bucket crossing one raises a notification on the rate limit.",
+								ElementType: types.Int64Type,
+								Optional:    true,
+							},
+						},
+					},
+				},
+			},
+			"shaping": schema.SingleNestedBlock{
+				Description: "Queue configuration for shape mode. Required when mode is 'shape' and rejected by the server otherwise.",
+				Attributes: map[string]schema.Attribute{
+					"max_wait": schema.StringAttribute{
+						Description: "Longest a request waits in the queue before it is rejected, as a HumanDuration. At most 1m.",
+						Optional:    true,
+					},
+					"max_queue_depth": schema.Int64Attribute{
+						Description: "How many requests can wait per bucket. Server default: 50.",
+						Optional:    true,
+					},
+				},
+			},
+			"priority": schema.SingleNestedBlock{
+				Description: "Capacity held back for higher-priority traffic.",
+				Attributes: map[string]schema.Attribute{
+					"reserve": schema.MapAttribute{
+						Description: "Fraction of capacity (between 0 and 1) reserved for each of interactive and normal. A request may not use capacity reserved for a class that outranks it; reserves must add up to less than 1.",
+						Optional:    true,
+						ElementType: types.Float64Type,
+					},
 				},
 			},
 		},
@@ -362,6 +451,11 @@ func buildDefinition(ctx context.Context, plan *RateLimitResourceModel) (client.
 		} else {
 			def.Selector.RequestTypes = rts
 		}
+		if ps, err := listToStrings(ctx, plan.Selector.Priorities); err != nil {
+			return def, fmt.Errorf("selector.priorities: %w", err)
+		} else {
+			def.Selector.Priorities = ps
+		}
 		if plan.Selector.PathMatch != nil {
 			def.Selector.PathMatch = &client.RateLimitPathMatch{
 				Kind:  plan.Selector.PathMatch.Kind.ValueString(),
@@ -396,9 +490,42 @@ func buildDefinition(ctx context.Context, plan *RateLimitResourceModel) (client.
 				Capacity:   int(plan.Algorithm.TokenBucket.Capacity.ValueInt64()),
 				RefillRate: plan.Algorithm.TokenBucket.RefillRate.ValueFloat64(),
 			}
+		case plan.Algorithm.Concurrency != nil:
+			def.Algorithm.Concurrency = &client.RateLimitConcurrency{
+				Limit:    int(plan.Algorithm.Concurrency.Limit.ValueInt64()),
+				LeaseTtl: plan.Algorithm.Concurrency.LeaseTtl.ValueString(),
+			}
+		case plan.Algorithm.Quota != nil:
+			softLimits, err := listToInts(ctx, plan.Algorithm.Quota.SoftLimits)
+			if err != nil {
+				return def, fmt.Errorf("algorithm.quota.soft_limits: %w", err)
+			}
+			def.Algorithm.Quota = &client.RateLimitQuota{
+				Period:     plan.Algorithm.Quota.Period.ValueString(),
+				Limit:      int(plan.Algorithm.Quota.Limit.ValueInt64()),
+				Timezone:   plan.Algorithm.Quota.Timezone.ValueString(),
+				SoftLimits: softLimits,
+			}
 		}
 	}
 
+	if plan.Shaping != nil {
+		def.Shaping = &client.RateLimitShaping{
+			MaxWait:       plan.Shaping.MaxWait.ValueString(),
+			MaxQueueDepth: int(plan.Shaping.MaxQueueDepth.ValueInt64()),
+		}
+	}
+
+	if plan.Priority != nil {
+		reserve := map[string]float64{}
+		if !plan.Priority.Reserve.IsNull() && !plan.Priority.Reserve.IsUnknown() {
+			if diags := plan.Priority.Reserve.ElementsAs(ctx, &reserve, false); diags.HasError() {
+				return def, fmt.Errorf("priority.reserve: %s", diags.Errors())
+			}
+		}
+		def.Priority = &client.RateLimitPriority{Reserve: reserve}
+	}
+
 	return def, nil
 }
 
@@ -423,6 +550,7 @@ func setRateLimitState(model *RateLimitResourceModel, rl *client.RateLimit) {
 		LabelSelector: optionalString(rl.Definition.Selector.LabelSelector),
 		Methods:       stringsToList(rl.Definition.Selector.Methods),
 		RequestTypes:  stringsToList(rl.Definition.Selector.RequestTypes),
+		Priorities:    stringsToList(rl.Definition.Selector.Priorities),
 	}
 	if rl.Definition.Selector.PathMatch != nil {
 		model.Selector.PathMatch = &rateLimitPathMatchModel{
@@ -453,8 +581,37 @@ func setRateLimitState(model *RateLimitResourceModel, rl *client.RateLimit) {
 			Capacity:   types.Int64Value(int64(rl.Definition.Algorithm.TokenBucket.Capacity)),
 			RefillRate: types.Float64Value(rl.Definition.Algorithm.TokenBucket.RefillRate),
 		}
+	case rl.Definition.Algorithm.Concurrency != nil:
+		algoModel.Concurrency = &rateLimitConcurrencyModel{
+			Limit:    types.Int64Value(int64(rl.Definition.Algorithm.Concurrency.Limit)),
+			LeaseTtl: optionalString(rl.Definition.Algorithm.Concurrency.LeaseTtl),
+		}
+	case rl.Definition.Algorithm.Quota != nil:
+		algoModel.Quota = &rateLimitQuotaModel{
+			Period:     types.StringValue(rl.Definition.Algorithm.Quota.Period),
+			Limit:      types.Int64Value(int64(rl.Definition.Algorithm.Quota.Limit)),
+			Timezone:   optionalString(rl.Definition.Algorithm.Quota.Timezone),
+			SoftLimits: intsToList(rl.Definition.Algorithm.Quota.SoftLimits),
+		}
 	}
 	model.Algorithm = algoModel
+
+	model.Shaping = nil
+	if s := rl.Definition.Shaping; s != nil {
+		model.Shaping = &rateLimitShapingModel{
+			MaxWait:       types.StringValue(s.MaxWait),
+			MaxQueueDepth: types.Int64Null(),
+		}
+		if s.MaxQueueDepth != 0 {
+			model.Shaping.MaxQueueDepth = types.Int64Value(int64(s.MaxQueueDepth))
+		}
+	}
+
+	model.Priority = nil
+	if p := rl.Definition.Priority; p != nil {
+		reserve, _ := types.MapValueFrom(context.Background(), types.Float64Type, p.Reserve)
+		model.Priority = &rateLimitPriorityModel{Reserve: reserve}
+	}
 }
 
 // optionalString returns a Null types.String for an empty input so
@@ -496,12 +653,42 @@ func stringsToList(in []string) types.List {
 	return l
 }
 
+// listToInts is listToStrings for a list of integers.
+func listToInts(ctx context.Context, l types.List) ([]int, error) {
+	if l.IsNull() || l.IsUnknown() {
+		return nil, nil
+	}
+	vals := make([]int64, 0, len(l.Elements()))
+	diags := l.ElementsAs(ctx, &vals, false)
+	if diags.HasError() {
+		return nil, fmt.Errorf("%s", diags.Errors())
+	}
+	out := make([]int, len(vals))
+	for i, v := range vals {
+		out[i] = int(v)
+	}
+	return out, nil
+}
+
+// intsToList is stringsToList for a list of integers.
+func intsToList(in []int) types.List {
+	if len(in) == 0 {
+		return types.ListNull(types.Int64Type)
+	}
+	elements := make([]attr.Value, 0, len(in))
+	for _, v := range in {
+		elements = append(elements, types.Int64Value(int64(v)))
+	}
+	l, _ := types.ListValue(types.Int64Type, elements)
+	return l
+}
+
 // --- algorithm exactly-one validator ---
 
 type exactlyOneAlgorithmValidator struct{}
 
 func (v exactlyOneAlgorithmValidator) Description(_ context.Context) string {
-	return "ensures exactly one of algorithm.fixed_window / sliding_window / token_bucket is set"
+	return "ensures exactly one of algorithm.fixed_window / sliding_window / token_bucket / concurrency / quota is set"
 }
 func (v exactlyOneAlgorithmValidator) MarkdownDescription(ctx context.Context) string {
 	return v.Description(ctx)
@@ -518,7 +705,7 @@ func (v exactlyOneAlgorithmValidator) ValidateResource(ctx context.Context, req
 		resp.Diagnostics.AddAttributeError(
 			path.Root("algorithm"),
 			"Missing algorithm block",
-			"Exactly one of fixed_window, sliding_window, or token_bucket must be set.",
+			"Exactly one of fixed_window, sliding_window, token_bucket, concurrency, or quota must be set.",
 		)
 		return
 	}
@@ -533,13 +720,19 @@ func (v exactlyOneAlgorithmValidator) ValidateResource(ctx context.Context, req
 	if model.Algorithm.TokenBucket != nil {
 		set++
 	}
+	if model.Algorithm.Concurrency != nil {
+		set++
+	}
+	if model.Algorithm.Quota != nil {
+		set++
+	}
 
 	switch set {
 	case 0:
 		resp.Diagnostics.AddAttributeError(
 			path.Root("algorithm"),
 			"No algorithm variant set",
-			"Exactly one of fixed_window, sliding_window, or token_bucket must be set.",
+			"Exactly one of fixed_window, sliding_window, token_bucket, concurrency, or quota must be set.",
 		)
 	case 1:
 		// ok
@@ -547,7 +740,7 @@ func (v exactlyOneAlgorithmValidator) ValidateResource(ctx context.Context, req
 		resp.Diagnostics.AddAttributeError(
 			path.Root("algorithm"),
 			"Multiple algorithm variants set",
-			fmt.Sprintf("Exactly one of fixed_window, sliding_window, or token_bucket must be set; %d are configured.", set),
+			fmt.Sprintf("Exactly one of fixed_window, sliding_window, token_bucket, concurrency, or quota must be set; %d are configured.", set),
 		)
 	}
 }
@@ -2,6 +2,7 @@ package resources
 
 import (
 	"context"
+	"encoding/json"
 	"testing"
 	"time"
 
@@ -108,6 +109,223 @@ func TestBuildDefinition_SlidingWindow(t *testing.T) {
 	}
 }
 
+func TestBuildDefinition_Concurrency(t *testing.T) {
+	plan := &RateLimitResourceModel{
+		Selector: &rateLimitSelectorModel{},
+		Bucket:   &rateLimitBucketModel{},
+		Algorithm: &rateLimitAlgorithmModel{
+			Concurrency: &rateLimitConcurrencyModel{
+				Limit:    types.Int64Value(5),
+				LeaseTtl: types.StringNull(),
+			},
+		},
+	}
+	def, err := buildDefinition(context.Background(), plan)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if def.Algorithm.Concurrency == nil || def.Algorithm.Concurrency.Limit != 5 {
+		t.Errorf("concurrency: %+v", def.Algorithm.Concurrency)
+	}
+
+	b, err := json.Marshal(def.Algorithm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `{"concurrency":{"limit":5}}` {
+		t.Errorf("unset lease_ttl should be omitted so the server default applies; got %s", b)
+	}
+}
+
+func TestSetRateLimitState_Concurrency(t *testing.T) {
+	model := &RateLimitResourceModel{}
+	setRateLimitState(model, &client.RateLimit{
+		Id:        "rl_cc",
+		Namespace: "root",
+		Definition: client.RateLimitDefinition{
+			Algorithm: client.RateLimitAlgorithm{Concurrency: &client.RateLimitConcurrency{Limit: 5, LeaseTtl: "30s"}},
+		},
+	})
+	if model.Algorithm.Concurrency == nil ||
+		model.Algorithm.Concurrency.Limit.ValueInt64() != 5 ||
+		model.Algorithm.Concurrency.LeaseTtl.ValueString() != "30s" {
+		t.Errorf("algorithm/concurrency: %+v", model.Algorithm)
+	}
+}
+
+func TestBuildDefinition_Quota(t *testing.T) {
+	plan := &RateLimitResourceModel{
+		Selector: &rateLimitSelectorModel{},
+		Bucket:   &rateLimitBucketModel{},
+		Algorithm: &rateLimitAlgorithmModel{
+			Quota: &rateLimitQuotaModel{
+				Period:     types.StringValue("month"),
+				Limit:      types.Int64Value(10000),
+				Timezone:   types.StringNull(),
+				SoftLimits: intsToList([]int{80, 95}),
+			},
+		},
+	}
+	def, err := buildDefinition(context.Background(), plan)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := json.Marshal(def.Algorithm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `{"quota":{"period":"month","limit":10000,"softLimits":[80,95]}}` {
+		t.Errorf("unset timezone should be omitted so the server default applies; got %s", b)
+	}
+}
+
+func TestSetRateLimitState_Quota(t *testing.T) {
+	model := &RateLimitResourceModel{}
+	setRateLimitState(model, &client.RateLimit{
+		Id:        "rl_quota",
+		Namespace: "root",
+		Definition: client.RateLimitDefinition{
+			Algorithm: client.RateLimitAlgorithm{Quota: &client.RateLimitQuota{
+				Period:     "week",
+				Limit:      500,
+				Timezone:   "Europe/Berlin",
+				SoftLimits: []int{90},
+			}},
+		},
+	})
+	q := model.Algorithm.Quota
+	if q == nil ||
+		q.Period.ValueString() != "week" ||
+		q.Limit.ValueInt64() != 500 ||
+		q.Timezone.ValueString() != "Europe/Berlin" {
+		t.Fatalf("algorithm/quota: %+v", model.Algorithm)
+	}
+	softLimits, err := listToInts(context.Background(), q.SoftLimits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(softLimits) != 1 || softLimits[0] != 90 {
+		t.Errorf("soft_limits: %v", softLimits)
+	}
+
+	setRateLimitState(model, &client.RateLimit{
+		Id:        "rl_quota",
+		Namespace: "root",
+		Definition: client.RateLimitDefinition{
+			Algorithm: client.RateLimitAlgorithm{Quota: &client.RateLimitQuota{Period: "day", Limit: 5}},
+		},
+	})
+	if !model.Algorithm.Quota.Timezone.IsNull() || !model.Algorithm.Quota.SoftLimits.IsNull() {
+		t.Errorf("unset timezone / soft_limits should be null: %+v", model.Algorithm.Quota)
+	}
+}
+
+func TestBuildDefinition_Shaping(t *testing.T) {
+	plan := &RateLimitResourceModel{
+		Mode:     types.StringValue("shape"),
+		Selector: &rateLimitSelectorModel{},
+		Bucket:   &rateLimitBucketModel{},
+		Algorithm: &rateLimitAlgorithmModel{
+			TokenBucket: &rateLimitTokenBucketModel{Capacity: types.Int64Value(10), RefillRate: types.Float64Value(5)},
+		},
+		Shaping: &rateLimitShapingModel{
+			MaxWait:       types.StringValue("5s"),
+			MaxQueueDepth: types.Int64Null(),
+		},
+	}
+	def, err := buildDefinition(context.Background(), plan)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := json.Marshal(def.Shaping)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `{"maxWait":"5s"}` {
+		t.Errorf("unset max_queue_depth should be omitted so the server default applies; got %s", b)
+	}
+}
+
+func TestSetRateLimitState_Shaping(t *testing.T) {
+	model := &RateLimitResourceModel{}
+	setRateLimitState(model, &client.RateLimit{
+		Id:        "rl_shape",
+		Namespace: "root",
+		Definition: client.RateLimitDefinition{
+			Mode:      "shape",
+			Algorithm: client.RateLimitAlgorithm{TokenBucket: &client.RateLimitTokenBucket{Capacity: 10, RefillRate: 5}},
+			Shaping:   &client.RateLimitShaping{MaxWait: "5s", MaxQueueDepth: 20},
+		},
+	})
+	if model.Shaping == nil ||
+		model.Shaping.MaxWait.ValueString() != "5s" ||
+		model.Shaping.MaxQueueDepth.ValueInt64() != 20 {
+		t.Errorf("shaping: %+v", model.Shaping)
+	}
+
+	setRateLimitState(model, &client.RateLimit{Id: "rl_shape", Namespace: "root"})
+	if model.Shaping != nil {
+		t.Errorf("shaping should clear when the server drops it; got %+v", model.Shaping)
+	}
+}
+
+func TestBuildDefinition_Priority(t *testing.T) {
+	reserve, _ := types.MapValueFrom(context.Background(), types.Float64Type, map[string]float64{"interactive": 0.2})
+	priorities, _ := types.ListValueFrom(context.Background(), types.StringType, []string{"batch"})
+	plan := &RateLimitResourceModel{
+		Selector: &rateLimitSelectorModel{Priorities: priorities},
+		Bucket:   &rateLimitBucketModel{},
+		Algorithm: &rateLimitAlgorithmModel{
+			TokenBucket: &rateLimitTokenBucketModel{Capacity: types.Int64Value(10), RefillRate: types.Float64Value(5)},
+		},
+		Priority: &rateLimitPriorityModel{Reserve: reserve},
+	}
+	def, err := buildDefinition(context.Background(), plan)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := json.Marshal(def.Priority)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `{"reserve":{"interactive":0.2}}` {
+		t.Errorf("priority: got %s", b)
+	}
+	if len(def.Selector.Priorities) != 1 || def.Selector.Priorities[0] != "batch" {
+		t.Errorf("selector.priorities: got %v", def.Selector.Priorities)
+	}
+}
+
+func TestSetRateLimitState_Priority(t *testing.T) {
+	model := &RateLimitResourceModel{}
+	setRateLimitState(model, &client.RateLimit{
+		Id:        "rl_priority",
+		Namespace: "root",
+		Definition: client.RateLimitDefinition{
+			Selector:  client.RateLimitSelector{Priorities: []string{"normal", "batch"}},
+			Algorithm: client.RateLimitAlgorithm{TokenBucket: &client.RateLimitTokenBucket{Capacity: 10, RefillRate: 5}},
+			Priority:  &client.RateLimitPriority{Reserve: map[string]float64{"interactive": 0.2, "normal": 0.1}},
+		},
+	})
+	if model.Priority == nil || len(model.Priority.Reserve.Elements()) != 2 {
+		t.Fatalf("priority: %+v", model.Priority)
+	}
+	if len(model.Selector.Priorities.Elements()) != 2 {
+		t.Errorf("selector.priorities: %v", model.Selector.Priorities)
+	}
+
+	setRateLimitState(model, &client.RateLimit{Id: "rl_priority", Namespace: "root"})
+	if model.Priority != nil {
+		t.Errorf("priority should clear when the server drops it; got %+v", model.Priority)
+	}
+	if !model.Selector.Priorities.IsNull() {
+		t.Errorf("selector.priorities should be null when the server drops it; got %v", model.Selector.Priorities)
+	}
+}
+
 func TestBuildDefinition_EmptyOptionalFieldsOmitted(t *testing.T) {
 	// Confirm that null/empty optional fields don't end up serialised
 	// into the request body — the JSON encoder's omitempty + our nil
@@ -244,6 +462,14 @@ func TestAlgorithmValidator_ExactlyOne(t *testing.T) {
 			},
 			1,
 		},
+		{
+			"concurrency with a window",
+			&rateLimitAlgorithmModel{
+				Concurrency: &rateLimitConcurrencyModel{Limit: types.Int64Value(5)},
+				FixedWindow: &rateLimitFixedWindowModel{Window: types.StringValue("1m"), Limit: types.Int64Value(1)},
+			},
+			1,
+		},
 		{
 			"three variants",
 			&rateLimitAlgorithmModel{
@@ -281,6 +507,9 @@ func countAlgorithmVariants(m *rateLimitAlgorithmModel) int {
 	if m.TokenBucket != nil {
 		n++
 	}
+	if m.Concurrency != nil {
+		n++
+	}
 	return n
 }
 